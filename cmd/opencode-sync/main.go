@@ -16,6 +16,6 @@ var (
 func main() {
 	cli.SetVersionInfo(version, commit, date)
 	if err := cli.Execute(); err != nil {
-		os.Exit(1)
+		os.Exit(cli.ExitCode(err))
 	}
 }
@@ -0,0 +1,83 @@
+// Package capability records what the running build supports, so that
+// packaging and support tooling can query it through `version --json`.
+package capability
+
+import (
+	"sort"
+	"sync"
+)
+
+// Capability kinds
+const (
+	// KindBackend is a storage or transport backend
+	KindBackend = "backend"
+
+	// KindEncryption is an encryption scheme
+	KindEncryption = "encryption"
+
+	// KindFeature is an optional feature
+	KindFeature = "feature"
+)
+
+// Capability describes one thing this build can do
+type Capability struct {
+	// Name identifies the capability, e.g. "git"
+	Name string `json:"name"`
+
+	// Kind is one of the Kind constants
+	Kind string `json:"kind"`
+
+	// Description is a short human-readable summary
+	Description string `json:"description"`
+}
+
+var (
+	mu       sync.Mutex
+	registry = map[string]Capability{}
+)
+
+// Register adds a capability, replacing any with the same name
+func Register(c Capability) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	registry[c.Name] = c
+}
+
+// Has reports whether a capability is registered
+func Has(name string) bool {
+	mu.Lock()
+	defer mu.Unlock()
+
+	_, ok := registry[name]
+	return ok
+}
+
+// All returns every registered capability sorted by kind and name
+func All() []Capability {
+	mu.Lock()
+	defer mu.Unlock()
+
+	caps := make([]Capability, 0, len(registry))
+	for _, c := range registry {
+		caps = append(caps, c)
+	}
+	sort.Slice(caps, func(i, j int) bool {
+		if caps[i].Kind != caps[j].Kind {
+			return caps[i].Kind < caps[j].Kind
+		}
+		return caps[i].Name < caps[j].Name
+	})
+	return caps
+}
+
+// Names returns the names of registered capabilities of the given kind
+func Names(kind string) []string {
+	var names []string
+	for _, c := range All() {
+		if c.Kind == kind {
+			names = append(names, c.Name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/GareArc/opencode-sync/internal/sync"
+	"github.com/GareArc/opencode-sync/internal/ui"
+	"github.com/charmbracelet/huh"
+)
+
+// adoptAsk asks about each local file that differs from a fresh clone
+const adoptAsk = "ask"
+
+// cloneAdopt is set by 'clone --adopt'
+var cloneAdopt = adoptAsk
+
+// adoptLocalFiles settles the local files that differ from a fresh clone
+// before the repo is applied over them: each is kept, replaced or merged
+// as 'clone --adopt' says or the user picks, and the files kept or merged
+// are committed. It returns how many were committed.
+func adoptLocalFiles(syncer *sync.Syncer) (int, error) {
+	// Applying refuses a repo this build does not understand, so nothing
+	// is committed to it here
+	if err := syncer.CheckManifest("HEAD"); err != nil {
+		return 0, nil
+	}
+
+	conflicts, err := syncer.AdoptionConflicts()
+	if err != nil {
+		return 0, err
+	}
+	if len(conflicts) == 0 {
+		return 0, nil
+	}
+
+	mode := cloneAdopt
+	if mode == adoptAsk && noPrompt {
+		mode = sync.AdoptRemote
+	}
+	if mode == sync.AdoptRemote {
+		ui.Warn(fmt.Sprintf("%d local files differ from the repo and will be replaced. They are backed up first.", len(conflicts)))
+		return 0, nil
+	}
+
+	ui.Info(fmt.Sprintf("%d local files differ from the repo", len(conflicts)))
+
+	var adopted []string
+	for _, relPath := range conflicts {
+		choice := mode
+		if choice == sync.AdoptMerge && !syncer.Mergeable(relPath) {
+			// --adopt merge keeps what it cannot merge
+			choice = sync.AdoptLocal
+		}
+		if mode == adoptAsk {
+			if choice, err = askAdoption(relPath, syncer.Mergeable(relPath)); err != nil {
+				return 0, err
+			}
+		}
+
+		err := syncer.Adopt(relPath, choice)
+		if errors.Is(err, sync.ErrAdoptConflict) {
+			ui.Warn(fmt.Sprintf("%s can't be merged: %v", relPath, sync.ErrAdoptConflict))
+			choice = sync.AdoptLocal
+			if mode == adoptAsk {
+				if choice, err = askAdoption(relPath, false); err != nil {
+					return 0, err
+				}
+			}
+			err = syncer.Adopt(relPath, choice)
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		if choice != sync.AdoptRemote {
+			adopted = append(adopted, relPath)
+		}
+		if verbose {
+			fmt.Printf("  %s: %s\n", relPath, describeAdoption(choice))
+		}
+	}
+
+	if err := syncer.CommitAdopted(adopted); err != nil {
+		return 0, fmt.Errorf("failed to commit adopted files: %w", err)
+	}
+	return len(adopted), nil
+}
+
+// askAdoption asks how to settle one local file
+func askAdoption(relPath string, mergeable bool) (string, error) {
+	options := []huh.Option[string]{
+		huh.NewOption(describeAdoption(sync.AdoptLocal), sync.AdoptLocal),
+		huh.NewOption(describeAdoption(sync.AdoptRemote), sync.AdoptRemote),
+	}
+	if mergeable {
+		options = append(options, huh.NewOption(describeAdoption(sync.AdoptMerge), sync.AdoptMerge))
+	}
+
+	return ui.Select(
+		fmt.Sprintf("%s differs from the repo", relPath),
+		"Local files that are replaced are backed up first.",
+		options,
+	)
+}
+
+// describeAdoption names an adoption choice
+func describeAdoption(choice string) string {
+	switch choice {
+	case sync.AdoptLocal:
+		return "Keep local"
+	case sync.AdoptRemote:
+		return "Take remote"
+	}
+	return "Merge both"
+}
@@ -0,0 +1,143 @@
+package cli
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/GareArc/opencode-sync/internal/sync"
+	"github.com/spf13/cobra"
+)
+
+var (
+	auditPaths  []string
+	auditSince  string
+	auditUntil  string
+	auditFormat string
+)
+
+// auditCmd represents the audit command
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Report who changed which synced files, and when",
+	Long: `Reconstruct from the history of the active channel a chronological
+report of which user and machine changed which synced files. The user is
+the commit author and the machine the Host trailer opencode-sync adds to
+its commits.
+
+Encrypted files are listed by name, without their .age suffix; their
+content is never decrypted. Renamed files are listed once, with their old
+and new path, and --path matches either.
+
+--since and --until take a date (2006-01-02, inclusive) or an RFC 3339
+time. --format csv or json writes the report for other tools.
+
+The sync repo is cloned without its history, so the first audit fetches
+it from the remote.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runAudit(cmd.Context(), auditPaths, auditSince, auditUntil, auditFormat)
+	},
+}
+
+func init() {
+	auditCmd.Flags().StringArrayVar(&auditPaths, "path", nil, "only report paths matching this glob, file name or directory (repeatable)")
+	auditCmd.Flags().StringVar(&auditSince, "since", "", "only report changes from this date or time on")
+	auditCmd.Flags().StringVar(&auditUntil, "until", "", "only report changes up to this date or time")
+	auditCmd.Flags().StringVar(&auditFormat, "format", "table", "output format: table, csv or json")
+}
+
+// parseAuditTime parses a --since or --until value. A date alone means
+// the start of that day, or with end set the end of it, in local time.
+func parseAuditTime(value string, end bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if day, err := time.ParseInLocation(time.DateOnly, value, time.Local); err == nil {
+		if end {
+			return day.AddDate(0, 0, 1).Add(-time.Second), nil
+		}
+		return day, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q: use a date like 2006-01-02 or an RFC 3339 time", value)
+	}
+	return t, nil
+}
+
+func runAudit(ctx context.Context, paths []string, since, until, format string) error {
+	if format != "table" && format != "csv" && format != "json" {
+		return fmt.Errorf("invalid format %q: use table, csv or json", format)
+	}
+
+	filter := sync.AuditFilter{Paths: paths}
+	var err error
+	if filter.Since, err = parseAuditTime(since, false); err != nil {
+		return err
+	}
+	if filter.Until, err = parseAuditTime(until, true); err != nil {
+		return err
+	}
+
+	repo, err := openSyncRepo()
+	if err != nil {
+		return err
+	}
+
+	entries, err := sync.Audit(ctx, repo, filter)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "json":
+		if entries == nil {
+			entries = []sync.AuditEntry{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	case "csv":
+		w := csv.NewWriter(os.Stdout)
+		_ = w.Write([]string{"time", "commit", "author", "email", "host", "change", "path", "encrypted", "old_path"})
+		for _, entry := range entries {
+			_ = w.Write([]string{
+				entry.Time.Format(time.RFC3339), entry.Commit, entry.Author, entry.Email,
+				entry.Host, entry.Change, entry.Path, strconv.FormatBool(entry.Encrypted), entry.OldPath,
+			})
+		}
+		w.Flush()
+		return w.Error()
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("No changes found")
+		return nil
+	}
+
+	for _, entry := range entries {
+		host := entry.Host
+		if host == "" {
+			host = "unknown host"
+		}
+		path := entry.Path
+		if entry.OldPath != "" {
+			path = entry.OldPath + " → " + path
+		}
+		if entry.Encrypted {
+			path += " (encrypted)"
+		}
+		fmt.Printf("%s  %s  %-8s  %s <%s> on %s  %s\n",
+			entry.Time.Local().Format("2006-01-02 15:04"), entry.Commit, entry.Change,
+			entry.Author, entry.Email, host, path)
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/GareArc/opencode-sync/internal/paths"
+	"github.com/GareArc/opencode-sync/internal/sync"
+	"github.com/GareArc/opencode-sync/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// browseNoFetch is set by 'browse --no-fetch'
+var browseNoFetch bool
+
+// browseRaw is set by 'browse --raw'
+var browseRaw bool
+
+// browseCmd represents the browse command
+var browseCmd = &cobra.Command{
+	Use:   "browse [path]",
+	Short: "Inspect what is stored on the remote",
+	Long: `List a directory or print a file as it is on the remote, without pulling.
+
+The remote branch is fetched first. Encrypted .age files are decrypted in
+memory with your key; nothing is written to disk. Use --raw to print the
+ciphertext instead.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var target string
+		if len(args) > 0 {
+			target = args[0]
+		}
+		return runBrowse(cmd.Context(), target)
+	},
+}
+
+func init() {
+	browseCmd.Flags().BoolVar(&browseNoFetch, "no-fetch", false, "show the remote as it was last fetched")
+	browseCmd.Flags().BoolVar(&browseRaw, "raw", false, "print .age files without decrypting them")
+}
+
+func runBrowse(ctx context.Context, target string) error {
+	syncer, err := initSyncer()
+	if err != nil {
+		return err
+	}
+
+	p, _ := paths.Get()
+	repo := sync.NewRepo(p)
+	if err := repo.Open(); err != nil {
+		return err
+	}
+
+	branch, err := repo.GetBranch()
+	if err != nil {
+		return err
+	}
+
+	// No spinner, so a printed file can be piped
+	if !browseNoFetch {
+		if err := repo.Fetch(ctx); err != nil {
+			ui.Warn(fmt.Sprintf("Showing the remote as last fetched: %v", err))
+		}
+	}
+
+	rev := "origin/" + branch
+	if _, err := repo.RevParse(rev); err != nil {
+		return fmt.Errorf("branch %s has not been pushed or fetched yet", branch)
+	}
+
+	target = path.Clean("/" + strings.TrimSpace(target))[1:]
+
+	data, err := repo.ReadFileAt(rev, target)
+	if target != "" && err == nil {
+		if strings.HasSuffix(target, ".age") && !browseRaw {
+			if data, err = syncer.DecryptRepoFile(target, data); err != nil {
+				return err
+			}
+		}
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	entries, err := repo.ListTreeAt(rev, target)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%s does not exist on %s", target, rev)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		switch {
+		case entry.IsDir:
+			fmt.Printf("  %s/\n", entry.Name)
+		case strings.HasSuffix(entry.Name, ".age"):
+			fmt.Printf("  %-40s %10s  (encrypted)\n", entry.Name, formatBytes(entry.Size))
+		default:
+			fmt.Printf("  %-40s %10s\n", entry.Name, formatBytes(entry.Size))
+		}
+	}
+
+	return nil
+}
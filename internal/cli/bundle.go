@@ -0,0 +1,162 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/GareArc/opencode-sync/internal/git"
+	"github.com/GareArc/opencode-sync/internal/paths"
+	"github.com/GareArc/opencode-sync/internal/sync"
+	"github.com/GareArc/opencode-sync/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// bundleFull is set by 'bundle create --full'
+var bundleFull bool
+
+// bundleCmd represents the bundle command
+var bundleCmd = &cobra.Command{
+	Use:   "bundle",
+	Short: "Sync machines without a shared remote using bundle files",
+	Long: `Exchange changes as files, for machines that never share a network or
+remote (e.g. over a USB stick).
+
+'bundle create' commits local changes and writes every commit since the last
+exchange to a git bundle. 'bundle apply' on the other machine merges it and
+applies it to OpenCode like a pull. Bundles hold the same content as the
+repo, so encrypted files stay encrypted.`,
+}
+
+var bundleCreateCmd = &cobra.Command{
+	Use:   "create <file>",
+	Short: "Write local changes to a bundle file",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runBundleCreate(cmd.Context(), args[0])
+	},
+}
+
+var bundleApplyCmd = &cobra.Command{
+	Use:   "apply <file>",
+	Short: "Merge a bundle file and apply it to OpenCode",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runBundleApply(cmd.Context(), args[0])
+	},
+}
+
+func init() {
+	bundleCreateCmd.Flags().BoolVar(&bundleFull, "full", false, "include the whole history, for a machine that has not received a bundle from here")
+
+	bundleCmd.AddCommand(bundleCreateCmd)
+	bundleCmd.AddCommand(bundleApplyCmd)
+}
+
+func runBundleCreate(ctx context.Context, file string) error {
+	syncer, err := initSyncer()
+	if err != nil {
+		return err
+	}
+
+	if err := ui.SpinnerWithResult("Copying config files to sync repo", func() error {
+		return syncer.CopyToRepo(ctx)
+	}); err != nil {
+		return fmt.Errorf("failed to copy files: %w", err)
+	}
+	reportSkippedBinaries(syncer)
+
+	p, _ := paths.Get()
+	repo := sync.NewRepo(p)
+	if err := repo.Open(); err != nil {
+		return err
+	}
+
+	hasChanges, err := repo.HasChanges()
+	if err != nil {
+		return fmt.Errorf("failed to check for changes: %w", err)
+	}
+	if hasChanges {
+		if err := repo.AddAll(); err != nil {
+			return fmt.Errorf("failed to stage changes: %w", err)
+		}
+
+		commitMsg := sync.CommitMessage(fmt.Sprintf("Sync from %s at %s", sync.Hostname(), time.Now().Format("2006-01-02 15:04:05")))
+		if err := repo.Commit(commitMsg); err != nil {
+			return fmt.Errorf("failed to commit: %w", err)
+		}
+	}
+
+	head, err := repo.GetHead()
+	if err != nil {
+		return err
+	}
+
+	var base string
+	if !bundleFull {
+		base, _ = repo.RevParse(git.BundleBaseRef)
+	}
+	if base == head {
+		ui.Info("Nothing new since the last bundle. Use --full to write the whole history.")
+		return nil
+	}
+
+	if err := repo.CreateBundle(file, base); err != nil {
+		return err
+	}
+
+	// The next bundle builds on what the other machine receives now
+	if err := repo.SetBundleBase(head); err != nil {
+		return fmt.Errorf("failed to record bundle: %w", err)
+	}
+
+	if base == "" {
+		ui.Success(fmt.Sprintf("Wrote the full history to %s", file))
+	} else {
+		ui.Success(fmt.Sprintf("Wrote changes since %s to %s", base[:7], file))
+	}
+	return nil
+}
+
+func runBundleApply(ctx context.Context, file string) error {
+	syncer, err := initSyncer()
+	if err != nil {
+		return err
+	}
+
+	p, _ := paths.Get()
+	repo := sync.NewRepo(p)
+	if err := repo.Open(); err != nil {
+		return err
+	}
+
+	hasChanges, err := repo.HasChanges()
+	if err != nil {
+		return fmt.Errorf("failed to check for changes: %w", err)
+	}
+	if hasChanges {
+		return fmt.Errorf("local changes detected. Commit or discard them before applying a bundle")
+	}
+
+	headBefore, _ := repo.GetHead()
+
+	var tip string
+	if err := ui.SpinnerWithResult("Merging bundle", func() error {
+		var err error
+		tip, err = repo.ApplyBundle(file)
+		return err
+	}); err != nil {
+		var conflictErr *git.ConflictError
+		if errors.As(err, &conflictErr) {
+			return fmt.Errorf("%w. Please resolve manually", conflictErr)
+		}
+		return fmt.Errorf("failed to apply bundle: %w", err)
+	}
+
+	if err := repo.SetBundleBase(tip); err != nil {
+		return fmt.Errorf("failed to record bundle: %w", err)
+	}
+
+	return applyPulled(ctx, syncer, repo, headBefore, "bundle apply "+shellQuote(file))
+}
@@ -0,0 +1,253 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/GareArc/opencode-sync/internal/config"
+	"github.com/GareArc/opencode-sync/internal/crypto"
+	"github.com/GareArc/opencode-sync/internal/paths"
+	"github.com/GareArc/opencode-sync/internal/sync"
+	"github.com/GareArc/opencode-sync/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// passphraseEnv supplies the setup capsule passphrase without a prompt
+const passphraseEnv = "OPENCODE_SYNC_PASSPHRASE"
+
+var setupExportCmd = &cobra.Command{
+	Use:   "export <file>",
+	Short: "Write config, key and remote to an encrypted setup capsule",
+	Long: `Write everything needed to set up opencode-sync on another machine to a
+passphrase-encrypted file: config.json, the encryption key and the remote.
+
+The file is ASCII-armored, so it can also be kept in a password manager.
+The host key used by sync.hostSecrets is never exported. Set
+` + passphraseEnv + ` to skip the passphrase prompt.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runSetupExport(args[0])
+	},
+}
+
+var setupImportCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Restore a setup capsule and clone the sync repo",
+	Long: `Restore config.json and the encryption key from a setup capsule written
+by 'setup export', then clone the sync repo and apply it to OpenCode.
+
+Set ` + passphraseEnv + ` to skip the passphrase prompt.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runSetupImport(cmd.Context(), args[0])
+	},
+}
+
+func init() {
+	setupCmd.AddCommand(setupExportCmd)
+	setupCmd.AddCommand(setupImportCmd)
+}
+
+// capsuleVersion is the current setup capsule format
+const capsuleVersion = 1
+
+// setupCapsule is the content of an exported setup
+type setupCapsule struct {
+	Version int `json:"version"`
+
+	// Host, Created and ToolVersion describe where the capsule came from
+	Host        string    `json:"host"`
+	Created     time.Time `json:"created"`
+	ToolVersion string    `json:"toolVersion"`
+
+	Config    json.RawMessage `json:"config"`
+	Key       string          `json:"key,omitempty"`
+	RemoteURL string          `json:"remoteUrl"`
+}
+
+// capsulePassphrase reads the passphrase from the environment or asks for
+// it, twice when confirm is set
+func capsulePassphrase(confirm bool) (string, error) {
+	if passphrase := os.Getenv(passphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+	if noPrompt {
+		return "", fmt.Errorf("set %s to provide the passphrase without prompts", passphraseEnv)
+	}
+
+	passphrase, err := ui.Password("Capsule passphrase", "Protects your encryption key; you need it to import the capsule")
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", fmt.Errorf("passphrase must not be empty")
+	}
+
+	if confirm {
+		again, err := ui.Password("Repeat passphrase", "")
+		if err != nil {
+			return "", err
+		}
+		if again != passphrase {
+			return "", fmt.Errorf("passphrases do not match")
+		}
+	}
+
+	return passphrase, nil
+}
+
+func runSetupExport(file string) error {
+	p, err := paths.Get()
+	if err != nil {
+		return fmt.Errorf("failed to get paths: %w", err)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if cfg == nil {
+		return config.ErrNoConfig
+	}
+
+	configData, err := os.ReadFile(p.ConfigFile())
+	if err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+
+	capsule := setupCapsule{
+		Version:     capsuleVersion,
+		Host:        sync.Hostname(),
+		Created:     time.Now(),
+		ToolVersion: version,
+		Config:      configData,
+		RemoteURL:   cfg.Repo.URL,
+	}
+
+	// The repo's remote wins if it was changed outside the config
+	repo := sync.NewRepo(p)
+	if err := repo.Open(); err == nil {
+		if url, err := repo.GetRemoteURL("origin"); err == nil {
+			capsule.RemoteURL = url
+		}
+	}
+
+	if cfg.Encryption.Enabled {
+		key, err := crypto.LoadKeyFromFile(p.KeyFile())
+		if err != nil {
+			return fmt.Errorf("failed to load encryption key: %w", err)
+		}
+		capsule.Key = key
+	}
+
+	data, err := json.Marshal(capsule)
+	if err != nil {
+		return fmt.Errorf("failed to encode setup: %w", err)
+	}
+
+	passphrase, err := capsulePassphrase(true)
+	if err != nil {
+		return err
+	}
+
+	ciphertext, err := crypto.EncryptWithPassphrase(data, passphrase)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(file, ciphertext, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", file, err)
+	}
+
+	ui.Success(fmt.Sprintf("Setup exported to %s", file))
+	ui.Info("On the new machine, run: opencode-sync setup import " + shellQuote(filepath.Base(file)))
+	return nil
+}
+
+func runSetupImport(ctx context.Context, file string) error {
+	ciphertext, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", file, err)
+	}
+
+	passphrase, err := capsulePassphrase(false)
+	if err != nil {
+		return err
+	}
+
+	data, err := crypto.DecryptWithPassphrase(ciphertext, passphrase)
+	if err != nil {
+		return fmt.Errorf("failed to open setup capsule: %w", err)
+	}
+
+	var capsule setupCapsule
+	if err := json.Unmarshal(data, &capsule); err != nil {
+		return fmt.Errorf("failed to parse setup capsule: %w", err)
+	}
+	if capsule.Version > capsuleVersion {
+		return fmt.Errorf("setup capsule was written by a newer opencode-sync (%s); upgrade first", capsule.ToolVersion)
+	}
+
+	var cfg config.Config
+	if err := json.Unmarshal(capsule.Config, &cfg); err != nil {
+		return fmt.Errorf("failed to parse config from setup capsule: %w", err)
+	}
+	if capsule.RemoteURL != "" {
+		cfg.Repo.URL = capsule.RemoteURL
+	}
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("setup capsule has an invalid config: %w", err)
+	}
+
+	p, err := paths.Get()
+	if err != nil {
+		return fmt.Errorf("failed to get paths: %w", err)
+	}
+
+	ui.Info(fmt.Sprintf("Setup exported from %s on %s", capsule.Host, capsule.Created.Format("2006-01-02 15:04")))
+
+	if existing, err := config.Load(); err == nil && existing != nil && !noPrompt {
+		confirmed, err := ui.Confirm("Replace the existing setup?", "Your config.json and encryption key will be overwritten")
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			ui.Info("Import cancelled")
+			return nil
+		}
+	}
+
+	if err := p.EnsureDirs(); err != nil {
+		return fmt.Errorf("failed to create directories: %w", err)
+	}
+
+	// Paths from the old machine do not apply here
+	if cfg.Encryption.KeyFile != "" {
+		cfg.Encryption.KeyFile = p.KeyFile()
+	}
+
+	if capsule.Key != "" {
+		if _, err := crypto.NewAgeEncryption(capsule.Key); err != nil {
+			return fmt.Errorf("setup capsule has an invalid key: %w", err)
+		}
+		if err := crypto.SaveKeyToFile(capsule.Key, p.KeyFile()); err != nil {
+			return fmt.Errorf("failed to save key: %w", err)
+		}
+	}
+
+	if err := config.Save(&cfg); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	ui.Success("Config and key restored")
+
+	if _, err := os.Stat(filepath.Join(p.SyncRepoRoot(), ".git")); err == nil {
+		ui.Info("Sync repository already exists. Run 'opencode-sync pull' to apply it.")
+		return nil
+	}
+
+	return runClone(ctx, cfg.Repo.URL)
+}
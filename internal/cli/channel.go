@@ -0,0 +1,252 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/GareArc/opencode-sync/internal/config"
+	"github.com/GareArc/opencode-sync/internal/git"
+	"github.com/GareArc/opencode-sync/internal/paths"
+	"github.com/GareArc/opencode-sync/internal/sync"
+	"github.com/GareArc/opencode-sync/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// channelCreate is set by 'channel switch --create'
+var channelCreate bool
+
+// channelCmd represents the channel command
+var channelCmd = &cobra.Command{
+	Use:   "channel",
+	Short: "List or switch config channels",
+	Long: `Channels are branches of the sync repo, such as a "stable" config you
+use everywhere and an "experimental" one you try out on a single machine.
+Push and pull always work on the active channel.
+
+Without a subcommand, lists the channels and marks the active one.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runChannelList(cmd.Context())
+	},
+}
+
+var channelListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the channels on this machine and on the remote",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runChannelList(cmd.Context())
+	},
+}
+
+var channelSwitchCmd = &cobra.Command{
+	Use:   "switch <branch>",
+	Short: "Check out a channel and apply it to your OpenCode config",
+	Long: `Check out another channel and apply it to your OpenCode config.
+
+Local changes must be pushed first, so nothing is lost. A channel only on
+the remote is fetched; one that already exists here is updated from the
+remote. Your local files are backed up first, like a pull. Files the new
+channel doesn't have are removed from your OpenCode config; switching back
+restores them.
+
+With --create, a channel that doesn't exist yet starts from the current
+one. Run 'opencode-sync push' to publish it.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runChannelSwitch(cmd.Context(), args[0], channelCreate)
+	},
+}
+
+func init() {
+	channelSwitchCmd.Flags().BoolVar(&channelCreate, "create", false, "create the channel from the current one if it doesn't exist")
+
+	channelCmd.AddCommand(channelListCmd)
+	channelCmd.AddCommand(channelSwitchCmd)
+}
+
+// openSyncRepo opens the sync repo without loading keys, for commands
+// that only look at git
+func openSyncRepo() (*git.BuiltinGit, error) {
+	p, err := paths.Get()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get paths: %w", err)
+	}
+
+	repo := sync.NewRepo(p)
+	if err := repo.Open(); err != nil {
+		return nil, fmt.Errorf("failed to open git repository: %w", err)
+	}
+	return repo, nil
+}
+
+func runChannelList(ctx context.Context) error {
+	repo, err := openSyncRepo()
+	if err != nil {
+		return err
+	}
+
+	active, err := repo.GetBranch()
+	if err != nil {
+		return err
+	}
+
+	local, err := repo.LocalBranches()
+	if err != nil {
+		return err
+	}
+
+	remote, err := repo.RemoteBranches(ctx)
+	if err != nil {
+		ui.Warn(fmt.Sprintf("Showing local channels only: %v", err))
+	}
+
+	channels := slices.Concat(local, remote)
+	slices.Sort(channels)
+	channels = slices.Compact(channels)
+
+	for _, channel := range channels {
+		// Holds the encrypted auth history, not a config
+		if channel == sync.AuthBranch {
+			continue
+		}
+
+		marker := " "
+		if channel == active {
+			marker = "*"
+		}
+
+		var where string
+		switch {
+		case !slices.Contains(local, channel):
+			where = " (remote only)"
+		case err == nil && !slices.Contains(remote, channel):
+			where = " (not pushed)"
+		}
+
+		fmt.Printf("%s %s%s\n", marker, channel, where)
+	}
+
+	return nil
+}
+
+func runChannelSwitch(ctx context.Context, branch string, create bool) error {
+	if branch == sync.AuthBranch {
+		return fmt.Errorf("%s holds the auth history and is not a channel", branch)
+	}
+	if strings.HasPrefix(branch, "-") {
+		return fmt.Errorf("invalid channel name: %s", branch)
+	}
+
+	syncer, err := initSyncer()
+	if err != nil {
+		return err
+	}
+
+	repo, err := openSyncRepo()
+	if err != nil {
+		return err
+	}
+
+	active, err := repo.GetBranch()
+	if err != nil {
+		return err
+	}
+	if branch == active {
+		ui.Info(fmt.Sprintf("Already on channel %s", branch))
+		return nil
+	}
+
+	if repo.MergeInProgress() {
+		return fmt.Errorf("a merge is in progress. Resolve it with 'opencode-sync sync' first")
+	}
+	if err := requireInSync(syncer); err != nil {
+		return err
+	}
+
+	if unpushed, err := repo.UnpushedCommits(); err == nil && unpushed > 0 {
+		ui.Warn(fmt.Sprintf("%d commit(s) on channel %s are not pushed yet; they stay on that channel", unpushed, active))
+	}
+
+	local, err := repo.LocalBranches()
+	if err != nil {
+		return err
+	}
+	isLocal := slices.Contains(local, branch)
+
+	remote, err := repo.RemoteBranches(ctx)
+	if err != nil {
+		if !isLocal {
+			return fmt.Errorf("failed to look up channel %s on the remote: %w", branch, err)
+		}
+		ui.Warn(fmt.Sprintf("Switching without updating from the remote: %v", err))
+	}
+	isRemote := slices.Contains(remote, branch)
+
+	headBefore, _ := repo.GetHead()
+
+	switch {
+	case isLocal:
+	case isRemote:
+		if err := ui.SpinnerWithResult(fmt.Sprintf("Fetching channel %s", branch), func() error {
+			return repo.FetchBranch(ctx, branch)
+		}); err != nil {
+			return fmt.Errorf("failed to fetch channel %s: %w", branch, err)
+		}
+	case create:
+		if err := repo.CreateBranch(branch); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("channel %s was not found here or on the remote. Use --create to start it from %s", branch, active)
+	}
+
+	// Keep the working tree limited to what this machine syncs
+	if err := syncer.SetSparseCheckout(); err != nil {
+		return err
+	}
+	if err := repo.CheckoutBranch(branch); err != nil {
+		return err
+	}
+
+	if isLocal && isRemote {
+		if err := ui.SpinnerWithResult("Fetching from remote", func() error {
+			return repo.Pull(ctx)
+		}); err != nil {
+			var conflictErr *git.ConflictError
+			if errors.As(err, &conflictErr) {
+				return fmt.Errorf("switched to channel %s, but %w. Please resolve manually", branch, conflictErr)
+			}
+			return fmt.Errorf("switched to channel %s, but failed to pull: %w", branch, err)
+		}
+		recordFetch()
+	}
+
+	// An interrupted apply is completed by pulling the new channel
+	if err := applyPulled(ctx, syncer, repo, headBefore, "pull"); err != nil {
+		return err
+	}
+
+	removed, err := syncer.RemoveDropped(headBefore)
+	if err != nil {
+		return err
+	}
+	if len(removed) > 0 {
+		ui.Info(fmt.Sprintf("Removed %d file(s) channel %s doesn't have", len(removed), branch))
+	}
+
+	cfg := syncer.Config()
+	cfg.Repo.Branch = branch
+	if err := config.Save(cfg); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	ui.Success(fmt.Sprintf("Switched to channel %s", branch))
+	if !isRemote && create {
+		ui.Info("Run 'opencode-sync push' to publish it")
+	}
+	return nil
+}
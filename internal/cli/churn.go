@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/GareArc/opencode-sync/internal/config"
+	"github.com/GareArc/opencode-sync/internal/paths"
+	"github.com/GareArc/opencode-sync/internal/sync"
+	"github.com/GareArc/opencode-sync/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	churnWindow    int
+	churnThreshold int
+	churnAuto      bool
+)
+
+// churnCmd represents the churn command
+var churnCmd = &cobra.Command{
+	Use:   "churn",
+	Short: "Find files that change in almost every sync and suggest excluding them",
+	Long: `Look through recent sync commits for files that changed in more than
+--threshold of the last --window syncs, such as caches, lockfiles and
+timestamps inside plugin and skill directories.
+
+Files that look volatile (in a cache or tmp directory, lockfiles, logs,
+databases, or changed only in their numbers) get a suggested sync.exclude
+pattern. Others are listed for you to review. With --auto, the suggested
+patterns are added to sync.exclude.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runChurn(churnWindow, churnThreshold, churnAuto)
+	},
+}
+
+func init() {
+	churnCmd.Flags().IntVar(&churnWindow, "window", 20, "number of recent syncs to examine")
+	churnCmd.Flags().IntVar(&churnThreshold, "threshold", 10, "report files changed in more than this many of them")
+	churnCmd.Flags().BoolVar(&churnAuto, "auto", false, "add the suggested patterns to sync.exclude")
+}
+
+func runChurn(window, threshold int, auto bool) error {
+	if window <= 0 || threshold < 0 || threshold >= window {
+		return fmt.Errorf("--window must be positive and --threshold between 0 and --window")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if cfg == nil {
+		return config.ErrNoConfig
+	}
+
+	p, err := paths.Get()
+	if err != nil {
+		return fmt.Errorf("failed to get paths: %w", err)
+	}
+
+	repo := sync.NewRepo(p)
+	if err := repo.Open(); err != nil {
+		return fmt.Errorf("failed to open repository: %w", err)
+	}
+
+	churn, syncs, err := sync.Churn(repo, window, threshold)
+	if err != nil {
+		return err
+	}
+
+	// Files excluded since show their old churn in history
+	var patterns []string
+	var review []sync.ChurnFile
+	fmt.Printf("Examined %d sync(s)\n", syncs)
+	for _, file := range churn {
+		if file.Pattern == "" {
+			review = append(review, file)
+			continue
+		}
+		if slices.Contains(cfg.Sync.Exclude, file.Pattern) {
+			continue
+		}
+
+		if len(patterns) == 0 {
+			fmt.Println("\nVolatile files:")
+			fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		}
+		fmt.Printf("%-50s %2d/%d  %s\n", file.Path, file.Changes, syncs, file.Reason)
+		if !slices.Contains(patterns, file.Pattern) {
+			patterns = append(patterns, file.Pattern)
+		}
+	}
+
+	if len(review) > 0 {
+		fmt.Println("\nFrequently changed (review these yourself):")
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		for _, file := range review {
+			fmt.Printf("%-50s %2d/%d  %s\n", file.Path, file.Changes, syncs, file.Reason)
+		}
+	}
+
+	if len(patterns) == 0 {
+		if len(review) == 0 {
+			ui.Success("No churning files found")
+		}
+		return nil
+	}
+
+	fmt.Println("\nSuggested sync.exclude patterns:")
+	for _, pattern := range patterns {
+		fmt.Printf("  %s\n", pattern)
+	}
+
+	if !auto {
+		fmt.Println()
+		ui.Info("Run 'opencode-sync churn --auto' to add them.")
+		return nil
+	}
+
+	cfg.Sync.Exclude = append(cfg.Sync.Exclude, patterns...)
+	if err := config.Save(cfg); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	fmt.Println()
+	ui.Success(fmt.Sprintf("Added %d pattern(s) to sync.exclude", len(patterns)))
+	ui.Info("sync.exclude is per machine; add the same patterns on your other machines. Files already in the repo stay there until you delete them.")
+	return nil
+}
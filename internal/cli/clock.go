@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/GareArc/opencode-sync/internal/clockskew"
+	"github.com/GareArc/opencode-sync/internal/git"
+)
+
+// maxClockSkew is how far a clock may be off before doctor reports it
+const maxClockSkew = 5 * time.Minute
+
+// clockTimeout limits how long doctor waits for the reference time
+const clockTimeout = 5 * time.Second
+
+// suggestedTimeSync is how to turn on automatic time sync on this platform
+func suggestedTimeSync() string {
+	switch runtime.GOOS {
+	case "darwin":
+		return "turn on 'Set time and date automatically' in System Settings > General > Date & Time"
+	case "windows":
+		return "turn on 'Set time automatically' in Settings > Time & language, or run 'w32tm /resync'"
+	default:
+		return "run 'sudo timedatectl set-ntp true'"
+	}
+}
+
+// checkClock compares the system clock with the remote's HTTP Date
+// header, or an NTP server for other remotes. A wrong clock misorders
+// history and can make TLS certificates look invalid. It returns an issue
+// and suggestion for doctor; either is empty when there is nothing to
+// report.
+func checkClock(ctx context.Context, remoteURL string) (string, string) {
+	ctx, cancel := context.WithTimeout(ctx, clockTimeout)
+	defer cancel()
+
+	skew, source, err := clockskew.Measure(ctx, remoteURL)
+	if err != nil {
+		fmt.Printf("⚠ could not check (%v)\n", err)
+		return "", ""
+	}
+
+	if skew.Abs() <= maxClockSkew {
+		fmt.Printf("✓ (within %s of %s)\n", skew.Abs().Round(time.Second), source)
+		return "", ""
+	}
+
+	direction := "ahead"
+	if skew < 0 {
+		direction = "behind"
+	}
+	fmt.Printf("✗ %s %s of %s\n", skew.Abs().Round(time.Second), direction, source)
+	return fmt.Sprintf("System clock is %s %s", skew.Abs().Round(time.Second), direction),
+		fmt.Sprintf("Fix the system clock: %s", suggestedTimeSync())
+}
+
+// checkCommitTimes reports a latest commit dated in the future, which
+// means the machine that made it has its clock ahead. It returns an issue
+// and suggestion for doctor; either is empty when there is nothing to
+// report.
+func checkCommitTimes(repo *git.BuiltinGit) (string, string) {
+	commit, err := repo.GetLastCommit()
+	if err != nil {
+		fmt.Println("⚠ no commits yet")
+		return "", ""
+	}
+
+	ahead := commit.Timestamp.Sub(time.Now())
+	if ahead <= maxClockSkew {
+		fmt.Println("✓")
+		return "", ""
+	}
+
+	fmt.Printf("⚠ %s is dated %s in the future\n", commit.Hash, ahead.Round(time.Minute))
+	return fmt.Sprintf("The latest commit (%s) is dated %s in the future", commit.Hash, ahead.Round(time.Minute)),
+		"Run 'opencode-sync doctor' on the machine that made it to check its clock"
+}
@@ -1,40 +1,40 @@
 package cli
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/GareArc/opencode-sync/internal/config"
 	"github.com/GareArc/opencode-sync/internal/crypto"
+	"github.com/GareArc/opencode-sync/internal/forge"
 	"github.com/GareArc/opencode-sync/internal/git"
+	"github.com/GareArc/opencode-sync/internal/journal"
 	"github.com/GareArc/opencode-sync/internal/paths"
+	"github.com/GareArc/opencode-sync/internal/procs"
+	"github.com/GareArc/opencode-sync/internal/snapshot"
+	"github.com/GareArc/opencode-sync/internal/state"
 	"github.com/GareArc/opencode-sync/internal/sync"
 	"github.com/GareArc/opencode-sync/internal/ui"
 	"github.com/spf13/cobra"
 )
 
-// versionCmd represents the version command
-var versionCmd = &cobra.Command{
-	Use:   "version",
-	Short: "Print version information",
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Printf("opencode-sync %s\n", version)
-		fmt.Printf("  commit: %s\n", commit)
-		fmt.Printf("  built:  %s\n", date)
-	},
-}
-
 // syncCmd represents the sync command (pull + push)
 var syncCmd = &cobra.Command{
 	Use:   "sync",
 	Short: "Sync configurations (pull then push)",
 	Long:  `Pull remote changes and push local changes in one command.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runSync()
+		return runSync(cmd.Context())
 	},
 }
 
@@ -42,8 +42,26 @@ var syncCmd = &cobra.Command{
 var pushCmd = &cobra.Command{
 	Use:   "push",
 	Short: "Push local changes to remote",
+	Long: `Copy local OpenCode configs into the sync repo, commit and push them.
+
+With --review, the commit is created locally and shown before anything is
+sent to the remote. Declining undoes the commit and keeps the changes staged.
+
+With --propose, the commit goes to a branch of its own for this machine
+(opencode-sync/<hostname>) and a pull request against the active channel
+is opened on GitHub or Gitea, or a merge request on GitLab, so changes to
+a shared repo are reviewed first. The API token comes from GITHUB_TOKEN,
+GH_TOKEN, GITLAB_TOKEN or GITEA_TOKEN, or else from git's credential
+helper (e.g. after 'gh auth login'). Proposing again updates the open pull
+request.
+
+With --only, only repo paths matching the glob are copied (repeatable, e.g.
+--only 'agent/**' --only AGENTS.md). A directory selects everything in it.
+
+The files are linted first, as by 'opencode-sync lint', and nothing is
+pushed while there are problems. --no-lint pushes anyway.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runPush()
+		return runPush(cmd.Context())
 	},
 }
 
@@ -51,8 +69,19 @@ var pushCmd = &cobra.Command{
 var pullCmd = &cobra.Command{
 	Use:   "pull",
 	Short: "Pull remote changes",
+	Long: `Pull remote changes into the sync repo and apply them to the local config.
+
+With --only, only repo paths matching the glob are applied (repeatable, e.g.
+--only 'agent/**'). The rest of the local config is left untouched until the
+next full pull; a full push before then sends the local versions back.
+
+With --review, the pulled changes are staged in the sync repo and compared
+with the live config first. Risky changes, such as providers added,
+removed or changed, agents removed from opencode.json, or auth.json
+replaced, are listed and only applied once you confirm. Declined changes
+stay staged: a later pull applies them, a push sends your versions back.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runPull()
+		return runPull(cmd.Context())
 	},
 }
 
@@ -60,6 +89,31 @@ var pullCmd = &cobra.Command{
 var statusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Show sync status",
+	Long: `Show sync status.
+
+With --verify, every applied local file is re-read and compared with the
+last commit of the sync repo, listing files that were edited by hand,
+corrupted or left behind by an interrupted pull.
+
+File hashes are cached in the data directory and reused while a file's
+size and modification time are unchanged. --no-cache hashes every file
+again.
+
+With --porcelain, the status is printed for scripts in a format that does
+not change between versions: one tab-separated record per line, its type
+first.
+
+  version   1, the format version; always the first line
+  channel   the active channel
+  layout    copy or direct
+  clean     true if the sync repo has no uncommitted changes
+  paused    when automatic syncing resumes (RFC 3339), or - for 'resume'
+  pending   a repo path with local changes to push
+  renamed   a renamed repo path, then its old path
+  conflict  a path with a merge conflict
+
+Fields holding a tab, newline, double quote or backslash are written as
+quoted C-style strings. Scripts should ignore record types they do not know.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		return runStatus()
 	},
@@ -69,6 +123,15 @@ var statusCmd = &cobra.Command{
 var diffCmd = &cobra.Command{
 	Use:   "diff",
 	Short: "Show differences between local and remote",
+	Long: `Show differences between the sync repo working tree and its last commit.
+
+With --secrets, encrypted files are decrypted in memory and compared key by
+key. Only the names of added, removed or rotated entries are shown, never
+their values.
+
+With --porcelain, each change is printed for scripts as one tab-separated
+line that does not change between versions: the status (added, modified,
+deleted or renamed), the path and, for renames, the old path.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		return runDiff()
 	},
@@ -78,6 +141,10 @@ var diffCmd = &cobra.Command{
 var setupCmd = &cobra.Command{
 	Use:   "setup",
 	Short: "Run the setup wizard",
+	Long: `Run the setup wizard.
+
+Use 'setup export' and 'setup import' to move a complete setup to a new
+machine.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		return runSetupWizard()
 	},
@@ -88,7 +155,7 @@ var doctorCmd = &cobra.Command{
 	Use:   "doctor",
 	Short: "Diagnose configuration issues",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runDoctor()
+		return runDoctor(cmd.Context())
 	},
 }
 
@@ -102,9 +169,27 @@ This command will:
 1. Create a new Git repository
 2. Copy current OpenCode configs to the repository
 3. Create an initial commit
-4. Set up the remote (if configured)`,
+4. Set up the remote (if configured)
+
+With --from-template, the repository is seeded from a public template
+repository (agents, commands, themes, ...) before your own configs are
+copied on top, and the result is applied to OpenCode. The template's
+history, encrypted files and credentials are not copied; origin stays your
+own repository.
+
+With --create-remote github, gitlab or gitea, a private repository is
+created for you (named by --remote-name, opencode-config by default), set
+as repo.url and pushed to, so there is no need to create one on the website
+first. The API token comes from GITHUB_TOKEN, GH_TOKEN, GITLAB_TOKEN or
+GITEA_TOKEN, or from git's credential helper (e.g. after 'gh auth login').
+Gitea needs --remote-host, such as codeberg.org. The HTTPS URL is used
+unless --ssh is given.
+
+Example:
+  opencode-sync init --from-template https://github.com/someone/opencode-config
+  opencode-sync init --create-remote github`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runInit()
+		return runInit(cmd.Context(), initTemplate)
 	},
 }
 
@@ -116,14 +201,42 @@ var cloneCmd = &cobra.Command{
 
 This command will:
 1. Clone the repository from the remote URL
-2. Apply the configurations to your local OpenCode`,
+2. Apply the configurations to your local OpenCode
+
+If local OpenCode files differ from the repository, you are asked for
+each whether to keep the local file, take the repository's or merge
+both (JSON config files and AGENTS.md). Kept and merged files are
+committed, ready to push. --adopt local, remote or merge decides for all
+files; merge keeps the local copy of files it cannot merge. With
+--no-prompt, the repository's files are taken, as with --adopt remote.
+
+With --layout direct, the OpenCode config directory becomes the
+repository's working tree (see repo.layout). With --subdir, only that
+directory of the repository is synced, so an existing dotfiles repo can
+hold the config next to its other files (see repo.subdir).`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		var repoURL string
 		if len(args) > 0 {
 			repoURL = args[0]
 		}
-		return runClone(repoURL)
+		switch cloneLayout {
+		case "", config.LayoutCopy, config.LayoutDirect:
+		default:
+			return fmt.Errorf("--layout must be one of: copy, direct")
+		}
+		if cloneSubdir != "" && !config.ValidSubdir(cloneSubdir) {
+			return fmt.Errorf("--subdir must be a relative directory inside the repository, such as opencode")
+		}
+		if cloneSubdir != "" && cloneLayout == config.LayoutDirect {
+			return fmt.Errorf("--subdir cannot be used with --layout direct")
+		}
+		switch cloneAdopt {
+		case adoptAsk, sync.AdoptLocal, sync.AdoptRemote, sync.AdoptMerge:
+		default:
+			return fmt.Errorf("--adopt must be one of: ask, local, remote, merge")
+		}
+		return runClone(cmd.Context(), repoURL)
 	},
 }
 
@@ -145,7 +258,7 @@ Example:
   opencode-sync link git@github.com:username/opencode-config.git`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runLink(args[0])
+		return runLink(cmd.Context(), args[0])
 	},
 }
 
@@ -189,18 +302,33 @@ var configEditCmd = &cobra.Command{
 
 // configSetCmd sets a configuration value
 var configSetCmd = &cobra.Command{
-	Use:   "set <key> <value>",
+	Use:   "set <key> [value]",
 	Short: "Set a configuration value",
 	Long: `Set a configuration value using dot notation.
 
+Booleans take true or false (also yes/no, on/off, 1/0); anything else is
+an error. Lists such as sync.exclude take a JSON array or a comma-separated
+value, which replaces the whole list, or are edited one element at a time
+with --add and --remove instead of a value. An empty value clears a list.
+
 Examples:
   opencode-sync config set repo.url git@github.com:user/repo.git
   opencode-sync config set repo.branch main
   opencode-sync config set encryption.enabled true
-  opencode-sync config set sync.includeAuth false`,
-	Args: cobra.ExactArgs(2),
+  opencode-sync config set sync.includeAuth false
+  opencode-sync config set sync.whenRunning wait
+  opencode-sync config set sync.exclude '["node_modules","*.log"]'
+  opencode-sync config set sync.exclude --add '*.tmp' --remove '*.log'`,
+	Args: cobra.RangeArgs(1, 2),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runConfigSet(args[0], args[1])
+		editing := len(configSetAdd) > 0 || len(configSetRemove) > 0
+		if editing && len(args) == 2 {
+			return fmt.Errorf("give either a value or --add/--remove, not both")
+		}
+		if !editing && len(args) == 1 {
+			return fmt.Errorf("missing value for %s", args[0])
+		}
+		return runConfigSet(args[0], optionalArg(args, 1, ""), configSetAdd, configSetRemove)
 	},
 }
 
@@ -269,21 +397,6 @@ Examples:
 	},
 }
 
-var uninstallCmd = &cobra.Command{
-	Use:   "uninstall",
-	Short: "Uninstall opencode-sync",
-	Long: `Remove opencode-sync and optionally its data.
-
-This will:
-- Remove the opencode-sync binary (may require sudo)
-- Optionally remove config and sync data
-
-Your OpenCode configurations are NOT affected.`,
-	RunE: func(cmd *cobra.Command, args []string) error {
-		return runUninstall()
-	},
-}
-
 var gcCmd = &cobra.Command{
 	Use:   "gc",
 	Short: "Run git garbage collection to optimize repository size",
@@ -299,6 +412,30 @@ Useful after many sync operations to keep storage optimized.`,
 }
 
 func init() {
+	pushCmd.Flags().StringArrayVar(&pushOnly, "only", nil, "only push repo paths matching this glob (repeatable)")
+	pullCmd.Flags().StringArrayVar(&pullOnly, "only", nil, "only apply repo paths matching this glob (repeatable)")
+	pushCmd.Flags().BoolVar(&pushReview, "review", false, "show the commit and ask for confirmation before pushing")
+	pullCmd.Flags().BoolVar(&pullReview, "review", false, "ask for confirmation before applying risky changes")
+	pushCmd.Flags().BoolVar(&pushPropose, "propose", false, "push to a branch for this machine and open a pull request instead of pushing to the channel")
+	pushCmd.Flags().BoolVar(&pushNoLint, "no-lint", false, "push even if linting finds problems")
+	pushCmd.Flags().BoolVar(&pushNoCompress, "no-compress", false, "upload the snapshot to a WebDAV or SFTP remote uncompressed")
+	initCmd.Flags().StringVar(&initTemplate, "from-template", "", "seed the repository from a template repository URL")
+	initCmd.Flags().StringVar(&initCreateRemote, "create-remote", "", "create a private repository on github, gitlab or gitea and push to it")
+	initCmd.Flags().StringVar(&initRemoteHost, "remote-host", "", "host of the service for --create-remote (default github.com or gitlab.com)")
+	initCmd.Flags().StringVar(&initRemoteName, "remote-name", "opencode-config", "name of the repository --create-remote creates")
+	initCmd.Flags().BoolVar(&initRemoteSSH, "ssh", false, "use the SSH URL of the repository --create-remote creates")
+	cloneCmd.Flags().StringVar(&cloneLayout, "layout", "", "repo.layout to clone with: copy or direct")
+	cloneCmd.Flags().StringVar(&cloneSubdir, "subdir", "", "repo.subdir to keep the synced files in, for a repository that holds other files too")
+	cloneCmd.Flags().StringVar(&cloneAdopt, "adopt", adoptAsk, "how to settle local files that differ from the repo: ask, local, remote or merge")
+	statusCmd.Flags().BoolVar(&statusVerify, "verify", false, "compare applied local files with the sync repo")
+	diffCmd.Flags().BoolVar(&diffSecrets, "secrets", false, "show which keys changed inside encrypted files (values redacted)")
+	statusCmd.Flags().BoolVar(&statusPorcelain, "porcelain", false, "print stable tab-separated output for scripts")
+	statusCmd.Flags().BoolVar(&statusNoCache, "no-cache", false, "hash every file instead of trusting cached hashes of unchanged files")
+	diffCmd.Flags().BoolVar(&diffPorcelain, "porcelain", false, "print stable tab-separated output for scripts")
+
+	configSetCmd.Flags().StringArrayVar(&configSetAdd, "add", nil, "add an element to a list key (repeatable)")
+	configSetCmd.Flags().StringArrayVar(&configSetRemove, "remove", nil, "remove an element from a list key (repeatable)")
+
 	// Add config subcommands
 	configCmd.AddCommand(configShowCmd)
 	configCmd.AddCommand(configPathCmd)
@@ -313,6 +450,117 @@ func init() {
 
 // Command implementations
 
+// pushReview is set by 'push --review'
+var pushReview bool
+
+// pullReview is set by 'pull --review', and pullHold by watch to hold
+// back risky changes without asking
+var pullReview, pullHold bool
+
+// pushPropose is set by 'push --propose'
+var pushPropose bool
+
+// pushNoLint is set by 'push --no-lint'
+var pushNoLint bool
+
+// pushNoCompress is set by 'push --no-compress'
+var pushNoCompress bool
+
+// pushOnly and pullOnly are set by 'push --only' and 'pull --only'
+var pushOnly, pullOnly []string
+
+// syncDue limits push and pull to the sync targets watch found due (see
+// sync.Syncer.SetDue)
+var syncDue map[string]bool
+
+// statusVerify is set by 'status --verify'
+var statusVerify bool
+
+// diffSecrets is set by 'diff --secrets'
+var diffSecrets bool
+
+// statusNoCache is set by 'status --no-cache'
+var statusNoCache bool
+
+// statusPorcelain is set by 'status --porcelain'
+var statusPorcelain bool
+
+// diffPorcelain is set by 'diff --porcelain'
+var diffPorcelain bool
+
+// initTemplate is set by 'init --from-template'
+var initTemplate string
+
+// initCreateRemote, initRemoteHost, initRemoteName and initRemoteSSH are
+// set by 'init --create-remote' and its options
+var (
+	initCreateRemote string
+	initRemoteHost   string
+	initRemoteName   string
+	initRemoteSSH    bool
+)
+
+// cloneLayout is set by 'clone --layout'
+var cloneLayout string
+
+// cloneSubdir is set by 'clone --subdir'
+var cloneSubdir string
+
+// reportCopyStats shows how many files were reflinked, and how long each
+// source took, in verbose mode
+func reportCopyStats(syncer *sync.Syncer) {
+	if !verbose {
+		return
+	}
+
+	stats := syncer.CopyStats()
+	ui.Info(fmt.Sprintf("Copied %d file(s): %d reflinked, %d copied", stats.Reflinked+stats.Copied, stats.Reflinked, stats.Copied))
+	for _, source := range syncer.SourceResults() {
+		fmt.Printf("  %-24s %10s\n", source.Name, formatDuration(source.Duration))
+	}
+}
+
+// reportMigrations lists the layout migrations the push applied
+func reportMigrations(syncer *sync.Syncer) {
+	for _, migration := range syncer.Migrated() {
+		ui.Info(fmt.Sprintf("Migrated the sync repo to layout %d: %s", migration.Layout, migration.Description))
+	}
+}
+
+// reportMovedFiles lists the local files the pull moved to follow a rename
+func reportMovedFiles(syncer *sync.Syncer) {
+	for _, change := range syncer.MovedFiles() {
+		ui.Info(fmt.Sprintf("Renamed %s to %s", change.OldPath, change.Path))
+	}
+}
+
+// reportLockedIncoming lists the incoming versions of locked files the
+// pull saved next to them
+func reportLockedIncoming(syncer *sync.Syncer) {
+	for _, path := range syncer.LockedIncoming() {
+		ui.Warn(fmt.Sprintf("Kept your locked %s; the incoming version is in %s", strings.TrimSuffix(path, sync.IncomingSuffix), path))
+	}
+}
+
+// reportSkippedBinaries warns about binary files left out of the push
+func reportSkippedBinaries(syncer *sync.Syncer) {
+	skipped := syncer.SkippedBinaries()
+	if len(skipped) == 0 {
+		return
+	}
+
+	shown := skipped
+	if len(shown) > 5 {
+		shown = shown[:5]
+	}
+	msg := fmt.Sprintf("Skipped %d binary file(s): %s", len(skipped), strings.Join(shown, ", "))
+	if len(skipped) > len(shown) {
+		msg += fmt.Sprintf(" and %d more", len(skipped)-len(shown))
+	}
+	ui.Warn(msg)
+	ui.Info("Set sync.includeBinaries or add patterns to sync.binaryAllow to sync them.")
+}
+
 // initSyncer initializes syncer instance
 func initSyncer() (*sync.Syncer, error) {
 	// Load config
@@ -321,7 +569,7 @@ func initSyncer() (*sync.Syncer, error) {
 		return nil, fmt.Errorf("failed to load config: %w", err)
 	}
 	if cfg == nil {
-		return nil, fmt.Errorf("no configuration found. Run 'opencode-sync setup' first")
+		return nil, config.ErrNoConfig
 	}
 
 	// Get paths
@@ -331,51 +579,63 @@ func initSyncer() (*sync.Syncer, error) {
 	}
 
 	// Initialize git repo
-	repo := git.NewBuiltinGit(p.SyncRepoDir())
+	repo := sync.NewRepo(p)
 	if err := repo.Open(); err != nil {
 		return nil, fmt.Errorf("failed to open git repository: %w", err)
 	}
 
 	// Create syncer
-	syncer := sync.New(cfg, p, repo)
-
-	// Initialize encryption if enabled
-	if cfg.Encryption.Enabled {
-		keyFile := p.KeyFile()
-
-		// Check if key file exists
-		if _, err := os.Stat(keyFile); os.IsNotExist(err) {
-			return nil, fmt.Errorf("encryption key not found at %s. Run 'opencode-sync setup' first", keyFile)
-		}
+	syncer, err := sync.Open(cfg, p, repo)
+	if err != nil {
+		return nil, err
+	}
+	installMergeDriver(syncer)
 
-		// Load private key
-		privateKey, err := crypto.LoadKeyFromFile(keyFile)
-		if err != nil {
-			return nil, fmt.Errorf("failed to load encryption key: %w", err)
-		}
+	return syncer, nil
+}
 
-		// Initialize encryption
-		enc, err := crypto.NewAgeEncryption(privateKey)
-		if err != nil {
-			return nil, fmt.Errorf("failed to initialize encryption: %w", err)
-		}
+// setupEncryption loads the encryption key into syncer if encryption is enabled
+func setupEncryption(syncer *sync.Syncer, cfg *config.Config, p *paths.Paths) error {
+	if !cfg.Encryption.Enabled {
+		return nil
+	}
 
-		syncer.SetEncryption(enc)
+	// The session parses the key once per process
+	enc, err := crypto.LoadEncryption(p.KeyFile())
+	if errors.Is(err, crypto.ErrKeyMissing) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load encryption key: %w", err)
 	}
 
-	return syncer, nil
+	syncer.SetEncryption(enc)
+	return nil
 }
 
-func runSync() error {
+func runSync(ctx context.Context) error {
 	ui.Info("Syncing...")
 
+	// Local edits are already in the working tree, so they are committed
+	// first; the push rebases them onto remote changes
+	if paths.DirectLayout {
+		if err := runPush(ctx); err != nil {
+			return fmt.Errorf("push failed: %w", err)
+		}
+		if err := runPull(ctx); err != nil {
+			return fmt.Errorf("pull failed: %w", err)
+		}
+		ui.Success("Sync complete!")
+		return nil
+	}
+
 	// Pull first
-	if err := runPull(); err != nil {
+	if err := runPull(ctx); err != nil {
 		return fmt.Errorf("pull failed: %w", err)
 	}
 
 	// Then push
-	if err := runPush(); err != nil {
+	if err := runPush(ctx); err != nil {
 		return fmt.Errorf("push failed: %w", err)
 	}
 
@@ -383,22 +643,59 @@ func runSync() error {
 	return nil
 }
 
-func runPush() error {
+func runPush(ctx context.Context) (err error) {
+	start := time.Now()
+	defer writeStatusFile(state.OpPush, start, syncHead(), &err)
+
 	syncer, err := initSyncer()
 	if err != nil {
 		return err
 	}
+	autoSnapshot(syncer)
+	if pushNoCompress {
+		snapshot.CompressionLevel = 0
+	}
+	if err := syncer.SetOnly(pushOnly); err != nil {
+		return err
+	}
+	if err := syncer.SetDue(syncDue); err != nil {
+		return err
+	}
+
+	// Keep broken agents and commands from reaching every machine
+	if !pushNoLint {
+		if err := lintBeforePush(syncer); err != nil {
+			return err
+		}
+	}
+
+	// Find out where to propose before committing anything
+	var target *proposal
+	if pushPropose {
+		if target, err = proposalTarget(syncer); err != nil {
+			return err
+		}
+	}
+
+	// Apply a changed sync.onlyDirs first, so files it no longer leaves
+	// out of the working tree are pushed
+	if err := syncer.SetSparseCheckout(); err != nil {
+		return err
+	}
 
 	// Copy OpenCode config to repo
 	if err := ui.SpinnerWithResult("Copying config files to sync repo", func() error {
-		return syncer.CopyToRepo()
+		return syncer.CopyToRepo(ctx)
 	}); err != nil {
 		return fmt.Errorf("failed to copy files: %w", err)
 	}
+	reportCopyStats(syncer)
+	reportMigrations(syncer)
+	reportSkippedBinaries(syncer)
 
 	// Get repo instance
 	p, _ := paths.Get()
-	repo := git.NewBuiltinGit(p.SyncRepoDir())
+	repo := sync.NewRepo(p)
 	if err := repo.Open(); err != nil {
 		return err
 	}
@@ -409,41 +706,169 @@ func runPush() error {
 		return fmt.Errorf("failed to check for changes: %w", err)
 	}
 
+	// Remember where we started so the push can be undone
+	headBefore, _ := repo.GetHead()
+
+	// replaced is the commit a squashed sync was folded into
+	var replaced string
+
 	if !hasChanges {
-		ui.Info("No changes to push")
-		return nil
+		// Resume a push that was interrupted after committing
+		unpushed, authPending := 0, false
+		if _, err := repo.GetRemoteURL("origin"); err == nil {
+			unpushed, _ = repo.UnpushedCommits()
+			authPending = sync.AuthBranchPending(repo)
+		}
+		switch {
+		case unpushed > 0 && pushPropose:
+			ui.Info(fmt.Sprintf("Proposing %d unpushed commit(s)", unpushed))
+		case unpushed > 0:
+			ui.Info(fmt.Sprintf("Pushing %d unpushed commit(s)", unpushed))
+		case pushPropose:
+			ui.Info("No changes to propose")
+			return nil
+		case authPending:
+			ui.Info("Pushing updated auth files")
+		default:
+			ui.Info("No changes to push")
+			reportTimings(state.OpPush, syncer, start)
+			return nil
+		}
+	} else {
+		endGit := syncer.Timings().Start(sync.PhaseGit)
+
+		// Stage all changes
+		if err := repo.AddAll(); err != nil {
+			return fmt.Errorf("failed to stage changes: %w", err)
+		}
+
+		// Commit, or fold into today's sync commit in squash mode. A
+		// reviewed or proposed commit is always new so declining it drops
+		// only it, and a proposal never rewrites the channel.
+		now := time.Now()
+		squash := syncer.Config().Sync.HistoryMode == config.HistoryModeSquash && !pushReview && !pushPropose
+		replaced, err = sync.CommitSync(repo, fmt.Sprintf("Sync from %s at %s", sync.Hostname(), now.Format("2006-01-02 15:04:05")), squash, now)
+		if err != nil {
+			return fmt.Errorf("failed to commit: %w", err)
+		}
+
+		endGit()
 	}
 
-	// Stage all changes
-	if err := repo.AddAll(); err != nil {
-		return fmt.Errorf("failed to stage changes: %w", err)
+	if pushReview && hasChanges {
+		confirmed, err := reviewCommit(repo)
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			ui.Info("Push cancelled. Changes remain staged in the sync repo.")
+			return nil
+		}
 	}
 
-	// Commit
-	commitMsg := fmt.Sprintf("Sync from %s at %s", getHostname(), time.Now().Format("2006-01-02 15:04:05"))
-	if err := repo.Commit(commitMsg); err != nil {
-		return fmt.Errorf("failed to commit: %w", err)
+	if pushPropose {
+		unpushed, _ := repo.UnpushedCommits()
+		if err := proposeCommits(ctx, syncer, repo, target, unpushed); err != nil {
+			return err
+		}
+		reportTimings(state.OpPush, syncer, start)
+		return nil
 	}
 
 	// Push
+	squashedHead, _ := repo.GetHead()
 	if err := ui.SpinnerWithResult("Pushing to remote", func() error {
-		return repo.Push()
+		defer syncer.Timings().Start(sync.PhaseNetwork)()
+
+		if replaced != "" {
+			return sync.PushSquashed(ctx, repo, replaced)
+		}
+		return sync.PushWithRetry(ctx, repo)
 	}); err != nil {
+		// The remote may or may not have received the commit, so keep it
+		// and let the next push finish the job
+		var timeout *git.TimeoutError
+		if ctx.Err() != nil || errors.As(err, &timeout) {
+			ui.Warn("Push interrupted. The commit is kept and will be sent by the next push.")
+		}
 		return fmt.Errorf("failed to push: %w", err)
 	}
 
+	// A retried push rebased our commit onto the remote's. A squashed
+	// commit that went out as is replaced the previous one instead.
+	headAfter, _ := repo.GetHead()
+	squashed := replaced != "" && headAfter == squashedHead
+	switch {
+	case squashed:
+		headBefore = replaced
+	case headBefore != "":
+		headBefore, _ = repo.RevParse("HEAD~1")
+	}
+	if err := state.RecordOperation(&state.Operation{
+		Type:       state.OpPush,
+		Time:       time.Now(),
+		HeadBefore: headBefore,
+		HeadAfter:  headAfter,
+		Squashed:   squashed,
+	}); err != nil {
+		ui.Warn(fmt.Sprintf("Failed to record push for undo: %v", err))
+	}
+	logOperation(state.OpPush, headBefore, headAfter, squashed)
+	reportUploadSize()
+
+	reportTimings(state.OpPush, syncer, start)
 	return nil
 }
 
-func runPull() error {
+// reviewCommit shows the HEAD commit and asks whether to push it.
+// If declined, the commit is undone with a soft reset.
+func reviewCommit(repo *git.BuiltinGit) (bool, error) {
+	if noPrompt {
+		return false, fmt.Errorf("--review requires interactive prompts")
+	}
+
+	out, err := repo.ShowCommit("HEAD")
+	if err != nil {
+		return false, err
+	}
+
+	fmt.Println("\nCommit to be pushed:")
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Println(out)
+
+	confirmed, err := ui.Confirm("Push this commit to the remote?", "Declining undoes the commit (soft reset)")
+	if err != nil {
+		return false, fmt.Errorf("failed to get confirmation: %w", err)
+	}
+
+	if !confirmed {
+		if err := repo.ResetSoft("HEAD~1"); err != nil {
+			return false, err
+		}
+	}
+
+	return confirmed, nil
+}
+
+func runPull(ctx context.Context) (err error) {
+	start := time.Now()
+	defer writeStatusFile(state.OpPull, start, syncHead(), &err)
+
 	syncer, err := initSyncer()
 	if err != nil {
 		return err
 	}
+	autoSnapshot(syncer)
+	if err := syncer.SetOnly(pullOnly); err != nil {
+		return err
+	}
+	if err := syncer.SetDue(syncDue); err != nil {
+		return err
+	}
 
 	// Get repo instance
 	p, _ := paths.Get()
-	repo := git.NewBuiltinGit(p.SyncRepoDir())
+	repo := sync.NewRepo(p)
 	if err := repo.Open(); err != nil {
 		return err
 	}
@@ -455,64 +880,259 @@ func runPull() error {
 	}
 
 	if hasChanges {
+		if paths.DirectLayout {
+			return fmt.Errorf("local changes detected. Push them first with 'opencode-sync push' or 'opencode-sync sync'")
+		}
 		return fmt.Errorf("local changes detected. Commit or discard them before pulling")
 	}
 
+	headBefore, _ := repo.GetHead()
+
+	// Keep the working tree limited to what this machine syncs
+	if err := syncer.SetSparseCheckout(); err != nil {
+		return err
+	}
+
 	// Pull from remote
 	if err := ui.SpinnerWithResult("Fetching from remote", func() error {
-		return repo.Pull()
+		defer syncer.Timings().Start(sync.PhaseNetwork)()
+		return repo.Pull(ctx)
 	}); err != nil {
-		if conflictErr, ok := err.(*git.ConflictError); ok {
-			return fmt.Errorf("merge conflict detected in %d file(s). Please resolve manually", len(conflictErr.Files))
+		var conflictErr *git.ConflictError
+		if errors.As(err, &conflictErr) {
+			return fmt.Errorf("%w. Please resolve manually", conflictErr)
 		}
 		return fmt.Errorf("failed to pull: %w", err)
 	}
+	recordFetch()
 
-	// Copy from repo to OpenCode config
-	if err := ui.SpinnerWithResult("Applying changes to OpenCode config", func() error {
-		return syncer.CopyFromRepo()
-	}); err != nil {
-		return fmt.Errorf("failed to copy files: %w", err)
+	if pullReview || pullHold {
+		apply, err := reviewPull(syncer)
+		if err != nil || !apply {
+			return err
+		}
+	}
+
+	if err := applyPulled(ctx, syncer, repo, headBefore, "pull"); err != nil {
+		return err
 	}
 
 	// Run garbage collection to optimize repo size
 	if err := ui.SpinnerWithResult("Optimizing repository", func() error {
+		defer syncer.Timings().Start(sync.PhaseGC)()
 		return repo.GC()
 	}); err != nil {
 		ui.Warn(fmt.Sprintf("Failed to run gc: %v", err))
 	}
 
+	reportTimings(state.OpPull, syncer, start)
+	return nil
+}
+
+// reviewPull lists the risky changes applying the sync repo would make and
+// reports whether to go ahead. Without prompts, or for watch, they are
+// held back with a RiskyChangesError.
+func reviewPull(syncer *sync.Syncer) (bool, error) {
+	if syncer.Direct() {
+		if pullHold {
+			return true, nil
+		}
+		// The working tree is the live config, so nothing can be staged
+		return false, fmt.Errorf("--review is not supported with repo.layout direct")
+	}
+
+	changes, err := syncer.RiskyChanges()
+	if err != nil {
+		return false, err
+	}
+	if len(changes) == 0 {
+		return true, nil
+	}
+	if pullHold || noPrompt {
+		return false, &sync.RiskyChangesError{Changes: changes}
+	}
+
+	fmt.Println("\nRisky changes to apply:")
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	for _, change := range changes {
+		fmt.Printf("  %s\n", change.Description)
+	}
+	if configChanges, err := syncer.IncomingConfigChanges(); err == nil && len(configChanges) > 0 {
+		fmt.Println("\nConfig changes:")
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		for _, file := range configChanges {
+			fmt.Println(file.Path)
+			for _, line := range file.Changes {
+				fmt.Printf("  %s\n", line)
+			}
+		}
+	}
+	fmt.Println()
+
+	confirmed, err := ui.Confirm("Apply these changes to your OpenCode config?", "Declining leaves them staged in the sync repo")
+	if err != nil {
+		return false, fmt.Errorf("failed to get confirmation: %w", err)
+	}
+	if !confirmed {
+		ui.Info("The changes stay staged in the sync repo. Run 'opencode-sync pull' to apply them, or 'opencode-sync push' to send your versions back.")
+	}
+	return confirmed, nil
+}
+
+// applyPulled applies the sync repo to the OpenCode config after new
+// commits arrived, backing up local files first and recording the
+// operation for undo. command names what to rerun after an interruption.
+func applyPulled(ctx context.Context, syncer *sync.Syncer, repo *git.BuiltinGit, headBefore, command string) error {
+	// A repo this build does not understand is not applied
+	if err := syncer.CheckPulled(headBefore); err != nil {
+		return err
+	}
+
+	// Avoid racing with OpenCode's own writes
+	if err := checkOpenCodeRunning(syncer.Config().Sync.WhenRunning); err != nil {
+		return err
+	}
+
+	// Renamed files are moved rather than copied anew
+	if err := syncer.DetectRenames(headBefore); err != nil {
+		return fmt.Errorf("failed to detect renamed files: %w", err)
+	}
+
+	// Journal the apply, so one cut short by a crash can be completed or
+	// rolled back by the next run
+	entry := &journal.Entry{Op: journal.OpPull, HeadBefore: headBefore, Command: command}
+	warnJournal(journal.Begin(entry))
+
+	// Back up local files so the pull can be undone
+	var backup *sync.Backup
+	if err := ui.SpinnerWithResult("Backing up local config", func() error {
+		var err error
+		backup, err = syncer.BackupLocal()
+		return err
+	}); err != nil {
+		// Nothing was applied; running the command again does it all
+		warnJournal(entry.Finish())
+		return fmt.Errorf("failed to back up local config: %w", err)
+	}
+	entry.BackupDir = backup.Dir
+	warnJournal(entry.Step(journal.StepBackedUp))
+
+	// Copy from repo to OpenCode config. A config the pull left broken
+	// must not stop OpenCode from starting here, so it is rolled back too.
+	var restored bool
+	if err := ui.SpinnerWithResult("Applying changes to OpenCode config", func() error {
+		var err error
+		restored, err = syncer.ApplyPulled(ctx, backup)
+		return err
+	}); err != nil {
+		var invalid *sync.InvalidConfigError
+		if restored || errors.As(err, &invalid) {
+			warnJournal(entry.Finish())
+		}
+		if restored && ctx.Err() != nil {
+			ui.Warn(fmt.Sprintf("Interrupted. Local config was restored; run 'opencode-sync %s' again to apply the changes.", command))
+		}
+		return err
+	}
+	reportCopyStats(syncer)
+	reportMovedFiles(syncer)
+	reportLockedIncoming(syncer)
+	if err := syncer.InvalidApplied(); err != nil {
+		ui.Warn(err.Error())
+	}
+	warnJournal(entry.Finish())
+
+	headAfter, _ := repo.GetHead()
+	if err := state.RecordOperation(&state.Operation{
+		Type:       state.OpPull,
+		Time:       time.Now(),
+		HeadBefore: headBefore,
+		HeadAfter:  headAfter,
+		BackupDir:  backup.Dir,
+	}); err != nil {
+		ui.Warn(fmt.Sprintf("Failed to record pull for undo: %v", err))
+	}
+	logOperation(state.OpPull, headBefore, headAfter, false)
+
 	return nil
 }
 
 func runStatus() error {
-	ui.Info("Checking status...")
+	if statusPorcelain {
+		if statusVerify {
+			return fmt.Errorf("--porcelain cannot be combined with --verify")
+		}
+		noPrompt = true
+	} else {
+		ui.Info("Checking status...")
+	}
 
 	syncer, err := initSyncer()
 	if err != nil {
 		return err
 	}
+	if statusNoCache {
+		syncer.DisableHashCache()
+	}
+	defer saveHashCache(syncer)
 
 	state, err := syncer.GetState()
 	if err != nil {
 		return fmt.Errorf("failed to get state: %w", err)
 	}
 
+	if statusPorcelain {
+		return printStatusPorcelain(syncer, state)
+	}
+
 	fmt.Println("\nSync Status:")
 	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 
+	if state.Branch != "" {
+		fmt.Printf("Channel: %s\n", state.Branch)
+	}
+
 	if state.IsClean {
 		fmt.Println("✓ Working directory is clean")
 	} else {
 		fmt.Println("✗ Working directory has changes")
 	}
 
-	if state.HasLocalChanges {
-		fmt.Printf("\n%d file(s) modified locally\n", len(state.LocalFiles))
+	if syncer.Direct() {
+		// The working tree is the OpenCode config, so its changes are the
+		// local ones
+		p, _ := paths.Get()
+		fmt.Printf("Layout: direct (working tree %s)\n", p.SyncRepoDir())
+	}
+
+	// The files push would send, the same as 'status --porcelain' lists
+	pending, err := syncer.PendingChanges()
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		fmt.Printf("\n%d file(s) to push:\n", len(pending))
+		for _, file := range pending {
+			fmt.Printf("  - %s\n", file)
+		}
 	} else {
 		fmt.Println("No local changes")
 	}
 
+	if !state.IsClean {
+		renames, err := syncer.WorktreeRenames()
+		if err != nil {
+			return err
+		}
+		for _, change := range renames {
+			fmt.Printf("  renamed %s → %s\n", change.OldPath, change.Path)
+		}
+	}
+
+	if pause := activePause(); pause != nil {
+		fmt.Printf("\n⏸ %s\n", pauseDescription(pause))
+	}
+
 	if len(state.ConflictFiles) > 0 {
 		fmt.Printf("\n⚠ %d conflict(s) detected:\n", len(state.ConflictFiles))
 		for _, file := range state.ConflictFiles {
@@ -520,40 +1140,181 @@ func runStatus() error {
 		}
 	}
 
+	if statusVerify {
+		return printVerification(syncer)
+	}
+
 	return nil
 }
 
+// saveHashCache keeps the hashes a command computed for the next run.
+// Failures only make the next run slower.
+func saveHashCache(syncer *sync.Syncer) {
+	if err := syncer.SaveHashCache(); err != nil && verbose {
+		fmt.Fprintf(os.Stderr, "failed to save hash cache: %v\n", err)
+	}
+}
+
+// printVerification lists local files that no longer match the sync repo
+func printVerification(syncer *sync.Syncer) error {
+	mismatches, err := syncer.VerifyLocal()
+	if err != nil {
+		return fmt.Errorf("failed to verify local files: %w", err)
+	}
+
+	if len(mismatches) == 0 {
+		fmt.Println("\n✓ All applied files match the sync repo")
+		return nil
+	}
+
+	fmt.Printf("\n✗ %d applied file(s) differ from the sync repo:\n", len(mismatches))
+	for _, m := range mismatches {
+		fmt.Printf("  - %s (%s)\n", m.LocalPath, m.Reason)
+	}
+	var only []string
+	for _, m := range mismatches {
+		only = append(only, "--only "+shellQuote(m.RelPath))
+	}
+	fmt.Printf("\nRun 'opencode-sync pull %s' to reapply them, or 'opencode-sync push' to keep the local versions.\n", strings.Join(only, " "))
+
+	return fmt.Errorf("%d file(s) differ from the sync repo", len(mismatches))
+}
+
 func runDiff() error {
-	ui.Info("Checking differences...")
+	if diffPorcelain {
+		if diffSecrets {
+			return fmt.Errorf("--porcelain cannot be combined with --secrets")
+		}
+	} else {
+		ui.Info("Checking differences...")
+	}
 
 	p, err := paths.Get()
 	if err != nil {
 		return err
 	}
 
-	repo := git.NewBuiltinGit(p.SyncRepoDir())
+	repo := sync.NewRepo(p)
 	if err := repo.Open(); err != nil {
 		return err
 	}
 
-	diff, err := repo.Diff()
+	if diffPorcelain {
+		changes, err := repo.WorktreeChanges()
+		if err != nil {
+			return fmt.Errorf("failed to get diff: %w", err)
+		}
+		printDiffPorcelain(changes)
+		return nil
+	}
+
+	changes, err := repo.WorktreeChanges()
 	if err != nil {
 		return fmt.Errorf("failed to get diff: %w", err)
 	}
 
-	if diff == "" {
+	if len(changes) == 0 {
 		fmt.Println("No differences")
+	} else {
+		fmt.Println("\nDifferences:")
+		fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+		for _, change := range changes {
+			if change.OldPath != "" {
+				fmt.Printf("%s → %s: %s\n", change.OldPath, change.Path, change.Status)
+			} else {
+				fmt.Printf("%s: %s\n", change.Path, change.Status)
+			}
+			// JSON config reads better setting by setting
+			for _, line := range structuralDiff(repo, p, change) {
+				fmt.Printf("    %s\n", line)
+			}
+		}
+		fmt.Println()
+	}
+
+	if diffSecrets {
+		return printSecretDiffs()
+	}
+
+	return nil
+}
+
+// structuralDiff describes, setting by setting, how a modified JSON file
+// in the sync repo differs from HEAD. Other files, and JSON that doesn't
+// parse, get no description.
+func structuralDiff(repo *git.BuiltinGit, p *paths.Paths, change git.FileChange) []string {
+	if change.Status != git.StatusModified || !sync.StructuralDiff(filepath.FromSlash(change.Path)) {
+		return nil
+	}
+
+	before, err := repo.ReadFileAt("HEAD", change.Path)
+	if err != nil {
+		return nil
+	}
+	after, err := os.ReadFile(filepath.Join(p.SyncRepoDir(), filepath.FromSlash(change.Path)))
+	if err != nil {
+		return nil
+	}
+
+	lines, err := sync.DescribeChanges(change.Path, before, after)
+	if err != nil {
 		return nil
 	}
+	return lines
+}
 
-	fmt.Println("\nDifferences:")
+// shellQuote quotes s for a POSIX shell if it contains special characters
+func shellQuote(s string) string {
+	if !strings.ContainsAny(s, " \t'\"$`\\*?[]{}()&;|<>!#~") {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// printSecretDiffs lists key-level changes inside encrypted files
+func printSecretDiffs() error {
+	syncer, err := initSyncer()
+	if err != nil {
+		return err
+	}
+
+	diffs, err := syncer.SecretDiffs()
+	if err != nil {
+		return fmt.Errorf("failed to diff encrypted files: %w", err)
+	}
+
+	fmt.Println("\nEncrypted files:")
 	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
-	fmt.Println(diff)
+
+	if len(diffs) == 0 {
+		fmt.Println("No changes")
+		return nil
+	}
+
+	names := make([]string, 0, len(diffs))
+	for name := range diffs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Println(name)
+		for _, change := range diffs[name] {
+			symbol := "~"
+			switch change.Change {
+			case "added":
+				symbol = "+"
+			case "removed":
+				symbol = "-"
+			}
+			fmt.Printf("  %s %s (%s)\n", symbol, change.Key, change.Change)
+		}
+	}
 
 	return nil
 }
 
-func runDoctor() error {
+func runDoctor(ctx context.Context) error {
 	ui.Info("Running diagnostics...")
 
 	p, err := paths.Get()
@@ -604,7 +1365,9 @@ func runDoctor() error {
 				// Try to load the key to verify it's valid
 				if privateKey, err := crypto.LoadKeyFromFile(keyFile); err == nil {
 					// Try to create encryption instance to verify it works
-					if _, err := crypto.NewAgeEncryption(privateKey); err == nil {
+					if crypto.IsPassphraseProtected([]byte(privateKey)) {
+						fmt.Println("✓ (passphrase-protected)")
+					} else if _, err := crypto.NewAgeEncryption(privateKey); err == nil {
 						fmt.Println("✓")
 					} else {
 						fmt.Println("✗ invalid key")
@@ -626,7 +1389,7 @@ func runDoctor() error {
 
 	// Check sync repo directory
 	fmt.Print("Sync repository directory... ")
-	if _, err := os.Stat(p.SyncRepoDir()); err == nil {
+	if _, err := os.Stat(p.SyncRepoRoot()); err == nil {
 		fmt.Println("✓")
 	} else {
 		fmt.Println("✗ not found")
@@ -637,26 +1400,64 @@ func runDoctor() error {
 	// Check git repo
 	if cfg != nil {
 		fmt.Print("Git repository... ")
-		repo := git.NewBuiltinGit(p.SyncRepoDir())
+		repo := sync.NewRepo(p)
 		if err := repo.Open(); err == nil {
 			fmt.Println("✓")
 
+			// Check object integrity
+			fmt.Print("Repository integrity... ")
+			if err := repo.Fsck(); err == nil {
+				fmt.Println("✓")
+			} else {
+				fmt.Println("✗ corrupted")
+				issues = append(issues, "Git repository objects are corrupted")
+				suggestions = append(suggestions, "Run 'opencode-sync repair' to re-clone from the remote")
+			}
+
 			// Check remote
 			fmt.Print("Git remote... ")
 			remoteURL, err := repo.GetRemoteURL("origin")
 			if err == nil {
 				fmt.Printf("✓ (%s)\n", remoteURL)
 
+				// Check the SSH host key before connecting through git
+				if _, _, ok := git.SSHEndpoint(remoteURL); ok {
+					fmt.Print("Remote host key... ")
+					if issue, suggestion := checkHostKey(ctx, cfg, remoteURL); issue != "" {
+						issues = append(issues, issue)
+						suggestions = append(suggestions, suggestion)
+					}
+				}
+
+				// Check which credential helper git would ask
+				if strings.HasPrefix(remoteURL, "https://") || strings.HasPrefix(remoteURL, "http://") {
+					fmt.Print("Credential helper... ")
+					issue, suggestion := checkCredentialHelper(repo, remoteURL)
+					if issue != "" {
+						issues = append(issues, issue)
+					}
+					if suggestion != "" {
+						suggestions = append(suggestions, suggestion)
+					}
+				}
+
 				// Check remote connectivity
 				fmt.Print("Remote connectivity... ")
 				// Try to fetch to verify connectivity (dry-run)
-				if err := repo.Fetch(); err == nil {
+				if err := repo.Fetch(ctx); err == nil {
 					fmt.Println("✓")
 				} else {
 					fmt.Println("✗ failed to connect")
 					issues = append(issues, "Cannot connect to remote")
 					suggestions = append(suggestions, "Check network connection and authentication")
 				}
+
+				// Check the system clock against the remote's
+				fmt.Print("System clock... ")
+				if issue, suggestion := checkClock(ctx, remoteURL); issue != "" {
+					issues = append(issues, issue)
+					suggestions = append(suggestions, suggestion)
+				}
 			} else {
 				fmt.Println("✗ not configured")
 				issues = append(issues, "Git remote not configured")
@@ -672,6 +1473,13 @@ func runDoctor() error {
 				fmt.Println("✗ failed to determine")
 			}
 
+			// Check that no machine committed with its clock ahead
+			fmt.Print("Commit timestamps... ")
+			if issue, suggestion := checkCommitTimes(repo); issue != "" {
+				issues = append(issues, issue)
+				suggestions = append(suggestions, suggestion)
+			}
+
 			// Check for uncommitted changes
 			fmt.Print("Working directory... ")
 			hasChanges, err := repo.HasChanges()
@@ -688,7 +1496,7 @@ func runDoctor() error {
 		} else {
 			fmt.Println("✗ failed to open")
 			issues = append(issues, "Git repository is not initialized or corrupted")
-			suggestions = append(suggestions, "Run 'opencode-sync init' to reinitialize")
+			suggestions = append(suggestions, "Run 'opencode-sync repair' to re-clone from the remote, or 'opencode-sync init' to reinitialize")
 		}
 	}
 
@@ -795,34 +1603,269 @@ func runConfigEdit() error {
 	return nil
 }
 
-func runConfigSet(key, value string) error {
+func runConfigSet(key, value string, add, remove []string) error {
 	cfg, err := config.Load()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 	if cfg == nil {
-		return fmt.Errorf("no configuration found. Run 'opencode-sync setup' first")
+		return config.ErrNoConfig
+	}
+
+	if (len(add) > 0 || len(remove) > 0) && !slices.Contains(configListKeys, key) {
+		return fmt.Errorf("--add and --remove only work with list keys: %s", strings.Join(configListKeys, ", "))
 	}
 
 	// Parse key and set value
 	switch key {
+	case "sync.exclude":
+		if err := setConfigList(&cfg.Sync.Exclude, value, add, remove); err != nil {
+			return fmt.Errorf("sync.exclude: %w", err)
+		}
 	case "repo.url":
 		cfg.Repo.URL = value
 	case "repo.branch":
 		cfg.Repo.Branch = value
+	case "repo.upstream":
+		cfg.Repo.Upstream = value
+	case "repo.timeoutSeconds":
+		seconds, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("repo.timeoutSeconds must be a number of seconds")
+		}
+		cfg.Repo.TimeoutSeconds = seconds
+	case "repo.compressionLevel":
+		level, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("repo.compressionLevel must be a number from 1 to 22")
+		}
+		cfg.Repo.CompressionLevel = level
+	case "repo.ssh.hostKeyPolicy":
+		cfg.Repo.SSH.HostKeyPolicy = value
+	case "repo.ssh.fingerprint":
+		cfg.Repo.SSH.Fingerprint = value
+	case "repo.layout":
+		p, err := paths.Get()
+		if err != nil {
+			return fmt.Errorf("failed to get paths: %w", err)
+		}
+		if value != cfg.Repo.Layout && syncRepoExists(p) {
+			return fmt.Errorf("repo.layout can only be set before 'init' or 'clone'")
+		}
+		cfg.Repo.Layout = value
+	case "repo.subdir":
+		p, err := paths.Get()
+		if err != nil {
+			return fmt.Errorf("failed to get paths: %w", err)
+		}
+		if value != cfg.Repo.Subdir && syncRepoExists(p) {
+			return fmt.Errorf("repo.subdir can only be set before 'init' or 'clone'")
+		}
+		cfg.Repo.Subdir = value
+	case "repo.forge":
+		cfg.Repo.Forge = value
+	case "repo.author.name":
+		cfg.Repo.Author.Name = value
+	case "repo.author.email":
+		cfg.Repo.Author.Email = value
 	case "encryption.enabled":
-		enabled := value == "true" || value == "yes" || value == "1"
+		enabled, err := parseConfigBool(key, value)
+		if err != nil {
+			return err
+		}
 		cfg.Encryption.Enabled = enabled
 	case "encryption.keyFile":
 		cfg.Encryption.KeyFile = value
+	case "encryption.unlockMinutes":
+		minutes, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("encryption.unlockMinutes must be a number of minutes")
+		}
+		cfg.Encryption.UnlockMinutes = minutes
 	case "sync.includeAuth":
-		enabled := value == "true" || value == "yes" || value == "1"
+		enabled, err := parseConfigBool(key, value)
+		if err != nil {
+			return err
+		}
 		cfg.Sync.IncludeAuth = enabled
 	case "sync.includeMcpAuth":
-		enabled := value == "true" || value == "yes" || value == "1"
+		enabled, err := parseConfigBool(key, value)
+		if err != nil {
+			return err
+		}
 		cfg.Sync.IncludeMcpAuth = enabled
+	case "sync.authHistory":
+		cfg.Sync.AuthHistory = value
+	case "sync.canonicalJSON":
+		enabled, err := parseConfigBool(key, value)
+		if err != nil {
+			return err
+		}
+		cfg.Sync.CanonicalJSON = enabled
+	case "sync.splitMcpSecrets":
+		enabled, err := parseConfigBool(key, value)
+		if err != nil {
+			return err
+		}
+		cfg.Sync.SplitMcpSecrets = enabled
+	case "sync.includeSessions":
+		enabled, err := parseConfigBool(key, value)
+		if err != nil {
+			return err
+		}
+		cfg.Sync.IncludeSessions = enabled
+	case "sync.sessionsMaxSizeMB":
+		size, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("sync.sessionsMaxSizeMB must be a number of megabytes")
+		}
+		cfg.Sync.SessionsMaxSizeMB = size
+	case "sync.sessionsRetentionDays":
+		days, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("sync.sessionsRetentionDays must be a number of days")
+		}
+		cfg.Sync.SessionsRetentionDays = days
+	case "sync.maxMemoryFileSizeMB":
+		size, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("sync.maxMemoryFileSizeMB must be a number of megabytes")
+		}
+		cfg.Sync.MaxMemoryFileSizeMB = size
+	case "sync.parallelism":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("sync.parallelism must be a number")
+		}
+		cfg.Sync.Parallelism = n
+	case "sync.startupPullMinutes":
+		minutes, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("sync.startupPullMinutes must be a number of minutes")
+		}
+		cfg.Sync.StartupPullMinutes = minutes
+	case "sync.includeBinaries":
+		enabled, err := parseConfigBool(key, value)
+		if err != nil {
+			return err
+		}
+		cfg.Sync.IncludeBinaries = enabled
+	case "sync.binaryAllow":
+		if err := setConfigList(&cfg.Sync.BinaryAllow, value, add, remove); err != nil {
+			return fmt.Errorf("sync.binaryAllow: %w", err)
+		}
+	case "sync.hostSecrets":
+		if err := setConfigList(&cfg.Sync.HostSecrets, value, add, remove); err != nil {
+			return fmt.Errorf("sync.hostSecrets: %w", err)
+		}
+	case "sync.unionMerge":
+		if err := setConfigList(&cfg.Sync.UnionMerge, value, add, remove); err != nil {
+			return fmt.Errorf("sync.unionMerge: %w", err)
+		}
+	case "sync.mergeKeys":
+		if err := setConfigList(&cfg.Sync.MergeKeys, value, add, remove); err != nil {
+			return fmt.Errorf("sync.mergeKeys: %w", err)
+		}
+	case "sync.copyMode":
+		cfg.Sync.CopyMode = value
+	case "sync.whenRunning":
+		cfg.Sync.WhenRunning = value
+	case "sync.historyMode":
+		cfg.Sync.HistoryMode = value
+	case "sync.onlyDirs":
+		if err := setConfigList(&cfg.Sync.OnlyDirs, value, add, remove); err != nil {
+			return fmt.Errorf("sync.onlyDirs: %w", err)
+		}
+	case "sync.preserveMtimes":
+		enabled, err := parseConfigBool(key, value)
+		if err != nil {
+			return err
+		}
+		cfg.Sync.PreserveMtimes = enabled
+	case "sync.preserveExecutable":
+		enabled, err := parseConfigBool(key, value)
+		if err != nil {
+			return err
+		}
+		cfg.Sync.PreserveExecutable = enabled
+	case "sync.network.allowMetered":
+		enabled, err := parseConfigBool(key, value)
+		if err != nil {
+			return err
+		}
+		cfg.Sync.Network.AllowMetered = enabled
+	case "watch.debounceSeconds":
+		seconds, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("watch.debounceSeconds must be a number of seconds")
+		}
+		cfg.Watch.DebounceSeconds = seconds
+	case "watch.minPushMinutes":
+		minutes, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("watch.minPushMinutes must be a number of minutes")
+		}
+		cfg.Watch.MinPushMinutes = minutes
+	case "watch.quietHours":
+		cfg.Watch.QuietHours = value
+	case "watch.skipReview":
+		enabled, err := parseConfigBool(key, value)
+		if err != nil {
+			return err
+		}
+		cfg.Watch.SkipReview = enabled
+	case "watch.minBatteryPercent":
+		percent, err := strconv.Atoi(strings.TrimSuffix(value, "%"))
+		if err != nil {
+			return fmt.Errorf("watch.minBatteryPercent must be a percentage")
+		}
+		cfg.Watch.MinBatteryPercent = percent
+	case "claude.disabled":
+		disabled, err := parseConfigBool(key, value)
+		if err != nil {
+			return err
+		}
+		cfg.Claude.Disabled = disabled
+	case "sync.statusFile":
+		cfg.Sync.StatusFile = value
+	case "usage.enabled":
+		enabled, err := parseConfigBool(key, value)
+		if err != nil {
+			return err
+		}
+		cfg.Usage.Enabled = enabled
+	case "backup.auto":
+		cfg.Backup.Auto = value
+	case "backup.keep":
+		keep, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("backup.keep must be a number of snapshots")
+		}
+		cfg.Backup.Keep = keep
+	case "backup.keepDays":
+		days, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("backup.keepDays must be a number of days")
+		}
+		cfg.Backup.KeepDays = days
+	case "claude.paths":
+		if err := setConfigList(&cfg.Claude.Paths, value, add, remove); err != nil {
+			return fmt.Errorf("claude.paths: %w", err)
+		}
 	default:
-		return fmt.Errorf("unknown config key: %s. Valid keys: repo.url, repo.branch, encryption.enabled, encryption.keyFile, sync.includeAuth, sync.includeMcpAuth", key)
+		if strings.HasPrefix(key, "repo.author.hosts.") {
+			if err := setAuthorOverride(cfg, key, value); err != nil {
+				return err
+			}
+			break
+		}
+		if strings.HasPrefix(key, "sync.targets.") {
+			if err := setTargetInterval(cfg, key, value); err != nil {
+				return err
+			}
+			break
+		}
+		return fmt.Errorf("unknown config key: %s. Valid keys: repo.url, repo.branch, repo.upstream, repo.timeoutSeconds, repo.compressionLevel, repo.layout, repo.subdir, repo.forge, repo.ssh.hostKeyPolicy, repo.ssh.fingerprint, repo.author.name, repo.author.email, repo.author.hosts.<host>.name, repo.author.hosts.<host>.email, encryption.enabled, encryption.keyFile, encryption.unlockMinutes, sync.includeAuth, sync.includeMcpAuth, sync.authHistory, sync.canonicalJSON, sync.splitMcpSecrets, sync.includeSessions, sync.sessionsMaxSizeMB, sync.sessionsRetentionDays, sync.maxMemoryFileSizeMB, sync.parallelism, sync.startupPullMinutes, sync.exclude, sync.includeBinaries, sync.binaryAllow, sync.hostSecrets, sync.unionMerge, sync.mergeKeys, sync.copyMode, sync.whenRunning, sync.historyMode, sync.onlyDirs, sync.preserveMtimes, sync.preserveExecutable, sync.targets.<name>.interval, sync.network.allowMetered, sync.statusFile, watch.debounceSeconds, watch.minPushMinutes, watch.quietHours, watch.minBatteryPercent, watch.skipReview, usage.enabled, backup.auto, backup.keep, backup.keepDays, claude.disabled, claude.paths", key)
 	}
 
 	// Validate config
@@ -835,17 +1878,69 @@ func runConfigSet(key, value string) error {
 		return fmt.Errorf("failed to save config: %w", err)
 	}
 
-	ui.Success(fmt.Sprintf("Set %s = %s", key, value))
+	// The stored value shows how the input was read, e.g. "yes" as true
+	ui.Success(fmt.Sprintf("Set %s = %s", key, configValue(cfg, key, value)))
+	return nil
+}
+
+// setAuthorOverride sets a repo.author.hosts.<host>.name or .email key.
+// Hostnames may contain dots, so the field is taken from the end.
+func setAuthorOverride(cfg *config.Config, key, value string) error {
+	rest := strings.TrimPrefix(key, "repo.author.hosts.")
+	i := strings.LastIndex(rest, ".")
+	if i <= 0 || (rest[i+1:] != "name" && rest[i+1:] != "email") {
+		return fmt.Errorf("expected repo.author.hosts.<host>.name or repo.author.hosts.<host>.email, got %s", key)
+	}
+	host, field := rest[:i], rest[i+1:]
+
+	override := cfg.Repo.Author.Hosts[host]
+	if field == "name" {
+		override.Name = value
+	} else {
+		override.Email = value
+	}
+
+	if override.Name == "" && override.Email == "" {
+		delete(cfg.Repo.Author.Hosts, host)
+		return nil
+	}
+	if cfg.Repo.Author.Hosts == nil {
+		cfg.Repo.Author.Hosts = make(map[string]config.AuthorConfig)
+	}
+	cfg.Repo.Author.Hosts[host] = override
+	return nil
+}
+
+// setTargetInterval sets a sync.targets.<name>.interval key. Target names
+// may contain dots, so the field is taken from the end. An empty value
+// removes the target.
+func setTargetInterval(cfg *config.Config, key, value string) error {
+	name, ok := strings.CutSuffix(strings.TrimPrefix(key, "sync.targets."), ".interval")
+	if !ok || name == "" {
+		return fmt.Errorf("expected sync.targets.<name>.interval, got %s", key)
+	}
+
+	if value == "" {
+		delete(cfg.Sync.Targets, name)
+		return nil
+	}
+	if cfg.Sync.Targets == nil {
+		cfg.Sync.Targets = make(map[string]config.TargetConfig)
+	}
+	cfg.Sync.Targets[name] = config.TargetConfig{Interval: value}
 	return nil
 }
 
-func runInit() error {
+func runInit(ctx context.Context, templateURL string) error {
 	ui.Info("Initializing sync repository...")
 
-	// Load config
+	// Load config. Creating the remote also creates the config.
 	cfg, err := config.Load()
+	if err == nil && cfg == nil && initCreateRemote != "" {
+		cfg = config.Default()
+	}
 	if err != nil || cfg == nil {
-		return fmt.Errorf("no configuration found. Run 'opencode-sync setup' first")
+		return config.ErrNoConfig
 	}
 
 	// Get paths
@@ -859,15 +1954,33 @@ func runInit() error {
 		return fmt.Errorf("failed to create directories: %w", err)
 	}
 
-	repoDir := p.SyncRepoDir()
+	paths.DirectLayout = cfg.Repo.Layout == config.LayoutDirect
+	paths.RepoSubdir = cfg.Repo.SubdirPath()
+	if paths.DirectLayout && templateURL != "" {
+		// The template would be written straight over the local config
+		return fmt.Errorf("init --from-template is not supported with repo.layout direct")
+	}
+
+	repoDir := p.SyncRepoRoot()
 
 	// Check if repo already exists
 	if _, err := os.Stat(filepath.Join(repoDir, ".git")); err == nil {
 		return fmt.Errorf("repository already initialized at %s", repoDir)
 	}
 
+	var created *forge.Created
+	if initCreateRemote != "" {
+		if created, err = createRemote(ctx, cfg); err != nil {
+			return err
+		}
+	}
+
+	// Until the first commit, an interrupted init leaves a half-made repo
+	entry := &journal.Entry{Op: journal.OpInit, Template: templateURL, Layout: cfg.Repo.Layout, Subdir: paths.RepoSubdir}
+	warnJournal(journal.Begin(entry))
+
 	// Initialize git repository
-	repo := git.NewBuiltinGit(repoDir)
+	repo := sync.NewRepo(p)
 	if err := ui.SpinnerWithResult("Creating Git repository", func() error {
 		return repo.Init()
 	}); err != nil {
@@ -886,29 +1999,24 @@ func runInit() error {
 	// Create syncer and copy OpenCode configs
 	ui.Info("Copying OpenCode configurations...")
 	syncer := sync.New(cfg, p, repo)
+	installMergeDriver(syncer)
 
 	// Initialize encryption if enabled
-	if cfg.Encryption.Enabled {
-		keyFile := p.KeyFile()
-		if _, err := os.Stat(keyFile); os.IsNotExist(err) {
-			return fmt.Errorf("encryption key not found. Run 'opencode-sync setup' first")
-		}
-
-		privateKey, err := crypto.LoadKeyFromFile(keyFile)
-		if err != nil {
-			return fmt.Errorf("failed to load encryption key: %w", err)
-		}
+	if err := setupEncryption(syncer, cfg, p); err != nil {
+		return err
+	}
 
-		enc, err := crypto.NewAgeEncryption(privateKey)
-		if err != nil {
-			return fmt.Errorf("failed to initialize encryption: %w", err)
+	if templateURL != "" {
+		if err := seedFromTemplate(ctx, syncer, templateURL); err != nil {
+			return err
 		}
-
-		syncer.SetEncryption(enc)
 	}
 
 	if err := ui.SpinnerWithResult("Copying OpenCode configurations", func() error {
-		return syncer.CopyToRepo()
+		if err := syncer.CopyToRepo(ctx); err != nil {
+			return err
+		}
+		return syncer.WriteRepoGitFiles()
 	}); err != nil {
 		return fmt.Errorf("failed to copy configs: %w", err)
 	}
@@ -918,14 +2026,36 @@ func runInit() error {
 		if err := repo.AddAll(); err != nil {
 			return err
 		}
-		commitMsg := fmt.Sprintf("Initial commit from %s", getHostname())
-		return repo.Commit(commitMsg)
+		subject := fmt.Sprintf("Initial commit from %s", sync.Hostname())
+		if templateURL != "" {
+			subject += fmt.Sprintf(" (template %s)", templateURL)
+		}
+		return repo.Commit(sync.CommitMessage(subject))
 	}); err != nil {
 		return fmt.Errorf("failed to commit: %w", err)
 	}
+	warnJournal(entry.Finish())
+
+	// Applied like a pull, so it is backed up and can be undone
+	if templateURL != "" {
+		head, _ := repo.GetHead()
+		if err := applyPulled(ctx, syncer, repo, head, "pull"); err != nil {
+			return fmt.Errorf("failed to apply template: %w", err)
+		}
+	}
 
 	ui.Success("Repository initialized!")
 
+	if created != nil {
+		if err := ui.SpinnerWithResult("Pushing to remote", func() error {
+			return repo.Push(ctx)
+		}); err != nil {
+			return fmt.Errorf("failed to push to %s: %w. Run 'opencode-sync push' to try again", cfg.Repo.URL, err)
+		}
+		ui.Success(fmt.Sprintf("Pushed to %s", created.WebURL))
+		return nil
+	}
+
 	// Suggest next steps
 	fmt.Println()
 	if cfg.Repo.URL != "" {
@@ -937,13 +2067,41 @@ func runInit() error {
 	return nil
 }
 
-func runLink(repoURL string) error {
+// seedFromTemplate clones a template repository to a temporary directory
+// and copies its files, minus secrets, into the sync repo
+func seedFromTemplate(ctx context.Context, syncer *sync.Syncer, templateURL string) error {
+	tmpDir, err := os.MkdirTemp("", "opencode-sync-template-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	template := git.NewBuiltinGit(filepath.Join(tmpDir, "template"))
+	if err := ui.SpinnerWithResult(fmt.Sprintf("Cloning template %s", templateURL), func() error {
+		return template.Clone(ctx, templateURL)
+	}); err != nil {
+		return fmt.Errorf("failed to clone template: %w", err)
+	}
+
+	skipped, err := syncer.SeedFromTemplate(filepath.Join(tmpDir, "template"))
+	if err != nil {
+		return err
+	}
+
+	for _, path := range skipped {
+		ui.Warn(fmt.Sprintf("Skipped template secret: %s", path))
+	}
+
+	return nil
+}
+
+func runLink(ctx context.Context, repoURL string) error {
 	ui.Info(fmt.Sprintf("Linking local configs to remote: %s", repoURL))
 
 	// Load config
 	cfg, err := config.Load()
 	if err != nil || cfg == nil {
-		return fmt.Errorf("no configuration found. Run 'opencode-sync setup' first")
+		return config.ErrNoConfig
 	}
 
 	// Get paths
@@ -957,15 +2115,24 @@ func runLink(repoURL string) error {
 		return fmt.Errorf("failed to create directories: %w", err)
 	}
 
-	repoDir := p.SyncRepoDir()
+	paths.DirectLayout = cfg.Repo.Layout == config.LayoutDirect
+	paths.RepoSubdir = cfg.Repo.SubdirPath()
+	if paths.RepoSubdir != "" {
+		// The force push would drop the rest of the repository
+		return fmt.Errorf("link replaces the remote's history, which would remove the files outside repo.subdir. Run 'opencode-sync clone %s --subdir %s' and push instead", repoURL, paths.RepoSubdir)
+	}
+	repoDir := p.SyncRepoRoot()
 
 	// Check if repo already exists
 	if _, err := os.Stat(filepath.Join(repoDir, ".git")); err == nil {
 		return fmt.Errorf("repository already exists at %s. Use 'opencode-sync push' to sync, or remove the directory first", repoDir)
 	}
 
+	entry := &journal.Entry{Op: journal.OpLink, URL: repoURL, PreviousURL: cfg.Repo.URL, Layout: cfg.Repo.Layout, Subdir: paths.RepoSubdir}
+	warnJournal(journal.Begin(entry))
+
 	// Initialize git repository
-	repo := git.NewBuiltinGit(repoDir)
+	repo := sync.NewRepo(p)
 	if err := ui.SpinnerWithResult("Creating Git repository", func() error {
 		return repo.Init()
 	}); err != nil {
@@ -983,33 +2150,24 @@ func runLink(repoURL string) error {
 	cfg.Repo.URL = repoURL
 	if err := config.Save(cfg); err != nil {
 		ui.Warn("Failed to update config with remote URL, but link will continue")
+	} else {
+		warnJournal(entry.Step(journal.StepConfigSaved))
 	}
 
 	// Create syncer and copy OpenCode configs
 	syncer := sync.New(cfg, p, repo)
+	installMergeDriver(syncer)
 
 	// Initialize encryption if enabled
-	if cfg.Encryption.Enabled {
-		keyFile := p.KeyFile()
-		if _, err := os.Stat(keyFile); os.IsNotExist(err) {
-			return fmt.Errorf("encryption key not found. Run 'opencode-sync setup' first")
-		}
-
-		privateKey, err := crypto.LoadKeyFromFile(keyFile)
-		if err != nil {
-			return fmt.Errorf("failed to load encryption key: %w", err)
-		}
-
-		enc, err := crypto.NewAgeEncryption(privateKey)
-		if err != nil {
-			return fmt.Errorf("failed to initialize encryption: %w", err)
-		}
-
-		syncer.SetEncryption(enc)
+	if err := setupEncryption(syncer, cfg, p); err != nil {
+		return err
 	}
 
 	if err := ui.SpinnerWithResult("Copying OpenCode configurations", func() error {
-		return syncer.CopyToRepo()
+		if err := syncer.CopyToRepo(ctx); err != nil {
+			return err
+		}
+		return syncer.WriteRepoGitFiles()
 	}); err != nil {
 		return fmt.Errorf("failed to copy configs: %w", err)
 	}
@@ -1019,11 +2177,29 @@ func runLink(repoURL string) error {
 		if err := repo.AddAll(); err != nil {
 			return err
 		}
-		commitMsg := fmt.Sprintf("Link from %s at %s", getHostname(), time.Now().Format("2006-01-02 15:04:05"))
+		commitMsg := sync.CommitMessage(fmt.Sprintf("Link from %s at %s", sync.Hostname(), time.Now().Format("2006-01-02 15:04:05")))
 		return repo.Commit(commitMsg)
 	}); err != nil {
 		return fmt.Errorf("failed to commit: %w", err)
 	}
+	warnJournal(entry.Step(journal.StepCommitted))
+
+	return forcePushLink(ctx, repo, cfg, entry)
+}
+
+// forcePushLink replaces the remote with the commit link made, once the
+// user confirms
+func forcePushLink(ctx context.Context, repo *git.BuiltinGit, cfg *config.Config, entry *journal.Entry) error {
+	// Learn what the remote holds now, so the force push cannot overwrite
+	// anything pushed after the user confirmed
+	if err := ui.SpinnerWithResult("Fetching remote", func() error {
+		return repo.Fetch(ctx)
+	}); err != nil {
+		return fmt.Errorf("failed to fetch remote: %w", err)
+	}
+
+	// Overwriting the branch does not remove credentials from its history
+	checkPlaintextSecrets(ctx, repo, cfg)
 
 	// Force push to overwrite remote
 	ui.Warn("This will OVERWRITE the remote repository with your local configs")
@@ -1033,16 +2209,22 @@ func runLink(repoURL string) error {
 	}
 
 	if !confirmed {
+		warnJournal(entry.Finish())
 		ui.Info("Link cancelled. Local repository created but not pushed.")
 		ui.Info("You can manually push later with: opencode-sync push")
 		return nil
 	}
 
 	if err := ui.SpinnerWithResult("Force pushing to remote", func() error {
-		return repo.ForcePush()
+		return repo.ForcePush(ctx)
 	}); err != nil {
+		var rejected *git.RejectedError
+		if errors.As(err, &rejected) {
+			return fmt.Errorf("the remote changed while linking, so nothing was overwritten. Run 'opencode-sync recover --rollback' and link again to review it: %w", err)
+		}
 		return fmt.Errorf("failed to force push: %w", err)
 	}
+	warnJournal(entry.Finish())
 
 	ui.Success("Successfully linked local configs to remote!")
 	fmt.Println()
@@ -1052,7 +2234,7 @@ func runLink(repoURL string) error {
 	return nil
 }
 
-func runClone(repoURL string) error {
+func runClone(ctx context.Context, repoURL string) error {
 	// Load or prompt for repository URL
 	if repoURL == "" {
 		cfg, err := config.Load()
@@ -1071,74 +2253,158 @@ func runClone(repoURL string) error {
 		return fmt.Errorf("failed to get paths: %w", err)
 	}
 
+	// Clone repository, limited to sync.onlyDirs if this machine has it set
+	var onlyDirs []string
+	layout, subdir := cloneLayout, cloneSubdir
+	if cfg, err := config.Load(); err == nil && cfg != nil {
+		onlyDirs = cfg.Sync.OnlyDirs
+		if layout == "" {
+			layout = cfg.Repo.Layout
+		}
+		if subdir == "" {
+			subdir = cfg.Repo.Subdir
+		}
+		if layout != cfg.Repo.Layout || subdir != cfg.Repo.Subdir {
+			cfg.Repo.Layout, cfg.Repo.Subdir = layout, subdir
+			if err := cfg.Validate(); err != nil {
+				return err
+			}
+			if err := config.Save(cfg); err != nil {
+				return fmt.Errorf("failed to save config: %w", err)
+			}
+		}
+	}
+	paths.DirectLayout = layout == config.LayoutDirect
+	paths.RepoSubdir = config.RepoConfig{Subdir: subdir}.SubdirPath()
+
 	// Ensure directories exist
 	if err := p.EnsureDirs(); err != nil {
 		return fmt.Errorf("failed to create directories: %w", err)
 	}
 
-	repoDir := p.SyncRepoDir()
+	repoDir := p.SyncRepoRoot()
 
 	// Check if repo already exists
 	if _, err := os.Stat(filepath.Join(repoDir, ".git")); err == nil {
 		return fmt.Errorf("repository already exists at %s. Use 'opencode-sync pull' to update", repoDir)
 	}
 
-	// Clone repository
-	repo := git.NewBuiltinGit(repoDir)
+	entry := &journal.Entry{Op: journal.OpClone, URL: repoURL, Layout: layout, Subdir: paths.RepoSubdir}
+	warnJournal(journal.Begin(entry))
+
+	repo := sync.NewRepo(p)
 	if err := ui.SpinnerWithResult(fmt.Sprintf("Cloning repository from %s", repoURL), func() error {
-		return repo.Clone(repoURL)
+		if len(onlyDirs) > 0 {
+			return repo.CloneSparse(ctx, repoURL, onlyDirs)
+		}
+		return repo.Clone(ctx, repoURL)
 	}); err != nil {
+		// A failed clone removes itself, so there is nothing to recover
+		if _, statErr := os.Stat(p.SyncGitDir()); os.IsNotExist(statErr) {
+			warnJournal(entry.Finish())
+		}
 		return fmt.Errorf("failed to clone repository: %w", err)
 	}
+	warnJournal(entry.Step(journal.StepCloned))
+
+	return setUpClone(ctx, p, repo, entry)
+}
 
+// setUpClone creates a config for a fresh clone if there is none and
+// applies the repository to OpenCode
+func setUpClone(ctx context.Context, p *paths.Paths, repo *git.BuiltinGit, entry *journal.Entry) error {
 	// Load config or create minimal one
 	cfg, err := config.Load()
 	if err != nil || cfg == nil {
 		// Create minimal config
 		cfg = config.Default()
-		cfg.Repo.URL = repoURL
+		cfg.Repo.URL = entry.URL
+		cfg.Repo.Layout = entry.Layout
+		cfg.Repo.Subdir = entry.Subdir
 		if err := config.Save(cfg); err != nil {
 			ui.Warn("Failed to save config, but clone succeeded")
+		} else {
+			warnJournal(entry.Step(journal.StepConfigCreated))
 		}
 	}
 
+	// Warn before applying anything from a repo that leaked credentials
+	checkPlaintextSecrets(ctx, repo, cfg)
+
 	// Create syncer and copy to OpenCode
 	ui.Info("Applying configurations to OpenCode...")
 	syncer := sync.New(cfg, p, repo)
+	installMergeDriver(syncer)
 
 	// Initialize encryption if enabled
 	if cfg.Encryption.Enabled {
 		keyFile := p.KeyFile()
 		if _, err := os.Stat(keyFile); err == nil {
-			privateKey, err := crypto.LoadKeyFromFile(keyFile)
-			if err == nil {
-				enc, err := crypto.NewAgeEncryption(privateKey)
-				if err == nil {
-					syncer.SetEncryption(enc)
-				}
+			if enc, err := crypto.LoadEncryption(keyFile); err == nil {
+				syncer.SetEncryption(enc)
 			}
 		} else {
 			ui.Warn("Encryption enabled but key file not found. Encrypted files will not be decrypted.")
 		}
 	}
 
-	if err := ui.SpinnerWithResult("Applying configurations to OpenCode", func() error {
-		return syncer.CopyFromRepo()
-	}); err != nil {
-		return fmt.Errorf("failed to copy configs: %w", err)
+	// Keep paths stored for other destinations out of the working tree
+	if err := syncer.SetSparseCheckout(); err != nil {
+		return err
+	}
+
+	// The clone is complete; applying it is journaled and backed up like a
+	// pull, so it can be undone
+	warnJournal(entry.Finish())
+
+	// Local files that differ from the repo are settled before it is
+	// applied over them
+	adopted, err := adoptLocalFiles(syncer)
+	if err != nil {
+		return err
+	}
+
+	head, _ := repo.GetHead()
+	if err := applyPulled(ctx, syncer, repo, head, "pull"); err != nil {
+		return err
 	}
 	fmt.Println()
+	if adopted > 0 {
+		ui.Success(fmt.Sprintf("Committed %d adopted local files to the sync repo", adopted))
+		ui.Info("Run 'opencode-sync push' to share them with your other machines.")
+	}
 	ui.Info("Your OpenCode is now synced. Use 'opencode-sync sync' to keep it up to date.")
 
 	return nil
 }
 
-func getHostname() string {
-	hostname, err := os.Hostname()
+// checkOpenCodeRunning applies the sync.whenRunning policy before files are
+// written into the OpenCode config directory
+func checkOpenCodeRunning(policy string) error {
+	if policy == config.WhenRunningForce {
+		return nil
+	}
+
+	running, err := procs.IsOpenCodeRunning()
+	if err != nil || !running {
+		return nil
+	}
+
+	if policy != config.WhenRunningWait {
+		ui.Warn("OpenCode is running. Restart it after the pull to pick up the new config.")
+		return nil
+	}
+
+	ui.Info("OpenCode is running, waiting for it to exit...")
+	exited, err := procs.WaitForOpenCodeExit(10*time.Minute, 2*time.Second)
 	if err != nil {
-		return "unknown"
+		return fmt.Errorf("failed to check for OpenCode process: %w", err)
 	}
-	return hostname
+	if !exited {
+		return fmt.Errorf("OpenCode is still running after 10m. Close it or set sync.whenRunning to warn or force")
+	}
+
+	return nil
 }
 
 func runKeyExport() error {
@@ -1149,7 +2415,7 @@ func runKeyExport() error {
 
 	keyFile := p.KeyFile()
 	if _, err := os.Stat(keyFile); os.IsNotExist(err) {
-		return fmt.Errorf("no encryption key found. Run 'opencode-sync setup' with encryption enabled first")
+		return crypto.ErrKeyMissing
 	}
 
 	privateKey, err := crypto.LoadKeyFromFile(keyFile)
@@ -1266,7 +2532,7 @@ func runRebind(newURL string) error {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 	if cfg == nil {
-		return fmt.Errorf("no configuration found. Run 'opencode-sync setup' first")
+		return config.ErrNoConfig
 	}
 
 	p, err := paths.Get()
@@ -1274,7 +2540,7 @@ func runRebind(newURL string) error {
 		return fmt.Errorf("failed to get paths: %w", err)
 	}
 
-	repoDir := p.SyncRepoDir()
+	repoDir := p.SyncRepoRoot()
 	gitDir := filepath.Join(repoDir, ".git")
 
 	if _, err := os.Stat(gitDir); os.IsNotExist(err) {
@@ -1294,6 +2560,16 @@ func runRebind(newURL string) error {
 		return fmt.Errorf("failed to update git remote: %w", err)
 	}
 
+	// Branches seen on the old remote say nothing about the new one, and
+	// would make push think an empty remote already has every commit
+	if out, err := exec.Command("git", "-C", repoDir, "for-each-ref", "--format=%(refname)", "refs/remotes/origin").Output(); err == nil {
+		for _, ref := range strings.Fields(string(out)) {
+			if err := runGitCommand(repoDir, "update-ref", "--no-deref", "-d", ref); err != nil {
+				return fmt.Errorf("failed to forget %s: %w", ref, err)
+			}
+		}
+	}
+
 	cfg.Repo.URL = newURL
 	if err := config.Save(cfg); err != nil {
 		return fmt.Errorf("failed to save config: %w", err)
@@ -1315,74 +2591,6 @@ func runGitCommand(dir string, args ...string) error {
 	return cmd.Run()
 }
 
-func runUninstall() error {
-	ui.Warn("This will uninstall opencode-sync from your system.")
-	fmt.Println()
-
-	p, err := paths.Get()
-	if err != nil {
-		return fmt.Errorf("failed to get paths: %w", err)
-	}
-
-	binaryPath, err := os.Executable()
-	if err != nil {
-		binaryPath = "opencode-sync (location unknown)"
-	}
-
-	fmt.Println("The following will be removed:")
-	fmt.Printf("  Binary: %s\n", binaryPath)
-	fmt.Printf("  Config: %s\n", p.ConfigDir)
-	fmt.Printf("  Data:   %s\n", p.DataDir)
-	fmt.Println()
-	ui.Info("Your OpenCode configurations will NOT be affected.")
-	fmt.Println()
-
-	confirmed, err := ui.Confirm("Proceed with uninstall?", "This cannot be undone")
-	if err != nil {
-		return err
-	}
-	if !confirmed {
-		ui.Info("Uninstall cancelled")
-		return nil
-	}
-
-	removeData, err := ui.Confirm("Also remove config and sync data?", "Includes encryption key and local repo")
-	if err != nil {
-		return err
-	}
-
-	if removeData {
-		if err := os.RemoveAll(p.ConfigDir); err != nil {
-			ui.Warn(fmt.Sprintf("Failed to remove config dir: %v", err))
-		} else {
-			ui.Success(fmt.Sprintf("Removed: %s", p.ConfigDir))
-		}
-
-		if err := os.RemoveAll(p.DataDir); err != nil {
-			ui.Warn(fmt.Sprintf("Failed to remove data dir: %v", err))
-		} else {
-			ui.Success(fmt.Sprintf("Removed: %s", p.DataDir))
-		}
-	}
-
-	if binaryPath != "" && binaryPath != "opencode-sync (location unknown)" {
-		if err := os.Remove(binaryPath); err != nil {
-			if os.IsPermission(err) {
-				ui.Warn("Cannot remove binary (permission denied). Run with sudo or remove manually:")
-				fmt.Printf("  sudo rm %s\n", binaryPath)
-			} else {
-				ui.Warn(fmt.Sprintf("Failed to remove binary: %v", err))
-			}
-		} else {
-			ui.Success(fmt.Sprintf("Removed: %s", binaryPath))
-		}
-	}
-
-	fmt.Println()
-	ui.Success("Uninstall complete!")
-	return nil
-}
-
 func runGC() error {
 	ui.Info("Running garbage collection...")
 
@@ -1391,7 +2599,7 @@ func runGC() error {
 		return fmt.Errorf("failed to get paths: %w", err)
 	}
 
-	repo := git.NewBuiltinGit(p.SyncRepoDir())
+	repo := sync.NewRepo(p)
 	if err := repo.Open(); err != nil {
 		return fmt.Errorf("failed to open repository: %w", err)
 	}
@@ -0,0 +1,121 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/GareArc/opencode-sync/internal/config"
+)
+
+// configSetAdd and configSetRemove are set by 'config set --add' and
+// 'config set --remove'
+var configSetAdd, configSetRemove []string
+
+// configListKeys are the config keys holding lists
+var configListKeys = []string{"sync.exclude", "sync.binaryAllow", "sync.hostSecrets", "sync.unionMerge", "sync.mergeKeys", "sync.onlyDirs", "claude.paths"}
+
+// parseConfigBool reads the boolean value of key, rejecting anything that
+// is not clearly true or false
+func parseConfigBool(key, value string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "true", "yes", "on", "1":
+		return true, nil
+	case "false", "no", "off", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("%s must be true or false, not %q", key, value)
+}
+
+// parseConfigList reads a list value: a JSON array of strings, or
+// comma-separated elements. Empty elements are dropped.
+func parseConfigList(value string) ([]string, error) {
+	value = strings.TrimSpace(value)
+
+	var elements []string
+	if strings.HasPrefix(value, "[") {
+		if err := json.Unmarshal([]byte(value), &elements); err != nil {
+			return nil, fmt.Errorf("must be a JSON array of strings: %w", err)
+		}
+	} else {
+		elements = strings.Split(value, ",")
+	}
+
+	var list []string
+	for _, element := range elements {
+		if element = strings.TrimSpace(element); element != "" {
+			list = append(list, element)
+		}
+	}
+	return list, nil
+}
+
+// setConfigList replaces list with value, or without a value adds and
+// removes the given elements. Elements already in the list are not added
+// again.
+func setConfigList(list *[]string, value string, add, remove []string) error {
+	if len(add) == 0 && len(remove) == 0 {
+		parsed, err := parseConfigList(value)
+		if err != nil {
+			return err
+		}
+		*list = parsed
+		return nil
+	}
+
+	for _, element := range remove {
+		i := slices.Index(*list, element)
+		if i < 0 {
+			return fmt.Errorf("%q is not in the list", element)
+		}
+		*list = slices.Delete(*list, i, i+1)
+	}
+	for _, element := range add {
+		if element = strings.TrimSpace(element); element != "" && !slices.Contains(*list, element) {
+			*list = append(*list, element)
+		}
+	}
+	return nil
+}
+
+// configValue returns the value of key as stored in cfg, in JSON, or
+// fallback if the key does not map onto the config file. Unset values
+// are shown as "(unset)", as their defaults apply.
+func configValue(cfg *config.Config, key, fallback string) string {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return fallback
+	}
+	var node any
+	if err := json.Unmarshal(data, &node); err != nil {
+		return fallback
+	}
+
+	// Map keys such as target names may contain dots, so the longest
+	// run of fields that names a member is taken
+	fields := strings.Split(key, ".")
+	for len(fields) > 0 {
+		object, ok := node.(map[string]any)
+		if !ok {
+			return fallback
+		}
+		n := len(fields)
+		for ; n > 0; n-- {
+			if member, ok := object[strings.Join(fields[:n], ".")]; ok {
+				node = member
+				break
+			}
+		}
+		if n == 0 {
+			return "(unset)"
+		}
+		fields = fields[n:]
+	}
+
+	value, err := json.Marshal(node)
+	if err != nil {
+		return fallback
+	}
+	return string(value)
+}
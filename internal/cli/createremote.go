@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/GareArc/opencode-sync/internal/config"
+	"github.com/GareArc/opencode-sync/internal/forge"
+	"github.com/GareArc/opencode-sync/internal/ui"
+)
+
+// createRemote creates the private repository 'init --create-remote' asks
+// for and saves it as repo.url
+func createRemote(ctx context.Context, cfg *config.Config) (*forge.Created, error) {
+	kind := initCreateRemote
+	if !slices.Contains(forge.Kinds, kind) {
+		return nil, fmt.Errorf("--create-remote must be one of: %s", strings.Join(forge.Kinds, ", "))
+	}
+	if cfg.Repo.URL != "" {
+		return nil, fmt.Errorf("repo.url is already set to %s. Run 'opencode-sync init' without --create-remote to use it", cfg.Repo.URL)
+	}
+
+	host := initRemoteHost
+	if host == "" {
+		host = forge.DefaultHosts[kind]
+	}
+	if host == "" {
+		return nil, fmt.Errorf("--create-remote %s needs --remote-host", kind)
+	}
+
+	var created *forge.Created
+	if err := ui.SpinnerWithResult(fmt.Sprintf("Creating private repository %s on %s", initRemoteName, host), func() error {
+		var err error
+		created, err = forge.CreateRepo(ctx, kind, host, initRemoteName)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	cfg.Repo.URL = created.HTTPSURL
+	if initRemoteSSH {
+		cfg.Repo.URL = created.SSHURL
+	}
+	cfg.Repo.Forge = kind
+	if err := config.Save(cfg); err != nil {
+		return nil, fmt.Errorf("failed to save config: %w", err)
+	}
+
+	ui.Success(fmt.Sprintf("Created %s", created.WebURL))
+	return created, nil
+}
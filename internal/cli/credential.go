@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+
+	"github.com/GareArc/opencode-sync/internal/git"
+)
+
+// suggestedCredentialHelper is the credential helper usually available
+// on this platform
+func suggestedCredentialHelper() string {
+	switch runtime.GOOS {
+	case "darwin":
+		return "osxkeychain"
+	case "windows":
+		return "manager"
+	default:
+		return "libsecret"
+	}
+}
+
+// checkCredentialHelper reports which git credential helper would supply
+// credentials for an HTTP(S) remote. It returns an issue and suggestion
+// for doctor; either is empty when there is nothing to report.
+func checkCredentialHelper(repo *git.BuiltinGit, remoteURL string) (string, string) {
+	helper, err := repo.CredentialHelper(remoteURL)
+	if err != nil {
+		fmt.Println("✗ failed to check")
+		return fmt.Sprintf("Cannot read git credential settings: %v", err), "Check your git configuration with 'git config --list --show-origin'"
+	}
+
+	if helper == "" {
+		fmt.Println("⚠ none configured")
+		return "", fmt.Sprintf("Configure a credential helper (e.g. 'git config --global credential.helper %s') so your access token is stored instead of asked for on every sync", suggestedCredentialHelper())
+	}
+
+	path, ok := git.CredentialHelperPath(helper)
+	if !ok {
+		fmt.Printf("✗ %s (%s not found)\n", helper, path)
+		return fmt.Sprintf("Credential helper %q is configured but not installed", helper),
+			fmt.Sprintf("Install %s, or point credential.helper at an installed helper", path)
+	}
+
+	if strings.HasPrefix(helper, "!") || path == helper {
+		fmt.Printf("✓ %s\n", helper)
+	} else {
+		fmt.Printf("✓ %s (%s)\n", helper, path)
+	}
+	return "", ""
+}
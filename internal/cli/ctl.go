@@ -0,0 +1,371 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/GareArc/opencode-sync/internal/daemon"
+	"github.com/GareArc/opencode-sync/internal/paths"
+	"github.com/GareArc/opencode-sync/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// errWatchNotRunning is returned by 'ctl' when no watch serves the control
+// API
+var errWatchNotRunning = errors.New("watch is not running; start it with 'opencode-sync watch'")
+
+// controlInfo is what the control file holds while watch runs
+type controlInfo struct {
+	// URL is the base URL of the control API
+	URL   string `json:"url"`
+	Token string `json:"token"`
+	PID   int    `json:"pid"`
+}
+
+var (
+	ctlStatusJSON bool
+	ctlSyncNoWait bool
+	ctlPauseFor   time.Duration
+	ctlLogsFollow bool
+)
+
+// ctlCmd represents the ctl command
+var ctlCmd = &cobra.Command{
+	Use:   "ctl",
+	Short: "Control a running watch",
+	Long: `Control a running 'opencode-sync watch' through its local REST API,
+without starting a sync of its own.
+
+Watch serves the API on the --listen address, or on a random loopback port
+without it. The address and a token are kept in control.json in the data
+dir, readable only by you, while watch runs. Every request needs the token
+as a bearer token:
+
+  GET  /api/v1/status           watch's state as JSON
+  POST /api/v1/sync?wait=true   sync now; without wait, answers at once
+  POST /api/v1/pause?for=1h     pause automatic syncing
+  POST /api/v1/resume           resume automatic syncing
+  GET  /api/v1/logs?follow=true recent output, then new lines as they come
+
+For example:
+  curl -H "Authorization: Bearer $(jq -r .token control.json)" \
+    "$(jq -r .url control.json)/api/v1/status"`,
+}
+
+var ctlStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show what watch is doing",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runCtlStatus(cmd.Context(), ctlStatusJSON)
+	},
+}
+
+var ctlSyncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Make watch sync now",
+	Long: `Make watch sync now, even while paused, and wait for the result.
+With --no-wait, return as soon as watch has the request.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runCtlSync(cmd.Context(), !ctlSyncNoWait)
+	},
+}
+
+var ctlPauseCmd = &cobra.Command{
+	Use:   "pause",
+	Short: "Pause watch's automatic syncing",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		query := ""
+		if ctlPauseFor > 0 {
+			query = "?for=" + url.QueryEscape(ctlPauseFor.String())
+		}
+		return runCtlStateChange(cmd.Context(), "/api/v1/pause"+query)
+	},
+}
+
+var ctlResumeCmd = &cobra.Command{
+	Use:   "resume",
+	Short: "Resume watch's automatic syncing",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runCtlStateChange(cmd.Context(), "/api/v1/resume")
+	},
+}
+
+var ctlLogsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "Show watch's recent output",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runCtlLogs(cmd.Context(), ctlLogsFollow)
+	},
+}
+
+func init() {
+	ctlStatusCmd.Flags().BoolVar(&ctlStatusJSON, "json", false, "print the status as JSON")
+	ctlSyncCmd.Flags().BoolVar(&ctlSyncNoWait, "no-wait", false, "return without waiting for the sync to finish")
+	ctlPauseCmd.Flags().DurationVar(&ctlPauseFor, "for", 0, "resume automatically after this long (e.g. 30m, 2h)")
+	ctlLogsCmd.Flags().BoolVarP(&ctlLogsFollow, "follow", "f", false, "keep printing new output")
+
+	ctlCmd.AddCommand(ctlStatusCmd)
+	ctlCmd.AddCommand(ctlSyncCmd)
+	ctlCmd.AddCommand(ctlPauseCmd)
+	ctlCmd.AddCommand(ctlResumeCmd)
+	ctlCmd.AddCommand(ctlLogsCmd)
+}
+
+// startControl serves the control API for watch, next to /healthz and
+// /metrics on listen or on a loopback port if listen is empty, and
+// records where in the control file. The returned function stops it.
+func startControl(listen string, metrics *daemon.Metrics, mode string) (*daemon.API, func(), error) {
+	log := daemon.NewLog()
+	api, err := daemon.NewAPI(log)
+	if err != nil {
+		return nil, nil, err
+	}
+	api.Status = func() daemon.Status {
+		status := metrics.Status()
+		status.Mode = mode
+		if pause := activePause(); pause != nil {
+			status.Paused = true
+			if !pause.Until.IsZero() {
+				status.PausedUntil = &pause.Until
+			}
+		}
+		return status
+	}
+	api.Pause = func(d time.Duration) error {
+		_, err := pauseSyncing(d)
+		return err
+	}
+	api.Resume = func() error {
+		_, err := resumeSyncing()
+		return err
+	}
+
+	p, err := paths.Get()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get paths: %w", err)
+	}
+
+	if listen == "" {
+		listen = "127.0.0.1:0"
+	}
+	server := daemon.NewServer(listen, metrics)
+	server.EnableAPI(api)
+	if err := server.Start(); err != nil {
+		return nil, nil, err
+	}
+
+	info := controlInfo{URL: "http://" + server.Addr().String(), Token: api.Token, PID: os.Getpid()}
+	if err := writeControlFile(p.ControlFile(), &info); err != nil {
+		server.Close()
+		return nil, nil, err
+	}
+
+	ui.SetLog(log)
+	stop := func() {
+		ui.SetLog(nil)
+		// Another watch may have started since and taken over the file
+		if current, err := readControlFile(p.ControlFile()); err == nil && current.Token == info.Token {
+			os.Remove(p.ControlFile())
+		}
+		server.Close()
+	}
+	return api, stop, nil
+}
+
+// writeControlFile writes info readable only by the user, as the token
+// lets anyone holding it drive watch
+func writeControlFile(path string, info *controlInfo) error {
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal control file: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write control file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write control file: %w", err)
+	}
+	return nil
+}
+
+// readControlFile reads the control file of the running watch
+func readControlFile(path string) (*controlInfo, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errWatchNotRunning
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read control file: %w", err)
+	}
+
+	var info controlInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse control file: %w", err)
+	}
+	return &info, nil
+}
+
+// controlRequest sends a request to the running watch's control API. The
+// caller closes the body of the response, which has a 2xx status.
+func controlRequest(ctx context.Context, method, path string) (*http.Response, error) {
+	p, err := paths.Get()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get paths: %w", err)
+	}
+	info, err := readControlFile(p.ControlFile())
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, info.URL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+info.Token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		// Left behind by a watch that did not exit cleanly
+		return nil, errWatchNotRunning
+	}
+
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		var body struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&body) == nil && body.Error != "" {
+			return nil, errors.New(body.Error)
+		}
+		return nil, fmt.Errorf("watch answered %s", resp.Status)
+	}
+	return resp, nil
+}
+
+func runCtlStatus(ctx context.Context, asJSON bool) error {
+	resp, err := controlRequest(ctx, http.MethodGet, "/api/v1/status")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if asJSON {
+		_, err := io.Copy(os.Stdout, resp.Body)
+		return err
+	}
+
+	var status daemon.Status
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return fmt.Errorf("failed to parse status: %w", err)
+	}
+	printWatchStatus(&status)
+	return nil
+}
+
+// printWatchStatus prints what 'ctl status' shows
+func printWatchStatus(status *daemon.Status) {
+	activity := "idle"
+	if status.Syncing {
+		activity = "syncing"
+	}
+	fmt.Printf("Watch (%s): %s\n", status.Mode, activity)
+
+	switch {
+	case status.LastSync == nil:
+		fmt.Println("Last sync: none yet")
+	case status.LastError != "":
+		fmt.Printf("Last sync: %s, failed: %s\n", status.LastSync.Local().Format("2006-01-02 15:04:05"), status.LastError)
+	default:
+		fmt.Printf("Last sync: %s, ok\n", status.LastSync.Local().Format("2006-01-02 15:04:05"))
+	}
+	fmt.Printf("Syncs: %d (%d failed)\n", status.Syncs, status.Failures)
+	fmt.Printf("Pending changes: %d\n", status.PendingChanges)
+
+	switch {
+	case status.PausedUntil != nil:
+		fmt.Printf("⏸ Paused until %s\n", status.PausedUntil.Local().Format("2006-01-02 15:04"))
+	case status.Paused:
+		fmt.Println("⏸ Paused until 'opencode-sync resume'")
+	}
+}
+
+func runCtlSync(ctx context.Context, wait bool) error {
+	path := "/api/v1/sync"
+	if wait {
+		ui.Info("Asking watch to sync...")
+		path += "?wait=true"
+	}
+
+	resp, err := controlRequest(ctx, http.MethodPost, path)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if wait {
+		ui.Success("Synced")
+	} else {
+		ui.Success("Sync requested")
+	}
+	return nil
+}
+
+// runCtlStateChange pauses or resumes watch and shows its new status
+func runCtlStateChange(ctx context.Context, path string) error {
+	resp, err := controlRequest(ctx, http.MethodPost, path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var status daemon.Status
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return fmt.Errorf("failed to parse status: %w", err)
+	}
+	printWatchStatus(&status)
+	return nil
+}
+
+func runCtlLogs(ctx context.Context, follow bool) error {
+	path := "/api/v1/logs"
+	if follow {
+		path += "?follow=true"
+	}
+
+	resp, err := controlRequest(ctx, http.MethodGet, path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		fmt.Println(scanner.Text())
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("failed to read logs: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/GareArc/opencode-sync/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// driftEnforce is set by 'drift --enforce'
+var driftEnforce bool
+
+// driftCmd represents the drift command
+var driftCmd = &cobra.Command{
+	Use:   "drift",
+	Short: "Compare your applied config with the team's baseline",
+	Long: `Compare the config applied on this machine with the baseline/ directory
+committed to the sync repo, laid out like the rest of the repo (e.g.
+baseline/agent/review.md for agent/review.md). The baseline is never
+copied by push or pull.
+
+Reports baseline files that are missing or modified locally, and local
+files the baseline doesn't have in the directories it has files in, such
+as extra agents. JSON files are compared ignoring formatting. Exits 1 if
+anything drifted.
+
+With --enforce, drifting files are reset to the baseline and extra files
+removed, after backing them up.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runDrift(driftEnforce)
+	},
+}
+
+func init() {
+	driftCmd.Flags().BoolVar(&driftEnforce, "enforce", false, "reset drifting files to the baseline")
+}
+
+func runDrift(enforce bool) error {
+	syncer, err := initSyncer()
+	if err != nil {
+		return err
+	}
+
+	if !syncer.HasBaseline() {
+		ui.Info("The sync repo has no baseline/ directory to compare with")
+		return nil
+	}
+
+	drift, err := syncer.Drift()
+	if err != nil {
+		return err
+	}
+
+	if len(drift) == 0 {
+		ui.Success("Your config matches the baseline")
+		return nil
+	}
+
+	fmt.Printf("%d file(s) drift from the baseline:\n", len(drift))
+	for _, d := range drift {
+		fmt.Printf("  %-8s  %s\n", d.Kind, d.LocalPath)
+	}
+
+	if !enforce {
+		fmt.Println("\nRun 'opencode-sync drift --enforce' to reset them to the baseline.")
+		return fmt.Errorf("%d file(s) drift from the baseline", len(drift))
+	}
+
+	if dryRun {
+		ui.Info("Dry run: no files changed")
+		return nil
+	}
+
+	backup, err := syncer.EnforceBaseline(drift)
+	if backup != nil {
+		ui.Info(fmt.Sprintf("Backed up the local files to %s", backup.Dir))
+	}
+	if err != nil {
+		return err
+	}
+
+	ui.Success(fmt.Sprintf("Reset %d file(s) to the baseline", len(drift)))
+	return nil
+}
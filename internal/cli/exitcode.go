@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"context"
+	"errors"
+
+	"github.com/GareArc/opencode-sync/internal/config"
+	"github.com/GareArc/opencode-sync/internal/crypto"
+	"github.com/GareArc/opencode-sync/internal/git"
+	"github.com/GareArc/opencode-sync/internal/sync"
+)
+
+// Exit codes returned by the CLI. These are part of the documented
+// interface for scripts, so existing values must not change.
+const (
+	ExitOK          = 0
+	ExitError       = 1
+	ExitNoConfig    = 3
+	ExitKeyMissing  = 4
+	ExitAuth        = 5
+	ExitNetwork     = 6
+	ExitConflict    = 7
+	ExitInterrupted = 130
+)
+
+// ExitCode maps an error returned by Execute to the process exit code
+func ExitCode(err error) int {
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.Is(err, context.Canceled):
+		return ExitInterrupted
+	case errors.Is(err, config.ErrNoConfig):
+		return ExitNoConfig
+	case errors.Is(err, crypto.ErrKeyMissing):
+		return ExitKeyMissing
+	case errors.Is(err, git.ErrAuth):
+		return ExitAuth
+	case errors.Is(err, git.ErrNetwork):
+		return ExitNetwork
+	case errors.Is(err, git.ErrConflict):
+		return ExitConflict
+	default:
+		return ExitError
+	}
+}
+
+// needsUser reports whether an error will keep happening until the user
+// intervenes, so retrying it on a schedule is pointless
+func needsUser(err error) bool {
+	var manifest *sync.ManifestError
+	var lint *sync.LintError
+	return errors.As(err, &manifest) || errors.As(err, &lint) ||
+		errors.Is(err, config.ErrNoConfig) ||
+		errors.Is(err, crypto.ErrKeyMissing) ||
+		errors.Is(err, git.ErrConflict)
+}
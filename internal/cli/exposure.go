@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/GareArc/opencode-sync/internal/config"
+	"github.com/GareArc/opencode-sync/internal/git"
+	"github.com/GareArc/opencode-sync/internal/paths"
+	"github.com/GareArc/opencode-sync/internal/sync"
+	"github.com/GareArc/opencode-sync/internal/ui"
+)
+
+// checkPlaintextSecrets warns when the sync repo's history holds
+// credentials committed without encryption, and walks through cleaning
+// them up. Problems with the scan itself are only reported, so they never
+// block clone or link.
+func checkPlaintextSecrets(ctx context.Context, repo *git.BuiltinGit, cfg *config.Config) {
+	exposed, err := sync.PlaintextSecrets(repo)
+	if err != nil {
+		ui.Warn(fmt.Sprintf("Could not check the repository for plaintext credentials: %v", err))
+		return
+	}
+	if len(exposed) == 0 {
+		return
+	}
+
+	p, _ := paths.Get()
+
+	fmt.Println()
+	ui.Warn("PLAINTEXT CREDENTIALS FOUND IN THE SYNC REPOSITORY")
+	var current, files []string
+	for _, secret := range exposed {
+		files = append(files, secret.Path)
+		if secret.InHead {
+			current = append(current, secret.Path)
+			fmt.Printf("  %s (in the current files)\n", secret.Path)
+		} else {
+			fmt.Printf("  %s (in history)\n", secret.Path)
+		}
+	}
+	fmt.Println("Anyone who can read this repository, or any clone of it, can use these credentials.")
+	fmt.Println()
+
+	// 1. Encrypt going forward
+	fmt.Println("1. Encrypt credentials from now on")
+	if cfg.Encryption.Enabled {
+		fmt.Println("   Encryption is enabled; auth files are only pushed as .age files.")
+	} else {
+		fmt.Println("   opencode-sync config set encryption.enabled true")
+		fmt.Println("   opencode-sync config set sync.includeAuth true   (only if you want auth synced)")
+	}
+
+	// 2. Remove them from the current branch
+	if len(current) > 0 {
+		fmt.Println("2. Remove the plaintext files from the current branch")
+		if removePlaintextSecrets(ctx, repo, current) {
+			fmt.Println("   Done.")
+		} else {
+			fmt.Printf("   git -C %s rm %s && git -C %s commit -m \"Remove plaintext credentials\" && opencode-sync push\n",
+				shellQuote(p.SyncRepoDir()), strings.Join(current, " "), shellQuote(p.SyncRepoDir()))
+		}
+	} else {
+		fmt.Println("2. The current files are clean; nothing to remove")
+	}
+
+	// 3. Purge history, which rewrites every commit
+	fmt.Println("3. Purge them from history (rewrites history; every machine must clone again)")
+	// filter-repo takes paths from the repository root
+	var prefix string
+	if paths.RepoSubdir != "" {
+		prefix = paths.RepoSubdir + "/"
+	}
+	var args []string
+	for _, path := range files {
+		args = append(args, "--path "+shellQuote(prefix+path))
+	}
+	fmt.Printf("   git -C %s filter-repo --invert-paths %s\n", shellQuote(p.SyncRepoRoot()), strings.Join(args, " "))
+	fmt.Printf("   git -C %s push --force --all\n", shellQuote(p.SyncRepoRoot()))
+	fmt.Println("   Your git host may keep old commits in forks, pull requests or caches.")
+
+	// 4. Rotate, since a purge cannot take back what was already read
+	fmt.Println("4. Rotate the exposed credentials")
+	fmt.Println("   - Log out and back in to each provider in OpenCode (opencode auth logout / login)")
+	fmt.Println("   - Revoke the old API keys and tokens in each provider's dashboard")
+	fmt.Println("   - Re-authorize MCP servers whose OAuth tokens were in mcp-auth.json")
+	fmt.Println()
+}
+
+// removePlaintextSecrets offers to delete exposed files from the current
+// branch, commit and push. It returns false if the user declined or it
+// failed, so the manual steps are shown instead.
+func removePlaintextSecrets(ctx context.Context, repo *git.BuiltinGit, paths []string) bool {
+	if noPrompt {
+		return false
+	}
+
+	confirmed, err := ui.Confirm("Remove the plaintext files from the current branch now?", "They are deleted from the repository and the removal is pushed; local copies in OpenCode are kept")
+	if err != nil || !confirmed {
+		return false
+	}
+
+	if err := repo.RemovePaths(paths); err != nil {
+		ui.Warn(err.Error())
+		return false
+	}
+	if err := repo.Commit(sync.CommitMessage("Remove plaintext credentials")); err != nil {
+		ui.Warn(fmt.Sprintf("Failed to commit: %v", err))
+		return false
+	}
+
+	if err := ui.SpinnerWithResult("Pushing removal", func() error {
+		return sync.PushWithRetry(ctx, repo)
+	}); err != nil {
+		ui.Warn(fmt.Sprintf("Committed but failed to push; run 'opencode-sync push': %v", err))
+	}
+	return true
+}
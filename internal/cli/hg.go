@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/GareArc/opencode-sync/internal/config"
+	"github.com/GareArc/opencode-sync/internal/git"
+	"github.com/GareArc/opencode-sync/internal/hg"
+	"github.com/GareArc/opencode-sync/internal/paths"
+	"github.com/GareArc/opencode-sync/internal/vcs"
+)
+
+func init() {
+	for _, scheme := range hg.Schemes {
+		git.RegisterTransport(scheme, openHg)
+	}
+}
+
+// openHg opens the transport for a Mercurial repo.url. The repository
+// holds the sync repo's files and history as changesets, so it needs no
+// repo key beyond what the sync repo encrypts itself.
+func openHg(rawURL string) (git.Transport, error) {
+	p, err := paths.Get()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get paths: %w", err)
+	}
+
+	// hg+ssh honors repo.ssh.hostKeyPolicy like git's ssh does
+	var options []string
+	if cfg, err := config.Load(); err == nil && cfg != nil {
+		options = sshOptions(cfg)
+	}
+
+	// The Mercurial clone lives next to the git mirror
+	mirror := transportMirror(p, rawURL)
+	client, err := hg.New(rawURL, strings.TrimSuffix(mirror, ".git")+".hg", options)
+	if err != nil {
+		return nil, err
+	}
+	return vcs.NewTransport(client, mirror), nil
+}
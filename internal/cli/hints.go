@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/GareArc/opencode-sync/internal/config"
+	"github.com/GareArc/opencode-sync/internal/crypto"
+	"github.com/GareArc/opencode-sync/internal/forge"
+	"github.com/GareArc/opencode-sync/internal/git"
+	"github.com/GareArc/opencode-sync/internal/paths"
+	"github.com/GareArc/opencode-sync/internal/snapshot"
+	"github.com/GareArc/opencode-sync/internal/sync"
+	"github.com/GareArc/opencode-sync/internal/ui"
+)
+
+// remediation returns the next steps for a failed command, or "" if
+// there is nothing more useful to say than the error itself
+func remediation(err error) string {
+	var (
+		timeout    *git.TimeoutError
+		rejected   *git.RejectedError
+		corruption *git.CorruptionError
+		secrets    *sync.SecretsError
+		recipients *sync.RecipientsError
+		invalid    *sync.InvalidConfigError
+		lint       *sync.LintError
+		risky      *sync.RiskyChangesError
+		manifest   *sync.ManifestError
+		apiErr     *forge.APIError
+		tooLarge   *git.FileTooLargeError
+	)
+
+	switch {
+	case err == nil, errors.Is(err, context.Canceled):
+		return ""
+	case errors.Is(err, config.ErrNoConfig):
+		return "Run 'opencode-sync setup' to create a configuration."
+	case errors.Is(err, crypto.ErrKeyMissing):
+		return "Copy your key from another machine: run 'opencode-sync key export' there, then 'opencode-sync key import <key>' here."
+	case errors.Is(err, crypto.ErrKeyLocked):
+		return "Your key file is passphrase-protected. Run the command interactively, or set OPENCODE_SYNC_KEY_PASSPHRASE."
+	case errors.As(err, &timeout):
+		return "The remote did not respond in time. Check your connection, or allow more time with 'opencode-sync config set repo.timeoutSeconds <seconds>'."
+	case errors.Is(err, git.ErrNetwork):
+		return "Check your network connection and the repository URL ('opencode-sync config show'), then try again."
+	case errors.Is(err, snapshot.ErrUnauthorized):
+		return fmt.Sprintf("Check the username in repo.url and the password (an app password on Nextcloud) in %s or your git credential helper.", webdavPasswordEnv)
+	case errors.Is(err, git.ErrAuth):
+		return "Check that your SSH key or access token can reach the repository (e.g. 'ssh -T git@github.com'), then run 'opencode-sync doctor'."
+	case errors.Is(err, git.ErrConflict):
+		return conflictHint()
+	case errors.As(err, &rejected):
+		return "Another machine keeps pushing at the same time. Run 'opencode-sync sync' again."
+	case errors.Is(err, git.ErrMissingPrerequisites):
+		return "The bundle builds on commits this machine never received. Create it again with 'opencode-sync bundle create --full <file>'."
+	case errors.As(err, &secrets):
+		return "Reference credentials with {env:NAME} or {file:path} instead of writing them into synced files, or add the files to sync.exclude with 'opencode-sync config edit'."
+	case errors.As(err, &recipients):
+		return "A machine listed as a recipient of these files must push them to encrypt them to the current keys. Review the rules with 'opencode-sync recipients list'."
+	case errors.As(err, &invalid):
+		return fmt.Sprintf("Fix %s on the machine that pushed it and push again; 'opencode-sync audit --path %s' shows who changed it.", invalid.Problems[0].Path, invalid.Problems[0].Path)
+	case errors.As(err, &lint):
+		return "Fix the problems listed above and push again, or push anyway with 'opencode-sync push --no-lint'."
+	case errors.As(err, &manifest) && manifest.BadSignature:
+		return "Check who changed it with 'opencode-sync audit --path sync-manifest.json'. If the change is genuine, run 'opencode-sync manifest sign'."
+	case errors.As(err, &manifest):
+		return "Upgrade opencode-sync on this machine. 'opencode-sync manifest' shows what the sync repo requires."
+	case errors.As(err, &risky):
+		return "Run 'opencode-sync pull --review' in a terminal to look at them and apply them, or set watch.skipReview to true to let watch apply such changes."
+	case errors.Is(err, forge.ErrUnknownForge):
+		return "Name the service hosting your remote with 'opencode-sync config set repo.forge github' (or gitlab)."
+	case errors.Is(err, forge.ErrRepoExists):
+		return "Pick another name with --remote-name, or use the existing repository with 'opencode-sync link <url>'."
+	case errors.Is(err, forge.ErrNoToken):
+		return "Sign in with 'gh auth login' or 'glab auth login', or create an API token and set it in GITHUB_TOKEN, GITLAB_TOKEN or GITEA_TOKEN."
+	case errors.As(err, &apiErr) && (apiErr.Status == 401 || apiErr.Status == 403):
+		return "Check that your API token is valid and allowed to create repositories and pull requests (the repo scope on GitHub, api on GitLab)."
+	case errors.As(err, &tooLarge):
+		return "Allow larger files with 'opencode-sync config set sync.maxMemoryFileSizeMB <megabytes>', or add the file to sync.exclude with 'opencode-sync config edit'."
+	case errors.As(err, &corruption):
+		return "Run 'opencode-sync repair' to re-clone the sync repository. Unpushed local changes are kept."
+	}
+
+	return ""
+}
+
+// conflictHint explains how to get out of a conflict. A pull leaves the
+// merge in the sync repo to resolve, while a push aborts its rebase.
+func conflictHint() string {
+	p, err := paths.Get()
+	if err != nil {
+		return ""
+	}
+
+	repo := sync.NewRepo(p)
+	if err := repo.Open(); err != nil || !repo.MergeInProgress() {
+		return "Run 'opencode-sync pull' to merge the remote changes first, then push again."
+	}
+
+	return fmt.Sprintf("Edit the conflicted files in %s and commit them with git, or run 'git -C %s merge --abort' to drop the remote changes. Then run 'opencode-sync sync'.", p.SyncRepoDir(), p.SyncRepoDir())
+}
+
+// reportError prints an error followed by its remediation hint
+func reportError(err error) {
+	ui.Error(err.Error())
+	printHint(err)
+}
+
+// printHint prints the remediation hint for err, if any
+func printHint(err error) {
+	if hint := remediation(err); hint != "" {
+		ui.Info(hint)
+	}
+}
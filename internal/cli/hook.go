@@ -0,0 +1,223 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/GareArc/opencode-sync/internal/config"
+	"github.com/GareArc/opencode-sync/internal/git"
+	"github.com/GareArc/opencode-sync/internal/paths"
+	"github.com/GareArc/opencode-sync/internal/state"
+	"github.com/GareArc/opencode-sync/internal/sync"
+	"github.com/GareArc/opencode-sync/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// hookTimeout is set by 'hook opencode-start --timeout'
+var hookTimeout time.Duration
+
+// hookCmd represents the hook command
+var hookCmd = &cobra.Command{
+	Use:   "hook",
+	Short: "Entry points for editor and shell integrations",
+	Long: `Commands meant to be run by other programs rather than by hand. They
+never prompt and stay quiet when there is nothing to do.`,
+}
+
+var hookOpenCodeStartCmd = &cobra.Command{
+	Use:   "opencode-start",
+	Short: "Pull remote changes when OpenCode starts, at most every few minutes",
+	Long: `Run from an OpenCode startup plugin or a shell wrapper around opencode.
+
+Does nothing if the remote was checked less than sync.startupPullMinutes
+ago (15 by default), or while syncing is paused with 'opencode-sync
+pause'. Otherwise fetches and, only if the remote has new commits, pulls
+them. Network failures are reported but do not fail the hook, so a slow
+or missing connection never holds up OpenCode.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runHookOpenCodeStart(cmd.Context(), hookTimeout)
+	},
+}
+
+var hookStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Print the last sync and pending changes as JSON",
+	Long: `Print what integrations need to show sync status, as one JSON object:
+
+  lastOperation   "push" or "pull", the last sync operation
+  lastSync        when it finished
+  lastFetch       when the remote was last checked
+  pendingChanges  local files not yet pushed (omitted if unknown)
+  pausedUntil     set while automatic syncing is paused; the zero time
+                  means until 'opencode-sync resume'
+
+Times are RFC 3339 and omitted if the event never happened.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runHookStatus()
+	},
+}
+
+func init() {
+	hookOpenCodeStartCmd.Flags().DurationVar(&hookTimeout, "timeout", 10*time.Second, "time limit for checking the remote")
+
+	hookCmd.AddCommand(hookOpenCodeStartCmd)
+	hookCmd.AddCommand(hookStatusCmd)
+}
+
+func runHookOpenCodeStart(ctx context.Context, timeout time.Duration) error {
+	// Nobody is at the terminal to answer a prompt
+	noPrompt = true
+	git.NoTerminalPrompt = true
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if cfg == nil {
+		return config.ErrNoConfig
+	}
+
+	// Snapshots are due whether or not a pull is
+	if cfg.Backup.Interval() > 0 {
+		if syncer, err := initSyncer(); err == nil {
+			autoSnapshot(syncer)
+		}
+	}
+
+	st, err := state.Load()
+	if err != nil {
+		return err
+	}
+	if time.Since(st.LastFetch) < cfg.Sync.StartupPullInterval() || st.Paused(time.Now()) != nil {
+		return nil
+	}
+
+	p, err := paths.Get()
+	if err != nil {
+		return fmt.Errorf("failed to get paths: %w", err)
+	}
+
+	repo := sync.NewRepo(p)
+	if err := repo.Open(); err != nil {
+		return fmt.Errorf("failed to open git repository: %w", err)
+	}
+	repo.SetTimeout(timeout)
+
+	if err := repo.Fetch(ctx); err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		ui.Warn(fmt.Sprintf("Skipped checking for remote changes: %v", err))
+		return nil
+	}
+	recordFetch()
+
+	branch, err := repo.GetBranch()
+	if err != nil {
+		return err
+	}
+	upToDate, err := repo.IsAncestor("origin/"+branch, "HEAD")
+	if err != nil || upToDate {
+		// A branch that was never pushed has nothing to pull
+		return nil
+	}
+
+	hasChanges, err := repo.HasChanges()
+	if err != nil {
+		return fmt.Errorf("failed to check for changes: %w", err)
+	}
+	if hasChanges {
+		ui.Warn("Remote changes are waiting, but the sync repo has uncommitted changes. Run 'opencode-sync sync'.")
+		return nil
+	}
+
+	syncer, err := initSyncer()
+	if err != nil {
+		return err
+	}
+
+	// OpenCode is starting up, so waiting for it to exit would never end
+	if syncer.Config().Sync.WhenRunning == config.WhenRunningWait {
+		syncer.Config().Sync.WhenRunning = config.WhenRunningWarn
+	}
+
+	headBefore, _ := repo.GetHead()
+
+	if err := syncer.SetSparseCheckout(); err != nil {
+		return err
+	}
+
+	// The short limit is for the check; a real pull gets the usual time
+	repo.SetTimeout(git.DefaultTimeout)
+
+	if err := ui.SpinnerWithResult("Pulling remote changes", func() error {
+		return repo.Pull(ctx)
+	}); err != nil {
+		var conflict *git.ConflictError
+		if errors.As(err, &conflict) {
+			return fmt.Errorf("%w. Please resolve manually", conflict)
+		}
+		ui.Warn(fmt.Sprintf("Skipped pulling remote changes: %v", err))
+		return nil
+	}
+
+	return applyPulled(ctx, syncer, repo, headBefore, "pull")
+}
+
+// recordFetch notes that the remote was just checked, for the startup
+// hook's rate limit
+func recordFetch() {
+	if err := state.RecordFetch(time.Now()); err != nil {
+		ui.Warn(fmt.Sprintf("Failed to record fetch time: %v", err))
+	}
+}
+
+// hookStatus is the output of 'hook status'
+type hookStatus struct {
+	LastOperation  string     `json:"lastOperation,omitempty"`
+	LastSync       *time.Time `json:"lastSync,omitempty"`
+	LastFetch      *time.Time `json:"lastFetch,omitempty"`
+	PendingChanges *int       `json:"pendingChanges,omitempty"`
+	PausedUntil    *time.Time `json:"pausedUntil,omitempty"`
+}
+
+func runHookStatus() error {
+	noPrompt = true
+
+	st, err := state.Load()
+	if err != nil {
+		return err
+	}
+
+	var status hookStatus
+	if op := st.LastOperation; op != nil {
+		status.LastOperation = op.Type
+		status.LastSync = &op.Time
+	}
+	if !st.LastFetch.IsZero() {
+		status.LastFetch = &st.LastFetch
+	}
+	if pause := st.Paused(time.Now()); pause != nil {
+		status.PausedUntil = &pause.Until
+	}
+
+	// Best effort: a locked key or missing setup just leaves it out
+	if syncer, err := initSyncer(); err == nil {
+		if pending, err := syncer.PendingChanges(); err == nil {
+			count := len(pending)
+			status.PendingChanges = &count
+		}
+	}
+
+	data, err := json.Marshal(status)
+	if err != nil {
+		return fmt.Errorf("failed to marshal status: %w", err)
+	}
+	fmt.Println(string(data))
+	return nil
+}
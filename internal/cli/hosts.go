@@ -0,0 +1,231 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/GareArc/opencode-sync/internal/paths"
+	"github.com/GareArc/opencode-sync/internal/sync"
+	"github.com/GareArc/opencode-sync/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// hostsCmd represents the hosts command
+var hostsCmd = &cobra.Command{
+	Use:   "hosts",
+	Short: "List and clean up machines that sync to this repo",
+	Long: `List machines known to the sync repo and remove decommissioned ones.
+
+Machines are discovered from commit metadata and from per-host directories
+under hosts/<name>/ in the repo. Machines that keep secrets with
+sync.hostSecrets publish their public host key there.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runHostsList()
+	},
+}
+
+var hostsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List known machines",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runHostsList()
+	},
+}
+
+var hostsRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Delete a machine's overlays and secrets from the repo",
+	Long: `Delete hosts/<name>/ from the sync repo, commit the cleanup and push it.
+
+Commit history is kept; run 'opencode-sync gc' afterwards to reclaim space
+locally.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runHostsRemove(cmd.Context(), args[0])
+	},
+}
+
+func init() {
+	hostsCmd.AddCommand(hostsListCmd)
+	hostsCmd.AddCommand(hostsRemoveCmd)
+}
+
+// hostInfo summarizes what the repo knows about one machine
+type hostInfo struct {
+	Name     string
+	Commits  int
+	LastSeen time.Time
+	HasDir   bool
+	HasKey   bool
+}
+
+func runHostsList() error {
+	syncer, err := initSyncer()
+	if err != nil {
+		return err
+	}
+
+	p, err := paths.Get()
+	if err != nil {
+		return fmt.Errorf("failed to get paths: %w", err)
+	}
+
+	repo := sync.NewRepo(p)
+	if err := repo.Open(); err != nil {
+		return fmt.Errorf("failed to open repository: %w", err)
+	}
+
+	commits, err := repo.Log()
+	if err != nil {
+		return err
+	}
+
+	dirs, err := syncer.HostDirs()
+	if err != nil {
+		return err
+	}
+
+	hosts := map[string]*hostInfo{}
+	get := func(name string) *hostInfo {
+		if hosts[name] == nil {
+			hosts[name] = &hostInfo{Name: name}
+		}
+		return hosts[name]
+	}
+
+	for _, c := range commits {
+		name := sync.CommitHost(c.Message)
+		if name == "" {
+			continue
+		}
+		h := get(name)
+		h.Commits++
+		if c.Timestamp.After(h.LastSeen) {
+			h.LastSeen = c.Timestamp
+		}
+	}
+	for _, name := range dirs {
+		get(name).HasDir = true
+	}
+
+	recipients, err := syncer.HostRecipients()
+	if err != nil {
+		return err
+	}
+	for name := range recipients {
+		get(name).HasKey = true
+	}
+
+	if len(hosts) == 0 {
+		ui.Info("No machines found in the sync repo")
+		return nil
+	}
+
+	list := make([]*hostInfo, 0, len(hosts))
+	for _, h := range hosts {
+		list = append(list, h)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].LastSeen.After(list[j].LastSeen) })
+
+	current := sync.Hostname()
+
+	fmt.Println("\nMachines:")
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	for _, h := range list {
+		lastSeen := "never"
+		if !h.LastSeen.IsZero() {
+			lastSeen = h.LastSeen.Format("2006-01-02 15:04")
+		}
+
+		var notes string
+		if h.HasDir {
+			notes += "  [hosts/" + h.Name + "]"
+		}
+		if h.HasKey {
+			notes += "  [host key]"
+		}
+		if h.Name == current {
+			notes += "  (this machine)"
+		}
+
+		fmt.Printf("%-30s last sync %-16s  %4d commits%s\n", h.Name, lastSeen, h.Commits, notes)
+	}
+
+	return nil
+}
+
+func runHostsRemove(ctx context.Context, name string) error {
+	if name == sync.Hostname() {
+		return fmt.Errorf("refusing to remove the current machine (%s)", name)
+	}
+
+	syncer, err := initSyncer()
+	if err != nil {
+		return err
+	}
+
+	p, err := paths.Get()
+	if err != nil {
+		return fmt.Errorf("failed to get paths: %w", err)
+	}
+
+	repo := sync.NewRepo(p)
+	if err := repo.Open(); err != nil {
+		return fmt.Errorf("failed to open repository: %w", err)
+	}
+
+	clean, err := repo.IsClean()
+	if err != nil {
+		return fmt.Errorf("failed to check for changes: %w", err)
+	}
+	if !clean {
+		return fmt.Errorf("sync repo has uncommitted changes. Run 'opencode-sync push' first")
+	}
+
+	if dryRun {
+		ui.Info(fmt.Sprintf("Would remove hosts/%s/ from the sync repo", name))
+		return nil
+	}
+
+	if !noPrompt {
+		confirmed, err := ui.Confirm(
+			fmt.Sprintf("Remove %s from the sync repo?", name),
+			fmt.Sprintf("hosts/%s/ will be deleted and the change pushed to the remote.", name),
+		)
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			ui.Info("Cancelled")
+			return nil
+		}
+	}
+
+	removed, err := syncer.RemoveHostDir(name)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		ui.Info(fmt.Sprintf("%s has no overlays or secrets in the repo", name))
+		return nil
+	}
+
+	if err := repo.AddAll(); err != nil {
+		return fmt.Errorf("failed to stage changes: %w", err)
+	}
+
+	if err := repo.Commit(sync.CommitMessage(fmt.Sprintf("Remove host %s", name))); err != nil {
+		return fmt.Errorf("failed to commit: %w", err)
+	}
+
+	if err := ui.SpinnerWithResult("Pushing to remote", func() error {
+		return sync.PushWithRetry(ctx, repo)
+	}); err != nil {
+		return fmt.Errorf("failed to push: %w", err)
+	}
+
+	ui.Success(fmt.Sprintf("Removed %s", name))
+	return nil
+}
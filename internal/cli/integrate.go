@@ -0,0 +1,188 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GareArc/opencode-sync/internal/paths"
+	"github.com/GareArc/opencode-sync/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// integrationMarker marks files written by 'integrate', which it may
+// overwrite or remove
+const integrationMarker = "Generated by opencode-sync integrate"
+
+// integrateForce is set by 'integrate opencode --force' and 'integrate
+// statusbar --force'
+var integrateForce bool
+
+// integrateRemove is set by 'integrate opencode --remove' and 'integrate
+// statusbar --remove'
+var integrateRemove bool
+
+// integrateCmd represents the integrate command
+var integrateCmd = &cobra.Command{
+	Use:   "integrate",
+	Short: "Install integrations with other tools",
+}
+
+var integrateOpenCodeCmd = &cobra.Command{
+	Use:   "opencode",
+	Short: "Add a /sync command and a sync status plugin to OpenCode",
+	Long: `Install into your OpenCode config:
+
+  command/sync.md            a /sync command that runs 'opencode-sync sync'
+                             and summarizes the result
+  plugin/opencode-sync.ts    a plugin that shows the last sync and pending
+                             changes in a toast when a session starts
+
+Both call opencode-sync from PATH. They live in the OpenCode config, so the
+next push installs them on your other machines too. Run again to update
+them, or with --remove to delete them.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runIntegrateOpenCode(integrateForce, integrateRemove)
+	},
+}
+
+func init() {
+	integrateOpenCodeCmd.Flags().BoolVar(&integrateForce, "force", false, "overwrite files that were not written by integrate")
+	integrateOpenCodeCmd.Flags().BoolVar(&integrateRemove, "remove", false, "remove the installed command and plugin")
+
+	integrateCmd.AddCommand(integrateOpenCodeCmd)
+}
+
+// openCodeSyncCommand is the /sync command. OpenCode replaces the !`...`
+// line with the command's output before sending the prompt.
+const openCodeSyncCommand = `---
+description: Sync OpenCode config with opencode-sync (pull, then push)
+---
+<!-- ` + integrationMarker + ` opencode. Changes are overwritten when it runs again. -->
+Output of ` + "`opencode-sync sync --no-prompt`" + `:
+
+!` + "`opencode-sync sync --no-prompt 2>&1`" + `
+
+Summarize the result in one or two sentences. If it failed, say what the output suggests doing next. Do not run any commands.
+`
+
+// openCodeSyncPlugin shows the output of 'hook status' in a toast once a
+// session starts
+const openCodeSyncPlugin = `// ` + integrationMarker + ` opencode. Changes are overwritten when it runs again.
+
+const ago = (time) => {
+  const minutes = Math.round((Date.now() - new Date(time).getTime()) / 60000)
+  if (minutes < 1) return "just now"
+  if (minutes < 60) return minutes + "m ago"
+  if (minutes < 48 * 60) return Math.round(minutes / 60) + "h ago"
+  return Math.round(minutes / 1440) + "d ago"
+}
+
+export const OpencodeSync = async ({ client, $ }) => {
+  let shown = false
+
+  const showStatus = async () => {
+    const out = await $` + "`opencode-sync hook status`" + `.quiet().nothrow()
+    if (out.exitCode !== 0) return
+
+    const status = JSON.parse(out.stdout.toString())
+    let message = status.lastSync
+      ? "Last " + status.lastOperation + " " + ago(status.lastSync)
+      : "Not synced yet"
+    if (status.pendingChanges > 0) {
+      message += ", " + status.pendingChanges + " local change(s) to push. Run /sync"
+    }
+
+    await client.tui
+      .showToast({ body: { title: "opencode-sync", message, variant: status.pendingChanges > 0 ? "warning" : "info" } })
+      .catch(() => {})
+  }
+
+  return {
+    event: async ({ event }) => {
+      if (event.type === "session.created" && !shown) {
+        shown = true
+        await showStatus()
+      }
+    },
+  }
+}
+`
+
+// openCodeIntegration is a file installed by 'integrate opencode'
+type openCodeIntegration struct {
+	// path is relative to the OpenCode config dir
+	path    string
+	content string
+}
+
+// openCodeIntegrations are the files 'integrate opencode' manages
+var openCodeIntegrations = []openCodeIntegration{
+	{path: filepath.Join("command", "sync.md"), content: openCodeSyncCommand},
+	{path: filepath.Join("plugin", "opencode-sync.ts"), content: openCodeSyncPlugin},
+}
+
+func runIntegrateOpenCode(force, remove bool) error {
+	p, err := paths.Get()
+	if err != nil {
+		return fmt.Errorf("failed to get paths: %w", err)
+	}
+
+	for _, file := range openCodeIntegrations {
+		path := filepath.Join(p.OpenCodeConfigDir, file.path)
+		if err := installIntegration(path, file.content, 0644, force, remove); err != nil {
+			return err
+		}
+	}
+
+	if !remove {
+		ui.Info("Restart OpenCode to load them. Push to install them on your other machines.")
+	}
+	return nil
+}
+
+// installIntegration writes content to path, or removes it if remove is
+// set. Files not written by integrate are left alone unless force is set.
+func installIntegration(path, content string, mode os.FileMode, force, remove bool) error {
+	existing, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		existing = nil
+	case err != nil:
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	case !strings.Contains(string(existing), integrationMarker) && !force:
+		return fmt.Errorf("%s exists and was not written by opencode-sync; move it away or use --force", path)
+	}
+
+	if remove {
+		if existing == nil {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+		ui.Success(fmt.Sprintf("Removed %s", path))
+		return nil
+	}
+
+	if string(existing) == content {
+		ui.Info(fmt.Sprintf("%s is up to date", path))
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(content), mode); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	// WriteFile keeps the mode of an existing file
+	if err := os.Chmod(path, mode); err != nil {
+		return fmt.Errorf("failed to set mode of %s: %w", path, err)
+	}
+	ui.Success(fmt.Sprintf("Installed %s", path))
+	return nil
+}
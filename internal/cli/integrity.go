@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/GareArc/opencode-sync/internal/integrity"
+	"github.com/GareArc/opencode-sync/internal/paths"
+	"github.com/GareArc/opencode-sync/internal/sync"
+	"github.com/GareArc/opencode-sync/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var auditVerifyFormat string
+
+var auditVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check the local integrity log against the sync repo",
+	Long: `Check this machine's integrity log, where every push, pull, merge and
+undo appends the commits it moved the sync repo between and a SHA-256 of
+each file, chained to the entry before by its hash.
+
+It reports entries that were changed, removed or reordered, logged
+commits that are gone from the sync repo or whose files changed, and
+history that was rewritten between operations, such as a force push from
+another machine. The log is local, so it shows what this machine saw,
+and whoever can write it can also rewrite it from scratch; keep a copy
+elsewhere for stronger evidence. It exits 1 on any problem.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runAuditVerify(auditVerifyFormat)
+	},
+}
+
+func init() {
+	auditVerifyCmd.Flags().StringVar(&auditVerifyFormat, "format", "text", "output format: text or json")
+	auditCmd.AddCommand(auditVerifyCmd)
+}
+
+// logOperation appends a sync operation to the integrity log, warning
+// instead of failing, as the operation itself succeeded
+func logOperation(op, headBefore, headAfter string, replaced bool) {
+	if headAfter == "" {
+		return
+	}
+
+	repo, err := openSyncRepo()
+	if err == nil {
+		var files map[string]string
+		if files, err = integrity.FileHashes(repo, headAfter); err == nil {
+			err = integrity.Append(&integrity.Entry{
+				Time:       time.Now(),
+				Op:         op,
+				Host:       sync.Hostname(),
+				HeadBefore: headBefore,
+				HeadAfter:  headAfter,
+				Replaced:   replaced,
+				Files:      files,
+			})
+		}
+	}
+	if err != nil {
+		ui.Warn(fmt.Sprintf("Failed to update the integrity log: %v", err))
+	}
+}
+
+func runAuditVerify(format string) error {
+	if format != "text" && format != "json" {
+		return fmt.Errorf("invalid format %q: use text or json", format)
+	}
+
+	entries, err := integrity.Load()
+	if err != nil {
+		return err
+	}
+	repo, err := openSyncRepo()
+	if err != nil {
+		return err
+	}
+	problems := integrity.Verify(repo, entries)
+
+	if format == "json" {
+		if problems == nil {
+			problems = []integrity.Problem{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(struct {
+			Entries  int                 `json:"entries"`
+			Problems []integrity.Problem `json:"problems"`
+		}{len(entries), problems}); err != nil {
+			return err
+		}
+	} else {
+		if len(entries) == 0 {
+			p, _ := paths.Get()
+			ui.Info(fmt.Sprintf("The integrity log is empty; push and pull add to it (%s)", p.IntegrityLogFile()))
+			return nil
+		}
+		for _, problem := range problems {
+			ui.Error(fmt.Sprintf("entry %d: %s: %s", problem.Seq, problem.Kind, problem.Reason))
+		}
+		if len(problems) == 0 {
+			first, last := entries[0], entries[len(entries)-1]
+			ui.Success(fmt.Sprintf("%d operation(s) from %s to %s verified", len(entries),
+				first.Time.Local().Format("2006-01-02 15:04"), last.Time.Local().Format("2006-01-02 15:04")))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("found %d problem(s) in the integrity log", len(problems))
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/GareArc/opencode-sync/internal/crypto"
+	"github.com/GareArc/opencode-sync/internal/paths"
+	"github.com/spf13/cobra"
+)
+
+// keyCryptHost is set by 'key encrypt --host-key' and 'key decrypt --host-key'
+var keyCryptHost bool
+
+var keyEncryptCmd = &cobra.Command{
+	Use:   "encrypt <file|-> [output|-]",
+	Short: "Encrypt a file or stdin with the configured key",
+	Long: `Encrypt a file, or stdin for -, with the configured key, writing the age
+ciphertext to output or stdout. The result is what push stores as a .age
+file, so it can be put into the sync repo by hand.
+
+With --host-key, this machine's own key (host.key, used for
+sync.hostSecrets) is used instead of the shared one.
+
+Example:
+  opencode-sync key encrypt auth.json auth.json.age`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runKeyCrypt(true, args[0], optionalArg(args, 1, "-"))
+	},
+}
+
+var keyDecryptCmd = &cobra.Command{
+	Use:   "decrypt <file|-> [output|-]",
+	Short: "Decrypt a file or stdin with the configured key",
+	Long: `Decrypt an age file, or stdin for -, with the configured key, writing the
+plaintext to output or stdout. Use it to read or recover encrypted files of
+the sync repo by hand, without the age CLI.
+
+With --host-key, this machine's own key (host.key, used for
+sync.hostSecrets) is used instead of the shared one.
+
+Example:
+  opencode-sync key decrypt ~/.local/share/opencode-sync/repo/secrets/auth.json.age -`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runKeyCrypt(false, args[0], optionalArg(args, 1, "-"))
+	},
+}
+
+func init() {
+	keyEncryptCmd.Flags().BoolVar(&keyCryptHost, "host-key", false, "use this machine's own key instead of the shared one")
+	keyDecryptCmd.Flags().BoolVar(&keyCryptHost, "host-key", false, "use this machine's own key instead of the shared one")
+
+	keyCmd.AddCommand(keyEncryptCmd)
+	keyCmd.AddCommand(keyDecryptCmd)
+}
+
+// optionalArg returns args[i], or def if there are not that many
+func optionalArg(args []string, i int, def string) string {
+	if len(args) > i {
+		return args[i]
+	}
+	return def
+}
+
+// runKeyCrypt encrypts or decrypts input to output, either of which may be
+// - for stdin and stdout
+func runKeyCrypt(encrypt bool, input, output string) error {
+	p, err := paths.Get()
+	if err != nil {
+		return fmt.Errorf("failed to get paths: %w", err)
+	}
+
+	keyFile := p.KeyFile()
+	if keyCryptHost {
+		// Only this machine ever has it, so there is nowhere to import it from
+		keyFile = p.HostKeyFile()
+		if _, err := os.Stat(keyFile); os.IsNotExist(err) {
+			return fmt.Errorf("this machine has no host key; one is made once sync.hostSecrets is set")
+		}
+	} else if _, err := os.Stat(keyFile); os.IsNotExist(err) {
+		return crypto.ErrKeyMissing
+	}
+	enc, err := crypto.LoadEncryption(keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load encryption key: %w", err)
+	}
+
+	if encrypt && output == "-" && isTerminal(os.Stdout) {
+		return fmt.Errorf("refusing to write binary ciphertext to a terminal; give an output file")
+	}
+
+	in := io.Reader(os.Stdin)
+	if input != "-" {
+		f, err := os.Open(input)
+		if err != nil {
+			return fmt.Errorf("failed to open input: %w", err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	crypt := enc.DecryptReader
+	if encrypt {
+		crypt = enc.EncryptReader
+	}
+
+	if output == "-" {
+		return crypt(in, os.Stdout)
+	}
+
+	// Written under a temporary name, so a failure never leaves half a
+	// file behind. Plaintext is a secret, so it is only readable by you.
+	out, err := os.CreateTemp(filepath.Dir(output), ".opencode-sync-*")
+	if err != nil {
+		return fmt.Errorf("failed to create output: %w", err)
+	}
+	defer os.Remove(out.Name())
+
+	err = crypt(in, out)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(out.Name(), output); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+	return nil
+}
+
+// isTerminal reports whether f is a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
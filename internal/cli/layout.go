@@ -0,0 +1,274 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GareArc/opencode-sync/internal/config"
+	"github.com/GareArc/opencode-sync/internal/git"
+	"github.com/GareArc/opencode-sync/internal/paths"
+	"github.com/GareArc/opencode-sync/internal/sync"
+	"github.com/GareArc/opencode-sync/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// layoutCmd represents the layout command
+var layoutCmd = &cobra.Command{
+	Use:   "layout [copy|direct]",
+	Short: "Show or convert the sync repo layout",
+	Long: `Show where the sync repo lives, or convert an existing setup in place.
+
+  copy     a working copy in the data directory; files are copied between
+           it and your OpenCode config (default)
+  direct   your OpenCode config directory is the working tree and the git
+           data lives in the data directory; push and pull work in place
+
+Converting needs the sync repo and your OpenCode config to match, so push
+or pull first. History and the remote are kept.`,
+	Args:      cobra.MaximumNArgs(1),
+	ValidArgs: []string{config.LayoutCopy, config.LayoutDirect},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return runLayoutShow()
+		}
+		return runLayoutConvert(args[0])
+	},
+}
+
+func runLayoutShow() error {
+	cfg, err := config.Load()
+	if err != nil || cfg == nil {
+		return config.ErrNoConfig
+	}
+
+	p, err := paths.Get()
+	if err != nil {
+		return fmt.Errorf("failed to get paths: %w", err)
+	}
+
+	layout := cfg.Repo.Layout
+	if layout == "" {
+		layout = config.LayoutCopy
+	}
+
+	fmt.Printf("Layout:       %s\n", layout)
+	fmt.Printf("Working tree: %s\n", p.SyncRepoDir())
+	fmt.Printf("Git data:     %s\n", p.SyncGitDir())
+	return nil
+}
+
+func runLayoutConvert(layout string) error {
+	cfg, err := config.Load()
+	if err != nil || cfg == nil {
+		return config.ErrNoConfig
+	}
+
+	switch layout {
+	case config.LayoutCopy, config.LayoutDirect:
+	default:
+		return fmt.Errorf("layout must be one of: copy, direct")
+	}
+
+	if layout == cfg.Repo.Layout || (layout == config.LayoutCopy && cfg.Repo.Layout == "") {
+		ui.Info(fmt.Sprintf("The sync repo already uses the %s layout", layout))
+		return nil
+	}
+
+	p, err := paths.Get()
+	if err != nil {
+		return fmt.Errorf("failed to get paths: %w", err)
+	}
+	if !syncRepoExists(p) {
+		return fmt.Errorf("no repository found. Set repo.layout and run 'opencode-sync init' or 'opencode-sync clone' instead")
+	}
+
+	if layout == config.LayoutDirect {
+		return convertToDirect(cfg, p)
+	}
+	return convertToCopy(cfg, p)
+}
+
+// convertToDirect moves the git data of the copy layout's working copy to
+// the data dir and makes the OpenCode config dir its working tree
+func convertToDirect(cfg *config.Config, p *paths.Paths) error {
+	converted := *cfg
+	converted.Repo.Layout = config.LayoutDirect
+	if err := converted.Validate(); err != nil {
+		return err
+	}
+
+	syncer, err := initSyncer()
+	if err != nil {
+		return err
+	}
+	if err := requireInSync(syncer); err != nil {
+		return err
+	}
+
+	oldRepoDir := p.SyncRepoRoot()
+	oldGitDir := p.SyncGitDir()
+	paths.DirectLayout = true
+	worktree, gitDir := p.SyncRepoDir(), p.SyncGitDir()
+
+	if _, err := os.Stat(filepath.Join(worktree, ".git")); err == nil {
+		return fmt.Errorf("%s already has a .git; remove it first", worktree)
+	}
+
+	if err := os.Rename(oldGitDir, gitDir); err != nil {
+		return fmt.Errorf("failed to move %s: %w", oldGitDir, err)
+	}
+	if err := os.WriteFile(filepath.Join(worktree, ".git"), []byte("gitdir: "+gitDir+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to link %s to %s: %w", worktree, gitDir, err)
+	}
+
+	repo := git.NewBuiltinGit(worktree)
+	if err := repo.Open(); err != nil {
+		return fmt.Errorf("failed to open git repository: %w", err)
+	}
+	syncer = sync.New(&converted, p, repo)
+
+	// Paths stored for other destinations stay out of the OpenCode config
+	if err := syncer.SetSparseCheckout(); err != nil {
+		return err
+	}
+	// Brings the index up to date with the new working tree
+	if err := runGitCommand(worktree, "reset", "--quiet"); err != nil {
+		return fmt.Errorf("failed to refresh the index: %w", err)
+	}
+	// The repo's .gitignore and .gitattributes belong in the working tree
+	if err := repo.ResetHard("HEAD"); err != nil {
+		return err
+	}
+
+	if err := config.Save(&converted); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	// Also writes the ignore rules
+	pending, err := syncer.PendingChanges()
+	if err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(oldRepoDir); err != nil {
+		ui.Warn(fmt.Sprintf("Failed to remove the old working copy %s: %v", oldRepoDir, err))
+	}
+
+	ui.Success(fmt.Sprintf("Converted to the direct layout: %s is the working tree", worktree))
+	if len(pending) > 0 {
+		ui.Warn(fmt.Sprintf("%d file(s) differ from the last commit and will be pushed: %s", len(pending), strings.Join(pending, ", ")))
+	}
+	return nil
+}
+
+// convertToCopy moves the git data of the direct layout back into a
+// working copy in the data dir and checks out every file there
+func convertToCopy(cfg *config.Config, p *paths.Paths) error {
+	syncer, err := initSyncer()
+	if err != nil {
+		return err
+	}
+	if err := requireInSync(syncer); err != nil {
+		return err
+	}
+
+	worktree, gitDir := p.SyncRepoDir(), p.SyncGitDir()
+	paths.DirectLayout = false
+	repoDir, newGitDir := p.SyncRepoDir(), p.SyncGitDir()
+
+	if _, err := os.Stat(repoDir); err == nil {
+		if entries, _ := os.ReadDir(repoDir); len(entries) > 0 {
+			return fmt.Errorf("%s is not empty; remove it first", repoDir)
+		}
+	}
+	if err := os.MkdirAll(repoDir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", repoDir, err)
+	}
+
+	if err := os.Rename(gitDir, newGitDir); err != nil {
+		return fmt.Errorf("failed to move %s: %w", gitDir, err)
+	}
+	if err := os.Remove(filepath.Join(worktree, ".git")); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove %s: %w", filepath.Join(worktree, ".git"), err)
+	}
+
+	// Forget the old working tree and its sparse checkout, then check out
+	// every file from scratch
+	_ = runGitCommand(repoDir, "config", "--unset", "core.worktree")
+	_ = runGitCommand(repoDir, "config", "core.sparseCheckout", "false")
+	_ = os.Remove(filepath.Join(newGitDir, "info", "sparse-checkout"))
+	_ = os.Remove(filepath.Join(newGitDir, "index"))
+
+	repo := git.NewBuiltinGit(repoDir)
+	if err := repo.Open(); err != nil {
+		return fmt.Errorf("failed to open git repository: %w", err)
+	}
+	if err := repo.ResetHard("HEAD"); err != nil {
+		return err
+	}
+
+	converted := *cfg
+	converted.Repo.Layout = ""
+	if err := sync.New(&converted, p, repo).RemoveDirectExcludes(); err != nil {
+		return err
+	}
+	if err := config.Save(&converted); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	ui.Success(fmt.Sprintf("Converted to the copy layout: the working copy is %s", repoDir))
+	ui.Info(fmt.Sprintf("Your files in %s are unchanged; .gitignore and .gitattributes there are no longer used", worktree))
+	return nil
+}
+
+// requireInSync refuses to convert while local files and the sync repo
+// differ, so nothing is lost when the working tree moves
+func requireInSync(syncer *sync.Syncer) error {
+	pending, err := syncer.PendingChanges()
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%d local file(s) differ from the sync repo. Run 'opencode-sync sync' first", len(pending))
+	}
+
+	state, err := syncer.GetState()
+	if err != nil {
+		return fmt.Errorf("failed to get state: %w", err)
+	}
+	if state.HasLocalChanges {
+		return fmt.Errorf("the sync repo has uncommitted changes. Run 'opencode-sync sync' first")
+	}
+	return nil
+}
+
+// syncRepoExists reports whether a sync repo was created in either layout
+func syncRepoExists(p *paths.Paths) bool {
+	for _, dir := range []string{filepath.Join(p.DataDir, "repo", ".git"), filepath.Join(p.DataDir, "repo.git")} {
+		if _, err := os.Stat(dir); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// removeSyncRepo deletes the sync repo. In the direct layout only the git
+// data goes; the working tree is the OpenCode config.
+func removeSyncRepo(p *paths.Paths) error {
+	if !paths.DirectLayout {
+		if err := os.RemoveAll(p.SyncRepoRoot()); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", p.SyncRepoRoot(), err)
+		}
+		return nil
+	}
+
+	if err := os.RemoveAll(p.SyncGitDir()); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", p.SyncGitDir(), err)
+	}
+	if err := os.Remove(filepath.Join(p.SyncRepoDir(), ".git")); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove %s: %w", filepath.Join(p.SyncRepoDir(), ".git"), err)
+	}
+	return nil
+}
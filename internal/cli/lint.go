@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/GareArc/opencode-sync/internal/sync"
+	"github.com/GareArc/opencode-sync/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// lintCmd represents the lint command
+var lintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Check agents, commands and skills before they are pushed",
+	Long: `Check the files a push would publish for mistakes that would break them on
+every machine:
+
+  - the frontmatter of agents, modes, commands and skills: agents need a
+    description and a valid mode, temperature and tools, commands a
+    template and an agent that exists, skills a name matching their
+    directory and a description
+  - model IDs in the frontmatter and in opencode.json, checked against the
+    models OpenCode caches from models.dev and the providers configured in
+    opencode.json
+  - relative links in markdown files whose targets don't exist
+
+push runs the same checks and refuses to push while there are problems,
+unless given --no-lint. Exits 1 if there are any.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runLint()
+	},
+}
+
+func runLint() error {
+	syncer, err := initSyncer()
+	if err != nil {
+		return err
+	}
+
+	problems, err := syncer.Lint()
+	if err != nil {
+		return err
+	}
+
+	if len(problems) == 0 {
+		ui.Success("No problems found")
+		return nil
+	}
+
+	printLintProblems(problems)
+	return fmt.Errorf("%d lint problem(s) found", len(problems))
+}
+
+// lintBeforePush refuses a push that would publish files with lint
+// problems
+func lintBeforePush(syncer *sync.Syncer) error {
+	problems, err := syncer.Lint()
+	if err != nil {
+		return fmt.Errorf("failed to lint: %w", err)
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+
+	printLintProblems(problems)
+	return &sync.LintError{Problems: problems}
+}
+
+// printLintProblems lists lint problems, one per line
+func printLintProblems(problems []sync.LintProblem) {
+	fmt.Printf("%d problem(s):\n", len(problems))
+	for _, problem := range problems {
+		fmt.Printf("  %s\n", problem)
+	}
+}
@@ -0,0 +1,156 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/GareArc/opencode-sync/internal/state"
+	"github.com/GareArc/opencode-sync/internal/sync"
+	"github.com/GareArc/opencode-sync/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// lockCmd represents the lock command
+var lockCmd = &cobra.Command{
+	Use:   "lock [path]",
+	Short: "Keep pulls from overwriting a local file",
+	Long: `Protect a local file from pulls, for settings tuned to this machine.
+Pulls keep your version and save the incoming one next to it as
+<file>.remote, so you can merge what you need by hand. Push still sends
+your version.
+
+The path is a local file, or its path in the sync repo (e.g.
+opencode.json). Without a path, lists the locked files. Locks only apply
+to this machine.
+
+Examples:
+  opencode-sync lock ~/.config/opencode/opencode.json
+  opencode-sync lock agent/review.md
+  opencode-sync unlock agent/review.md`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return runLockList()
+		}
+		return runLock(args[0])
+	},
+}
+
+// unlockCmd represents the unlock command
+var unlockCmd = &cobra.Command{
+	Use:   "unlock <path>",
+	Short: "Let pulls overwrite a locked file again",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runUnlock(args[0])
+	},
+}
+
+func runLock(path string) error {
+	syncer, err := initSyncer()
+	if err != nil {
+		return err
+	}
+	if syncer.Direct() {
+		// The working tree is the live config, so pulls update it in place
+		return fmt.Errorf("locking files is not supported with repo.layout direct")
+	}
+
+	relPath, err := syncer.RepoPath(lockPath(path))
+	if err != nil {
+		return err
+	}
+
+	st, err := state.Load()
+	if err != nil {
+		return err
+	}
+	if slices.Contains(st.Locked, relPath) {
+		ui.Info(fmt.Sprintf("%s is already locked", relPath))
+		return nil
+	}
+
+	locked := append(st.Locked, relPath)
+	slices.Sort(locked)
+	if err := state.RecordLocks(locked); err != nil {
+		return fmt.Errorf("failed to lock %s: %w", relPath, err)
+	}
+
+	ui.Success(fmt.Sprintf("Locked %s", relPath))
+	ui.Info(fmt.Sprintf("Pulls will save incoming changes as %s", syncer.LocalPath(relPath)+sync.IncomingSuffix))
+	return nil
+}
+
+func runUnlock(path string) error {
+	syncer, err := initSyncer()
+	if err != nil {
+		return err
+	}
+
+	st, err := state.Load()
+	if err != nil {
+		return err
+	}
+
+	// The file may no longer be synced, so look for the path as given too
+	relPath, err := syncer.RepoPath(lockPath(path))
+	if err != nil || !slices.Contains(st.Locked, relPath) {
+		relPath = filepath.Clean(path)
+	}
+
+	i := slices.Index(st.Locked, relPath)
+	if i < 0 {
+		ui.Info(fmt.Sprintf("%s is not locked", path))
+		return nil
+	}
+
+	if err := state.RecordLocks(slices.Delete(st.Locked, i, i+1)); err != nil {
+		return fmt.Errorf("failed to unlock %s: %w", relPath, err)
+	}
+
+	// The next pull applies the incoming version itself
+	if local := syncer.LocalPath(relPath); local != "" {
+		if err := os.Remove(local + sync.IncomingSuffix); err != nil && !os.IsNotExist(err) {
+			ui.Warn(fmt.Sprintf("Failed to remove %s: %v", local+sync.IncomingSuffix, err))
+		}
+	}
+
+	ui.Success(fmt.Sprintf("Unlocked %s; the next pull will overwrite it", relPath))
+	return nil
+}
+
+func runLockList() error {
+	st, err := state.Load()
+	if err != nil {
+		return err
+	}
+
+	if len(st.Locked) == 0 {
+		ui.Info("No files are locked")
+		return nil
+	}
+
+	fmt.Printf("%d locked file(s):\n", len(st.Locked))
+	for _, relPath := range st.Locked {
+		fmt.Printf("  %s\n", relPath)
+	}
+	return nil
+}
+
+// lockPath makes a path naming an existing local file absolute, leaving
+// repo paths as they are
+func lockPath(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	if _, err := os.Stat(path); err != nil {
+		return path
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	return abs
+}
@@ -0,0 +1,134 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/GareArc/opencode-sync/internal/sync"
+	"github.com/GareArc/opencode-sync/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// manifestCmd represents the manifest command
+var manifestCmd = &cobra.Command{
+	Use:   "manifest",
+	Short: "Show what the sync repo requires of opencode-sync",
+	Long: `Show the sync repo's manifest, sync-manifest.json at its root. Push writes
+it, listing the features the repo's files need, such as age encryption or
+host secrets. Pull, push and everything that applies the repo refuse a repo
+whose manifest asks for a newer version or features this build lacks, and
+an undone pull leaves the sync repo as it was.
+
+With encryption, the manifest is signed with the shared key, and a manifest
+not signed with it is refused too.
+
+In the direct layout the manifest is only checked, never written.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runManifestShow()
+	},
+}
+
+var manifestRequireCmd = &cobra.Command{
+	Use:   "require <version|none>",
+	Short: "Make the sync repo require this version of opencode-sync or newer",
+	Long: `Set the oldest opencode-sync allowed to use the sync repo, e.g. once every
+machine was upgraded and an older version must not write to it any more.
+'none' allows any version again. The next push publishes it.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runManifestRequire(args[0])
+	},
+}
+
+var manifestSignCmd = &cobra.Command{
+	Use:   "sign",
+	Short: "Sign the manifest again with your key",
+	Long: `Sign the sync repo's manifest again with the shared key, accepting what it
+says. Use it after checking a manifest that was refused as not signed with
+your key, e.g. with 'opencode-sync audit --path sync-manifest.json'. The
+next push publishes it.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runManifestSign()
+	},
+}
+
+func init() {
+	manifestCmd.AddCommand(manifestRequireCmd)
+	manifestCmd.AddCommand(manifestSignCmd)
+}
+
+func runManifestShow() error {
+	syncer, err := initSyncer()
+	if err != nil {
+		return err
+	}
+
+	manifest, err := syncer.Manifest("HEAD")
+	if err != nil {
+		return err
+	}
+	if manifest == nil {
+		ui.Info("The sync repo has no manifest yet. The next push writes one.")
+		return nil
+	}
+
+	minVersion := manifest.MinVersion
+	if minVersion == "" {
+		minVersion = "any"
+	}
+	features := strings.Join(manifest.Features, ", ")
+	if features == "" {
+		features = "none"
+	}
+	fmt.Printf("Minimum version: %s\n", minVersion)
+	fmt.Printf("Layout:          %d\n", max(manifest.Layout, 1))
+	fmt.Printf("Features:        %s\n", features)
+	fmt.Printf("Written by:      %s\n", manifest.WrittenBy)
+	fmt.Printf("Signature:       %s\n", syncer.ManifestSigned(manifest))
+	fmt.Println()
+
+	if err := syncer.CheckManifest("HEAD"); err != nil {
+		ui.Warn(err.Error())
+		return nil
+	}
+	ui.Success(fmt.Sprintf("This build (%s) can use the sync repo", sync.Version))
+	return nil
+}
+
+func runManifestRequire(version string) error {
+	syncer, err := initSyncer()
+	if err != nil {
+		return err
+	}
+
+	if version == "none" {
+		version = ""
+	}
+	if err := syncer.RequireVersion(version); err != nil {
+		return err
+	}
+
+	if version == "" {
+		ui.Success("The sync repo allows any version of opencode-sync")
+	} else {
+		ui.Success(fmt.Sprintf("The sync repo requires opencode-sync %s or newer", strings.TrimPrefix(version, "v")))
+	}
+	ui.Info("Run 'opencode-sync push' to publish it.")
+	return nil
+}
+
+func runManifestSign() error {
+	syncer, err := initSyncer()
+	if err != nil {
+		return err
+	}
+
+	if err := syncer.SignManifest(); err != nil {
+		return err
+	}
+	ui.Success("Signed the manifest")
+	ui.Info("Run 'opencode-sync push' to publish it.")
+	return nil
+}
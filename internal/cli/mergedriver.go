@@ -0,0 +1,109 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/GareArc/opencode-sync/internal/config"
+	"github.com/GareArc/opencode-sync/internal/git"
+	"github.com/GareArc/opencode-sync/internal/sync"
+	"github.com/spf13/cobra"
+)
+
+// mergeDriverUnion is set by 'merge-driver --union'
+var mergeDriverUnion bool
+
+// unionMarkerSize is the length of the conflict markers the union merge
+// reads, long enough that no markdown line is mistaken for one
+const unionMarkerSize = 31
+
+// mergeDriverCmd is invoked by git to merge OpenCode config files
+var mergeDriverCmd = &cobra.Command{
+	Use:    "merge-driver <base> <current> <other> [path]",
+	Short:  "Git merge driver for OpenCode JSON config files and AGENTS.md",
+	Hidden: true,
+	Args:   cobra.RangeArgs(3, 4),
+	// git reports the conflict itself
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if mergeDriverUnion {
+			return runUnionMergeDriver(args[0], args[1], args[2])
+		}
+		return runMergeDriver(args[0], args[1], args[2])
+	},
+}
+
+func init() {
+	mergeDriverCmd.Flags().BoolVar(&mergeDriverUnion, "union", false, "keep additions from both sides of a markdown file")
+}
+
+// runMergeDriver merges other into current using base as the common
+// ancestor. If the files are not plain JSON or a value was changed on both
+// sides, it falls back to a line-based merge with conflict markers.
+func runMergeDriver(basePath, currentPath, otherPath string) error {
+	var contents [3][]byte
+	for i, path := range []string{basePath, currentPath, otherPath} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		contents[i] = data
+	}
+
+	keys := config.DefaultMergeKeys
+	if cfg, err := config.Load(); err == nil && cfg != nil {
+		keys = cfg.Sync.ArrayMergeKeys()
+	}
+
+	merged, ok, err := sync.MergeJSON(contents[0], contents[1], contents[2], keys)
+	if err != nil || !ok {
+		return git.MergeFile(currentPath, basePath, otherPath)
+	}
+
+	if err := os.WriteFile(currentPath, merged, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", currentPath, err)
+	}
+
+	return nil
+}
+
+// runUnionMergeDriver merges other into current using base as the common
+// ancestor, like a line-based merge except that lines both sides added at
+// the same place are all kept. Conflict markers are left only where both
+// sides changed the same lines.
+func runUnionMergeDriver(basePath, currentPath, otherPath string) error {
+	merged, conflicts, err := git.MergeFileDiff3(currentPath, basePath, otherPath, unionMarkerSize)
+	if err != nil {
+		return err
+	}
+
+	clean := true
+	if conflicts {
+		merged, clean = sync.MergeAdditions(merged, unionMarkerSize)
+	}
+
+	if err := os.WriteFile(currentPath, merged, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", currentPath, err)
+	}
+	if !clean {
+		return fmt.Errorf("conflicting changes in %s", currentPath)
+	}
+	return nil
+}
+
+// installMergeDriver registers this executable as the sync repo's JSON
+// and union merge drivers. Failures only mean git falls back to a
+// regular merge.
+func installMergeDriver(syncer *sync.Syncer) {
+	exe, err := os.Executable()
+	if err != nil {
+		return
+	}
+
+	command := fmt.Sprintf("'%s' merge-driver", strings.ReplaceAll(exe, "'", `'\''`))
+	if err := syncer.InstallMergeDriver(command); err != nil && verbose {
+		fmt.Fprintf(os.Stderr, "failed to register merge driver: %v\n", err)
+	}
+}
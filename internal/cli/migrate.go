@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/GareArc/opencode-sync/internal/sync"
+	"github.com/GareArc/opencode-sync/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// migrateCmd represents the migrate command
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Bring the sync repo to the layout of this version",
+	Long: `Move the files of a sync repo written by an older opencode-sync to where
+this version keeps them, such as the encrypted auth files into secrets/,
+and record the new layout in the manifest. Push does this on its own; use
+--dry-run to see what it would move first.
+
+Once the migration is pushed, versions that predate the layout refuse the
+repo, so upgrade every machine before pushing.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runMigrate()
+	},
+}
+
+func runMigrate() error {
+	syncer, err := initSyncer()
+	if err != nil {
+		return err
+	}
+
+	layout, err := syncer.Layout()
+	if err != nil {
+		return err
+	}
+	pending, err := syncer.PendingMigrations()
+	if err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		ui.Success(fmt.Sprintf("The sync repo already uses layout %d", layout))
+		return nil
+	}
+
+	fmt.Printf("The sync repo uses layout %d; this version uses layout %d.\n\n", layout, sync.RepoLayout)
+	for _, migration := range pending {
+		fmt.Printf("Layout %d: %s\n", migration.Layout, migration.Description)
+		for _, move := range migration.Moves {
+			fmt.Printf("  %s → %s\n", move.From, move.To)
+		}
+		if len(migration.Moves) == 0 {
+			fmt.Println("  (no files to move)")
+		}
+	}
+	fmt.Println()
+
+	if dryRun {
+		ui.Info("Dry run: nothing was changed. The next push migrates the repo.")
+		return nil
+	}
+
+	if _, err := syncer.MigrateLayout(); err != nil {
+		return err
+	}
+	ui.Success(fmt.Sprintf("Migrated the sync repo to layout %d", sync.RepoLayout))
+	ui.Info("Run 'opencode-sync push' to publish it.")
+	return nil
+}
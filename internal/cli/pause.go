@@ -0,0 +1,141 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/GareArc/opencode-sync/internal/state"
+	"github.com/GareArc/opencode-sync/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// pauseFor is set by 'pause --for'
+var pauseFor time.Duration
+
+// pauseCmd represents the pause command
+var pauseCmd = &cobra.Command{
+	Use:   "pause",
+	Short: "Stop automatic syncing until 'resume', or for a while",
+	Long: `Pause automatic syncing: watch keeps running but skips its syncs, and
+the OpenCode startup hook stops pulling. Use it while experimenting with
+config changes you may not want on your other machines.
+
+Commands you run yourself, such as push, pull and sync, still work.
+
+Examples:
+  opencode-sync pause            # until 'opencode-sync resume'
+  opencode-sync pause --for 2h   # resumes by itself after two hours`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runPause(pauseFor)
+	},
+}
+
+// resumeCmd represents the resume command
+var resumeCmd = &cobra.Command{
+	Use:   "resume",
+	Short: "Resume automatic syncing after 'pause'",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runResume()
+	},
+}
+
+func init() {
+	pauseCmd.Flags().DurationVar(&pauseFor, "for", 0, "resume automatically after this long (e.g. 30m, 2h)")
+}
+
+func runPause(duration time.Duration) error {
+	if duration < 0 {
+		return fmt.Errorf("--for must be positive")
+	}
+
+	pause, err := pauseSyncing(duration)
+	if err != nil {
+		return err
+	}
+
+	ui.Success(pauseDescription(pause))
+	ui.Info("Run 'opencode-sync resume' to sync again.")
+	return nil
+}
+
+func runResume() error {
+	paused, err := resumeSyncing()
+	if err != nil {
+		return err
+	}
+
+	if !paused {
+		ui.Info("Automatic syncing is not paused")
+		return nil
+	}
+
+	ui.Success("Automatic syncing resumed")
+	return nil
+}
+
+// pauseSyncing pauses automatic syncing for duration, or until resumed if
+// it is 0
+func pauseSyncing(duration time.Duration) (*state.Pause, error) {
+	now := time.Now()
+	pause := &state.Pause{Since: now}
+	if duration > 0 {
+		pause.Until = now.Add(duration)
+	}
+
+	if err := state.RecordPause(pause); err != nil {
+		return nil, fmt.Errorf("failed to pause: %w", err)
+	}
+	return pause, nil
+}
+
+// resumeSyncing ends a pause, reporting whether one was in effect
+func resumeSyncing() (bool, error) {
+	st, err := state.Load()
+	if err != nil {
+		return false, err
+	}
+	paused := st.Paused(time.Now()) != nil
+
+	// Also clears a pause that already ran out
+	if st.Pause != nil {
+		if err := state.RecordPause(nil); err != nil {
+			return false, fmt.Errorf("failed to resume: %w", err)
+		}
+	}
+	return paused, nil
+}
+
+// activePause returns the pause in effect now, or nil. A state file that
+// cannot be read counts as not paused.
+func activePause() *state.Pause {
+	st, err := state.Load()
+	if err != nil {
+		return nil
+	}
+	return st.Paused(time.Now())
+}
+
+// pauseDescription says how long automatic syncing is paused
+func pauseDescription(pause *state.Pause) string {
+	if pause.Until.IsZero() {
+		return "Automatic syncing paused until 'opencode-sync resume'"
+	}
+	return fmt.Sprintf("Automatic syncing paused until %s", pause.Until.Format("2006-01-02 15:04"))
+}
+
+// pausedNow reports whether automatic syncing is paused, announcing when
+// that changed since the last call. wasPaused holds the last answer.
+func pausedNow(wasPaused *bool) bool {
+	pause := activePause()
+	switch {
+	case pause != nil && !*wasPaused:
+		ui.Info(pauseDescription(pause) + "; skipping syncs")
+	case pause == nil && *wasPaused:
+		ui.Info("Automatic syncing resumed")
+	}
+
+	*wasPaused = pause != nil
+	return *wasPaused
+}
@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GareArc/opencode-sync/internal/git"
+	"github.com/GareArc/opencode-sync/internal/sync"
+)
+
+// porcelainVersion is the first line of 'status --porcelain'. The format
+// of a version never changes; new records may only be added.
+const porcelainVersion = "1"
+
+// porcelainLine prints one tab-separated record of porcelain output
+func porcelainLine(fields ...string) {
+	for i, field := range fields {
+		fields[i] = porcelainQuote(field)
+	}
+	fmt.Println(strings.Join(fields, "\t"))
+}
+
+// porcelainQuote quotes a field holding a tab, newline, double quote or
+// backslash as a C-style string, like git does for unusual paths
+func porcelainQuote(field string) string {
+	if !strings.ContainsAny(field, "\t\n\r\"\\") {
+		return field
+	}
+	return strconv.Quote(field)
+}
+
+// printStatusPorcelain prints 'status --porcelain': one record per line,
+// the record type first
+func printStatusPorcelain(syncer *sync.Syncer, state *sync.SyncState) error {
+	pending, err := syncer.PendingChanges()
+	if err != nil {
+		return err
+	}
+	var renames []git.FileChange
+	if !state.IsClean {
+		if renames, err = syncer.WorktreeRenames(); err != nil {
+			return err
+		}
+	}
+
+	porcelainLine("version", porcelainVersion)
+	if state.Branch != "" {
+		porcelainLine("channel", state.Branch)
+	}
+	layout := "copy"
+	if syncer.Direct() {
+		layout = "direct"
+	}
+	porcelainLine("layout", layout)
+	porcelainLine("clean", strconv.FormatBool(state.IsClean))
+	if pause := activePause(); pause != nil {
+		until := "-"
+		if !pause.Until.IsZero() {
+			until = pause.Until.UTC().Format(time.RFC3339)
+		}
+		porcelainLine("paused", until)
+	}
+	for _, file := range pending {
+		porcelainLine("pending", file)
+	}
+	for _, change := range renames {
+		porcelainLine("renamed", change.Path, change.OldPath)
+	}
+	for _, file := range state.ConflictFiles {
+		porcelainLine("conflict", file)
+	}
+
+	return nil
+}
+
+// printDiffPorcelain prints 'diff --porcelain': the status and path of
+// each changed file, and the old path of renamed ones
+func printDiffPorcelain(changes []git.FileChange) {
+	for _, change := range changes {
+		if change.OldPath != "" {
+			porcelainLine(change.Status.String(), change.Path, change.OldPath)
+			continue
+		}
+		porcelainLine(change.Status.String(), change.Path)
+	}
+}
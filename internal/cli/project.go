@@ -0,0 +1,222 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/GareArc/opencode-sync/internal/config"
+	"github.com/GareArc/opencode-sync/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var projectName string
+
+// projectCmd represents the project command
+var projectCmd = &cobra.Command{
+	Use:   "project",
+	Short: "Manage synced project directories",
+	Long: `Register project directories whose .opencode/ directory and AGENTS.md
+are synced under projects/<name>/ in the sync repo.
+
+Project paths are stored per machine, so the same project can live in a
+different location on each machine as long as it is registered with the
+same name.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runProjectList()
+	},
+}
+
+var projectAddCmd = &cobra.Command{
+	Use:   "add <path>",
+	Short: "Register a project directory",
+	Long: `Register a project directory for syncing.
+
+Examples:
+  opencode-sync project add ~/code/my-app
+  opencode-sync project add . --name my-app`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runProjectAdd(args[0], projectName)
+	},
+}
+
+var projectRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Unregister a project",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runProjectRemove(args[0])
+	},
+}
+
+var projectListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List registered projects",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runProjectList()
+	},
+}
+
+var projectEnableCmd = &cobra.Command{
+	Use:   "enable <name>",
+	Short: "Enable syncing for a project",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runProjectSetEnabled(args[0], true)
+	},
+}
+
+var projectDisableCmd = &cobra.Command{
+	Use:   "disable <name>",
+	Short: "Disable syncing for a project without unregistering it",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runProjectSetEnabled(args[0], false)
+	},
+}
+
+func init() {
+	projectAddCmd.Flags().StringVar(&projectName, "name", "", "name used in the sync repo (default: directory name)")
+
+	projectCmd.AddCommand(projectAddCmd)
+	projectCmd.AddCommand(projectRemoveCmd)
+	projectCmd.AddCommand(projectListCmd)
+	projectCmd.AddCommand(projectEnableCmd)
+	projectCmd.AddCommand(projectDisableCmd)
+}
+
+// loadConfigForEdit loads the config, failing if none exists
+func loadConfigForEdit() (*config.Config, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	if cfg == nil {
+		return nil, config.ErrNoConfig
+	}
+	return cfg, nil
+}
+
+// saveValidConfig validates and saves the config
+func saveValidConfig(cfg *config.Config) error {
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	if err := config.Save(cfg); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	return nil
+}
+
+func runProjectAdd(path, name string) error {
+	cfg, err := loadConfigForEdit()
+	if err != nil {
+		return err
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("failed to resolve path: %w", err)
+	}
+
+	info, err := os.Stat(absPath)
+	if err != nil || !info.IsDir() {
+		return fmt.Errorf("not a directory: %s", absPath)
+	}
+
+	if name == "" {
+		name = filepath.Base(absPath)
+	}
+
+	if cfg.FindProject(name) != nil {
+		return fmt.Errorf("project %s is already registered. Use --name to choose another name", name)
+	}
+
+	cfg.Projects = append(cfg.Projects, config.ProjectConfig{
+		Name:    name,
+		Path:    absPath,
+		Enabled: true,
+	})
+
+	if err := saveValidConfig(cfg); err != nil {
+		return err
+	}
+
+	ui.Success(fmt.Sprintf("Registered project %s (%s)", name, absPath))
+	ui.Info("Its .opencode/ and AGENTS.md will be synced under projects/" + name + "/")
+	return nil
+}
+
+func runProjectRemove(name string) error {
+	cfg, err := loadConfigForEdit()
+	if err != nil {
+		return err
+	}
+
+	for i, project := range cfg.Projects {
+		if project.Name == name {
+			cfg.Projects = append(cfg.Projects[:i], cfg.Projects[i+1:]...)
+
+			if err := saveValidConfig(cfg); err != nil {
+				return err
+			}
+
+			ui.Success(fmt.Sprintf("Unregistered project %s", name))
+			ui.Info("Files already in the sync repo are kept. Delete projects/" + name + "/ there to remove them.")
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown project: %s", name)
+}
+
+func runProjectList() error {
+	cfg, err := loadConfigForEdit()
+	if err != nil {
+		return err
+	}
+
+	if len(cfg.Projects) == 0 {
+		ui.Info("No projects registered. Add one with 'opencode-sync project add <path>'")
+		return nil
+	}
+
+	fmt.Println("\nProjects:")
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	for _, project := range cfg.Projects {
+		status := "✓"
+		if !project.Enabled {
+			status = "✗ disabled"
+		}
+		fmt.Printf("%s %s  %s\n", status, project.Name, project.Path)
+	}
+
+	return nil
+}
+
+func runProjectSetEnabled(name string, enabled bool) error {
+	cfg, err := loadConfigForEdit()
+	if err != nil {
+		return err
+	}
+
+	project := cfg.FindProject(name)
+	if project == nil {
+		return fmt.Errorf("unknown project: %s", name)
+	}
+	project.Enabled = enabled
+
+	if err := saveValidConfig(cfg); err != nil {
+		return err
+	}
+
+	if enabled {
+		ui.Success(fmt.Sprintf("Enabled project %s", name))
+	} else {
+		ui.Success(fmt.Sprintf("Disabled project %s", name))
+	}
+	return nil
+}
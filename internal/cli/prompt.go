@@ -0,0 +1,153 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/GareArc/opencode-sync/internal/config"
+	"github.com/GareArc/opencode-sync/internal/paths"
+	"github.com/GareArc/opencode-sync/internal/sync"
+	"github.com/spf13/cobra"
+)
+
+// promptCacheTTL is how long 'prompt' reuses its last result. Prompts are
+// drawn after every command, and hashing the synced files each time would
+// make the shell feel slow.
+const promptCacheTTL = 15 * time.Second
+
+// promptShell is set by 'prompt --shell'
+var promptShell string
+
+// promptCmd represents the prompt command
+var promptCmd = &cobra.Command{
+	Use:   "prompt",
+	Short: "Print a short sync status for your shell prompt",
+	Long: `Print a compact sync status for a shell prompt, or nothing when in sync:
+
+  ⇡2   two local commits not pushed yet
+  ⇣1   one remote commit not pulled yet (as of the last fetch)
+  ✗3   three local files changed since the last push
+  ⏸    automatic syncing is paused ('opencode-sync pause')
+
+It never touches the network and caches its result for 15 seconds, or until
+the next push or pull.
+
+With --shell, print a snippet that defines an opencode_sync_prompt function
+(Get-OpencodeSyncPrompt in PowerShell) to use in your prompt:
+
+  eval "$(opencode-sync prompt --shell zsh)"          # ~/.zshrc
+  eval "$(opencode-sync prompt --shell bash)"         # ~/.bashrc
+  opencode-sync prompt --shell fish | source          # config.fish
+  opencode-sync prompt --shell powershell | Invoke-Expression`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if promptShell != "" {
+			return printPromptSnippet(promptShell)
+		}
+		return runPrompt()
+	},
+}
+
+func init() {
+	promptCmd.Flags().StringVar(&promptShell, "shell", "", "print a prompt snippet for zsh, bash, fish or powershell")
+}
+
+// promptSnippets are the prompt functions printed by 'prompt --shell'
+var promptSnippets = map[string]string{
+	"zsh": `opencode_sync_prompt() { opencode-sync prompt 2>/dev/null }
+# Show it on the right, e.g.:
+#   setopt PROMPT_SUBST; RPROMPT='$(opencode_sync_prompt)'
+`,
+	"bash": `opencode_sync_prompt() { opencode-sync prompt 2>/dev/null; }
+# Add it to your prompt, e.g.:
+#   PS1='$(opencode_sync_prompt) '"$PS1"
+`,
+	"fish": `function opencode_sync_prompt
+    opencode-sync prompt 2>/dev/null
+end
+# Call it from fish_prompt or fish_right_prompt
+`,
+	"powershell": `function Get-OpencodeSyncPrompt { opencode-sync prompt 2>$null }
+# Call it from your prompt function, e.g.:
+#   function prompt { "$(Get-OpencodeSyncPrompt) PS $($PWD.Path)> " }
+`,
+}
+
+func printPromptSnippet(shell string) error {
+	snippet, ok := promptSnippets[shell]
+	if !ok {
+		return fmt.Errorf("unsupported shell %q: use zsh, bash, fish or powershell", shell)
+	}
+
+	fmt.Print(snippet)
+	return nil
+}
+
+func runPrompt() error {
+	p, err := paths.Get()
+	if err != nil {
+		return fmt.Errorf("failed to get paths: %w", err)
+	}
+
+	if promptCacheFresh(p) {
+		if data, err := os.ReadFile(p.PromptCacheFile()); err == nil {
+			fmt.Print(string(data))
+			return nil
+		}
+	}
+
+	status := promptStatus(p)
+
+	// A failed cache write only costs speed
+	_ = os.WriteFile(p.PromptCacheFile(), []byte(status), 0644)
+
+	fmt.Print(status)
+	return nil
+}
+
+// promptCacheFresh reports whether the cached prompt can be reused: it is
+// recent and no push, pull or fetch was recorded since it was written
+func promptCacheFresh(p *paths.Paths) bool {
+	cache, err := os.Stat(p.PromptCacheFile())
+	if err != nil || time.Since(cache.ModTime()) >= promptCacheTTL {
+		return false
+	}
+
+	st, err := os.Stat(p.StateFile())
+	return err != nil || st.ModTime().Before(cache.ModTime())
+}
+
+// promptStatus computes the prompt segment from local state only. Keys are
+// not loaded, so a passphrase-protected key never prompts.
+func promptStatus(p *paths.Paths) string {
+	cfg, err := config.Load()
+	if err != nil || cfg == nil {
+		// Not set up: show nothing rather than break the prompt
+		return ""
+	}
+
+	repo := sync.NewRepo(p)
+	if err := repo.Open(); err != nil {
+		return ""
+	}
+
+	var parts []string
+	if activePause() != nil {
+		parts = append(parts, "⏸")
+	}
+	if ahead, err := repo.UnpushedCommits(); err == nil && ahead > 0 {
+		parts = append(parts, fmt.Sprintf("⇡%d", ahead))
+	}
+	if behind, err := repo.BehindCommits(); err == nil && behind > 0 {
+		parts = append(parts, fmt.Sprintf("⇣%d", behind))
+	}
+	syncer := sync.New(cfg, p, repo)
+	if pending, err := syncer.PendingChanges(); err == nil && len(pending) > 0 {
+		parts = append(parts, fmt.Sprintf("✗%d", len(pending)))
+	}
+	saveHashCache(syncer)
+
+	return strings.Join(parts, " ")
+}
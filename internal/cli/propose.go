@@ -0,0 +1,115 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/GareArc/opencode-sync/internal/forge"
+	"github.com/GareArc/opencode-sync/internal/git"
+	"github.com/GareArc/opencode-sync/internal/sync"
+	"github.com/GareArc/opencode-sync/internal/ui"
+)
+
+// proposeBranchPrefix starts the branch each machine proposes changes on
+const proposeBranchPrefix = "opencode-sync/"
+
+// proposeBranch returns the branch this machine proposes changes on
+func proposeBranch() string {
+	host := strings.Map(func(r rune) rune {
+		if r == ' ' || r == ':' || r == '~' || r == '^' || r == '?' || r == '*' || r == '[' || r == '\\' {
+			return '-'
+		}
+		return r
+	}, sync.Hostname())
+	return proposeBranchPrefix + host
+}
+
+// proposalTarget returns where 'push --propose' opens pull requests and
+// the API token to do it with, checked before anything is committed
+func proposalTarget(syncer *sync.Syncer) (*proposal, error) {
+	repo, err := openSyncRepo()
+	if err != nil {
+		return nil, err
+	}
+
+	remoteURL, err := repo.GetRemoteURL("origin")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get remote URL: %w", err)
+	}
+	forgeRepo, err := forge.Parse(remoteURL, syncer.Config().Repo.Forge)
+	if err != nil {
+		return nil, err
+	}
+	token, err := forgeRepo.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	return &proposal{repo: forgeRepo, token: token}, nil
+}
+
+// proposal is where 'push --propose' opens pull requests
+type proposal struct {
+	repo  *forge.Repo
+	token string
+}
+
+// proposeCommits pushes the unpushed commits of the active channel to this
+// machine's proposal branch and opens a pull request for them. The
+// commits stay on the channel locally, so pulling after the pull request
+// is merged brings in the reviewed result.
+func proposeCommits(ctx context.Context, syncer *sync.Syncer, repo *git.BuiltinGit, target *proposal, unpushed int) error {
+	base, err := repo.GetBranch()
+	if err != nil {
+		return err
+	}
+
+	head := proposeBranch()
+	if err := ui.SpinnerWithResult(fmt.Sprintf("Pushing to %s", head), func() error {
+		defer syncer.Timings().Start(sync.PhaseNetwork)()
+		return repo.PushHeadTo(ctx, head)
+	}); err != nil {
+		return fmt.Errorf("failed to push: %w", err)
+	}
+
+	var webURL string
+	var existing bool
+	if err := ui.SpinnerWithResult("Opening pull request", func() error {
+		var err error
+		webURL, existing, err = target.repo.OpenPullRequest(ctx, target.token, forge.PullRequest{
+			Title: fmt.Sprintf("Config changes from %s", sync.Hostname()),
+			Body:  proposalBody(repo, unpushed),
+			Head:  head,
+			Base:  base,
+		})
+		return err
+	}); err != nil {
+		return err
+	}
+
+	if existing {
+		ui.Success(fmt.Sprintf("Updated the open pull request: %s", webURL))
+	} else {
+		ui.Success(fmt.Sprintf("Opened a pull request: %s", webURL))
+	}
+	ui.Info(fmt.Sprintf("Once it is merged, run 'opencode-sync pull' to bring the reviewed changes into %s", base))
+	return nil
+}
+
+// proposalBody describes the proposed commits for the pull request
+func proposalBody(repo *git.BuiltinGit, unpushed int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Proposed from %s with opencode-sync.\n", sync.Hostname())
+
+	commits, err := repo.Log()
+	if err != nil || unpushed == 0 {
+		return b.String()
+	}
+	b.WriteString("\n")
+	for _, commit := range commits[:min(unpushed, len(commits))] {
+		subject, _, _ := strings.Cut(commit.Message, "\n")
+		fmt.Fprintf(&b, "- %s %s\n", commit.Hash, subject)
+	}
+	return b.String()
+}
@@ -0,0 +1,213 @@
+package cli
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/GareArc/opencode-sync/internal/crypto"
+	"github.com/GareArc/opencode-sync/internal/sync"
+	"github.com/GareArc/opencode-sync/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// recipientsPath is set by 'recipients add --path' and 'recipients remove --path'
+var recipientsPath string
+
+// recipientsCmd represents the recipients command
+var recipientsCmd = &cobra.Command{
+	Use:   "recipients",
+	Short: "Encrypt paths to their own list of public keys",
+	Long: `Encrypt matching paths to their own list of age public keys instead of
+the shared key, so a team repo can keep e.g. CI secrets readable by a few
+people only.
+
+The policy is stored as recipients.json in the sync repo. Matching files
+are pushed as <path>.age, encrypted to the keys of the last rule that
+matches them. Machines whose key is not a recipient leave their local copy
+alone on pull. Every push checks that each encrypted file is encrypted to
+the keys its rule lists, and refuses to publish one that is not.
+
+A machine is listed by the public key of its shared key, shown by
+'opencode-sync recipients list'.
+
+Without a subcommand, lists the rules.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runRecipientsList()
+	},
+}
+
+var recipientsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the recipient rules and this machine's key",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runRecipientsList()
+	},
+}
+
+var recipientsAddCmd = &cobra.Command{
+	Use:   "add --path <glob> <pubkey>...",
+	Short: "Encrypt matching paths to a public key",
+	Long: `Add age public keys to the recipients of the paths matching --path,
+creating the rule if needed. --path is matched like sync.hostSecrets: a
+glob against the file name or repo path, or a directory.
+
+Run 'opencode-sync push' to re-encrypt the matching files and publish the
+policy.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runRecipientsAdd(recipientsPath, args)
+	},
+}
+
+var recipientsRemoveCmd = &cobra.Command{
+	Use:   "remove --path <glob> [pubkey]",
+	Short: "Remove a public key, or the whole rule, for matching paths",
+	Long: `Remove a public key from the recipients of --path, or the whole rule if
+no key is given. A rule left without recipients is removed.
+
+Run 'opencode-sync push' from a machine that can read the files to
+re-encrypt them. Files no longer covered by any rule are pushed in
+plaintext again.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var recipient string
+		if len(args) == 1 {
+			recipient = args[0]
+		}
+		return runRecipientsRemove(recipientsPath, recipient)
+	},
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{recipientsAddCmd, recipientsRemoveCmd} {
+		cmd.Flags().StringVar(&recipientsPath, "path", "", "glob, file name or directory the rule applies to")
+		_ = cmd.MarkFlagRequired("path")
+	}
+
+	recipientsCmd.AddCommand(recipientsListCmd)
+	recipientsCmd.AddCommand(recipientsAddCmd)
+	recipientsCmd.AddCommand(recipientsRemoveCmd)
+}
+
+// initRecipientsSyncer returns a syncer for editing the recipient policy,
+// which only the copy layout supports
+func initRecipientsSyncer() (*sync.Syncer, error) {
+	syncer, err := initSyncer()
+	if err != nil {
+		return nil, err
+	}
+	if syncer.Direct() {
+		return nil, fmt.Errorf("recipient rules are not supported in the direct layout")
+	}
+	return syncer, nil
+}
+
+func runRecipientsList() error {
+	syncer, err := initRecipientsSyncer()
+	if err != nil {
+		return err
+	}
+
+	policy, err := syncer.LoadRecipientPolicy()
+	if err != nil {
+		return err
+	}
+
+	own := syncer.OwnRecipient()
+	if own != "" {
+		fmt.Printf("This machine: %s\n", own)
+	} else {
+		ui.Warn("Encryption is not enabled, so this machine cannot be a recipient")
+	}
+
+	if len(policy.Rules) == 0 {
+		fmt.Println("No recipient rules. Add one with 'opencode-sync recipients add --path <glob> <pubkey>'.")
+		return nil
+	}
+
+	for _, rule := range policy.Rules {
+		fmt.Println()
+		fmt.Printf("%s\n", rule.Path)
+		for _, recipient := range rule.Recipients {
+			if recipient == own {
+				fmt.Printf("  %s (this machine)\n", recipient)
+			} else {
+				fmt.Printf("  %s\n", recipient)
+			}
+		}
+	}
+
+	return nil
+}
+
+func runRecipientsAdd(path string, recipients []string) error {
+	for _, recipient := range recipients {
+		if _, err := crypto.NewAgeEncryptionWithPublicKey(recipient); err != nil {
+			return fmt.Errorf("invalid public key %s: %w", recipient, err)
+		}
+	}
+
+	syncer, err := initRecipientsSyncer()
+	if err != nil {
+		return err
+	}
+
+	policy, err := syncer.LoadRecipientPolicy()
+	if err != nil {
+		return err
+	}
+
+	if !policy.Add(path, recipients...) {
+		ui.Info(fmt.Sprintf("%s already has these recipients", path))
+		return nil
+	}
+	if err := syncer.SaveRecipientPolicy(policy); err != nil {
+		return err
+	}
+
+	ui.Success(fmt.Sprintf("Updated the recipients of %s", path))
+	if !ruleLists(policy, path, syncer.OwnRecipient()) {
+		ui.Warn("This machine is not a recipient; it won't be able to read these files once they are pushed")
+	}
+	ui.Info("Run 'opencode-sync push' to re-encrypt the matching files and publish the policy")
+	return nil
+}
+
+func runRecipientsRemove(path, recipient string) error {
+	syncer, err := initRecipientsSyncer()
+	if err != nil {
+		return err
+	}
+
+	policy, err := syncer.LoadRecipientPolicy()
+	if err != nil {
+		return err
+	}
+
+	if !policy.Remove(path, recipient) {
+		if recipient == "" || !ruleLists(policy, path, "") {
+			return fmt.Errorf("no recipient rule for %s", path)
+		}
+		return fmt.Errorf("the recipient rule for %s does not list %s", path, recipient)
+	}
+	if err := syncer.SaveRecipientPolicy(policy); err != nil {
+		return err
+	}
+
+	ui.Success(fmt.Sprintf("Updated the recipients of %s", path))
+	ui.Info("Run 'opencode-sync push' to re-encrypt the matching files and publish the policy")
+	return nil
+}
+
+// ruleLists reports whether the rule for path exists and, if recipient is
+// given, lists it
+func ruleLists(policy *sync.RecipientPolicy, path, recipient string) bool {
+	for _, rule := range policy.Rules {
+		if rule.Path == path {
+			return recipient == "" || slices.Contains(rule.Recipients, recipient)
+		}
+	}
+	return false
+}
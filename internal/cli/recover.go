@@ -0,0 +1,320 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/GareArc/opencode-sync/internal/config"
+	"github.com/GareArc/opencode-sync/internal/journal"
+	"github.com/GareArc/opencode-sync/internal/paths"
+	"github.com/GareArc/opencode-sync/internal/procs"
+	"github.com/GareArc/opencode-sync/internal/sync"
+	"github.com/GareArc/opencode-sync/internal/ui"
+	"github.com/charmbracelet/huh"
+	"github.com/spf13/cobra"
+)
+
+var (
+	recoverComplete bool
+	recoverRollback bool
+)
+
+// recoverCmd represents the recover command
+var recoverCmd = &cobra.Command{
+	Use:   "recover",
+	Short: "Complete or roll back an operation that was interrupted",
+	Long: `init, link, clone and pull record their progress in a journal while they
+run. If one is cut short by a crash, a kill or a power loss, the next
+command finds the journal and offers to complete the operation or roll it
+back. Use this command to do so without a prompt:
+
+  --complete   finish it: apply the pulled changes, or redo an init, link
+               or clone from the start
+  --rollback   undo it: restore the local files backed up by a pull and
+               reset the sync repo, or remove the half-made repo of an
+               init, link or clone`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if recoverComplete && recoverRollback {
+			return fmt.Errorf("use either --complete or --rollback")
+		}
+		return runRecover(cmd.Context(), recoverComplete, recoverRollback)
+	},
+}
+
+func init() {
+	recoverCmd.Flags().BoolVar(&recoverComplete, "complete", false, "complete the interrupted operation")
+	recoverCmd.Flags().BoolVar(&recoverRollback, "rollback", false, "roll back the interrupted operation")
+}
+
+func runRecover(ctx context.Context, complete, rollback bool) error {
+	entry, err := interruptedOperation()
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		ui.Info("No interrupted operation found")
+		return nil
+	}
+	useJournaledLayout(entry)
+
+	switch {
+	case complete:
+		return completeOperation(ctx, entry)
+	case rollback:
+		return rollBackOperation(ctx, entry)
+	case noPrompt:
+		return fmt.Errorf("found an interrupted %s; use --complete or --rollback", describeOperation(entry))
+	}
+	return offerRecovery(ctx, entry)
+}
+
+// interruptedOperation returns the journaled operation if the process that
+// ran it is gone, or nil
+func interruptedOperation() (*journal.Entry, error) {
+	entry, err := journal.Load()
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	if entry.PID == os.Getpid() || procs.ProcessAlive(entry.PID) {
+		// Still running, e.g. a pull in watch
+		return nil, nil
+	}
+	return entry, nil
+}
+
+// checkJournal runs before commands and offers to recover an interrupted
+// operation. Commands run by other programs are left alone.
+func checkJournal(cmd *cobra.Command) error {
+	switch {
+	case cmd == recoverCmd, cmd == promptCmd, cmd == mergeDriverCmd, cmd == versionCmd, cmd == verifyRepoCmd,
+		cmd.Parent() == hookCmd, cmd.Name() == "help", cmd.Name() == "completion":
+		return nil
+	}
+
+	entry, err := interruptedOperation()
+	if err != nil {
+		ui.Warn(fmt.Sprintf("Failed to read operation journal: %v", err))
+		return nil
+	}
+	if entry == nil {
+		return nil
+	}
+
+	useJournaledLayout(entry)
+
+	if noPrompt {
+		ui.Warn(fmt.Sprintf("Found an interrupted %s. Run 'opencode-sync recover --complete' or 'opencode-sync recover --rollback'.", describeOperation(entry)))
+		return nil
+	}
+
+	return offerRecovery(cmd.Context(), entry)
+}
+
+// useJournaledLayout locates the sync repo by the layout an init, link or
+// clone was creating it with, which the config may not record yet
+func useJournaledLayout(entry *journal.Entry) {
+	if entry.Op != journal.OpPull {
+		paths.DirectLayout = entry.Layout == config.LayoutDirect
+		paths.RepoSubdir = entry.Subdir
+		cloneLayout = entry.Layout
+	}
+}
+
+// offerRecovery asks whether to complete or roll back an operation
+func offerRecovery(ctx context.Context, entry *journal.Entry) error {
+	choice, err := ui.Select(
+		fmt.Sprintf("Found an interrupted %s", describeOperation(entry)),
+		"It did not finish, so the sync repo or your OpenCode config may be partly updated.",
+		[]huh.Option[string]{
+			huh.NewOption("Complete it", "complete"),
+			huh.NewOption("Roll it back", "rollback"),
+			huh.NewOption("Decide later", "later"),
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	switch choice {
+	case "complete":
+		return completeOperation(ctx, entry)
+	case "rollback":
+		return rollBackOperation(ctx, entry)
+	}
+
+	ui.Info("Run 'opencode-sync recover' when you are ready")
+	return nil
+}
+
+// describeOperation names an operation and when it started, such as
+// "pull by 'sync' started 2006-01-02 15:04:05"
+func describeOperation(entry *journal.Entry) string {
+	name := entry.Op
+	if entry.Op == journal.OpPull && entry.Command != "" && entry.Command != journal.OpPull {
+		name = fmt.Sprintf("pull by '%s'", entry.Command)
+	}
+	return fmt.Sprintf("%s started %s", name, entry.Started.Format("2006-01-02 15:04:05"))
+}
+
+// completeOperation finishes an interrupted operation
+func completeOperation(ctx context.Context, entry *journal.Entry) error {
+	p, err := paths.Get()
+	if err != nil {
+		return fmt.Errorf("failed to get paths: %w", err)
+	}
+	repo := sync.NewRepo(p)
+
+	switch entry.Op {
+	case journal.OpPull:
+		syncer, err := initSyncer()
+		if err != nil {
+			return err
+		}
+		if err := repo.Open(); err != nil {
+			return fmt.Errorf("failed to open git repository: %w", err)
+		}
+		// Backs up the current files and applies the repo in full
+		return applyPulled(ctx, syncer, repo, entry.HeadBefore, entry.Command)
+
+	case journal.OpClone:
+		if entry.Done(journal.StepCloned) {
+			if err := repo.Open(); err != nil {
+				return fmt.Errorf("failed to open git repository: %w", err)
+			}
+			return setUpClone(ctx, p, repo, entry)
+		}
+		if err := restartOperation(p); err != nil {
+			return err
+		}
+		return runClone(ctx, entry.URL)
+
+	case journal.OpLink:
+		if entry.Done(journal.StepCommitted) {
+			cfg, err := config.Load()
+			if err != nil || cfg == nil {
+				return config.ErrNoConfig
+			}
+			if err := repo.Open(); err != nil {
+				return fmt.Errorf("failed to open git repository: %w", err)
+			}
+			return forcePushLink(ctx, repo, cfg, entry)
+		}
+		if err := restartOperation(p); err != nil {
+			return err
+		}
+		return runLink(ctx, entry.URL)
+
+	case journal.OpInit:
+		if err := restartOperation(p); err != nil {
+			return err
+		}
+		return runInit(ctx, entry.Template)
+	}
+
+	return fmt.Errorf("unknown operation %q in journal", entry.Op)
+}
+
+// restartOperation removes the half-made sync repo of an init, link or
+// clone, so it can run again from the start
+func restartOperation(p *paths.Paths) error {
+	if err := removeSyncRepo(p); err != nil {
+		return err
+	}
+	return journal.Remove()
+}
+
+// rollBackOperation undoes what an interrupted operation changed
+func rollBackOperation(ctx context.Context, entry *journal.Entry) error {
+	p, err := paths.Get()
+	if err != nil {
+		return fmt.Errorf("failed to get paths: %w", err)
+	}
+
+	switch entry.Op {
+	case journal.OpPull:
+		if err := rollBackPull(entry); err != nil {
+			return err
+		}
+
+	case journal.OpInit, journal.OpLink, journal.OpClone:
+		if err := removeSyncRepo(p); err != nil {
+			return err
+		}
+		ui.Success("Removed the sync repository")
+
+		if entry.Done(journal.StepConfigCreated) {
+			if err := os.Remove(p.ConfigFile()); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to remove config: %w", err)
+			}
+			ui.Success("Removed the config created by clone")
+		}
+
+		if entry.Done(journal.StepConfigSaved) {
+			cfg, err := config.Load()
+			if err == nil && cfg != nil {
+				cfg.Repo.URL = entry.PreviousURL
+				if err := config.Save(cfg); err != nil {
+					return fmt.Errorf("failed to save config: %w", err)
+				}
+				ui.Success("Restored repo.url")
+			}
+		}
+
+	default:
+		return fmt.Errorf("unknown operation %q in journal", entry.Op)
+	}
+
+	if err := journal.Remove(); err != nil {
+		return err
+	}
+	ui.Success(fmt.Sprintf("Rolled back the interrupted %s", describeOperation(entry)))
+	return nil
+}
+
+// rollBackPull restores the local files a pull backed up and moves the
+// sync repo back to where it was, so the next pull applies it again
+func rollBackPull(entry *journal.Entry) error {
+	syncer, err := initSyncer()
+	if err != nil {
+		return err
+	}
+
+	if entry.Done(journal.StepBackedUp) {
+		backup, err := syncer.LoadBackup(entry.BackupDir)
+		if err != nil {
+			return fmt.Errorf("failed to load backup: %w", err)
+		}
+		if err := syncer.RestoreBackup(backup); err != nil {
+			return fmt.Errorf("failed to restore backup: %w", err)
+		}
+		ui.Success("Restored local config from the backup")
+	}
+
+	if entry.HeadBefore == "" {
+		return nil
+	}
+
+	p, _ := paths.Get()
+	repo := sync.NewRepo(p)
+	if err := repo.Open(); err != nil {
+		return fmt.Errorf("failed to open git repository: %w", err)
+	}
+	if head, _ := repo.GetHead(); head != entry.HeadBefore {
+		if err := repo.ResetHard(entry.HeadBefore); err != nil {
+			return err
+		}
+		ui.Success(fmt.Sprintf("Reset the sync repo to %s", entry.HeadBefore[:7]))
+	}
+
+	return nil
+}
+
+// warnJournal reports a failure to update the operation journal. It only
+// costs the ability to recover after a crash, so the operation continues.
+func warnJournal(err error) {
+	if err != nil {
+		ui.Warn(fmt.Sprintf("Failed to update operation journal: %v", err))
+	}
+}
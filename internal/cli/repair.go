@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/GareArc/opencode-sync/internal/config"
+	"github.com/GareArc/opencode-sync/internal/git"
+	"github.com/GareArc/opencode-sync/internal/paths"
+	"github.com/GareArc/opencode-sync/internal/sync"
+	"github.com/GareArc/opencode-sync/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// repairCmd represents the repair command
+var repairCmd = &cobra.Command{
+	Use:   "repair",
+	Short: "Re-clone a corrupted sync repository",
+	Long: `Replace the local sync repository with a fresh clone from the remote.
+
+This command will:
+1. Clone the remote into a new directory
+2. Move the old repository aside (kept for inspection)
+3. Re-copy your current OpenCode configs into the fresh clone
+
+Local changes that were never pushed are preserved as uncommitted changes.
+Run 'opencode-sync push' afterwards to publish them.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runRepair(cmd.Context())
+	},
+}
+
+func runRepair(ctx context.Context) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if cfg == nil {
+		return config.ErrNoConfig
+	}
+
+	p, err := paths.Get()
+	if err != nil {
+		return fmt.Errorf("failed to get paths: %w", err)
+	}
+
+	if cfg.Repo.Layout == config.LayoutDirect {
+		// The working tree is the OpenCode config, which cannot be swapped
+		// for a fresh clone
+		return fmt.Errorf("repair is not supported with repo.layout direct. Move %s aside and run 'opencode-sync clone --layout direct' instead", p.SyncGitDir())
+	}
+
+	repoDir := p.SyncRepoRoot()
+
+	// Prefer the URL recorded in the repo, fall back to config
+	repoURL := cfg.Repo.URL
+	old := git.NewBuiltinGit(repoDir)
+	if err := old.Open(); err == nil {
+		if url, err := old.GetRemoteURL("origin"); err == nil {
+			repoURL = url
+		}
+	}
+	if repoURL == "" {
+		return fmt.Errorf("no remote URL configured. Set one with 'opencode-sync config set repo.url <url>'")
+	}
+
+	stamp := time.Now().Format("20060102-150405")
+	freshDir := repoDir + ".repair-" + stamp
+	corruptDir := repoDir + ".corrupt-" + stamp
+
+	fresh := git.NewBuiltinGit(freshDir)
+	if err := ui.SpinnerWithResult(fmt.Sprintf("Cloning %s", repoURL), func() error {
+		return fresh.Clone(ctx, repoURL)
+	}); err != nil {
+		os.RemoveAll(freshDir)
+		return fmt.Errorf("failed to clone repository: %w", err)
+	}
+
+	if _, err := os.Stat(repoDir); err == nil {
+		if err := os.Rename(repoDir, corruptDir); err != nil {
+			os.RemoveAll(freshDir)
+			return fmt.Errorf("failed to move old repository aside: %w", err)
+		}
+	}
+
+	if err := os.Rename(freshDir, repoDir); err != nil {
+		return fmt.Errorf("failed to move fresh clone into place: %w", err)
+	}
+
+	repo := sync.NewRepo(p)
+	if err := repo.Open(); err != nil {
+		return err
+	}
+
+	syncer := sync.New(cfg, p, repo)
+	if err := setupEncryption(syncer, cfg, p); err != nil {
+		return err
+	}
+
+	if err := ui.SpinnerWithResult("Re-copying OpenCode configurations", func() error {
+		return syncer.CopyToRepo(ctx)
+	}); err != nil {
+		return fmt.Errorf("failed to copy configs: %w", err)
+	}
+
+	ui.Success("Repository repaired!")
+	if _, err := os.Stat(corruptDir); err == nil {
+		ui.Info(fmt.Sprintf("Old repository kept at: %s", corruptDir))
+	}
+
+	hasChanges, err := repo.HasChanges()
+	if err == nil && hasChanges {
+		ui.Info("Local changes were restored. Run 'opencode-sync push' to publish them.")
+	}
+
+	return nil
+}
@@ -1,11 +1,18 @@
 package cli
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/GareArc/opencode-sync/internal/config"
 	"github.com/GareArc/opencode-sync/internal/crypto"
+	"github.com/GareArc/opencode-sync/internal/git"
 	"github.com/GareArc/opencode-sync/internal/paths"
+	"github.com/GareArc/opencode-sync/internal/sync"
 	"github.com/GareArc/opencode-sync/internal/ui"
 	"github.com/spf13/cobra"
 )
@@ -21,6 +28,7 @@ var (
 	dryRun   bool
 	noPrompt bool
 	cfgFile  string
+	sandbox  bool
 )
 
 // SetVersionInfo sets version information from main
@@ -38,6 +46,32 @@ var rootCmd = &cobra.Command{
 across multiple machines via Git, with optional encryption for secrets.
 
 Run without arguments for interactive mode, or use subcommands for scripting.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if sandbox {
+			if err := enterSandbox(cmd.Context()); err != nil {
+				return err
+			}
+		}
+
+		// Checked against the minimum version of the sync repo
+		sync.Version = buildInfo().Version
+
+		// Without prompts, missing credentials must fail instead of
+		// waiting for input; stored ones still come from credential helpers
+		git.NoTerminalPrompt = noPrompt
+
+		// Don't let a hung connection block a command forever
+		git.DefaultTimeout = config.DefaultTimeoutSeconds * time.Second
+		git.MaxReadSize = config.DefaultMaxMemoryFileSizeMB << 20
+		if cfg, err := config.Load(); err == nil && cfg != nil {
+			// ssh runs this binary to check pinned host keys
+			exe, _ := os.Executable()
+			sync.Configure(cfg, exe)
+			setupKeySession(cfg)
+		}
+
+		return checkJournal(cmd)
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// Check if config exists
 		cfg, err := config.Load()
@@ -49,13 +83,30 @@ Run without arguments for interactive mode, or use subcommands for scripting.`,
 		}
 
 		// Config exists - show main menu
-		return runInteractiveMenu(cfg)
+		return runInteractiveMenu(cmd.Context(), cfg)
 	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 func Execute() error {
-	return rootCmd.Execute()
+	defer leaveSandbox()
+
+	// Don't leave unlocked key material behind
+	defer crypto.DefaultSession.Lock()
+
+	// Cancel running operations on Ctrl-C so they can stop cleanly. A
+	// second Ctrl-C is no longer caught and exits immediately.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	cmd, err := rootCmd.ExecuteContextC(ctx)
+	recordUsage(cmd, err)
+	printHint(err)
+	return err
 }
 
 func init() {
@@ -64,6 +115,7 @@ func init() {
 	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "show what would be done without making changes")
 	rootCmd.PersistentFlags().BoolVar(&noPrompt, "no-prompt", false, "disable interactive prompts (for scripting)")
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default: ~/.config/opencode-sync/config.json)")
+	rootCmd.PersistentFlags().BoolVar(&sandbox, "sandbox", false, "run against temporary copies of your config and sync repo")
 
 	// Add subcommands
 	rootCmd.AddCommand(versionCmd)
@@ -82,6 +134,39 @@ func init() {
 	rootCmd.AddCommand(rebindCmd)
 	rootCmd.AddCommand(gcCmd)
 	rootCmd.AddCommand(uninstallCmd)
+	rootCmd.AddCommand(watchCmd)
+	rootCmd.AddCommand(undoCmd)
+	rootCmd.AddCommand(repairCmd)
+	rootCmd.AddCommand(projectCmd)
+	rootCmd.AddCommand(statsCmd)
+	rootCmd.AddCommand(hostsCmd)
+	rootCmd.AddCommand(upstreamCmd)
+	rootCmd.AddCommand(bundleCmd)
+	rootCmd.AddCommand(browseCmd)
+	rootCmd.AddCommand(hookCmd)
+	rootCmd.AddCommand(integrateCmd)
+	rootCmd.AddCommand(promptCmd)
+	rootCmd.AddCommand(churnCmd)
+	rootCmd.AddCommand(pauseCmd)
+	rootCmd.AddCommand(resumeCmd)
+	rootCmd.AddCommand(lockCmd)
+	rootCmd.AddCommand(unlockCmd)
+	rootCmd.AddCommand(recoverCmd)
+	rootCmd.AddCommand(layoutCmd)
+	rootCmd.AddCommand(channelCmd)
+	rootCmd.AddCommand(recipientsCmd)
+	rootCmd.AddCommand(auditCmd)
+	rootCmd.AddCommand(driftCmd)
+	rootCmd.AddCommand(lintCmd)
+	rootCmd.AddCommand(mergeDriverCmd)
+	rootCmd.AddCommand(sshKnownHostsCmd)
+	rootCmd.AddCommand(selftestCmd)
+	rootCmd.AddCommand(ctlCmd)
+	rootCmd.AddCommand(serveCmd)
+	rootCmd.AddCommand(snapshotCmd)
+	rootCmd.AddCommand(verifyRepoCmd)
+	rootCmd.AddCommand(manifestCmd)
+	rootCmd.AddCommand(migrateCmd)
 }
 
 // runSetupWizard runs the first-time setup wizard
@@ -159,12 +244,12 @@ func runKeyMenu() error {
 		switch choice {
 		case "export":
 			if err := runKeyExport(); err != nil {
-				ui.Error(err.Error())
+				reportError(err)
 			}
 		case "import":
 			key, err := ui.Input("Paste your private key", "AGE-SECRET-KEY-1...")
 			if err != nil {
-				ui.Error(err.Error())
+				reportError(err)
 				continue
 			}
 			if key == "" {
@@ -172,11 +257,11 @@ func runKeyMenu() error {
 				continue
 			}
 			if err := runKeyImport(key); err != nil {
-				ui.Error(err.Error())
+				reportError(err)
 			}
 		case "regen":
 			if err := runKeyRegen(); err != nil {
-				ui.Error(err.Error())
+				reportError(err)
 			}
 		case "back":
 			return nil
@@ -186,7 +271,7 @@ func runKeyMenu() error {
 	}
 }
 
-func runInteractiveMenu(cfg *config.Config) error {
+func runInteractiveMenu(ctx context.Context, cfg *config.Config) error {
 	for {
 		choice, err := ui.MainMenu()
 		if err != nil {
@@ -195,67 +280,71 @@ func runInteractiveMenu(cfg *config.Config) error {
 
 		switch choice {
 		case "sync":
-			if err := runSync(); err != nil {
-				ui.Error(err.Error())
+			if err := runSync(ctx); err != nil {
+				reportError(err)
 			}
 		case "pull":
-			if err := runPull(); err != nil {
-				ui.Error(err.Error())
+			if err := runPull(ctx); err != nil {
+				reportError(err)
 			}
 		case "push":
-			if err := runPush(); err != nil {
-				ui.Error(err.Error())
+			if err := runPush(ctx); err != nil {
+				reportError(err)
 			}
 		case "status":
 			if err := runStatus(); err != nil {
-				ui.Error(err.Error())
+				reportError(err)
 			}
 		case "diff":
 			if err := runDiff(); err != nil {
-				ui.Error(err.Error())
+				reportError(err)
+			}
+		case "undo":
+			if err := runUndo(ctx, false); err != nil {
+				reportError(err)
 			}
 		case "config":
-			if err := runConfigShow(); err != nil {
-				ui.Error(err.Error())
+			if err := runSettings(ctx); err != nil {
+				reportError(err)
 			}
 		case "init":
-			if err := runInit(); err != nil {
-				ui.Error(err.Error())
+			if err := runInit(ctx, ""); err != nil {
+				reportError(err)
 			}
 		case "link":
 			repoURL, err := ui.Input("Enter repository URL to link", "git@github.com:username/repo.git")
 			if err != nil {
-				ui.Error(err.Error())
+				reportError(err)
 				continue
 			}
 			if repoURL == "" {
 				ui.Warn("No URL provided, cancelled")
 				continue
 			}
-			if err := runLink(repoURL); err != nil {
-				ui.Error(err.Error())
+			if err := runLink(ctx, repoURL); err != nil {
+				reportError(err)
 			}
 		case "clone":
 			repoURL, err := ui.Input("Enter repository URL to clone", "git@github.com:username/repo.git")
 			if err != nil {
-				ui.Error(err.Error())
+				reportError(err)
 				continue
 			}
-			if err := runClone(repoURL); err != nil {
-				ui.Error(err.Error())
+			if err := runClone(ctx, repoURL); err != nil {
+				reportError(err)
 			}
 		case "doctor":
-			if err := runDoctor(); err != nil {
-				ui.Error(err.Error())
+			if err := runDoctor(ctx); err != nil {
+				reportError(err)
 			}
 		case "key":
 			if err := runKeyMenu(); err != nil {
-				ui.Error(err.Error())
+				reportError(err)
 			}
 		case "rebind":
 			newURL, err := ui.Input("Enter new repository URL", "git@github.com:username/repo.git")
 			if err != nil {
-				ui.Error(err.Error())
+				reportError(err)
 				continue
 			}
 			if newURL == "" {
@@ -263,7 +352,7 @@ func runInteractiveMenu(cfg *config.Config) error {
 				continue
 			}
 			if err := runRebind(newURL); err != nil {
-				ui.Error(err.Error())
+				reportError(err)
 			}
 		case "exit":
 			return nil
@@ -0,0 +1,163 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/GareArc/opencode-sync/internal/config"
+	"github.com/GareArc/opencode-sync/internal/git"
+	"github.com/GareArc/opencode-sync/internal/paths"
+	"github.com/GareArc/opencode-sync/internal/sync"
+	"github.com/GareArc/opencode-sync/internal/ui"
+)
+
+// sandboxDir is the temporary directory used by --sandbox, if any
+var sandboxDir string
+
+// enterSandbox copies the current setup into a temporary directory and
+// points all paths at it. The sync repository is cloned with origin set
+// to a local bare mirror, so pushes never reach the real remote. The
+// copies are on disk rather than in memory because git runs as a separate
+// process; the directory is only readable by the user and leaveSandbox
+// removes it, keys included.
+func enterSandbox(ctx context.Context) error {
+	real, err := paths.Get()
+	if err != nil {
+		return fmt.Errorf("failed to get paths: %w", err)
+	}
+
+	// The OpenCode config dir holds the repo's working tree, which a copy
+	// would share git data with
+	if cfg, err := config.Load(); err == nil && cfg != nil && cfg.Repo.Layout == config.LayoutDirect {
+		return fmt.Errorf("--sandbox is not supported with repo.layout direct")
+	}
+
+	dir, err := os.MkdirTemp("", "opencode-sync-sandbox-")
+	if err != nil {
+		return fmt.Errorf("failed to create sandbox: %w", err)
+	}
+	sandboxDir = dir
+
+	sandbox := &paths.Paths{
+		ConfigDir:         filepath.Join(dir, "config", "opencode-sync"),
+		DataDir:           filepath.Join(dir, "data", "opencode-sync"),
+		OpenCodeConfigDir: filepath.Join(dir, "config", "opencode"),
+		OpenCodeDataDir:   filepath.Join(dir, "data", "opencode"),
+		OpenCodeCacheDir:  filepath.Join(dir, "cache", "opencode"),
+		ClaudeSkillsDir:   filepath.Join(dir, "claude", "skills"),
+		ClaudeDir:         filepath.Join(dir, "claude"),
+	}
+
+	copies := []struct{ src, dst string }{
+		{real.ConfigFile(), sandbox.ConfigFile()},
+		{real.KeyFile(), sandbox.KeyFile()},
+		{real.HostKeyFile(), sandbox.HostKeyFile()},
+		{real.OpenCodeConfigDir, sandbox.OpenCodeConfigDir},
+		{real.OpenCodeAuthFile(), sandbox.OpenCodeAuthFile()},
+		{real.OpenCodeMcpAuthFile(), sandbox.OpenCodeMcpAuthFile()},
+		{real.OpenCodeModelsFile(), sandbox.OpenCodeModelsFile()},
+		{real.ClaudeSkillsDir, sandbox.ClaudeSkillsDir},
+		{real.ClaudeCommandsDir(), sandbox.ClaudeCommandsDir()},
+		{real.ClaudeSettingsFile(), sandbox.ClaudeSettingsFile()},
+		{real.ClaudeMemoryFile(), sandbox.ClaudeMemoryFile()},
+	}
+	// Session history can be large, so only copy it when it is synced
+	if cfg, err := config.Load(); err == nil && cfg != nil && cfg.Sync.IncludeSessions {
+		copies = append(copies, struct{ src, dst string }{real.OpenCodeStorageDir(), sandbox.OpenCodeStorageDir()})
+	}
+	for _, c := range copies {
+		if err := copyTree(c.src, c.dst); err != nil {
+			return fmt.Errorf("failed to copy %s into sandbox: %w", c.src, err)
+		}
+	}
+
+	paths.Override(sandbox)
+
+	if _, err := os.Stat(filepath.Join(real.SyncRepoRoot(), ".git")); err == nil {
+		remote := filepath.Join(dir, "remote.git")
+		if err := git.CloneBare(real.SyncRepoRoot(), remote); err != nil {
+			return fmt.Errorf("failed to mirror sync repository: %w", err)
+		}
+		if err := sync.NewRepo(sandbox).Clone(ctx, remote); err != nil {
+			return err
+		}
+
+		// Point the sandbox config at the mirror as well
+		if cfg, err := config.Load(); err == nil && cfg != nil {
+			cfg.Repo.URL = remote
+			if err := config.Save(cfg); err != nil {
+				return fmt.Errorf("failed to save sandbox config: %w", err)
+			}
+		}
+	}
+
+	ui.Info(fmt.Sprintf("Sandbox mode: running against copies in %s", dir))
+	return nil
+}
+
+// leaveSandbox removes the sandbox directory
+func leaveSandbox() {
+	if sandboxDir == "" {
+		return
+	}
+
+	if err := os.RemoveAll(sandboxDir); err != nil {
+		ui.Warn(fmt.Sprintf("Failed to remove sandbox %s: %v", sandboxDir, err))
+	}
+	sandboxDir = ""
+	paths.Override(nil)
+}
+
+// copyTree copies a file or directory to dst, skipping missing sources
+func copyTree(src, dst string) error {
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		return nil
+	}
+
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+
+		out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, in); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	})
+}
@@ -0,0 +1,328 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/GareArc/opencode-sync/internal/config"
+	"github.com/GareArc/opencode-sync/internal/crypto"
+	"github.com/GareArc/opencode-sync/internal/git"
+	"github.com/GareArc/opencode-sync/internal/paths"
+	"github.com/GareArc/opencode-sync/internal/sync"
+	"github.com/GareArc/opencode-sync/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var selftestKeep bool
+
+// selftestCmd represents the selftest command
+var selftestCmd = &cobra.Command{
+	Use:   "selftest",
+	Short: "Run end-to-end sync scenarios in a temporary sandbox",
+	Long: `Run init, push, clone, pull, encryption and conflict scenarios between two
+simulated machines and a local bare repository, all inside a temporary
+directory. Your real configuration is never touched.
+
+Exits non-zero if any check fails, so it can be used in CI.`,
+	Hidden: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runSelftest(cmd.Context(), selftestKeep)
+	},
+}
+
+func init() {
+	selftestCmd.Flags().BoolVar(&selftestKeep, "keep", false, "keep the temporary directory for inspection")
+}
+
+// selftestMachine is a simulated machine with its own directories
+type selftestMachine struct {
+	paths  *paths.Paths
+	repo   *git.BuiltinGit
+	syncer *sync.Syncer
+}
+
+// newSelftestMachine creates the directories and config for one machine
+func newSelftestMachine(root, name, remote, keyFile string) (*selftestMachine, error) {
+	dir := filepath.Join(root, name)
+	p := &paths.Paths{
+		ConfigDir:         filepath.Join(dir, "config"),
+		DataDir:           filepath.Join(dir, "data"),
+		OpenCodeConfigDir: filepath.Join(dir, "opencode"),
+		OpenCodeDataDir:   filepath.Join(dir, "opencode-data"),
+		OpenCodeCacheDir:  filepath.Join(dir, "opencode-cache"),
+		ClaudeSkillsDir:   filepath.Join(dir, "claude-skills"),
+		ClaudeDir:         filepath.Join(dir, "claude"),
+	}
+	if err := p.EnsureDirs(); err != nil {
+		return nil, err
+	}
+	for _, d := range []string{p.OpenCodeConfigDir, p.OpenCodeDataDir} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	cfg := config.Default()
+	cfg.Repo.URL = remote
+	cfg.Encryption.Enabled = true
+	cfg.Encryption.KeyFile = keyFile
+	cfg.Sync.IncludeAuth = true
+
+	key, err := crypto.LoadKeyFromFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := crypto.NewAgeEncryption(key)
+	if err != nil {
+		return nil, err
+	}
+
+	repo := sync.NewRepo(p)
+	syncer := sync.New(cfg, p, repo)
+	syncer.SetEncryption(enc)
+
+	return &selftestMachine{paths: p, repo: repo, syncer: syncer}, nil
+}
+
+// write creates a file relative to the machine's OpenCode config dir
+func (m *selftestMachine) write(relPath, content string) error {
+	path := filepath.Join(m.paths.OpenCodeConfigDir, relPath)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(content), 0644)
+}
+
+// expect checks a file's content
+func expectFile(path, want string) error {
+	got, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if string(got) != want {
+		return fmt.Errorf("%s: got %q, want %q", filepath.Base(path), got, want)
+	}
+	return nil
+}
+
+// push copies local files into the repo, commits and pushes
+func (m *selftestMachine) push(ctx context.Context, message string) error {
+	if err := m.syncer.CopyToRepo(ctx); err != nil {
+		return err
+	}
+	if err := m.repo.AddAll(); err != nil {
+		return err
+	}
+	if err := m.repo.Commit(message); err != nil {
+		return err
+	}
+	return sync.PushWithRetry(ctx, m.repo)
+}
+
+// pull fetches remote changes and applies them locally
+func (m *selftestMachine) pull(ctx context.Context) error {
+	if err := m.repo.Pull(ctx); err != nil {
+		return err
+	}
+	return m.syncer.CopyFromRepo(ctx)
+}
+
+func runSelftest(ctx context.Context, keep bool) error {
+	root, err := os.MkdirTemp("", "opencode-sync-selftest-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	if keep {
+		ui.Info(fmt.Sprintf("Sandbox: %s", root))
+	} else {
+		defer os.RemoveAll(root)
+	}
+
+	remote := filepath.Join(root, "remote.git")
+	keyFile := filepath.Join(root, "age.key")
+	var a, b *selftestMachine
+
+	checks := []struct {
+		name string
+		run  func() error
+	}{
+		{"Encryption round trip", func() error {
+			keyPair, err := crypto.GenerateKey()
+			if err != nil {
+				return err
+			}
+			if err := crypto.SaveKeyToFile(keyPair.PrivateKey, keyFile); err != nil {
+				return err
+			}
+			key, err := crypto.LoadKeyFromFile(keyFile)
+			if err != nil {
+				return err
+			}
+			enc, err := crypto.NewAgeEncryption(key)
+			if err != nil {
+				return err
+			}
+
+			plaintext := []byte(`{"provider":{"key":"secret"}}`)
+			ciphertext, err := enc.Encrypt(plaintext)
+			if err != nil {
+				return err
+			}
+			if bytes.Contains(ciphertext, []byte("secret")) {
+				return fmt.Errorf("ciphertext contains plaintext")
+			}
+			decrypted, err := enc.Decrypt(ciphertext)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(decrypted, plaintext) {
+				return fmt.Errorf("decrypted content does not match")
+			}
+			return nil
+		}},
+		{"Create local bare repository", func() error {
+			out, err := exec.Command("git", "init", "--bare", "--initial-branch=master", remote).CombinedOutput()
+			if err != nil {
+				return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+			}
+			if a, err = newSelftestMachine(root, "a", remote, keyFile); err != nil {
+				return err
+			}
+			b, err = newSelftestMachine(root, "b", remote, keyFile)
+			return err
+		}},
+		{"Init and push from machine A", func() error {
+			if err := a.write("opencode.json", `{"model":"base","plugin":["shared"]}`); err != nil {
+				return err
+			}
+			if err := a.write("agent/review.md", "# review\n"); err != nil {
+				return err
+			}
+			if err := os.WriteFile(a.paths.OpenCodeAuthFile(), []byte(`{"p":{"key":"secret"}}`), 0600); err != nil {
+				return err
+			}
+
+			if err := a.repo.Init(); err != nil {
+				return err
+			}
+			if err := a.repo.AddRemote("origin", remote); err != nil {
+				return err
+			}
+			installMergeDriver(a.syncer)
+			if err := a.syncer.WriteRepoGitFiles(); err != nil {
+				return err
+			}
+			if err := a.push(ctx, "selftest: init"); err != nil {
+				return err
+			}
+
+			authAge, err := os.ReadFile(filepath.Join(a.paths.SyncRepoDir(), "secrets", "auth.json.age"))
+			if err != nil {
+				return err
+			}
+			if bytes.Contains(authAge, []byte("secret")) {
+				return fmt.Errorf("secrets/auth.json.age contains plaintext")
+			}
+			return nil
+		}},
+		{"Clone and apply on machine B", func() error {
+			if err := b.repo.Clone(ctx, remote); err != nil {
+				return err
+			}
+			installMergeDriver(b.syncer)
+			if err := b.syncer.CopyFromRepo(ctx); err != nil {
+				return err
+			}
+			if err := expectFile(filepath.Join(b.paths.OpenCodeConfigDir, "agent", "review.md"), "# review\n"); err != nil {
+				return err
+			}
+			return expectFile(b.paths.OpenCodeAuthFile(), `{"p":{"key":"secret"}}`)
+		}},
+		{"Pull changes on machine B", func() error {
+			if err := a.write("agent/review.md", "# review v2\n"); err != nil {
+				return err
+			}
+			if err := a.push(ctx, "selftest: update"); err != nil {
+				return err
+			}
+			if err := b.pull(ctx); err != nil {
+				return err
+			}
+			return expectFile(filepath.Join(b.paths.OpenCodeConfigDir, "agent", "review.md"), "# review v2\n")
+		}},
+		{"Merge concurrent pushes", func() error {
+			if err := a.write("opencode.json", `{"model":"base","plugin":["shared","from-a"]}`); err != nil {
+				return err
+			}
+			if err := b.write("opencode.json", `{"model":"base","plugin":["shared","from-b"]}`); err != nil {
+				return err
+			}
+			if err := a.push(ctx, "selftest: plugin a"); err != nil {
+				return err
+			}
+			if err := b.push(ctx, "selftest: plugin b"); err != nil {
+				return err
+			}
+
+			merged, err := os.ReadFile(filepath.Join(b.paths.SyncRepoDir(), "opencode.json"))
+			if err != nil {
+				return err
+			}
+			if !bytes.Contains(merged, []byte("from-a")) || !bytes.Contains(merged, []byte("from-b")) {
+				return fmt.Errorf("merged opencode.json is missing a plugin: %s", merged)
+			}
+			return a.pull(ctx)
+		}},
+		{"Detect conflicting changes", func() error {
+			if err := a.write("opencode.json", `{"model":"a","plugin":["shared"]}`); err != nil {
+				return err
+			}
+			if err := b.write("opencode.json", `{"model":"b","plugin":["shared"]}`); err != nil {
+				return err
+			}
+			if err := a.push(ctx, "selftest: model a"); err != nil {
+				return err
+			}
+
+			err := b.push(ctx, "selftest: model b")
+			var conflict *git.ConflictError
+			if !errors.As(err, &conflict) {
+				return fmt.Errorf("expected a conflict, got %v", err)
+			}
+			return nil
+		}},
+	}
+
+	fmt.Println("\nSelf-test:")
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+
+	failed := 0
+	for i, check := range checks {
+		if failed > 0 {
+			fmt.Printf("- %s (skipped)\n", check.name)
+			continue
+		}
+
+		if err := check.run(); err != nil {
+			failed++
+			ui.Error(fmt.Sprintf("%s: %v", check.name, err))
+			continue
+		}
+
+		ui.Success(fmt.Sprintf("%d/%d %s", i+1, len(checks), check.name))
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("self-test failed")
+	}
+
+	fmt.Println()
+	ui.Success("All checks passed")
+	return nil
+}
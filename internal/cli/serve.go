@@ -0,0 +1,360 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/GareArc/opencode-sync/internal/daemon"
+	"github.com/GareArc/opencode-sync/internal/git"
+	"github.com/GareArc/opencode-sync/internal/paths"
+	"github.com/GareArc/opencode-sync/internal/state"
+	"github.com/GareArc/opencode-sync/internal/sync"
+	"github.com/GareArc/opencode-sync/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// serveSocket is set by 'serve --socket'
+var serveSocket string
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve a JSON-RPC socket for editor integrations",
+	Long: `Serve JSON-RPC 2.0 on a local socket, so editor extensions and plugins
+can show the sync state and run syncs with structured answers.
+
+The socket is control.sock in the data dir unless --socket is given, and
+only you can connect to it. Each request and response is one JSON object
+on its own line. Methods:
+
+  status        channel, layout, pending local changes, conflicts, the
+                last sync, pause, and watch's status while it runs
+  listChanges   repo paths with local changes to push, and uncommitted
+                changes in the sync repo with their status
+  sync          pull and push, through watch while it runs so syncs never
+                overlap
+  resolve       settle merge conflicts with {"side": "ours"|"theirs"},
+                for one {"path"} or all; commits the merge once none are
+                left, after which sync applies it
+
+A failed call returns error code -32000 with the error kind and exit code
+in its data, and the conflicted files for a conflict.
+
+Example:
+  echo '{"jsonrpc":"2.0","id":1,"method":"status"}' | nc -U ~/.local/share/opencode-sync/control.sock`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runServe(cmd.Context(), serveSocket)
+	},
+}
+
+func init() {
+	serveCmd.Flags().StringVar(&serveSocket, "socket", "", "path of the socket (default: control.sock in the data dir)")
+}
+
+// serveStatus is the result of the status method
+type serveStatus struct {
+	Channel         string     `json:"channel,omitempty"`
+	Layout          string     `json:"layout"`
+	Clean           bool       `json:"clean"`
+	MergeInProgress bool       `json:"mergeInProgress"`
+	Pending         []string   `json:"pending"`
+	Conflicts       []string   `json:"conflicts"`
+	LastOperation   string     `json:"lastOperation,omitempty"`
+	LastSync        *time.Time `json:"lastSync,omitempty"`
+	Paused          bool       `json:"paused"`
+	PausedUntil     *time.Time `json:"pausedUntil,omitempty"`
+
+	// Watch is the status of the running watch, if any
+	Watch *daemon.Status `json:"watch,omitempty"`
+}
+
+// serveChange is an uncommitted change in the sync repo
+type serveChange struct {
+	Path    string `json:"path"`
+	Status  string `json:"status"`
+	OldPath string `json:"oldPath,omitempty"`
+}
+
+func runServe(ctx context.Context, socket string) error {
+	noPrompt = true
+	git.NoTerminalPrompt = true
+
+	// Fail early if the setup is incomplete
+	if _, err := initSyncer(); err != nil {
+		return err
+	}
+
+	if socket == "" {
+		p, err := paths.Get()
+		if err != nil {
+			return fmt.Errorf("failed to get paths: %w", err)
+		}
+		socket = p.SocketFile()
+	}
+
+	ln, err := listenSocket(socket)
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+
+	// Syncs and merges change the sync repo, so they run one at a time
+	busy := make(chan struct{}, 1)
+	exclusive := func(method daemon.RPCMethod) daemon.RPCMethod {
+		return func(ctx context.Context, params json.RawMessage) (any, error) {
+			select {
+			case busy <- struct{}{}:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			defer func() { <-busy }()
+			return method(ctx, params)
+		}
+	}
+
+	server := daemon.NewRPCServer(map[string]daemon.RPCMethod{
+		"status":      serveStatusMethod,
+		"listChanges": serveListChanges,
+		"sync":        exclusive(serveSync),
+		"resolve":     exclusive(serveResolve),
+	})
+
+	ui.Info(fmt.Sprintf("Serving JSON-RPC on %s (Ctrl-C to stop)", socket))
+	if err := server.Serve(ctx, ln); err != nil {
+		return fmt.Errorf("failed to serve: %w", err)
+	}
+	ui.Info("Stopping serve")
+	return nil
+}
+
+// listenSocket listens on a Unix socket at path that only the user can
+// connect to, replacing one left behind by a serve that did not exit
+// cleanly. Windows 10 and later support these sockets too.
+func listenSocket(path string) (net.Listener, error) {
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("%s is in use by another 'opencode-sync serve'", path)
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("failed to restrict %s: %w", path, err)
+	}
+	return ln, nil
+}
+
+// serveRepo opens the sync repo for a method
+func serveRepo() (*git.BuiltinGit, error) {
+	p, err := paths.Get()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get paths: %w", err)
+	}
+	repo := sync.NewRepo(p)
+	if err := repo.Open(); err != nil {
+		return nil, fmt.Errorf("failed to open repository: %w", err)
+	}
+	return repo, nil
+}
+
+// rpcFailure describes a failed operation for the client, with the same
+// kinds and exit codes as the command line
+func rpcFailure(err error) error {
+	data := map[string]any{
+		"kind":     errorKind(err),
+		"exitCode": ExitCode(err),
+	}
+	var conflict *git.ConflictError
+	if errors.As(err, &conflict) {
+		data["conflicts"] = conflict.Files
+	}
+	return &daemon.RPCError{Code: daemon.RPCOperationFailed, Message: err.Error(), Data: data}
+}
+
+func serveStatusMethod(ctx context.Context, params json.RawMessage) (any, error) {
+	syncer, err := initSyncer()
+	if err != nil {
+		return nil, rpcFailure(err)
+	}
+	repo, err := serveRepo()
+	if err != nil {
+		return nil, rpcFailure(err)
+	}
+
+	syncState, err := syncer.GetState()
+	if err != nil {
+		return nil, rpcFailure(fmt.Errorf("failed to get state: %w", err))
+	}
+	pending, err := syncer.PendingChanges()
+	if err != nil {
+		return nil, rpcFailure(err)
+	}
+
+	status := serveStatus{
+		Channel:         syncState.Branch,
+		Layout:          "copy",
+		Clean:           syncState.IsClean,
+		MergeInProgress: repo.MergeInProgress(),
+		Pending:         pending,
+		Conflicts:       repo.UnmergedFiles(),
+	}
+	if syncer.Direct() {
+		status.Layout = "direct"
+	}
+	if status.Pending == nil {
+		status.Pending = []string{}
+	}
+	if status.Conflicts == nil {
+		status.Conflicts = []string{}
+	}
+
+	if st, err := state.Load(); err == nil {
+		if op := st.LastOperation; op != nil {
+			status.LastOperation = op.Type
+			status.LastSync = &op.Time
+		}
+		if pause := st.Paused(time.Now()); pause != nil {
+			status.Paused = true
+			if !pause.Until.IsZero() {
+				status.PausedUntil = &pause.Until
+			}
+		}
+	}
+
+	// Best effort: no watch, or one that does not answer, leaves it out
+	watchCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	if resp, err := controlRequest(watchCtx, http.MethodGet, "/api/v1/status"); err == nil {
+		var watch daemon.Status
+		if json.NewDecoder(resp.Body).Decode(&watch) == nil {
+			status.Watch = &watch
+		}
+		resp.Body.Close()
+	}
+
+	return status, nil
+}
+
+func serveListChanges(ctx context.Context, params json.RawMessage) (any, error) {
+	syncer, err := initSyncer()
+	if err != nil {
+		return nil, rpcFailure(err)
+	}
+	repo, err := serveRepo()
+	if err != nil {
+		return nil, rpcFailure(err)
+	}
+
+	pending, err := syncer.PendingChanges()
+	if err != nil {
+		return nil, rpcFailure(err)
+	}
+	changes, err := repo.WorktreeChanges()
+	if err != nil {
+		return nil, rpcFailure(err)
+	}
+
+	result := struct {
+		Pending     []string      `json:"pending"`
+		Uncommitted []serveChange `json:"uncommitted"`
+	}{Pending: pending, Uncommitted: []serveChange{}}
+	if result.Pending == nil {
+		result.Pending = []string{}
+	}
+	for _, change := range changes {
+		result.Uncommitted = append(result.Uncommitted, serveChange{Path: change.Path, Status: change.Status.String(), OldPath: change.OldPath})
+	}
+	return result, nil
+}
+
+// serveSync syncs through the running watch, or in this process if none
+// runs
+func serveSync(ctx context.Context, params json.RawMessage) (any, error) {
+	resp, err := controlRequest(ctx, http.MethodPost, "/api/v1/sync?wait=true")
+	if err == nil {
+		resp.Body.Close()
+		return map[string]any{"ok": true, "via": "watch"}, nil
+	}
+	if !errors.Is(err, errWatchNotRunning) {
+		return nil, rpcFailure(err)
+	}
+
+	if err := runSync(ctx); err != nil {
+		return nil, rpcFailure(err)
+	}
+	return map[string]any{"ok": true, "via": "serve"}, nil
+}
+
+// serveResolve settles merge conflicts by taking one side, and commits
+// the merge once none are left
+func serveResolve(ctx context.Context, params json.RawMessage) (any, error) {
+	var args struct {
+		Path string `json:"path"`
+		Side string `json:"side"`
+	}
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &args); err != nil {
+			return nil, &daemon.RPCError{Code: daemon.RPCInvalidParams, Message: "params must be an object with side and, optionally, path"}
+		}
+	}
+	if args.Side != "ours" && args.Side != "theirs" {
+		return nil, &daemon.RPCError{Code: daemon.RPCInvalidParams, Message: `side must be "ours" or "theirs"`}
+	}
+
+	repo, err := serveRepo()
+	if err != nil {
+		return nil, rpcFailure(err)
+	}
+	if !repo.MergeInProgress() {
+		return nil, rpcFailure(errors.New("no merge in progress"))
+	}
+
+	conflicts := repo.UnmergedFiles()
+	resolve := conflicts
+	if args.Path != "" {
+		if !slices.Contains(conflicts, args.Path) {
+			return nil, &daemon.RPCError{Code: daemon.RPCInvalidParams, Message: fmt.Sprintf("%s has no conflict", args.Path)}
+		}
+		resolve = []string{args.Path}
+	}
+
+	for _, path := range resolve {
+		if err := repo.ResolvePath(path, args.Side); err != nil {
+			return nil, rpcFailure(err)
+		}
+		ui.Info(fmt.Sprintf("Resolved %s with %s", path, args.Side))
+	}
+
+	remaining := repo.UnmergedFiles()
+	committed := false
+	if len(remaining) == 0 {
+		if err := repo.CommitMerge(sync.CommitMessage("Merge remote changes")); err != nil {
+			return nil, rpcFailure(err)
+		}
+		committed = true
+		ui.Success("Merge committed")
+	}
+	if remaining == nil {
+		remaining = []string{}
+	}
+
+	return map[string]any{"resolved": resolve, "remaining": remaining, "committed": committed}, nil
+}
@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/GareArc/opencode-sync/internal/config"
+	"github.com/GareArc/opencode-sync/internal/paths"
+	"github.com/GareArc/opencode-sync/internal/ui"
+)
+
+// runSettings edits the config in a form and saves it once it is valid.
+// A new repository URL or branch on a machine that is already synced is
+// applied like 'rebind' and 'channel switch', so the sync repo follows.
+func runSettings(ctx context.Context) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if cfg == nil {
+		return config.ErrNoConfig
+	}
+
+	edited, err := cloneConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	for {
+		saved, err := ui.SettingsForm(edited)
+		if err != nil {
+			return err
+		}
+		if !saved {
+			ui.Info("Settings not saved")
+			return nil
+		}
+
+		err = edited.Validate()
+		if err == nil {
+			break
+		}
+		ui.Error(fmt.Sprintf("Invalid settings: %v", err))
+		again, err := ui.Confirm("Edit the settings again?", "Your changes are kept")
+		if err != nil {
+			return err
+		}
+		if !again {
+			ui.Info("Settings not saved")
+			return nil
+		}
+	}
+
+	p, err := paths.Get()
+	if err != nil {
+		return fmt.Errorf("failed to get paths: %w", err)
+	}
+
+	// The remote and branch of an existing sync repo change through
+	// rebind and channel switch, which update the config themselves
+	newURL, newBranch := edited.Repo.URL, edited.Repo.Branch
+	synced := syncRepoExists(p)
+	if synced {
+		edited.Repo.URL, edited.Repo.Branch = cfg.Repo.URL, cfg.Repo.Branch
+	}
+
+	if err := config.Save(edited); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	ui.Success("Settings saved")
+
+	if synced && newURL != cfg.Repo.URL {
+		if err := runRebind(newURL); err != nil {
+			return err
+		}
+	}
+	if synced && newBranch != cfg.Repo.Branch {
+		if err := runChannelSwitch(ctx, newBranch, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cloneConfig returns a deep copy of cfg
+func cloneConfig(cfg *config.Config) (*config.Config, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy config: %w", err)
+	}
+	var clone config.Config
+	if err := json.Unmarshal(data, &clone); err != nil {
+		return nil, fmt.Errorf("failed to copy config: %w", err)
+	}
+	return &clone, nil
+}
@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/GareArc/opencode-sync/internal/config"
+	"github.com/GareArc/opencode-sync/internal/git"
+	"github.com/GareArc/opencode-sync/internal/paths"
+	"github.com/GareArc/opencode-sync/internal/sftp"
+	"github.com/GareArc/opencode-sync/internal/snapshot"
+)
+
+func init() {
+	git.RegisterTransport("sftp", openSFTP)
+}
+
+// openSFTP opens the transport for an sftp:// repo.url. The repository is
+// kept there as one bundle encrypted with the repo key, like on WebDAV.
+func openSFTP(rawURL string) (git.Transport, error) {
+	p, err := paths.Get()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get paths: %w", err)
+	}
+
+	enc, err := transportKey(p, "SFTP")
+	if err != nil {
+		return nil, err
+	}
+
+	// sftp honors repo.ssh.hostKeyPolicy like git's ssh does
+	var options []string
+	if cfg, err := config.Load(); err == nil {
+		options = sshOptions(cfg)
+	}
+
+	client, err := sftp.New(rawURL, options)
+	if err != nil {
+		return nil, err
+	}
+	return snapshot.NewTransport(client.Remote(), client, transportMirror(p, rawURL), enc), nil
+}
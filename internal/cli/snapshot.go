@@ -0,0 +1,150 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/GareArc/opencode-sync/internal/sync"
+	"github.com/GareArc/opencode-sync/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// snapshotRestoreOnly is set by 'snapshot restore --only'
+var snapshotRestoreOnly []string
+
+// snapshotCmd represents the snapshot command
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Take and restore local snapshots of your OpenCode config",
+	Long: `Snapshots are tarballs of the local files that sync would push, kept in
+the snapshots directory of the data dir. They never leave this machine, so
+they can undo your own edits even if you never pushed them.
+
+Set backup.auto to hourly, daily or weekly to take them on a schedule:
+watch, pull, push and the OpenCode startup hook take one when it is due,
+whether or not anything syncs. backup.keep (14 by default) and
+backup.keepDays limit how many are kept.
+
+Without a subcommand, lists the snapshots.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runSnapshotList()
+	},
+}
+
+var snapshotListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the snapshots, oldest first",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runSnapshotList()
+	},
+}
+
+var snapshotTakeCmd = &cobra.Command{
+	Use:   "take",
+	Short: "Take a snapshot now",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runSnapshotTake()
+	},
+}
+
+var snapshotRestoreCmd = &cobra.Command{
+	Use:   "restore <name|latest>",
+	Short: "Write the files of a snapshot back to your OpenCode config",
+	Long: `Write the files of a snapshot back to your OpenCode config. A snapshot of
+the current files is taken first, so a restore can be undone the same way.
+Files the snapshot does not have are left alone.
+
+--only restores just the matching repo paths, e.g. --only AGENTS.md or
+--only 'agent/**'. Run 'opencode-sync push' afterwards to sync the result.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runSnapshotRestore(args[0], snapshotRestoreOnly)
+	},
+}
+
+func init() {
+	snapshotRestoreCmd.Flags().StringArrayVar(&snapshotRestoreOnly, "only", nil, "restore only repo paths matching this glob (repeatable)")
+
+	snapshotCmd.AddCommand(snapshotListCmd)
+	snapshotCmd.AddCommand(snapshotTakeCmd)
+	snapshotCmd.AddCommand(snapshotRestoreCmd)
+}
+
+func runSnapshotList() error {
+	syncer, err := initSyncer()
+	if err != nil {
+		return err
+	}
+
+	snapshots, err := syncer.Snapshots()
+	if err != nil {
+		return err
+	}
+	if len(snapshots) == 0 {
+		ui.Info("No snapshots yet. Take one with 'opencode-sync snapshot take', or set backup.auto to take them on a schedule.")
+		return nil
+	}
+
+	for _, snapshot := range snapshots {
+		fmt.Printf("%s  %s  %s\n", snapshot.Name, snapshot.Time.Format("2006-01-02 15:04"), formatBytes(snapshot.Size))
+	}
+	return nil
+}
+
+func runSnapshotTake() error {
+	syncer, err := initSyncer()
+	if err != nil {
+		return err
+	}
+
+	snapshot, err := syncer.TakeSnapshot()
+	if err != nil {
+		return err
+	}
+	ui.Success(fmt.Sprintf("Took snapshot %s (%s)", snapshot.Name, formatBytes(snapshot.Size)))
+	return nil
+}
+
+func runSnapshotRestore(name string, only []string) error {
+	syncer, err := initSyncer()
+	if err != nil {
+		return err
+	}
+
+	snapshot, err := syncer.FindSnapshot(name)
+	if err != nil {
+		return err
+	}
+
+	restored, before, err := syncer.RestoreSnapshot(snapshot, only)
+	if err != nil {
+		return err
+	}
+	if len(restored) == 0 {
+		ui.Warn(fmt.Sprintf("Snapshot %s has no matching files", snapshot.Name))
+		return nil
+	}
+
+	ui.Success(fmt.Sprintf("Restored %d file(s) from snapshot %s", len(restored), snapshot.Name))
+	if verbose {
+		fmt.Println("  " + strings.Join(restored, "\n  "))
+	}
+	ui.Info(fmt.Sprintf("The files before the restore are in snapshot %s. Run 'opencode-sync push' to sync the result.", before.Name))
+	return nil
+}
+
+// autoSnapshot takes a snapshot if backup.auto says one is due. A failure
+// is only reported, as snapshots never hold up syncing.
+func autoSnapshot(syncer *sync.Syncer) {
+	snapshot, err := syncer.AutoSnapshot()
+	if err != nil {
+		ui.Warn(fmt.Sprintf("Failed to take scheduled snapshot: %v", err))
+		return
+	}
+	if snapshot != nil && verbose {
+		ui.Info(fmt.Sprintf("Took scheduled snapshot %s", snapshot.Name))
+	}
+}
@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/GareArc/opencode-sync/internal/config"
+	"github.com/GareArc/opencode-sync/internal/git"
+	"github.com/GareArc/opencode-sync/internal/sync"
+	"github.com/spf13/cobra"
+)
+
+// sshKnownHostsCmd is run by ssh as its KnownHostsCommand when
+// repo.ssh.hostKeyPolicy is "fingerprint". It prints a known_hosts line
+// for the presented key only if it matches the pinned fingerprint.
+var sshKnownHostsCmd = &cobra.Command{
+	Use:           "ssh-known-hosts <fingerprint> <host> <type> <key>",
+	Short:         "Known hosts lookup for pinned SSH host keys",
+	Hidden:        true,
+	Args:          cobra.ExactArgs(4),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fingerprint, err := git.FingerprintSHA256(args[3])
+		if err != nil || fingerprint != args[0] {
+			// ssh reports the unknown host key itself
+			return nil
+		}
+
+		fmt.Printf("%s %s %s\n", args[1], args[2], args[3])
+		return nil
+	},
+}
+
+// sshOptions returns the ssh arguments that enforce
+// repo.ssh.hostKeyPolicy, for sftp and hg
+func sshOptions(cfg *config.Config) []string {
+	exe, _ := os.Executable()
+	return sync.SSHOptions(cfg, exe)
+}
+
+// checkHostKey reports whether the remote's SSH host key passes
+// repo.ssh.hostKeyPolicy. It returns an issue and suggestion for doctor,
+// or empty strings if the key is fine.
+func checkHostKey(ctx context.Context, cfg *config.Config, remoteURL string) (string, string) {
+	var fingerprint string
+	if cfg.Repo.SSH.HostKeyPolicy == config.HostKeyPolicyFingerprint {
+		fingerprint = cfg.Repo.SSH.Fingerprint
+	}
+
+	got, err := git.CheckHostKey(ctx, remoteURL, fingerprint)
+	switch {
+	case err == nil:
+		fmt.Printf("✓ (%s)\n", got)
+		return "", ""
+	case errors.Is(err, git.ErrHostKeyUnknown) && cfg.Repo.SSH.HostKeyPolicy == config.HostKeyPolicyAcceptNew:
+		fmt.Printf("⚠ not yet known, will be accepted on first connection (%s)\n", got)
+		return "", ""
+	case errors.Is(err, git.ErrHostKeyUnknown):
+		fmt.Println("✗ not in known_hosts")
+		return "Remote host key is not trusted yet",
+			fmt.Sprintf("Verify %s with your git host, then run 'opencode-sync config set repo.ssh.fingerprint %s' and 'opencode-sync config set repo.ssh.hostKeyPolicy fingerprint', or add it to ~/.ssh/known_hosts", got, got)
+	case errors.Is(err, git.ErrHostKeyMismatch):
+		fmt.Println("✗ does not match")
+		return fmt.Sprintf("Remote host key does not match: %v", err),
+			"Do not push until you have confirmed the new key with your git host; it may be an impersonation attempt"
+	default:
+		fmt.Println("✗ failed to check")
+		return fmt.Sprintf("Cannot check remote host key: %v", err), "Check network connection"
+	}
+}
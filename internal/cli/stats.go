@@ -0,0 +1,203 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/GareArc/opencode-sync/internal/paths"
+	"github.com/GareArc/opencode-sync/internal/state"
+	"github.com/GareArc/opencode-sync/internal/sync"
+	"github.com/spf13/cobra"
+)
+
+// statsWindow is how far back per-machine sync counts go
+const statsWindow = 30 * 24 * time.Hour
+
+// statsTopFiles is the number of largest files shown
+const statsTopFiles = 5
+
+// statsUsage is set by 'stats --usage'
+var statsUsage bool
+
+// statsCmd represents the stats command
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show sync repository statistics",
+	Long: `Show statistics about the sync repository: synced files by category,
+size on disk, history length, syncs per machine over the last 30 days, the
+largest files and where the time went in the last push and pull.
+
+With --usage, show the usage insights recorded on this machine instead:
+runs and failure rates per command, failures by kind and syncs per day.
+They are only recorded with usage.enabled set, and never leave the data
+dir.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if statsUsage {
+			return runUsageStats()
+		}
+		return runStats()
+	},
+}
+
+func init() {
+	statsCmd.Flags().BoolVar(&statsUsage, "usage", false, "show the usage insights recorded on this machine")
+}
+
+func runStats() error {
+	syncer, err := initSyncer()
+	if err != nil {
+		return err
+	}
+
+	p, err := paths.Get()
+	if err != nil {
+		return fmt.Errorf("failed to get paths: %w", err)
+	}
+
+	repo := sync.NewRepo(p)
+	if err := repo.Open(); err != nil {
+		return fmt.Errorf("failed to open repository: %w", err)
+	}
+
+	files, err := syncer.RepoFiles()
+	if err != nil {
+		return err
+	}
+
+	commits, err := repo.Log()
+	if err != nil {
+		return err
+	}
+
+	repoSize, err := dirSize(p.SyncRepoRoot())
+	if err != nil {
+		return fmt.Errorf("failed to measure repository: %w", err)
+	}
+	historySize, err := dirSize(p.SyncGitDir())
+	if err != nil {
+		return fmt.Errorf("failed to measure repository: %w", err)
+	}
+
+	fmt.Println("\nRepository:")
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Printf("Size on disk:   %s (history: %s)\n", formatBytes(repoSize), formatBytes(historySize))
+	fmt.Printf("Commits:        %d\n", len(commits))
+	if len(commits) > 0 {
+		first := commits[len(commits)-1].Timestamp
+		fmt.Printf("First commit:   %s\n", first.Format("2006-01-02"))
+	}
+
+	fmt.Println("\nFiles by category:")
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	counts := map[string]int{}
+	sizes := map[string]int64{}
+	for _, f := range files {
+		counts[f.Category]++
+		sizes[f.Category] += f.Size
+	}
+	categories := make([]string, 0, len(counts))
+	for category := range counts {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	for _, category := range categories {
+		fmt.Printf("%-15s %5d files  %10s\n", category, counts[category], formatBytes(sizes[category]))
+	}
+	if len(categories) == 0 {
+		fmt.Println("No files synced")
+	}
+
+	fmt.Println("\nSyncs per machine (last 30 days):")
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	since := time.Now().Add(-statsWindow)
+	perHost := map[string]int{}
+	for _, c := range commits {
+		if c.Timestamp.Before(since) {
+			continue
+		}
+		host := sync.CommitHost(c.Message)
+		if host == "" {
+			host = "(unknown)"
+		}
+		perHost[host]++
+	}
+	hosts := make([]string, 0, len(perHost))
+	for host := range perHost {
+		hosts = append(hosts, host)
+	}
+	sort.Slice(hosts, func(i, j int) bool {
+		if perHost[hosts[i]] != perHost[hosts[j]] {
+			return perHost[hosts[i]] > perHost[hosts[j]]
+		}
+		return hosts[i] < hosts[j]
+	})
+	for _, host := range hosts {
+		fmt.Printf("%-30s %5d\n", host, perHost[host])
+	}
+	if len(hosts) == 0 {
+		fmt.Println("No syncs")
+	}
+
+	fmt.Println("\nLargest files:")
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	sort.Slice(files, func(i, j int) bool { return files[i].Size > files[j].Size })
+	for i, f := range files {
+		if i == statsTopFiles {
+			break
+		}
+		fmt.Printf("%10s  %s\n", formatBytes(f.Size), f.Path)
+	}
+
+	fmt.Println("\nLast sync timing:")
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	st, err := state.Load()
+	if err != nil {
+		return err
+	}
+	timed := false
+	for _, op := range []string{state.OpPush, state.OpPull} {
+		if timing := st.Timings[op]; timing != nil {
+			printTiming(fmt.Sprintf("%s (%s)", op, timing.Time.Local().Format("2006-01-02 15:04")), timing)
+			timed = true
+		}
+	}
+	if !timed {
+		fmt.Println("No push or pull timed yet")
+	}
+
+	return nil
+}
+
+// dirSize returns the total size of all files under dir
+func dirSize(dir string) (int64, error) {
+	var total int64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			total += info.Size()
+		}
+		return nil
+	})
+	return total, err
+}
+
+// formatBytes renders a byte count with a binary unit suffix
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,229 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/GareArc/opencode-sync/internal/config"
+	"github.com/GareArc/opencode-sync/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// defaultStatusFile is what 'integrate statusbar' sets sync.statusFile to
+// when it is unset
+const defaultStatusFile = "~/.cache/opencode-sync/status.json"
+
+// waybarSignal is the signal waybar refreshes the module on after a sync
+const waybarSignal = 8
+
+// statusBarTarget is set by 'integrate statusbar --target'
+var statusBarTarget string
+
+// statusBarOutput is set by 'integrate statusbar --output'
+var statusBarOutput string
+
+var integrateStatusBarCmd = &cobra.Command{
+	Use:   "statusbar",
+	Short: "Show sync state in xbar, waybar or polybar",
+	Long: `Write a script for a status bar that shows the sync state at a glance:
+
+  ✓     the last push or pull succeeded and nothing is waiting
+  ↑N    N local changes are waiting to be pushed
+  ⏸     automatic syncing is paused
+  ✗     the last push or pull failed
+
+Clicking it runs 'opencode-sync sync'. The script reads sync.statusFile,
+which is set to ` + defaultStatusFile + ` if unset, so it never runs
+opencode-sync just to draw the bar.
+
+Targets:
+  xbar      installs a plugin into xbar's plugin folder (macOS)
+  waybar    installs a script and prints the module to add to the config
+  polybar   installs a script and prints the module to add to the config
+
+Run again to update the script, for example after moving sync.statusFile,
+or with --remove to delete it.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runIntegrateStatusBar(statusBarTarget, statusBarOutput, integrateForce, integrateRemove)
+	},
+}
+
+func init() {
+	integrateStatusBarCmd.Flags().StringVar(&statusBarTarget, "target", "", "status bar to integrate with: xbar, waybar or polybar")
+	integrateStatusBarCmd.Flags().StringVar(&statusBarOutput, "output", "", "where to write the script (default depends on the target)")
+	integrateStatusBarCmd.Flags().BoolVar(&integrateForce, "force", false, "overwrite a script that was not written by integrate")
+	integrateStatusBarCmd.Flags().BoolVar(&integrateRemove, "remove", false, "remove the installed script")
+	_ = integrateStatusBarCmd.MarkFlagRequired("target")
+
+	integrateCmd.AddCommand(integrateStatusBarCmd)
+}
+
+// statusBarScriptHeader reads sync.statusFile into shell variables and
+// picks a state. The snapshot is indented JSON with one field per line,
+// so sed can read it without jq.
+const statusBarScriptHeader = `#!/bin/sh
+# ` + integrationMarker + ` statusbar. Changes are overwritten when it runs again.
+
+status_file=%s
+opencode_sync=%s
+
+field() {
+  sed -n 's/^ *"'"$1"'": *"\{0,1\}\([^"]*\).*/\1/p' "$status_file" 2>/dev/null | sed 's/,$//' | tr -d '\\' | head -n 1
+}
+
+if [ -f "$status_file" ]; then
+  operation=$(field operation)
+  result=$(field result)
+  time=$(field time)
+  host=$(field host)
+  pending=$(field pendingChanges)
+  paused=$(field paused)
+  error=$(field error)
+fi
+case "$pending" in ''|-*) pending=0 ;; esac
+
+if [ -z "$result" ]; then
+  state=none icon="–" summary="Not synced yet"
+else
+  summary="Last $operation $result at $time on $host"
+  if [ "$result" = failed ]; then
+    state=failed icon="✗"
+  elif [ "$paused" = true ]; then
+    state=paused icon="⏸"
+  elif [ "$pending" -gt 0 ]; then
+    state=pending icon="↑$pending"
+  else
+    state=ok icon="✓"
+  fi
+fi
+`
+
+// statusBarXbar is the rest of the xbar plugin. xbar reruns it every
+// minute, as its file name says, and after "Sync now".
+const statusBarXbar = `
+echo "OC $icon"
+echo "---"
+echo "$summary"
+[ "$pending" -gt 0 ] && echo "$pending local change(s) to push"
+[ "$paused" = true ] && echo "Automatic syncing is paused"
+[ -n "$error" ] && echo "Error: $error"
+echo "---"
+echo "Sync now | bash=\"$opencode_sync\" param1=sync param2=--no-prompt terminal=false refresh=true"
+`
+
+// statusBarWaybar is the rest of the waybar script, which prints the
+// module as JSON
+const statusBarWaybar = `
+tooltip="$summary"
+[ "$pending" -gt 0 ] && tooltip="$tooltip\n$pending local change(s) to push"
+[ "$paused" = true ] && tooltip="$tooltip\nAutomatic syncing is paused"
+[ -n "$error" ] && tooltip="$tooltip\nError: $error"
+tooltip="$tooltip\nClick to sync"
+printf '{"text":"OC %%s","tooltip":"%%s","class":"%%s"}\n' "$icon" "$tooltip" "$state"
+`
+
+// statusBarPolybar is the rest of the polybar script, which colors
+// states that need attention
+const statusBarPolybar = `
+case "$state" in
+  failed) echo "%%{F#e06c75}OC $icon%%{F-}" ;;
+  pending|paused) echo "%%{F#e5c07b}OC $icon%%{F-}" ;;
+  *) echo "OC $icon" ;;
+esac
+`
+
+// statusBarTargets maps each target to its script body and default
+// script path, relative to the user config dir
+var statusBarTargets = map[string]struct {
+	body string
+	path string
+}{
+	"xbar":    {body: statusBarXbar, path: filepath.Join("xbar", "plugins", "opencode-sync.1m.sh")},
+	"waybar":  {body: statusBarWaybar, path: filepath.Join("waybar", "scripts", "opencode-sync.sh")},
+	"polybar": {body: statusBarPolybar, path: filepath.Join("polybar", "scripts", "opencode-sync.sh")},
+}
+
+func runIntegrateStatusBar(target, output string, force, remove bool) error {
+	t, ok := statusBarTargets[target]
+	if !ok {
+		return fmt.Errorf("unknown status bar: %q. Use xbar, waybar or polybar", target)
+	}
+
+	script := output
+	if script == "" {
+		configDir, err := os.UserConfigDir()
+		if err != nil {
+			return fmt.Errorf("failed to get config directory: %w", err)
+		}
+		script = filepath.Join(configDir, t.path)
+	}
+
+	if remove {
+		return installIntegration(script, "", 0755, force, true)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if cfg == nil {
+		return config.ErrNoConfig
+	}
+	if cfg.Sync.StatusFile == "" {
+		cfg.Sync.StatusFile = defaultStatusFile
+		if err := config.Save(cfg); err != nil {
+			return fmt.Errorf("failed to save config: %w", err)
+		}
+		ui.Info(fmt.Sprintf("Set sync.statusFile to %s", defaultStatusFile))
+	}
+	statusFile, err := cfg.Sync.StatusFilePath()
+	if err != nil {
+		return err
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find opencode-sync: %w", err)
+	}
+
+	content := fmt.Sprintf(statusBarScriptHeader+t.body, shellQuote(statusFile), shellQuote(exe))
+	if err := installIntegration(script, content, 0755, force, false); err != nil {
+		return err
+	}
+
+	switch target {
+	case "xbar":
+		ui.Info("Refresh xbar to show it. It updates after every push and pull.")
+	case "waybar":
+		exec, _ := json.Marshal(script)
+		onClick, _ := json.Marshal(fmt.Sprintf("%s sync --no-prompt; pkill -RTMIN+%d waybar", shellQuote(exe), waybarSignal))
+		fmt.Printf(`
+Add this module to your waybar config and "custom/opencode-sync" to a
+modules list, then reload waybar:
+
+"custom/opencode-sync": {
+  "exec": %s,
+  "return-type": "json",
+  "interval": 60,
+  "signal": %d,
+  "on-click": %s
+}
+`, exec, waybarSignal, onClick)
+	case "polybar":
+		fmt.Printf(`
+Add this module to your polybar config and opencode-sync to a modules
+list, then restart polybar:
+
+[module/opencode-sync]
+type = custom/script
+exec = %s
+interval = 60
+click-left = %s sync --no-prompt &
+`, shellQuote(script), shellQuote(exe))
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/GareArc/opencode-sync/internal/config"
+	"github.com/GareArc/opencode-sync/internal/paths"
+	"github.com/GareArc/opencode-sync/internal/sync"
+	"github.com/GareArc/opencode-sync/internal/ui"
+)
+
+// statusSnapshot is what sync.statusFile holds after a push or pull. Its
+// fields are read by status bars and dashboards, so existing ones must not
+// change.
+type statusSnapshot struct {
+	// Operation is "push" or "pull"
+	Operation string `json:"operation"`
+
+	// Result is "ok" or "failed"
+	Result string `json:"result"`
+
+	// Time is when the operation finished
+	Time time.Time `json:"time"`
+
+	// DurationSeconds is how long the operation took
+	DurationSeconds float64 `json:"durationSeconds"`
+
+	Host    string `json:"host"`
+	Channel string `json:"channel,omitempty"`
+
+	// Commit is the sync repo HEAD after the operation
+	Commit string `json:"commit,omitempty"`
+
+	// FilesChanged is the number of repo files the operation changed
+	FilesChanged int `json:"filesChanged"`
+
+	// PendingChanges is the number of local files not yet pushed, or -1
+	// if they could not be counted
+	PendingChanges int `json:"pendingChanges"`
+
+	// UnpushedCommits is the number of commits not yet on the remote, or
+	// -1 if they could not be counted
+	UnpushedCommits int `json:"unpushedCommits"`
+
+	// Paused is set while automatic syncing is paused
+	Paused bool `json:"paused"`
+
+	// Error and ErrorKind describe why the operation failed. ErrorKind is
+	// one of the kinds of 'stats --usage', such as "network".
+	Error     string `json:"error,omitempty"`
+	ErrorKind string `json:"errorKind,omitempty"`
+}
+
+// syncHead returns the sync repo's HEAD, or "" if there is none
+func syncHead() string {
+	p, err := paths.Get()
+	if err != nil {
+		return ""
+	}
+	repo := sync.NewRepo(p)
+	if err := repo.Open(); err != nil {
+		return ""
+	}
+	head, _ := repo.GetHead()
+	return head
+}
+
+// writeStatusFile writes a snapshot of op, which started at start from
+// headBefore and ended with *errp, to sync.statusFile if it is set. It is
+// deferred by push and pull, so it sees their final error. Failing to
+// write it never fails the operation.
+func writeStatusFile(op string, start time.Time, headBefore string, errp *error) {
+	cfg, err := config.Load()
+	if err != nil || cfg == nil || cfg.Sync.StatusFile == "" {
+		return
+	}
+
+	snapshot := statusSnapshot{
+		Operation:       op,
+		Result:          "ok",
+		Time:            time.Now(),
+		DurationSeconds: time.Since(start).Seconds(),
+		Host:            sync.Hostname(),
+		PendingChanges:  -1,
+		UnpushedCommits: -1,
+		Paused:          activePause() != nil,
+	}
+	if *errp != nil {
+		snapshot.Result = "failed"
+		snapshot.Error = (*errp).Error()
+		snapshot.ErrorKind = errorKind(*errp)
+	}
+
+	if p, err := paths.Get(); err == nil {
+		repo := sync.NewRepo(p)
+		if repo.Open() == nil {
+			snapshot.Commit, _ = repo.GetHead()
+			snapshot.Channel, _ = repo.GetBranch()
+			if unpushed, err := repo.UnpushedCommits(); err == nil {
+				snapshot.UnpushedCommits = unpushed
+			}
+			if headBefore != "" && snapshot.Commit != "" && headBefore != snapshot.Commit {
+				if changed, err := repo.ChangedFiles(headBefore, snapshot.Commit); err == nil {
+					snapshot.FilesChanged = len(changed)
+				}
+			}
+		}
+	}
+	if syncer, err := initSyncer(); err == nil {
+		if pending, err := syncer.PendingChanges(); err == nil {
+			snapshot.PendingChanges = len(pending)
+		}
+	}
+
+	if err := saveStatusSnapshot(cfg.Sync, &snapshot); err != nil {
+		ui.Warn(fmt.Sprintf("Failed to write sync.statusFile: %v", err))
+	}
+}
+
+// saveStatusSnapshot writes a snapshot to sync.statusFile atomically, so
+// readers never see half of it
+func saveStatusSnapshot(cfg config.SyncConfig, snapshot *statusSnapshot) error {
+	path, err := cfg.StatusFilePath()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal status: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	return nil
+}
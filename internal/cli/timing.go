@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/GareArc/opencode-sync/internal/state"
+	"github.com/GareArc/opencode-sync/internal/sync"
+	"github.com/GareArc/opencode-sync/internal/ui"
+)
+
+// reportTimings records how long op took, for 'stats', and shows where
+// the time went in verbose mode
+func reportTimings(op string, syncer *sync.Syncer, start time.Time) {
+	timing := &state.Timing{
+		Time:   time.Now(),
+		Total:  time.Since(start),
+		Phases: syncer.Timings().Totals(),
+	}
+
+	if err := state.RecordTiming(op, timing); err != nil {
+		ui.Warn(fmt.Sprintf("Failed to record %s timing: %v", op, err))
+	}
+
+	if verbose {
+		fmt.Println()
+		printTiming(op, timing)
+	}
+}
+
+// printTiming prints the per-phase breakdown of an operation. Time not
+// spent in any timed phase, such as reading config and walking
+// directories, is shown as "other".
+func printTiming(op string, t *state.Timing) {
+	fmt.Printf("%s took %s\n", op, formatDuration(t.Total))
+
+	var timed time.Duration
+	for _, phase := range sync.Phases {
+		d, ok := t.Phases[phase]
+		if !ok {
+			continue
+		}
+		timed += d
+		printPhase(phase, d, t.Total)
+	}
+	if other := t.Total - timed; other > 0 {
+		printPhase("other", other, t.Total)
+	}
+}
+
+// printPhase prints one line of a timing breakdown
+func printPhase(name string, d, total time.Duration) {
+	var percent float64
+	if total > 0 {
+		percent = 100 * float64(d) / float64(total)
+	}
+	fmt.Printf("  %-16s %10s  %5.1f%%\n", name, formatDuration(d), percent)
+}
+
+// formatDuration rounds d to a readable precision
+func formatDuration(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		return d.Round(10 * time.Millisecond).String()
+	case d >= time.Millisecond:
+		return d.Round(100 * time.Microsecond).String()
+	default:
+		return d.Round(time.Microsecond).String()
+	}
+}
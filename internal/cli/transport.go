@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/GareArc/opencode-sync/internal/crypto"
+	"github.com/GareArc/opencode-sync/internal/paths"
+	"github.com/GareArc/opencode-sync/internal/snapshot"
+	"github.com/GareArc/opencode-sync/internal/ui"
+)
+
+// transportKey loads the repo key that snapshots for a kind of remote,
+// such as "WebDAV", are encrypted with
+func transportKey(p *paths.Paths, kind string) (crypto.Encryption, error) {
+	enc, err := crypto.LoadEncryption(p.KeyFile())
+	if errors.Is(err, crypto.ErrKeyMissing) {
+		return nil, fmt.Errorf("%s remotes are encrypted with the repo key; import it with 'opencode-sync key import' first: %w", kind, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load encryption key: %w", err)
+	}
+	return enc, nil
+}
+
+// transportMirror returns where the local mirror of a remote that a
+// transport carries lives
+func transportMirror(p *paths.Paths, rawURL string) string {
+	sum := sha256.Sum256([]byte(rawURL))
+	return filepath.Join(p.DataDir, "mirrors", hex.EncodeToString(sum[:8])+".git")
+}
+
+// reportUploadSize shows how large the snapshot a push uploaded to a
+// WebDAV or SFTP remote was, before and after compression
+func reportUploadSize() {
+	sizes, ok := snapshot.LastUpload()
+	if !ok {
+		return
+	}
+	if sizes.Level == 0 {
+		ui.Info(fmt.Sprintf("Uploaded snapshot: %s, uncompressed", formatBytes(sizes.Raw)))
+		return
+	}
+	ui.Info(fmt.Sprintf("Uploaded snapshot: %s compressed from %s", formatBytes(sizes.Compressed), formatBytes(sizes.Raw)))
+}
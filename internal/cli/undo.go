@@ -0,0 +1,248 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/GareArc/opencode-sync/internal/git"
+	"github.com/GareArc/opencode-sync/internal/integrity"
+	"github.com/GareArc/opencode-sync/internal/paths"
+	"github.com/GareArc/opencode-sync/internal/state"
+	"github.com/GareArc/opencode-sync/internal/sync"
+	"github.com/GareArc/opencode-sync/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var undoForce bool
+
+// undoCmd represents the undo command
+var undoCmd = &cobra.Command{
+	Use:   "undo",
+	Short: "Revert the most recent push or pull",
+	Long: `Revert the most recent sync operation.
+
+After a push, a revert commit is pushed to the remote. With --force, the
+pushed commit is removed from the remote history instead. Local OpenCode
+files are put back the way they were before the push, so the next push
+doesn't publish the undone changes again; they are backed up first.
+
+After a pull, local OpenCode files are restored from the backup taken
+before the pull. The sync repo is left as is, so the next pull will
+re-apply the remote changes unless you push first.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runUndo(cmd.Context(), undoForce)
+	},
+}
+
+func init() {
+	undoCmd.Flags().BoolVar(&undoForce, "force", false, "drop the pushed commit and force push instead of reverting")
+}
+
+func runUndo(ctx context.Context, force bool) error {
+	st, err := state.Load()
+	if err != nil {
+		return err
+	}
+
+	op := st.LastOperation
+	if op == nil {
+		return fmt.Errorf("nothing to undo")
+	}
+
+	ui.Info(fmt.Sprintf("Last operation: %s at %s", op.Type, op.Time.Format("2006-01-02 15:04:05")))
+
+	if !noPrompt {
+		confirmed, err := ui.Confirm(fmt.Sprintf("Undo the last %s?", op.Type), "")
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			ui.Info("Cancelled")
+			return nil
+		}
+	}
+
+	switch op.Type {
+	case state.OpPush:
+		err = undoPush(ctx, op, force)
+	case state.OpPull:
+		err = undoPull(ctx, op)
+	default:
+		err = fmt.Errorf("unknown operation type: %s", op.Type)
+	}
+	if err != nil {
+		return err
+	}
+
+	st.LastOperation = nil
+	if err := state.Save(st); err != nil {
+		ui.Warn(fmt.Sprintf("Failed to clear last operation: %v", err))
+	}
+
+	return nil
+}
+
+func undoPush(ctx context.Context, op *state.Operation, force bool) error {
+	p, err := paths.Get()
+	if err != nil {
+		return fmt.Errorf("failed to get paths: %w", err)
+	}
+
+	repo := sync.NewRepo(p)
+	if err := repo.Open(); err != nil {
+		return err
+	}
+
+	head, err := repo.GetHead()
+	if err != nil {
+		return err
+	}
+	if head != op.HeadAfter {
+		return fmt.Errorf("sync repo has new commits since the last push; nothing was undone")
+	}
+
+	if force {
+		if op.HeadBefore == "" {
+			return fmt.Errorf("the last push created the first commit and cannot be dropped")
+		}
+
+		if err := repo.ResetHard(op.HeadBefore); err != nil {
+			return err
+		}
+
+		if err := ui.SpinnerWithResult("Force pushing to remote", func() error {
+			return repo.ForcePush(ctx)
+		}); err != nil {
+			var rejected *git.RejectedError
+			if errors.As(err, &rejected) {
+				return fmt.Errorf("another machine pushed after your push, so nothing was removed from the remote. Run 'opencode-sync pull' to catch up: %w", err)
+			}
+			return fmt.Errorf("failed to force push: %w", err)
+		}
+
+		logOperation(integrity.OpUndo, op.HeadAfter, op.HeadBefore, true)
+		ui.Success("Pushed commit removed from remote")
+		return restoreUnpushed(ctx, repo, op)
+	}
+
+	// A squashed commit also holds earlier syncs, so only go back to
+	// the replaced commit's content
+	if op.Squashed {
+		err = repo.RestoreTree(op.HeadBefore, sync.CommitMessage(fmt.Sprintf("Undo sync from %s", sync.Hostname())))
+	} else {
+		err = repo.Revert(op.HeadAfter)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Once the local files match the revert, a failed push is finished by
+	// the next one
+	if err := restoreUnpushed(ctx, repo, op); err != nil {
+		return err
+	}
+
+	if err := ui.SpinnerWithResult("Pushing revert to remote", func() error {
+		return sync.PushWithRetry(ctx, repo)
+	}); err != nil {
+		return fmt.Errorf("failed to push: %w", err)
+	}
+
+	headAfter, _ := repo.GetHead()
+	logOperation(integrity.OpUndo, head, headAfter, false)
+	ui.Success("Last push reverted")
+	return nil
+}
+
+// restoreUnpushed applies the files the undone push op changed from the
+// sync repo to the local config, so the local files no longer hold what
+// the push published. Other files are left alone: commits from other
+// machines the push was rebased onto were never pulled here. The direct
+// layout needs nothing, since undoing the push changed the files in place.
+func restoreUnpushed(ctx context.Context, repo *git.BuiltinGit, op *state.Operation) error {
+	syncer, err := initSyncer()
+	if err != nil {
+		return err
+	}
+	if syncer.Direct() {
+		return nil
+	}
+
+	// The first commit of the repo changed everything
+	undone := op.HeadAfter
+	if op.HeadBefore != "" {
+		changed, err := repo.ChangedFiles(op.HeadBefore, undone)
+		if err != nil {
+			return err
+		}
+		renames, err := repo.Renames(op.HeadBefore, undone)
+		if err != nil {
+			return err
+		}
+		for _, change := range renames {
+			changed = append(changed, change.OldPath)
+		}
+		if len(changed) == 0 {
+			return nil
+		}
+
+		only := make([]string, 0, len(changed))
+		for _, relPath := range changed {
+			only = append(only, sync.LiteralPattern(relPath))
+		}
+		if err := syncer.SetOnly(only); err != nil {
+			return err
+		}
+	}
+
+	var backup *sync.Backup
+	if err := ui.SpinnerWithResult("Backing up local config", func() error {
+		var err error
+		backup, err = syncer.BackupLocalSince(undone)
+		return err
+	}); err != nil {
+		return fmt.Errorf("failed to back up local config: %w", err)
+	}
+
+	if err := ui.SpinnerWithResult("Restoring local config from before the push", func() error {
+		if err := syncer.CopyFromRepo(ctx); err != nil {
+			return err
+		}
+		_, err := syncer.RemoveDropped(undone)
+		return err
+	}); err != nil {
+		if restoreErr := syncer.RestoreBackup(backup); restoreErr != nil {
+			return fmt.Errorf("failed to restore local config: %w, and restoring the backup failed: %v", err, restoreErr)
+		}
+		return fmt.Errorf("failed to restore local config: %w; the local files were left as they were", err)
+	}
+
+	ui.Info(fmt.Sprintf("Local files as they were before the undo are in %s", backup.Dir))
+	return nil
+}
+
+func undoPull(ctx context.Context, op *state.Operation) error {
+	if op.BackupDir == "" {
+		return fmt.Errorf("no backup recorded for the last pull")
+	}
+
+	syncer, err := initSyncer()
+	if err != nil {
+		return err
+	}
+
+	backup, err := syncer.LoadBackup(op.BackupDir)
+	if err != nil {
+		return err
+	}
+
+	if err := ui.SpinnerWithResult("Restoring local config from backup", func() error {
+		return syncer.RestoreBackup(backup)
+	}); err != nil {
+		return err
+	}
+
+	ui.Success(fmt.Sprintf("Restored %d file(s), removed %d file(s) added by the pull", len(backup.Files), len(backup.Created)))
+	return nil
+}
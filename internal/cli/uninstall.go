@@ -0,0 +1,596 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/GareArc/opencode-sync/internal/config"
+	"github.com/GareArc/opencode-sync/internal/paths"
+	"github.com/GareArc/opencode-sync/internal/procs"
+	"github.com/GareArc/opencode-sync/internal/sync"
+	"github.com/GareArc/opencode-sync/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// Set by the flags of 'uninstall', which answer its prompts under
+// --no-prompt
+var (
+	uninstallRemoveData   bool
+	uninstallRemoveConfig bool
+	uninstallRemoveKey    bool
+	uninstallKeepBinary   bool
+)
+
+// uninstallCmd represents the uninstall command
+var uninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Uninstall opencode-sync",
+	Long: `Remove opencode-sync and optionally its data.
+
+This will:
+- Stop a running watch
+- Remove systemd units, launchd agents, crontab lines and scheduled tasks
+  of the current user that run opencode-sync
+- Remove the files installed by 'integrate opencode' and 'integrate statusbar'
+- Optionally remove the sync repo and data, and the config
+- Optionally remove the encryption key, asked separately
+- Remove the opencode-sync binary (may require sudo)
+
+Finally it lists what is left, such as shell startup lines that mention
+opencode-sync. Your OpenCode configurations are NOT affected.
+
+With --no-prompt nothing is asked: the data, config and key are only removed
+with --remove-data, --remove-config and --remove-key.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runUninstall()
+	},
+}
+
+func init() {
+	uninstallCmd.Flags().BoolVar(&uninstallRemoveData, "remove-data", false, "also remove the sync repo and data dir")
+	uninstallCmd.Flags().BoolVar(&uninstallRemoveConfig, "remove-config", false, "also remove the config, except the encryption key")
+	uninstallCmd.Flags().BoolVar(&uninstallRemoveKey, "remove-key", false, "also remove the encryption key")
+	uninstallCmd.Flags().BoolVar(&uninstallKeepBinary, "keep-binary", false, "leave the opencode-sync binary in place")
+}
+
+// scheduledJob is an entry of the user's scheduler that runs opencode-sync
+type scheduledJob struct {
+	// kind is the scheduler: systemd, launchd, cron or schtasks
+	kind string
+
+	// name identifies the job to the scheduler: the unit, the agent's
+	// label, the crontab line or the task name
+	name string
+
+	// path is the unit or plist file, if there is one
+	path string
+}
+
+func (j scheduledJob) String() string {
+	if j.path != "" {
+		return fmt.Sprintf("%s %s (%s)", j.kind, j.name, j.path)
+	}
+	return fmt.Sprintf("%s %s", j.kind, j.name)
+}
+
+func runUninstall() error {
+	ui.Warn("This will uninstall opencode-sync from your system.")
+	fmt.Println()
+
+	p, err := paths.Get()
+	if err != nil {
+		return fmt.Errorf("failed to get paths: %w", err)
+	}
+
+	// Read now, for the report at the end
+	cfg, _ := config.Load()
+
+	binaryPath, err := os.Executable()
+	if err != nil {
+		binaryPath = ""
+	}
+	jobs := findScheduledJobs()
+	watchPID := runningWatchPID(p)
+	integrations := installedIntegrations(p)
+
+	fmt.Println("The following will be removed:")
+	if watchPID != 0 {
+		fmt.Printf("  Running watch: PID %d\n", watchPID)
+	}
+	for _, job := range jobs {
+		fmt.Printf("  Schedule: %s\n", job)
+	}
+	for _, path := range integrations {
+		fmt.Printf("  Integration: %s\n", path)
+	}
+	switch {
+	case uninstallKeepBinary:
+	case binaryPath != "":
+		fmt.Printf("  Binary: %s\n", binaryPath)
+	default:
+		fmt.Println("  Binary: opencode-sync (location unknown)")
+	}
+	fmt.Println()
+	fmt.Println("You will be asked about:")
+	fmt.Printf("  Data:   %s\n", p.DataDir)
+	fmt.Printf("  Config: %s\n", p.ConfigDir)
+	fmt.Println()
+	ui.Info("Your OpenCode configurations will NOT be affected.")
+	fmt.Println()
+
+	if dryRun {
+		ui.Info("Dry run, nothing was removed")
+		return nil
+	}
+
+	if !noPrompt {
+		confirmed, err := ui.Confirm("Proceed with uninstall?", "This cannot be undone")
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			ui.Info("Uninstall cancelled")
+			return nil
+		}
+	}
+
+	removeData, removeConfig, removeKey, err := askUninstallData(p)
+	if err != nil {
+		return err
+	}
+
+	// Schedules go first, so a scheduler cannot start the watch again
+	removeScheduledJobs(jobs)
+	if watchPID != 0 {
+		if err := stopProcess(watchPID); err != nil {
+			ui.Warn(fmt.Sprintf("Failed to stop watch (PID %d): %v", watchPID, err))
+		} else {
+			ui.Success(fmt.Sprintf("Stopped watch (PID %d)", watchPID))
+		}
+	}
+
+	for _, path := range integrations {
+		if err := os.Remove(path); err != nil {
+			ui.Warn(fmt.Sprintf("Failed to remove %s: %v", path, err))
+		} else {
+			ui.Success(fmt.Sprintf("Removed: %s", path))
+		}
+	}
+
+	if removeData {
+		removeUninstallPath(p.DataDir)
+	}
+	keyFiles := []string{p.KeyFile(), p.HostKeyFile()}
+	switch {
+	case removeConfig && removeKey:
+		removeUninstallPath(p.ConfigDir)
+	case removeConfig:
+		removeConfigExcept(p.ConfigDir, keyFiles)
+	case removeKey:
+		for _, path := range keyFiles {
+			if _, err := os.Stat(path); err == nil {
+				removeUninstallPath(path)
+			}
+		}
+	}
+
+	if binaryPath != "" && !uninstallKeepBinary {
+		if err := os.Remove(binaryPath); err != nil {
+			if os.IsPermission(err) {
+				ui.Warn("Cannot remove binary (permission denied). Run with sudo or remove manually:")
+				fmt.Printf("  sudo rm %s\n", binaryPath)
+			} else {
+				ui.Warn(fmt.Sprintf("Failed to remove binary: %v", err))
+			}
+		} else {
+			ui.Success(fmt.Sprintf("Removed: %s", binaryPath))
+		}
+	}
+
+	fmt.Println()
+	ui.Success("Uninstall complete!")
+	printUninstallRemains(p, cfg)
+	return nil
+}
+
+// askUninstallData asks whether to remove the data, the config and the
+// key, or takes the answers from the flags under --no-prompt
+func askUninstallData(p *paths.Paths) (removeData, removeConfig, removeKey bool, err error) {
+	if noPrompt {
+		return uninstallRemoveData, uninstallRemoveConfig, uninstallRemoveKey, nil
+	}
+
+	desc := "The local sync repo, snapshots and backups. Your remote is not touched."
+	if warning := unsyncedWarning(p); warning != "" {
+		desc = warning + " " + desc
+	}
+	if removeData, err = ui.Confirm(fmt.Sprintf("Remove the sync data (%s)?", p.DataDir), desc); err != nil {
+		return
+	}
+
+	if removeConfig, err = ui.Confirm(fmt.Sprintf("Remove the config (%s)?", p.ConfigDir), "config.json and the other settings. The encryption key is asked about next."); err != nil {
+		return
+	}
+
+	if _, statErr := os.Stat(p.KeyFile()); statErr != nil {
+		// Without a key there is nothing to ask, but a host key goes with the config
+		return removeData, removeConfig, removeConfig, nil
+	}
+	removeKey, err = ui.Confirm("Also remove the encryption key?",
+		"Without it, the encrypted files in your remote cannot be read. Keep a copy first with 'opencode-sync key export' unless another machine has it.")
+	return
+}
+
+// unsyncedWarning describes changes in the sync repo that are not pushed
+// yet, or returns "" if there are none
+func unsyncedWarning(p *paths.Paths) string {
+	if !syncRepoExists(p) {
+		return ""
+	}
+	repo := sync.NewRepo(p)
+	if err := repo.Open(); err != nil {
+		return ""
+	}
+
+	var pending []string
+	if changed, err := repo.HasChanges(); err == nil && changed {
+		pending = append(pending, "uncommitted changes")
+	}
+	if unpushed, err := repo.UnpushedCommits(); err == nil && unpushed > 0 {
+		pending = append(pending, fmt.Sprintf("%d unpushed commit(s)", unpushed))
+	}
+	if len(pending) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("The sync repo has %s, which would be lost.", strings.Join(pending, " and "))
+}
+
+// removeUninstallPath removes path and everything below it, reporting
+// the outcome
+func removeUninstallPath(path string) {
+	if err := os.RemoveAll(path); err != nil {
+		ui.Warn(fmt.Sprintf("Failed to remove %s: %v", path, err))
+		return
+	}
+	ui.Success(fmt.Sprintf("Removed: %s", path))
+}
+
+// removeConfigExcept removes everything in dir except the paths in keep
+func removeConfigExcept(dir string, keep []string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			ui.Warn(fmt.Sprintf("Failed to read %s: %v", dir, err))
+		}
+		return
+	}
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if slices.Contains(keep, path) {
+			continue
+		}
+		removeUninstallPath(path)
+	}
+	// Only succeeds if nothing was kept
+	_ = os.Remove(dir)
+}
+
+// runningWatchPID returns the PID of a running watch, or 0
+func runningWatchPID(p *paths.Paths) int {
+	info, err := readControlFile(p.ControlFile())
+	if err != nil || info.PID == os.Getpid() || !procs.ProcessAlive(info.PID) {
+		return 0
+	}
+	return info.PID
+}
+
+// stopProcess asks the process to exit, and kills it if it is still
+// running after a few seconds
+func stopProcess(pid int) error {
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	defer proc.Release()
+
+	// Interrupt is not supported on Windows
+	if err := proc.Signal(os.Interrupt); err != nil {
+		return proc.Kill()
+	}
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(100 * time.Millisecond) {
+		if !procs.ProcessAlive(pid) {
+			return nil
+		}
+	}
+	return proc.Kill()
+}
+
+// installedIntegrations returns the files written by 'integrate' that
+// are still in place
+func installedIntegrations(p *paths.Paths) []string {
+	var candidates []string
+	for _, file := range openCodeIntegrations {
+		candidates = append(candidates, filepath.Join(p.OpenCodeConfigDir, file.path))
+	}
+	if configDir, err := os.UserConfigDir(); err == nil {
+		for _, target := range statusBarTargets {
+			candidates = append(candidates, filepath.Join(configDir, target.path))
+		}
+	}
+
+	var installed []string
+	for _, path := range candidates {
+		data, err := os.ReadFile(path)
+		if err == nil && bytes.Contains(data, []byte(integrationMarker)) && !slices.Contains(installed, path) {
+			installed = append(installed, path)
+		}
+	}
+	slices.Sort(installed)
+	return installed
+}
+
+// findScheduledJobs returns the current user's scheduler entries that
+// mention opencode-sync
+func findScheduledJobs() []scheduledJob {
+	switch runtime.GOOS {
+	case "windows":
+		return findWindowsTasks()
+	case "darwin":
+		return append(findLaunchdJobs(), findCronJobs()...)
+	default:
+		return append(findSystemdJobs(), findCronJobs()...)
+	}
+}
+
+// mentionsOpenCodeSync reports whether the file at path mentions
+// opencode-sync
+func mentionsOpenCodeSync(path string) bool {
+	data, err := os.ReadFile(path)
+	return err == nil && bytes.Contains(data, []byte("opencode-sync"))
+}
+
+// systemdUserDir is where systemd looks for the units of the user
+func systemdUserDir() string {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(configDir, "systemd", "user")
+}
+
+func findSystemdJobs() []scheduledJob {
+	dir := systemdUserDir()
+	if dir == "" {
+		return nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var jobs []scheduledJob
+	for _, entry := range entries {
+		name := entry.Name()
+		ext := filepath.Ext(name)
+		if entry.IsDir() || (ext != ".service" && ext != ".timer") {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		// A timer only names its service implicitly, by sharing its name
+		service := filepath.Join(dir, strings.TrimSuffix(name, ext)+".service")
+		if mentionsOpenCodeSync(path) || (ext == ".timer" && mentionsOpenCodeSync(service)) {
+			jobs = append(jobs, scheduledJob{kind: "systemd", name: name, path: path})
+		}
+	}
+
+	// Timers are stopped before the services they start
+	slices.SortStableFunc(jobs, func(a, b scheduledJob) int {
+		return strings.Compare(filepath.Ext(b.name), filepath.Ext(a.name))
+	})
+	return jobs
+}
+
+func findLaunchdJobs() []scheduledJob {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	dir := filepath.Join(home, "Library", "LaunchAgents")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var jobs []scheduledJob
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if filepath.Ext(entry.Name()) == ".plist" && mentionsOpenCodeSync(path) {
+			jobs = append(jobs, scheduledJob{kind: "launchd", name: strings.TrimSuffix(entry.Name(), ".plist"), path: path})
+		}
+	}
+	return jobs
+}
+
+func findCronJobs() []scheduledJob {
+	if _, err := exec.LookPath("crontab"); err != nil {
+		return nil
+	}
+	// Fails when the user has no crontab
+	out, err := exec.Command("crontab", "-l").Output()
+	if err != nil {
+		return nil
+	}
+
+	var jobs []scheduledJob
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "#") && strings.Contains(line, "opencode-sync") {
+			jobs = append(jobs, scheduledJob{kind: "cron", name: line})
+		}
+	}
+	return jobs
+}
+
+func findWindowsTasks() []scheduledJob {
+	out, err := exec.Command("schtasks", "/Query", "/FO", "CSV", "/NH", "/V").Output()
+	if err != nil {
+		return nil
+	}
+
+	reader := csv.NewReader(bytes.NewReader(out))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil
+	}
+
+	// The second column is the task name; a task with several triggers
+	// is listed once per trigger
+	var jobs []scheduledJob
+	var seen []string
+	for _, record := range records {
+		if len(record) < 2 || slices.Contains(seen, record[1]) {
+			continue
+		}
+		if slices.ContainsFunc(record, func(field string) bool { return strings.Contains(field, "opencode-sync") }) {
+			seen = append(seen, record[1])
+			jobs = append(jobs, scheduledJob{kind: "schtasks", name: record[1]})
+		}
+	}
+	return jobs
+}
+
+// removeScheduledJobs disables and removes jobs, reporting each outcome.
+// The commands of the schedulers are best effort: a unit file is removed
+// even if systemctl is not around.
+func removeScheduledJobs(jobs []scheduledJob) {
+	var cronLines []string
+	reloadSystemd := false
+
+	for _, job := range jobs {
+		var err error
+		switch job.kind {
+		case "systemd":
+			_ = exec.Command("systemctl", "--user", "disable", "--now", job.name).Run()
+			err = os.Remove(job.path)
+			reloadSystemd = true
+		case "launchd":
+			_ = exec.Command("launchctl", "unload", "-w", job.path).Run()
+			err = os.Remove(job.path)
+		case "schtasks":
+			if out, cmdErr := exec.Command("schtasks", "/Delete", "/TN", job.name, "/F").CombinedOutput(); cmdErr != nil {
+				err = fmt.Errorf("%w: %s", cmdErr, strings.TrimSpace(string(out)))
+			}
+		case "cron":
+			// Removed together below
+			cronLines = append(cronLines, job.name)
+			continue
+		}
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			ui.Warn(fmt.Sprintf("Failed to remove %s: %v", job, err))
+		} else {
+			ui.Success(fmt.Sprintf("Removed: %s", job))
+		}
+	}
+
+	if reloadSystemd {
+		_ = exec.Command("systemctl", "--user", "daemon-reload").Run()
+	}
+	if len(cronLines) > 0 {
+		if err := removeCronLines(cronLines); err != nil {
+			ui.Warn(fmt.Sprintf("Failed to update crontab: %v. Remove the opencode-sync lines with 'crontab -e'", err))
+		} else {
+			ui.Success(fmt.Sprintf("Removed %d crontab line(s)", len(cronLines)))
+		}
+	}
+}
+
+// removeCronLines installs the user's crontab without the given lines
+func removeCronLines(lines []string) error {
+	out, err := exec.Command("crontab", "-l").Output()
+	if err != nil {
+		return fmt.Errorf("failed to read crontab: %w", err)
+	}
+
+	var kept bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		if !slices.Contains(lines, strings.TrimSpace(scanner.Text())) {
+			kept.WriteString(scanner.Text() + "\n")
+		}
+	}
+
+	cmd := exec.Command("crontab", "-")
+	cmd.Stdin = &kept
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// printUninstallRemains lists what uninstall left in place, so it can be
+// removed by hand
+func printUninstallRemains(p *paths.Paths, cfg *config.Config) {
+	var remains []string
+	for _, dir := range []string{p.DataDir, p.ConfigDir} {
+		if _, err := os.Stat(dir); err == nil {
+			remains = append(remains, dir)
+		}
+	}
+	for _, path := range []string{p.KeyFile(), p.HostKeyFile()} {
+		if _, err := os.Stat(path); err == nil {
+			remains = append(remains, fmt.Sprintf("%s (encryption key)", path))
+		}
+	}
+
+	// Lines added by hand, such as aliases or a 'watch' started at login
+	if home, err := os.UserHomeDir(); err == nil {
+		for _, rc := range []string{".bashrc", ".bash_profile", ".profile", ".zshrc", ".zprofile", filepath.Join(".config", "fish", "config.fish")} {
+			remains = append(remains, mentionsInFile(filepath.Join(home, rc))...)
+		}
+	}
+	remains = append(remains, mentionsInFile(p.OpenCodeConfigFile())...)
+
+	if cfg != nil && cfg.Repo.URL != "" {
+		remains = append(remains, fmt.Sprintf("%s (your remote, with the synced config)", cfg.Repo.URL))
+	}
+
+	if len(remains) == 0 {
+		return
+	}
+	fmt.Println()
+	fmt.Println("Left in place:")
+	for _, remain := range remains {
+		fmt.Printf("  %s\n", remain)
+	}
+}
+
+// mentionsInFile returns path:line for each line of path that mentions
+// opencode-sync
+func mentionsInFile(path string) []string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	var mentions []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		if strings.Contains(scanner.Text(), "opencode-sync") {
+			mentions = append(mentions, fmt.Sprintf("%s:%d: %s", path, n, strings.TrimSpace(scanner.Text())))
+		}
+	}
+	return mentions
+}
@@ -0,0 +1,34 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/GareArc/opencode-sync/internal/config"
+	"github.com/GareArc/opencode-sync/internal/crypto"
+	"github.com/GareArc/opencode-sync/internal/ui"
+)
+
+// keyPassphraseEnv supplies the passphrase of a protected key file
+// without a prompt
+const keyPassphraseEnv = "OPENCODE_SYNC_KEY_PASSPHRASE"
+
+// setupKeySession configures the process-wide key cache: how long an
+// unlocked key is kept, and how its passphrase is asked for
+func setupKeySession(cfg *config.Config) {
+	crypto.DefaultSession.SetUnlockTTL(time.Duration(cfg.Encryption.UnlockMinutes) * time.Minute)
+	crypto.DefaultSession.SetPrompt(keyPassphrase)
+}
+
+// keyPassphrase asks for the passphrase of the key file at path
+func keyPassphrase(path string) (string, error) {
+	if passphrase := os.Getenv(keyPassphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+	if noPrompt {
+		return "", fmt.Errorf("%w: set %s to unlock it without prompts", crypto.ErrKeyLocked, keyPassphraseEnv)
+	}
+
+	return ui.Password("Key passphrase", fmt.Sprintf("Unlocks %s", path))
+}
@@ -0,0 +1,285 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/GareArc/opencode-sync/internal/paths"
+	"github.com/GareArc/opencode-sync/internal/state"
+	"github.com/GareArc/opencode-sync/internal/sync"
+	"github.com/GareArc/opencode-sync/internal/ui"
+	"github.com/charmbracelet/huh"
+	"github.com/spf13/cobra"
+)
+
+// upstreamRemote is the git remote name used for the upstream template
+const upstreamRemote = "upstream"
+
+// upstreamCmd represents the upstream command
+var upstreamCmd = &cobra.Command{
+	Use:   "upstream",
+	Short: "Track and merge a shared template repository",
+	Long: `Subscribe to an upstream template repository, such as a community agent
+pack, and merge its improvements into your config on demand.
+
+Encrypted files, credentials and per-host data from upstream are never
+merged.
+
+Examples:
+  opencode-sync upstream set https://github.com/someone/opencode-agents
+  opencode-sync upstream merge`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runUpstreamShow()
+	},
+}
+
+var upstreamSetCmd = &cobra.Command{
+	Use:   "set <url>",
+	Short: "Set the upstream template repository",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runUpstreamSet(args[0])
+	},
+}
+
+var upstreamMergeCmd = &cobra.Command{
+	Use:   "merge",
+	Short: "Preview and merge upstream changes",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runUpstreamMerge(cmd.Context())
+	},
+}
+
+func init() {
+	upstreamCmd.AddCommand(upstreamSetCmd)
+	upstreamCmd.AddCommand(upstreamMergeCmd)
+}
+
+func runUpstreamShow() error {
+	cfg, err := loadConfigForEdit()
+	if err != nil {
+		return err
+	}
+
+	if cfg.Repo.Upstream == "" {
+		ui.Info("No upstream configured. Set one with 'opencode-sync upstream set <url>'")
+		return nil
+	}
+
+	fmt.Println(cfg.Repo.Upstream)
+	return nil
+}
+
+func runUpstreamSet(url string) error {
+	cfg, err := loadConfigForEdit()
+	if err != nil {
+		return err
+	}
+
+	cfg.Repo.Upstream = url
+	if err := saveValidConfig(cfg); err != nil {
+		return err
+	}
+
+	p, err := paths.Get()
+	if err != nil {
+		return fmt.Errorf("failed to get paths: %w", err)
+	}
+
+	repo := sync.NewRepo(p)
+	if err := repo.Open(); err == nil {
+		if err := repo.SetRemote(upstreamRemote, url); err != nil {
+			return err
+		}
+	}
+
+	ui.Success(fmt.Sprintf("Upstream set to %s", url))
+	ui.Info("Merge its changes with 'opencode-sync upstream merge'")
+	return nil
+}
+
+func runUpstreamMerge(ctx context.Context) error {
+	syncer, err := initSyncer()
+	if err != nil {
+		return err
+	}
+
+	cfg := syncer.Config()
+	if cfg.Repo.Upstream == "" {
+		return fmt.Errorf("no upstream configured. Set one with 'opencode-sync upstream set <url>'")
+	}
+
+	p, err := paths.Get()
+	if err != nil {
+		return fmt.Errorf("failed to get paths: %w", err)
+	}
+
+	repo := sync.NewRepo(p)
+	if err := repo.Open(); err != nil {
+		return err
+	}
+
+	clean, err := repo.IsClean()
+	if err != nil {
+		return fmt.Errorf("failed to check for changes: %w", err)
+	}
+	if !clean {
+		return fmt.Errorf("sync repo has uncommitted changes. Run 'opencode-sync push' first")
+	}
+
+	if err := repo.SetRemote(upstreamRemote, cfg.Repo.Upstream); err != nil {
+		return err
+	}
+
+	if err := ui.SpinnerWithResult("Fetching upstream", func() error {
+		return repo.FetchRemote(ctx, upstreamRemote)
+	}); err != nil {
+		return err
+	}
+
+	headBefore, _ := repo.GetHead()
+
+	conflicts, err := repo.MergeNoCommit(upstreamRemote + "/HEAD")
+	if err != nil {
+		return err
+	}
+
+	if !repo.MergeInProgress() {
+		ui.Info("Already up to date with upstream")
+		return nil
+	}
+
+	// From here on, any early return leaves the repo as it was
+	merged := false
+	defer func() {
+		if !merged {
+			if err := repo.MergeAbort(); err != nil {
+				ui.Warn(err.Error())
+			}
+		}
+	}()
+
+	// Never take secrets or per-host data from upstream
+	staged, _, err := repo.StagedChanges()
+	if err != nil {
+		return err
+	}
+	for _, path := range append(staged, conflicts...) {
+		if sync.IsTemplateSecret(path) {
+			if err := repo.ResolvePath(path, "ours"); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, path := range conflicts {
+		if sync.IsTemplateSecret(path) {
+			continue
+		}
+
+		if noPrompt {
+			return fmt.Errorf("upstream changes conflict with yours in %s", path)
+		}
+
+		choice, err := ui.Select(
+			fmt.Sprintf("Conflict in %s", path),
+			"Both you and upstream changed this file.",
+			[]huh.Option[string]{
+				huh.NewOption("Keep mine", "ours"),
+				huh.NewOption("Take upstream", "theirs"),
+				huh.NewOption("Abort merge", "abort"),
+			},
+		)
+		if err != nil {
+			return err
+		}
+		if choice == "abort" {
+			ui.Info("Merge aborted")
+			return nil
+		}
+
+		if err := repo.ResolvePath(path, choice); err != nil {
+			return err
+		}
+	}
+
+	_, stat, err := repo.StagedChanges()
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(stat) == "" {
+		ui.Info("Already up to date with upstream")
+		return nil
+	}
+
+	fmt.Println("\nUpstream changes:")
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	fmt.Print(stat)
+	fmt.Println()
+
+	if dryRun {
+		ui.Info("Dry run: no changes applied")
+		return nil
+	}
+
+	if !noPrompt {
+		confirmed, err := ui.Confirm("Apply these upstream changes?", "They will be committed, applied to OpenCode and pushed.")
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			ui.Info("Merge cancelled")
+			return nil
+		}
+	}
+
+	if err := repo.CommitMerge(sync.CommitMessage(fmt.Sprintf("Merge upstream %s", cfg.Repo.Upstream))); err != nil {
+		return err
+	}
+	merged = true
+
+	// Avoid racing with OpenCode's own writes
+	if err := checkOpenCodeRunning(cfg.Sync.WhenRunning); err != nil {
+		return err
+	}
+
+	var backup *sync.Backup
+	if err := ui.SpinnerWithResult("Backing up local config", func() error {
+		var err error
+		backup, err = syncer.BackupLocal()
+		return err
+	}); err != nil {
+		return fmt.Errorf("failed to back up local config: %w", err)
+	}
+
+	if err := ui.SpinnerWithResult("Applying changes to OpenCode config", func() error {
+		return syncer.CopyFromRepo(ctx)
+	}); err != nil {
+		return fmt.Errorf("failed to copy files: %w", err)
+	}
+
+	headAfter, _ := repo.GetHead()
+	if err := state.RecordOperation(&state.Operation{
+		Type:       state.OpPull,
+		Time:       time.Now(),
+		HeadBefore: headBefore,
+		HeadAfter:  headAfter,
+		BackupDir:  backup.Dir,
+	}); err != nil {
+		ui.Warn(fmt.Sprintf("Failed to record merge for undo: %v", err))
+	}
+	logOperation(state.OpPull, headBefore, headAfter, false)
+
+	if cfg.Repo.URL != "" {
+		if err := ui.SpinnerWithResult("Pushing to remote", func() error {
+			return sync.PushWithRetry(ctx, repo)
+		}); err != nil {
+			return fmt.Errorf("failed to push: %w", err)
+		}
+	}
+
+	ui.Success("Merged upstream changes")
+	return nil
+}
@@ -0,0 +1,159 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/GareArc/opencode-sync/internal/config"
+	"github.com/GareArc/opencode-sync/internal/paths"
+	"github.com/GareArc/opencode-sync/internal/ui"
+	"github.com/GareArc/opencode-sync/internal/usage"
+	"github.com/spf13/cobra"
+)
+
+// usageDays is the number of days 'stats --usage' shows syncs for
+const usageDays = 14
+
+// syncCommands are the commands counted as syncs
+var syncCommands = map[string]bool{"push": true, "pull": true, "sync": true}
+
+// errorKind names the kind of an error for usage insights, following the
+// exit codes. It never includes the message, which may hold paths or URLs.
+func errorKind(err error) string {
+	switch ExitCode(err) {
+	case ExitOK:
+		return ""
+	case ExitInterrupted:
+		return "interrupted"
+	case ExitNoConfig:
+		return "no config"
+	case ExitKeyMissing:
+		return "key missing"
+	case ExitAuth:
+		return "auth"
+	case ExitNetwork:
+		return "network"
+	case ExitConflict:
+		return "conflict"
+	default:
+		return "other"
+	}
+}
+
+// recordUsage counts a run of cmd that ended with err, if usage insights
+// are enabled. Failing to record never fails the command.
+func recordUsage(cmd *cobra.Command, err error) {
+	if cmd == nil {
+		return
+	}
+	name := strings.TrimPrefix(cmd.CommandPath(), rootCmd.Name())
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "menu"
+	}
+	recordUsageOf(name, syncCommands[name], err)
+}
+
+// recordUsageOf counts a run of the named command, if usage insights are
+// enabled
+func recordUsageOf(name string, sync bool, err error) {
+	cfg, loadErr := config.Load()
+	if loadErr != nil || cfg == nil || !cfg.Usage.Enabled {
+		return
+	}
+
+	if recordErr := usage.Record(name, sync, errorKind(err), time.Now()); recordErr != nil && verbose {
+		ui.Warn(fmt.Sprintf("Failed to record usage: %v", recordErr))
+	}
+}
+
+func runUsageStats() error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	enabled := cfg != nil && cfg.Usage.Enabled
+
+	u, err := usage.Load()
+	if err != nil {
+		return err
+	}
+
+	if len(u.Commands) == 0 {
+		if enabled {
+			ui.Info("Nothing recorded yet")
+		} else {
+			ui.Info("Usage insights are off. Turn them on with 'opencode-sync config set usage.enabled true'; they never leave this machine.")
+		}
+		return nil
+	}
+
+	fmt.Printf("\nUsage since %s:\n", u.Since.Local().Format("2006-01-02"))
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	names := make([]string, 0, len(u.Commands))
+	for name := range u.Commands {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if u.Commands[names[i]].Runs != u.Commands[names[j]].Runs {
+			return u.Commands[names[i]].Runs > u.Commands[names[j]].Runs
+		}
+		return names[i] < names[j]
+	})
+	fmt.Printf("%-20s %6s %9s %6s\n", "Command", "Runs", "Failures", "Rate")
+	for _, name := range names {
+		c := u.Commands[name]
+		fmt.Printf("%-20s %6d %9d %5.0f%%\n", name, c.Runs, c.Failures, c.FailureRate()*100)
+	}
+
+	fmt.Println("\nFailures by kind:")
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	kinds := map[string]int{}
+	var lastFailure time.Time
+	for _, c := range u.Commands {
+		for kind, n := range c.Errors {
+			kinds[kind] += n
+		}
+		if c.LastFailure.After(lastFailure) {
+			lastFailure = c.LastFailure
+		}
+	}
+	if len(kinds) == 0 {
+		fmt.Println("No failures")
+	} else {
+		kindNames := make([]string, 0, len(kinds))
+		for kind := range kinds {
+			kindNames = append(kindNames, kind)
+		}
+		sort.Strings(kindNames)
+		for _, kind := range kindNames {
+			fmt.Printf("%-20s %6d\n", kind, kinds[kind])
+		}
+		fmt.Printf("Last failure: %s\n", lastFailure.Local().Format("2006-01-02 15:04"))
+	}
+
+	fmt.Printf("\nSyncs per day (last %d days):\n", usageDays)
+	fmt.Println("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+	today := time.Now()
+	for i := usageDays - 1; i >= 0; i-- {
+		day := today.AddDate(0, 0, -i).Format("2006-01-02")
+		c := u.Days[day]
+		if c == nil {
+			fmt.Printf("%s %6d\n", day, 0)
+			continue
+		}
+		fmt.Printf("%s %6d  (%d failed)\n", day, c.Runs, c.Failures)
+	}
+
+	if p, err := paths.Get(); err == nil {
+		fmt.Printf("\nRecorded in %s", p.UsageFile())
+		if !enabled {
+			fmt.Print(" (recording is off)")
+		}
+		fmt.Println()
+	}
+
+	return nil
+}
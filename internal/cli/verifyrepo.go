@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/GareArc/opencode-sync/internal/sync"
+	"github.com/GareArc/opencode-sync/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// verifyRepoFormat is set by 'verify-repo --format'
+var verifyRepoFormat string
+
+// verifyRepoCmd represents the verify-repo command
+var verifyRepoCmd = &cobra.Command{
+	Use:   "verify-repo [path]",
+	Short: "Check a checked-out sync repo, e.g. in CI",
+	Long: `Check a clone of a sync repo at path (the current directory by default)
+without changing it. No config, key or OpenCode install is needed, so it
+can run in a CI pipeline of the config repo.
+
+It checks that:
+- the repo has files, laid out the way opencode-sync writes them, without
+  merge conflict markers
+- JSON files parse, and the OpenCode config matches the bundled schema
+- credentials, sessions and host secrets are only stored encrypted, and
+  .age files are age files
+- files are encrypted as recipients.json says, to the right number of
+  keys, and published host keys are valid
+
+Files are never decrypted. The command exits with status 1 if it finds a
+problem. --format json writes the problems for other tools.
+
+Example:
+  opencode-sync verify-repo ./config-repo`,
+	Args: cobra.MaximumNArgs(1),
+	// Finding problems is not a usage error
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runVerifyRepo(optionalArg(args, 0, "."), verifyRepoFormat)
+	},
+}
+
+func init() {
+	verifyRepoCmd.Flags().StringVar(&verifyRepoFormat, "format", "text", "output format: text or json")
+}
+
+func runVerifyRepo(dir, format string) error {
+	if format != "text" && format != "json" {
+		return fmt.Errorf("invalid format %q: use text or json", format)
+	}
+
+	problems, err := sync.VerifyRepo(dir)
+	if err != nil {
+		return err
+	}
+
+	if format == "json" {
+		if problems == nil {
+			problems = []sync.RepoProblem{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(problems); err != nil {
+			return err
+		}
+	} else if len(problems) == 0 {
+		ui.Success(fmt.Sprintf("%s passed all checks", dir))
+	} else {
+		for _, problem := range problems {
+			fmt.Printf("  %-10s %s: %s\n", problem.Check, problem.Path, problem.Reason)
+		}
+		fmt.Println()
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("found %d problem(s) in %s", len(problems), dir)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"github.com/GareArc/opencode-sync/internal/capability"
+	"github.com/spf13/cobra"
+)
+
+// versionJSON is set by 'version --json'
+var versionJSON bool
+
+// versionCmd represents the version command
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print version information",
+	Long: `Print version information.
+
+With --json, print version, commit, build date, Go version, platform and
+the backends and features compiled into this build, for use by package
+managers and support tooling.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runVersion()
+	},
+}
+
+func init() {
+	versionCmd.Flags().BoolVar(&versionJSON, "json", false, "print build metadata and capabilities as JSON")
+}
+
+// versionInfo describes this build of opencode-sync
+type versionInfo struct {
+	Version      string                  `json:"version"`
+	Commit       string                  `json:"commit"`
+	Date         string                  `json:"date"`
+	GoVersion    string                  `json:"goVersion"`
+	Platform     string                  `json:"platform"`
+	Backends     []string                `json:"backends"`
+	Encryption   []string                `json:"encryption"`
+	Capabilities []capability.Capability `json:"capabilities"`
+}
+
+// buildInfo collects version information, falling back to the module
+// metadata embedded by 'go install' when ldflags were not set
+func buildInfo() *versionInfo {
+	info := &versionInfo{
+		Version:      version,
+		Commit:       commit,
+		Date:         date,
+		GoVersion:    runtime.Version(),
+		Platform:     runtime.GOOS + "/" + runtime.GOARCH,
+		Backends:     capability.Names(capability.KindBackend),
+		Encryption:   capability.Names(capability.KindEncryption),
+		Capabilities: capability.All(),
+	}
+
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+
+	if info.Version == "dev" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+		info.Version = strings.TrimPrefix(bi.Main.Version, "v")
+	}
+	for _, s := range bi.Settings {
+		switch {
+		case s.Key == "vcs.revision" && info.Commit == "none":
+			info.Commit = s.Value
+		case s.Key == "vcs.time" && info.Date == "unknown":
+			info.Date = s.Value
+		}
+	}
+
+	return info
+}
+
+func runVersion() error {
+	info := buildInfo()
+
+	if versionJSON {
+		data, err := json.MarshalIndent(info, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode build info: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("opencode-sync %s\n", info.Version)
+	fmt.Printf("  commit: %s\n", info.Commit)
+	fmt.Printf("  built:  %s\n", info.Date)
+	fmt.Printf("  go:     %s (%s)\n", info.GoVersion, info.Platform)
+	return nil
+}
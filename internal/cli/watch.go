@@ -0,0 +1,629 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"time"
+
+	"github.com/GareArc/opencode-sync/internal/config"
+	"github.com/GareArc/opencode-sync/internal/daemon"
+	"github.com/GareArc/opencode-sync/internal/fswatch"
+	"github.com/GareArc/opencode-sync/internal/git"
+	"github.com/GareArc/opencode-sync/internal/netstate"
+	"github.com/GareArc/opencode-sync/internal/paths"
+	"github.com/GareArc/opencode-sync/internal/power"
+	"github.com/GareArc/opencode-sync/internal/state"
+	"github.com/GareArc/opencode-sync/internal/sync"
+	"github.com/GareArc/opencode-sync/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	watchInterval time.Duration
+	watchListen   string
+	watchTwoWay   bool
+)
+
+// watchCmd represents the watch command
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Continuously sync in the background",
+	Long: `Run a sync (pull then push) on a fixed interval until interrupted.
+
+Watch stops with an error when a sync fails in a way that needs you to act:
+missing configuration or key, or conflicting changes. Network and
+authentication failures are retried on the next interval.
+
+With --two-way, the OpenCode config and the sync repo are watched for
+changes, and edits on either side are synced within seconds: local edits
+are pushed, and files another tool writes into the sync repo are committed
+and applied to the OpenCode config. The remote is still checked every
+--interval. Each cycle records a manifest of the sync repo, so the writes
+watch makes itself are recognized and do not trigger another sync. When a
+file changed on both sides, the local version wins and the repo version is
+kept in history. Deletions are not synced, as with push and pull.
+
+Files can be pushed more or less often than the rest with
+sync.targets.<name>.interval, where <name> matches repo paths like
+sync.hostSecrets. A target is synced at most once per its interval, and
+its changes are pushed as soon as watch notices with an interval of 0, so
+e.g. auth tokens go out within seconds while themes batch up for hours:
+  opencode-sync config set sync.targets.auth.json.interval 0
+  opencode-sync config set sync.targets.themes.interval 6h
+Watch checks for due targets as often as the shortest interval needs, but
+not more than every 10 seconds. With --two-way, files no target covers are
+synced as they change.
+
+While 'opencode-sync pause' is in effect, watch keeps running but skips
+its syncs. To save battery and network, watch can also be told to:
+  wait watch.debounceSeconds (default 2) after the last file change
+  before a --two-way sync, so a burst of writes becomes one sync
+  make no push within watch.minPushMinutes of its last one
+  skip syncs during watch.quietHours, a local time range such as
+  00:00-07:00
+  skip syncs while running on battery with less charge than
+  watch.minBatteryPercent
+Syncs also wait while the machine is offline, and on connections the
+platform reports as metered (NetworkManager on Linux, Windows) unless
+sync.network.allowMetered is set. Watch catches up within seconds of the
+network coming back.
+
+Risky pulled changes, such as changed providers or a replaced auth.json,
+are held back until you run 'opencode-sync pull --review', unless
+watch.skipReview is set.
+
+With --listen, a local HTTP endpoint is exposed for monitoring:
+  /healthz  returns 200 while the last sync succeeded, 503 otherwise
+  /metrics  Prometheus-style counters and gauges
+
+Watch also serves a control API there, or on a random loopback port
+without --listen, so scripts and editors can make it sync now, pause or
+resume, and read its status and output. See 'opencode-sync ctl --help'.
+
+Example:
+  opencode-sync watch --interval 10m --listen 127.0.0.1:9477
+  opencode-sync watch --two-way --interval 2m`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWatch(cmd.Context(), watchInterval, watchListen, watchTwoWay)
+	},
+}
+
+func init() {
+	watchCmd.Flags().DurationVar(&watchInterval, "interval", 5*time.Minute, "time between sync attempts")
+	watchCmd.Flags().StringVar(&watchListen, "listen", "", "address for /healthz and /metrics (e.g. 127.0.0.1:9477)")
+	watchCmd.Flags().BoolVar(&watchTwoWay, "two-way", false, "sync file changes in the OpenCode config and sync repo as they happen")
+}
+
+func runWatch(ctx context.Context, interval time.Duration, listen string, twoWay bool) error {
+	if interval <= 0 {
+		return fmt.Errorf("interval must be positive")
+	}
+
+	// Nobody is at the terminal to answer a credential prompt
+	git.NoTerminalPrompt = true
+
+	// Fail early if the setup is incomplete
+	syncer, err := initSyncer()
+	if err != nil {
+		return err
+	}
+	if twoWay && syncer.Direct() {
+		return fmt.Errorf("--two-way is not needed with repo.layout direct: the OpenCode config is the sync repo")
+	}
+
+	metrics := daemon.NewMetrics()
+
+	if listen != "" {
+		if host, _, err := net.SplitHostPort(listen); err == nil {
+			if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+				ui.Warn(fmt.Sprintf("Metrics endpoint %s is not bound to loopback", listen))
+			}
+		}
+	}
+
+	mode := "interval"
+	if twoWay {
+		mode = "two-way"
+	}
+	api, stopControl, err := startControl(listen, metrics, mode)
+	if err != nil {
+		return err
+	}
+	defer stopControl()
+
+	if listen != "" {
+		ui.Info(fmt.Sprintf("Serving /healthz and /metrics on http://%s", listen))
+	}
+
+	targets := syncer.TargetIntervals()
+	if len(targets) > 0 && syncer.Direct() {
+		ui.Warn("sync.targets is ignored with repo.layout direct")
+		targets = nil
+	}
+
+	watchCfg := syncer.Config().Watch
+	networkCfg := syncer.Config().Sync.Network
+
+	// Nobody is there to confirm risky pulled changes either
+	pullHold = !watchCfg.SkipReview
+
+	if twoWay {
+		schedule := daemon.NewSchedule(0, targets)
+		schedule.SetMinPush(watchCfg.MinPushInterval())
+		return runWatchTwoWay(ctx, syncer, interval, schedule, watchCfg, networkCfg, metrics, api)
+	}
+
+	schedule := daemon.NewSchedule(interval, targets)
+	schedule.SetMinPush(watchCfg.MinPushInterval())
+	tick := schedule.Tick()
+	if schedule.Targets() {
+		ui.Info(fmt.Sprintf("Watching for changes every %v, checking sync targets every %v (Ctrl-C to stop)", interval, tick))
+	} else {
+		ui.Info(fmt.Sprintf("Watching for changes every %v (Ctrl-C to stop)", interval))
+	}
+
+	// Ticks fall on a grid from now, so an interval that is a multiple of
+	// the tick is due on the tick it ends on
+	now := time.Now()
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	paused, quiet, network, battery := false, false, "", false
+	var requested chan error
+	for {
+		// Snapshots are taken even while syncs are held back
+		autoSnapshot(syncer)
+
+		// Syncs held back by the network or battery are retried once
+		// they allow it
+		var retry <-chan time.Time
+		_, skip := quietNow(watchCfg, &quiet)
+		if !skip && networkHeld(networkCfg, &network) {
+			skip, retry = true, time.After(networkRecheck)
+		} else if !skip && batteryLow(watchCfg, &battery) {
+			skip, retry = true, time.After(batteryRecheck)
+		}
+
+		if requested != nil {
+			err := requestedSync(ctx, metrics, requested, func() error { return runSync(ctx) })
+			requested = nil
+			if ctx.Err() != nil {
+				ui.Info("Stopping watch")
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if pending, err := syncer.PendingChanges(); err == nil {
+				metrics.SetPendingChanges(len(pending))
+			}
+		} else if !pausedNow(&paused) && !skip {
+			if pending, err := syncer.PendingChanges(); err == nil {
+				metrics.SetPendingChanges(len(pending))
+			}
+
+			metrics.SetSyncing(true)
+			synced, err := scheduledSync(ctx, syncer, schedule, now)
+			metrics.SetSyncing(false)
+			if synced {
+				metrics.RecordSync(err)
+				recordUsageOf("watch sync", true, err)
+			}
+			if ctx.Err() != nil {
+				ui.Info("Stopping watch")
+				return nil
+			}
+			if err != nil && needsUser(err) {
+				return fmt.Errorf("stopping watch: %w", err)
+			}
+			if err != nil {
+				reportError(err)
+			} else if pending, err := syncer.PendingChanges(); err == nil {
+				metrics.SetPendingChanges(len(pending))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			ui.Info("Stopping watch")
+			return nil
+		case now = <-ticker.C:
+		case <-retry:
+			now = time.Now()
+		case requested = <-api.SyncRequests():
+		case <-api.Wake():
+			now = time.Now()
+		}
+	}
+}
+
+// requestedSync runs a sync asked for through the control API, whatever
+// holds automatic syncs back, and sends its result to done. It returns an
+// error when watch has to stop.
+func requestedSync(ctx context.Context, metrics *daemon.Metrics, done chan<- error, run func() error) error {
+	ui.Info("Sync requested")
+	metrics.SetSyncing(true)
+	err := run()
+	metrics.SetSyncing(false)
+
+	metrics.RecordSync(err)
+	recordUsageOf("watch sync", true, err)
+	done <- err
+
+	if err != nil && needsUser(err) {
+		return fmt.Errorf("stopping watch: %w", err)
+	}
+	if err != nil && ctx.Err() == nil {
+		reportError(err)
+	}
+	return nil
+}
+
+// scheduledSync runs a sync when the files no target covers are due, and
+// otherwise pushes the changes to the targets that are due. It reports
+// whether a sync ran.
+func scheduledSync(ctx context.Context, syncer *sync.Syncer, schedule *daemon.Schedule, now time.Time) (bool, error) {
+	if schedule.PushWait(now) > 0 {
+		return false, nil
+	}
+
+	due := schedule.Due(now)
+	full := due == nil || due[""]
+
+	pending, err := syncer.DuePending(due)
+	if err != nil && !full {
+		return false, err
+	}
+	if !full && len(pending) == 0 {
+		return false, nil
+	}
+
+	// Targets that are not due are neither pulled nor pushed, so the pull
+	// doesn't overwrite their local changes
+	syncDue = due
+	defer func() { syncDue = nil }()
+
+	if full {
+		err = runSync(ctx)
+	} else {
+		err = runPush(ctx)
+	}
+	if err != nil {
+		return true, err
+	}
+
+	pushed := pushedTargets(syncer, pending)
+	if full {
+		pushed = append(pushed, "")
+	}
+	schedule.Pushed(pushed, now)
+	return true, nil
+}
+
+// quietNow reports whether watch.quietHours are in effect and until when,
+// announcing when they start and end
+func quietNow(cfg config.WatchConfig, wasQuiet *bool) (time.Time, bool) {
+	until, quiet := cfg.QuietUntil(time.Now())
+	switch {
+	case quiet && !*wasQuiet:
+		ui.Info(fmt.Sprintf("Quiet hours until %s; skipping syncs", until.Format("15:04")))
+	case !quiet && *wasQuiet:
+		ui.Info("Quiet hours are over; syncing resumed")
+	}
+
+	*wasQuiet = quiet
+	return until, quiet
+}
+
+// networkRecheck is how often watch checks whether the network is back
+// while it holds back syncs
+const networkRecheck = 15 * time.Second
+
+// networkHeld reports whether syncs wait for the network: while offline,
+// and on a metered connection unless sync.network.allowMetered is set. It
+// announces when the reason changes, recording it in held.
+func networkHeld(cfg config.NetworkConfig, held *string) bool {
+	state := netstate.Current()
+
+	reason := ""
+	switch {
+	case !state.Online:
+		reason = "Offline; syncs wait for the network"
+	case state.Metered && !cfg.AllowMetered:
+		reason = "On a metered connection; syncs wait (set sync.network.allowMetered to sync anyway)"
+	}
+
+	switch {
+	case reason != "" && reason != *held:
+		ui.Info(reason)
+	case reason == "" && *held != "":
+		ui.Info("Network is back; catching up")
+	}
+
+	*held = reason
+	return reason != ""
+}
+
+// batteryRecheck is how often watch checks the battery while it holds
+// back syncs
+const batteryRecheck = time.Minute
+
+// batteryLow reports whether the machine runs on battery with less charge
+// than watch.minBatteryPercent, announcing when that starts and ends
+func batteryLow(cfg config.WatchConfig, wasLow *bool) bool {
+	low := false
+	battery, ok := power.Status()
+	if ok && cfg.MinBatteryPercent > 0 {
+		low = battery.Discharging && battery.Percent < cfg.MinBatteryPercent
+	}
+
+	switch {
+	case low && !*wasLow:
+		ui.Info(fmt.Sprintf("On battery at %d%%; syncs wait until charging or above %d%%", battery.Percent, cfg.MinBatteryPercent))
+	case !low && *wasLow:
+		ui.Info("Battery is fine again; catching up")
+	}
+
+	*wasLow = low
+	return low
+}
+
+// pushedTargets returns the targets the pushed repo paths belong to
+func pushedTargets(syncer *sync.Syncer, relPaths []string) []string {
+	var targets []string
+	for _, relPath := range relPaths {
+		if target := syncer.Target(relPath); !slices.Contains(targets, target) {
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
+
+// runWatchTwoWay syncs after file changes settle and checks the remote
+// every interval
+func runWatchTwoWay(ctx context.Context, syncer *sync.Syncer, interval time.Duration, schedule *daemon.Schedule, watchCfg config.WatchConfig, networkCfg config.NetworkConfig, metrics *daemon.Metrics, api *daemon.API) error {
+	roots, skip := syncer.WatchPaths()
+	watcher, err := fswatch.New(roots, skip)
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	ui.Info(fmt.Sprintf("Watching %d directories with %s, checking the remote every %v (Ctrl-C to stop)", len(roots), fswatch.Method, interval))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// Targets with their own interval are checked on their own ticker
+	var targetTicks <-chan time.Time
+	if schedule.Targets() {
+		targetTicker := time.NewTicker(schedule.Tick())
+		defer targetTicker.Stop()
+		targetTicks = targetTicker.C
+	}
+
+	// The first cycle also checks the remote. Edits made while paused
+	// are synced by the first cycle after resuming.
+	remote, paused, quiet, network, battery := true, false, false, "", false
+	var requested chan error
+	for {
+		autoSnapshot(syncer)
+
+		// A sync held back by quiet hours, the network, the battery or
+		// watch.minPushMinutes is retried once they allow it
+		var retry <-chan time.Time
+		until, skip := quietNow(watchCfg, &quiet)
+		if skip {
+			retry = time.After(time.Until(until))
+		} else if networkHeld(networkCfg, &network) {
+			skip, retry = true, time.After(networkRecheck)
+		} else if batteryLow(watchCfg, &battery) {
+			skip, retry = true, time.After(batteryRecheck)
+		}
+
+		held := skip || pausedNow(&paused)
+		if requested != nil {
+			err := requestedSync(ctx, metrics, requested, func() error {
+				_, err := twoWayCycle(ctx, true, schedule)
+				return err
+			})
+			requested, remote = nil, false
+			if ctx.Err() != nil {
+				ui.Info("Stopping watch")
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		} else if !held {
+			metrics.SetSyncing(true)
+			synced, err := twoWayCycle(ctx, remote, schedule)
+			metrics.SetSyncing(false)
+			if ctx.Err() != nil {
+				ui.Info("Stopping watch")
+				return nil
+			}
+			if synced {
+				metrics.RecordSync(err)
+				recordUsageOf("watch sync", true, err)
+			}
+			if err != nil && needsUser(err) {
+				return fmt.Errorf("stopping watch: %w", err)
+			}
+			if err != nil {
+				reportError(err)
+			}
+		}
+		if pending, err := syncer.PendingChanges(); err == nil {
+			metrics.SetPendingChanges(len(pending))
+		}
+		if wait := schedule.PushWait(time.Now()); wait > 0 && retry == nil {
+			retry = time.After(wait)
+		}
+
+		// A remote check that was held back is made once possible
+		remote = remote && held
+		var settle <-chan time.Time
+	wait:
+		for {
+			select {
+			case <-ctx.Done():
+				ui.Info("Stopping watch")
+				return nil
+			case <-watcher.Events:
+				settle = time.After(watchCfg.Debounce())
+			case <-settle:
+				break wait
+			case <-retry:
+				break wait
+			case <-ticker.C:
+				remote = true
+				break wait
+			case <-targetTicks:
+				break wait
+			case requested = <-api.SyncRequests():
+				break wait
+			case <-api.Wake():
+				break wait
+			}
+		}
+	}
+}
+
+// twoWayCycle brings the OpenCode config, the sync repo and the remote up
+// to date with each other. Changes are found by comparing both sides with
+// the manifest the previous cycle recorded, so files watch wrote itself
+// are not changes. Local edits to targets that are not due, or made within
+// watch.minPushMinutes of the last push, wait for a later cycle. Without
+// remote, nothing is done unless a file changed. It reports whether a sync
+// ran.
+func twoWayCycle(ctx context.Context, remote bool, schedule *daemon.Schedule) (bool, error) {
+	syncer, err := initSyncer()
+	if err != nil {
+		return false, err
+	}
+
+	p, _ := paths.Get()
+	repo := sync.NewRepo(p)
+	if err := repo.Open(); err != nil {
+		return false, fmt.Errorf("failed to open repository: %w", err)
+	}
+
+	st, err := state.Load()
+	if err != nil {
+		return false, err
+	}
+	manifest := st.Manifest
+	if manifest == nil {
+		if manifest, err = syncer.HeadManifest(); err != nil {
+			return false, err
+		}
+	}
+
+	current, err := syncer.RepoManifest()
+	if err != nil {
+		return false, err
+	}
+	repoEdits := sync.RepoEdits(manifest, current)
+	localEdits, err := syncer.LocalEdits(manifest)
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	due := schedule.Due(now)
+	var dueEdits []string
+	for _, relPath := range localEdits {
+		if due == nil || due[syncer.Target(relPath)] {
+			dueEdits = append(dueEdits, relPath)
+		}
+	}
+
+	if !remote && len(repoEdits) == 0 && len(dueEdits) == 0 {
+		return false, nil
+	}
+	if len(dueEdits) > 0 && schedule.PushWait(now) > 0 {
+		// Held back by watch.minPushMinutes; a pull now would overwrite
+		// the local edits
+		return false, nil
+	}
+
+	if len(repoEdits) > 0 {
+		if err := applyRepoEdits(ctx, syncer, repo, repoEdits, localEdits); err != nil {
+			return true, err
+		}
+	}
+
+	// Push first: a pull would overwrite local edits. A push that had to
+	// rebase brought remote commits along, so always pull afterwards.
+	syncDue = due
+	defer func() { syncDue = nil }()
+
+	if err := runPush(ctx); err != nil {
+		return true, fmt.Errorf("push failed: %w", err)
+	}
+	schedule.Pushed(pushedTargets(syncer, dueEdits), now)
+	if err := recordManifest(syncer); err != nil {
+		return true, err
+	}
+
+	// Targets that are not due are not pulled either, so their local
+	// edits are kept until they are pushed
+	if err := runPull(ctx); err != nil {
+		return true, fmt.Errorf("pull failed: %w", err)
+	}
+	return true, recordManifest(syncer)
+}
+
+// applyRepoEdits commits files changed directly in the sync repo and
+// applies them to the OpenCode config. Where the local copy changed too,
+// the local version wins and the repo version stays in history.
+func applyRepoEdits(ctx context.Context, syncer *sync.Syncer, repo *git.BuiltinGit, repoEdits, localEdits []string) error {
+	hasChanges, err := repo.HasChanges()
+	if err != nil {
+		return fmt.Errorf("failed to check for changes: %w", err)
+	}
+	if hasChanges {
+		if err := repo.AddAll(); err != nil {
+			return fmt.Errorf("failed to stage changes: %w", err)
+		}
+		now := time.Now()
+		if _, err := sync.CommitSync(repo, fmt.Sprintf("Edit in sync repo on %s at %s", sync.Hostname(), now.Format("2006-01-02 15:04:05")), false, now); err != nil {
+			return fmt.Errorf("failed to commit: %w", err)
+		}
+	}
+
+	var apply []string
+	for _, relPath := range repoEdits {
+		if slices.Contains(localEdits, relPath) {
+			ui.Warn(fmt.Sprintf("%s changed both locally and in the sync repo; keeping the local version", relPath))
+			continue
+		}
+		apply = append(apply, sync.LiteralPattern(relPath))
+	}
+	if len(apply) == 0 {
+		return nil
+	}
+
+	ui.Info(fmt.Sprintf("Applying %d file(s) changed in the sync repo", len(apply)))
+	if err := syncer.SetOnly(apply); err != nil {
+		return err
+	}
+
+	head, _ := repo.GetHead()
+	return applyPulled(ctx, syncer, repo, head, "watch --two-way")
+}
+
+// recordManifest stores the sync repo manifest for the next two-way cycle
+func recordManifest(syncer *sync.Syncer) error {
+	manifest, err := syncer.RepoManifest()
+	if err != nil {
+		return err
+	}
+	if err := state.RecordManifest(manifest); err != nil {
+		return fmt.Errorf("failed to record manifest: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/GareArc/opencode-sync/internal/git"
+	"github.com/GareArc/opencode-sync/internal/paths"
+	"github.com/GareArc/opencode-sync/internal/snapshot"
+	"github.com/GareArc/opencode-sync/internal/webdav"
+)
+
+// webdavPasswordEnv holds the WebDAV password or app password when no git
+// credential helper has one stored
+const webdavPasswordEnv = "OPENCODE_SYNC_WEBDAV_PASSWORD"
+
+func init() {
+	git.RegisterTransport("davs", openWebDAV)
+	git.RegisterTransport("dav", openWebDAV)
+}
+
+// openWebDAV opens the transport for a davs:// or dav:// repo.url. The
+// repository is kept there as one bundle encrypted with the repo key.
+func openWebDAV(rawURL string) (git.Transport, error) {
+	folder, username, err := webdav.FolderURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := paths.Get()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get paths: %w", err)
+	}
+
+	enc, err := transportKey(p, "WebDAV")
+	if err != nil {
+		return nil, err
+	}
+
+	password := os.Getenv(webdavPasswordEnv)
+	if password == "" {
+		user, stored, err := git.StoredCredential(folder.Scheme, folder.Host, username)
+		if err != nil {
+			return nil, err
+		}
+		if stored != "" {
+			username, password = user, stored
+		}
+	}
+
+	remote := rawURL
+	if u, err := url.Parse(rawURL); err == nil {
+		remote = u.Redacted()
+	}
+
+	client := webdav.New(folder, username, password)
+	return snapshot.NewTransport(remote, client, transportMirror(p, rawURL), enc), nil
+}
@@ -0,0 +1,103 @@
+// Package clockskew measures how far the system clock is off, against the
+// Date header of an HTTP server or an NTP server
+package clockskew
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// NTPServer is asked for the time when the remote is not served over HTTP
+const NTPServer = "pool.ntp.org:123"
+
+// ntpEpochOffset is the number of seconds between the NTP epoch (1900)
+// and the Unix epoch
+const ntpEpochOffset = 2208988800
+
+// Measure returns how far the system clock is ahead of the reference
+// time (negative when behind) and what the reference was. HTTP(S)
+// remotes are asked for their Date header; any other remote falls back
+// to NTPServer.
+func Measure(ctx context.Context, remoteURL string) (time.Duration, string, error) {
+	if u, err := url.Parse(remoteURL); err == nil && (u.Scheme == "https" || u.Scheme == "http") {
+		skew, err := httpSkew(ctx, u.Scheme+"://"+u.Host+"/")
+		return skew, u.Host, err
+	}
+
+	skew, err := ntpSkew(ctx, NTPServer)
+	return skew, NTPServer, err
+}
+
+// httpSkew compares the system clock with the Date header of a HEAD
+// request to target. The header has a resolution of one second.
+func httpSkew(ctx context.Context, target string) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, target, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reach %s: %w", target, err)
+	}
+	resp.Body.Close()
+	received := time.Now()
+
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return 0, fmt.Errorf("%s sent no usable Date header", target)
+	}
+
+	// The server stamped the response about halfway through the round trip
+	local := sent.Add(received.Sub(sent) / 2)
+	return local.Sub(date), nil
+}
+
+// ntpSkew compares the system clock with the transmit time of an SNTP
+// reply from server
+func ntpSkew(ctx context.Context, server string) (time.Duration, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", server)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reach %s: %w", server, err)
+	}
+	defer conn.Close()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(5 * time.Second)
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return 0, err
+	}
+
+	// Version 3, client mode
+	request := make([]byte, 48)
+	request[0] = 0x1B
+
+	sent := time.Now()
+	if _, err := conn.Write(request); err != nil {
+		return 0, fmt.Errorf("failed to query %s: %w", server, err)
+	}
+	reply := make([]byte, 48)
+	if _, err := conn.Read(reply); err != nil {
+		return 0, fmt.Errorf("failed to query %s: %w", server, err)
+	}
+	received := time.Now()
+
+	seconds := binary.BigEndian.Uint32(reply[40:44])
+	fraction := binary.BigEndian.Uint32(reply[44:48])
+	if seconds == 0 {
+		return 0, fmt.Errorf("%s sent no time", server)
+	}
+	transmit := time.Unix(int64(seconds)-ntpEpochOffset, int64(fraction)*int64(time.Second)>>32)
+
+	local := sent.Add(received.Sub(sent) / 2)
+	return local.Sub(transmit), nil
+}
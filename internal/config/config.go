@@ -2,30 +2,179 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/GareArc/opencode-sync/internal/paths"
 )
 
+// ErrNoConfig is returned when opencode-sync has not been set up yet
+var ErrNoConfig = errors.New("no configuration found")
+
 // Config represents the opencode-sync configuration
 type Config struct {
 	Repo       RepoConfig       `json:"repo"`
 	Encryption EncryptionConfig `json:"encryption"`
 	Sync       SyncConfig       `json:"sync"`
+	Watch      WatchConfig      `json:"watch,omitempty"`
+	Backup     BackupConfig     `json:"backup,omitempty"`
+	Projects   []ProjectConfig  `json:"projects,omitempty"`
+	Claude     ClaudeConfig     `json:"claude,omitempty"`
+	Usage      UsageConfig      `json:"usage,omitempty"`
 }
 
 // RepoConfig holds Git repository configuration
 type RepoConfig struct {
 	URL    string `json:"url"`
 	Branch string `json:"branch"`
+
+	// Upstream is an optional template repository whose changes can be
+	// merged with 'opencode-sync upstream merge'
+	Upstream string `json:"upstream,omitempty"`
+
+	// TimeoutSeconds limits clone, fetch, pull and push. Zero means
+	// DefaultTimeoutSeconds.
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+
+	// CompressionLevel is the zstd level of snapshots uploaded to WebDAV
+	// and SFTP remotes, from 1 (fastest) to 22 (smallest). Zero means
+	// DefaultCompressionLevel.
+	CompressionLevel int `json:"compressionLevel,omitempty"`
+
+	// SSH holds settings for SSH remotes
+	SSH SSHConfig `json:"ssh,omitempty"`
+
+	// Author is the identity sync commits are made with
+	Author AuthorConfig `json:"author,omitempty"`
+
+	// Layout selects where the sync repo lives: "copy" (default) keeps a
+	// working copy in the data dir and copies files in and out of it,
+	// "direct" makes the OpenCode config dir the working tree and keeps
+	// the git data in the data dir. It is fixed when the repo is created.
+	Layout string `json:"layout,omitempty"`
+
+	// Subdir keeps the synced files in a subdirectory of the repository,
+	// such as "opencode" in a dotfiles repo that holds other files too.
+	// Empty uses the whole repository. It is fixed when the repo is
+	// created.
+	Subdir string `json:"subdir,omitempty"`
+
+	// Forge names the service hosting the remote, "github", "gitlab" or
+	// "gitea", for 'push --propose'. Empty guesses it from the remote's
+	// host name.
+	Forge string `json:"forge,omitempty"`
+}
+
+// Values for RepoConfig.Layout
+const (
+	LayoutCopy   = "copy"
+	LayoutDirect = "direct"
+)
+
+// AuthorConfig holds the commit author identity. Unset fields fall back
+// to git's user.name and user.email.
+type AuthorConfig struct {
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty"`
+
+	// Hosts overrides the name or email on the named machines
+	Hosts map[string]AuthorConfig `json:"hosts,omitempty"`
+}
+
+// For returns the author name and email to use on host
+func (a AuthorConfig) For(host string) (name, email string) {
+	name, email = a.Name, a.Email
+	if override, ok := a.Hosts[host]; ok {
+		if override.Name != "" {
+			name = override.Name
+		}
+		if override.Email != "" {
+			email = override.Email
+		}
+	}
+	return name, email
+}
+
+// SSHConfig holds settings for SSH remotes
+type SSHConfig struct {
+	// HostKeyPolicy controls how the remote's host key is verified:
+	// "known_hosts" requires it to be in known_hosts already,
+	// "accept-new" adds unknown hosts on first connection but rejects
+	// changed keys, and "fingerprint" accepts only the key matching
+	// Fingerprint. Empty leaves it to the user's ssh configuration.
+	HostKeyPolicy string `json:"hostKeyPolicy,omitempty"`
+
+	// Fingerprint is the pinned SHA256 host key fingerprint, as printed
+	// by ssh-keygen -l (e.g. "SHA256:...")
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+// Values for SSHConfig.HostKeyPolicy
+const (
+	HostKeyPolicyKnownHosts  = "known_hosts"
+	HostKeyPolicyAcceptNew   = "accept-new"
+	HostKeyPolicyFingerprint = "fingerprint"
+)
+
+// DefaultTimeoutSeconds is the remote operation time limit used when
+// repo.timeoutSeconds is not set
+const DefaultTimeoutSeconds = 300
+
+// Timeout returns the time limit for remote operations
+func (r RepoConfig) Timeout() time.Duration {
+	if r.TimeoutSeconds <= 0 {
+		return DefaultTimeoutSeconds * time.Second
+	}
+	return time.Duration(r.TimeoutSeconds) * time.Second
+}
+
+// DefaultCompressionLevel is the snapshot compression level used when
+// repo.compressionLevel is not set
+const DefaultCompressionLevel = 3
+
+// Compression returns the zstd level snapshots are uploaded with
+func (r RepoConfig) Compression() int {
+	if r.CompressionLevel <= 0 {
+		return DefaultCompressionLevel
+	}
+	return r.CompressionLevel
+}
+
+// SubdirPath returns repo.subdir cleaned and slash-separated, or "" for
+// the whole repository
+func (r RepoConfig) SubdirPath() string {
+	if r.Subdir == "" {
+		return ""
+	}
+	return path.Clean(filepath.ToSlash(r.Subdir))
+}
+
+// ValidSubdir reports whether dir names a directory inside a repository
+func ValidSubdir(dir string) bool {
+	clean := path.Clean(filepath.ToSlash(dir))
+	if filepath.IsAbs(dir) || path.IsAbs(clean) || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return false
+	}
+	first, _, _ := strings.Cut(clean, "/")
+	return first != ".git"
 }
 
 // EncryptionConfig holds encryption settings
 type EncryptionConfig struct {
 	Enabled bool   `json:"enabled"`
 	KeyFile string `json:"keyFile,omitempty"`
+
+	// UnlockMinutes is how long a passphrase-protected key stays unlocked
+	// in a long-running process such as the interactive menu or watch.
+	// Zero keeps it until the process exits.
+	UnlockMinutes int `json:"unlockMinutes,omitempty"`
 }
 
 // SyncConfig holds sync behavior settings
@@ -33,6 +182,451 @@ type SyncConfig struct {
 	IncludeAuth    bool     `json:"includeAuth"`
 	IncludeMcpAuth bool     `json:"includeMcpAuth"`
 	Exclude        []string `json:"exclude,omitempty"`
+
+	// AuthHistory controls where encrypted auth.json and mcp-auth.json
+	// are stored: "keep" (default) commits them to the sync branch like
+	// any other file, "latest" keeps only the current version on a
+	// separate branch that is replaced on every change
+	AuthHistory string `json:"authHistory,omitempty"`
+
+	// CanonicalJSON rewrites JSON and JSONC files with sorted keys and
+	// consistent indentation when they are copied into the repo, so
+	// reordering or reformatting by OpenCode makes no commit. Comments in
+	// JSONC files are kept.
+	CanonicalJSON bool `json:"canonicalJSON,omitempty"`
+
+	// SplitMcpSecrets moves MCP server credentials (headers, environment,
+	// oauth) out of opencode.json into an encrypted mcp-secrets.json.age
+	SplitMcpSecrets bool `json:"splitMcpSecrets,omitempty"`
+
+	// IncludeBinaries syncs binary files. By default files that look
+	// binary (e.g. compiled plugin artifacts) are skipped.
+	IncludeBinaries bool `json:"includeBinaries,omitempty"`
+
+	// BinaryAllow lists patterns of binary files that are synced even
+	// without IncludeBinaries. A pattern matches a file name, a repo path
+	// or a repo directory prefix (e.g. "*.png", "themes/").
+	BinaryAllow []string `json:"binaryAllow,omitempty"`
+
+	// CopyMode selects how files are copied between the OpenCode config
+	// and the sync repo: "auto" (default) clones files with reflinks where
+	// the filesystem supports them, "copy" always copies the content
+	CopyMode string `json:"copyMode,omitempty"`
+
+	// HostSecrets lists patterns of files that are private to this
+	// machine. They are encrypted to this machine's own host key and
+	// stored under hosts/<name>/, so other machines cannot read them.
+	// Patterns match like BinaryAllow.
+	HostSecrets []string `json:"hostSecrets,omitempty"`
+
+	// UnionMerge lists gitattributes patterns of markdown files that mostly
+	// grow, such as notes. Additions both machines made at the same place
+	// are kept without a conflict. AGENTS.md is always merged this way.
+	UnionMerge []string `json:"unionMerge,omitempty"`
+
+	// MergeKeys are the fields identifying object items of JSON arrays when
+	// config files are merged, in order of preference. Items with the same
+	// value are merged as one. Empty means DefaultMergeKeys.
+	MergeKeys []string `json:"mergeKeys,omitempty"`
+
+	// HistoryMode controls how syncs are recorded: "append" (default)
+	// commits every sync, "squash" folds a sync into the previous one when
+	// this machine made it the same day
+	HistoryMode string `json:"historyMode,omitempty"`
+
+	// WhenRunning controls what pull does while OpenCode is running:
+	// "warn" (default), "wait" or "force"
+	WhenRunning string `json:"whenRunning,omitempty"`
+
+	// OnlyDirs limits this machine to the given repo directories (e.g.
+	// "agent", "command"). Top-level files are always synced. Empty means
+	// everything.
+	OnlyDirs []string `json:"onlyDirs,omitempty"`
+
+	// IncludeSessions syncs OpenCode session and message history,
+	// encrypted. Requires encryption.
+	IncludeSessions bool `json:"includeSessions,omitempty"`
+
+	// SessionsMaxSizeMB caps the total size of synced history; the newest
+	// files are kept. Zero means DefaultSessionsMaxSizeMB.
+	SessionsMaxSizeMB int `json:"sessionsMaxSizeMB,omitempty"`
+
+	// SessionsRetentionDays drops history older than this many days from
+	// the repo. Zero means DefaultSessionsRetentionDays.
+	SessionsRetentionDays int `json:"sessionsRetentionDays,omitempty"`
+
+	// MaxMemoryFileSizeMB caps the size of files read into memory whole,
+	// such as JSON configs that are parsed. Copying, hashing and
+	// encryption stream and are not limited. Zero means
+	// DefaultMaxMemoryFileSizeMB.
+	MaxMemoryFileSizeMB int `json:"maxMemoryFileSizeMB,omitempty"`
+
+	// Parallelism is how many sources (the OpenCode config, Claude Code
+	// paths, each project and session history) are copied at once. Zero
+	// means one per CPU; 1 copies them one after another.
+	Parallelism int `json:"parallelism,omitempty"`
+
+	// StartupPullMinutes is how often 'hook opencode-start' checks the
+	// remote; starts within this many minutes of the last check or pull do
+	// nothing. Zero means DefaultStartupPullMinutes.
+	StartupPullMinutes int `json:"startupPullMinutes,omitempty"`
+
+	// PreserveMtimes records file modification times in the repo and
+	// restores them on pull
+	PreserveMtimes bool `json:"preserveMtimes,omitempty"`
+
+	// PreserveExecutable records which files are executable in the repo,
+	// so they stay executable after a round trip through Windows or FAT
+	// filesystems that cannot store the flag
+	PreserveExecutable bool `json:"preserveExecutable,omitempty"`
+
+	// Targets holds schedule hints for 'watch', keyed by a pattern that
+	// matches repo paths like HostSecrets (e.g. "auth.json", "themes")
+	Targets map[string]TargetConfig `json:"targets,omitempty"`
+
+	// Network controls syncing by 'watch' on limited connections
+	Network NetworkConfig `json:"network,omitempty"`
+
+	// StatusFile is where a JSON snapshot of the last push or pull is
+	// written, for status bars and dashboards. A leading ~ stands for the
+	// home directory. Empty writes none.
+	StatusFile string `json:"statusFile,omitempty"`
+}
+
+// StatusFilePath returns sync.statusFile with ~ expanded, or "" if unset
+func (s SyncConfig) StatusFilePath() (string, error) {
+	path := s.StatusFile
+	if path == "~" || strings.HasPrefix(path, "~/") || strings.HasPrefix(path, `~\`) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get home directory: %w", err)
+		}
+		path = filepath.Join(home, path[1:])
+	}
+	return path, nil
+}
+
+// NetworkConfig controls syncing by 'watch' on limited connections
+type NetworkConfig struct {
+	// AllowMetered lets watch sync on connections the platform reports
+	// as metered. By default it waits for an unmetered one.
+	AllowMetered bool `json:"allowMetered,omitempty"`
+}
+
+// TargetConfig is the schedule of one sync target
+type TargetConfig struct {
+	// Interval is the least time between two pushes of the target's
+	// changes by 'watch', as a duration such as "30s" or "2h". "0" pushes
+	// them as soon as watch notices. Empty means the watch interval.
+	Interval string `json:"interval,omitempty"`
+}
+
+// TargetIntervals returns the push interval of every target that sets
+// one. Invalid intervals are left out; Validate reports them.
+func (s SyncConfig) TargetIntervals() map[string]time.Duration {
+	intervals := make(map[string]time.Duration)
+	for name, target := range s.Targets {
+		if target.Interval == "" {
+			continue
+		}
+		if interval, err := time.ParseDuration(target.Interval); err == nil && interval >= 0 {
+			intervals[name] = interval
+		}
+	}
+	return intervals
+}
+
+// Session sync defaults
+const (
+	DefaultSessionsMaxSizeMB     = 100
+	DefaultSessionsRetentionDays = 30
+)
+
+// DefaultMaxMemoryFileSizeMB is the limit on files read into memory whole
+// used when sync.maxMemoryFileSizeMB is not set
+const DefaultMaxMemoryFileSizeMB = 64
+
+// Parallel returns how many sources are copied at once
+func (s SyncConfig) Parallel() int {
+	if s.Parallelism <= 0 {
+		return runtime.NumCPU()
+	}
+	return s.Parallelism
+}
+
+// DefaultStartupPullMinutes is the startup hook's rate limit used when
+// sync.startupPullMinutes is not set
+const DefaultStartupPullMinutes = 15
+
+// StartupPullInterval returns the minimum time between remote checks by
+// the startup hook
+func (s SyncConfig) StartupPullInterval() time.Duration {
+	if s.StartupPullMinutes <= 0 {
+		return DefaultStartupPullMinutes * time.Minute
+	}
+	return time.Duration(s.StartupPullMinutes) * time.Minute
+}
+
+// DefaultMergeKeys identify array items when sync.mergeKeys is not set
+var DefaultMergeKeys = []string{"name", "id"}
+
+// ArrayMergeKeys returns the fields identifying object items of JSON
+// arrays when config files are merged
+func (s SyncConfig) ArrayMergeKeys() []string {
+	if len(s.MergeKeys) == 0 {
+		return DefaultMergeKeys
+	}
+	return s.MergeKeys
+}
+
+// MaxMemoryFileSize returns the limit on files read into memory whole in
+// bytes
+func (s SyncConfig) MaxMemoryFileSize() int64 {
+	if s.MaxMemoryFileSizeMB <= 0 {
+		return DefaultMaxMemoryFileSizeMB << 20
+	}
+	return int64(s.MaxMemoryFileSizeMB) << 20
+}
+
+// SessionsMaxSize returns the size cap for synced history in bytes
+func (s SyncConfig) SessionsMaxSize() int64 {
+	if s.SessionsMaxSizeMB <= 0 {
+		return DefaultSessionsMaxSizeMB << 20
+	}
+	return int64(s.SessionsMaxSizeMB) << 20
+}
+
+// SessionsRetention returns how long synced history is kept
+func (s SyncConfig) SessionsRetention() time.Duration {
+	days := s.SessionsRetentionDays
+	if days <= 0 {
+		days = DefaultSessionsRetentionDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// Values for SyncConfig.AuthHistory
+const (
+	AuthHistoryKeep   = "keep"
+	AuthHistoryLatest = "latest"
+)
+
+// Values for SyncConfig.CopyMode
+const (
+	CopyModeAuto = "auto"
+	CopyModeCopy = "copy"
+)
+
+// Values for SyncConfig.HistoryMode
+const (
+	HistoryModeAppend = "append"
+	HistoryModeSquash = "squash"
+)
+
+// Values for SyncConfig.WhenRunning
+const (
+	WhenRunningWarn  = "warn"
+	WhenRunningWait  = "wait"
+	WhenRunningForce = "force"
+)
+
+// WatchConfig controls how often 'opencode-sync watch' syncs
+type WatchConfig struct {
+	// DebounceSeconds is how long 'watch --two-way' waits after the last
+	// file change before syncing. Zero means DefaultDebounceSeconds.
+	DebounceSeconds int `json:"debounceSeconds,omitempty"`
+
+	// MinPushMinutes is the least time between two pushes by watch; a
+	// sync due sooner waits. Zero means no limit.
+	MinPushMinutes int `json:"minPushMinutes,omitempty"`
+
+	// QuietHours is a daily range of local time, such as "00:00-07:00",
+	// during which watch doesn't sync. It may span midnight.
+	QuietHours string `json:"quietHours,omitempty"`
+
+	// MinBatteryPercent makes watch skip its syncs while the machine runs
+	// on battery with less charge than this. Zero means never.
+	MinBatteryPercent int `json:"minBatteryPercent,omitempty"`
+
+	// SkipReview makes watch apply risky pulled changes, such as changed
+	// providers or a replaced auth.json, instead of holding them back for
+	// 'pull --review'
+	SkipReview bool `json:"skipReview,omitempty"`
+}
+
+// BackupConfig controls automatic snapshots of the local OpenCode config
+type BackupConfig struct {
+	// Auto takes a snapshot "hourly", "daily" or "weekly", whether or not
+	// anything syncs. Empty or "off" means never.
+	Auto string `json:"auto,omitempty"`
+
+	// Keep is how many snapshots are kept. Zero means DefaultBackupKeep.
+	Keep int `json:"keep,omitempty"`
+
+	// KeepDays removes snapshots older than this many days, even within
+	// Keep. Zero means no age limit.
+	KeepDays int `json:"keepDays,omitempty"`
+}
+
+// Values for BackupConfig.Auto
+const (
+	BackupAutoOff    = "off"
+	BackupAutoHourly = "hourly"
+	BackupAutoDaily  = "daily"
+	BackupAutoWeekly = "weekly"
+)
+
+// DefaultBackupKeep is the number of snapshots kept when backup.keep is
+// not set
+const DefaultBackupKeep = 14
+
+// Interval returns how often a snapshot is taken, or zero for never
+func (b BackupConfig) Interval() time.Duration {
+	switch b.Auto {
+	case BackupAutoHourly:
+		return time.Hour
+	case BackupAutoDaily:
+		return 24 * time.Hour
+	case BackupAutoWeekly:
+		return 7 * 24 * time.Hour
+	}
+	return 0
+}
+
+// KeepCount returns how many snapshots are kept
+func (b BackupConfig) KeepCount() int {
+	if b.Keep <= 0 {
+		return DefaultBackupKeep
+	}
+	return b.Keep
+}
+
+// MaxAge returns how old a snapshot may get, or zero for no limit
+func (b BackupConfig) MaxAge() time.Duration {
+	return time.Duration(max(b.KeepDays, 0)) * 24 * time.Hour
+}
+
+// DefaultDebounceSeconds is the debounce window of 'watch --two-way' used
+// when watch.debounceSeconds is not set
+const DefaultDebounceSeconds = 2
+
+// Debounce returns how long 'watch --two-way' waits for file changes to
+// settle
+func (w WatchConfig) Debounce() time.Duration {
+	if w.DebounceSeconds <= 0 {
+		return DefaultDebounceSeconds * time.Second
+	}
+	return time.Duration(w.DebounceSeconds) * time.Second
+}
+
+// MinPushInterval returns the least time between two pushes by watch
+func (w WatchConfig) MinPushInterval() time.Duration {
+	return time.Duration(max(w.MinPushMinutes, 0)) * time.Minute
+}
+
+// QuietUntil reports whether t falls in the quiet hours and, if so, when
+// they end
+func (w WatchConfig) QuietUntil(t time.Time) (time.Time, bool) {
+	start, end, err := parseQuietHours(w.QuietHours)
+	if err != nil || start == end {
+		return time.Time{}, false
+	}
+
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	now := t.Sub(midnight)
+	switch {
+	case start < end && now >= start && now < end:
+		return midnight.Add(end), true
+	case start > end && now >= start:
+		return midnight.AddDate(0, 0, 1).Add(end), true
+	case start > end && now < end:
+		return midnight.Add(end), true
+	}
+	return time.Time{}, false
+}
+
+// parseQuietHours parses a "HH:MM-HH:MM" range into its start and end as
+// offsets from midnight. An empty range is zero.
+func parseQuietHours(value string) (time.Duration, time.Duration, error) {
+	if value == "" {
+		return 0, 0, nil
+	}
+
+	from, to, ok := strings.Cut(value, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("expected a range such as 00:00-07:00")
+	}
+
+	var bounds [2]time.Duration
+	for i, clock := range []string{from, to} {
+		t, err := time.Parse("15:04", strings.TrimSpace(clock))
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid time %q: use HH:MM", clock)
+		}
+		bounds[i] = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	}
+	return bounds[0], bounds[1], nil
+}
+
+// UsageConfig holds the opt-in usage insights
+type UsageConfig struct {
+	// Enabled records which commands run and how syncs end in the data
+	// dir, for 'stats --usage'. Nothing is ever uploaded.
+	Enabled bool `json:"enabled,omitempty"`
+}
+
+// ClaudeConfig selects the Claude Code paths synced alongside OpenCode
+type ClaudeConfig struct {
+	// Disabled turns off every Claude Code path, for machines without
+	// Claude Code
+	Disabled bool `json:"disabled,omitempty"`
+
+	// Paths lists the Claude Code paths to sync, from ClaudePaths. Empty
+	// means DefaultClaudePaths.
+	Paths []string `json:"paths,omitempty"`
+}
+
+// Claude Code paths that can be synced
+const (
+	ClaudeSkills   = "skills"   // ~/.claude/skills/
+	ClaudeCommands = "commands" // ~/.claude/commands/
+	ClaudeSettings = "settings" // ~/.claude/settings.json
+	ClaudeMemory   = "memory"   // ~/.claude/CLAUDE.md
+)
+
+// ClaudePaths are the values accepted in claude.paths
+var ClaudePaths = []string{ClaudeSkills, ClaudeCommands, ClaudeSettings, ClaudeMemory}
+
+// DefaultClaudePaths are synced when claude.paths is not set
+var DefaultClaudePaths = []string{ClaudeSkills}
+
+// Enabled returns the Claude Code paths synced on this machine
+func (c ClaudeConfig) Enabled() []string {
+	if c.Disabled {
+		return nil
+	}
+	if len(c.Paths) == 0 {
+		return DefaultClaudePaths
+	}
+	return c.Paths
+}
+
+// ProjectConfig registers a project directory whose .opencode/ and
+// AGENTS.md are synced under projects/<name>/ in the repo
+type ProjectConfig struct {
+	Name    string `json:"name"`
+	Path    string `json:"path"`
+	Enabled bool   `json:"enabled"`
+}
+
+// FindProject returns the project with the given name, or nil
+func (c *Config) FindProject(name string) *ProjectConfig {
+	for i := range c.Projects {
+		if c.Projects[i].Name == name {
+			return &c.Projects[i]
+		}
+	}
+	return nil
 }
 
 // Default returns a default configuration
@@ -55,6 +649,7 @@ func Default() *Config {
 			IncludeAuth:    false,
 			IncludeMcpAuth: false,
 			Exclude:        []string{"node_modules", "*.log", "bun.lock"},
+			WhenRunning:    WhenRunningWarn,
 		},
 	}
 }
@@ -117,6 +712,34 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("repo.url is required")
 	}
 
+	if c.Repo.TimeoutSeconds < 0 {
+		return fmt.Errorf("repo.timeoutSeconds must not be negative")
+	}
+
+	if c.Repo.CompressionLevel < 0 || c.Repo.CompressionLevel > 22 {
+		return fmt.Errorf("repo.compressionLevel must be between 1 and 22")
+	}
+
+	switch c.Repo.SSH.HostKeyPolicy {
+	case "", HostKeyPolicyKnownHosts, HostKeyPolicyAcceptNew:
+	case HostKeyPolicyFingerprint:
+		if !strings.HasPrefix(c.Repo.SSH.Fingerprint, "SHA256:") {
+			return fmt.Errorf("repo.ssh.hostKeyPolicy fingerprint requires repo.ssh.fingerprint to be set to a SHA256:... fingerprint")
+		}
+	default:
+		return fmt.Errorf("repo.ssh.hostKeyPolicy must be one of: known_hosts, accept-new, fingerprint")
+	}
+
+	for host, override := range c.Repo.Author.Hosts {
+		if len(override.Hosts) > 0 {
+			return fmt.Errorf("repo.author.hosts.%s cannot have its own hosts", host)
+		}
+	}
+
+	if c.Encryption.UnlockMinutes < 0 {
+		return fmt.Errorf("encryption.unlockMinutes must not be negative")
+	}
+
 	if c.Sync.IncludeAuth && !c.Encryption.Enabled {
 		return fmt.Errorf("sync.includeAuth requires encryption.enabled to be true")
 	}
@@ -125,6 +748,152 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("sync.includeMcpAuth requires encryption.enabled to be true")
 	}
 
+	seen := map[string]bool{}
+	for _, project := range c.Projects {
+		if project.Name == "" || project.Name == "." || project.Name == ".." || strings.ContainsAny(project.Name, `/\`) {
+			return fmt.Errorf("invalid project name: %q", project.Name)
+		}
+		if seen[project.Name] {
+			return fmt.Errorf("duplicate project name: %s", project.Name)
+		}
+		seen[project.Name] = true
+	}
+
+	if c.Sync.IncludeSessions && !c.Encryption.Enabled {
+		return fmt.Errorf("sync.includeSessions requires encryption.enabled to be true")
+	}
+
+	if c.Sync.SessionsMaxSizeMB < 0 || c.Sync.SessionsRetentionDays < 0 {
+		return fmt.Errorf("sync.sessionsMaxSizeMB and sync.sessionsRetentionDays must not be negative")
+	}
+
+	if c.Sync.MaxMemoryFileSizeMB < 0 {
+		return fmt.Errorf("sync.maxMemoryFileSizeMB must not be negative")
+	}
+
+	if c.Sync.Parallelism < 0 {
+		return fmt.Errorf("sync.parallelism must not be negative")
+	}
+
+	if c.Sync.StartupPullMinutes < 0 {
+		return fmt.Errorf("sync.startupPullMinutes must not be negative")
+	}
+
+	if c.Sync.SplitMcpSecrets && !c.Encryption.Enabled {
+		return fmt.Errorf("sync.splitMcpSecrets requires encryption.enabled to be true")
+	}
+
+	if len(c.Sync.HostSecrets) > 0 && !c.Encryption.Enabled {
+		return fmt.Errorf("sync.hostSecrets requires encryption.enabled to be true")
+	}
+
+	for _, dir := range c.Sync.OnlyDirs {
+		clean := filepath.ToSlash(filepath.Clean(dir))
+		if dir == "" || clean != dir || filepath.IsAbs(dir) || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+			return fmt.Errorf("invalid sync.onlyDirs entry: %q", dir)
+		}
+	}
+
+	for _, key := range c.Sync.MergeKeys {
+		if strings.TrimSpace(key) == "" {
+			return fmt.Errorf("sync.mergeKeys entries must not be empty")
+		}
+	}
+
+	for name, target := range c.Sync.Targets {
+		if name == "" {
+			return fmt.Errorf("sync.targets entries need a name")
+		}
+		if target.Interval == "" {
+			continue
+		}
+		if interval, err := time.ParseDuration(target.Interval); err != nil || interval < 0 {
+			return fmt.Errorf("sync.targets.%s.interval must be a duration such as 30s or 2h", name)
+		}
+	}
+
+	if c.Watch.DebounceSeconds < 0 || c.Watch.MinPushMinutes < 0 {
+		return fmt.Errorf("watch.debounceSeconds and watch.minPushMinutes must not be negative")
+	}
+
+	if c.Watch.MinBatteryPercent < 0 || c.Watch.MinBatteryPercent > 100 {
+		return fmt.Errorf("watch.minBatteryPercent must be between 0 and 100")
+	}
+
+	if _, _, err := parseQuietHours(c.Watch.QuietHours); err != nil {
+		return fmt.Errorf("invalid watch.quietHours: %w", err)
+	}
+
+	switch c.Backup.Auto {
+	case "", BackupAutoOff, BackupAutoHourly, BackupAutoDaily, BackupAutoWeekly:
+	default:
+		return fmt.Errorf("backup.auto must be one of: off, hourly, daily, weekly")
+	}
+
+	if c.Backup.Keep < 0 || c.Backup.KeepDays < 0 {
+		return fmt.Errorf("backup.keep and backup.keepDays must not be negative")
+	}
+
+	switch c.Sync.AuthHistory {
+	case "", AuthHistoryKeep, AuthHistoryLatest:
+	default:
+		return fmt.Errorf("sync.authHistory must be one of: keep, latest")
+	}
+
+	switch c.Sync.CopyMode {
+	case "", CopyModeAuto, CopyModeCopy:
+	default:
+		return fmt.Errorf("sync.copyMode must be one of: auto, copy")
+	}
+
+	switch c.Sync.WhenRunning {
+	case "", WhenRunningWarn, WhenRunningWait, WhenRunningForce:
+	default:
+		return fmt.Errorf("sync.whenRunning must be one of: warn, wait, force")
+	}
+
+	switch c.Sync.HistoryMode {
+	case "", HistoryModeAppend, HistoryModeSquash:
+	default:
+		return fmt.Errorf("sync.historyMode must be one of: append, squash")
+	}
+
+	switch c.Repo.Layout {
+	case "", LayoutCopy:
+	case LayoutDirect:
+		// Files are committed as they are, so nothing can be transformed
+		// or stored outside the OpenCode config dir
+		switch {
+		case c.Sync.IncludeAuth, c.Sync.IncludeMcpAuth, c.Sync.IncludeSessions, c.Sync.SplitMcpSecrets,
+			c.Sync.CanonicalJSON, c.Sync.PreserveMtimes, len(c.Sync.HostSecrets) > 0, len(c.Sync.OnlyDirs) > 0,
+			len(c.Projects) > 0:
+			return fmt.Errorf("repo.layout direct cannot be used with includeAuth, includeMcpAuth, includeSessions, splitMcpSecrets, canonicalJSON, preserveMtimes, hostSecrets, onlyDirs or projects")
+		}
+	default:
+		return fmt.Errorf("repo.layout must be one of: copy, direct")
+	}
+
+	if c.Repo.Subdir != "" {
+		if !ValidSubdir(c.Repo.Subdir) {
+			return fmt.Errorf("repo.subdir must be a relative directory inside the repository, such as opencode")
+		}
+		if c.Repo.Layout == LayoutDirect {
+			return fmt.Errorf("repo.subdir cannot be used with repo.layout direct")
+		}
+	}
+
+	switch c.Repo.Forge {
+	case "", "github", "gitlab", "gitea":
+	default:
+		return fmt.Errorf("repo.forge must be one of: github, gitlab, gitea")
+	}
+
+	for _, path := range c.Claude.Paths {
+		if !slices.Contains(ClaudePaths, path) {
+			return fmt.Errorf("invalid claude.paths entry %q: must be one of: %s", path, strings.Join(ClaudePaths, ", "))
+		}
+	}
+
 	return nil
 }
 
@@ -1,14 +1,28 @@
 package crypto
 
 import (
+	"bufio"
 	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"strings"
 
 	"filippo.io/age"
+	"github.com/GareArc/opencode-sync/internal/capability"
 )
 
+func init() {
+	capability.Register(capability.Capability{
+		Name:        "age",
+		Kind:        capability.KindEncryption,
+		Description: "Encrypt secrets with age X25519 keys",
+	})
+}
+
 // AgeEncryption implements Encryption using age
 type AgeEncryption struct {
 	identity  *age.X25519Identity
@@ -43,6 +57,11 @@ func NewAgeEncryptionWithPublicKey(publicKey string) (*AgeEncryption, error) {
 	}, nil
 }
 
+// PublicKey returns the public key files are encrypted to
+func (a *AgeEncryption) PublicKey() string {
+	return a.recipient.String()
+}
+
 // GenerateKey generates a new age key pair
 func GenerateKey() (*KeyPair, error) {
 	identity, err := age.GenerateX25519Identity()
@@ -86,6 +105,10 @@ func (a *AgeEncryption) Decrypt(ciphertext []byte) ([]byte, error) {
 	}
 
 	r, err := age.Decrypt(bytes.NewReader(ciphertext), a.identity)
+	var noMatch *age.NoIdentityMatchError
+	if errors.As(err, &noMatch) {
+		return nil, fmt.Errorf("%w: %w", ErrNotRecipient, err)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to create decrypter: %w", err)
 	}
@@ -98,44 +121,124 @@ func (a *AgeEncryption) Decrypt(ciphertext []byte) ([]byte, error) {
 	return plaintext, nil
 }
 
-// EncryptFile encrypts a file
-func (a *AgeEncryption) EncryptFile(src, dst string) error {
-	// Read source file
-	plaintext, err := os.ReadFile(src)
-	if err != nil {
-		return fmt.Errorf("failed to read source file: %w", err)
+// MAC returns an HMAC-SHA256 of data keyed by the private key, so that
+// everyone holding the key can tell data written by one of them. The key
+// is hashed first, so the MAC reveals nothing about it.
+func (a *AgeEncryption) MAC(data []byte) ([]byte, error) {
+	if a.identity == nil {
+		return nil, fmt.Errorf("no identity configured")
+	}
+
+	key := sha256.Sum256([]byte("opencode-sync mac\n" + a.identity.String()))
+	mac := hmac.New(sha256.New, key[:])
+	mac.Write(data)
+	return mac.Sum(nil), nil
+}
+
+// ErrNotRecipient is returned when data was not encrypted to the key
+// decrypting it
+var ErrNotRecipient = errors.New("not encrypted to this key")
+
+// EncryptTo encrypts plaintext to every public key in publicKeys, so any
+// of their private keys can decrypt it
+func EncryptTo(publicKeys []string, plaintext []byte) ([]byte, error) {
+	out := &bytes.Buffer{}
+	if err := EncryptReaderTo(publicKeys, bytes.NewReader(plaintext), out); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// EncryptReaderTo is EncryptTo from reader to writer
+func EncryptReaderTo(publicKeys []string, plaintext io.Reader, ciphertext io.Writer) error {
+	if len(publicKeys) == 0 {
+		return fmt.Errorf("no recipient configured")
 	}
 
-	// Encrypt
-	ciphertext, err := a.Encrypt(plaintext)
+	recipients := make([]age.Recipient, 0, len(publicKeys))
+	for _, publicKey := range publicKeys {
+		recipient, err := age.ParseX25519Recipient(publicKey)
+		if err != nil {
+			return fmt.Errorf("failed to parse public key %s: %w", publicKey, err)
+		}
+		recipients = append(recipients, recipient)
+	}
+
+	w, err := age.Encrypt(ciphertext, recipients...)
 	if err != nil {
-		return fmt.Errorf("failed to encrypt: %w", err)
+		return fmt.Errorf("failed to create encrypter: %w", err)
 	}
 
-	// Write destination file
-	if err := os.WriteFile(dst, ciphertext, 0600); err != nil {
-		return fmt.Errorf("failed to write destination file: %w", err)
+	if _, err := io.Copy(w, plaintext); err != nil {
+		return fmt.Errorf("failed to write plaintext: %w", err)
+	}
+
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to close encrypter: %w", err)
 	}
 
 	return nil
 }
 
-// DecryptFile decrypts a file
+// CountRecipients returns how many X25519 keys age data is encrypted to,
+// read from its header. The header does not say which keys they are.
+func CountRecipients(ciphertext []byte) (int, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(ciphertext))
+	if !scanner.Scan() || scanner.Text() != "age-encryption.org/v1" {
+		return 0, fmt.Errorf("not an age file")
+	}
+
+	count := 0
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "---"):
+			return count, nil
+		case strings.HasPrefix(line, "-> X25519 "):
+			count++
+		}
+	}
+
+	return 0, fmt.Errorf("truncated age header")
+}
+
+// EncryptFile encrypts a file without holding it in memory
+func (a *AgeEncryption) EncryptFile(src, dst string) error {
+	return streamFile(src, dst, a.EncryptReader)
+}
+
+// DecryptFile decrypts a file without holding it in memory
 func (a *AgeEncryption) DecryptFile(src, dst string) error {
-	// Read source file
-	ciphertext, err := os.ReadFile(src)
+	return streamFile(src, dst, a.DecryptReader)
+}
+
+// streamFile writes src through fn into dst, readable only by the owner.
+// dst is replaced only once fn succeeded, so a failure leaves it as it
+// was.
+func streamFile(src, dst string, fn func(io.Reader, io.Writer) error) error {
+	in, err := os.Open(src)
 	if err != nil {
 		return fmt.Errorf("failed to read source file: %w", err)
 	}
+	defer in.Close()
 
-	// Decrypt
-	plaintext, err := a.Decrypt(ciphertext)
+	tmp := dst + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
 	if err != nil {
-		return fmt.Errorf("failed to decrypt: %w", err)
+		return fmt.Errorf("failed to write destination file: %w", err)
 	}
 
-	// Write destination file
-	if err := os.WriteFile(dst, plaintext, 0600); err != nil {
+	if err := fn(in, out); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write destination file: %w", err)
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
 		return fmt.Errorf("failed to write destination file: %w", err)
 	}
 
@@ -171,6 +274,10 @@ func (a *AgeEncryption) DecryptReader(ciphertext io.Reader, plaintext io.Writer)
 	}
 
 	r, err := age.Decrypt(ciphertext, a.identity)
+	var noMatch *age.NoIdentityMatchError
+	if errors.As(err, &noMatch) {
+		return fmt.Errorf("%w: %w", ErrNotRecipient, err)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to create decrypter: %w", err)
 	}
@@ -193,6 +300,9 @@ func SaveKeyToFile(privateKey, path string) error {
 // LoadKeyFromFile loads a private key from a file
 func LoadKeyFromFile(path string) (string, error) {
 	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("%w at %s", ErrKeyMissing, path)
+	}
 	if err != nil {
 		return "", fmt.Errorf("failed to read key file: %w", err)
 	}
@@ -1,9 +1,13 @@
 package crypto
 
 import (
+	"errors"
 	"io"
 )
 
+// ErrKeyMissing is returned when encrypted data needs a key that is not installed
+var ErrKeyMissing = errors.New("encryption key not found")
+
 // Encryption interface defines methods for encrypting and decrypting data
 type Encryption interface {
 	// Encrypt encrypts plaintext data
@@ -2,7 +2,6 @@ package crypto
 
 import (
 	"io"
-	"os"
 )
 
 // NoOpEncryption implements Encryption with no actual encryption
@@ -26,20 +25,12 @@ func (n *NoOpEncryption) Decrypt(ciphertext []byte) ([]byte, error) {
 
 // EncryptFile copies file without encryption
 func (n *NoOpEncryption) EncryptFile(src, dst string) error {
-	data, err := os.ReadFile(src)
-	if err != nil {
-		return err
-	}
-	return os.WriteFile(dst, data, 0600)
+	return streamFile(src, dst, n.EncryptReader)
 }
 
 // DecryptFile copies file without decryption
 func (n *NoOpEncryption) DecryptFile(src, dst string) error {
-	data, err := os.ReadFile(src)
-	if err != nil {
-		return err
-	}
-	return os.WriteFile(dst, data, 0600)
+	return streamFile(src, dst, n.DecryptReader)
 }
 
 // EncryptReader copies data without encryption
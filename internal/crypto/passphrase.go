@@ -0,0 +1,67 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+)
+
+// ErrWrongPassphrase is returned when data cannot be decrypted with the
+// given passphrase
+var ErrWrongPassphrase = errors.New("wrong passphrase")
+
+// EncryptWithPassphrase encrypts plaintext with a passphrase (age's scrypt
+// recipient) into ASCII-armored text that can be pasted anywhere
+func EncryptWithPassphrase(plaintext []byte, passphrase string) ([]byte, error) {
+	recipient, err := age.NewScryptRecipient(passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recipient: %w", err)
+	}
+
+	out := &bytes.Buffer{}
+	a := armor.NewWriter(out)
+	w, err := age.Encrypt(a, recipient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create encrypter: %w", err)
+	}
+
+	if _, err := w.Write(plaintext); err != nil {
+		return nil, fmt.Errorf("failed to encrypt: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finalize encryption: %w", err)
+	}
+	if err := a.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finalize armor: %w", err)
+	}
+
+	return out.Bytes(), nil
+}
+
+// DecryptWithPassphrase decrypts data written by EncryptWithPassphrase
+func DecryptWithPassphrase(ciphertext []byte, passphrase string) ([]byte, error) {
+	identity, err := age.NewScryptIdentity(passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create identity: %w", err)
+	}
+
+	r, err := age.Decrypt(armor.NewReader(bytes.NewReader(ciphertext)), identity)
+	var noMatch *age.NoIdentityMatchError
+	if errors.As(err, &noMatch) {
+		return nil, ErrWrongPassphrase
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+
+	plaintext, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read decrypted data: %w", err)
+	}
+
+	return plaintext, nil
+}
@@ -0,0 +1,166 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrKeyLocked is returned when a passphrase-protected key is needed but
+// no passphrase can be asked for
+var ErrKeyLocked = errors.New("encryption key is passphrase-protected and locked")
+
+// Session caches parsed keys for the life of the process, so a key file is
+// read and parsed once however many files and commands use it. A key file
+// that changes on disk is loaded again.
+//
+// Key files may be protected with a passphrase (an age file encrypted with
+// scrypt). Their passphrase is asked for through the prompt function and
+// the unlocked key is kept for the session's unlock TTL.
+type Session struct {
+	mu      sync.Mutex
+	keys    map[string]*sessionKey
+	prompt  func(path string) (string, error)
+	ttl     time.Duration
+	nowFunc func() time.Time
+}
+
+// sessionKey is a cached key and what it was loaded from
+type sessionKey struct {
+	enc     *AgeEncryption
+	secret  []byte
+	modTime time.Time
+	size    int64
+
+	// expires is when a passphrase-unlocked key is locked again; zero
+	// for keys stored without a passphrase
+	expires time.Time
+}
+
+// DefaultSession is the process-wide key cache
+var DefaultSession = NewSession()
+
+// NewSession returns an empty key cache
+func NewSession() *Session {
+	return &Session{keys: make(map[string]*sessionKey), nowFunc: time.Now}
+}
+
+// SetPrompt sets the function that asks for the passphrase of a protected
+// key file. Without one, protected keys fail with ErrKeyLocked.
+func (s *Session) SetPrompt(prompt func(path string) (string, error)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prompt = prompt
+}
+
+// SetUnlockTTL sets how long a key unlocked with a passphrase is kept.
+// Zero keeps it until the session is locked.
+func (s *Session) SetUnlockTTL(ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ttl = ttl
+}
+
+// Load returns the encryption for the key file at path, from the cache
+// when the file is unchanged
+func (s *Session) Load(path string) (*AgeEncryption, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w at %s", ErrKeyMissing, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	now := s.nowFunc()
+	if key, ok := s.keys[path]; ok {
+		fresh := key.modTime.Equal(info.ModTime()) && key.size == info.Size()
+		if fresh && (key.expires.IsZero() || now.Before(key.expires)) {
+			return key.enc, nil
+		}
+		s.drop(path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	key := &sessionKey{modTime: info.ModTime(), size: info.Size()}
+	if IsPassphraseProtected(data) {
+		if s.prompt == nil {
+			return nil, fmt.Errorf("%w: %s", ErrKeyLocked, path)
+		}
+		passphrase, err := s.prompt(path)
+		if err != nil {
+			return nil, err
+		}
+		plaintext, err := DecryptWithPassphrase(data, passphrase)
+		zero(data)
+		if err != nil {
+			return nil, err
+		}
+		data = plaintext
+
+		// Without a TTL the key is only good for this one load
+		key.expires = now.Add(s.ttl)
+	}
+
+	key.secret = data
+	key.enc, err = NewAgeEncryption(strings.TrimSpace(string(data)))
+	if err != nil {
+		zero(data)
+		return nil, fmt.Errorf("failed to initialize encryption: %w", err)
+	}
+
+	s.keys[path] = key
+	return key.enc, nil
+}
+
+// Lock forgets every cached key and overwrites the key material the
+// session holds. Parsed identities inside age cannot be wiped, so this is
+// best effort; it mainly ends the unlock period early.
+func (s *Session) Lock() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for path := range s.keys {
+		s.drop(path)
+	}
+}
+
+// drop removes one cached key, zeroing its secret
+func (s *Session) drop(path string) {
+	if key, ok := s.keys[path]; ok {
+		zero(key.secret)
+		delete(s.keys, path)
+	}
+}
+
+// IsPassphraseProtected reports whether key file content is an age file
+// rather than a bare key
+func IsPassphraseProtected(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	return bytes.HasPrefix(data, []byte("age-encryption.org/")) ||
+		bytes.HasPrefix(data, []byte("-----BEGIN AGE ENCRYPTED FILE-----"))
+}
+
+// LoadEncryption returns the encryption for the key file at path through
+// DefaultSession
+func LoadEncryption(path string) (*AgeEncryption, error) {
+	return DefaultSession.Load(path)
+}
+
+// zero overwrites b
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -0,0 +1,194 @@
+package daemon
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/GareArc/opencode-sync/internal/capability"
+)
+
+func init() {
+	capability.Register(capability.Capability{
+		Name:        "control-api",
+		Kind:        capability.KindFeature,
+		Description: "Local REST API to control watch",
+	})
+}
+
+// API is the control API of the watch daemon, served under /api/v1/.
+// Every request needs Token as a bearer token, so web pages the user
+// visits cannot drive it.
+type API struct {
+	// Token authorizes requests
+	Token string
+
+	// Log is streamed by /api/v1/logs
+	Log *Log
+
+	// Status, Pause and Resume are called from the server's goroutines
+	Status func() Status
+	Pause  func(time.Duration) error
+	Resume func() error
+
+	syncs chan chan error
+	wake  chan struct{}
+}
+
+// NewAPI creates an API with a random token
+func NewAPI(log *Log) (*API, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	return &API{
+		Token: hex.EncodeToString(token),
+		Log:   log,
+		syncs: make(chan chan error, 1),
+		wake:  make(chan struct{}, 1),
+	}, nil
+}
+
+// SyncRequests receives a channel for each requested sync, on which the
+// daemon sends the sync's result. At most one request waits at a time.
+func (a *API) SyncRequests() <-chan chan error {
+	return a.syncs
+}
+
+// Wake receives after a pause or resume, so the daemon can act on it
+func (a *API) Wake() <-chan struct{} {
+	return a.wake
+}
+
+// register adds the API's handlers to mux
+func (a *API) register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/v1/status", a.authorized(a.handleStatus))
+	mux.HandleFunc("POST /api/v1/sync", a.authorized(a.handleSync))
+	mux.HandleFunc("POST /api/v1/pause", a.authorized(a.handlePause))
+	mux.HandleFunc("POST /api/v1/resume", a.authorized(a.handleResume))
+	mux.HandleFunc("GET /api/v1/logs", a.authorized(a.handleLogs))
+}
+
+// authorized rejects requests without the token
+func (a *API) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(a.Token)) != 1 {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "missing or wrong token"})
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (a *API) handleStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.Status())
+}
+
+// handleSync asks the daemon for a sync now. With ?wait=true it answers
+// once the sync is done, with its result.
+func (a *API) handleSync(w http.ResponseWriter, r *http.Request) {
+	done := make(chan error, 1)
+
+	if r.URL.Query().Get("wait") != "true" {
+		select {
+		case a.syncs <- done:
+		default:
+			// A request already waits and covers the same changes
+		}
+		writeJSON(w, http.StatusAccepted, map[string]bool{"queued": true})
+		return
+	}
+
+	select {
+	case a.syncs <- done:
+	case <-r.Context().Done():
+		return
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+	case <-r.Context().Done():
+	}
+}
+
+// handlePause pauses automatic syncing, for the duration in ?for= if set
+func (a *API) handlePause(w http.ResponseWriter, r *http.Request) {
+	var duration time.Duration
+	if value := r.URL.Query().Get("for"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "for must be a positive duration such as 30m"})
+			return
+		}
+		duration = d
+	}
+
+	if err := a.Pause(duration); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	a.wakeDaemon()
+	writeJSON(w, http.StatusOK, a.Status())
+}
+
+func (a *API) handleResume(w http.ResponseWriter, r *http.Request) {
+	if err := a.Resume(); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	a.wakeDaemon()
+	writeJSON(w, http.StatusOK, a.Status())
+}
+
+// handleLogs writes the recent log lines as text. With ?follow=true it
+// keeps streaming new lines until the client goes away.
+func (a *API) handleLogs(w http.ResponseWriter, r *http.Request) {
+	lines, follower, stop := a.Log.Follow()
+	defer stop()
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, line := range lines {
+		fmt.Fprintln(w, line)
+	}
+	if r.URL.Query().Get("follow") != "true" {
+		return
+	}
+
+	flusher, _ := w.(http.Flusher)
+	for {
+		if flusher != nil {
+			flusher.Flush()
+		}
+		select {
+		case line := <-follower:
+			fmt.Fprintln(w, line)
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// wakeDaemon tells the daemon to look at the pause state again
+func (a *API) wakeDaemon() {
+	select {
+	case a.wake <- struct{}{}:
+	default:
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
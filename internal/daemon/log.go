@@ -0,0 +1,73 @@
+package daemon
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// logLines is how many recent lines a Log keeps for new readers
+const logLines = 200
+
+// Log keeps the recent output of the watch daemon and passes new lines to
+// followers. It is safe for concurrent use.
+type Log struct {
+	mu        sync.Mutex
+	partial   []byte
+	lines     []string
+	followers map[chan string]struct{}
+}
+
+// NewLog creates an empty Log
+func NewLog() *Log {
+	return &Log{followers: make(map[chan string]struct{})}
+}
+
+// Write adds the complete lines in p, each stamped with the current time
+func (l *Log) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.partial = append(l.partial, p...)
+	for {
+		i := bytes.IndexByte(l.partial, '\n')
+		if i < 0 {
+			break
+		}
+		line := fmt.Sprintf("%s %s", time.Now().Format(time.RFC3339), l.partial[:i])
+		l.partial = l.partial[i+1:]
+
+		l.lines = append(l.lines, line)
+		if len(l.lines) > logLines {
+			l.lines = l.lines[len(l.lines)-logLines:]
+		}
+		for follower := range l.followers {
+			// A follower that does not keep up misses lines rather than
+			// holding up the daemon
+			select {
+			case follower <- line:
+			default:
+			}
+		}
+	}
+
+	return len(p), nil
+}
+
+// Follow returns the recent lines and a channel receiving new ones until
+// the returned stop function is called
+func (l *Log) Follow() ([]string, <-chan string, func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	follower := make(chan string, 64)
+	l.followers[follower] = struct{}{}
+	stop := func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		delete(l.followers, follower)
+	}
+
+	return append([]string(nil), l.lines...), follower, stop
+}
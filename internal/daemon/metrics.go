@@ -0,0 +1,154 @@
+package daemon
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Metrics tracks counters exposed by the watch daemon
+type Metrics struct {
+	mu             sync.Mutex
+	syncsTotal     int64
+	failuresTotal  int64
+	lastSync       time.Time
+	lastSuccess    time.Time
+	lastErr        error
+	pendingChanges int
+	syncing        bool
+}
+
+// Status is what the control API reports about the watch daemon
+type Status struct {
+	// Mode is "interval" or "two-way"
+	Mode string `json:"mode"`
+
+	// Syncing is set while a sync runs
+	Syncing bool `json:"syncing"`
+
+	Syncs       int64      `json:"syncs"`
+	Failures    int64      `json:"failures"`
+	LastSync    *time.Time `json:"lastSync,omitempty"`
+	LastSuccess *time.Time `json:"lastSuccess,omitempty"`
+
+	// LastError is the error of the last sync, if it failed
+	LastError string `json:"lastError,omitempty"`
+
+	PendingChanges int `json:"pendingChanges"`
+
+	// Paused is set while automatic syncing is paused, until PausedUntil
+	// or, without it, 'resume'
+	Paused      bool       `json:"paused"`
+	PausedUntil *time.Time `json:"pausedUntil,omitempty"`
+}
+
+// NewMetrics creates an empty Metrics instance
+func NewMetrics() *Metrics {
+	return &Metrics{}
+}
+
+// RecordSync records the outcome of a sync attempt
+func (m *Metrics) RecordSync(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.syncsTotal++
+	m.lastSync = now
+	m.lastErr = err
+
+	if err != nil {
+		m.failuresTotal++
+		return
+	}
+
+	m.lastSuccess = now
+}
+
+// SetPendingChanges records the number of local files not yet pushed
+func (m *Metrics) SetPendingChanges(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.pendingChanges = n
+}
+
+// SetSyncing records whether a sync is running
+func (m *Metrics) SetSyncing(syncing bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.syncing = syncing
+}
+
+// Status returns the counters as a Status, leaving the fields the
+// metrics do not track empty
+func (m *Metrics) Status() Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	status := Status{
+		Syncing:        m.syncing,
+		Syncs:          m.syncsTotal,
+		Failures:       m.failuresTotal,
+		PendingChanges: m.pendingChanges,
+	}
+	if !m.lastSync.IsZero() {
+		lastSync := m.lastSync
+		status.LastSync = &lastSync
+	}
+	if !m.lastSuccess.IsZero() {
+		lastSuccess := m.lastSuccess
+		status.LastSuccess = &lastSuccess
+	}
+	if m.lastErr != nil {
+		status.LastError = m.lastErr.Error()
+	}
+	return status
+}
+
+// LastError returns the error of the most recent sync attempt, if any
+func (m *Metrics) LastError() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.lastErr
+}
+
+// WritePrometheus writes all metrics in the Prometheus text exposition format
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	metrics := []struct {
+		name  string
+		help  string
+		kind  string
+		value float64
+	}{
+		{"opencode_sync_syncs_total", "Total number of sync attempts.", "counter", float64(m.syncsTotal)},
+		{"opencode_sync_failures_total", "Total number of failed sync attempts.", "counter", float64(m.failuresTotal)},
+		{"opencode_sync_last_sync_timestamp_seconds", "Unix time of the last sync attempt.", "gauge", unixSeconds(m.lastSync)},
+		{"opencode_sync_last_success_timestamp_seconds", "Unix time of the last successful sync.", "gauge", unixSeconds(m.lastSuccess)},
+		{"opencode_sync_pending_changes", "Number of local files not yet pushed.", "gauge", float64(m.pendingChanges)},
+	}
+
+	for _, metric := range metrics {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %s\n",
+			metric.name, metric.help, metric.name, metric.kind, metric.name,
+			strconv.FormatFloat(metric.value, 'f', -1, 64)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func unixSeconds(t time.Time) float64 {
+	if t.IsZero() {
+		return 0
+	}
+	return float64(t.Unix())
+}
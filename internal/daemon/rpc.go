@@ -0,0 +1,166 @@
+package daemon
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/GareArc/opencode-sync/internal/capability"
+)
+
+func init() {
+	capability.Register(capability.Capability{
+		Name:        "rpc-socket",
+		Kind:        capability.KindFeature,
+		Description: "JSON-RPC control socket for editor integrations",
+	})
+}
+
+// JSON-RPC 2.0 error codes
+const (
+	RPCParseError     = -32700
+	RPCInvalidRequest = -32600
+	RPCMethodNotFound = -32601
+	RPCInvalidParams  = -32602
+
+	// RPCOperationFailed is returned when a method ran and failed
+	RPCOperationFailed = -32000
+)
+
+// RPCError is a JSON-RPC error. Methods return one to choose the code and
+// data; any other error becomes RPCOperationFailed.
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+func (e *RPCError) Error() string {
+	return e.Message
+}
+
+// RPCMethod handles a call with its raw params, which may be empty
+type RPCMethod func(ctx context.Context, params json.RawMessage) (any, error)
+
+// RPCServer answers JSON-RPC 2.0 requests sent as one JSON object per
+// line, on every connection of a listener
+type RPCServer struct {
+	methods map[string]RPCMethod
+	wg      sync.WaitGroup
+}
+
+// NewRPCServer creates an RPCServer for the given methods
+func NewRPCServer(methods map[string]RPCMethod) *RPCServer {
+	return &RPCServer{methods: methods}
+}
+
+// rpcRequest is a JSON-RPC request or notification
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// rpcResponse is a JSON-RPC response
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+// Serve answers connections on ln until ctx is done or ln is closed
+func (s *RPCServer) Serve(ctx context.Context, ln net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			s.wg.Wait()
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.serveConn(ctx, conn)
+		}()
+	}
+}
+
+// serveConn answers the requests on conn one after another
+func (s *RPCServer) serveConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+
+	// Close the connection when the server stops, which ends the scan
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	encoder := json.NewEncoder(conn)
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		response := s.call(ctx, line)
+		if response == nil {
+			continue
+		}
+		if err := encoder.Encode(response); err != nil {
+			return
+		}
+	}
+}
+
+// call runs one request. Notifications get no response.
+func (s *RPCServer) call(ctx context.Context, line []byte) *rpcResponse {
+	var req rpcRequest
+	if err := json.Unmarshal(line, &req); err != nil {
+		return &rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &RPCError{Code: RPCParseError, Message: "parse error"}}
+	}
+
+	id := req.ID
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return &rpcResponse{JSONRPC: "2.0", ID: id, Error: &RPCError{Code: RPCInvalidRequest, Message: "invalid request"}}
+	}
+
+	var result any
+	var err error
+	if method, ok := s.methods[req.Method]; ok {
+		result, err = method(ctx, req.Params)
+	} else {
+		err = &RPCError{Code: RPCMethodNotFound, Message: "method not found: " + req.Method}
+	}
+
+	if req.ID == nil {
+		return nil
+	}
+	if err != nil {
+		var rpcErr *RPCError
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &RPCError{Code: RPCOperationFailed, Message: err.Error()}
+		}
+		return &rpcResponse{JSONRPC: "2.0", ID: id, Error: rpcErr}
+	}
+	if result == nil {
+		result = struct{}{}
+	}
+	return &rpcResponse{JSONRPC: "2.0", ID: id, Result: result}
+}
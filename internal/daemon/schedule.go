@@ -0,0 +1,95 @@
+package daemon
+
+import "time"
+
+// MinTick is the shortest time between two checks for due targets, so a
+// target that is pushed immediately doesn't keep watch busy hashing files
+const MinTick = 10 * time.Second
+
+// Schedule tracks when the changes to each sync target were last pushed,
+// so targets with their own interval are pushed more or less often than
+// the rest. The rest is the target "".
+type Schedule struct {
+	intervals map[string]time.Duration
+	last      map[string]time.Time
+
+	// minPush is the least time between two pushes, and lastPush the
+	// time of the last one
+	minPush  time.Duration
+	lastPush time.Time
+}
+
+// NewSchedule creates a schedule pushing the rest every interval and each
+// target in targets at its own interval
+func NewSchedule(interval time.Duration, targets map[string]time.Duration) *Schedule {
+	intervals := map[string]time.Duration{"": interval}
+	for name, d := range targets {
+		if name != "" {
+			intervals[name] = d
+		}
+	}
+	return &Schedule{intervals: intervals, last: make(map[string]time.Time)}
+}
+
+// Targets reports whether any target has its own interval
+func (s *Schedule) Targets() bool {
+	return len(s.intervals) > 1
+}
+
+// Tick returns how often watch should check for due targets: the
+// shortest interval, but not less than MinTick. It returns 0 if no
+// interval is positive and there are no targets.
+func (s *Schedule) Tick() time.Duration {
+	var tick time.Duration
+	for name, d := range s.intervals {
+		if name != "" || d > 0 {
+			d = max(d, MinTick)
+			if tick == 0 || d < tick {
+				tick = d
+			}
+		}
+	}
+	return tick
+}
+
+// Due returns the targets whose interval has passed since their changes
+// were last pushed, or nil if all of them are due
+func (s *Schedule) Due(now time.Time) map[string]bool {
+	due := make(map[string]bool)
+	for name, d := range s.intervals {
+		if last, ok := s.last[name]; !ok || now.Sub(last) >= d {
+			due[name] = true
+		}
+	}
+
+	if len(due) == len(s.intervals) {
+		return nil
+	}
+	return due
+}
+
+// SetMinPush sets the least time between two pushes
+func (s *Schedule) SetMinPush(d time.Duration) {
+	s.minPush = d
+}
+
+// PushWait returns how long a push has to wait at now to keep the least
+// time between two pushes, or 0 if it can go ahead
+func (s *Schedule) PushWait(now time.Time) time.Duration {
+	if s.lastPush.IsZero() {
+		return 0
+	}
+	return max(s.lastPush.Add(s.minPush).Sub(now), 0)
+}
+
+// Pushed records that the changes to targets were pushed at now
+func (s *Schedule) Pushed(targets []string, now time.Time) {
+	if len(targets) > 0 {
+		s.lastPush = now
+	}
+	for _, name := range targets {
+		if _, ok := s.intervals[name]; ok {
+			s.last[name] = now
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/GareArc/opencode-sync/internal/capability"
+)
+
+func init() {
+	capability.Register(capability.Capability{
+		Name:        "metrics",
+		Kind:        capability.KindFeature,
+		Description: "Health and metrics endpoint for watch",
+	})
+}
+
+// Server exposes /healthz and /metrics for the watch daemon, and the
+// control API once enabled
+type Server struct {
+	metrics *Metrics
+	mux     *http.ServeMux
+	server  *http.Server
+	addr    net.Addr
+}
+
+// NewServer creates a new Server listening on addr
+func NewServer(addr string, m *Metrics) *Server {
+	mux := http.NewServeMux()
+	s := &Server{metrics: m, mux: mux}
+
+	mux.HandleFunc("/healthz", s.handleHealth)
+	mux.HandleFunc("/metrics", s.handleMetrics)
+
+	s.server = &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	return s
+}
+
+// Start starts listening and serves requests in the background
+func (s *Server) Start() error {
+	ln, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", s.server.Addr, err)
+	}
+
+	s.addr = ln.Addr()
+
+	go func() {
+		_ = s.server.Serve(ln)
+	}()
+
+	return nil
+}
+
+// EnableAPI serves the control API. It must be called before Start.
+func (s *Server) EnableAPI(api *API) {
+	api.register(s.mux)
+}
+
+// Addr returns the address the server listens on once started, with the
+// port chosen when it was 0
+func (s *Server) Addr() net.Addr {
+	return s.addr
+}
+
+// Close shuts down the server
+func (s *Server) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := s.server.Shutdown(ctx); err != nil {
+		// Clients following the logs never finish by themselves
+		return s.server.Close()
+	}
+	return nil
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	if err := s.metrics.LastError(); err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(w, "last sync failed: %v\n", err)
+		return
+	}
+
+	fmt.Fprintln(w, "ok")
+}
+
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = s.metrics.WritePrometheus(w)
+}
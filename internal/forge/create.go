@@ -0,0 +1,78 @@
+package forge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ErrRepoExists is returned when the repository to create already exists
+var ErrRepoExists = errors.New("repository already exists")
+
+// Created is a new repository on a hosting service
+type Created struct {
+	// HTTPSURL and SSHURL are the remote URLs to clone it with
+	HTTPSURL string
+	SSHURL   string
+
+	// WebURL is its page on the service
+	WebURL string
+}
+
+// CreateRepo creates a private repository called name, owned by the user
+// the API token belongs to
+func CreateRepo(ctx context.Context, kind, host, name string) (*Created, error) {
+	r := &Repo{Kind: kind, Host: host}
+	token, err := r.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	const description = "OpenCode configuration, synced by opencode-sync"
+
+	if kind == GitLab {
+		var project struct {
+			HTTPURL string `json:"http_url_to_repo"`
+			SSHURL  string `json:"ssh_url_to_repo"`
+			WebURL  string `json:"web_url"`
+		}
+		err := r.request(ctx, token, http.MethodPost, "/projects", map[string]any{
+			"name":        name,
+			"path":        name,
+			"description": description,
+			"visibility":  "private",
+		}, &project)
+		if err != nil {
+			return nil, createError(name, err)
+		}
+		return &Created{HTTPSURL: project.HTTPURL, SSHURL: project.SSHURL, WebURL: project.WebURL}, nil
+	}
+
+	// GitHub and Gitea share the request and response
+	var repo struct {
+		CloneURL string `json:"clone_url"`
+		SSHURL   string `json:"ssh_url"`
+		HTMLURL  string `json:"html_url"`
+	}
+	err = r.request(ctx, token, http.MethodPost, "/user/repos", map[string]any{
+		"name":        name,
+		"description": description,
+		"private":     true,
+	}, &repo)
+	if err != nil {
+		return nil, createError(name, err)
+	}
+	return &Created{HTTPSURL: repo.CloneURL, SSHURL: repo.SSHURL, WebURL: repo.HTMLURL}, nil
+}
+
+// createError explains why a repository could not be created
+func createError(name string, err error) error {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && (apiErr.Status == http.StatusConflict ||
+		strings.Contains(apiErr.Message, "already exists") || strings.Contains(apiErr.Message, "already been taken")) {
+		return fmt.Errorf("%w: %s", ErrRepoExists, name)
+	}
+	return fmt.Errorf("failed to create repository %s: %w", name, err)
+}
@@ -0,0 +1,248 @@
+// Package forge talks to the web APIs of git hosting services, for what
+// plain git cannot do, such as opening pull requests.
+package forge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/GareArc/opencode-sync/internal/capability"
+	"github.com/GareArc/opencode-sync/internal/git"
+)
+
+func init() {
+	capability.Register(capability.Capability{
+		Name:        "pull-requests",
+		Kind:        capability.KindFeature,
+		Description: "Create remotes and propose changes on GitHub, GitLab and Gitea",
+	})
+}
+
+// Supported hosting services
+const (
+	GitHub = "github"
+	GitLab = "gitlab"
+	Gitea  = "gitea"
+)
+
+// Kinds lists the supported hosting services
+var Kinds = []string{GitHub, GitLab, Gitea}
+
+// DefaultHosts are the public instances of the hosting services
+var DefaultHosts = map[string]string{
+	GitHub: "github.com",
+	GitLab: "gitlab.com",
+}
+
+// tokenEnv are the environment variables checked for an API token, in
+// order, before git's stored credentials
+var tokenEnv = map[string][]string{
+	GitHub: {"GITHUB_TOKEN", "GH_TOKEN"},
+	GitLab: {"GITLAB_TOKEN"},
+	Gitea:  {"GITEA_TOKEN"},
+}
+
+// ErrNoToken is returned when no API token is available
+var ErrNoToken = errors.New("no API token found")
+
+// ErrUnknownForge is returned when a remote's hosting service cannot be
+// told from its URL
+var ErrUnknownForge = errors.New("unknown hosting service")
+
+// httpClient is used for all API requests
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// Repo is a repository on a hosting service
+type Repo struct {
+	// Kind is one of Kinds
+	Kind string
+
+	// Host is the web host, with a port if it is not the default
+	Host string
+
+	// Path is owner/name, or group/subgroup/name on GitLab
+	Path string
+}
+
+// Parse returns the repository a remote URL points to. kind names the
+// hosting service; if empty it is guessed from the host name.
+func Parse(remoteURL, kind string) (*Repo, error) {
+	host, path, err := splitRemote(remoteURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if kind == "" {
+		switch {
+		case strings.Contains(host, "github"):
+			kind = GitHub
+		case strings.Contains(host, "gitlab"):
+			kind = GitLab
+		case strings.Contains(host, "gitea"), host == "codeberg.org":
+			kind = Gitea
+		default:
+			return nil, fmt.Errorf("%w: cannot tell which service hosts %s", ErrUnknownForge, host)
+		}
+	}
+	if _, ok := tokenEnv[kind]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownForge, kind)
+	}
+
+	return &Repo{Kind: kind, Host: host, Path: path}, nil
+}
+
+// splitRemote returns the web host and repository path of an HTTPS or SSH
+// remote URL
+func splitRemote(remoteURL string) (host, path string, err error) {
+	if sshHost, _, ok := git.SSHEndpoint(remoteURL); ok {
+		// The API is served over HTTPS whatever port SSH listens on
+		host = sshHost
+		if _, rest, found := strings.Cut(remoteURL, "://"); found {
+			_, path, _ = strings.Cut(rest, "/")
+		} else {
+			_, path, _ = strings.Cut(remoteURL, ":")
+		}
+	} else {
+		u, parseErr := url.Parse(remoteURL)
+		if parseErr != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return "", "", fmt.Errorf("%s is not an HTTPS or SSH remote URL", remoteURL)
+		}
+		host, path = u.Host, u.Path
+	}
+
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	if !strings.Contains(path, "/") {
+		return "", "", fmt.Errorf("%s does not name an owner and repository", remoteURL)
+	}
+	return host, path, nil
+}
+
+// Token returns an API token for the repository's host: from an
+// environment variable, or the token git's credential helper stored when
+// you signed in (e.g. with 'gh auth login' or 'glab auth login')
+func (r *Repo) Token() (string, error) {
+	if _, ok := tokenEnv[r.Kind]; !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownForge, r.Kind)
+	}
+
+	for _, name := range tokenEnv[r.Kind] {
+		if token := os.Getenv(name); token != "" {
+			return token, nil
+		}
+	}
+
+	token, err := git.StoredPassword(r.Host)
+	if err != nil {
+		return "", err
+	}
+	if token == "" {
+		return "", fmt.Errorf("%w for %s. Sign in with git's credential helper or set %s", ErrNoToken, r.Host, tokenEnv[r.Kind][0])
+	}
+	return token, nil
+}
+
+// apiURL returns the base URL of the service's REST API
+func (r *Repo) apiURL() string {
+	switch {
+	case r.Kind == GitHub && r.Host == "github.com":
+		return "https://api.github.com"
+	case r.Kind == GitHub:
+		return "https://" + r.Host + "/api/v3"
+	case r.Kind == Gitea:
+		return "https://" + r.Host + "/api/v1"
+	default:
+		return "https://" + r.Host + "/api/v4"
+	}
+}
+
+// APIError is a request the hosting service refused
+type APIError struct {
+	Status  int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("API request failed with status %d", e.Status)
+	}
+	return fmt.Sprintf("API request failed with status %d: %s", e.Status, e.Message)
+}
+
+// request sends a JSON request to the API and decodes the response into
+// out, if given
+func (r *Repo) request(ctx context.Context, token, method, endpoint string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, r.apiURL()+endpoint, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if r.Kind == Gitea {
+		req.Header.Set("Authorization", "token "+token)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.Kind == GitHub {
+		req.Header.Set("Accept", "application/vnd.github+json")
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach %s: %w", r.Host, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response from %s: %w", r.Host, err)
+	}
+
+	if resp.StatusCode >= 300 {
+		// GitHub and Gitea send a message, GitHub validation details in
+		// errors, and GitLab either a message or an error
+		var failure struct {
+			Message any `json:"message"`
+			Error   any `json:"error"`
+			Errors  []struct {
+				Message string `json:"message"`
+			} `json:"errors"`
+		}
+		_ = json.Unmarshal(data, &failure)
+		var messages []string
+		for _, message := range []any{failure.Message, failure.Error} {
+			if message != nil {
+				messages = append(messages, fmt.Sprint(message))
+			}
+		}
+		for _, detail := range failure.Errors {
+			if detail.Message != "" {
+				messages = append(messages, detail.Message)
+			}
+		}
+		return &APIError{Status: resp.StatusCode, Message: strings.Join(messages, "; ")}
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("failed to decode response from %s: %w", r.Host, err)
+		}
+	}
+	return nil
+}
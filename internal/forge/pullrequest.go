@@ -0,0 +1,124 @@
+package forge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// PullRequest describes changes proposed for review
+type PullRequest struct {
+	Title string
+
+	// Body is the description, in Markdown
+	Body string
+
+	// Head is the branch holding the changes
+	Head string
+
+	// Base is the branch they are proposed for
+	Base string
+}
+
+// OpenPullRequest opens a pull request (a merge request on GitLab) and
+// returns its web URL. If one is already open for the same branches, it
+// returns that one's URL instead, with existing set; pushing to the head
+// branch has already updated it.
+func (r *Repo) OpenPullRequest(ctx context.Context, token string, pr PullRequest) (webURL string, existing bool, err error) {
+	if r.Kind == GitLab {
+		return r.openMergeRequest(ctx, token, pr)
+	}
+
+	var created struct {
+		HTMLURL string `json:"html_url"`
+	}
+	err = r.request(ctx, token, http.MethodPost, "/repos/"+r.Path+"/pulls", map[string]any{
+		"title": pr.Title,
+		"body":  pr.Body,
+		"head":  pr.Head,
+		"base":  pr.Base,
+	}, &created)
+	if err == nil {
+		return created.HTMLURL, false, nil
+	}
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || (apiErr.Status != http.StatusUnprocessableEntity && apiErr.Status != http.StatusConflict) {
+		return "", false, fmt.Errorf("failed to open pull request: %w", err)
+	}
+
+	// GitHub and Gitea refuse a second pull request for the same branches
+	if r.Kind == Gitea {
+		return r.findGiteaPullRequest(ctx, token, pr, err)
+	}
+	owner, _, _ := strings.Cut(r.Path, "/")
+	query := url.Values{"head": {owner + ":" + pr.Head}, "base": {pr.Base}, "state": {"open"}}
+	var open []struct {
+		HTMLURL string `json:"html_url"`
+	}
+	if lookupErr := r.request(ctx, token, http.MethodGet, "/repos/"+r.Path+"/pulls?"+query.Encode(), nil, &open); lookupErr != nil || len(open) == 0 {
+		return "", false, fmt.Errorf("failed to open pull request: %w", err)
+	}
+	return open[0].HTMLURL, true, nil
+}
+
+// findGiteaPullRequest returns the open Gitea pull request for the same
+// branches as pr, or createErr if there is none
+func (r *Repo) findGiteaPullRequest(ctx context.Context, token string, pr PullRequest, createErr error) (string, bool, error) {
+	var open []struct {
+		HTMLURL string `json:"html_url"`
+		Head    struct {
+			Ref string `json:"ref"`
+		} `json:"head"`
+		Base struct {
+			Ref string `json:"ref"`
+		} `json:"base"`
+	}
+	if err := r.request(ctx, token, http.MethodGet, "/repos/"+r.Path+"/pulls?state=open&limit=50", nil, &open); err != nil {
+		return "", false, fmt.Errorf("failed to open pull request: %w", createErr)
+	}
+
+	for _, candidate := range open {
+		if candidate.Head.Ref == pr.Head && candidate.Base.Ref == pr.Base {
+			return candidate.HTMLURL, true, nil
+		}
+	}
+	return "", false, fmt.Errorf("failed to open pull request: %w", createErr)
+}
+
+// openMergeRequest is OpenPullRequest for GitLab
+func (r *Repo) openMergeRequest(ctx context.Context, token string, pr PullRequest) (string, bool, error) {
+	project := "/projects/" + url.PathEscape(r.Path)
+
+	var created struct {
+		WebURL string `json:"web_url"`
+	}
+	err := r.request(ctx, token, http.MethodPost, project+"/merge_requests", map[string]any{
+		"title":                pr.Title,
+		"description":          pr.Body,
+		"source_branch":        pr.Head,
+		"target_branch":        pr.Base,
+		"remove_source_branch": true,
+	}, &created)
+	if err == nil {
+		return created.WebURL, false, nil
+	}
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusConflict {
+		return "", false, fmt.Errorf("failed to open merge request: %w", err)
+	}
+
+	// GitLab refuses a second merge request for the same branches
+	query := url.Values{"source_branch": {pr.Head}, "target_branch": {pr.Base}, "state": {"opened"}}
+	var open []struct {
+		WebURL string `json:"web_url"`
+	}
+	if lookupErr := r.request(ctx, token, http.MethodGet, project+"/merge_requests?"+query.Encode(), nil, &open); lookupErr != nil || len(open) == 0 {
+		return "", false, fmt.Errorf("failed to open merge request: %w", err)
+	}
+	return open[0].WebURL, true, nil
+}
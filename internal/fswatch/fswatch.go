@@ -0,0 +1,43 @@
+// Package fswatch reports changes below directory trees, with inotify on
+// Linux and by polling elsewhere.
+package fswatch
+
+// eventBuffer is how many changed paths are queued for the receiver.
+// Further changes are dropped until it catches up; receivers only need to
+// know that something changed.
+const eventBuffer = 64
+
+// Watcher reports paths that changed below its roots
+type Watcher struct {
+	// Events receives the path of each changed file or directory
+	Events <-chan string
+
+	stop func() error
+}
+
+// New starts watching the directory trees under roots. Roots that do not
+// exist are ignored. Paths for which skip returns true are not reported,
+// and skipped directories are not descended into.
+func New(roots []string, skip func(path string) bool) (*Watcher, error) {
+	events := make(chan string, eventBuffer)
+
+	stop, err := start(roots, skip, events)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Watcher{Events: events, stop: stop}, nil
+}
+
+// Close stops watching
+func (w *Watcher) Close() error {
+	return w.stop()
+}
+
+// send reports path without blocking
+func send(events chan<- string, path string) {
+	select {
+	case events <- path:
+	default:
+	}
+}
@@ -0,0 +1,144 @@
+//go:build linux
+
+package fswatch
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"github.com/GareArc/opencode-sync/internal/capability"
+	"golang.org/x/sys/unix"
+)
+
+func init() {
+	capability.Register(capability.Capability{
+		Name:        "inotify",
+		Kind:        capability.KindFeature,
+		Description: "Instant change detection for watch --two-way",
+	})
+}
+
+// Method is how changes are detected on this platform
+const Method = "inotify"
+
+// watchMask selects the inotify events that mean a file changed
+const watchMask = unix.IN_CLOSE_WRITE | unix.IN_CREATE | unix.IN_DELETE |
+	unix.IN_MODIFY | unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_ATTRIB
+
+// inotify watches every directory of the trees, since inotify itself is
+// not recursive
+type inotify struct {
+	file *os.File
+	fd   int
+	skip func(path string) bool
+
+	// dirs maps watch descriptors to directories
+	dirs map[int]string
+}
+
+func start(roots []string, skip func(path string) bool, events chan<- string) (func() error, error) {
+	// Non-blocking, so closing the file interrupts a pending read
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start inotify: %w", err)
+	}
+
+	in := &inotify{
+		file: os.NewFile(uintptr(fd), "inotify"),
+		fd:   fd,
+		skip: skip,
+		dirs: map[int]string{},
+	}
+
+	for _, root := range roots {
+		if err := in.addTree(root); err != nil {
+			in.file.Close()
+			return nil, err
+		}
+	}
+
+	go in.read(events)
+	return in.file.Close, nil
+}
+
+// addTree watches dir and every directory below it
+func (in *inotify) addTree(dir string) error {
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Gone or unreadable: nothing to watch there
+			if path == dir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if in.skip(path) {
+			return filepath.SkipDir
+		}
+
+		wd, err := unix.InotifyAddWatch(in.fd, path, watchMask)
+		if err != nil {
+			if err == unix.ENOSPC {
+				return fmt.Errorf("failed to watch %s: too many directories; raise fs.inotify.max_user_watches", path)
+			}
+			return nil
+		}
+		in.dirs[wd] = path
+		return nil
+	})
+	return err
+}
+
+// read reports events until the file is closed
+func (in *inotify) read(events chan<- string) {
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	for {
+		n, err := in.file.Read(buf)
+		if err != nil {
+			return
+		}
+
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + unix.SizeofInotifyEvent
+			name := string(bytes.TrimRight(buf[nameStart:nameStart+int(event.Len)], "\x00"))
+			offset = nameStart + int(event.Len)
+
+			if event.Mask&unix.IN_Q_OVERFLOW != 0 {
+				// Events were lost; report that something changed
+				for _, dir := range in.dirs {
+					send(events, dir)
+					break
+				}
+				continue
+			}
+
+			dir, ok := in.dirs[int(event.Wd)]
+			if !ok {
+				continue
+			}
+			if event.Mask&unix.IN_IGNORED != 0 {
+				delete(in.dirs, int(event.Wd))
+				continue
+			}
+
+			path := filepath.Join(dir, name)
+			if in.skip(path) {
+				continue
+			}
+
+			// New directories need watches of their own
+			if event.Mask&unix.IN_ISDIR != 0 && event.Mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 {
+				_ = in.addTree(path)
+			}
+
+			send(events, path)
+		}
+	}
+}
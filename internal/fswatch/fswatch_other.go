@@ -0,0 +1,84 @@
+//go:build !linux
+
+package fswatch
+
+import (
+	"io/fs"
+	"path/filepath"
+	"time"
+)
+
+// Method is how changes are detected on this platform
+const Method = "polling"
+
+// pollInterval is how often the trees are rescanned
+const pollInterval = 2 * time.Second
+
+// fileState is what a rescan compares
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+func start(roots []string, skip func(path string) bool, events chan<- string) (func() error, error) {
+	done := make(chan struct{})
+	last := scan(roots, skip)
+
+	go func() {
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			current := scan(roots, skip)
+			for path, st := range current {
+				if old, ok := last[path]; !ok || old != st {
+					send(events, path)
+				}
+			}
+			for path := range last {
+				if _, ok := current[path]; !ok {
+					send(events, path)
+				}
+			}
+			last = current
+		}
+	}()
+
+	return func() error {
+		close(done)
+		return nil
+	}, nil
+}
+
+// scan records the modification time and size of every file in the trees
+func scan(roots []string, skip func(path string) bool) map[string]fileState {
+	files := map[string]fileState{}
+	for _, root := range roots {
+		_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if skip(path) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() {
+				return nil
+			}
+
+			if info, err := d.Info(); err == nil {
+				files[path] = fileState{modTime: info.ModTime(), size: info.Size()}
+			}
+			return nil
+		})
+	}
+	return files
+}
@@ -1,44 +1,366 @@
 package git
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/GareArc/opencode-sync/internal/capability"
+	"github.com/go-git/go-billy/v5/osfs"
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
 	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/filesystem"
 )
 
+func init() {
+	capability.Register(capability.Capability{
+		Name:        "git",
+		Kind:        capability.KindBackend,
+		Description: "Sync through a Git remote using the git CLI",
+	})
+}
+
+// NoTerminalPrompt stops git from asking for credentials on the terminal
+// when no credential helper supplies them, so unattended runs fail
+// instead of waiting for input
+var NoTerminalPrompt bool
+
+// AuthorName and AuthorEmail override git's user.name and user.email for
+// commits made by opencode-sync. Empty leaves git's configuration alone.
+var (
+	AuthorName  string
+	AuthorEmail string
+)
+
+// commandEnv returns the environment for git processes, or nil to
+// inherit the current one
+func commandEnv() []string {
+	var env []string
+	if SSHCommand != "" {
+		env = append(env, "GIT_SSH_COMMAND="+SSHCommand)
+	}
+	if NoTerminalPrompt {
+		env = append(env, "GIT_TERMINAL_PROMPT=0")
+	}
+	if AuthorName != "" {
+		env = append(env, "GIT_AUTHOR_NAME="+AuthorName, "GIT_COMMITTER_NAME="+AuthorName)
+	}
+	if AuthorEmail != "" {
+		env = append(env, "GIT_AUTHOR_EMAIL="+AuthorEmail, "GIT_COMMITTER_EMAIL="+AuthorEmail)
+	}
+	env = append(env, transportEnv()...)
+
+	if len(env) == 0 {
+		return nil
+	}
+	return append(os.Environ(), env...)
+}
+
 func runGitCommand(dir string, args ...string) error {
-	cmd := exec.Command("git", args...)
+	return runGitCommandContext(context.Background(), dir, args...)
+}
+
+// runGitCommandContext is runGitCommand with cancellation. A cancelled
+// git process is interrupted rather than killed so it can clean up its
+// lock files, and the context error is returned.
+func runGitCommandContext(ctx context.Context, dir string, args ...string) error {
+	_, err := runGitCommandStderrContext(ctx, dir, args...)
+	return err
+}
+
+// runGitCommandStderr streams output like runGitCommand and also returns
+// what was written to stderr
+func runGitCommandStderr(dir string, args ...string) (string, error) {
+	return runGitCommandStderrContext(context.Background(), dir, args...)
+}
+
+func runGitCommandStderrContext(ctx context.Context, dir string, args ...string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", args...)
 	cmd.Dir = dir
+	cmd.Env = commandEnv()
 	cmd.Stdout = os.Stdout
+	cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
+	cmd.Cancel = func() error {
+		if err := cmd.Process.Signal(os.Interrupt); err != nil {
+			return cmd.Process.Kill()
+		}
+		return nil
+	}
+	cmd.WaitDelay = 10 * time.Second
+
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return stderr.String(), ctx.Err()
+	}
+	return stderr.String(), err
+}
+
+func runGitOutput(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = commandEnv()
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// runGitOutputEnv is runGitOutput with extra environment variables
+func runGitOutputEnv(dir string, env []string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	base := commandEnv()
+	if base == nil {
+		base = os.Environ()
+	}
+	cmd.Env = append(base, env...)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// runGitInput is runGitOutputEnv with input written to git's stdin
+func runGitInput(dir string, env []string, input []byte, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	base := commandEnv()
+	if base == nil {
+		base = os.Environ()
+	}
+	cmd.Env = append(base, env...)
+	cmd.Stdin = bytes.NewReader(input)
 	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// runGitOutputStderrContext returns the output of a git command that talks
+// to a remote, along with what it wrote to stderr
+func runGitOutputStderrContext(ctx context.Context, dir string, args ...string) (string, string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = commandEnv()
+	cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
+	cmd.WaitDelay = 10 * time.Second
+
+	out, err := cmd.Output()
+	if ctx.Err() != nil {
+		return string(out), stderr.String(), ctx.Err()
+	}
+	return string(out), stderr.String(), err
+}
+
+// outputLines splits command output into its non-empty lines
+func outputLines(out string) []string {
+	var lines []string
+	for _, line := range strings.Split(out, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
 }
 
 type BuiltinGit struct {
-	path string
-	repo *git.Repository
+	path    string
+	gitDir  string
+	repo    *git.Repository
+	now     func() time.Time
+	timeout time.Duration
+
+	// subdir is the slash-separated directory of the working tree that
+	// paths are relative to (see SetSubdir)
+	subdir string
+}
+
+// DefaultTimeout limits remote operations (clone, fetch, pull, push) of
+// new repositories. Zero means no limit.
+var DefaultTimeout time.Duration
+
+// MaxReadSize is the largest file ReadFileAt reads into memory. Zero means
+// no limit.
+var MaxReadSize int64
+
+// FileTooLargeError is returned for a file that would have to be read
+// into memory whole but is larger than the limit
+type FileTooLargeError struct {
+	Path  string
+	Size  int64
+	Limit int64
+}
+
+func (e *FileTooLargeError) Error() string {
+	return fmt.Sprintf("%s is %d MB, larger than the %d MB limit for files read into memory", e.Path, (e.Size+1<<20-1)>>20, e.Limit>>20)
 }
 
 func NewBuiltinGit(path string) *BuiltinGit {
 	return &BuiltinGit{
-		path: path,
+		path:    path,
+		now:     time.Now,
+		timeout: DefaultTimeout,
+	}
+}
+
+// SetGitDir makes Init and Clone keep the repository's data in dir
+// instead of a .git directory. The working tree then only holds a .git
+// file pointing there, which Open follows.
+func (g *BuiltinGit) SetGitDir(dir string) {
+	g.gitDir = dir
+}
+
+// SetSubdir makes the paths given to and returned by the repository
+// relative to dir, a subdirectory of the working tree. Staging, listing
+// and restoring are limited to dir, and files outside it are left alone,
+// so the repository can hold other files as well. The auth branch keeps
+// its files in dir too.
+func (g *BuiltinGit) SetSubdir(dir string) {
+	g.subdir = strings.Trim(path.Clean("/"+filepath.ToSlash(dir)), "/")
+}
+
+// repoPath returns where a path relative to the subdir is in the
+// repository
+func (g *BuiltinGit) repoPath(p string) string {
+	if g.subdir == "" {
+		return p
+	}
+	return path.Join(g.subdir, filepath.ToSlash(p))
+}
+
+// subdirPath returns a path relative to the repository root relative to
+// the subdir, or ok false if it lies outside it
+func (g *BuiltinGit) subdirPath(p string) (string, bool) {
+	if g.subdir == "" {
+		return p, true
+	}
+	return strings.CutPrefix(filepath.ToSlash(p), g.subdir+"/")
+}
+
+// relativeArgs makes a git diff or log show paths relative to the subdir
+// and leave out the others
+func (g *BuiltinGit) relativeArgs() []string {
+	if g.subdir == "" {
+		return nil
+	}
+	return []string{"--relative=" + g.subdir + "/"}
+}
+
+// pathspec limits a git command to the subdir
+func (g *BuiltinGit) pathspec() []string {
+	if g.subdir == "" {
+		return nil
+	}
+	return []string{"--", g.subdir}
+}
+
+// GitDir returns the directory holding the repository's data, following
+// a .git file in the working tree
+func (g *BuiltinGit) GitDir() string {
+	dotGit := filepath.Join(g.path, ".git")
+	data, err := os.ReadFile(dotGit)
+	if err != nil {
+		// A .git directory, or no repository yet
+		if g.gitDir != "" {
+			return g.gitDir
+		}
+		return dotGit
+	}
+
+	dir := strings.TrimSpace(strings.TrimPrefix(string(data), "gitdir:"))
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(g.path, dir)
+	}
+	return dir
+}
+
+// worktree returns the working tree. go-git only reads info/exclude from
+// a .git directory, so it is loaded here for a separate git dir.
+func (g *BuiltinGit) worktree() (*git.Worktree, error) {
+	w, err := g.repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+
+	if info, err := os.Stat(filepath.Join(g.path, ".git")); err == nil && !info.IsDir() {
+		data, err := os.ReadFile(filepath.Join(g.GitDir(), "info", "exclude"))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read info/exclude: %w", err)
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimRight(line, "\r"); line != "" && !strings.HasPrefix(line, "#") {
+				w.Excludes = append(w.Excludes, gitignore.ParsePattern(line, nil))
+			}
+		}
+	}
+
+	return w, nil
+}
+
+// SetTimeout sets the time limit for remote operations. Zero means no limit.
+func (g *BuiltinGit) SetTimeout(timeout time.Duration) {
+	g.timeout = timeout
+}
+
+// remoteContext applies the remote operation time limit to ctx
+func (g *BuiltinGit) remoteContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if g.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, g.timeout)
+}
+
+// timedOut returns a TimeoutError if ctx ran out of time
+func (g *BuiltinGit) timedOut(ctx context.Context, op string) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return &TimeoutError{Op: op, Timeout: g.timeout}
 	}
+	return nil
+}
+
+// SetClock replaces the function used for commit timestamps
+func (g *BuiltinGit) SetClock(now func() time.Time) {
+	g.now = now
 }
 
-func (g *BuiltinGit) Clone(url string) error {
+func (g *BuiltinGit) Clone(ctx context.Context, url string) error {
+	ctx, cancel := g.remoteContext(ctx)
+	defer cancel()
+
+	if err := g.downloadURL(ctx, url); err != nil {
+		return err
+	}
+
+	if g.gitDir != "" {
+		return g.cloneSeparate(ctx, url)
+	}
+
 	parentDir := filepath.Dir(g.path)
 	if err := os.MkdirAll(parentDir, 0755); err != nil {
 		return fmt.Errorf("failed to create parent directory: %w", err)
 	}
 
-	if err := runGitCommand(parentDir, "clone", "--depth", "1", url, g.path); err != nil {
+	if stderr, err := runGitCommandStderrContext(ctx, parentDir, "clone", "--depth", "1", "--recurse-submodules", "--shallow-submodules", url, g.path); err != nil {
+		g.removeInterruptedClone(ctx)
+		if err := g.timedOut(ctx, "clone"); err != nil {
+			return err
+		}
+		if err := remoteError(url, stderr, err); err != nil {
+			return err
+		}
 		return fmt.Errorf("failed to clone repository: %w", err)
 	}
 
@@ -47,19 +369,301 @@ func (g *BuiltinGit) Clone(url string) error {
 		return fmt.Errorf("failed to open cloned repository: %w", err)
 	}
 
+	return g.setRepo(repo)
+}
+
+// setRepo makes repo the one g works on. The subdir set with SetSubdir
+// is created if the repository has none yet.
+func (g *BuiltinGit) setRepo(repo *git.Repository) error {
 	g.repo = repo
+	if g.subdir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Join(g.path, filepath.FromSlash(g.subdir)), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", g.subdir, err)
+	}
+	return nil
+}
+
+// removeInterruptedClone deletes a partial clone left by a cancelled
+// clone so it can simply be retried
+func (g *BuiltinGit) removeInterruptedClone(ctx context.Context) {
+	if ctx.Err() != nil {
+		_ = os.RemoveAll(g.path)
+	}
+}
+
+// cloneSeparate clones url into the git dir set by SetGitDir without
+// checking anything out, so files already in the working tree are left
+// alone. The index matches HEAD, so they show up as local changes until
+// the caller resets them.
+func (g *BuiltinGit) cloneSeparate(ctx context.Context, url string) error {
+	if err := os.MkdirAll(g.path, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", g.path, err)
+	}
+	if _, err := os.Stat(g.gitDir); err == nil {
+		return fmt.Errorf("failed to clone repository: %s already exists", g.gitDir)
+	}
+
+	// git clone needs an empty directory for the .git file, which is
+	// then moved into the working tree
+	tmpDir, err := os.MkdirTemp(filepath.Dir(g.gitDir), "clone-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if stderr, err := runGitCommandStderrContext(ctx, tmpDir, "clone", "--depth", "1", "--no-checkout", "--separate-git-dir", g.gitDir, url, "worktree"); err != nil {
+		_ = os.RemoveAll(g.gitDir)
+		if err := g.timedOut(ctx, "clone"); err != nil {
+			return err
+		}
+		if err := remoteError(url, stderr, err); err != nil {
+			return err
+		}
+		return fmt.Errorf("failed to clone repository: %w", err)
+	}
+
+	if err := os.Rename(filepath.Join(tmpDir, "worktree", ".git"), filepath.Join(g.path, ".git")); err != nil {
+		_ = os.RemoveAll(g.gitDir)
+		return fmt.Errorf("failed to link working tree: %w", err)
+	}
+
+	// Fill the index from HEAD without touching the working tree
+	if err := runGitCommand(g.path, "reset", "--quiet"); err != nil {
+		return fmt.Errorf("failed to read HEAD into the index: %w", err)
+	}
+
+	return g.Open()
+}
+
+// CloneSparse clones url with only the given top-level directories
+// checked out. Blobs outside them are not downloaded.
+func (g *BuiltinGit) CloneSparse(ctx context.Context, url string, dirs []string) error {
+	ctx, cancel := g.remoteContext(ctx)
+	defer cancel()
+
+	if err := g.downloadURL(ctx, url); err != nil {
+		return err
+	}
+
+	parentDir := filepath.Dir(g.path)
+	if err := os.MkdirAll(parentDir, 0755); err != nil {
+		return fmt.Errorf("failed to create parent directory: %w", err)
+	}
+
+	if stderr, err := runGitCommandStderrContext(ctx, parentDir, "clone", "--depth", "1", "--filter=blob:none", "--sparse", "--recurse-submodules", "--shallow-submodules", url, g.path); err != nil {
+		g.removeInterruptedClone(ctx)
+		if err := g.timedOut(ctx, "clone"); err != nil {
+			return err
+		}
+		if err := remoteError(url, stderr, err); err != nil {
+			return err
+		}
+		return fmt.Errorf("failed to clone repository: %w", err)
+	}
+
+	repo, err := git.PlainOpen(g.path)
+	if err != nil {
+		return fmt.Errorf("failed to open cloned repository: %w", err)
+	}
+	if err := g.setRepo(repo); err != nil {
+		return err
+	}
+
+	return g.SetSparseDirs(dirs)
+}
+
+// SetSparseDirs restricts the working tree to dirs plus top-level files.
+// An empty list restores a full checkout.
+func (g *BuiltinGit) SetSparseDirs(dirs []string) error {
+	if g.repo == nil {
+		return fmt.Errorf("repository not initialized")
+	}
+
+	if g.subdir != "" {
+		// Files outside the subdir are never needed
+		scoped := []string{g.subdir}
+		if len(dirs) > 0 {
+			scoped = nil
+			for _, dir := range dirs {
+				scoped = append(scoped, g.repoPath(dir))
+			}
+		}
+		dirs = scoped
+	}
+
+	if err := g.useWorktreeConfig(); err != nil {
+		return err
+	}
+
+	if len(dirs) == 0 {
+		if !g.isSparse() {
+			return nil
+		}
+		return g.disableSparse()
+	}
+
+	if g.sparseConfigured() {
+		current, _ := runGitOutput(g.path, "sparse-checkout", "list")
+		if strings.Join(strings.Fields(current), " ") == strings.Join(dirs, " ") {
+			return nil
+		}
+	}
+
+	args := append([]string{"sparse-checkout", "set", "--cone", "--"}, dirs...)
+	if err := runGitCommand(g.path, args...); err != nil {
+		return fmt.Errorf("failed to set sparse checkout: %w", err)
+	}
+
+	return nil
+}
+
+// SetSparsePatterns restricts the working tree to paths matching the
+// given gitignore-style patterns
+func (g *BuiltinGit) SetSparsePatterns(patterns []string) error {
+	if g.repo == nil {
+		return fmt.Errorf("repository not initialized")
+	}
+
+	if g.subdir != "" {
+		scoped := make([]string, len(patterns))
+		for i, pattern := range patterns {
+			negate := strings.HasPrefix(pattern, "!")
+			scoped[i] = "/" + g.repoPath(strings.TrimPrefix(strings.TrimPrefix(pattern, "!"), "/"))
+			if negate {
+				scoped[i] = "!" + scoped[i]
+			}
+		}
+		patterns = scoped
+	}
+
+	if err := g.useWorktreeConfig(); err != nil {
+		return err
+	}
+
+	current, _ := os.ReadFile(filepath.Join(g.GitDir(), "info", "sparse-checkout"))
+	if g.sparseConfigured() && strings.Join(strings.Fields(string(current)), " ") == strings.Join(patterns, " ") {
+		return nil
+	}
+
+	args := append([]string{"sparse-checkout", "set", "--no-cone", "--"}, patterns...)
+	if err := runGitCommand(g.path, args...); err != nil {
+		return fmt.Errorf("failed to set sparse checkout: %w", err)
+	}
+
+	return nil
+}
+
+// isSparse reports whether files are left out of the working tree, by
+// the configuration or by skip-worktree bits in the index that a sparse
+// checkout git no longer sees as enabled left behind
+func (g *BuiltinGit) isSparse() bool {
+	if g.sparseConfigured() {
+		return true
+	}
+	skipped, err := g.skipWorktree()
+	return err == nil && len(skipped) > 0
+}
+
+// sparseConfigured reports whether git sees sparse checkout as enabled
+func (g *BuiltinGit) sparseConfigured() bool {
+	out, err := runGitOutput(g.path, "config", "--bool", "core.sparseCheckout")
+	return err == nil && strings.TrimSpace(out) == "true"
+}
+
+// useWorktreeConfig makes git read the per-worktree config that
+// 'git sparse-checkout' keeps core.sparseCheckout in. Repositories go-git
+// created have no core.repositoryformatversion, and git ignores
+// extensions.worktreeConfig without version 1, so sparse checkout set in
+// them would look disabled while its skip-worktree bits hide edits.
+func (g *BuiltinGit) useWorktreeConfig() error {
+	out, _ := runGitOutput(g.path, "config", "core.repositoryformatversion")
+	if version := strings.TrimSpace(out); version != "" && version != "0" {
+		return nil
+	}
+	if err := runGitCommand(g.path, "config", "core.repositoryformatversion", "1"); err != nil {
+		return fmt.Errorf("failed to set repository format version: %w", err)
+	}
+	return nil
+}
+
+// disableSparse restores a full checkout. Files left out of it are
+// checked out again, except those the working tree has already, which
+// keep their content so edits to them show up as changes.
+func (g *BuiltinGit) disableSparse() error {
+	skipped, err := g.skipWorktree()
+	if err != nil {
+		return err
+	}
+	var present []string
+	for _, file := range skipped {
+		if _, err := os.Lstat(filepath.Join(g.path, filepath.FromSlash(file))); err == nil {
+			present = append(present, file)
+		}
+	}
+	if len(present) > 0 {
+		args := append([]string{"update-index", "--no-skip-worktree", "--"}, present...)
+		if err := runGitCommand(g.path, args...); err != nil {
+			return fmt.Errorf("failed to clear skip-worktree bits: %w", err)
+		}
+	}
+
+	if err := runGitCommand(g.path, "sparse-checkout", "disable"); err != nil {
+		return fmt.Errorf("failed to disable sparse checkout: %w", err)
+	}
+
+	// Bits the disable left, when git did not see sparse checkout as
+	// enabled
+	left, err := g.skipWorktree()
+	if err != nil || len(left) == 0 {
+		return err
+	}
+	args := append([]string{"update-index", "--no-skip-worktree", "--"}, left...)
+	if err := runGitCommand(g.path, args...); err != nil {
+		return fmt.Errorf("failed to clear skip-worktree bits: %w", err)
+	}
+	args = append([]string{"checkout", "--"}, left...)
+	if err := runGitCommand(g.path, args...); err != nil {
+		return fmt.Errorf("failed to check out files left out by sparse checkout: %w", err)
+	}
 	return nil
 }
 
+// skipWorktree returns the paths the index marks skip-worktree, which a
+// sparse checkout leaves out of the working tree
+func (g *BuiltinGit) skipWorktree() ([]string, error) {
+	out, err := runGitOutput(g.path, "ls-files", "-v", "-z")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list index: %w", err)
+	}
+	var skipped []string
+	for _, entry := range strings.Split(out, "\x00") {
+		// "S" marks skip-worktree, "s" skip-worktree and assume-unchanged
+		if len(entry) > 2 && (entry[0] == 'S' || entry[0] == 's') {
+			skipped = append(skipped, entry[2:])
+		}
+	}
+	return skipped, nil
+}
+
 // Init initializes a new repository
 func (g *BuiltinGit) Init() error {
+	if g.gitDir != "" {
+		storage := filesystem.NewStorage(osfs.New(g.gitDir), cache.NewObjectLRUDefault())
+		repo, err := git.Init(storage, osfs.New(g.path))
+		if err != nil {
+			return fmt.Errorf("failed to initialize repository: %w", err)
+		}
+		return g.setRepo(repo)
+	}
+
 	repo, err := git.PlainInit(g.path, false)
 	if err != nil {
 		return fmt.Errorf("failed to initialize repository: %w", err)
 	}
 
-	g.repo = repo
-	return nil
+	return g.setRepo(repo)
 }
 
 // Open opens an existing repository
@@ -69,8 +673,7 @@ func (g *BuiltinGit) Open() error {
 		return fmt.Errorf("failed to open repository: %w", err)
 	}
 
-	g.repo = repo
-	return nil
+	return g.setRepo(repo)
 }
 
 // AddRemote adds a remote
@@ -96,7 +699,7 @@ func (g *BuiltinGit) Status() (*Status, error) {
 		return nil, fmt.Errorf("repository not initialized")
 	}
 
-	w, err := g.repo.Worktree()
+	w, err := g.worktree()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get worktree: %w", err)
 	}
@@ -116,13 +719,19 @@ func (g *BuiltinGit) Status() (*Status, error) {
 	// Parse status
 	result := &Status{
 		Branch:         branch,
-		IsClean:        status.IsClean(),
+		IsClean:        true,
 		UntrackedFiles: []string{},
 		ModifiedFiles:  []string{},
 		StagedFiles:    []string{},
 	}
 
 	for path, fileStatus := range status {
+		path, ok := g.subdirPath(path)
+		if !ok {
+			continue
+		}
+		result.IsClean = false
+
 		switch {
 		case fileStatus.Worktree == git.Untracked:
 			result.HasUntracked = true
@@ -145,13 +754,13 @@ func (g *BuiltinGit) Add(paths []string) error {
 		return fmt.Errorf("repository not initialized")
 	}
 
-	w, err := g.repo.Worktree()
+	w, err := g.worktree()
 	if err != nil {
 		return fmt.Errorf("failed to get worktree: %w", err)
 	}
 
 	for _, path := range paths {
-		_, err := w.Add(path)
+		_, err := w.Add(g.repoPath(path))
 		if err != nil {
 			return fmt.Errorf("failed to add %s: %w", path, err)
 		}
@@ -166,12 +775,16 @@ func (g *BuiltinGit) AddAll() error {
 		return fmt.Errorf("repository not initialized")
 	}
 
-	w, err := g.repo.Worktree()
+	w, err := g.worktree()
 	if err != nil {
 		return fmt.Errorf("failed to get worktree: %w", err)
 	}
 
-	_, err = w.Add(".")
+	all := "."
+	if g.subdir != "" {
+		all = g.subdir
+	}
+	_, err = w.Add(all)
 	if err != nil {
 		return fmt.Errorf("failed to add all: %w", err)
 	}
@@ -185,32 +798,13 @@ func (g *BuiltinGit) Commit(message string) error {
 		return fmt.Errorf("repository not initialized")
 	}
 
-	w, err := g.repo.Worktree()
+	w, err := g.worktree()
 	if err != nil {
 		return fmt.Errorf("failed to get worktree: %w", err)
 	}
 
-	// Get git config for author info
-	cfg, err := g.repo.ConfigScoped(config.GlobalScope)
-	if err != nil {
-		cfg, _ = g.repo.Config()
-	}
-
-	author := &object.Signature{
-		Name:  cfg.User.Name,
-		Email: cfg.User.Email,
-		When:  time.Now(),
-	}
-
-	if author.Name == "" {
-		author.Name = "opencode-sync"
-	}
-	if author.Email == "" {
-		author.Email = "opencode-sync@local"
-	}
-
 	_, err = w.Commit(message, &git.CommitOptions{
-		Author: author,
+		Author: g.signature(),
 	})
 	if err != nil {
 		return fmt.Errorf("failed to commit: %w", err)
@@ -219,125 +813,889 @@ func (g *BuiltinGit) Commit(message string) error {
 	return nil
 }
 
-func (g *BuiltinGit) Push() error {
+// Amend replaces HEAD with a commit of the staged changes on top of
+// HEAD's parents
+func (g *BuiltinGit) Amend(message string) error {
 	if g.repo == nil {
 		return fmt.Errorf("repository not initialized")
 	}
 
-	if err := runGitCommand(g.path, "push", "origin", "HEAD"); err != nil {
-		return &AuthError{Remote: "origin", Err: err}
+	w, err := g.worktree()
+	if err != nil {
+		return fmt.Errorf("failed to get worktree: %w", err)
 	}
 
-	return nil
-}
+	_, err = w.Commit(message, &git.CommitOptions{
+		Author: g.signature(),
+		Amend:  true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to amend commit: %w", err)
+	}
+
+	return nil
+}
+
+// signature returns the author identity from AuthorName and AuthorEmail,
+// then git's configuration, falling back to a generic opencode-sync
+// identity when none is set
+func (g *BuiltinGit) signature() *object.Signature {
+	cfg, err := g.repo.ConfigScoped(config.GlobalScope)
+	if err != nil {
+		cfg, _ = g.repo.Config()
+	}
+
+	author := &object.Signature{
+		Name:  cfg.User.Name,
+		Email: cfg.User.Email,
+		When:  g.now(),
+	}
+
+	if AuthorName != "" {
+		author.Name = AuthorName
+	}
+	if AuthorEmail != "" {
+		author.Email = AuthorEmail
+	}
+
+	if author.Name == "" {
+		author.Name = "opencode-sync"
+	}
+	if author.Email == "" {
+		author.Email = "opencode-sync@local"
+	}
+
+	return author
+}
+
+func (g *BuiltinGit) Push(ctx context.Context) error {
+	if g.repo == nil {
+		return fmt.Errorf("repository not initialized")
+	}
+
+	ctx, cancel := g.remoteContext(ctx)
+	defer cancel()
+
+	if err := g.download(ctx, "origin"); err != nil {
+		return err
+	}
+
+	// Refuse to publish submodule commits that only exist locally
+	stderr, err := runGitCommandStderrContext(ctx, g.path, "push", "--recurse-submodules=check", "origin", "HEAD")
+	if err != nil {
+		if err := g.timedOut(ctx, "push"); err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("push interrupted: %w", err)
+		}
+		if isPushRejection(stderr) {
+			return &RejectedError{Remote: "origin", Err: err}
+		}
+		if err := remoteError("origin", stderr, err); err != nil {
+			return err
+		}
+		return &AuthError{Remote: "origin", Err: err}
+	}
+
+	return g.upload(ctx, "origin")
+}
+
+// isPushRejection reports whether git push failed because the remote
+// has commits that are not in the local branch
+func isPushRejection(stderr string) bool {
+	return strings.Contains(stderr, "non-fast-forward") ||
+		strings.Contains(stderr, "fetch first") ||
+		strings.Contains(stderr, "[rejected]")
+}
+
+// networkFailures and authFailures are git and ssh messages that identify
+// why talking to a remote failed
+var (
+	networkFailures = []string{
+		"Could not resolve host",
+		"Could not resolve hostname",
+		"Connection refused",
+		"Connection timed out",
+		"Network is unreachable",
+		"No route to host",
+		"Connection reset",
+		"Operation timed out",
+	}
+	authFailures = []string{
+		"Permission denied",
+		"Authentication failed",
+		"could not read Username",
+		"could not read Password",
+		"Host key verification failed",
+		"The requested URL returned error: 401",
+		"The requested URL returned error: 403",
+	}
+)
+
+// remoteError classifies a failed remote operation from git's stderr. It
+// returns nil if the cause is not recognized.
+func remoteError(remote, stderr string, err error) error {
+	for _, msg := range networkFailures {
+		if strings.Contains(stderr, msg) {
+			return &NetworkError{Remote: remote, Err: err}
+		}
+	}
+	for _, msg := range authFailures {
+		if strings.Contains(stderr, msg) {
+			return &AuthError{Remote: remote, Err: err}
+		}
+	}
+	return nil
+}
+
+// ClassifyRemoteError classifies a failed command that talked to remote
+// over ssh, like remoteError does for git
+func ClassifyRemoteError(remote, stderr string, err error) error {
+	return remoteError(remote, stderr, err)
+}
+
+// ForcePush overwrites the remote branch with HEAD. The push is rejected
+// with a RejectedError if the remote branch moved since it was last
+// fetched, so commits another machine pushed meanwhile are never lost.
+func (g *BuiltinGit) ForcePush(ctx context.Context) error {
+	if g.repo == nil {
+		return fmt.Errorf("repository not initialized")
+	}
+
+	branch, err := g.GetBranch()
+	if err != nil {
+		return err
+	}
+
+	return g.ForcePushWithLease(ctx, g.trackingHead(branch))
+}
+
+// trackingHead returns the commit origin's branch pointed at when it was
+// last fetched or pushed, or "" if it was never seen
+func (g *BuiltinGit) trackingHead(branch string) string {
+	ref, err := g.repo.Reference(plumbing.NewRemoteReferenceName("origin", branch), true)
+	if err != nil {
+		return ""
+	}
+	return ref.Hash().String()
+}
+
+// ForcePushWithLease overwrites the remote branch with HEAD, but only if
+// the remote still points at expect; an empty expect requires the branch
+// not to exist. Otherwise a RejectedError is returned.
+func (g *BuiltinGit) ForcePushWithLease(ctx context.Context, expect string) error {
+	if g.repo == nil {
+		return fmt.Errorf("repository not initialized")
+	}
+
+	branch, err := g.GetBranch()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := g.remoteContext(ctx)
+	defer cancel()
+
+	if err := g.download(ctx, "origin"); err != nil {
+		return err
+	}
+
+	lease := fmt.Sprintf("--force-with-lease=refs/heads/%s:%s", branch, expect)
+	stderr, err := runGitCommandStderrContext(ctx, g.path, "push", "--recurse-submodules=check", lease, "origin", "HEAD")
+	if err != nil {
+		if err := g.timedOut(ctx, "push"); err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("push interrupted: %w", err)
+		}
+		if isPushRejection(stderr) {
+			return &RejectedError{Remote: "origin", Err: err}
+		}
+		if err := remoteError("origin", stderr, err); err != nil {
+			return err
+		}
+		return &AuthError{Remote: "origin", Err: err}
+	}
+
+	return g.upload(ctx, "origin")
+}
+
+func (g *BuiltinGit) Pull(ctx context.Context) error {
+	if g.repo == nil {
+		return fmt.Errorf("repository not initialized")
+	}
+
+	// Name the branch so repos created by init, which have no upstream
+	// tracking configured, can pull too
+	branch, err := g.GetBranch()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := g.remoteContext(ctx)
+	defer cancel()
+
+	if err := g.download(ctx, "origin"); err != nil {
+		return err
+	}
+
+	if stderr, err := runGitCommandStderrContext(ctx, g.path, "pull", "origin", branch); err != nil {
+		// Don't leave a half-finished merge behind
+		if ctx.Err() != nil && g.MergeInProgress() {
+			_ = g.MergeAbort()
+		}
+		if err := g.timedOut(ctx, "pull"); err != nil {
+			return err
+		}
+		if err := remoteError("origin", stderr, err); err != nil {
+			return err
+		}
+		if files := g.UnmergedFiles(); len(files) > 0 {
+			return &ConflictError{Files: files}
+		}
+		return fmt.Errorf("failed to pull: %w", err)
+	}
+
+	return g.updateSubmodules(ctx)
+}
+
+// updateSubmodules checks out the submodule commits recorded in HEAD,
+// cloning submodules that are new
+func (g *BuiltinGit) updateSubmodules(ctx context.Context) error {
+	if _, err := os.Stat(filepath.Join(g.path, ".gitmodules")); os.IsNotExist(err) {
+		return nil
+	}
+
+	if err := runGitCommandContext(ctx, g.path, "submodule", "sync", "--recursive"); err != nil {
+		return fmt.Errorf("failed to sync submodule URLs: %w", err)
+	}
+
+	if stderr, err := runGitCommandStderrContext(ctx, g.path, "submodule", "update", "--init", "--recursive"); err != nil {
+		if err := g.timedOut(ctx, "pull"); err != nil {
+			return err
+		}
+		if err := remoteError("submodule", stderr, err); err != nil {
+			return err
+		}
+		return fmt.Errorf("failed to update submodules: %w", err)
+	}
+
+	return nil
+}
+
+// Submodules returns the paths of the repository's submodules, relative
+// to the repo root or the subdir set with SetSubdir
+func (g *BuiltinGit) Submodules() ([]string, error) {
+	if g.repo == nil {
+		return nil, fmt.Errorf("repository not initialized")
+	}
+
+	w, err := g.worktree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get worktree: %w", err)
+	}
+
+	submodules, err := w.Submodules()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read submodules: %w", err)
+	}
+
+	paths := make([]string, 0, len(submodules))
+	for _, sub := range submodules {
+		if path, ok := g.subdirPath(sub.Config().Path); ok {
+			paths = append(paths, filepath.FromSlash(path))
+		}
+	}
+
+	return paths, nil
+}
+
+// Diff returns the diff
+func (g *BuiltinGit) Diff() (string, error) {
+	changes, err := g.WorktreeChanges()
+	if err != nil {
+		return "", err
+	}
+
+	var diff string
+	for _, change := range changes {
+		if change.OldPath != "" {
+			diff += fmt.Sprintf("%s → %s: %s\n", change.OldPath, change.Path, change.Status)
+		} else {
+			diff += fmt.Sprintf("%s: %s\n", change.Path, change.Status)
+		}
+	}
+
+	return diff, nil
+}
+
+// ShowCommit returns the stat summary and patch of the given revision.
+// Binary files such as encrypted *.age blobs are listed but not rendered.
+func (g *BuiltinGit) ShowCommit(rev string) (string, error) {
+	if g.repo == nil {
+		return "", fmt.Errorf("repository not initialized")
+	}
+
+	args := append([]string{"show", "--stat", "--patch", "--format=medium", "--no-color"}, g.relativeArgs()...)
+	out, err := runGitOutput(g.path, append(args, rev)...)
+	if err != nil {
+		return "", fmt.Errorf("failed to show commit %s: %w", rev, err)
+	}
+
+	return out, nil
+}
+
+// ResetSoft moves HEAD to rev, keeping the changes staged
+func (g *BuiltinGit) ResetSoft(rev string) error {
+	if g.repo == nil {
+		return fmt.Errorf("repository not initialized")
+	}
+
+	if err := runGitCommand(g.path, "reset", "--soft", rev); err != nil {
+		return fmt.Errorf("failed to reset to %s: %w", rev, err)
+	}
+
+	return nil
+}
+
+// ResetHard moves HEAD to rev, discarding all changes
+func (g *BuiltinGit) ResetHard(rev string) error {
+	if g.repo == nil {
+		return fmt.Errorf("repository not initialized")
+	}
+
+	if err := runGitCommand(g.path, "reset", "--hard", "--quiet", rev); err != nil {
+		return fmt.Errorf("failed to reset to %s: %w", rev, err)
+	}
+
+	return nil
+}
+
+// RestoreTree makes the working tree and index match rev and commits
+// the result, undoing everything since rev without rewriting history
+func (g *BuiltinGit) RestoreTree(rev, message string) error {
+	if g.repo == nil {
+		return fmt.Errorf("repository not initialized")
+	}
+
+	args := []string{"read-tree", "-u", "--reset", rev}
+	if g.subdir != "" {
+		// Only the subdir goes back; the rest of the tree is not ours
+		args = append([]string{"restore", "--source=" + rev, "--staged", "--worktree"}, g.pathspec()...)
+	}
+	if err := runGitCommand(g.path, args...); err != nil {
+		return fmt.Errorf("failed to restore %s: %w", rev, err)
+	}
+
+	return g.Commit(message)
+}
+
+// Revert creates a new commit undoing rev
+func (g *BuiltinGit) Revert(rev string) error {
+	if g.repo == nil {
+		return fmt.Errorf("repository not initialized")
+	}
+
+	hash, err := g.repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", rev, err)
+	}
+
+	commit, err := g.repo.CommitObject(*hash)
+	if err != nil {
+		return fmt.Errorf("failed to get commit: %w", err)
+	}
+
+	// Apply the inverse change and commit it ourselves so the
+	// fallback author identity from Commit is used
+	if err := runGitCommand(g.path, "revert", "--no-commit", rev); err != nil {
+		return fmt.Errorf("failed to revert %s: %w", rev, err)
+	}
+
+	subject := strings.SplitN(commit.Message, "\n", 2)[0]
+	return g.Commit(fmt.Sprintf("Revert \"%s\"\n\nThis reverts commit %s.", subject, hash.String()))
+}
+
+// SetMergeDriver registers a custom merge driver in the repository config.
+// command is run by git with %O, %A, %B and %P substituted.
+func (g *BuiltinGit) SetMergeDriver(name, description, command string) error {
+	if g.repo == nil {
+		return fmt.Errorf("repository not initialized")
+	}
+
+	cfg, err := g.repo.Config()
+	if err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+
+	section := cfg.Raw.Section("merge").Subsection(name)
+	if section.Option("name") == description && section.Option("driver") == command {
+		return nil
+	}
+
+	section.SetOption("name", description)
+	section.SetOption("driver", command)
+
+	if err := g.repo.SetConfig(cfg); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+
+	return nil
+}
+
+// CloneBare creates a bare clone of src at dst
+func CloneBare(src, dst string) error {
+	if out, err := exec.Command("git", "clone", "--quiet", "--bare", src, dst).CombinedOutput(); err != nil {
+		return fmt.Errorf("git clone --bare failed: %s", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// MergeFile runs a line-based three-way merge of base and other into
+// current, leaving conflict markers in current on conflict
+func MergeFile(current, base, other string) error {
+	if err := exec.Command("git", "merge-file", current, base, other).Run(); err != nil {
+		return fmt.Errorf("failed to merge %s: %w", current, err)
+	}
+
+	return nil
+}
+
+// MergeFileDiff3 runs the same merge as MergeFile but returns the
+// result instead of writing it. Each conflict also holds the common
+// ancestor's lines (diff3 style), and its markers are markerSize
+// characters long and labeled ours, base and theirs. conflicts reports
+// whether any are left.
+func MergeFileDiff3(current, base, other string, markerSize int) (merged []byte, conflicts bool, err error) {
+	out, err := exec.Command("git", "merge-file", "-p", "--diff3", fmt.Sprintf("--marker-size=%d", markerSize),
+		"-L", "ours", "-L", "base", "-L", "theirs", current, base, other).Output()
+
+	// The exit code is the number of conflicts, negative on failure
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 && exitErr.ExitCode() < 128 {
+		return out, true, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to merge %s: %w", current, err)
+	}
+	return out, false, nil
+}
+
+// MergeFileUnion runs the same merge as MergeFile but returns the
+// result instead of writing it, settling each conflict by keeping the
+// lines of current followed by those of other
+func MergeFileUnion(current, base, other string) ([]byte, error) {
+	out, err := exec.Command("git", "merge-file", "-p", "--union", current, base, other).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge %s: %w", current, err)
+	}
+	return out, nil
+}
+
+// Rebase replays local commits on top of upstream. On conflict the
+// rebase is aborted and a ConflictError is returned.
+func (g *BuiltinGit) Rebase(upstream string) error {
+	if g.repo == nil {
+		return fmt.Errorf("repository not initialized")
+	}
+
+	// Rebasing rewrites commits, so git needs a committer identity
+	author := g.signature()
+	args := []string{
+		"-c", "user.name=" + author.Name,
+		"-c", "user.email=" + author.Email,
+		"rebase", upstream,
+	}
+
+	if err := runGitCommand(g.path, args...); err != nil {
+		files := g.UnmergedFiles()
+		_ = runGitCommand(g.path, "rebase", "--abort")
+
+		if len(files) > 0 {
+			return &ConflictError{Files: files}
+		}
+		return fmt.Errorf("failed to rebase onto %s: %w", upstream, err)
+	}
+
+	return nil
+}
+
+// GetHead returns the full hash of HEAD
+func (g *BuiltinGit) GetHead() (string, error) {
+	if g.repo == nil {
+		return "", fmt.Errorf("repository not initialized")
+	}
+
+	head, err := g.repo.Head()
+	if err != nil {
+		return "", fmt.Errorf("failed to get HEAD: %w", err)
+	}
+
+	return head.Hash().String(), nil
+}
+
+// RevParse returns the full hash a revision resolves to
+func (g *BuiltinGit) RevParse(rev string) (string, error) {
+	if g.repo == nil {
+		return "", fmt.Errorf("repository not initialized")
+	}
+
+	hash, err := g.repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", rev, err)
+	}
+
+	return hash.String(), nil
+}
+
+// IsAncestor reports whether ancestor is rev or one of its ancestors
+func (g *BuiltinGit) IsAncestor(ancestor, rev string) (bool, error) {
+	if g.repo == nil {
+		return false, fmt.Errorf("repository not initialized")
+	}
+
+	commits := make([]*object.Commit, 2)
+	for i, r := range []string{ancestor, rev} {
+		hash, err := g.repo.ResolveRevision(plumbing.Revision(r))
+		if err != nil {
+			return false, fmt.Errorf("failed to resolve %s: %w", r, err)
+		}
+		if commits[i], err = g.repo.CommitObject(*hash); err != nil {
+			return false, fmt.Errorf("failed to get commit %s: %w", r, err)
+		}
+	}
+
+	if commits[0].Hash == commits[1].Hash {
+		return true, nil
+	}
+	return commits[0].IsAncestor(commits[1])
+}
+
+// ReadFileAt returns the content of path (slash-separated, relative to
+// the repo root or the subdir set with SetSubdir) at rev. It returns os.ErrNotExist if the file is absent
+// and a *FileTooLargeError if it is larger than MaxReadSize.
+func (g *BuiltinGit) ReadFileAt(rev, path string) ([]byte, error) {
+	file, err := g.fileAt(rev, path)
+	if err != nil {
+		return nil, err
+	}
+	if MaxReadSize > 0 && file.Size > MaxReadSize {
+		return nil, &FileTooLargeError{Path: path, Size: file.Size, Limit: MaxReadSize}
+	}
+
+	// Read straight into one buffer; Contents would copy it into a string
+	r, err := file.Reader()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s at %s: %w", path, rev, err)
+	}
+	defer r.Close()
+
+	contents := make([]byte, file.Size)
+	if _, err := io.ReadFull(r, contents); err != nil {
+		return nil, fmt.Errorf("failed to read %s at %s: %w", path, rev, err)
+	}
+
+	return contents, nil
+}
+
+// OpenFileAt is ReadFileAt for files of any size, streaming the content
+func (g *BuiltinGit) OpenFileAt(rev, path string) (io.ReadCloser, error) {
+	file, err := g.fileAt(rev, path)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := file.Reader()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s at %s: %w", path, rev, err)
+	}
+	return r, nil
+}
+
+// fileAt looks up path at rev
+func (g *BuiltinGit) fileAt(rev, path string) (*object.File, error) {
+	if g.repo == nil {
+		return nil, fmt.Errorf("repository not initialized")
+	}
+
+	hash, err := g.repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", rev, err)
+	}
+
+	commit, err := g.repo.CommitObject(*hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get commit: %w", err)
+	}
+
+	file, err := commit.File(g.repoPath(filepath.ToSlash(path)))
+	if err == object.ErrFileNotFound {
+		return nil, os.ErrNotExist
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s at %s: %w", path, rev, err)
+	}
+	return file, nil
+}
+
+// GetRemoteURL returns the remote URL
+func (g *BuiltinGit) GetRemoteURL(name string) (string, error) {
+	if g.repo == nil {
+		return "", fmt.Errorf("repository not initialized")
+	}
+
+	remote, err := g.repo.Remote(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to get remote: %w", err)
+	}
+
+	cfg := remote.Config()
+	if len(cfg.URLs) == 0 {
+		return "", fmt.Errorf("no URLs configured for remote %s", name)
+	}
+
+	return cfg.URLs[0], nil
+}
+
+// SetRemote creates the named remote or updates its URL
+func (g *BuiltinGit) SetRemote(name, url string) error {
+	if g.repo == nil {
+		return fmt.Errorf("repository not initialized")
+	}
+
+	if _, err := g.repo.Remote(name); err == nil {
+		if err := g.repo.DeleteRemote(name); err != nil {
+			return fmt.Errorf("failed to update remote: %w", err)
+		}
+	}
+
+	return g.AddRemote(name, url)
+}
+
+// FetchRemote fetches a remote and records its default branch as
+// <name>/HEAD
+func (g *BuiltinGit) FetchRemote(ctx context.Context, name string) error {
+	if g.repo == nil {
+		return fmt.Errorf("repository not initialized")
+	}
+
+	ctx, cancel := g.remoteContext(ctx)
+	defer cancel()
+
+	if err := g.download(ctx, name); err != nil {
+		return err
+	}
+
+	if stderr, err := runGitCommandStderrContext(ctx, g.path, "fetch", name); err != nil {
+		if err := g.timedOut(ctx, "fetch"); err != nil {
+			return err
+		}
+		if err := remoteError(name, stderr, err); err != nil {
+			return err
+		}
+		return fmt.Errorf("failed to fetch %s: %w", name, err)
+	}
+
+	if err := runGitCommand(g.path, "remote", "set-head", name, "--auto"); err != nil {
+		return fmt.Errorf("failed to determine default branch of %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// MergeNoCommit merges rev into the working tree and index without
+// committing, allowing unrelated histories. It returns conflicted files.
+func (g *BuiltinGit) MergeNoCommit(rev string) ([]string, error) {
+	if g.repo == nil {
+		return nil, fmt.Errorf("repository not initialized")
+	}
+
+	author := g.signature()
+	mergeErr := runGitCommand(g.path,
+		"-c", "user.name="+author.Name,
+		"-c", "user.email="+author.Email,
+		"merge", "--no-commit", "--no-ff", "--allow-unrelated-histories", rev)
+
+	conflicts := g.UnmergedFiles()
+	if mergeErr != nil && len(conflicts) == 0 {
+		return nil, fmt.Errorf("failed to merge %s: %w", rev, mergeErr)
+	}
+
+	return conflicts, nil
+}
+
+// UnmergedFiles returns the files with unresolved conflicts
+func (g *BuiltinGit) UnmergedFiles() []string {
+	args := append([]string{"diff", "--name-only", "--diff-filter=U"}, g.relativeArgs()...)
+	out, _ := runGitOutput(g.path, args...)
+	return strings.Fields(out)
+}
+
+// MergeInProgress reports whether a merge is waiting to be committed
+func (g *BuiltinGit) MergeInProgress() bool {
+	_, err := os.Stat(filepath.Join(g.GitDir(), "MERGE_HEAD"))
+	return err == nil
+}
 
-func (g *BuiltinGit) ForcePush() error {
+// CommitMerge concludes an in-progress merge with a merge commit
+func (g *BuiltinGit) CommitMerge(message string) error {
 	if g.repo == nil {
 		return fmt.Errorf("repository not initialized")
 	}
 
-	if err := runGitCommand(g.path, "push", "--force", "origin", "HEAD"); err != nil {
-		return &AuthError{Remote: "origin", Err: err}
+	author := g.signature()
+	if err := runGitCommand(g.path,
+		"-c", "user.name="+author.Name,
+		"-c", "user.email="+author.Email,
+		"commit", "--quiet", "--no-verify", "-m", message); err != nil {
+		return fmt.Errorf("failed to commit merge: %w", err)
 	}
 
 	return nil
 }
 
-func (g *BuiltinGit) Pull() error {
+// MergeAbort abandons an in-progress merge
+func (g *BuiltinGit) MergeAbort() error {
 	if g.repo == nil {
 		return fmt.Errorf("repository not initialized")
 	}
 
-	if err := runGitCommand(g.path, "pull", "origin"); err != nil {
-		return fmt.Errorf("failed to pull: %w", err)
+	if err := runGitCommand(g.path, "merge", "--abort"); err != nil {
+		return fmt.Errorf("failed to abort merge: %w", err)
 	}
 
 	return nil
 }
 
-// Diff returns the diff
-func (g *BuiltinGit) Diff() (string, error) {
+// StagedChanges returns the paths and stat summary of changes staged
+// relative to HEAD
+func (g *BuiltinGit) StagedChanges() ([]string, string, error) {
 	if g.repo == nil {
-		return "", fmt.Errorf("repository not initialized")
+		return nil, "", fmt.Errorf("repository not initialized")
 	}
 
-	// Get HEAD commit
-	head, err := g.repo.Head()
+	names, err := runGitOutput(g.path, append([]string{"diff", "--cached", "--name-only"}, append(g.relativeArgs(), "HEAD")...)...)
 	if err != nil {
-		return "", fmt.Errorf("failed to get HEAD: %w", err)
+		return nil, "", fmt.Errorf("failed to diff index: %w", err)
 	}
 
-	commit, err := g.repo.CommitObject(head.Hash())
+	stat, err := runGitOutput(g.path, append([]string{"diff", "--cached", "--stat"}, append(g.relativeArgs(), "HEAD")...)...)
 	if err != nil {
-		return "", fmt.Errorf("failed to get commit: %w", err)
+		return nil, "", fmt.Errorf("failed to diff index: %w", err)
 	}
 
-	tree, err := commit.Tree()
-	if err != nil {
-		return "", fmt.Errorf("failed to get tree: %w", err)
-	}
+	return strings.Fields(names), stat, nil
+}
 
-	// Get worktree status
-	w, err := g.repo.Worktree()
-	if err != nil {
-		return "", fmt.Errorf("failed to get worktree: %w", err)
+// ResolvePath resolves a path in the index to the given side of a merge:
+// "ours" (HEAD) or "theirs" (the merged revision). A side on which the
+// file does not exist removes it.
+func (g *BuiltinGit) ResolvePath(path, side string) error {
+	if g.repo == nil {
+		return fmt.Errorf("repository not initialized")
 	}
 
-	status, err := w.Status()
-	if err != nil {
-		return "", fmt.Errorf("failed to get status: %w", err)
+	rev := "HEAD"
+	if side == "theirs" {
+		rev = "MERGE_HEAD"
 	}
 
-	// Build simple diff output
-	var diff string
-	for path, fileStatus := range status {
-		if fileStatus.Worktree != git.Unmodified {
-			diff += fmt.Sprintf("%s: %c\n", path, fileStatus.Worktree)
+	if _, err := g.ReadFileAt(rev, path); errors.Is(err, os.ErrNotExist) {
+		if err := runGitCommand(g.path, "rm", "--quiet", "--force", "--ignore-unmatch", "--", g.repoPath(path)); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", path, err)
 		}
+		return nil
 	}
 
-	_ = tree // TODO: Implement proper diff using tree
+	if err := runGitCommand(g.path, "checkout", rev, "--", g.repoPath(path)); err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
 
-	return diff, nil
+	return nil
 }
 
-// GetRemoteURL returns the remote URL
-func (g *BuiltinGit) GetRemoteURL(name string) (string, error) {
+// HasChanges returns true if there are uncommitted changes
+func (g *BuiltinGit) HasChanges() (bool, error) {
+	clean, err := g.IsClean()
+	return !clean, err
+}
+
+// UnpushedCommits returns how many commits on the current branch are not
+// on origin yet, as far as the last fetch or push knows
+func (g *BuiltinGit) UnpushedCommits() (int, error) {
 	if g.repo == nil {
-		return "", fmt.Errorf("repository not initialized")
+		return 0, fmt.Errorf("repository not initialized")
 	}
 
-	remote, err := g.repo.Remote(name)
+	branch, err := g.GetBranch()
 	if err != nil {
-		return "", fmt.Errorf("failed to get remote: %w", err)
+		return 0, err
 	}
 
-	cfg := remote.Config()
-	if len(cfg.URLs) == 0 {
-		return "", fmt.Errorf("no URLs configured for remote %s", name)
+	// Nothing was ever pushed if the remote branch is unknown
+	rev := "HEAD"
+	if _, err := g.repo.Reference(plumbing.NewRemoteReferenceName("origin", branch), true); err == nil {
+		rev = "origin/" + branch + "..HEAD"
 	}
 
-	return cfg.URLs[0], nil
+	out, err := runGitOutput(g.path, "rev-list", "--count", rev)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count unpushed commits: %w", err)
+	}
+
+	var count int
+	if _, err := fmt.Sscan(out, &count); err != nil {
+		return 0, fmt.Errorf("failed to count unpushed commits: %w", err)
+	}
+
+	return count, nil
 }
 
-// HasChanges returns true if there are uncommitted changes
-func (g *BuiltinGit) HasChanges() (bool, error) {
-	status, err := g.Status()
+// BehindCommits returns the number of commits on the remote branch, as
+// last fetched, that HEAD does not have yet
+func (g *BuiltinGit) BehindCommits() (int, error) {
+	if g.repo == nil {
+		return 0, fmt.Errorf("repository not initialized")
+	}
+
+	branch, err := g.GetBranch()
 	if err != nil {
-		return false, err
+		return 0, err
 	}
 
-	return !status.IsClean, nil
+	if _, err := g.repo.Reference(plumbing.NewRemoteReferenceName("origin", branch), true); err != nil {
+		return 0, nil
+	}
+
+	out, err := runGitOutput(g.path, "rev-list", "--count", "HEAD..origin/"+branch)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count remote commits: %w", err)
+	}
+
+	var count int
+	if _, err := fmt.Sscan(out, &count); err != nil {
+		return 0, fmt.Errorf("failed to count remote commits: %w", err)
+	}
+
+	return count, nil
 }
 
 // IsClean returns true if working directory is clean
 func (g *BuiltinGit) IsClean() (bool, error) {
-	status, err := g.Status()
+	if g.repo == nil {
+		return false, fmt.Errorf("repository not initialized")
+	}
+
+	// git reuses the stat data in its index, where go-git's status reads
+	// every file, which takes seconds on large trees
+	out, err := runGitOutput(g.path, append([]string{"status", "--porcelain"}, g.pathspec()...)...)
 	if err != nil {
-		return false, err
+		return false, fmt.Errorf("failed to get status: %w", err)
 	}
 
-	return status.IsClean, nil
+	return strings.TrimSpace(out) == "", nil
 }
 
 // GetLastCommit returns the last commit info
@@ -365,18 +1723,302 @@ func (g *BuiltinGit) GetLastCommit() (*CommitInfo, error) {
 	}, nil
 }
 
-func (g *BuiltinGit) Fetch() error {
+// Log returns the commits reachable from HEAD, newest first
+func (g *BuiltinGit) Log() ([]CommitInfo, error) {
+	if g.repo == nil {
+		return nil, fmt.Errorf("repository not initialized")
+	}
+
+	head, err := g.repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get HEAD: %w", err)
+	}
+
+	iter, err := g.repo.Log(&git.LogOptions{From: head.Hash()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	defer iter.Close()
+
+	var commits []CommitInfo
+	err = iter.ForEach(func(c *object.Commit) error {
+		commits = append(commits, CommitInfo{
+			Hash:      c.Hash.String()[:7],
+			Author:    c.Author.Name,
+			Email:     c.Author.Email,
+			Message:   c.Message,
+			Timestamp: c.Author.When,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+
+	return commits, nil
+}
+
+func (g *BuiltinGit) Fetch(ctx context.Context) error {
 	if g.repo == nil {
 		return fmt.Errorf("repository not initialized")
 	}
 
-	if err := runGitCommand(g.path, "fetch", "origin"); err != nil {
+	ctx, cancel := g.remoteContext(ctx)
+	defer cancel()
+
+	if err := g.download(ctx, "origin"); err != nil {
+		return err
+	}
+
+	if stderr, err := runGitCommandStderrContext(ctx, g.path, "fetch", "origin"); err != nil {
+		if err := g.timedOut(ctx, "fetch"); err != nil {
+			return err
+		}
+		if err := remoteError("origin", stderr, err); err != nil {
+			return err
+		}
 		return fmt.Errorf("failed to fetch: %w", err)
 	}
 
 	return nil
 }
 
+// IsShallow reports whether the repo is a shallow clone, whose history
+// stops at the commits it was cloned with
+func (g *BuiltinGit) IsShallow() (bool, error) {
+	if g.repo == nil {
+		return false, fmt.Errorf("repository not initialized")
+	}
+
+	out, err := runGitOutput(g.path, "rev-parse", "--is-shallow-repository")
+	if err != nil {
+		return false, fmt.Errorf("failed to check for a shallow clone: %w", err)
+	}
+	return strings.TrimSpace(out) == "true", nil
+}
+
+// Unshallow fetches the history a shallow clone left out
+func (g *BuiltinGit) Unshallow(ctx context.Context) error {
+	if g.repo == nil {
+		return fmt.Errorf("repository not initialized")
+	}
+
+	ctx, cancel := g.remoteContext(ctx)
+	defer cancel()
+
+	if err := g.download(ctx, "origin"); err != nil {
+		return err
+	}
+
+	if stderr, err := runGitCommandStderrContext(ctx, g.path, "fetch", "--unshallow", "origin"); err != nil {
+		if err := g.timedOut(ctx, "fetch"); err != nil {
+			return err
+		}
+		if err := remoteError("origin", stderr, err); err != nil {
+			return err
+		}
+		return fmt.Errorf("failed to fetch the full history: %w", err)
+	}
+
+	return nil
+}
+
+// ReplaceBranch points branch at a new commit without parents whose tree
+// holds files at its root. The previous commits of the branch become
+// unreachable, so the branch only ever carries the latest content.
+func (g *BuiltinGit) ReplaceBranch(branch string, files map[string][]byte, message string) error {
+	if g.repo == nil {
+		return fmt.Errorf("repository not initialized")
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tree := &object.Tree{}
+	for _, name := range names {
+		hash, err := g.storeObject(plumbing.BlobObject, func(w io.Writer) error {
+			_, err := w.Write(files[name])
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to store %s: %w", name, err)
+		}
+		tree.Entries = append(tree.Entries, object.TreeEntry{Name: name, Mode: filemode.Regular, Hash: hash})
+	}
+
+	treeHash, err := g.storeEncoded(tree)
+	if err != nil {
+		return fmt.Errorf("failed to store tree: %w", err)
+	}
+
+	// The files are read back relative to the subdir
+	if g.subdir != "" {
+		dirs := strings.Split(g.subdir, "/")
+		for i := len(dirs) - 1; i >= 0; i-- {
+			parent := &object.Tree{Entries: []object.TreeEntry{{Name: dirs[i], Mode: filemode.Dir, Hash: treeHash}}}
+			if treeHash, err = g.storeEncoded(parent); err != nil {
+				return fmt.Errorf("failed to store tree: %w", err)
+			}
+		}
+	}
+
+	sig := g.signature()
+	commitHash, err := g.storeEncoded(&object.Commit{
+		Author:    *sig,
+		Committer: *sig,
+		Message:   message,
+		TreeHash:  treeHash,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store commit: %w", err)
+	}
+
+	ref := plumbing.NewHashReference(plumbing.NewBranchReferenceName(branch), commitHash)
+	if err := g.repo.Storer.SetReference(ref); err != nil {
+		return fmt.Errorf("failed to update %s: %w", branch, err)
+	}
+
+	return nil
+}
+
+// storeObject writes an object of type t to the object database
+func (g *BuiltinGit) storeObject(t plumbing.ObjectType, write func(io.Writer) error) (plumbing.Hash, error) {
+	obj := g.repo.Storer.NewEncodedObject()
+	obj.SetType(t)
+
+	w, err := obj.Writer()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if err := write(w); err != nil {
+		w.Close()
+		return plumbing.ZeroHash, err
+	}
+	if err := w.Close(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return g.repo.Storer.SetEncodedObject(obj)
+}
+
+// storeEncoded writes a tree or commit to the object database
+func (g *BuiltinGit) storeEncoded(o interface {
+	Encode(plumbing.EncodedObject) error
+}) (plumbing.Hash, error) {
+	obj := g.repo.Storer.NewEncodedObject()
+	if err := o.Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return g.repo.Storer.SetEncodedObject(obj)
+}
+
+// PushBranch force pushes a local branch to the branch of the same name
+// on origin and updates the remote-tracking ref. Like ForcePush, it is
+// rejected if another machine moved the remote branch since it was last
+// fetched.
+func (g *BuiltinGit) PushBranch(ctx context.Context, branch string) error {
+	if g.repo == nil {
+		return fmt.Errorf("repository not initialized")
+	}
+
+	ctx, cancel := g.remoteContext(ctx)
+	defer cancel()
+
+	if err := g.download(ctx, "origin"); err != nil {
+		return err
+	}
+
+	refspec := "refs/heads/" + branch + ":refs/heads/" + branch
+	lease := fmt.Sprintf("--force-with-lease=refs/heads/%s:%s", branch, g.trackingHead(branch))
+	if stderr, err := runGitCommandStderrContext(ctx, g.path, "push", lease, "origin", refspec); err != nil {
+		if err := g.timedOut(ctx, "push"); err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("push interrupted: %w", err)
+		}
+		if isPushRejection(stderr) {
+			return &RejectedError{Remote: "origin", Err: err}
+		}
+		if err := remoteError("origin", stderr, err); err != nil {
+			return err
+		}
+		return &AuthError{Remote: "origin", Err: err}
+	}
+
+	if err := g.upload(ctx, "origin"); err != nil {
+		return err
+	}
+
+	return runGitCommand(g.path, "update-ref", "refs/remotes/origin/"+branch, "refs/heads/"+branch)
+}
+
+// PushHeadTo force pushes HEAD to a branch on origin without changing any
+// local branch, for branches only this machine writes to
+func (g *BuiltinGit) PushHeadTo(ctx context.Context, branch string) error {
+	if g.repo == nil {
+		return fmt.Errorf("repository not initialized")
+	}
+
+	ctx, cancel := g.remoteContext(ctx)
+	defer cancel()
+
+	if err := g.download(ctx, "origin"); err != nil {
+		return err
+	}
+
+	if stderr, err := runGitCommandStderrContext(ctx, g.path, "push", "--force", "origin", "HEAD:refs/heads/"+branch); err != nil {
+		if err := g.timedOut(ctx, "push"); err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("push interrupted: %w", err)
+		}
+		if err := remoteError("origin", stderr, err); err != nil {
+			return err
+		}
+		return &AuthError{Remote: "origin", Err: err}
+	}
+
+	return g.upload(ctx, "origin")
+}
+
+// FetchBranch fetches a single branch from origin, replacing both the
+// local branch and origin/<branch>. The branch must not be checked out. It
+// returns an error matching os.ErrNotExist if origin has no such branch.
+func (g *BuiltinGit) FetchBranch(ctx context.Context, branch string) error {
+	if g.repo == nil {
+		return fmt.Errorf("repository not initialized")
+	}
+
+	ctx, cancel := g.remoteContext(ctx)
+	defer cancel()
+
+	if err := g.download(ctx, "origin"); err != nil {
+		return err
+	}
+
+	local := "+refs/heads/" + branch + ":refs/heads/" + branch
+	tracking := "+refs/heads/" + branch + ":refs/remotes/origin/" + branch
+	if stderr, err := runGitCommandStderrContext(ctx, g.path, "fetch", "origin", local, tracking); err != nil {
+		if err := g.timedOut(ctx, "fetch"); err != nil {
+			return err
+		}
+		if strings.Contains(stderr, "couldn't find remote ref") {
+			return fmt.Errorf("branch %s not found on origin: %w", branch, os.ErrNotExist)
+		}
+		if err := remoteError("origin", stderr, err); err != nil {
+			return err
+		}
+		return fmt.Errorf("failed to fetch %s: %w", branch, err)
+	}
+
+	return nil
+}
+
 // GetBranch returns the current branch name
 func (g *BuiltinGit) GetBranch() (string, error) {
 	if g.repo == nil {
@@ -391,25 +2033,97 @@ func (g *BuiltinGit) GetBranch() (string, error) {
 	return head.Name().Short(), nil
 }
 
-// CheckoutBranch checks out a branch
+// CheckoutBranch checks out a local branch. Git's own checkout is used so
+// a sparse checkout and a separate git dir are respected.
 func (g *BuiltinGit) CheckoutBranch(branch string) error {
 	if g.repo == nil {
 		return fmt.Errorf("repository not initialized")
 	}
 
-	w, err := g.repo.Worktree()
+	if err := runGitCommand(g.path, "checkout", "--quiet", branch); err != nil {
+		return fmt.Errorf("failed to checkout branch %s: %w", branch, err)
+	}
+
+	return nil
+}
+
+// CreateBranch creates a local branch at HEAD without checking it out
+func (g *BuiltinGit) CreateBranch(branch string) error {
+	if g.repo == nil {
+		return fmt.Errorf("repository not initialized")
+	}
+
+	if err := runGitCommand(g.path, "branch", branch); err != nil {
+		return fmt.Errorf("failed to create branch %s: %w", branch, err)
+	}
+
+	return nil
+}
+
+// LocalBranches returns the names of the local branches
+func (g *BuiltinGit) LocalBranches() ([]string, error) {
+	if g.repo == nil {
+		return nil, fmt.Errorf("repository not initialized")
+	}
+
+	out, err := runGitOutput(g.path, "for-each-ref", "--format=%(refname:short)", "refs/heads")
 	if err != nil {
-		return fmt.Errorf("failed to get worktree: %w", err)
+		return nil, fmt.Errorf("failed to list branches: %w", err)
 	}
 
-	err = w.Checkout(&git.CheckoutOptions{
-		Branch: plumbing.NewBranchReferenceName(branch),
-	})
+	return outputLines(out), nil
+}
+
+// RemoteBranches asks origin for the names of its branches. Unlike the
+// remote-tracking refs, this also sees branches a shallow clone never
+// fetched.
+func (g *BuiltinGit) RemoteBranches(ctx context.Context) ([]string, error) {
+	if g.repo == nil {
+		return nil, fmt.Errorf("repository not initialized")
+	}
+
+	ctx, cancel := g.remoteContext(ctx)
+	defer cancel()
+
+	if err := g.download(ctx, "origin"); err != nil {
+		return nil, err
+	}
+
+	out, stderr, err := runGitOutputStderrContext(ctx, g.path, "ls-remote", "--heads", "origin")
 	if err != nil {
-		return fmt.Errorf("failed to checkout branch: %w", err)
+		if err := g.timedOut(ctx, "ls-remote"); err != nil {
+			return nil, err
+		}
+		if err := remoteError("origin", stderr, err); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to list remote branches: %w", err)
 	}
 
-	return nil
+	var branches []string
+	for _, line := range outputLines(out) {
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			branches = append(branches, strings.TrimPrefix(fields[1], "refs/heads/"))
+		}
+	}
+
+	return branches, nil
+}
+
+// DeletedFiles returns the files tracked at from that are gone at to
+func (g *BuiltinGit) DeletedFiles(from, to string) ([]string, error) {
+	if g.repo == nil {
+		return nil, fmt.Errorf("repository not initialized")
+	}
+
+	args := append([]string{"diff", "--name-only", "--no-renames", "--diff-filter=D"}, g.relativeArgs()...)
+	out, err := runGitOutput(g.path, append(args, from, to)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare %s and %s: %w", from, to, err)
+	}
+
+	return outputLines(out), nil
 }
 
 func (g *BuiltinGit) GC() error {
@@ -423,3 +2137,23 @@ func (g *BuiltinGit) GC() error {
 
 	return nil
 }
+
+// Fsck checks object integrity and returns a CorruptionError on failure
+func (g *BuiltinGit) Fsck() error {
+	if g.repo == nil {
+		return fmt.Errorf("repository not initialized")
+	}
+
+	cmd := exec.Command("git", "fsck", "--no-progress", "--no-dangling")
+	cmd.Dir = g.path
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		details := strings.TrimSpace(string(out))
+		if details == "" {
+			details = err.Error()
+		}
+		return &CorruptionError{Details: details}
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// BundleBaseRef records the last commit exchanged through a bundle, so
+// the next bundle only carries what came after it
+const BundleBaseRef = "refs/opencode-sync/bundle-base"
+
+// ErrMissingPrerequisites is returned when a bundle builds on commits this
+// repository does not have
+var ErrMissingPrerequisites = errors.New("bundle requires commits this repository does not have")
+
+// CreateBundle writes the current branch to a git bundle at file. With a
+// base, only commits after it are included and the receiving repository
+// must already have base.
+func (g *BuiltinGit) CreateBundle(file, base string) error {
+	if g.repo == nil {
+		return fmt.Errorf("repository not initialized")
+	}
+
+	branch, err := g.GetBranch()
+	if err != nil {
+		return err
+	}
+
+	file, err = filepath.Abs(file)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", file, err)
+	}
+
+	args := []string{"bundle", "create", "--quiet", file, "refs/heads/" + branch}
+	if base != "" {
+		args = append(args, "^"+base)
+	}
+	if out, err := exec.Command("git", append([]string{"-C", g.path}, args...)...).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to create bundle: %s", strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
+// ApplyBundle merges the branch carried by the bundle at file into the
+// current branch, as a pull would, and returns the bundle's tip commit
+func (g *BuiltinGit) ApplyBundle(file string) (string, error) {
+	if g.repo == nil {
+		return "", fmt.Errorf("repository not initialized")
+	}
+
+	file, err := filepath.Abs(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", file, err)
+	}
+
+	if out, err := exec.Command("git", "-C", g.path, "bundle", "verify", file).CombinedOutput(); err != nil {
+		if strings.Contains(string(out), "lacks these prerequisite commits") {
+			return "", ErrMissingPrerequisites
+		}
+		return "", fmt.Errorf("invalid bundle: %s", strings.TrimSpace(string(out)))
+	}
+
+	heads, err := runGitOutput(g.path, "bundle", "list-heads", file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read bundle: %w", err)
+	}
+
+	var tip, ref string
+	for _, line := range strings.Split(heads, "\n") {
+		hash, name, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if ok && strings.HasPrefix(name, "refs/heads/") {
+			tip, ref = hash, name
+			break
+		}
+	}
+	if ref == "" {
+		return "", fmt.Errorf("bundle has no branch")
+	}
+
+	if err := runGitCommand(g.path, "fetch", "--quiet", file, ref); err != nil {
+		return "", fmt.Errorf("failed to read bundle: %w", err)
+	}
+
+	// The first bundle between two machines set up separately shares no
+	// history with this repo
+	if err := runGitCommand(g.path, "merge", "--no-edit", "--allow-unrelated-histories", tip); err != nil {
+		if files := g.UnmergedFiles(); len(files) > 0 {
+			return "", &ConflictError{Files: files}
+		}
+		return "", fmt.Errorf("failed to merge bundle: %w", err)
+	}
+
+	return tip, nil
+}
+
+// SetBundleBase records the last commit exchanged through a bundle
+func (g *BuiltinGit) SetBundleBase(rev string) error {
+	return runGitCommand(g.path, "update-ref", BundleBaseRef, rev)
+}
@@ -0,0 +1,115 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// CredentialHelper returns the credential.helper value git uses for an
+// HTTP(S) remote URL, honoring URL-specific credential.<url>.helper
+// settings (e.g. "osxkeychain", "manager-core" or a path to
+// git-credential-libsecret). It returns "" when none is configured.
+func (g *BuiltinGit) CredentialHelper(url string) (string, error) {
+	out, err := runGitOutput(g.path, "config", "--get-urlmatch", "credential.helper", url)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(out), nil
+}
+
+// CredentialHelperPath returns the program git runs for a credential.helper
+// value, and whether it is installed. Shell helpers ("!...") are run as
+// given and always reported as found.
+func CredentialHelperPath(helper string) (string, bool) {
+	fields := strings.Fields(helper)
+	if len(fields) == 0 {
+		return "", false
+	}
+
+	if strings.HasPrefix(helper, "!") {
+		return helper, true
+	}
+
+	name := fields[0]
+	if filepath.IsAbs(name) {
+		_, err := os.Stat(name)
+		return name, err == nil
+	}
+
+	// Helpers such as osxkeychain ship in git's exec path, not on PATH
+	program := "git-credential-" + name
+	if out, err := exec.Command("git", "--exec-path").Output(); err == nil {
+		for _, ext := range []string{"", ".exe"} {
+			path := filepath.Join(strings.TrimSpace(string(out)), program+ext)
+			if _, err := os.Stat(path); err == nil {
+				return path, true
+			}
+		}
+	}
+
+	if path, err := exec.LookPath(program); err == nil {
+		return path, true
+	}
+
+	return program, false
+}
+
+// StoredPassword returns the password git's credential helpers have stored
+// for an HTTPS host, such as the token 'gh auth login' saves for
+// github.com. It never prompts, and returns "" if nothing is stored.
+func StoredPassword(host string) (string, error) {
+	_, password, err := StoredCredential("https", host, "")
+	return password, err
+}
+
+// StoredCredential returns the username and password git's credential
+// helpers have stored for a host reached over protocol (http or https),
+// for the given username if not empty. It never prompts, and returns
+// empty strings if nothing is stored.
+func StoredCredential(protocol, host, username string) (string, string, error) {
+	query := fmt.Sprintf("protocol=%s\nhost=%s\n", protocol, host)
+	if username != "" {
+		query += fmt.Sprintf("username=%s\n", username)
+	}
+
+	cmd := exec.Command("git", "credential", "fill")
+	cmd.Stdin = strings.NewReader(query + "\n")
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_ASKPASS=", "SSH_ASKPASS=")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		// Without a stored credential git fails rather than prompting
+		if strings.Contains(stderr.String(), "terminal prompts disabled") {
+			return "", "", nil
+		}
+		return "", "", fmt.Errorf("failed to read stored credentials: %w", err)
+	}
+
+	var password string
+	for _, line := range strings.Split(string(out), "\n") {
+		line = strings.TrimSpace(line)
+		if value, ok := strings.CutPrefix(line, "username="); ok {
+			username = value
+		}
+		if value, ok := strings.CutPrefix(line, "password="); ok {
+			password = value
+		}
+	}
+	if password == "" {
+		return "", "", nil
+	}
+
+	return username, password, nil
+}
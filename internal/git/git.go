@@ -1,6 +1,8 @@
 package git
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"time"
 )
@@ -8,7 +10,17 @@ import (
 // Repository represents a Git repository interface
 type Repository interface {
 	// Clone clones a repository from URL to the repo path
-	Clone(url string) error
+	Clone(ctx context.Context, url string) error
+
+	// CloneSparse clones a repository checking out only the given directories
+	CloneSparse(ctx context.Context, url string, dirs []string) error
+
+	// SetSparseDirs restricts the working tree to the given directories
+	SetSparseDirs(dirs []string) error
+
+	// SetSparsePatterns restricts the working tree to paths matching
+	// gitignore-style patterns
+	SetSparsePatterns(patterns []string) error
 
 	// Init initializes a new repository
 	Init() error
@@ -28,35 +40,135 @@ type Repository interface {
 	// Commit creates a new commit with the given message
 	Commit(message string) error
 
+	// Amend replaces HEAD with a commit of the staged changes
+	Amend(message string) error
+
 	// Push pushes commits to the remote
-	Push() error
+	Push(ctx context.Context) error
+
+	// ForcePushWithLease overwrites the remote branch only if it still
+	// points at expect
+	ForcePushWithLease(ctx context.Context, expect string) error
 
-	// ForcePush force pushes commits to the remote (overwrites remote)
-	ForcePush() error
+	// ForcePush overwrites the remote branch, as long as it still points
+	// at the commit last fetched from it
+	ForcePush(ctx context.Context) error
 
 	// Pull pulls changes from the remote
-	Pull() error
+	Pull(ctx context.Context) error
 
 	// Diff returns the diff between working directory and HEAD
 	Diff() (string, error)
 
+	// ShowCommit returns the stat summary and patch of a revision
+	ShowCommit(rev string) (string, error)
+
+	// ResetSoft moves HEAD to a revision, keeping changes staged
+	ResetSoft(rev string) error
+
+	// ResetHard moves HEAD to a revision, discarding changes
+	ResetHard(rev string) error
+
+	// Revert creates a commit that undoes the given revision
+	Revert(rev string) error
+
+	// Rebase replays local commits on top of upstream
+	Rebase(upstream string) error
+
+	// SetMergeDriver registers a custom merge driver in the repository config
+	SetMergeDriver(name, description, command string) error
+
+	// GetHead returns the full hash of HEAD
+	GetHead() (string, error)
+
+	// RevParse returns the full hash a revision resolves to
+	RevParse(rev string) (string, error)
+
+	// IsAncestor reports whether ancestor is rev or one of its ancestors
+	IsAncestor(ancestor, rev string) (bool, error)
+
+	// ReadFileAt returns the content of a file at the given revision
+	ReadFileAt(rev, path string) ([]byte, error)
+
+	// ListTreeAt returns the entries of a directory at the given revision
+	ListTreeAt(rev, dir string) ([]TreeEntry, error)
+
+	// Log returns the commits reachable from HEAD, newest first
+	Log() ([]CommitInfo, error)
+
+	// GetLastCommit returns the HEAD commit
+	GetLastCommit() (*CommitInfo, error)
+
 	// GetRemoteURL returns the URL of the given remote
 	GetRemoteURL(name string) (string, error)
 
+	// SetRemote creates the named remote or updates its URL
+	SetRemote(name, url string) error
+
+	// FetchRemote fetches a remote and records its default branch
+	FetchRemote(ctx context.Context, name string) error
+
+	// MergeNoCommit merges a revision without committing and returns conflicts
+	MergeNoCommit(rev string) ([]string, error)
+
+	// MergeInProgress reports whether a merge is waiting to be committed
+	MergeInProgress() bool
+
+	// CommitMerge concludes an in-progress merge with a merge commit
+	CommitMerge(message string) error
+
+	// MergeAbort abandons an in-progress merge
+	MergeAbort() error
+
+	// StagedChanges returns the paths and stat summary of staged changes
+	StagedChanges() ([]string, string, error)
+
+	// ResolvePath resolves a path to "ours" or "theirs" during a merge
+	ResolvePath(path, side string) error
+
 	// HasChanges returns true if there are uncommitted changes
 	HasChanges() (bool, error)
 
 	// IsClean returns true if working directory is clean
 	IsClean() (bool, error)
 
+	// UnpushedCommits returns how many local commits are not on origin
+	UnpushedCommits() (int, error)
+
 	// GC runs git garbage collection to optimize repository size
 	GC() error
 
+	// Fsck validates the connectivity and integrity of repository objects
+	Fsck() error
+
 	// GetBranch returns the current branch name
 	GetBranch() (string, error)
 
 	// Fetch fetches updates from remote without merging
-	Fetch() error
+	Fetch(ctx context.Context) error
+
+	// Submodules returns the paths of the repository's submodules
+	Submodules() ([]string, error)
+
+	// ReplaceBranch points branch at a new parentless commit holding files
+	ReplaceBranch(branch string, files map[string][]byte, message string) error
+
+	// PushBranch force pushes a local branch to origin, as long as the
+	// remote branch still points at the commit last fetched from it
+	PushBranch(ctx context.Context, branch string) error
+
+	// FetchBranch replaces a local branch with the one on origin
+	FetchBranch(ctx context.Context, branch string) error
+
+	// DeletedFiles returns the files tracked at from that are gone at to
+	DeletedFiles(from, to string) ([]string, error)
+
+	// Renames returns the files renamed between from and to
+	Renames(from, to string) ([]FileChange, error)
+
+	// WorktreeChanges returns the changes in the working tree since HEAD,
+	// with renamed files paired up
+	WorktreeChanges() ([]FileChange, error)
 }
 
 // Status represents repository status
@@ -119,6 +231,14 @@ type CommitInfo struct {
 	Timestamp time.Time
 }
 
+// Error categories. The typed errors below match one of these with
+// errors.Is, so callers can react to a category without knowing the type.
+var (
+	ErrAuth     = errors.New("authentication failed")
+	ErrConflict = errors.New("conflicting changes")
+	ErrNetwork  = errors.New("network error")
+)
+
 // ConflictError represents a merge conflict
 type ConflictError struct {
 	Files []string
@@ -128,6 +248,33 @@ func (e *ConflictError) Error() string {
 	return fmt.Sprintf("merge conflict in %d file(s)", len(e.Files))
 }
 
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// CorruptionError represents a failed repository integrity check
+type CorruptionError struct {
+	Details string
+}
+
+func (e *CorruptionError) Error() string {
+	return fmt.Sprintf("repository is corrupted: %s", e.Details)
+}
+
+// RejectedError represents a push rejected because the remote has new commits
+type RejectedError struct {
+	Remote string
+	Err    error
+}
+
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("push to %s was rejected because the remote has new commits: %v", e.Remote, e.Err)
+}
+
+func (e *RejectedError) Unwrap() error {
+	return e.Err
+}
+
 // AuthError represents an authentication error
 type AuthError struct {
 	Remote string
@@ -141,3 +288,43 @@ func (e *AuthError) Error() string {
 func (e *AuthError) Unwrap() error {
 	return e.Err
 }
+
+func (e *AuthError) Is(target error) bool {
+	return target == ErrAuth
+}
+
+// NetworkError represents a remote that could not be reached
+type NetworkError struct {
+	Remote string
+	Err    error
+}
+
+func (e *NetworkError) Error() string {
+	return fmt.Sprintf("could not reach remote %s: %v", e.Remote, e.Err)
+}
+
+func (e *NetworkError) Unwrap() error {
+	return e.Err
+}
+
+func (e *NetworkError) Is(target error) bool {
+	return target == ErrNetwork
+}
+
+// TimeoutError represents a remote operation that exceeded its time limit
+type TimeoutError struct {
+	Op      string
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("git %s timed out after %v", e.Op, e.Timeout)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+func (e *TimeoutError) Is(target error) bool {
+	return target == ErrNetwork
+}
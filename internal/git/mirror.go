@@ -0,0 +1,348 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MirrorCommit is a commit of a mirror, as a Transport that carries the
+// history to another version control system exchanges it
+type MirrorCommit struct {
+	Hash    string
+	Parents []string
+	Author  string
+	Email   string
+	Time    time.Time
+	Message string
+}
+
+// Branches returns the mirror's branches and the commits they point at
+func (m *Mirror) Branches() (map[string]string, error) {
+	out, err := runGitOutput(m.Path, "for-each-ref", "--format=%(objectname) %(refname:strip=2)", "refs/heads")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mirror branches: %w", err)
+	}
+
+	branches := map[string]string{}
+	for _, line := range outputLines(out) {
+		if hash, name, ok := strings.Cut(line, " "); ok {
+			branches[name] = hash
+		}
+	}
+	return branches, nil
+}
+
+// SetBranches points the mirror's branches at the given commits and
+// removes the branches not given
+func (m *Mirror) SetBranches(branches map[string]string) error {
+	current, err := m.Branches()
+	if err != nil {
+		return err
+	}
+
+	for name, hash := range branches {
+		if current[name] == hash {
+			continue
+		}
+		if _, err := runGitOutput(m.Path, "update-ref", "refs/heads/"+name, hash); err != nil {
+			return fmt.Errorf("failed to update mirror branch %s: %w", name, err)
+		}
+	}
+	for name := range current {
+		if _, ok := branches[name]; ok {
+			continue
+		}
+		if _, err := runGitOutput(m.Path, "update-ref", "-d", "refs/heads/"+name); err != nil {
+			return fmt.Errorf("failed to remove mirror branch %s: %w", name, err)
+		}
+	}
+
+	return m.fixHead()
+}
+
+// IsAncestor reports whether ancestor is reachable from commit
+func (m *Mirror) IsAncestor(ancestor, commit string) bool {
+	_, err := runGitOutput(m.Path, "merge-base", "--is-ancestor", ancestor, commit)
+	return err == nil
+}
+
+// Commits returns the commits reachable from tip but not from any of
+// exclude, parents before their children
+func (m *Mirror) Commits(tip string, exclude []string) ([]MirrorCommit, error) {
+	args := []string{"log", "-z", "--topo-order", "--reverse", "--date=raw",
+		"--format=%H%x1f%P%x1f%an%x1f%ae%x1f%ad%x1f%B", tip}
+	if len(exclude) > 0 {
+		args = append(append(args, "--not"), exclude...)
+	}
+	out, err := runGitOutput(m.Path, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mirror commits: %w", err)
+	}
+
+	var commits []MirrorCommit
+	for _, record := range strings.Split(out, "\x00") {
+		if record == "" {
+			continue
+		}
+		commit, err := parseMirrorCommit(record)
+		if err != nil {
+			return nil, err
+		}
+		commits = append(commits, commit)
+	}
+	return commits, nil
+}
+
+// Commit returns one commit of the mirror
+func (m *Mirror) Commit(hash string) (*MirrorCommit, error) {
+	out, err := runGitOutput(m.Path, "log", "-1", "--date=raw",
+		"--format=%H%x1f%P%x1f%an%x1f%ae%x1f%ad%x1f%B", hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mirror commit %s: %w", hash, err)
+	}
+	commit, err := parseMirrorCommit(out)
+	if err != nil {
+		return nil, err
+	}
+	return &commit, nil
+}
+
+// parseMirrorCommit parses a commit as Commits formats it
+func parseMirrorCommit(record string) (MirrorCommit, error) {
+	fields := strings.SplitN(record, "\x1f", 6)
+	if len(fields) != 6 {
+		return MirrorCommit{}, fmt.Errorf("unexpected git log output: %q", record)
+	}
+
+	when, err := parseRawDate(fields[4])
+	if err != nil {
+		return MirrorCommit{}, err
+	}
+	return MirrorCommit{
+		Hash:    strings.TrimSpace(fields[0]),
+		Parents: strings.Fields(fields[1]),
+		Author:  fields[2],
+		Email:   fields[3],
+		Time:    when,
+		Message: strings.TrimRight(fields[5], "\n"),
+	}, nil
+}
+
+// parseRawDate parses a date in git's raw format, "1700000000 +0100"
+func parseRawDate(raw string) (time.Time, error) {
+	secs, zone, _ := strings.Cut(strings.TrimSpace(raw), " ")
+	unix, err := strconv.ParseInt(secs, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid commit date %q", raw)
+	}
+
+	offset := 0
+	if len(zone) == 5 {
+		hours, _ := strconv.Atoi(zone[1:3])
+		minutes, _ := strconv.Atoi(zone[3:5])
+		offset = hours*3600 + minutes*60
+		if zone[0] == '-' {
+			offset = -offset
+		}
+	}
+	return time.Unix(unix, 0).In(time.FixedZone("", offset)), nil
+}
+
+// rawDate writes a time in git's raw date format
+func rawDate(t time.Time) string {
+	return fmt.Sprintf("%d %s", t.Unix(), t.Format("-0700"))
+}
+
+// Checkout writes the files of a commit into dir, exactly as committed:
+// no attributes, filters or line ending conversion apply
+func (m *Mirror) Checkout(commit, dir string) error {
+	out, err := runGitOutput(m.Path, "ls-tree", "-r", "-z", "--full-tree", commit)
+	if err != nil {
+		return fmt.Errorf("failed to list files of %s: %w", commit, err)
+	}
+
+	type entry struct {
+		mode, hash, path string
+	}
+	var entries []entry
+	var hashes bytes.Buffer
+	for _, line := range strings.Split(out, "\x00") {
+		meta, path, ok := strings.Cut(line, "\t")
+		fields := strings.Fields(meta)
+		// Submodules have no content here
+		if !ok || len(fields) != 3 || fields[1] != "blob" {
+			continue
+		}
+		entries = append(entries, entry{mode: fields[0], hash: fields[2], path: path})
+		hashes.WriteString(fields[2] + "\n")
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	contents, err := runGitInput(m.Path, nil, hashes.Bytes(), "cat-file", "--batch")
+	if err != nil {
+		return fmt.Errorf("failed to read files of %s: %w", commit, err)
+	}
+
+	r := bufio.NewReader(strings.NewReader(contents))
+	for _, e := range entries {
+		header, err := r.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", e.path, err)
+		}
+		fields := strings.Fields(header)
+		if len(fields) != 3 {
+			return fmt.Errorf("failed to read %s: %s", e.path, strings.TrimSpace(header))
+		}
+		size, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", e.path, err)
+		}
+		data := make([]byte, size+1)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return fmt.Errorf("failed to read %s: %w", e.path, err)
+		}
+		data = data[:size]
+
+		target := filepath.Join(dir, filepath.FromSlash(e.path))
+		if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %w", e.path, err)
+		}
+		switch e.mode {
+		case "120000":
+			err = os.Symlink(string(data), target)
+		case "100755":
+			err = os.WriteFile(target, data, 0700)
+		default:
+			err = os.WriteFile(target, data, 0600)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", e.path, err)
+		}
+	}
+
+	return nil
+}
+
+// CommitFiles records the files in dir as a commit of the mirror with
+// the parents, author, time and message of c, and returns its hash. The
+// files are taken exactly as they are, like Checkout writes them, and the
+// author is also the committer, so the same files and metadata always
+// give the same commit.
+func (m *Mirror) CommitFiles(dir string, c MirrorCommit) (string, error) {
+	// Regular files are stored in one go, symlinks one by one
+	type file struct {
+		mode, path, hash string
+	}
+	var files []file
+	var regular bytes.Buffer
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		switch {
+		case d.Type()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			out, err := runGitInput(m.Path, nil, []byte(filepath.ToSlash(target)), "hash-object", "-w", "--no-filters", "--stdin")
+			if err != nil {
+				return fmt.Errorf("failed to store %s: %w", rel, err)
+			}
+			files = append(files, file{mode: "120000", path: rel, hash: strings.TrimSpace(out)})
+		case d.Type().IsRegular():
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			mode := "100644"
+			if info.Mode()&0100 != 0 {
+				mode = "100755"
+			}
+			files = append(files, file{mode: mode, path: rel})
+			regular.WriteString(path + "\n")
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to read files to commit: %w", err)
+	}
+
+	if regular.Len() > 0 {
+		out, err := runGitInput(m.Path, nil, regular.Bytes(), "hash-object", "-w", "--no-filters", "--stdin-paths")
+		if err != nil {
+			return "", fmt.Errorf("failed to store files: %w", err)
+		}
+		hashes := outputLines(out)
+		for i := range files {
+			if files[i].hash != "" {
+				continue
+			}
+			if len(hashes) == 0 {
+				return "", fmt.Errorf("failed to store files: git returned too few hashes")
+			}
+			files[i].hash, hashes = hashes[0], hashes[1:]
+		}
+	}
+
+	var index bytes.Buffer
+	for _, f := range files {
+		fmt.Fprintf(&index, "%s %s\t%s\x00", f.mode, f.hash, f.path)
+	}
+
+	indexFile, err := os.CreateTemp(filepath.Dir(m.Path), "mirror-*.index")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary index: %w", err)
+	}
+	indexFile.Close()
+	os.Remove(indexFile.Name())
+	defer os.Remove(indexFile.Name())
+	env := []string{"GIT_INDEX_FILE=" + indexFile.Name()}
+
+	if _, err := runGitInput(m.Path, env, index.Bytes(), "update-index", "-z", "--add", "--index-info"); err != nil {
+		return "", fmt.Errorf("failed to stage files: %w", err)
+	}
+	tree, err := runGitOutputEnv(m.Path, env, "write-tree")
+	if err != nil {
+		return "", fmt.Errorf("failed to write tree: %w", err)
+	}
+
+	args := []string{"commit-tree", strings.TrimSpace(tree)}
+	for _, parent := range c.Parents {
+		args = append(args, "-p", parent)
+	}
+	author := c.Author
+	if author == "" {
+		author = "unknown"
+	}
+	date := rawDate(c.Time)
+	env = append(env,
+		"GIT_AUTHOR_NAME="+author, "GIT_AUTHOR_EMAIL="+c.Email, "GIT_AUTHOR_DATE="+date,
+		"GIT_COMMITTER_NAME="+author, "GIT_COMMITTER_EMAIL="+c.Email, "GIT_COMMITTER_DATE="+date,
+	)
+	out, err := runGitInput(m.Path, env, []byte(c.Message+"\n"), append(args, "-F", "-")...)
+	if err != nil {
+		return "", fmt.Errorf("failed to commit: %w", err)
+	}
+	return strings.TrimSpace(out), nil
+}
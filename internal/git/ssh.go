@@ -0,0 +1,158 @@
+package git
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// SSHCommand replaces the ssh command git runs for SSH remotes, through
+// GIT_SSH_COMMAND. Empty leaves git's own configuration alone.
+var SSHCommand string
+
+// Host key check results
+var (
+	ErrHostKeyUnknown  = errors.New("host key is not in known_hosts")
+	ErrHostKeyMismatch = errors.New("host key does not match")
+)
+
+// SSHEndpoint returns the host and port of an SSH remote URL, either
+// ssh://[user@]host[:port]/path (or sftp://) or the scp-like
+// [user@]host:path. It returns false for other URLs.
+func SSHEndpoint(url string) (host, port string, ok bool) {
+	for _, scheme := range []string{"ssh://", "git+ssh://", "ssh+git://", "sftp://"} {
+		if rest, found := strings.CutPrefix(url, scheme); found {
+			hostPort, _, _ := strings.Cut(rest, "/")
+			if i := strings.LastIndex(hostPort, "@"); i >= 0 {
+				hostPort = hostPort[i+1:]
+			}
+			if h, p, err := net.SplitHostPort(hostPort); err == nil {
+				return h, p, h != ""
+			}
+			return strings.Trim(hostPort, "[]"), "22", hostPort != ""
+		}
+	}
+
+	if strings.Contains(url, "://") {
+		return "", "", false
+	}
+
+	// scp-like syntax needs a colon before the first slash, and a host
+	// longer than a Windows drive letter
+	hostPart, _, found := strings.Cut(url, ":")
+	if !found || strings.Contains(hostPart, "/") {
+		return "", "", false
+	}
+	if i := strings.LastIndex(hostPart, "@"); i >= 0 {
+		hostPart = hostPart[i+1:]
+	}
+	hostPart = strings.Trim(hostPart, "[]")
+	if len(hostPart) < 2 {
+		return "", "", false
+	}
+
+	return hostPart, "22", true
+}
+
+// FingerprintSHA256 returns the OpenSSH SHA256 fingerprint of a base64
+// encoded public key, as printed by ssh-keygen -l
+func FingerprintSHA256(key string) (string, error) {
+	blob, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode host key: %w", err)
+	}
+
+	pub, err := ssh.ParsePublicKey(blob)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse host key: %w", err)
+	}
+
+	return ssh.FingerprintSHA256(pub), nil
+}
+
+// errKeyScanned stops the SSH handshake once the host key is known
+var errKeyScanned = errors.New("host key scanned")
+
+// CheckHostKey connects to the SSH server of a remote URL and checks its
+// host key: against a pinned SHA256 fingerprint when one is given, or
+// otherwise against the user's and system's known_hosts files. It returns
+// the server's fingerprint, along with ErrHostKeyUnknown or
+// ErrHostKeyMismatch when the check fails.
+func CheckHostKey(ctx context.Context, url, fingerprint string) (string, error) {
+	host, port, ok := SSHEndpoint(url)
+	if !ok {
+		return "", fmt.Errorf("%s is not an SSH remote", url)
+	}
+
+	addr := net.JoinHostPort(host, port)
+	dialer := net.Dialer{Timeout: 30 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return "", &NetworkError{Remote: url, Err: err}
+	}
+	defer conn.Close()
+
+	var key ssh.PublicKey
+	var remote net.Addr
+	cfg := &ssh.ClientConfig{
+		User: "git",
+		HostKeyCallback: func(_ string, r net.Addr, k ssh.PublicKey) error {
+			key, remote = k, r
+			return errKeyScanned
+		},
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	if _, _, _, err := ssh.NewClientConn(conn, addr, cfg); key == nil {
+		return "", fmt.Errorf("failed to read host key from %s: %w", addr, err)
+	}
+
+	got := ssh.FingerprintSHA256(key)
+	if fingerprint != "" {
+		if got != fingerprint {
+			return got, fmt.Errorf("%w: %s presented %s, expected %s", ErrHostKeyMismatch, host, got, fingerprint)
+		}
+		return got, nil
+	}
+
+	var files []string
+	if home, err := os.UserHomeDir(); err == nil {
+		files = append(files, filepath.Join(home, ".ssh", "known_hosts"))
+	}
+	files = append(files, "/etc/ssh/ssh_known_hosts")
+
+	var existing []string
+	for _, file := range files {
+		if _, err := os.Stat(file); err == nil {
+			existing = append(existing, file)
+		}
+	}
+	if len(existing) == 0 {
+		return got, fmt.Errorf("%w: %s (%s)", ErrHostKeyUnknown, host, got)
+	}
+
+	callback, err := knownhosts.New(existing...)
+	if err != nil {
+		return got, fmt.Errorf("failed to read known_hosts: %w", err)
+	}
+
+	var keyErr *knownhosts.KeyError
+	switch err := callback(addr, remote, key); {
+	case err == nil:
+		return got, nil
+	case errors.As(err, &keyErr) && len(keyErr.Want) == 0:
+		return got, fmt.Errorf("%w: %s (%s)", ErrHostKeyUnknown, host, got)
+	default:
+		return got, fmt.Errorf("%w: %s presented %s: %v", ErrHostKeyMismatch, host, got, err)
+	}
+}
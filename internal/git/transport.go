@@ -0,0 +1,254 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Transport carries a repository to a remote git cannot reach by itself,
+// such as a WebDAV folder. Git works against a local bare mirror in its
+// place: the remote's URL is rewritten to the mirror for every git
+// command, the mirror is downloaded before git reads from it and
+// uploaded after git pushes to it.
+type Transport interface {
+	// Mirror returns the path of the bare repository standing in for the
+	// remote
+	Mirror() string
+
+	// Download updates the mirror from the remote
+	Download(ctx context.Context) error
+
+	// Upload sends the mirror to the remote. It returns a RejectedError if
+	// another machine uploaded since the last Download.
+	Upload(ctx context.Context) error
+}
+
+// TransportOpener returns the Transport for a remote URL
+type TransportOpener func(url string) (Transport, error)
+
+var (
+	transportMu sync.Mutex
+	openers     = map[string]TransportOpener{}
+	transports  = map[string]Transport{}
+)
+
+// RegisterTransport makes URLs with the given scheme go through the
+// Transport open returns for them
+func RegisterTransport(scheme string, open TransportOpener) {
+	transportMu.Lock()
+	defer transportMu.Unlock()
+	openers[scheme] = open
+}
+
+// IsTransportURL reports whether a registered Transport carries rawURL
+func IsTransportURL(rawURL string) bool {
+	transportMu.Lock()
+	defer transportMu.Unlock()
+	_, ok := openers[urlScheme(rawURL)]
+	return ok
+}
+
+// urlScheme returns the scheme of rawURL, or "" for paths and scp-like
+// addresses
+func urlScheme(rawURL string) string {
+	scheme, _, ok := strings.Cut(rawURL, "://")
+	if !ok {
+		return ""
+	}
+	return strings.ToLower(scheme)
+}
+
+// transportFor returns the Transport carrying rawURL, opening it on first
+// use, or nil if git reaches rawURL itself
+func transportFor(rawURL string) (Transport, error) {
+	transportMu.Lock()
+	defer transportMu.Unlock()
+
+	if t, ok := transports[rawURL]; ok {
+		return t, nil
+	}
+	open, ok := openers[urlScheme(rawURL)]
+	if !ok {
+		return nil, nil
+	}
+
+	t, err := open(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	transports[rawURL] = t
+	return t, nil
+}
+
+// transportEnv returns the environment that points git at the mirrors of
+// the open transports instead of their URLs
+func transportEnv() []string {
+	transportMu.Lock()
+	defer transportMu.Unlock()
+
+	if len(transports) == 0 {
+		return nil
+	}
+
+	urls := make([]string, 0, len(transports))
+	for rawURL := range transports {
+		urls = append(urls, rawURL)
+	}
+	sort.Strings(urls)
+
+	env := []string{"GIT_CONFIG_COUNT=" + strconv.Itoa(len(urls))}
+	for i, rawURL := range urls {
+		n := strconv.Itoa(i)
+		env = append(env,
+			"GIT_CONFIG_KEY_"+n+"=url."+fileURL(transports[rawURL].Mirror())+".insteadOf",
+			"GIT_CONFIG_VALUE_"+n+"="+rawURL,
+		)
+	}
+	return env
+}
+
+// fileURL returns the file:// URL of a local path. Shallow clones need a
+// URL rather than a plain path.
+func fileURL(path string) string {
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		// A Windows drive letter
+		path = "/" + path
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+// downloadURL updates the mirror standing in for rawURL, if a Transport
+// carries it
+func (g *BuiltinGit) downloadURL(ctx context.Context, rawURL string) error {
+	t, err := transportFor(rawURL)
+	if err != nil || t == nil {
+		return err
+	}
+	if err := t.Download(ctx); err != nil {
+		if timeout := g.timedOut(ctx, "download"); timeout != nil {
+			return timeout
+		}
+		return err
+	}
+	return nil
+}
+
+// download updates the mirror standing in for the named remote, if a
+// Transport carries it
+func (g *BuiltinGit) download(ctx context.Context, remote string) error {
+	rawURL, err := g.GetRemoteURL(remote)
+	if err != nil {
+		// Without a URL git reports the problem itself
+		return nil
+	}
+	return g.downloadURL(ctx, rawURL)
+}
+
+// upload sends the mirror standing in for the named remote to it, after
+// git pushed to the mirror
+func (g *BuiltinGit) upload(ctx context.Context, remote string) error {
+	rawURL, err := g.GetRemoteURL(remote)
+	if err != nil {
+		return nil
+	}
+	t, err := transportFor(rawURL)
+	if err != nil || t == nil {
+		return err
+	}
+	if err := t.Upload(ctx); err != nil {
+		if timeout := g.timedOut(ctx, "upload"); timeout != nil {
+			return timeout
+		}
+		return err
+	}
+	return nil
+}
+
+// Mirror is a bare repository standing in for a remote that a Transport
+// carries
+type Mirror struct {
+	Path string
+}
+
+// Init creates the mirror if it does not exist yet
+func (m *Mirror) Init() error {
+	if _, err := os.Stat(filepath.Join(m.Path, "HEAD")); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(m.Path), 0700); err != nil {
+		return fmt.Errorf("failed to create mirror directory: %w", err)
+	}
+	if _, err := runGitOutput("", "init", "--quiet", "--bare", m.Path); err != nil {
+		return fmt.Errorf("failed to create mirror: %w", err)
+	}
+	return nil
+}
+
+// Refs describes the mirror's branches and the commits they point at,
+// so a change to any of them changes the result
+func (m *Mirror) Refs() (string, error) {
+	out, err := runGitOutput(m.Path, "for-each-ref", "--format=%(objectname) %(refname)", "refs/heads")
+	if err != nil {
+		return "", fmt.Errorf("failed to list mirror branches: %w", err)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// WriteBundle writes all branches of the mirror to a git bundle at file.
+// The mirror must have at least one branch.
+func (m *Mirror) WriteBundle(file string) error {
+	if err := m.fixHead(); err != nil {
+		return err
+	}
+	if _, err := runGitOutput(m.Path, "bundle", "create", "--quiet", file, "--branches"); err != nil {
+		return fmt.Errorf("failed to create bundle: %w", err)
+	}
+	return nil
+}
+
+// ReadBundle replaces the mirror's branches with those of the bundle at
+// file, removing branches the bundle does not have
+func (m *Mirror) ReadBundle(file string) error {
+	if _, err := runGitOutput(m.Path, "fetch", "--quiet", "--prune", "--force", file, "refs/heads/*:refs/heads/*"); err != nil {
+		return fmt.Errorf("failed to read bundle: %w", err)
+	}
+	return m.fixHead()
+}
+
+// fixHead points HEAD at an existing branch, so clones of the mirror
+// check one out: main or master if present, otherwise the first one
+func (m *Mirror) fixHead() error {
+	if _, err := runGitOutput(m.Path, "rev-parse", "--verify", "--quiet", "HEAD"); err == nil {
+		return nil
+	}
+
+	out, err := runGitOutput(m.Path, "for-each-ref", "--format=%(refname)", "refs/heads")
+	if err != nil {
+		return fmt.Errorf("failed to list mirror branches: %w", err)
+	}
+	branches := outputLines(out)
+	if len(branches) == 0 {
+		return nil
+	}
+
+	head := branches[0]
+	for _, name := range []string{"refs/heads/master", "refs/heads/main"} {
+		if slices.Contains(branches, name) {
+			head = name
+		}
+	}
+
+	if _, err := runGitOutput(m.Path, "symbolic-ref", "HEAD", head); err != nil {
+		return fmt.Errorf("failed to set mirror HEAD: %w", err)
+	}
+	return nil
+}
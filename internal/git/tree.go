@@ -0,0 +1,353 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// RenameSimilarity is the least percentage of its content a file must
+// keep for a deletion and an addition to count as a rename
+const RenameSimilarity = 50
+
+// TreeEntry is a file or directory in a committed tree
+type TreeEntry struct {
+	Name  string
+	IsDir bool
+	Size  int64
+
+	// Submodule is set for a submodule, which is also a directory
+	Submodule bool
+}
+
+// ListTreeAt returns the entries of dir (slash-separated, relative to the
+// repo root or the subdir set with SetSubdir, "" for that) at rev,
+// directories first. It returns os.ErrNotExist if dir is not a directory
+// at rev.
+func (g *BuiltinGit) ListTreeAt(rev, dir string) ([]TreeEntry, error) {
+	if g.repo == nil {
+		return nil, fmt.Errorf("repository not initialized")
+	}
+
+	hash, err := g.repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", rev, err)
+	}
+
+	commit, err := g.repo.CommitObject(*hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get commit: %w", err)
+	}
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tree: %w", err)
+	}
+
+	root := path.Clean("/"+dir) == "/"
+	if dir = path.Clean("/" + g.repoPath(dir))[1:]; dir != "" {
+		tree, err = tree.Tree(dir)
+		if err == object.ErrDirectoryNotFound && root {
+			// Nothing was committed to the subdir yet
+			return []TreeEntry{}, nil
+		}
+		if err == object.ErrDirectoryNotFound {
+			return nil, os.ErrNotExist
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s at %s: %w", dir, rev, err)
+		}
+	}
+
+	entries := make([]TreeEntry, 0, len(tree.Entries))
+	for _, e := range tree.Entries {
+		entry := TreeEntry{Name: e.Name, IsDir: e.Mode == filemode.Dir || e.Mode == filemode.Submodule, Submodule: e.Mode == filemode.Submodule}
+		if e.Mode.IsFile() {
+			if entry.Size, err = tree.Size(e.Name); err != nil {
+				return nil, fmt.Errorf("failed to stat %s: %w", path.Join(dir, e.Name), err)
+			}
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].IsDir && !entries[j].IsDir
+	})
+
+	return entries, nil
+}
+
+// HistoryPaths returns every path whose base name is one of names in any
+// commit reachable from a local or remote-tracking ref, sorted
+func (g *BuiltinGit) HistoryPaths(names ...string) ([]string, error) {
+	if g.repo == nil {
+		return nil, fmt.Errorf("repository not initialized")
+	}
+
+	args := append([]string{"log", "--all", "--format=", "--name-only"}, g.relativeArgs()...)
+	args = append(args, "--")
+	for _, name := range names {
+		args = append(args, ":(glob)"+g.repoPath("**/"+name))
+	}
+
+	out, err := runGitOutput(g.path, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search history: %w", err)
+	}
+
+	seen := map[string]bool{}
+	var found []string
+	for _, line := range strings.Split(out, "\n") {
+		if line = strings.TrimSpace(line); line != "" && !seen[line] {
+			seen[line] = true
+			found = append(found, line)
+		}
+	}
+	sort.Strings(found)
+
+	return found, nil
+}
+
+// CommitChanges is a commit and the paths it changed
+type CommitChanges struct {
+	Hash  string
+	Paths []string
+}
+
+// RecentChanges returns the paths changed by each of the last limit
+// non-merge commits on HEAD whose subject starts with subject, newest
+// first. subject must not contain regular expression characters.
+func (g *BuiltinGit) RecentChanges(subject string, limit int) ([]CommitChanges, error) {
+	if g.repo == nil {
+		return nil, fmt.Errorf("repository not initialized")
+	}
+
+	args := append([]string{"-c", "core.quotePath=false", "log", "--no-merges", fmt.Sprintf("-n%d", limit),
+		"--grep=^" + subject, "--format=%x00%H", "--name-only"}, g.relativeArgs()...)
+	out, err := runGitOutput(g.path, append(args, g.pathspec()...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+
+	var changes []CommitChanges
+	for _, block := range strings.Split(out, "\x00") {
+		lines := strings.Split(strings.TrimSpace(block), "\n")
+		if lines[0] == "" {
+			continue
+		}
+
+		commit := CommitChanges{Hash: lines[0]}
+		for _, line := range lines[1:] {
+			if line = strings.TrimSpace(line); line != "" {
+				commit.Paths = append(commit.Paths, line)
+			}
+		}
+		changes = append(changes, commit)
+	}
+
+	return changes, nil
+}
+
+// CommitRecord is a commit with its author, message and changed paths
+type CommitRecord struct {
+	Hash      string
+	Author    string
+	Email     string
+	Timestamp time.Time
+	Message   string
+	Changes   []FileChange
+}
+
+// History returns the non-merge commits on HEAD made between since and
+// until, oldest first, with the paths each changed. A zero since or until
+// leaves that end open.
+func (g *BuiltinGit) History(since, until time.Time) ([]CommitRecord, error) {
+	if g.repo == nil {
+		return nil, fmt.Errorf("repository not initialized")
+	}
+
+	args := []string{"-c", "core.quotePath=false", "log", "--no-merges", "--reverse", renameArg(),
+		"--format=%x00%H%x1f%an%x1f%ae%x1f%at%x1f%B%x1f", "--name-status"}
+	if !since.IsZero() {
+		args = append(args, "--since="+since.Format(time.RFC3339))
+	}
+	if !until.IsZero() {
+		args = append(args, "--until="+until.Format(time.RFC3339))
+	}
+	args = append(append(args, g.relativeArgs()...), g.pathspec()...)
+
+	out, err := runGitOutput(g.path, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+
+	var records []CommitRecord
+	for _, block := range strings.Split(out, "\x00") {
+		fields := strings.Split(block, "\x1f")
+		if len(fields) != 6 {
+			continue
+		}
+
+		seconds, err := strconv.ParseInt(fields[3], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse commit time %q: %w", fields[3], err)
+		}
+
+		records = append(records, CommitRecord{
+			Hash:      fields[0],
+			Author:    fields[1],
+			Email:     fields[2],
+			Timestamp: time.Unix(seconds, 0),
+			Message:   fields[4],
+			Changes:   parseNameStatus(fields[5]),
+		})
+	}
+
+	return records, nil
+}
+
+// Renames returns the files renamed between from and to, with their old
+// path in OldPath
+func (g *BuiltinGit) Renames(from, to string) ([]FileChange, error) {
+	if g.repo == nil {
+		return nil, fmt.Errorf("repository not initialized")
+	}
+
+	args := append([]string{"-c", "core.quotePath=false", "diff", "--name-status", renameArg(), "--diff-filter=R"}, g.relativeArgs()...)
+	out, err := runGitOutput(g.path, append(args, from, to)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare %s and %s: %w", from, to, err)
+	}
+
+	return parseNameStatus(out), nil
+}
+
+// ChangedFiles returns the files added, modified, deleted or renamed
+// between from and to
+func (g *BuiltinGit) ChangedFiles(from, to string) ([]string, error) {
+	if g.repo == nil {
+		return nil, fmt.Errorf("repository not initialized")
+	}
+
+	args := append([]string{"-c", "core.quotePath=false", "diff", "--name-only", renameArg()}, g.relativeArgs()...)
+	out, err := runGitOutput(g.path, append(args, from, to)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare %s and %s: %w", from, to, err)
+	}
+
+	return outputLines(out), nil
+}
+
+// WorktreeChanges returns the changes in the working tree since HEAD,
+// untracked files included, with renamed files paired up. The changes are
+// staged in a scratch index, so the repository's own is left alone.
+func (g *BuiltinGit) WorktreeChanges() ([]FileChange, error) {
+	if g.repo == nil {
+		return nil, fmt.Errorf("repository not initialized")
+	}
+
+	scratch, err := os.CreateTemp("", "opencode-sync-index-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scratch index: %w", err)
+	}
+	defer os.Remove(scratch.Name())
+
+	// Starting from the real index keeps unchanged files from being hashed
+	index, err := os.ReadFile(filepath.Join(g.GitDir(), "index"))
+	if err == nil {
+		_, err = scratch.Write(index)
+	} else if os.IsNotExist(err) {
+		err = nil
+	}
+	if closeErr := scratch.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scratch index: %w", err)
+	}
+
+	env := []string{"GIT_INDEX_FILE=" + scratch.Name()}
+	if _, err := runGitOutputEnv(g.path, env, append([]string{"add", "--all"}, g.pathspec()...)...); err != nil {
+		return nil, fmt.Errorf("failed to stage changes: %w", err)
+	}
+
+	args := append([]string{"-c", "core.quotePath=false", "diff", "--cached", "--name-status", renameArg()}, g.relativeArgs()...)
+	out, err := runGitOutputEnv(g.path, env, append(args, "HEAD")...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare with HEAD: %w", err)
+	}
+
+	return parseNameStatus(out), nil
+}
+
+// renameArg is the option making git pair renamed files that kept at
+// least RenameSimilarity percent of their content
+func renameArg() string {
+	return fmt.Sprintf("-M%d%%", RenameSimilarity)
+}
+
+// parseNameStatus parses the output of git's --name-status
+func parseNameStatus(out string) []FileChange {
+	var changes []FileChange
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Split(strings.TrimSpace(line), "\t")
+		if len(fields) < 2 || fields[0] == "" {
+			continue
+		}
+
+		change := FileChange{Path: unquotePath(fields[len(fields)-1]), Status: StatusModified}
+		switch fields[0][0] {
+		case 'A':
+			change.Status = StatusAdded
+		case 'D':
+			change.Status = StatusDeleted
+		case 'R':
+			change.Status = StatusRenamed
+		case 'C':
+			change.Status = StatusCopied
+		}
+		if len(fields) == 3 {
+			change.OldPath = unquotePath(fields[1])
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// unquotePath undoes the C-style quoting git applies to paths holding
+// tabs, quotes, backslashes or non-ASCII bytes
+func unquotePath(path string) string {
+	if !strings.HasPrefix(path, `"`) {
+		return path
+	}
+	if unquoted, err := strconv.Unquote(path); err == nil {
+		return unquoted
+	}
+	return path
+}
+
+// RemovePaths deletes paths from the working tree and stages the removal
+func (g *BuiltinGit) RemovePaths(paths []string) error {
+	if g.repo == nil {
+		return fmt.Errorf("repository not initialized")
+	}
+
+	args := []string{"rm", "--quiet", "--ignore-unmatch", "--"}
+	for _, path := range paths {
+		args = append(args, g.repoPath(path))
+	}
+	if err := runGitCommand(g.path, args...); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", strings.Join(paths, ", "), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,401 @@
+// Package hg keeps the sync repository's history in a Mercurial
+// repository, using the system hg client. It is a vcs.Backend: each git
+// commit becomes a changeset with the same files, on a named branch with
+// the git branch's name.
+package hg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/GareArc/opencode-sync/internal/capability"
+	"github.com/GareArc/opencode-sync/internal/git"
+	"github.com/GareArc/opencode-sync/internal/vcs"
+)
+
+func init() {
+	capability.Register(capability.Capability{
+		Name:        "mercurial",
+		Kind:        capability.KindBackend,
+		Description: "Sync to a Mercurial repository, one changeset per commit (hg+ssh://, hg+https:// and hg+file:// URLs)",
+	})
+}
+
+// Schemes are the URL schemes of Mercurial remotes. hg is given the URL
+// without the "hg+" prefix.
+var Schemes = []string{"hg+ssh", "hg+https", "hg+http", "hg+file"}
+
+// Client is the vcs.Backend of one Mercurial repository, reached through
+// a local clone of it
+type Client struct {
+	// remote names the repository in errors
+	remote string
+
+	url     string
+	clone   string
+	options []string
+}
+
+// New creates a Client for the repository at an hg+ssh://, hg+https://,
+// hg+http:// or hg+file:// URL, cloned into the directory clone. options
+// are passed on to ssh, such as "-o", "StrictHostKeyChecking=yes".
+func New(rawURL, clone string, options []string) (*Client, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Mercurial URL: %w", err)
+	}
+	scheme, ok := strings.CutPrefix(u.Scheme, "hg+")
+	if !ok || !isScheme(u.Scheme) {
+		return nil, fmt.Errorf("invalid Mercurial URL %s: must start with %s://", rawURL, strings.Join(Schemes, ":// or "))
+	}
+	if scheme != "file" && u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid Mercurial URL %s: no host", rawURL)
+	}
+
+	hgURL := *u
+	hgURL.Scheme = scheme
+	target := hgURL.String()
+	if scheme == "file" {
+		// hg takes local repositories as plain paths
+		target = filepath.FromSlash(u.Path)
+	}
+
+	remote := *u
+	remote.User = nil
+	if username := u.User.Username(); username != "" {
+		remote.User = url.User(username)
+	}
+
+	return &Client{
+		remote:  remote.String(),
+		url:     target,
+		clone:   clone,
+		options: options,
+	}, nil
+}
+
+// isScheme reports whether scheme is one of Schemes
+func isScheme(scheme string) bool {
+	for _, s := range Schemes {
+		if s == scheme {
+			return true
+		}
+	}
+	return false
+}
+
+// Remote returns the URL of the repository without secrets, for messages
+func (c *Client) Remote() string {
+	return c.remote
+}
+
+// run runs hg in the clone and returns its output
+func (c *Client) run(ctx context.Context, args ...string) (string, error) {
+	return c.runIn(ctx, c.clone, args...)
+}
+
+// runIn runs hg in dir and returns its output
+func (c *Client) runIn(ctx context.Context, dir string, args ...string) (string, error) {
+	global := []string{"--cwd", dir}
+	if git.NoTerminalPrompt {
+		global = append(global, "--noninteractive")
+	}
+	if len(c.options) > 0 {
+		global = append(global, "--config", "ui.ssh=ssh "+shellJoin(c.options))
+	}
+
+	cmd := exec.CommandContext(ctx, "hg", append(global, args...)...)
+	// HGPLAIN keeps the user's hgrc from changing output and behavior
+	cmd.Env = append(os.Environ(), "HGPLAIN=1", "HGENCODING=utf-8")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return "", fmt.Errorf("hg not found; install Mercurial: %w", err)
+		}
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = strings.TrimSpace(stdout.String())
+		}
+		return "", c.classify(msg, fmt.Errorf("hg %s: %s: %w", args[0], msg, err))
+	}
+	return stdout.String(), nil
+}
+
+// classify turns hg's failures to reach or log in to the remote into the
+// errors git remotes fail with
+func (c *Client) classify(msg string, failed error) error {
+	lower := strings.ToLower(msg)
+	switch {
+	case strings.Contains(lower, "http error 401"), strings.Contains(lower, "http error 403"),
+		strings.Contains(lower, "authorization failed"):
+		return &git.AuthError{Remote: c.remote, Err: failed}
+	case strings.Contains(lower, "no suitable response from remote hg"):
+		// ssh gave up before hg started; its own reason comes first
+		if classified := git.ClassifyRemoteError(c.remote, msg, failed); classified != nil {
+			return classified
+		}
+	}
+	return failed
+}
+
+// shellJoin quotes args for the shell hg runs ui.ssh with
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg != "" && !strings.ContainsAny(arg, " \t\n'\"\\$`;&|<>()*?[]{}~#%") {
+			quoted[i] = arg
+			continue
+		}
+		quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+	}
+	return strings.Join(quoted, " ")
+}
+
+// nullID is the ID hg gives the parent of a first changeset
+const nullID = "0000000000000000000000000000000000000000"
+
+// pendingFile marks a clone holding changesets that were not pushed
+const pendingFile = "opencode-sync-pending"
+
+// pending returns the path of the clone's pendingFile
+func (c *Client) pending() string {
+	return filepath.Join(c.clone, ".hg", pendingFile)
+}
+
+// Pull brings the clone to the remote's state, cloning it first if
+// needed. A clone holding changesets a failed push left is replaced.
+func (c *Client) Pull(ctx context.Context) error {
+	if _, err := os.Stat(c.pending()); err == nil {
+		if err := os.RemoveAll(c.clone); err != nil {
+			return fmt.Errorf("failed to remove Mercurial clone: %w", err)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(c.clone, ".hg")); err == nil {
+		_, err := c.run(ctx, "pull", "--", c.url)
+		return err
+	}
+
+	parent := filepath.Dir(c.clone)
+	if err := os.MkdirAll(parent, 0700); err != nil {
+		return fmt.Errorf("failed to create Mercurial clone: %w", err)
+	}
+	os.RemoveAll(c.clone)
+	if _, err := c.runIn(ctx, parent, "clone", "--noupdate", "--", c.url, c.clone); err != nil {
+		os.RemoveAll(c.clone)
+		return err
+	}
+	return nil
+}
+
+// logEntry is a changeset as hg log -T json writes it
+type logEntry struct {
+	Rev     int       `json:"rev"`
+	Node    string    `json:"node"`
+	Branch  string    `json:"branch"`
+	User    string    `json:"user"`
+	Date    []float64 `json:"date"`
+	Desc    string    `json:"desc"`
+	Parents []string  `json:"parents"`
+}
+
+// log returns the changesets of a revset, oldest first
+func (c *Client) log(ctx context.Context, revset string) ([]logEntry, error) {
+	out, err := c.run(ctx, "log", "--rev", revset, "--template", "json")
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []logEntry
+	if err := json.Unmarshal([]byte(out), &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse hg log: %w", err)
+	}
+	return entries, nil
+}
+
+// Branches returns the newest open head of each named branch
+func (c *Client) Branches(ctx context.Context) (map[string]string, error) {
+	entries, err := c.log(ctx, "sort(head() and not closed(), rev)")
+	if err != nil {
+		return nil, err
+	}
+
+	branches := map[string]string{}
+	for _, entry := range entries {
+		branches[entry.Branch] = entry.Node
+	}
+	return branches, nil
+}
+
+// Log returns the changesets leading to the open heads, parents before
+// their children
+func (c *Client) Log(ctx context.Context) ([]vcs.Changeset, error) {
+	entries, err := c.log(ctx, "sort(::(head() and not closed()), rev)")
+	if err != nil {
+		return nil, err
+	}
+
+	changesets := make([]vcs.Changeset, 0, len(entries))
+	for _, entry := range entries {
+		var parents []string
+		for _, parent := range entry.Parents {
+			if parent != nullID {
+				parents = append(parents, parent)
+			}
+		}
+
+		// hg's offset is in seconds west of UTC
+		var when time.Time
+		if len(entry.Date) == 2 {
+			when = time.Unix(int64(entry.Date[0]), 0).In(time.FixedZone("", -int(entry.Date[1])))
+		}
+
+		changesets = append(changesets, vcs.Changeset{
+			ID:      entry.Node,
+			Parents: parents,
+			Branch:  entry.Branch,
+			Author:  entry.User,
+			Time:    when,
+			Message: entry.Desc,
+		})
+	}
+	return changesets, nil
+}
+
+// Export writes the files of a changeset into dir
+func (c *Client) Export(ctx context.Context, id, dir string) error {
+	_, err := c.run(ctx, "archive", "--config", "ui.archivemeta=false", "--rev", id, "--type", "files", "--", dir)
+	if err != nil && strings.Contains(err.Error(), "no files match") {
+		// A changeset without files
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+		return nil
+	}
+	return err
+}
+
+// Commit records the files in dir as a changeset of the clone. The
+// clone is marked pending until Push sends it.
+func (c *Client) Commit(ctx context.Context, dir string, cs vcs.Changeset) (string, error) {
+	if err := os.WriteFile(c.pending(), nil, 0600); err != nil {
+		return "", fmt.Errorf("failed to mark Mercurial clone: %w", err)
+	}
+
+	p1 := "null"
+	if len(cs.Parents) > 0 {
+		p1 = cs.Parents[0]
+	}
+	if _, err := c.run(ctx, "update", "--clean", "--rev", p1); err != nil {
+		return "", err
+	}
+	if len(cs.Parents) > 1 {
+		if _, err := c.run(ctx, "debugsetparents", p1, cs.Parents[1]); err != nil {
+			return "", err
+		}
+	}
+	if _, err := c.run(ctx, "branch", "--force", "--", cs.Branch); err != nil {
+		return "", err
+	}
+
+	if err := replaceFiles(c.clone, dir); err != nil {
+		return "", err
+	}
+	if _, err := c.run(ctx, "addremove"); err != nil {
+		return "", err
+	}
+
+	message := cs.Message
+	if strings.TrimSpace(message) == "" {
+		message = "(no message)"
+	}
+	_, offset := cs.Time.Zone()
+	date := fmt.Sprintf("%d %d", cs.Time.Unix(), -offset)
+	if _, err := c.run(ctx, "commit", "--config", "ui.allowemptycommit=true",
+		"--user", cs.Author, "--date", date, "--message", message); err != nil {
+		return "", err
+	}
+
+	out, err := c.run(ctx, "log", "--rev", ".", "--template", "{node}")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// Push sends the clone's new changesets and branches to the remote. A
+// push that would create a second head on a branch means another machine
+// pushed first.
+func (c *Client) Push(ctx context.Context) error {
+	_, err := c.run(ctx, "push", "--new-branch", "--", c.url)
+	switch {
+	case err == nil, strings.Contains(err.Error(), "no changes found"):
+		os.Remove(c.pending())
+		return nil
+	case strings.Contains(err.Error(), "new remote head"), strings.Contains(err.Error(), "remote has heads"):
+		return fmt.Errorf("%w: %w", vcs.ErrRejected, err)
+	}
+	return err
+}
+
+// replaceFiles makes the working directory of clone hold the files in dir
+// and nothing else, leaving .hg alone
+func replaceFiles(clone, dir string) error {
+	entries, err := os.ReadDir(clone)
+	if err != nil {
+		return fmt.Errorf("failed to read Mercurial clone: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.Name() == ".hg" {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(clone, entry.Name())); err != nil {
+			return fmt.Errorf("failed to clear Mercurial clone: %w", err)
+		}
+	}
+
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		target := filepath.Join(clone, rel)
+
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, 0700)
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, info.Mode().Perm())
+	})
+}
@@ -0,0 +1,336 @@
+// Package integrity keeps a local, hash-chained log of sync operations.
+// Each entry records the commits an operation moved the sync repo
+// between and the hash of every file it left, and the hash of the entry
+// before it, so removing, changing or reordering entries, or rewriting
+// the repo history they describe, can be detected later.
+package integrity
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"time"
+
+	"github.com/GareArc/opencode-sync/internal/git"
+	"github.com/GareArc/opencode-sync/internal/paths"
+)
+
+// Operations recorded besides state.OpPush and state.OpPull
+const (
+	OpUndo = "undo"
+)
+
+// Entry is one operation in the log
+type Entry struct {
+	// Seq numbers entries from 1, without gaps
+	Seq int `json:"seq"`
+
+	// Time is when the operation finished
+	Time time.Time `json:"time"`
+
+	// Op is the operation, e.g. state.OpPush
+	Op string `json:"op"`
+
+	// Host is the machine that ran the operation
+	Host string `json:"host"`
+
+	// HeadBefore and HeadAfter are the sync repo HEAD before and after
+	// the operation. HeadBefore is empty for the first commit.
+	HeadBefore string `json:"headBefore,omitempty"`
+	HeadAfter  string `json:"headAfter"`
+
+	// Replaced is set when HeadAfter replaced HeadBefore rather than
+	// following it, as a squashed push or a forced undo does
+	Replaced bool `json:"replaced,omitempty"`
+
+	// Files maps the repo paths at HeadAfter to the SHA-256 of their
+	// content
+	Files map[string]string `json:"files"`
+
+	// Prev is the Hash of the entry before, empty for the first
+	Prev string `json:"prev"`
+
+	// Hash is the SHA-256 of the entry with an empty Hash
+	Hash string `json:"hash"`
+}
+
+// computeHash returns the hash the entry should have
+func (e *Entry) computeHash() string {
+	unhashed := *e
+	unhashed.Hash = ""
+	data, _ := json.Marshal(unhashed)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Load returns the entries of the log, oldest first. A missing log
+// yields none.
+func Load() ([]Entry, error) {
+	p, err := paths.Get()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get paths: %w", err)
+	}
+
+	data, err := os.ReadFile(p.IntegrityLogFile())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read integrity log: %w", err)
+	}
+
+	var entries []Entry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 64<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("failed to parse integrity log line %d: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read integrity log: %w", err)
+	}
+
+	return entries, nil
+}
+
+// Append chains entry to the last one in the log and appends it. Seq,
+// Prev and Hash are set here.
+func Append(entry *Entry) error {
+	entries, err := Load()
+	if err != nil {
+		return err
+	}
+
+	entry.Seq = 1
+	entry.Prev = ""
+	if len(entries) > 0 {
+		last := entries[len(entries)-1]
+		entry.Seq = last.Seq + 1
+		entry.Prev = last.Hash
+	}
+	entry.Hash = entry.computeHash()
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal integrity log entry: %w", err)
+	}
+
+	p, err := paths.Get()
+	if err != nil {
+		return fmt.Errorf("failed to get paths: %w", err)
+	}
+	if err := os.MkdirAll(p.DataDir, 0755); err != nil {
+		return fmt.Errorf("failed to create data dir: %w", err)
+	}
+
+	f, err := os.OpenFile(p.IntegrityLogFile(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open integrity log: %w", err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write integrity log: %w", err)
+	}
+	return f.Close()
+}
+
+// FileHashes returns the SHA-256 of every file committed at rev, by
+// slash-separated repo path. Submodules are left out.
+func FileHashes(repo *git.BuiltinGit, rev string) (map[string]string, error) {
+	hashes := map[string]string{}
+
+	var walk func(dir string) error
+	walk = func(dir string) error {
+		entries, err := repo.ListTreeAt(rev, dir)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			relPath := path.Join(dir, entry.Name)
+			switch {
+			case entry.Submodule:
+				continue
+			case entry.IsDir:
+				if err := walk(relPath); err != nil {
+					return err
+				}
+				continue
+			}
+
+			r, err := repo.OpenFileAt(rev, relPath)
+			if err != nil {
+				return err
+			}
+			h := sha256.New()
+			_, err = io.Copy(h, r)
+			r.Close()
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", relPath, err)
+			}
+			hashes[relPath] = hex.EncodeToString(h.Sum(nil))
+		}
+		return nil
+	}
+
+	if err := walk(""); err != nil {
+		return nil, fmt.Errorf("failed to hash files at %s: %w", rev, err)
+	}
+	return hashes, nil
+}
+
+// Kinds of problems Verify finds
+const (
+	// ProblemModified is an entry changed after it was written
+	ProblemModified = "modified"
+
+	// ProblemGap is entries missing from the log
+	ProblemGap = "gap"
+
+	// ProblemChain is an entry that does not follow the one before it
+	ProblemChain = "chain"
+
+	// ProblemRewrite is repo history changed since it was logged
+	ProblemRewrite = "rewrite"
+
+	// ProblemContent is a logged commit whose files no longer match
+	ProblemContent = "content"
+)
+
+// Problem is something Verify found wrong with the log or the repo
+type Problem struct {
+	// Seq is the entry the problem was found at
+	Seq int `json:"seq"`
+
+	// Kind is one of the Problem values
+	Kind string `json:"kind"`
+
+	Reason string `json:"reason"`
+}
+
+// Verify checks that entries form an unbroken chain from the first entry
+// ever written, and that the sync repo still holds the history they
+// describe: each logged commit with the files logged for it, each
+// operation starting from where the one before left the repo or a later
+// commit, and HEAD at or after the last logged commit.
+func Verify(repo *git.BuiltinGit, entries []Entry) []Problem {
+	var problems []Problem
+	report := func(seq int, kind, reason string) {
+		problems = append(problems, Problem{Seq: seq, Kind: kind, Reason: reason})
+	}
+
+	// Commits replaced on purpose may be gone from the repo
+	replaced := map[string]bool{}
+	for _, entry := range entries {
+		if entry.Replaced && entry.HeadBefore != "" {
+			replaced[entry.HeadBefore] = true
+		}
+	}
+
+	exists := func(rev string) bool {
+		_, err := repo.RevParse(rev)
+		return err == nil
+	}
+
+	for i, entry := range entries {
+		if entry.Hash != entry.computeHash() {
+			report(entry.Seq, ProblemModified, "the entry was changed after it was written")
+		}
+
+		expected := 1
+		if i > 0 {
+			expected = entries[i-1].Seq + 1
+		}
+		switch {
+		case entry.Seq == expected+1:
+			report(entry.Seq, ProblemGap, fmt.Sprintf("entry %d is missing", expected))
+		case entry.Seq > expected:
+			report(entry.Seq, ProblemGap, fmt.Sprintf("entries %d to %d are missing", expected, entry.Seq-1))
+		case entry.Seq < expected:
+			report(entry.Seq, ProblemChain, fmt.Sprintf("the entry is out of order, after entry %d", expected-1))
+		}
+
+		prev := ""
+		if i > 0 {
+			prev = entries[i-1].Hash
+		}
+		if entry.Prev != prev && entry.Seq == expected {
+			report(entry.Seq, ProblemChain, "the entry does not follow the one before it")
+		}
+
+		if entry.HeadAfter == "" {
+			continue
+		}
+		if !exists(entry.HeadAfter) {
+			if !replaced[entry.HeadAfter] {
+				report(entry.Seq, ProblemRewrite, fmt.Sprintf("commit %s is no longer in the sync repo", short(entry.HeadAfter)))
+			}
+		} else if files, err := FileHashes(repo, entry.HeadAfter); err != nil {
+			report(entry.Seq, ProblemContent, err.Error())
+		} else if reason := compareFiles(entry.Files, files); reason != "" {
+			report(entry.Seq, ProblemContent, fmt.Sprintf("commit %s %s", short(entry.HeadAfter), reason))
+		}
+
+		// The repo may have moved on between operations, but only
+		// forward
+		if i == 0 || entry.HeadBefore == "" {
+			continue
+		}
+		last := entries[i-1].HeadAfter
+		if last == "" || last == entry.HeadBefore || !exists(last) || !exists(entry.HeadBefore) {
+			continue
+		}
+		if ancestor, err := repo.IsAncestor(last, entry.HeadBefore); err == nil && !ancestor {
+			report(entry.Seq, ProblemRewrite, fmt.Sprintf("the operation started from %s, which does not follow %s where the last one left the repo", short(entry.HeadBefore), short(last)))
+		}
+	}
+
+	if len(entries) > 0 {
+		last := entries[len(entries)-1]
+		if head, err := repo.GetHead(); err == nil && last.HeadAfter != "" && exists(last.HeadAfter) {
+			if ancestor, err := repo.IsAncestor(last.HeadAfter, head); err == nil && !ancestor {
+				report(last.Seq, ProblemRewrite, fmt.Sprintf("HEAD %s does not follow %s, the last logged commit", short(head), short(last.HeadAfter)))
+			}
+		}
+	}
+
+	return problems
+}
+
+// compareFiles returns how the files of a commit differ from those
+// logged for it, or ""
+func compareFiles(logged, actual map[string]string) string {
+	for relPath, hash := range logged {
+		switch actualHash, ok := actual[relPath]; {
+		case !ok:
+			return fmt.Sprintf("no longer has %s", relPath)
+		case actualHash != hash:
+			return fmt.Sprintf("has different content in %s", relPath)
+		}
+	}
+	for relPath := range actual {
+		if _, ok := logged[relPath]; !ok {
+			return fmt.Sprintf("has %s, which was not logged", relPath)
+		}
+	}
+	return ""
+}
+
+// short abbreviates a commit hash
+func short(commit string) string {
+	if len(commit) > 7 {
+		return commit[:7]
+	}
+	return commit
+}
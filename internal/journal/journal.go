@@ -0,0 +1,185 @@
+// Package journal records multi-step operations while they run, so one
+// interrupted by a crash, a kill or a power loss can be completed or
+// rolled back by a later run.
+package journal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/GareArc/opencode-sync/internal/paths"
+)
+
+// Journaled operations
+const (
+	OpInit  = "init"
+	OpLink  = "link"
+	OpClone = "clone"
+	OpPull  = "pull"
+)
+
+// Steps recorded as operations make progress
+const (
+	// StepCloned is recorded once clone has a complete repository
+	StepCloned = "cloned"
+
+	// StepConfigCreated is recorded when clone wrote a new config file
+	StepConfigCreated = "config-created"
+
+	// StepConfigSaved is recorded when link stored the new remote URL
+	StepConfigSaved = "config-saved"
+
+	// StepCommitted is recorded once init or link made its first commit
+	StepCommitted = "committed"
+
+	// StepBackedUp is recorded once a pull backed up local files
+	StepBackedUp = "backed-up"
+)
+
+// Entry is an operation in progress
+type Entry struct {
+	// Op is one of the Op constants
+	Op string `json:"op"`
+
+	// Started is when the operation began
+	Started time.Time `json:"started"`
+
+	// PID is the process running the operation
+	PID int `json:"pid"`
+
+	// URL is the remote being cloned or linked
+	URL string `json:"url,omitempty"`
+
+	// Template is the template repository of an init
+	Template string `json:"template,omitempty"`
+
+	// Layout is the repo.layout an init, link or clone creates the sync
+	// repo with
+	Layout string `json:"layout,omitempty"`
+
+	// Subdir is the repo.subdir an init, link or clone keeps the synced
+	// files in
+	Subdir string `json:"subdir,omitempty"`
+
+	// PreviousURL is repo.url before link replaced it
+	PreviousURL string `json:"previousUrl,omitempty"`
+
+	// HeadBefore is the sync repo HEAD before a pull
+	HeadBefore string `json:"headBefore,omitempty"`
+
+	// Command is the command that ran a pull, such as "pull" or "sync"
+	Command string `json:"command,omitempty"`
+
+	// BackupDir is the backup of local files taken by a pull
+	BackupDir string `json:"backupDir,omitempty"`
+
+	// Steps are the steps completed so far, in order
+	Steps []string `json:"steps,omitempty"`
+}
+
+// Begin records e as the operation in progress, replacing any other
+func Begin(e *Entry) error {
+	e.Started = time.Now()
+	e.PID = os.Getpid()
+	e.Steps = nil
+	return e.Save()
+}
+
+// Step records that step was completed
+func (e *Entry) Step(step string) error {
+	e.Steps = append(e.Steps, step)
+	return e.Save()
+}
+
+// Done reports whether step was completed
+func (e *Entry) Done(step string) bool {
+	return slices.Contains(e.Steps, step)
+}
+
+// Save writes the entry. It replaces the journal file in one rename, so a
+// crash leaves either the old or the new entry behind.
+func (e *Entry) Save() error {
+	p, err := paths.Get()
+	if err != nil {
+		return fmt.Errorf("failed to get paths: %w", err)
+	}
+
+	if err := os.MkdirAll(p.DataDir, 0755); err != nil {
+		return fmt.Errorf("failed to create data dir: %w", err)
+	}
+
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal journal: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(p.DataDir, filepath.Base(p.JournalFile())+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), p.JournalFile()); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+
+	return nil
+}
+
+// Finish removes the journal once the operation completed or was undone
+func (e *Entry) Finish() error {
+	return Remove()
+}
+
+// Load returns the journaled operation, or nil if there is none
+func Load() (*Entry, error) {
+	p, err := paths.Get()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get paths: %w", err)
+	}
+
+	data, err := os.ReadFile(p.JournalFile())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+
+	var e Entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, fmt.Errorf("failed to parse journal: %w", err)
+	}
+
+	return &e, nil
+}
+
+// Remove deletes the journal
+func Remove() error {
+	p, err := paths.Get()
+	if err != nil {
+		return fmt.Errorf("failed to get paths: %w", err)
+	}
+
+	if err := os.Remove(p.JournalFile()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove journal: %w", err)
+	}
+
+	return nil
+}
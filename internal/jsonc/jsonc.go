@@ -0,0 +1,424 @@
+// Package jsonc parses JSON with comments and trailing commas (as used by
+// opencode.jsonc) into a tree that keeps the comments and trailing commas,
+// and writes it back.
+package jsonc
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Kind is the type of a JSON value
+type Kind int
+
+// Value kinds
+const (
+	Null Kind = iota
+	Bool
+	Number
+	String
+	Array
+	Object
+)
+
+// Node is a JSON value
+type Node struct {
+	Kind Kind
+
+	// Literal is the source text of a scalar, e.g. `"a\n"` or `1e3`
+	Literal string
+
+	// Elements are the members of an object or the items of an array
+	Elements []*Element
+
+	// Dangling holds comments after the last element, before the closing
+	// bracket
+	Dangling []string
+
+	// TrailingComma is set when the last element is followed by a comma
+	TrailingComma bool
+}
+
+// Element is an object member or array item with its comments
+type Element struct {
+	// Key is the quoted key literal of an object member, "" in arrays
+	Key string
+
+	Value *Node
+
+	// Comments are the comments on the lines before the element
+	Comments []string
+
+	// LineComment is a comment on the same line after the element
+	LineComment string
+}
+
+// Document is a parsed file
+type Document struct {
+	// Comments are the comments before the value
+	Comments []string
+
+	Value *Node
+
+	// Trailing are the comments after the value
+	Trailing []string
+}
+
+// KeyName returns the unquoted key of an object member
+func (e *Element) KeyName() string {
+	var name string
+	if err := json.Unmarshal([]byte(e.Key), &name); err != nil {
+		return e.Key
+	}
+	return name
+}
+
+// SyntaxError is returned for malformed input
+type SyntaxError struct {
+	Line int
+	Msg  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// Parse parses JSON or JSONC
+func Parse(data []byte) (*Document, error) {
+	p := &parser{data: data, line: 1}
+
+	doc := &Document{}
+	doc.Comments = p.comments()
+
+	value, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	doc.Value = value
+
+	if lc := p.lineComment(); lc != "" {
+		doc.Trailing = append(doc.Trailing, lc)
+	}
+	doc.Trailing = append(doc.Trailing, p.comments()...)
+
+	if p.pos < len(p.data) {
+		return nil, p.errorf("unexpected %q after value", p.data[p.pos])
+	}
+
+	return doc, nil
+}
+
+// parser reads a document
+type parser struct {
+	data []byte
+	pos  int
+	line int
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return &SyntaxError{Line: p.line, Msg: fmt.Sprintf(format, args...)}
+}
+
+// skipSpace skips whitespace, reporting whether a newline was crossed
+func (p *parser) skipSpace() bool {
+	newline := false
+	for p.pos < len(p.data) {
+		switch p.data[p.pos] {
+		case '\n':
+			p.line++
+			newline = true
+		case ' ', '\t', '\r':
+		default:
+			return newline
+		}
+		p.pos++
+	}
+	return newline
+}
+
+// comment reads one comment at the current position, or returns ""
+func (p *parser) comment() (string, error) {
+	if p.pos+1 >= len(p.data) || p.data[p.pos] != '/' {
+		return "", nil
+	}
+
+	start := p.pos
+	switch p.data[p.pos+1] {
+	case '/':
+		end := p.pos
+		for end < len(p.data) && p.data[end] != '\n' {
+			end++
+		}
+		p.pos = end
+		return strings.TrimRight(string(p.data[start:end]), " \t\r"), nil
+	case '*':
+		end := strings.Index(string(p.data[p.pos+2:]), "*/")
+		if end < 0 {
+			return "", p.errorf("unterminated comment")
+		}
+		text := string(p.data[start : p.pos+2+end+2])
+		p.line += strings.Count(text, "\n")
+		p.pos += 2 + end + 2
+		return text, nil
+	}
+
+	return "", nil
+}
+
+// comments reads the comments and whitespace before the next token
+func (p *parser) comments() []string {
+	var found []string
+	for {
+		p.skipSpace()
+		c, err := p.comment()
+		if err != nil || c == "" {
+			return found
+		}
+		found = append(found, c)
+	}
+}
+
+// lineComment reads a comment that follows on the same line, or returns ""
+func (p *parser) lineComment() string {
+	save, saveLine := p.pos, p.line
+	if p.skipSpace() {
+		p.pos, p.line = save, saveLine
+		return ""
+	}
+
+	c, err := p.comment()
+	if err != nil || c == "" {
+		p.pos, p.line = save, saveLine
+		return ""
+	}
+	return c
+}
+
+// value reads one value
+func (p *parser) value() (*Node, error) {
+	p.skipSpace()
+	if p.pos >= len(p.data) {
+		return nil, p.errorf("unexpected end of input")
+	}
+
+	switch c := p.data[p.pos]; {
+	case c == '{':
+		return p.container(Object, '}')
+	case c == '[':
+		return p.container(Array, ']')
+	case c == '"':
+		literal, err := p.stringLiteral()
+		if err != nil {
+			return nil, err
+		}
+		return &Node{Kind: String, Literal: literal}, nil
+	case c == '-' || (c >= '0' && c <= '9'):
+		start := p.pos
+		for p.pos < len(p.data) && strings.IndexByte("+-.0123456789eE", p.data[p.pos]) >= 0 {
+			p.pos++
+		}
+		literal := string(p.data[start:p.pos])
+		if !json.Valid([]byte(literal)) {
+			return nil, p.errorf("invalid number %q", literal)
+		}
+		return &Node{Kind: Number, Literal: literal}, nil
+	}
+
+	for _, word := range []struct {
+		text string
+		kind Kind
+	}{{"true", Bool}, {"false", Bool}, {"null", Null}} {
+		if strings.HasPrefix(string(p.data[p.pos:]), word.text) {
+			p.pos += len(word.text)
+			return &Node{Kind: word.kind, Literal: word.text}, nil
+		}
+	}
+
+	return nil, p.errorf("unexpected %q", p.data[p.pos])
+}
+
+// stringLiteral reads a quoted string and returns it as written
+func (p *parser) stringLiteral() (string, error) {
+	start := p.pos
+	p.pos++
+	for p.pos < len(p.data) {
+		switch p.data[p.pos] {
+		case '\\':
+			p.pos += 2
+			continue
+		case '\n':
+			return "", p.errorf("newline in string")
+		case '"':
+			p.pos++
+			literal := string(p.data[start:p.pos])
+			if !json.Valid([]byte(literal)) {
+				return "", p.errorf("invalid string %s", literal)
+			}
+			return literal, nil
+		}
+		p.pos++
+	}
+	return "", p.errorf("unterminated string")
+}
+
+// container reads an object or array, allowing a trailing comma
+func (p *parser) container(kind Kind, closing byte) (*Node, error) {
+	node := &Node{Kind: kind}
+	p.pos++
+
+	// carried are comments found after an element's comma position that
+	// belong before the next element
+	var carried []string
+	for {
+		comments := append(carried, p.comments()...)
+		carried = nil
+		if p.pos >= len(p.data) {
+			return nil, p.errorf("unexpected end of input")
+		}
+		if p.data[p.pos] == closing {
+			node.Dangling = comments
+			node.TrailingComma = len(node.Elements) > 0
+			p.pos++
+			return node, nil
+		}
+
+		elem := &Element{Comments: comments}
+		if kind == Object {
+			if p.data[p.pos] != '"' {
+				return nil, p.errorf("expected key, found %q", p.data[p.pos])
+			}
+			key, err := p.stringLiteral()
+			if err != nil {
+				return nil, err
+			}
+			elem.Key = key
+
+			// Comments between a key and its value are kept before the member
+			elem.Comments = append(elem.Comments, p.comments()...)
+			if p.pos >= len(p.data) || p.data[p.pos] != ':' {
+				return nil, p.errorf("expected ':' after %s", key)
+			}
+			p.pos++
+			elem.Comments = append(elem.Comments, p.comments()...)
+		}
+
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		elem.Value = value
+		node.Elements = append(node.Elements, elem)
+
+		elem.LineComment = p.lineComment()
+		between := p.comments()
+		if p.pos >= len(p.data) {
+			return nil, p.errorf("unexpected end of input")
+		}
+
+		switch p.data[p.pos] {
+		case ',':
+			p.pos++
+			if elem.LineComment == "" {
+				elem.LineComment = p.lineComment()
+			}
+			// Comments between the value and its comma belong to it
+			if elem.LineComment == "" && len(between) > 0 {
+				elem.LineComment, between = between[0], between[1:]
+			}
+			carried = between
+		case closing:
+			node.Dangling = between
+			p.pos++
+			return node, nil
+		default:
+			return nil, p.errorf("expected ',' or %q, found %q", closing, p.data[p.pos])
+		}
+	}
+}
+
+// SortKeys sorts the members of every object in the tree by key. Comments
+// move with their members.
+func (n *Node) SortKeys() {
+	if n.Kind == Object {
+		sort.SliceStable(n.Elements, func(i, j int) bool {
+			return n.Elements[i].KeyName() < n.Elements[j].KeyName()
+		})
+	}
+	for _, elem := range n.Elements {
+		elem.Value.SortKeys()
+	}
+}
+
+// Format writes doc with two-space indentation, one member or item per
+// line and a trailing newline, keeping comments and trailing commas.
+// Scalars are written as they appeared in the input.
+func Format(doc *Document) []byte {
+	var b strings.Builder
+	for _, c := range doc.Comments {
+		b.WriteString(c)
+		b.WriteByte('\n')
+	}
+	writeNode(&b, doc.Value, "")
+	for i, c := range doc.Trailing {
+		if i == 0 && !strings.Contains(c, "\n") && strings.HasPrefix(c, "//") {
+			b.WriteByte(' ')
+		} else {
+			b.WriteByte('\n')
+		}
+		b.WriteString(c)
+	}
+	b.WriteByte('\n')
+	return []byte(b.String())
+}
+
+// writeNode writes a value at the given indentation
+func writeNode(b *strings.Builder, n *Node, indent string) {
+	if n.Kind != Object && n.Kind != Array {
+		b.WriteString(n.Literal)
+		return
+	}
+
+	open, closing := "[", "]"
+	if n.Kind == Object {
+		open, closing = "{", "}"
+	}
+
+	b.WriteString(open)
+	if len(n.Elements) == 0 && len(n.Dangling) == 0 {
+		b.WriteString(closing)
+		return
+	}
+
+	inner := indent + "  "
+	for i, elem := range n.Elements {
+		b.WriteByte('\n')
+		for _, c := range elem.Comments {
+			b.WriteString(inner)
+			b.WriteString(c)
+			b.WriteByte('\n')
+		}
+		b.WriteString(inner)
+		if n.Kind == Object {
+			b.WriteString(elem.Key)
+			b.WriteString(": ")
+		}
+		writeNode(b, elem.Value, inner)
+		if i < len(n.Elements)-1 || n.TrailingComma {
+			b.WriteByte(',')
+		}
+		if elem.LineComment != "" {
+			b.WriteByte(' ')
+			b.WriteString(elem.LineComment)
+		}
+	}
+	for _, c := range n.Dangling {
+		b.WriteByte('\n')
+		b.WriteString(inner)
+		b.WriteString(c)
+	}
+	b.WriteByte('\n')
+	b.WriteString(indent)
+	b.WriteString(closing)
+}
@@ -0,0 +1,181 @@
+package jsonc
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestFormatKeepsComments(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "plain json",
+			in:   `{"a": 1, "b": [true, null]}`,
+			want: "{\n  \"a\": 1,\n  \"b\": [\n    true,\n    null\n  ]\n}\n",
+		},
+		{
+			name: "comments before members",
+			in:   "{\n  // the model\n  \"model\": \"x\"\n}",
+			want: "{\n  // the model\n  \"model\": \"x\"\n}\n",
+		},
+		{
+			name: "line comment after member",
+			in:   "{\n  \"a\": 1, // one\n  \"b\": 2\n}",
+			want: "{\n  \"a\": 1, // one\n  \"b\": 2\n}\n",
+		},
+		{
+			name: "trailing comma",
+			in:   `{"a": [1, 2,],}`,
+			want: "{\n  \"a\": [\n    1,\n    2,\n  ],\n}\n",
+		},
+		{
+			name: "dangling comment",
+			in:   "{\n  \"a\": 1\n  // end\n}",
+			want: "{\n  \"a\": 1\n  // end\n}\n",
+		},
+		{
+			name: "document comments",
+			in:   "/* head */\n{} // tail",
+			want: "/* head */\n{} // tail\n",
+		},
+		{
+			name: "scalars as written",
+			in:   `{"n": 1e3, "s": "a\u00e9"}`,
+			want: "{\n  \"n\": 1e3,\n  \"s\": \"a\\u00e9\"\n}\n",
+		},
+		{
+			name: "empty containers",
+			in:   `{"o": {}, "a": []}`,
+			want: "{\n  \"o\": {},\n  \"a\": []\n}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := Parse([]byte(tt.in))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if got := string(Format(doc)); got != tt.want {
+				t.Errorf("Format() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       string
+		wantLine int
+	}{
+		{name: "empty", in: "", wantLine: 1},
+		{name: "unterminated object", in: "{\n  \"a\": 1", wantLine: 2},
+		{name: "missing colon", in: "{\n  \"a\" 1\n}", wantLine: 2},
+		{name: "unquoted key", in: "{\n\n  a: 1\n}", wantLine: 3},
+		{name: "unterminated comment", in: "/* never closed\n{}", wantLine: 1},
+		{name: "newline in string", in: "{\"a\": \"x\ny\"}", wantLine: 1},
+		{name: "invalid number", in: "[1.2.3]", wantLine: 1},
+		{name: "text after value", in: "{}\n\nx", wantLine: 3},
+		{name: "missing comma", in: "[1\n 2]", wantLine: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.in))
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("Parse() error = %v, want a SyntaxError", err)
+			}
+			if syntaxErr.Line != tt.wantLine {
+				t.Errorf("Parse() error on line %d, want line %d (%v)", syntaxErr.Line, tt.wantLine, err)
+			}
+		})
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want any
+	}{
+		{name: "null", in: "null", want: nil},
+		{name: "number keeps its text", in: "1.50", want: json.Number("1.50")},
+		{name: "escaped string", in: `"a\"b"`, want: `a"b`},
+		{
+			name: "comments and trailing commas dropped",
+			in:   "{\n  // c\n  \"a\": [1, \"x\", false,], /* d */\n}",
+			want: map[string]any{"a": []any{json.Number("1"), "x", false}},
+		},
+		{
+			name: "repeated key takes the last value",
+			in:   `{"a": 1, "a": 2}`,
+			want: map[string]any{"a": json.Number("2")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Unmarshal([]byte(tt.in))
+			if err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Unmarshal() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	tests := []struct {
+		name  string
+		in    string
+		value any
+		want  string
+	}{
+		{
+			name:  "changed member keeps its comment",
+			in:    "{\n  // keep\n  \"a\": 1,\n  \"b\": 2\n}",
+			value: map[string]any{"a": json.Number("3"), "b": json.Number("2")},
+			want:  "{\n  // keep\n  \"a\": 3,\n  \"b\": 2\n}\n",
+		},
+		{
+			name:  "removed member takes its comment",
+			in:    "{\n  // gone\n  \"a\": 1,\n  \"b\": 2\n}",
+			value: map[string]any{"b": json.Number("2")},
+			want:  "{\n  \"b\": 2\n}\n",
+		},
+		{
+			name:  "new members appended in key order",
+			in:    "{\n  \"z\": 1\n}",
+			value: map[string]any{"z": json.Number("1"), "b": true, "a": "x"},
+			want:  "{\n  \"z\": 1,\n  \"a\": \"x\",\n  \"b\": true\n}\n",
+		},
+		{
+			name:  "array item keeps its comment",
+			in:    "[\n  // first\n  \"a\",\n  \"b\"\n]",
+			value: []any{"c", "a"},
+			want:  "[\n  \"c\",\n  // first\n  \"a\"\n]\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := Parse([]byte(tt.in))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			doc.Value.Update(tt.value)
+			if got := string(Format(doc)); got != tt.want {
+				t.Errorf("Format() after Update() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package jsonc
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Interface returns the value as plain Go values, as decoded by
+// encoding/json with UseNumber: map[string]any, []any, string,
+// json.Number, bool or nil. Comments are dropped. A key repeated in an
+// object takes its last value.
+func (n *Node) Interface() any {
+	switch n.Kind {
+	case Object:
+		obj := make(map[string]any, len(n.Elements))
+		for _, elem := range n.Elements {
+			obj[elem.KeyName()] = elem.Value.Interface()
+		}
+		return obj
+	case Array:
+		arr := make([]any, 0, len(n.Elements))
+		for _, elem := range n.Elements {
+			arr = append(arr, elem.Value.Interface())
+		}
+		return arr
+	case String:
+		var s string
+		_ = json.Unmarshal([]byte(n.Literal), &s)
+		return s
+	case Number:
+		return json.Number(n.Literal)
+	case Bool:
+		return n.Literal == "true"
+	}
+	return nil
+}
+
+// Unmarshal parses JSON or JSONC into plain Go values, as Interface
+func Unmarshal(data []byte) (any, error) {
+	doc, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return doc.Value.Interface(), nil
+}
+
+// NewNode builds a tree from plain Go values. Object keys are sorted.
+func NewNode(v any) *Node {
+	switch v := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		n := &Node{Kind: Object}
+		for _, key := range keys {
+			n.Elements = append(n.Elements, &Element{Key: literal(key), Value: NewNode(v[key])})
+		}
+		return n
+	case []any:
+		n := &Node{Kind: Array}
+		for _, item := range v {
+			n.Elements = append(n.Elements, &Element{Value: NewNode(item)})
+		}
+		return n
+	case string:
+		return &Node{Kind: String, Literal: literal(v)}
+	case bool:
+		return &Node{Kind: Bool, Literal: literal(v)}
+	case nil:
+		return &Node{Kind: Null, Literal: "null"}
+	}
+
+	// Numbers, and anything else encoding/json can write
+	lit := literal(v)
+	var probe any
+	if err := json.Unmarshal([]byte(lit), &probe); err == nil {
+		if _, ok := probe.(float64); ok {
+			return &Node{Kind: Number, Literal: lit}
+		}
+	}
+	return &Node{Kind: String, Literal: literal(lit)}
+}
+
+// literal encodes v as JSON without escaping <, > and &
+func literal(v any) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return "null"
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// Update changes the tree to hold v, given as plain Go values, while
+// keeping the comments and order of what did not change: object members
+// whose keys remain keep their place and comments, removed members go
+// with theirs, and new members are appended in key order. Array items
+// equal to an old item keep that item's comments.
+func (n *Node) Update(v any) {
+	switch v := v.(type) {
+	case map[string]any:
+		if n.Kind != Object {
+			*n = *NewNode(v)
+			return
+		}
+
+		kept := make([]*Element, 0, len(v))
+		seen := map[string]bool{}
+		for _, elem := range n.Elements {
+			key := elem.KeyName()
+			value, ok := v[key]
+			if !ok || seen[key] {
+				continue
+			}
+			seen[key] = true
+			elem.Value.Update(value)
+			kept = append(kept, elem)
+		}
+
+		added := NewNode(v)
+		for _, elem := range added.Elements {
+			if !seen[elem.KeyName()] {
+				kept = append(kept, elem)
+			}
+		}
+		n.Elements = kept
+	case []any:
+		if n.Kind != Array {
+			*n = *NewNode(v)
+			return
+		}
+
+		old := n.Elements
+		used := make([]bool, len(old))
+		items := make([]*Element, 0, len(v))
+		for _, item := range v {
+			var elem *Element
+			for i, o := range old {
+				if !used[i] && equal(o.Value.Interface(), item) {
+					used[i] = true
+					elem = o
+					break
+				}
+			}
+			if elem == nil {
+				elem = &Element{Value: NewNode(item)}
+			}
+			items = append(items, elem)
+		}
+		n.Elements = items
+	default:
+		if n.Kind == Object || n.Kind == Array || !equal(n.Interface(), v) {
+			*n = *NewNode(v)
+		}
+	}
+}
+
+// equal compares plain values, treating numbers by their JSON encoding so
+// json.Number("1") equals float64(1)
+func equal(a, b any) bool {
+	if reflect.DeepEqual(a, b) {
+		return true
+	}
+	return literal(normalize(a)) == literal(normalize(b))
+}
+
+// normalize decodes a value's JSON encoding back with float64 numbers
+func normalize(v any) any {
+	var out any
+	if err := json.Unmarshal([]byte(literal(v)), &out); err != nil {
+		return v
+	}
+	return out
+}
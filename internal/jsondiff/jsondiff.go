@@ -0,0 +1,216 @@
+// Package jsondiff compares two JSON documents structurally: objects key
+// by key and lists of plain values element by element, so a change reads
+// as the setting that changed rather than the lines that moved.
+package jsondiff
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Kinds of change
+const (
+	Added   = "added"
+	Removed = "removed"
+	Changed = "changed"
+)
+
+// maxValueLength is how many characters of a value FormatValue shows
+const maxValueLength = 60
+
+// Change is one difference between two JSON documents
+type Change struct {
+	// Path is the keys leading to the changed value from the root. An
+	// element added to or removed from a list has the list's path.
+	Path []string
+
+	// Kind is Added, Removed or Changed
+	Kind string
+
+	// Old and New are the values before and after; Old is nil for an
+	// added value and New for a removed one
+	Old, New any
+}
+
+// Diff returns the changes that turn before into after, in key order.
+// Values are as decoded by encoding/json or jsonc.Unmarshal: objects
+// are map[string]any and lists []any. Lists of strings, numbers and
+// booleans report the elements added and removed; other lists, and lists
+// that were only reordered, change as a whole.
+func Diff(before, after any) []Change {
+	var changes []Change
+	diff(nil, before, after, &changes)
+	return changes
+}
+
+func diff(path []string, before, after any, changes *[]Change) {
+	if equal(before, after) {
+		return
+	}
+
+	beforeObj, beforeIsObj := before.(map[string]any)
+	afterObj, afterIsObj := after.(map[string]any)
+	if beforeIsObj && afterIsObj {
+		for _, key := range keys(beforeObj, afterObj) {
+			child := append(path[:len(path):len(path)], key)
+			oldValue, inBefore := beforeObj[key]
+			newValue, inAfter := afterObj[key]
+			switch {
+			case !inBefore:
+				*changes = append(*changes, Change{Path: child, Kind: Added, New: newValue})
+			case !inAfter:
+				*changes = append(*changes, Change{Path: child, Kind: Removed, Old: oldValue})
+			default:
+				diff(child, oldValue, newValue, changes)
+			}
+		}
+		return
+	}
+
+	beforeList, beforeIsList := before.([]any)
+	afterList, afterIsList := after.([]any)
+	if beforeIsList && afterIsList && plain(beforeList) && plain(afterList) {
+		var elements []Change
+		for _, value := range beforeList {
+			if !contains(afterList, value) {
+				elements = append(elements, Change{Path: path, Kind: Removed, Old: value})
+			}
+		}
+		for _, value := range afterList {
+			if !contains(beforeList, value) {
+				elements = append(elements, Change{Path: path, Kind: Added, New: value})
+			}
+		}
+		if len(elements) > 0 {
+			*changes = append(*changes, elements...)
+			return
+		}
+	}
+
+	*changes = append(*changes, Change{Path: path, Kind: Changed, Old: before, New: after})
+}
+
+// equal reports whether two values are the same JSON, comparing numbers
+// by value so 1 and 1.0 are equal
+func equal(a, b any) bool {
+	if an, ok := a.(json.Number); ok {
+		if bn, ok := b.(json.Number); ok {
+			af, aErr := an.Float64()
+			bf, bErr := bn.Float64()
+			if aErr == nil && bErr == nil {
+				return af == bf
+			}
+		}
+	}
+
+	aObj, aIsObj := a.(map[string]any)
+	bObj, bIsObj := b.(map[string]any)
+	if aIsObj && bIsObj {
+		if len(aObj) != len(bObj) {
+			return false
+		}
+		for key, value := range aObj {
+			other, ok := bObj[key]
+			if !ok || !equal(value, other) {
+				return false
+			}
+		}
+		return true
+	}
+
+	aList, aIsList := a.([]any)
+	bList, bIsList := b.([]any)
+	if aIsList && bIsList {
+		if len(aList) != len(bList) {
+			return false
+		}
+		for i := range aList {
+			if !equal(aList[i], bList[i]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+// plain reports whether a list holds only strings, numbers and booleans
+func plain(list []any) bool {
+	for _, value := range list {
+		switch value.(type) {
+		case string, json.Number, float64, bool:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// contains reports whether a list holds a value
+func contains(list []any, value any) bool {
+	for _, v := range list {
+		if equal(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// keys returns the keys of both objects, sorted
+func keys(a, b map[string]any) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	for key := range a {
+		seen[key] = true
+	}
+	for key := range b {
+		seen[key] = true
+	}
+
+	sorted := make([]string, 0, len(seen))
+	for key := range seen {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// FormatPath writes a path as dotted keys, e.g. provider.anthropic.model.
+// Keys that would read ambiguously, such as model names with dots, are
+// written in brackets: models["gpt-4.1"].
+func FormatPath(path []string) string {
+	var b strings.Builder
+	for i, key := range path {
+		if key == "" || strings.ContainsAny(key, ".[]\" ") {
+			quoted, _ := json.Marshal(key)
+			b.WriteString("[" + string(quoted) + "]")
+			continue
+		}
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(key)
+	}
+	return b.String()
+}
+
+// FormatValue writes a value for a one-line description: strings on one
+// line as they are, anything else as compact JSON, cut short if long
+func FormatValue(value any) string {
+	var s string
+	if str, ok := value.(string); ok && str != "" && !strings.ContainsAny(str, "\n\r\t") {
+		s = str
+	} else {
+		data, _ := json.Marshal(value)
+		s = string(data)
+	}
+
+	if utf8.RuneCountInString(s) > maxValueLength {
+		runes := []rune(s)
+		s = string(runes[:maxValueLength-1]) + "…"
+	}
+	return s
+}
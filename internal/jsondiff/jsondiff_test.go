@@ -0,0 +1,169 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// decode parses a JSON document as Diff expects it
+func decode(t *testing.T, data string) any {
+	t.Helper()
+	if data == "" {
+		return nil
+	}
+
+	var v any
+	if err := json.Unmarshal([]byte(data), &v); err != nil {
+		t.Fatalf("invalid test JSON %s: %v", data, err)
+	}
+	return v
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name   string
+		before string
+		after  string
+		want   []Change
+	}{
+		{
+			name:   "equal",
+			before: `{"a": 1, "b": [1, 2]}`,
+			after:  `{"b": [1, 2], "a": 1}`,
+		},
+		{
+			name:   "numbers compared by value",
+			before: `{"a": 1}`,
+			after:  `{"a": 1.0}`,
+		},
+		{
+			name:   "member added, removed and changed",
+			before: `{"a": 1, "b": "x"}`,
+			after:  `{"b": "y", "c": true}`,
+			want: []Change{
+				{Path: []string{"a"}, Kind: Removed, Old: 1.0},
+				{Path: []string{"b"}, Kind: Changed, Old: "x", New: "y"},
+				{Path: []string{"c"}, Kind: Added, New: true},
+			},
+		},
+		{
+			name:   "nested member",
+			before: `{"provider": {"anthropic": {"model": "a"}}}`,
+			after:  `{"provider": {"anthropic": {"model": "b"}}}`,
+			want: []Change{
+				{Path: []string{"provider", "anthropic", "model"}, Kind: Changed, Old: "a", New: "b"},
+			},
+		},
+		{
+			name:   "plain list elements",
+			before: `{"plugin": ["a", "b"]}`,
+			after:  `{"plugin": ["b", "c"]}`,
+			want: []Change{
+				{Path: []string{"plugin"}, Kind: Removed, Old: "a"},
+				{Path: []string{"plugin"}, Kind: Added, New: "c"},
+			},
+		},
+		{
+			name:   "reordered plain list changes as a whole",
+			before: `{"plugin": ["a", "b"]}`,
+			after:  `{"plugin": ["b", "a"]}`,
+			want: []Change{
+				{Path: []string{"plugin"}, Kind: Changed, Old: []any{"a", "b"}, New: []any{"b", "a"}},
+			},
+		},
+		{
+			name:   "list of objects changes as a whole",
+			before: `{"x": [{"a": 1}]}`,
+			after:  `{"x": [{"a": 2}]}`,
+			want: []Change{
+				{Path: []string{"x"}, Kind: Changed, Old: []any{map[string]any{"a": 1.0}}, New: []any{map[string]any{"a": 2.0}}},
+			},
+		},
+		{
+			name:   "type changed",
+			before: `{"a": {"b": 1}}`,
+			after:  `{"a": "b"}`,
+			want: []Change{
+				{Path: []string{"a"}, Kind: Changed, Old: map[string]any{"b": 1.0}, New: "b"},
+			},
+		},
+		{
+			name:  "document added",
+			after: `{"a": 1}`,
+			want: []Change{
+				{Kind: Changed, New: map[string]any{"a": 1.0}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Diff(decode(t, tt.before), decode(t, tt.after))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffJSONNumbers(t *testing.T) {
+	before := map[string]any{"a": json.Number("1"), "b": json.Number("2")}
+	after := map[string]any{"a": json.Number("1.0"), "b": json.Number("3")}
+
+	want := []Change{{Path: []string{"b"}, Kind: Changed, Old: json.Number("2"), New: json.Number("3")}}
+	if got := Diff(before, after); !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff() = %#v, want %#v", got, want)
+	}
+}
+
+func TestFormatPath(t *testing.T) {
+	tests := []struct {
+		path []string
+		want string
+	}{
+		{path: nil, want: ""},
+		{path: []string{"model"}, want: "model"},
+		{path: []string{"provider", "anthropic", "model"}, want: "provider.anthropic.model"},
+		{path: []string{"models", "gpt-4.1"}, want: `models["gpt-4.1"]`},
+		{path: []string{"a b", "c"}, want: `["a b"].c`},
+		{path: []string{"x", ""}, want: `x[""]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := FormatPath(tt.path); got != tt.want {
+				t.Errorf("FormatPath(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatValue(t *testing.T) {
+	long := ""
+	for range 70 {
+		long += "é"
+	}
+
+	tests := []struct {
+		name  string
+		value any
+		want  string
+	}{
+		{name: "string as is", value: "anthropic/claude", want: "anthropic/claude"},
+		{name: "empty string quoted", value: "", want: `""`},
+		{name: "multiline string quoted", value: "a\nb", want: `"a\nb"`},
+		{name: "number", value: json.Number("1.5"), want: "1.5"},
+		{name: "null", value: nil, want: "null"},
+		{name: "object compact", value: map[string]any{"a": true}, want: `{"a":true}`},
+		{name: "long value cut", value: long, want: long[:2*59] + "…"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FormatValue(tt.value); got != tt.want {
+				t.Errorf("FormatValue() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+// Package netstate reports whether this machine is online and whether its
+// connection is metered, so the watch daemon can hold back syncs
+package netstate
+
+import "net"
+
+// State is the network condition of this machine
+type State struct {
+	// Online is false when no network interface other than loopback is
+	// up with a routable address
+	Online bool
+
+	// Metered is true when the platform reports the connection as
+	// metered. Platforms that don't expose it always report false.
+	Metered bool
+}
+
+// Current returns the network condition of this machine
+func Current() State {
+	state := State{Online: online()}
+	if state.Online {
+		state.Metered = metered()
+	}
+	return state
+}
+
+// online reports whether an interface other than loopback is up with a
+// routable address
+func online() bool {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		// Can't tell; let the sync find out
+		return true
+	}
+
+	for _, iface := range interfaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.IsGlobalUnicast() {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,31 @@
+//go:build linux
+
+package netstate
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// metered asks NetworkManager over D-Bus whether the primary connection
+// is metered. Its values are 1 (yes) and 3 (guessed yes), among others.
+func metered() bool {
+	if _, err := exec.LookPath("busctl"); err != nil {
+		return false
+	}
+
+	out, err := exec.Command("busctl", "get-property",
+		"org.freedesktop.NetworkManager", "/org/freedesktop/NetworkManager",
+		"org.freedesktop.NetworkManager", "Metered").Output()
+	if err != nil {
+		// NetworkManager isn't running
+		return false
+	}
+
+	// The reply looks like "u 4"
+	fields := strings.Fields(string(out))
+	if len(fields) != 2 {
+		return false
+	}
+	return fields[1] == "1" || fields[1] == "3"
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !windows
+
+package netstate
+
+// metered is unknown on this platform
+func metered() bool {
+	return false
+}
@@ -0,0 +1,27 @@
+//go:build windows
+
+package netstate
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// meteredScript prints the cost type of the internet connection profile:
+// Unrestricted, Fixed, Variable or Unknown
+const meteredScript = `$p = [Windows.Networking.Connectivity.NetworkInformation,Windows.Networking.Connectivity,ContentType=WindowsRuntime]::GetInternetConnectionProfile(); if ($p) { $p.GetConnectionCost().NetworkCostType }`
+
+// metered asks Windows for the cost of the internet connection; Fixed and
+// Variable plans are metered
+func metered() bool {
+	out, err := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", meteredScript).Output()
+	if err != nil {
+		return false
+	}
+
+	switch strings.TrimSpace(string(out)) {
+	case "Fixed", "Variable":
+		return true
+	}
+	return false
+}
@@ -19,20 +19,123 @@ type Paths struct {
 	// OpenCodeDataDir is where OpenCode stores its data (auth.json, etc.)
 	OpenCodeDataDir string
 
+	// OpenCodeCacheDir is where OpenCode caches downloads, such as the
+	// list of models it knows
+	OpenCodeCacheDir string
+
 	// ClaudeSkillsDir is where Claude Code stores skills (~/.claude/skills/)
 	ClaudeSkillsDir string
+
+	// ClaudeDir is where Claude Code stores its config (~/.claude/)
+	ClaudeDir string
 }
 
+// override replaces the platform paths when set, e.g. for --sandbox
+var override *Paths
+
 // Get returns the paths for the current platform
 func Get() (*Paths, error) {
+	if override != nil {
+		p := *override
+		return &p, nil
+	}
 	return getPlatformPaths()
 }
 
-// SyncRepoDir returns the path to the sync repository
+// Override makes Get return p instead of the platform paths. Passing nil
+// restores the platform paths.
+func Override(p *Paths) {
+	override = p
+}
+
+// DirectLayout makes the OpenCode config dir the sync repo's working
+// tree, with git's data kept in DataDir (repo.layout "direct"). It is set
+// from the config at startup.
+var DirectLayout bool
+
+// RepoSubdir is the slash-separated subdirectory of the sync repo that
+// holds the synced files (repo.subdir), or "" for the whole repo. It is
+// set from the config at startup.
+var RepoSubdir string
+
+// SyncRepoDir returns the path to the directory of the sync repository
+// holding the synced files: its working tree, or repo.subdir in it
 func (p *Paths) SyncRepoDir() string {
+	if DirectLayout {
+		return p.OpenCodeConfigDir
+	}
+	return filepath.Join(p.SyncRepoRoot(), filepath.FromSlash(RepoSubdir))
+}
+
+// SyncRepoRoot returns the path to the sync repository's working tree
+func (p *Paths) SyncRepoRoot() string {
+	if DirectLayout {
+		return p.OpenCodeConfigDir
+	}
 	return filepath.Join(p.DataDir, "repo")
 }
 
+// SyncGitDir returns the path to the sync repository's git data
+func (p *Paths) SyncGitDir() string {
+	if DirectLayout {
+		return filepath.Join(p.DataDir, "repo.git")
+	}
+	return filepath.Join(p.SyncRepoRoot(), ".git")
+}
+
+// StateFile returns the path to the opencode-sync state file
+func (p *Paths) StateFile() string {
+	return filepath.Join(p.DataDir, "state.json")
+}
+
+// IntegrityLogFile returns the path to the hash-chained log of sync
+// operations
+func (p *Paths) IntegrityLogFile() string {
+	return filepath.Join(p.DataDir, "integrity.log")
+}
+
+// HashCacheFile returns the path to the cached hashes of synced files
+func (p *Paths) HashCacheFile() string {
+	return filepath.Join(p.DataDir, "hash-cache.json")
+}
+
+// UsageFile returns the path to the local usage insights
+func (p *Paths) UsageFile() string {
+	return filepath.Join(p.DataDir, "usage.json")
+}
+
+// PromptCacheFile returns the path to the cached 'prompt' output
+func (p *Paths) PromptCacheFile() string {
+	return filepath.Join(p.DataDir, "prompt-cache")
+}
+
+// JournalFile returns the path to the journal of the operation in progress
+func (p *Paths) JournalFile() string {
+	return filepath.Join(p.DataDir, "journal.json")
+}
+
+// ControlFile returns the path to the address and token of the running
+// watch's control API
+func (p *Paths) ControlFile() string {
+	return filepath.Join(p.DataDir, "control.json")
+}
+
+// SocketFile returns the path to the JSON-RPC socket of 'serve'
+func (p *Paths) SocketFile() string {
+	return filepath.Join(p.DataDir, "control.sock")
+}
+
+// BackupsDir returns the directory holding pre-pull backups
+func (p *Paths) BackupsDir() string {
+	return filepath.Join(p.DataDir, "backups")
+}
+
+// SnapshotsDir returns the directory holding snapshots of the local
+// OpenCode config
+func (p *Paths) SnapshotsDir() string {
+	return filepath.Join(p.DataDir, "snapshots")
+}
+
 // ConfigFile returns the path to the opencode-sync config file
 func (p *Paths) ConfigFile() string {
 	return filepath.Join(p.ConfigDir, "config.json")
@@ -43,6 +146,12 @@ func (p *Paths) KeyFile() string {
 	return filepath.Join(p.ConfigDir, "age.key")
 }
 
+// HostKeyFile returns the path to this machine's own age key, used for
+// secrets that other machines must not be able to read
+func (p *Paths) HostKeyFile() string {
+	return filepath.Join(p.ConfigDir, "host.key")
+}
+
 // OpenCodeConfigFile returns the path to the main OpenCode config
 func (p *Paths) OpenCodeConfigFile() string {
 	// Try .jsonc first, then .json
@@ -63,13 +172,39 @@ func (p *Paths) OpenCodeMcpAuthFile() string {
 	return filepath.Join(p.OpenCodeDataDir, "mcp-auth.json")
 }
 
+// OpenCodeModelsFile returns the path to OpenCode's cached list of
+// providers and their models, from models.dev
+func (p *Paths) OpenCodeModelsFile() string {
+	return filepath.Join(p.OpenCodeCacheDir, "models.json")
+}
+
+// OpenCodeStorageDir returns the directory where OpenCode stores sessions
+// and message history
+func (p *Paths) OpenCodeStorageDir() string {
+	return filepath.Join(p.OpenCodeDataDir, "storage")
+}
+
+// ClaudeCommandsDir returns the directory of Claude Code slash commands
+func (p *Paths) ClaudeCommandsDir() string {
+	return filepath.Join(p.ClaudeDir, "commands")
+}
+
+// ClaudeSettingsFile returns the path to Claude Code's settings.json
+func (p *Paths) ClaudeSettingsFile() string {
+	return filepath.Join(p.ClaudeDir, "settings.json")
+}
+
+// ClaudeMemoryFile returns the path to Claude Code's user CLAUDE.md
+func (p *Paths) ClaudeMemoryFile() string {
+	return filepath.Join(p.ClaudeDir, "CLAUDE.md")
+}
+
 // EnsureDirs creates all necessary directories
 func (p *Paths) EnsureDirs() error {
 	dirs := []string{
 		p.ConfigDir,
 		p.DataDir,
-		p.SyncRepoDir(),
-		p.ClaudeSkillsDir,
+		p.SyncRepoRoot(),
 	}
 
 	for _, dir := range dirs {
@@ -88,6 +223,7 @@ func (p *Paths) SyncableOpenCodePaths() []string {
 		filepath.Join(p.OpenCodeConfigDir, "opencode.jsonc"),
 		filepath.Join(p.OpenCodeConfigDir, "oh-my-opencode.json"),
 		filepath.Join(p.OpenCodeConfigDir, "AGENTS.md"),
+		filepath.Join(p.OpenCodeConfigDir, ".syncignore"),
 		filepath.Join(p.OpenCodeConfigDir, "agent"),
 		filepath.Join(p.OpenCodeConfigDir, "command"),
 		filepath.Join(p.OpenCodeConfigDir, "skills"),
@@ -96,7 +232,5 @@ func (p *Paths) SyncableOpenCodePaths() []string {
 		filepath.Join(p.OpenCodeConfigDir, "plugin"),
 	}
 
-	paths = append(paths, p.ClaudeSkillsDir)
-
 	return paths
 }
@@ -24,11 +24,18 @@ func getPlatformPaths() (*Paths, error) {
 		dataHome = filepath.Join(home, ".local", "share")
 	}
 
+	cacheHome := os.Getenv("XDG_CACHE_HOME")
+	if cacheHome == "" {
+		cacheHome = filepath.Join(home, ".cache")
+	}
+
 	return &Paths{
 		ConfigDir:         filepath.Join(configHome, "opencode-sync"),
 		DataDir:           filepath.Join(dataHome, "opencode-sync"),
 		OpenCodeConfigDir: filepath.Join(configHome, "opencode"),
 		OpenCodeDataDir:   filepath.Join(dataHome, "opencode"),
+		OpenCodeCacheDir:  filepath.Join(cacheHome, "opencode"),
 		ClaudeSkillsDir:   filepath.Join(home, ".claude", "skills"),
+		ClaudeDir:         filepath.Join(home, ".claude"),
 	}, nil
 }
@@ -21,6 +21,8 @@ func getPlatformPaths() (*Paths, error) {
 		DataDir:           filepath.Join(localAppData, "opencode-sync"),
 		OpenCodeConfigDir: filepath.Join(appData, "opencode"),
 		OpenCodeDataDir:   filepath.Join(localAppData, "opencode"),
+		OpenCodeCacheDir:  filepath.Join(localAppData, "opencode", "cache"),
 		ClaudeSkillsDir:   filepath.Join(home, ".claude", "skills"),
+		ClaudeDir:         filepath.Join(home, ".claude"),
 	}, nil
 }
@@ -0,0 +1,18 @@
+// Package power reads the battery state of laptops, so the watch daemon
+// can leave the disk and radio alone when the battery runs low
+package power
+
+// Battery is the state of this machine's battery
+type Battery struct {
+	// Percent is the remaining charge, 0 to 100
+	Percent int
+
+	// Discharging is true while the machine runs on battery power
+	Discharging bool
+}
+
+// Status returns the battery state. It reports false on machines without
+// a battery and on platforms it cannot be read on.
+func Status() (Battery, bool) {
+	return status()
+}
@@ -0,0 +1,33 @@
+//go:build darwin
+
+package power
+
+import (
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// pmsetPercent finds the charge in a line such as
+// " -InternalBattery-0 (id=1234)	85%; discharging; 3:10 remaining"
+var pmsetPercent = regexp.MustCompile(`(\d+)%;`)
+
+func status() (Battery, bool) {
+	out, err := exec.Command("pmset", "-g", "batt").Output()
+	if err != nil {
+		return Battery{}, false
+	}
+
+	match := pmsetPercent.FindSubmatch(out)
+	if match == nil {
+		// No battery
+		return Battery{}, false
+	}
+	percent, err := strconv.Atoi(string(match[1]))
+	if err != nil {
+		return Battery{}, false
+	}
+
+	return Battery{Percent: percent, Discharging: strings.Contains(string(out), "'Battery Power'")}, true
+}
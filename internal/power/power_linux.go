@@ -0,0 +1,60 @@
+//go:build linux
+
+package power
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// powerSupplyDir lists batteries and AC adapters
+const powerSupplyDir = "/sys/class/power_supply"
+
+func status() (Battery, bool) {
+	supplies, err := os.ReadDir(powerSupplyDir)
+	if err != nil {
+		return Battery{}, false
+	}
+
+	var total, batteries int
+	discharging, onMains := false, false
+	for _, supply := range supplies {
+		dir := filepath.Join(powerSupplyDir, supply.Name())
+		switch readValue(dir, "type") {
+		case "Battery":
+			// Peripherals such as mice report their batteries too
+			if readValue(dir, "scope") == "Device" {
+				continue
+			}
+			capacity, err := strconv.Atoi(readValue(dir, "capacity"))
+			if err != nil {
+				continue
+			}
+			total += capacity
+			batteries++
+			if readValue(dir, "status") == "Discharging" {
+				discharging = true
+			}
+		case "Mains", "USB":
+			if readValue(dir, "online") == "1" {
+				onMains = true
+			}
+		}
+	}
+
+	if batteries == 0 {
+		return Battery{}, false
+	}
+	return Battery{Percent: total / batteries, Discharging: discharging && !onMains}, true
+}
+
+// readValue reads one attribute of a power supply
+func readValue(dir, name string) string {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
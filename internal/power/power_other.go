@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !windows
+
+package power
+
+// status is unknown on this platform
+func status() (Battery, bool) {
+	return Battery{}, false
+}
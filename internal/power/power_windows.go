@@ -0,0 +1,34 @@
+//go:build windows
+
+package power
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// systemPowerStatus is SYSTEM_POWER_STATUS from the Windows API
+type systemPowerStatus struct {
+	ACLineStatus        byte
+	BatteryFlag         byte
+	BatteryLifePercent  byte
+	SystemStatusFlag    byte
+	BatteryLifeTime     uint32
+	BatteryFullLifeTime uint32
+}
+
+var getSystemPowerStatus = syscall.NewLazyDLL("kernel32.dll").NewProc("GetSystemPowerStatus")
+
+func status() (Battery, bool) {
+	var s systemPowerStatus
+	if ret, _, _ := getSystemPowerStatus.Call(uintptr(unsafe.Pointer(&s))); ret == 0 {
+		return Battery{}, false
+	}
+
+	// 128 means no battery, 255 an unknown state or charge
+	if s.BatteryFlag == 128 || s.BatteryFlag == 255 || s.BatteryLifePercent == 255 {
+		return Battery{}, false
+	}
+
+	return Battery{Percent: int(s.BatteryLifePercent), Discharging: s.ACLineStatus == 0}, true
+}
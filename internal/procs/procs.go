@@ -0,0 +1,36 @@
+package procs
+
+import "time"
+
+// OpenCodeProcessName is the executable name of OpenCode (without extension)
+const OpenCodeProcessName = "opencode"
+
+// IsOpenCodeRunning reports whether an OpenCode process is currently running
+func IsOpenCodeRunning() (bool, error) {
+	return isRunning(OpenCodeProcessName)
+}
+
+// ProcessAlive reports whether a process with the given PID exists
+func ProcessAlive(pid int) bool {
+	return pid > 0 && processAlive(pid)
+}
+
+// WaitForOpenCodeExit polls until OpenCode is no longer running or the
+// timeout elapses. It returns true if OpenCode exited in time.
+func WaitForOpenCodeExit(timeout, pollInterval time.Duration) (bool, error) {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		running, err := IsOpenCodeRunning()
+		if err != nil {
+			return false, err
+		}
+		if !running {
+			return true, nil
+		}
+		if time.Now().After(deadline) {
+			return false, nil
+		}
+		time.Sleep(pollInterval)
+	}
+}
@@ -0,0 +1,35 @@
+//go:build unix
+
+package procs
+
+import (
+	"errors"
+	"os/exec"
+	"syscall"
+)
+
+func isRunning(name string) (bool, error) {
+	if _, err := exec.LookPath("pgrep"); err != nil {
+		// Without pgrep we cannot tell, assume not running
+		return false, nil
+	}
+
+	err := exec.Command("pgrep", "-x", name).Run()
+	if err == nil {
+		return true, nil
+	}
+
+	// pgrep exits with status 1 when no process matched
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return false, nil
+	}
+
+	return false, err
+}
+
+func processAlive(pid int) bool {
+	// Signal 0 checks for existence; EPERM means it exists as another user
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,30 @@
+//go:build windows
+
+package procs
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+)
+
+func isRunning(name string) (bool, error) {
+	image := name + ".exe"
+
+	out, err := exec.Command("tasklist", "/FI", "IMAGENAME eq "+image, "/NH").Output()
+	if err != nil {
+		return false, err
+	}
+
+	return strings.Contains(strings.ToLower(string(out)), image), nil
+}
+
+func processAlive(pid int) bool {
+	// Fails on Windows when no such process exists
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	proc.Release()
+	return true
+}
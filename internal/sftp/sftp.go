@@ -0,0 +1,462 @@
+// Package sftp stores snapshots in a directory on another machine over
+// SSH, using the system sftp client. The other machine needs nothing but
+// an SSH server.
+package sftp
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/GareArc/opencode-sync/internal/capability"
+	"github.com/GareArc/opencode-sync/internal/git"
+	"github.com/GareArc/opencode-sync/internal/snapshot"
+)
+
+func init() {
+	capability.Register(capability.Capability{
+		Name:        "sftp",
+		Kind:        capability.KindBackend,
+		Description: "Sync encrypted snapshots to a directory on another machine over SSH (sftp:// URLs)",
+	})
+}
+
+// SFTP has no conditional writes, so each object has a sidecar file
+// holding a random version that stands in for an ETag, and writers take
+// a lock directory while they compare and replace it
+const (
+	etagSuffix = ".etag"
+	lockSuffix = ".lock"
+
+	// lockOwner is the file in a lock directory naming who holds it
+	lockOwner = "owner"
+
+	// lockTimeout is how long a lock is honored. A writer that held it this
+	// long was interrupted and left it behind.
+	lockTimeout = 10 * time.Minute
+
+	// lockWait is how long a writer waits for another one to finish, and
+	// lockPoll how often it checks
+	lockWait = 30 * time.Second
+	lockPoll = 2 * time.Second
+)
+
+// Client reads and writes the files of one directory over SFTP. It
+// implements snapshot.Store.
+type Client struct {
+	// remote names the directory in errors
+	remote string
+
+	host    string
+	port    string
+	dir     string
+	options []string
+}
+
+// New creates a Client for the directory at an sftp://[user@]host[:port]/path
+// URL. A path starting with /~/ is relative to the home directory. options
+// are passed on to ssh, such as "-o", "StrictHostKeyChecking=yes".
+func New(rawURL string, options []string) (*Client, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SFTP URL: %w", err)
+	}
+	if u.Scheme != "sftp" {
+		return nil, fmt.Errorf("invalid SFTP URL %s: must start with sftp://", rawURL)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid SFTP URL %s: no host", rawURL)
+	}
+
+	dir := u.Path
+	switch {
+	case dir == "~" || dir == "/~" || dir == "/~/":
+		dir = "."
+	case strings.HasPrefix(dir, "/~/"):
+		dir = path.Clean(strings.TrimPrefix(dir, "/~/"))
+	case dir == "" || dir == "/":
+		return nil, fmt.Errorf("invalid SFTP URL %s: no directory", rawURL)
+	default:
+		dir = path.Clean(dir)
+	}
+
+	host := u.Hostname()
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if username := u.User.Username(); username != "" {
+		host = username + "@" + host
+	}
+
+	remote := *u
+	remote.User = nil
+	if username := u.User.Username(); username != "" {
+		remote.User = url.User(username)
+	}
+
+	return &Client{
+		remote:  remote.String(),
+		host:    host,
+		port:    u.Port(),
+		dir:     dir,
+		options: options,
+	}, nil
+}
+
+// Remote returns the URL of the directory without secrets, for messages
+func (c *Client) Remote() string {
+	return c.remote
+}
+
+// file returns the remote path of a file in the directory
+func (c *Client) file(name string) string {
+	return path.Join(c.dir, name)
+}
+
+// run runs sftp with a batch of commands. Commands prefixed with "-" may
+// fail without stopping the batch.
+func (c *Client) run(ctx context.Context, commands []string) error {
+	args := []string{"-q", "-b", "-"}
+	if c.port != "" {
+		args = append(args, "-P", c.port)
+	}
+	if git.NoTerminalPrompt {
+		args = append(args, "-o", "BatchMode=yes")
+	}
+	args = append(args, c.options...)
+	args = append(args, c.host)
+
+	cmd := exec.CommandContext(ctx, "sftp", args...)
+	cmd.Stdin = strings.NewReader(strings.Join(commands, "\n") + "\n")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return fmt.Errorf("sftp not found; install the OpenSSH client: %w", err)
+		}
+		msg := strings.TrimSpace(stderr.String())
+		failed := fmt.Errorf("sftp: %s: %w", msg, err)
+		// sftp says "Connection closed" when ssh could not log in at all,
+		// after ssh's own reason
+		if strings.Contains(msg, "Connection closed") {
+			if classified := git.ClassifyRemoteError(c.remote, msg, failed); classified != nil {
+				return classified
+			}
+		}
+		return failed
+	}
+	return nil
+}
+
+// quote quotes a path for an sftp batch command
+func quote(p string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(p) + `"`
+}
+
+// workDir creates a local directory for the files of one operation
+func workDir() (string, error) {
+	dir, err := os.MkdirTemp("", "opencode-sync-sftp-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	return dir, nil
+}
+
+// readLocal returns the content of a file sftp downloaded, or ok false if
+// it could not download it
+func readLocal(file string) ([]byte, bool) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// List returns the files in the directory, without the version files
+// and locks. A missing directory has none.
+func (c *Client) List(ctx context.Context) ([]snapshot.Object, error) {
+	work, err := workDir()
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(work)
+
+	// Every object has a version file, and fetching those is simpler than
+	// parsing ls output
+	listing := filepath.Join(work, "listing")
+	if err := os.Mkdir(listing, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	if err := c.run(ctx, []string{"-get " + quote(c.file("*"+etagSuffix)) + " " + quote(listing)}); err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(listing)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read listing: %w", err)
+	}
+
+	var objects []snapshot.Object
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), etagSuffix)
+		if !ok || entry.IsDir() {
+			continue
+		}
+		etag, _ := readLocal(filepath.Join(listing, entry.Name()))
+		objects = append(objects, snapshot.Object{Name: name, ETag: strings.TrimSpace(string(etag))})
+	}
+	return objects, nil
+}
+
+// Get returns the content and version of a file. With ifNoneMatch set,
+// it returns snapshot.ErrNotModified without downloading the file if it
+// still has that version.
+func (c *Client) Get(ctx context.Context, name, ifNoneMatch string) ([]byte, string, error) {
+	work, err := workDir()
+	if err != nil {
+		return nil, "", err
+	}
+	defer os.RemoveAll(work)
+
+	etagFile := filepath.Join(work, "etag")
+	dataFile := filepath.Join(work, "data")
+
+	if ifNoneMatch != "" {
+		if err := c.run(ctx, []string{"-get " + quote(c.file(name+etagSuffix)) + " " + quote(etagFile)}); err != nil {
+			return nil, "", err
+		}
+		if etag, ok := readLocal(etagFile); ok && strings.TrimSpace(string(etag)) == ifNoneMatch {
+			return nil, "", fmt.Errorf("%s: %w", name, snapshot.ErrNotModified)
+		}
+		os.Remove(etagFile)
+	}
+
+	// The version is read first: a writer replaces it after the file, so a
+	// new file may come with the old version, which only causes another
+	// download, but never the other way round
+	err = c.run(ctx, []string{
+		"-get " + quote(c.file(name+etagSuffix)) + " " + quote(etagFile),
+		"-get " + quote(c.file(name)) + " " + quote(dataFile),
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	data, ok := readLocal(dataFile)
+	if !ok {
+		return nil, "", fmt.Errorf("%s: %w", name, snapshot.ErrNotFound)
+	}
+	etag, _ := readLocal(etagFile)
+	return data, strings.TrimSpace(string(etag)), nil
+}
+
+// Put writes a file and returns its new version. With ifMatch set the
+// file must still have that version, without it the file must not exist
+// yet; otherwise snapshot.ErrPreconditionFailed is returned. The
+// directory is created if it is missing.
+func (c *Client) Put(ctx context.Context, name string, data []byte, ifMatch string) (string, error) {
+	work, err := workDir()
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(work)
+
+	if err := c.lock(ctx, name, ifMatch, work); err != nil {
+		return "", err
+	}
+
+	etag, err := newETag()
+	if err != nil {
+		c.unlock(ctx, name)
+		return "", err
+	}
+
+	dataFile := filepath.Join(work, "data")
+	etagFile := filepath.Join(work, "new-etag")
+	if err := os.WriteFile(dataFile, data, 0600); err != nil {
+		c.unlock(ctx, name)
+		return "", fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := os.WriteFile(etagFile, []byte(etag+"\n"), 0600); err != nil {
+		c.unlock(ctx, name)
+		return "", fmt.Errorf("failed to write temporary file: %w", err)
+	}
+
+	// The file is uploaded under a temporary name and renamed over the old
+	// one, so readers never see half of it
+	temp := c.file("." + name + ".tmp")
+	upload := []string{"put " + quote(dataFile) + " " + quote(temp)}
+	replace := []string{"rename " + quote(temp) + " " + quote(c.file(name))}
+	finish := append([]string{"put " + quote(etagFile) + " " + quote(c.file(name+etagSuffix))}, c.unlockCommands(name)...)
+
+	err = c.run(ctx, append(append(upload, replace...), finish...))
+	if err != nil && strings.Contains(err.Error(), "rename") {
+		// Servers without posix-rename cannot rename over an existing file
+		replace = append([]string{"-rm " + quote(c.file(name))}, replace...)
+		err = c.run(ctx, append(replace, finish...))
+	}
+	if err != nil {
+		c.unlock(ctx, name)
+		return "", err
+	}
+	return etag, nil
+}
+
+// Delete removes a file, if it still has the version ifMatch when set
+func (c *Client) Delete(ctx context.Context, name, ifMatch string) error {
+	work, err := workDir()
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(work)
+
+	if ifMatch == "" {
+		// Anything but an empty version matches
+		ifMatch = "*"
+	}
+	if err := c.lock(ctx, name, ifMatch, work); err != nil {
+		return err
+	}
+
+	commands := []string{"rm " + quote(c.file(name)), "-rm " + quote(c.file(name+etagSuffix))}
+	if err := c.run(ctx, append(commands, c.unlockCommands(name)...)); err != nil {
+		c.unlock(ctx, name)
+		return err
+	}
+	return nil
+}
+
+// lock takes the lock of a file, creating the directory if needed, and
+// checks that the file has the version ifMatch: "" for a file that does
+// not exist, "*" for any existing file. It returns
+// snapshot.ErrPreconditionFailed if it does not, or if another machine
+// keeps holding the lock for longer than lockWait.
+func (c *Client) lock(ctx context.Context, name, ifMatch, work string) error {
+	host, _ := os.Hostname()
+	owner := filepath.Join(work, "owner")
+	content := time.Now().UTC().Format(time.RFC3339) + " " + host + "\n"
+	if err := os.WriteFile(owner, []byte(content), 0600); err != nil {
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+
+	var commands []string
+	if c.dir != "." && c.dir != "/" {
+		parents := strings.Split(strings.TrimPrefix(c.dir, "/"), "/")
+		for i := range parents {
+			dir := path.Join(parents[:i+1]...)
+			if strings.HasPrefix(c.dir, "/") {
+				dir = "/" + dir
+			}
+			commands = append(commands, "-mkdir "+quote(dir))
+		}
+	}
+
+	lockDir := c.file(name + lockSuffix)
+	etagFile := filepath.Join(work, "etag")
+	commands = append(commands,
+		"mkdir "+quote(lockDir),
+		"put "+quote(owner)+" "+quote(path.Join(lockDir, lockOwner)),
+		"-get "+quote(c.file(name+etagSuffix))+" "+quote(etagFile),
+	)
+
+	start := time.Now()
+	for {
+		err := c.run(ctx, commands)
+		if err == nil {
+			break
+		}
+
+		holder, since, lockErr := c.lockHolder(ctx, name, work)
+		switch {
+		case lockErr != nil:
+			return lockErr
+		case holder == "" && strings.Contains(err.Error(), "Permission denied"):
+			return fmt.Errorf("failed to lock %s: %w", lockDir, err)
+		case holder == "":
+			// Its owner is being written, or its writer died before that
+			holder, since = "another machine", start
+		case time.Since(since) > lockTimeout:
+			// The holder was interrupted; take the lock over
+			if err := c.run(ctx, c.unlockCommands(name)); err != nil {
+				return err
+			}
+			continue
+		}
+		if time.Since(start) > lockWait {
+			return fmt.Errorf("%s: %s has been uploading since %s (remove %s if not): %w", name, holder, since.Local().Format(time.Kitchen), lockDir, snapshot.ErrPreconditionFailed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(lockPoll):
+		}
+	}
+
+	current := ""
+	if etag, ok := readLocal(etagFile); ok {
+		current = strings.TrimSpace(string(etag))
+	}
+	if (ifMatch == "*" && current == "") || (ifMatch != "*" && current != ifMatch) {
+		c.unlock(ctx, name)
+		return fmt.Errorf("%s: %w", name, snapshot.ErrPreconditionFailed)
+	}
+	return nil
+}
+
+// lockHolder returns who holds the lock of a file and since when, or ""
+// if nobody does. An owner file that cannot be parsed counts as written
+// just now.
+func (c *Client) lockHolder(ctx context.Context, name, work string) (string, time.Time, error) {
+	owner := filepath.Join(work, "current-owner")
+	os.Remove(owner)
+	if err := c.run(ctx, []string{"-get " + quote(path.Join(c.file(name+lockSuffix), lockOwner)) + " " + quote(owner)}); err != nil {
+		return "", time.Time{}, err
+	}
+
+	content, ok := readLocal(owner)
+	if !ok {
+		return "", time.Time{}, nil
+	}
+	since, holder, _ := strings.Cut(strings.TrimSpace(string(content)), " ")
+	taken, err := time.Parse(time.RFC3339, since)
+	if err != nil {
+		taken = time.Now()
+	}
+	if holder == "" {
+		holder = "another machine"
+	}
+	return holder, taken, nil
+}
+
+// unlockCommands returns the commands that release the lock of a file
+func (c *Client) unlockCommands(name string) []string {
+	lockDir := c.file(name + lockSuffix)
+	return []string{"-rm " + quote(path.Join(lockDir, lockOwner)), "rmdir " + quote(lockDir)}
+}
+
+// unlock releases the lock of a file after a failure. A lock it cannot
+// remove expires after lockTimeout.
+func (c *Client) unlock(ctx context.Context, name string) {
+	_ = c.run(ctx, c.unlockCommands(name))
+}
+
+// newETag returns a random version
+func newETag() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate version: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
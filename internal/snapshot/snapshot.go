@@ -0,0 +1,344 @@
+// Package snapshot carries the sync repository to storage that is not a
+// git server, as a single git bundle, compressed with zstd and encrypted.
+package snapshot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/GareArc/opencode-sync/internal/crypto"
+	"github.com/GareArc/opencode-sync/internal/git"
+)
+
+// CompressionLevel is the zstd level snapshots are uploaded with, from 1
+// (fastest) to 22 (smallest). Zero uploads them uncompressed. Snapshots
+// are read back whatever level they were written with.
+var CompressionLevel = 3
+
+// zstdMagic starts every zstd frame. A git bundle starts with "# v2 git
+// bundle" or "# v3 git bundle", so snapshots from before compression
+// still read.
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// Sizes are the size of a snapshot's bundle and of what was encrypted of
+// it, which is usually smaller when it was compressed
+type Sizes struct {
+	Raw        int64
+	Compressed int64
+
+	// Level is the CompressionLevel it was uploaded with
+	Level int
+}
+
+var (
+	uploadMu   sync.Mutex
+	lastUpload *Sizes
+)
+
+// LastUpload returns the sizes of the last snapshot this process uploaded,
+// and false if it uploaded none
+func LastUpload() (Sizes, bool) {
+	uploadMu.Lock()
+	defer uploadMu.Unlock()
+	if lastUpload == nil {
+		return Sizes{}, false
+	}
+	return *lastUpload, true
+}
+
+// Errors a Store returns, wrapped in more detail
+var (
+	ErrNotFound           = errors.New("not found")
+	ErrNotModified        = errors.New("not modified")
+	ErrPreconditionFailed = errors.New("changed by someone else")
+	ErrUnauthorized       = errors.New("unauthorized")
+)
+
+// Object describes a stored object
+type Object struct {
+	Name     string
+	Size     int64
+	ETag     string
+	Modified time.Time
+}
+
+// Store keeps named objects and versions them with ETags, so a write can
+// require that nobody changed the object since it was read
+type Store interface {
+	// List returns the objects in the store
+	List(ctx context.Context) ([]Object, error)
+
+	// Get returns the content and ETag of an object. With ifNoneMatch set,
+	// it returns ErrNotModified if the object still has that ETag.
+	Get(ctx context.Context, name, ifNoneMatch string) ([]byte, string, error)
+
+	// Put writes an object and returns its new ETag, which may be empty if
+	// the store does not report it. With ifMatch set the object must still
+	// have that ETag, without it the object must not exist; otherwise
+	// ErrPreconditionFailed is returned.
+	Put(ctx context.Context, name string, data []byte, ifMatch string) (string, error)
+
+	// Delete removes an object, if it still has the ETag ifMatch when set
+	Delete(ctx context.Context, name, ifMatch string) error
+}
+
+// BundleName is the object holding the encrypted repository
+const BundleName = "repo.bundle.age"
+
+// stateFile records, in the mirror, the last snapshot exchanged with the
+// store
+const stateFile = "opencode-sync-snapshot.json"
+
+// syncedState is what stateFile holds
+type syncedState struct {
+	// ETag is the snapshot's ETag in the store
+	ETag string `json:"etag"`
+
+	// Refs are the mirror's branches when they matched the snapshot
+	Refs string `json:"refs"`
+}
+
+// Transport is a git.Transport keeping the repository in a Store as one
+// bundle, compressed with CompressionLevel and encrypted with the repo key
+type Transport struct {
+	remote string
+	store  Store
+	mirror *git.Mirror
+	enc    crypto.Encryption
+}
+
+// NewTransport creates a Transport between store and the mirror at
+// mirrorDir. remote names the store in errors and must not hold secrets.
+func NewTransport(remote string, store Store, mirrorDir string, enc crypto.Encryption) *Transport {
+	return &Transport{
+		remote: remote,
+		store:  store,
+		mirror: &git.Mirror{Path: mirrorDir},
+		enc:    enc,
+	}
+}
+
+// Mirror returns the path of the local bare repository
+func (t *Transport) Mirror() string {
+	return t.mirror.Path
+}
+
+// Download replaces the mirror's branches with the stored snapshot if it
+// changed. An upload that did not finish is sent again.
+func (t *Transport) Download(ctx context.Context) error {
+	if err := t.mirror.Init(); err != nil {
+		return err
+	}
+	synced := t.loadState()
+
+	data, etag, err := t.store.Get(ctx, BundleName, synced.ETag)
+	switch {
+	case errors.Is(err, ErrNotModified):
+		refs, err := t.mirror.Refs()
+		if err != nil {
+			return err
+		}
+		if refs != synced.Refs {
+			return t.Upload(ctx)
+		}
+		return nil
+	case errors.Is(err, ErrNotFound):
+		// Nothing uploaded yet; the next push creates the snapshot
+		synced.ETag = ""
+		return t.saveState(synced)
+	case err != nil:
+		return t.remoteError(err)
+	}
+
+	plain, err := t.enc.Decrypt(data)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt snapshot from %s (is the repo key the same on every machine?): %w", t.remote, err)
+	}
+	if plain, err = decompress(plain); err != nil {
+		return fmt.Errorf("failed to decompress snapshot from %s: %w", t.remote, err)
+	}
+
+	bundle, err := t.tempFile(plain)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(bundle)
+
+	if err := t.mirror.ReadBundle(bundle); err != nil {
+		return err
+	}
+
+	refs, err := t.mirror.Refs()
+	if err != nil {
+		return err
+	}
+	return t.saveState(syncedState{ETag: etag, Refs: refs})
+}
+
+// Upload stores the mirror's branches as a new snapshot, unless they did
+// not change since the last exchange. It returns a git.RejectedError if
+// another machine uploaded in the meantime.
+func (t *Transport) Upload(ctx context.Context) error {
+	refs, err := t.mirror.Refs()
+	if err != nil {
+		return err
+	}
+	synced := t.loadState()
+	if refs == "" || (refs == synced.Refs && synced.ETag != "") {
+		return nil
+	}
+
+	bundle, err := t.tempFile(nil)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(bundle)
+
+	if err := t.mirror.WriteBundle(bundle); err != nil {
+		return err
+	}
+	plain, err := os.ReadFile(bundle)
+	if err != nil {
+		return fmt.Errorf("failed to read bundle: %w", err)
+	}
+	packed, err := compress(plain, CompressionLevel)
+	if err != nil {
+		return fmt.Errorf("failed to compress snapshot: %w", err)
+	}
+	data, err := t.enc.Encrypt(packed)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt snapshot: %w", err)
+	}
+
+	etag, err := t.store.Put(ctx, BundleName, data, synced.ETag)
+	if errors.Is(err, ErrPreconditionFailed) {
+		return &git.RejectedError{Remote: t.remote, Err: err}
+	}
+	if err != nil {
+		return t.remoteError(err)
+	}
+
+	if etag == "" {
+		if etag, err = t.storedETag(ctx); err != nil {
+			return t.remoteError(err)
+		}
+	}
+
+	uploadMu.Lock()
+	lastUpload = &Sizes{Raw: int64(len(plain)), Compressed: int64(len(packed)), Level: CompressionLevel}
+	uploadMu.Unlock()
+
+	return t.saveState(syncedState{ETag: etag, Refs: refs})
+}
+
+// compress returns bundle as a zstd frame of the given level, or as it is
+// for level zero
+func compress(bundle []byte, level int) ([]byte, error) {
+	if level == 0 {
+		return bundle, nil
+	}
+	enc, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)), zstd.WithEncoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	defer enc.Close()
+	return enc.EncodeAll(bundle, nil), nil
+}
+
+// decompress returns the bundle a snapshot holds, which was compressed if
+// it starts with a zstd frame
+func decompress(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, zstdMagic) {
+		return data, nil
+	}
+	dec, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	defer dec.Close()
+	return dec.DecodeAll(data, nil)
+}
+
+// storedETag looks up the snapshot's ETag, for stores that do not
+// return it from Put
+func (t *Transport) storedETag(ctx context.Context) (string, error) {
+	objects, err := t.store.List(ctx)
+	if err != nil {
+		return "", err
+	}
+	for _, object := range objects {
+		if object.Name == BundleName {
+			return object.ETag, nil
+		}
+	}
+	return "", fmt.Errorf("%s is missing right after it was written", BundleName)
+}
+
+// remoteError classifies a failed store operation like git's own remote
+// errors, so the usual hints and exit codes apply
+func (t *Transport) remoteError(err error) error {
+	if errors.Is(err, git.ErrAuth) || errors.Is(err, git.ErrNetwork) {
+		// The store classified it already
+		return err
+	}
+	if errors.Is(err, ErrUnauthorized) {
+		return &git.AuthError{Remote: t.remote, Err: err}
+	}
+	var netErr net.Error
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	if errors.As(err, &opErr) || errors.As(err, &dnsErr) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return &git.NetworkError{Remote: t.remote, Err: err}
+	}
+	return fmt.Errorf("failed to sync with %s: %w", t.remote, err)
+}
+
+// tempFile creates a file next to the mirror holding data, so bundles
+// never leave the data directory
+func (t *Transport) tempFile(data []byte) (string, error) {
+	f, err := os.CreateTemp(filepath.Dir(t.mirror.Path), "snapshot-*.bundle")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	name := f.Name()
+
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(name)
+		return "", fmt.Errorf("failed to write temporary file: %w", err)
+	}
+
+	return name, nil
+}
+
+func (t *Transport) loadState() syncedState {
+	var synced syncedState
+	if data, err := os.ReadFile(filepath.Join(t.mirror.Path, stateFile)); err == nil {
+		_ = json.Unmarshal(data, &synced)
+	}
+	return synced
+}
+
+func (t *Transport) saveState(synced syncedState) error {
+	data, err := json.Marshal(synced)
+	if err != nil {
+		return fmt.Errorf("failed to marshal snapshot state: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(t.mirror.Path, stateFile), data, 0600); err != nil {
+		return fmt.Errorf("failed to save snapshot state: %w", err)
+	}
+	return nil
+}
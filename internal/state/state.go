@@ -0,0 +1,209 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/GareArc/opencode-sync/internal/paths"
+)
+
+// Operation types recorded in the state file
+const (
+	OpPush = "push"
+	OpPull = "pull"
+)
+
+// State holds information opencode-sync keeps between runs
+type State struct {
+	LastOperation *Operation `json:"lastOperation,omitempty"`
+
+	// Timings holds how long the last run of each operation type took
+	Timings map[string]*Timing `json:"timings,omitempty"`
+
+	// LastFetch is when pull or the startup hook last checked the remote
+	LastFetch time.Time `json:"lastFetch,omitempty"`
+
+	// Manifest maps sync repo paths to the SHA-256 of their content after
+	// the last cycle of 'watch --two-way', to tell which side changed since
+	Manifest map[string]string `json:"manifest,omitempty"`
+
+	// Pause is set while automatic syncing is paused
+	Pause *Pause `json:"pause,omitempty"`
+
+	// Locked are the sync repo paths pull must not overwrite on this
+	// machine (see 'lock')
+	Locked []string `json:"locked,omitempty"`
+}
+
+// Pause records that watch and the startup hook should not sync
+type Pause struct {
+	// Since is when syncing was paused
+	Since time.Time `json:"since"`
+
+	// Until is when syncing resumes by itself, zero to wait for 'resume'
+	Until time.Time `json:"until,omitempty"`
+}
+
+// Paused returns the pause in effect at now, or nil
+func (st *State) Paused(now time.Time) *Pause {
+	if st.Pause == nil || (!st.Pause.Until.IsZero() && !now.Before(st.Pause.Until)) {
+		return nil
+	}
+	return st.Pause
+}
+
+// Operation records what a sync operation changed
+type Operation struct {
+	// Type is OpPush or OpPull
+	Type string `json:"type"`
+
+	// Time is when the operation finished
+	Time time.Time `json:"time"`
+
+	// HeadBefore is the sync repo HEAD before the operation
+	HeadBefore string `json:"headBefore"`
+
+	// HeadAfter is the sync repo HEAD after the operation
+	HeadAfter string `json:"headAfter"`
+
+	// Squashed is set when a push folded its changes into HeadBefore's
+	// commit, so HeadAfter replaced HeadBefore rather than following it
+	Squashed bool `json:"squashed,omitempty"`
+
+	// BackupDir is the pre-pull backup of local files (pull only)
+	BackupDir string `json:"backupDir,omitempty"`
+}
+
+// Timing records how long an operation took and where the time went
+type Timing struct {
+	// Time is when the operation finished
+	Time time.Time `json:"time"`
+
+	// Total is the wall-clock duration of the whole operation
+	Total time.Duration `json:"total"`
+
+	// Phases is the time spent in each timed phase
+	Phases map[string]time.Duration `json:"phases,omitempty"`
+}
+
+// Load loads the state from the default location.
+// A missing state file yields an empty state.
+func Load() (*State, error) {
+	p, err := paths.Get()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get paths: %w", err)
+	}
+
+	data, err := os.ReadFile(p.StateFile())
+	if os.IsNotExist(err) {
+		return &State{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state: %w", err)
+	}
+
+	var st State
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("failed to parse state: %w", err)
+	}
+
+	return &st, nil
+}
+
+// Save saves the state to the default location
+func Save(st *State) error {
+	p, err := paths.Get()
+	if err != nil {
+		return fmt.Errorf("failed to get paths: %w", err)
+	}
+
+	if err := os.MkdirAll(p.DataDir, 0755); err != nil {
+		return fmt.Errorf("failed to create data dir: %w", err)
+	}
+
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	if err := os.WriteFile(p.StateFile(), data, 0644); err != nil {
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+
+	return nil
+}
+
+// RecordOperation stores op as the last operation and drops the manifest
+func RecordOperation(op *Operation) error {
+	st, err := Load()
+	if err != nil {
+		return err
+	}
+
+	st.LastOperation = op
+
+	// The repo changed outside 'watch --two-way', which falls back to
+	// comparing with HEAD until it records a manifest again
+	st.Manifest = nil
+	return Save(st)
+}
+
+// RecordTiming stores t as the timing of the last operation of type op
+func RecordTiming(op string, t *Timing) error {
+	st, err := Load()
+	if err != nil {
+		return err
+	}
+
+	if st.Timings == nil {
+		st.Timings = make(map[string]*Timing)
+	}
+	st.Timings[op] = t
+	return Save(st)
+}
+
+// RecordFetch stores t as the time the remote was last checked
+func RecordFetch(t time.Time) error {
+	st, err := Load()
+	if err != nil {
+		return err
+	}
+
+	st.LastFetch = t
+	return Save(st)
+}
+
+// RecordManifest stores the sync repo manifest for 'watch --two-way'
+func RecordManifest(manifest map[string]string) error {
+	st, err := Load()
+	if err != nil {
+		return err
+	}
+
+	st.Manifest = manifest
+	return Save(st)
+}
+
+// RecordPause stores p as the current pause, or resumes syncing if p is nil
+func RecordPause(p *Pause) error {
+	st, err := Load()
+	if err != nil {
+		return err
+	}
+
+	st.Pause = p
+	return Save(st)
+}
+
+// RecordLocks stores the sync repo paths pull must not overwrite
+func RecordLocks(relPaths []string) error {
+	st, err := Load()
+	if err != nil {
+		return err
+	}
+
+	st.Locked = relPaths
+	return Save(st)
+}
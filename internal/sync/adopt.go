@@ -0,0 +1,232 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/GareArc/opencode-sync/internal/git"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// Ways of adopting a local file that differs from a freshly cloned repo
+const (
+	// AdoptRemote applies the repo's copy, as a pull does
+	AdoptRemote = "remote"
+
+	// AdoptLocal keeps the local file and commits it to the repo
+	AdoptLocal = "local"
+
+	// AdoptMerge merges both and commits the result
+	AdoptMerge = "merge"
+)
+
+// ErrAdoptConflict is returned by Adopt when a merge finds values the
+// two sides set differently
+var ErrAdoptConflict = errors.New("both sides set different values")
+
+// AdoptionConflicts returns the repo paths of local files that differ
+// from their copy in a freshly cloned repo, which applying the repo would
+// overwrite. Encrypted files and files split into a redacted form are
+// left out: they are applied as usual and only kept in the backup.
+func (s *Syncer) AdoptionConflicts() ([]string, error) {
+	s.resetSyncIgnore()
+	if s.Direct() {
+		return s.directAdoptionConflicts()
+	}
+
+	if err := s.reloadRecipients(); err != nil {
+		return nil, err
+	}
+
+	files, err := s.getSyncableFiles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get syncable files: %w", err)
+	}
+
+	var conflicts []string
+	for _, file := range files {
+		if !s.onlyMatches(file.RelPath) || s.isHostSecret(file.RelPath) || s.hasRecipients(file.RelPath) ||
+			(s.cfg.Sync.SplitMcpSecrets && isMcpConfigFile(file.RelPath)) {
+			continue
+		}
+
+		// Files only this machine has are pushed by the next push
+		hash, err := s.hashFile(filepath.Join(s.paths.SyncRepoDir(), file.RelPath))
+		if err != nil {
+			continue
+		}
+		if hash != s.repoFormHash(file) {
+			conflicts = append(conflicts, file.RelPath)
+		}
+	}
+
+	sort.Strings(conflicts)
+	return conflicts, nil
+}
+
+// directAdoptionConflicts is AdoptionConflicts for the direct layout,
+// whose clone leaves local files in place as uncommitted changes
+func (s *Syncer) directAdoptionConflicts() ([]string, error) {
+	changes, err := s.repo.WorktreeChanges()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list changed files: %w", err)
+	}
+
+	var conflicts []string
+	for _, change := range changes {
+		if change.Status == git.StatusModified {
+			conflicts = append(conflicts, filepath.FromSlash(change.Path))
+		}
+	}
+
+	sort.Strings(conflicts)
+	return conflicts, nil
+}
+
+// Mergeable reports whether Adopt can merge a file: JSON config files and
+// files merged with the union driver, such as AGENTS.md
+func (s *Syncer) Mergeable(relPath string) bool {
+	return isJSONFile(relPath) || s.unionMerged(relPath)
+}
+
+// Adopt settles a file AdoptionConflicts returned in the sync repo's
+// working tree. Files kept or merged are committed by CommitAdopted and
+// then applied like the rest of the repo. A merge that finds conflicting
+// values returns ErrAdoptConflict and changes nothing.
+func (s *Syncer) Adopt(relPath, choice string) error {
+	repoPath := filepath.Join(s.paths.SyncRepoDir(), relPath)
+
+	switch choice {
+	case AdoptRemote:
+		return nil
+	case AdoptLocal:
+		// In the direct layout the local file is the working tree
+		if s.Direct() {
+			return nil
+		}
+		if err := s.copyFile(s.localPath(relPath), repoPath); err != nil {
+			return fmt.Errorf("failed to copy %s: %w", relPath, err)
+		}
+		return nil
+	case AdoptMerge:
+	default:
+		return fmt.Errorf("unknown adoption choice %q", choice)
+	}
+
+	local, remote, err := s.adoptionSides(relPath)
+	if err != nil {
+		return err
+	}
+
+	var merged []byte
+	switch {
+	case isJSONFile(relPath):
+		var ok bool
+		merged, ok, err = MergeJSON(nil, local, remote, s.cfg.Sync.ArrayMergeKeys())
+		if err != nil {
+			return fmt.Errorf("failed to merge %s: %w", relPath, err)
+		}
+		if !ok {
+			return fmt.Errorf("failed to merge %s: %w", relPath, ErrAdoptConflict)
+		}
+		if s.canonicalizes(repoPath) {
+			if canonical, err := canonicalJSON(merged); err == nil {
+				merged = canonical
+			}
+		}
+	case s.unionMerged(relPath):
+		merged, err = unionMerge(local, remote)
+		if err != nil {
+			return fmt.Errorf("failed to merge %s: %w", relPath, err)
+		}
+	default:
+		return fmt.Errorf("failed to merge %s: only JSON config files and union-merged files can be merged", relPath)
+	}
+
+	mode := os.FileMode(0644)
+	if info, err := s.fs.Stat(repoPath); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := util.WriteFile(s.fs, repoPath, merged, mode); err != nil {
+		return fmt.Errorf("failed to write %s: %w", relPath, err)
+	}
+	return nil
+}
+
+// adoptionSides returns the local and the cloned content of a file
+func (s *Syncer) adoptionSides(relPath string) ([]byte, []byte, error) {
+	repoPath := filepath.Join(s.paths.SyncRepoDir(), relPath)
+
+	if s.Direct() {
+		local, err := s.readFile(repoPath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s: %w", relPath, err)
+		}
+		remote, err := s.repo.ReadFileAt("HEAD", filepath.ToSlash(relPath))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s at HEAD: %w", relPath, err)
+		}
+		return local, remote, nil
+	}
+
+	local, err := s.readFile(s.localPath(relPath))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %w", relPath, err)
+	}
+	remote, err := s.readFile(repoPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %w", relPath, err)
+	}
+	return local, remote, nil
+}
+
+// unionMerge merges two versions of a file without a common ancestor,
+// keeping the lines they share once and, where they differ, the local
+// lines followed by the cloned ones
+func unionMerge(local, remote []byte) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "opencode-sync-adopt-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var files [3]string
+	for i, data := range [][]byte{local, nil, remote} {
+		files[i] = filepath.Join(dir, fmt.Sprintf("%d", i))
+		if err := os.WriteFile(files[i], data, 0600); err != nil {
+			return nil, fmt.Errorf("failed to write temporary file: %w", err)
+		}
+	}
+
+	return git.MergeFileUnion(files[0], files[1], files[2])
+}
+
+// CommitAdopted commits the files kept or merged by Adopt, after checking
+// them for credentials
+func (s *Syncer) CommitAdopted(relPaths []string) error {
+	if len(relPaths) == 0 {
+		return nil
+	}
+
+	if err := s.checkSecrets(relPaths); err != nil {
+		return err
+	}
+
+	slashed := make([]string, len(relPaths))
+	for i, relPath := range relPaths {
+		slashed[i] = filepath.ToSlash(relPath)
+	}
+	if err := s.repo.Add(slashed); err != nil {
+		return err
+	}
+
+	// A merge may come out the same as the repo's copy
+	if staged, _, err := s.repo.StagedChanges(); err == nil && len(staged) == 0 {
+		return nil
+	}
+
+	return s.repo.Commit(CommitMessage(fmt.Sprintf("Adopt local config from %s", Hostname())))
+}
@@ -0,0 +1,116 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/GareArc/opencode-sync/internal/git"
+)
+
+// AuditEntry is one change to one synced file
+type AuditEntry struct {
+	Time   time.Time `json:"time"`
+	Commit string    `json:"commit"`
+	Author string    `json:"author"`
+	Email  string    `json:"email"`
+	Host   string    `json:"host"`
+
+	// Change is added, modified, deleted or renamed
+	Change string `json:"change"`
+
+	// Path is the repo path, without the .age suffix of encrypted files
+	Path      string `json:"path"`
+	Encrypted bool   `json:"encrypted"`
+
+	// OldPath is the path a renamed file had before, without its .age
+	// suffix
+	OldPath string `json:"oldPath,omitempty"`
+}
+
+// AuditFilter limits an audit to matching paths and a time range. Zero
+// values leave the filter open.
+type AuditFilter struct {
+	// Paths are patterns matched like sync.hostSecrets against the path
+	// with and without its .age suffix
+	Paths []string
+
+	Since time.Time
+	Until time.Time
+}
+
+// Audit reconstructs from the history of the active channel who changed
+// which synced file when, oldest first. Who is the commit author and the
+// machine recorded in its Host trailer. Encrypted files are reported by
+// name; their content is never read.
+//
+// Clones are shallow, and the commit a shallow history starts at would
+// show every file as added, so the full history is fetched first.
+func Audit(ctx context.Context, repo *git.BuiltinGit, filter AuditFilter) ([]AuditEntry, error) {
+	shallow, err := repo.IsShallow()
+	if err != nil {
+		return nil, err
+	}
+	if shallow {
+		if err := repo.Unshallow(ctx); err != nil {
+			return nil, fmt.Errorf("the sync repo is a shallow clone, and fetching its full history failed: %w", err)
+		}
+	}
+
+	records, err := repo.History(filter.Since, filter.Until)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []AuditEntry
+	for _, record := range records {
+		host := CommitHost(record.Message)
+		for _, change := range record.Changes {
+			if auditSkipped(change.Path) {
+				continue
+			}
+
+			name, encrypted := strings.CutSuffix(change.Path, ".age")
+			oldName := strings.TrimSuffix(change.OldPath, ".age")
+			if len(filter.Paths) > 0 && !auditMatches(filter.Paths, change.Path, name) &&
+				(change.OldPath == "" || !auditMatches(filter.Paths, change.OldPath, oldName)) {
+				continue
+			}
+
+			entries = append(entries, AuditEntry{
+				Time:      record.Timestamp,
+				Commit:    record.Hash[:min(7, len(record.Hash))],
+				Author:    record.Author,
+				Email:     record.Email,
+				Host:      host,
+				Change:    change.Status.String(),
+				Path:      name,
+				Encrypted: encrypted,
+				OldPath:   oldName,
+			})
+		}
+	}
+
+	return entries, nil
+}
+
+// auditMatches reports whether a repo path, or its name without the .age
+// suffix, matches one of the patterns
+func auditMatches(patterns []string, relPath, name string) bool {
+	return matchPathPatterns(patterns, relPath) || matchPathPatterns(patterns, name)
+}
+
+// auditSkipped reports whether a repo path is bookkeeping rather than a
+// synced file
+func auditSkipped(relPath string) bool {
+	if _, ok := repoGitFiles[relPath]; ok {
+		return true
+	}
+	switch relPath {
+	case metadataFile, recipientsFile, manifestFile, ".gitmodules":
+		return true
+	}
+	return strings.HasPrefix(relPath, hostsDir+"/") && path.Base(relPath) == hostRecipientFile
+}
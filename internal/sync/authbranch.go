@@ -0,0 +1,176 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/GareArc/opencode-sync/internal/config"
+	"github.com/GareArc/opencode-sync/internal/git"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// AuthBranch holds the latest encrypted auth files when sync.authHistory
+// is "latest". It has a single commit that is replaced on every change,
+// so old ciphertexts do not accumulate in history.
+const AuthBranch = "opencode-sync-auth"
+
+// authArtifact is an encrypted auth file and its local source
+type authArtifact struct {
+	name      string
+	relPath   string
+	localPath string
+}
+
+// branchPath is where the file is stored on AuthBranch, which holds its
+// files at the root whatever the repo's layout
+func (a authArtifact) branchPath() string {
+	return filepath.Base(a.relPath)
+}
+
+// authArtifacts returns the auth files enabled for syncing
+func (s *Syncer) authArtifacts() []authArtifact {
+	var artifacts []authArtifact
+	if s.cfg.Sync.IncludeAuth {
+		artifacts = append(artifacts, authArtifact{"auth.json", authRepoFile, s.paths.OpenCodeAuthFile()})
+	}
+	if s.cfg.Sync.IncludeMcpAuth {
+		artifacts = append(artifacts, authArtifact{"mcp-auth.json", mcpAuthRepoFile, s.paths.OpenCodeMcpAuthFile()})
+	}
+	return artifacts
+}
+
+// authOnBranch reports whether auth files are kept on AuthBranch
+func (s *Syncer) authOnBranch() bool {
+	return s.cfg.Sync.AuthHistory == config.AuthHistoryLatest
+}
+
+// writeAuthBranch encrypts the enabled auth files onto AuthBranch and
+// removes them from the sync branch. Unchanged files keep their existing
+// ciphertext, and the branch is only replaced when something changed.
+func (s *Syncer) writeAuthBranch() error {
+	rev := "refs/heads/" + AuthBranch
+	if _, err := s.repo.RevParse(rev); err != nil {
+		rev = ""
+	}
+
+	files := map[string][]byte{}
+	changed := false
+	for _, a := range s.authArtifacts() {
+		// Files outside SetOnly keep their current version
+		if !s.onlyMatches(a.relPath) {
+			if rev != "" {
+				if existing, err := s.repo.ReadFileAt(rev, a.branchPath()); err == nil {
+					files[a.branchPath()] = existing
+				}
+			}
+			continue
+		}
+
+		// Stop committing the file to the sync branch
+		repoPath := filepath.Join(s.paths.SyncRepoDir(), a.relPath)
+		if err := s.fs.Remove(repoPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s from the sync branch: %w", a.relPath, err)
+		}
+
+		plaintext, err := s.readFile(a.localPath)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", a.name, err)
+		}
+
+		if rev != "" {
+			if existing, err := s.repo.ReadFileAt(rev, a.branchPath()); err == nil {
+				if decrypted, err := s.encryption.Decrypt(existing); err == nil && bytes.Equal(decrypted, plaintext) {
+					files[a.branchPath()] = existing
+					continue
+				}
+			}
+		}
+
+		ciphertext, err := s.encryptVerified(a.name, plaintext)
+		if err != nil {
+			return err
+		}
+		files[a.branchPath()] = ciphertext
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	msg := CommitMessage(fmt.Sprintf("Update encrypted auth files from %s", Hostname()))
+	if err := s.repo.ReplaceBranch(AuthBranch, files, msg); err != nil {
+		return fmt.Errorf("failed to update %s: %w", AuthBranch, err)
+	}
+
+	return nil
+}
+
+// fetchAuthBranch updates AuthBranch from origin. A remote without the
+// branch is not an error.
+func (s *Syncer) fetchAuthBranch(ctx context.Context) error {
+	if _, err := s.repo.GetRemoteURL("origin"); err != nil {
+		return nil
+	}
+
+	if err := s.repo.FetchBranch(ctx, AuthBranch); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to fetch %s: %w", AuthBranch, err)
+	}
+
+	return nil
+}
+
+// copyAuthFromBranch decrypts the enabled auth files from AuthBranch
+func (s *Syncer) copyAuthFromBranch() error {
+	rev := "refs/heads/" + AuthBranch
+	if _, err := s.repo.RevParse(rev); err != nil {
+		return nil
+	}
+
+	for _, a := range s.authArtifacts() {
+		if !s.onlyMatches(a.relPath) {
+			continue
+		}
+
+		ciphertext, err := s.repo.ReadFileAt(rev, a.branchPath())
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		plaintext, err := s.encryption.Decrypt(ciphertext)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt %s: %w", a.name, err)
+		}
+
+		if err := s.fs.MkdirAll(filepath.Dir(a.localPath), 0755); err != nil {
+			return err
+		}
+		if err := util.WriteFile(s.fs, a.localPath, plaintext, 0600); err != nil {
+			return fmt.Errorf("failed to write %s: %w", a.name, err)
+		}
+	}
+
+	return nil
+}
+
+// AuthBranchPending reports whether AuthBranch has a local version that
+// origin does not have yet
+func AuthBranchPending(repo git.Repository) bool {
+	local, err := repo.RevParse("refs/heads/" + AuthBranch)
+	if err != nil {
+		return false
+	}
+
+	remote, err := repo.RevParse("refs/remotes/origin/" + AuthBranch)
+	return err != nil || remote != local
+}
@@ -0,0 +1,229 @@
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// maxBackups is the number of pre-pull backups kept in the backups directory
+const maxBackups = 10
+
+// Backup describes a snapshot of local files taken before they are
+// overwritten by a pull
+type Backup struct {
+	// Dir is the backup directory
+	Dir string `json:"-"`
+
+	// Time is when the backup was taken
+	Time time.Time `json:"time"`
+
+	// Files are repo-relative paths whose local copy was saved
+	Files []string `json:"files"`
+
+	// Created are repo-relative paths that did not exist locally
+	// and will be removed on restore
+	Created []string `json:"created"`
+}
+
+const backupManifest = "manifest.json"
+
+// BackupLocal saves every local file that CopyFromRepo would overwrite
+// into a new directory under the backups directory
+func (s *Syncer) BackupLocal() (*Backup, error) {
+	defer s.timings.Start(PhaseBackup)()
+
+	relPaths, err := s.overwrittenFiles()
+	if err != nil {
+		return nil, err
+	}
+	return s.backupPaths(relPaths)
+}
+
+// overwrittenFiles returns the repo paths whose local files CopyFromRepo
+// overwrites
+func (s *Syncer) overwrittenFiles() ([]string, error) {
+	relPaths, err := s.repoFiles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list repo files: %w", err)
+	}
+
+	// Auth files kept on their own branch are overwritten too
+	if s.authOnBranch() {
+		for _, a := range s.authArtifacts() {
+			if !slices.Contains(relPaths, a.relPath) {
+				relPaths = append(relPaths, a.relPath)
+			}
+		}
+	}
+
+	// So are this machine's secrets
+	secrets, err := s.hostSecretFiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, relPath := range secrets {
+		if !slices.Contains(relPaths, relPath) {
+			relPaths = append(relPaths, relPath)
+		}
+	}
+
+	// Renamed files are moved away from their old path
+	for _, change := range s.renames {
+		if !slices.Contains(relPaths, change.OldPath) {
+			relPaths = append(relPaths, change.OldPath)
+		}
+	}
+
+	return relPaths, nil
+}
+
+// BackupLocalSince is BackupLocal for a sync repo that moved from oldRev
+// to HEAD: it also saves the local files RemoveDropped(oldRev) deletes
+func (s *Syncer) BackupLocalSince(oldRev string) (*Backup, error) {
+	defer s.timings.Start(PhaseBackup)()
+
+	relPaths, err := s.overwrittenFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	if !s.Direct() {
+		dropped, err := s.droppedFiles(oldRev)
+		if err != nil {
+			return nil, err
+		}
+		for _, relPath := range dropped {
+			if !slices.Contains(relPaths, relPath) {
+				relPaths = append(relPaths, relPath)
+			}
+		}
+	}
+
+	return s.backupPaths(relPaths)
+}
+
+// backupPaths saves the local files of repo paths into a new directory
+// under the backups directory
+func (s *Syncer) backupPaths(relPaths []string) (*Backup, error) {
+	now := s.clock.Now()
+	backup := &Backup{
+		Dir:     filepath.Join(s.paths.BackupsDir(), now.Format("20060102-150405.000")),
+		Time:    now,
+		Files:   []string{},
+		Created: []string{},
+	}
+
+	if err := s.fs.MkdirAll(backup.Dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	for _, relPath := range relPaths {
+		dstPath := s.localPath(relPath)
+		if dstPath == "" || !s.onlyMatches(relPath) {
+			continue
+		}
+
+		if _, err := s.fs.Stat(dstPath); os.IsNotExist(err) {
+			backup.Created = append(backup.Created, relPath)
+			continue
+		}
+
+		if err := s.copyFile(dstPath, filepath.Join(backup.Dir, "files", relPath)); err != nil {
+			return nil, fmt.Errorf("failed to back up %s: %w", relPath, err)
+		}
+		backup.Files = append(backup.Files, relPath)
+	}
+
+	data, err := json.MarshalIndent(backup, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal backup manifest: %w", err)
+	}
+
+	if err := util.WriteFile(s.fs, filepath.Join(backup.Dir, backupManifest), data, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write backup manifest: %w", err)
+	}
+
+	if err := s.pruneBackups(); err != nil {
+		return nil, err
+	}
+
+	return backup, nil
+}
+
+// LoadBackup reads the backup stored in dir
+func (s *Syncer) LoadBackup(dir string) (*Backup, error) {
+	data, err := util.ReadFile(s.fs, filepath.Join(dir, backupManifest))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup manifest: %w", err)
+	}
+
+	var backup Backup
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return nil, fmt.Errorf("failed to parse backup manifest: %w", err)
+	}
+	backup.Dir = dir
+
+	return &backup, nil
+}
+
+// RestoreBackup copies backed up files back to their local destinations
+// and removes files that the pull created
+func (s *Syncer) RestoreBackup(backup *Backup) error {
+	for _, relPath := range backup.Files {
+		dstPath := s.localPath(relPath)
+		if dstPath == "" {
+			continue
+		}
+
+		if err := s.copyFile(filepath.Join(backup.Dir, "files", relPath), dstPath); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", relPath, err)
+		}
+	}
+
+	for _, relPath := range backup.Created {
+		dstPath := s.localPath(relPath)
+		if dstPath == "" {
+			continue
+		}
+
+		if err := s.fs.Remove(dstPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", relPath, err)
+		}
+	}
+
+	return nil
+}
+
+// pruneBackups removes the oldest backups beyond maxBackups
+func (s *Syncer) pruneBackups() error {
+	entries, err := s.fs.ReadDir(s.paths.BackupsDir())
+	if err != nil {
+		return fmt.Errorf("failed to read backups directory: %w", err)
+	}
+
+	var dirs []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			dirs = append(dirs, entry.Name())
+		}
+	}
+
+	// Names are timestamps, so lexical order is chronological
+	sort.Strings(dirs)
+
+	for len(dirs) > maxBackups {
+		if err := util.RemoveAll(s.fs, filepath.Join(s.paths.BackupsDir(), dirs[0])); err != nil {
+			return fmt.Errorf("failed to remove old backup: %w", err)
+		}
+		dirs = dirs[1:]
+	}
+
+	return nil
+}
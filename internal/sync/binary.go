@@ -0,0 +1,72 @@
+package sync
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// binarySniffLen is how much of a file is inspected for binary content,
+// the same amount git looks at
+const binarySniffLen = 8000
+
+// isBinary reports whether a file looks binary: it contains a NUL byte
+// near the start. Unreadable files are treated as text.
+func (s *Syncer) isBinary(path string) bool {
+	f, err := s.fs.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	buf := make([]byte, binarySniffLen)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false
+	}
+
+	return bytes.IndexByte(buf[:n], 0) >= 0
+}
+
+// binaryAllowed reports whether a binary file at relPath may be synced,
+// either because sync.includeBinaries is set or because it matches a
+// sync.binaryAllow pattern
+func (s *Syncer) binaryAllowed(relPath string) bool {
+	if s.cfg.Sync.IncludeBinaries {
+		return true
+	}
+
+	return matchPathPatterns(s.cfg.Sync.BinaryAllow, relPath)
+}
+
+// matchPathPatterns reports whether relPath matches one of the patterns:
+// a file name glob, a repo path glob or a repo directory prefix
+func matchPathPatterns(patterns []string, relPath string) bool {
+	slashed := filepath.ToSlash(relPath)
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, filepath.Base(relPath)); matched {
+			return true
+		}
+		if matched, _ := filepath.Match(pattern, slashed); matched {
+			return true
+		}
+		if strings.HasPrefix(slashed, strings.TrimSuffix(pattern, "/")+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// skipBinary reports whether the local file at path, stored at relPath
+// in the repo, is a binary that is not allowed and must not be synced
+func (s *Syncer) skipBinary(path, relPath string) bool {
+	return !s.binaryAllowed(relPath) && s.isBinary(path)
+}
+
+// SkippedBinaries returns the repo-relative paths of binary files the
+// last CopyToRepo left out
+func (s *Syncer) SkippedBinaries() []string {
+	return s.skippedBinaries
+}
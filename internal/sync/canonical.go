@@ -0,0 +1,67 @@
+package sync
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/GareArc/opencode-sync/internal/jsonc"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// isJSONFile reports whether path is a JSON or JSONC file
+func isJSONFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".json" || ext == ".jsonc"
+}
+
+// canonicalJSON rewrites JSON or JSONC with sorted keys and two-space
+// indentation, so files that only differ in key order or whitespace are
+// identical. Comments are kept with the members they precede.
+func canonicalJSON(data []byte) ([]byte, error) {
+	doc, err := jsonc.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	doc.Value.SortKeys()
+	return jsonc.Format(doc), nil
+}
+
+// copyCanonical writes the canonical form of the JSON file src to dst in
+// the repo. It returns false, leaving dst alone, if src cannot be parsed
+// or is too large to, so the caller copies it unchanged.
+func (s *Syncer) copyCanonical(src, dst string) (bool, error) {
+	data, err := s.readFile(src)
+	if tooLarge(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read source: %w", err)
+	}
+
+	canonical, err := canonicalJSON(data)
+	if err != nil {
+		return false, nil
+	}
+
+	info, err := s.fs.Stat(src)
+	if err != nil {
+		return false, fmt.Errorf("failed to stat source: %w", err)
+	}
+	if err := util.WriteFile(s.fs, dst, canonical, info.Mode().Perm()); err != nil {
+		return false, fmt.Errorf("failed to write %s: %w", dst, err)
+	}
+	if err := s.chmod(dst, info.Mode()); err != nil {
+		return false, fmt.Errorf("failed to set mode: %w", err)
+	}
+
+	s.stats.Copied++
+	return true, nil
+}
+
+// canonicalizes reports whether a file copied to dst is canonicalized
+func (s *Syncer) canonicalizes(dst string) bool {
+	return s.cfg.Sync.CanonicalJSON && isJSONFile(dst) &&
+		strings.HasPrefix(dst, s.paths.SyncRepoDir()+string(filepath.Separator))
+}
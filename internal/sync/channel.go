@@ -0,0 +1,60 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+)
+
+// RemoveDropped deletes the local copies of files that were tracked at
+// oldRev but are not at HEAD, such as files only the previous channel
+// had. Files that hold credentials or have no local destination are left
+// alone. The direct layout needs nothing, since checking out removes them.
+func (s *Syncer) RemoveDropped(oldRev string) ([]string, error) {
+	if s.Direct() || oldRev == "" {
+		return nil, nil
+	}
+
+	dropped, err := s.droppedFiles(oldRev)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	for _, relPath := range dropped {
+		local := s.localPath(relPath)
+		if local == "" {
+			continue
+		}
+		if err := s.fs.Remove(local); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return removed, fmt.Errorf("failed to remove %s: %w", local, err)
+		}
+		removed = append(removed, local)
+	}
+
+	return removed, nil
+}
+
+// droppedFiles returns the repo paths RemoveDropped deletes the local
+// copies of
+func (s *Syncer) droppedFiles(oldRev string) ([]string, error) {
+	deleted, err := s.repo.DeletedFiles(oldRev, "HEAD")
+	if err != nil {
+		return nil, err
+	}
+
+	var dropped []string
+	for _, relPath := range deleted {
+		if IsTemplateSecret(relPath) || s.shouldExclude(relPath) {
+			continue
+		}
+		if _, ok := repoGitFiles[relPath]; ok {
+			continue
+		}
+		dropped = append(dropped, relPath)
+	}
+
+	return dropped, nil
+}
@@ -0,0 +1,138 @@
+package sync
+
+import (
+	"bytes"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/GareArc/opencode-sync/internal/git"
+)
+
+// volatileDirs are directory names whose contents are regenerated by the
+// tools that own them
+var volatileDirs = []string{
+	"cache", ".cache", "tmp", ".tmp", "temp", "logs", "node_modules",
+	"__pycache__", ".turbo", ".next", "dist", "build",
+}
+
+// volatileFiles are file name patterns for lockfiles, logs, databases and
+// editor or OS droppings
+var volatileFiles = []string{
+	"*.lock", "*.lockb", "*-lock.json", "*-lock.yaml", "*.log", "*.tmp",
+	"*.pid", "*.db", "*.db-*", "*.sqlite", "*.sqlite-*", "*.swp", "*~",
+	".DS_Store", "Thumbs.db",
+}
+
+// ChurnFile is a file that changed in most recent syncs
+type ChurnFile struct {
+	// Path is the repo path
+	Path string
+
+	// Changes is how many of the examined syncs changed it
+	Changes int
+
+	// Reason says why the changes look meaningless, "" if they may matter
+	Reason string
+
+	// Pattern is the sync.exclude pattern that would stop the churn, ""
+	// when the file should be reviewed by hand rather than excluded
+	Pattern string
+}
+
+// Churn examines the last window sync commits and returns the files
+// changed by more than threshold of them, most frequent first, along with
+// the number of sync commits found.
+func Churn(repo *git.BuiltinGit, window, threshold int) ([]ChurnFile, int, error) {
+	commits, err := repo.RecentChanges(syncSubject, window)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	counts := map[string]int{}
+	lastChange := map[string]string{}
+	for _, commit := range commits {
+		for _, p := range commit.Paths {
+			counts[p]++
+			if _, ok := lastChange[p]; !ok {
+				lastChange[p] = commit.Hash
+			}
+		}
+	}
+
+	var churn []ChurnFile
+	for p, n := range counts {
+		// Encrypted files only change with their content, and hosts/ is
+		// managed by the hosts command
+		if n <= threshold || strings.HasSuffix(p, ".age") || strings.HasPrefix(p, hostsDir+"/") {
+			continue
+		}
+
+		file := ChurnFile{Path: p, Changes: n}
+		file.Reason, file.Pattern = volatility(repo, p, lastChange[p])
+		churn = append(churn, file)
+	}
+
+	sort.Slice(churn, func(i, j int) bool {
+		if churn[i].Changes != churn[j].Changes {
+			return churn[i].Changes > churn[j].Changes
+		}
+		return churn[i].Path < churn[j].Path
+	})
+
+	return churn, len(commits), nil
+}
+
+// volatility explains why changes to the file at repo path p look
+// meaningless and suggests an exclude pattern. Top-level files such as
+// opencode.json are never suggested for exclusion.
+func volatility(repo *git.BuiltinGit, p, lastChange string) (reason, pattern string) {
+	dirs := strings.Split(path.Dir(p), "/")
+	for _, dir := range dirs {
+		for _, volatile := range volatileDirs {
+			if dir == volatile {
+				return "inside a " + dir + " directory", dir
+			}
+		}
+	}
+
+	name := path.Base(p)
+	for _, volatile := range volatileFiles {
+		if matched, _ := path.Match(volatile, name); matched {
+			return "lockfile, log or database", volatile
+		}
+	}
+
+	if numbersOnlyChange(repo, p, lastChange) {
+		if !strings.Contains(p, "/") {
+			return "only numbers or timestamps changed", ""
+		}
+		return "only numbers or timestamps changed", p
+	}
+
+	return "", ""
+}
+
+// numbersOnlyChange reports whether the commit changed the file at p only
+// in its digits, as with timestamps, counters and cache keys
+func numbersOnlyChange(repo *git.BuiltinGit, p, commit string) bool {
+	after, err := repo.ReadFileAt(commit, p)
+	if err != nil {
+		return false
+	}
+	before, err := repo.ReadFileAt(commit+"^", p)
+	if err != nil || bytes.Equal(before, after) {
+		return false
+	}
+
+	stripDigits := func(data []byte) []byte {
+		return bytes.Map(func(r rune) rune {
+			if r >= '0' && r <= '9' {
+				return -1
+			}
+			return r
+		}, data)
+	}
+
+	return bytes.Equal(stripDigits(before), stripDigits(after))
+}
@@ -0,0 +1,60 @@
+package sync
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/GareArc/opencode-sync/internal/config"
+	"github.com/GareArc/opencode-sync/internal/paths"
+)
+
+// claudeTarget is a Claude Code path and where it is stored in the repo
+type claudeTarget struct {
+	name    string
+	relPath string
+	local   func(p *paths.Paths) string
+}
+
+// claudeTargets are the Claude Code paths that can be synced. Skills keep
+// their original claude-skills/ directory; the rest live under claude/.
+var claudeTargets = []claudeTarget{
+	{config.ClaudeSkills, "claude-skills", func(p *paths.Paths) string { return p.ClaudeSkillsDir }},
+	{config.ClaudeCommands, filepath.Join("claude", "commands"), (*paths.Paths).ClaudeCommandsDir},
+	{config.ClaudeSettings, filepath.Join("claude", "settings.json"), (*paths.Paths).ClaudeSettingsFile},
+	{config.ClaudeMemory, filepath.Join("claude", "CLAUDE.md"), (*paths.Paths).ClaudeMemoryFile},
+}
+
+// claudeSources returns the Claude Code paths synced on this machine
+func (s *Syncer) claudeSources() []syncSource {
+	enabled := s.cfg.Claude.Enabled()
+
+	var sources []syncSource
+	for _, target := range claudeTargets {
+		if slices.Contains(enabled, target.name) {
+			sources = append(sources, syncSource{LocalPath: target.local(s.paths), RelPath: target.relPath})
+		}
+	}
+	return sources
+}
+
+// claudeLocalPath maps a repo path under claude-skills/ or claude/ to its
+// destination on this machine. ok is false for other paths; the path is
+// empty for Claude Code paths not synced here.
+func (s *Syncer) claudeLocalPath(relPath string) (local string, ok bool) {
+	sep := string(filepath.Separator)
+	if top, _, _ := strings.Cut(relPath, sep); top != "claude-skills" && top != "claude" {
+		return "", false
+	}
+
+	for _, source := range s.claudeSources() {
+		if relPath == source.RelPath {
+			return source.LocalPath, true
+		}
+		if rel, found := strings.CutPrefix(relPath, source.RelPath+sep); found {
+			return filepath.Join(source.LocalPath, rel), true
+		}
+	}
+
+	return "", true
+}
@@ -0,0 +1,105 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/GareArc/opencode-sync/internal/git"
+)
+
+// Hostname returns this machine's hostname, or "unknown"
+func Hostname() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "unknown"
+	}
+	return hostname
+}
+
+// hostTrailer is the commit trailer recording which machine made a commit
+const hostTrailer = "Host: "
+
+// legacyHostSubject matches subjects written before the Host trailer existed
+var legacyHostSubject = regexp.MustCompile(`^(?:Sync|Initial commit|Link) from (\S+)`)
+
+// CommitMessage appends the Host trailer to a commit subject
+func CommitMessage(subject string) string {
+	return fmt.Sprintf("%s\n\n%s%s\n", subject, hostTrailer, Hostname())
+}
+
+// CommitHost returns the machine that made a commit, or "" if unknown
+func CommitHost(message string) string {
+	for _, line := range strings.Split(message, "\n") {
+		if host, ok := strings.CutPrefix(strings.TrimSpace(line), hostTrailer); ok {
+			return strings.TrimSpace(host)
+		}
+	}
+
+	if m := legacyHostSubject.FindStringSubmatch(message); m != nil {
+		return m[1]
+	}
+
+	return ""
+}
+
+// pushAuthBranch pushes a pending AuthBranch. The branch holds a single
+// replaced commit, so if another machine updated it first the auth files
+// must be pulled before this machine's version can be pushed.
+func pushAuthBranch(ctx context.Context, repo git.Repository) error {
+	if !AuthBranchPending(repo) {
+		return nil
+	}
+
+	err := repo.PushBranch(ctx, AuthBranch)
+	var rejected *git.RejectedError
+	if errors.As(err, &rejected) {
+		return fmt.Errorf("another machine updated the encrypted auth files; run 'opencode-sync pull' and push again: %w", err)
+	}
+	return err
+}
+
+// maxPushAttempts bounds how often a rejected push is rebased and retried
+const maxPushAttempts = 3
+
+// PushWithRetry pushes HEAD. If another machine pushed first, the local
+// commits are rebased onto the remote branch and the push is retried.
+// A pending AuthBranch is pushed afterwards.
+func PushWithRetry(ctx context.Context, repo git.Repository) error {
+	for attempt := 1; ; attempt++ {
+		err := repo.Push(ctx)
+		if err == nil {
+			return pushAuthBranch(ctx, repo)
+		}
+
+		var rejected *git.RejectedError
+		if !errors.As(err, &rejected) || attempt == maxPushAttempts {
+			return err
+		}
+
+		branch, err := repo.GetBranch()
+		if err != nil {
+			return err
+		}
+
+		if err := repo.Fetch(ctx); err != nil {
+			return err
+		}
+
+		// Another machine may have moved the repo past this build
+		if err := checkManifest(repo, "origin/"+branch); err != nil {
+			return err
+		}
+
+		if err := repo.Rebase("origin/" + branch); err != nil {
+			var conflict *git.ConflictError
+			if errors.As(err, &conflict) {
+				return fmt.Errorf("remote changes conflict with this push in %s: %w", strings.Join(conflict.Files, ", "), conflict)
+			}
+			return err
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package sync
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/GareArc/opencode-sync/internal/jsonc"
+	"github.com/GareArc/opencode-sync/internal/jsondiff"
+)
+
+// configEntryNames name the entries of opencode.json's objects keyed by
+// name, for describing them being added or removed
+var configEntryNames = map[string]string{
+	"agent":     "agent",
+	"mode":      "mode",
+	"command":   "command",
+	"provider":  "provider",
+	"mcp":       "MCP server",
+	"formatter": "formatter",
+	"lsp":       "language server",
+}
+
+// configListNames name the elements of opencode.json's lists
+var configListNames = map[string]string{
+	"plugin":             "plugin",
+	"instructions":       "instructions",
+	"disabled_providers": "disabled provider",
+	"enabled_providers":  "enabled provider",
+}
+
+// ConfigChanges are the changes to one JSON file, described setting by
+// setting
+type ConfigChanges struct {
+	// Path is the repo path of the file
+	Path string
+
+	Changes []string
+}
+
+// StructuralDiff reports whether DescribeChanges describes changes to a
+// repo file: JSON files other than session history
+func StructuralDiff(relPath string) bool {
+	return isJSONFile(relPath) && !isSessionFile(relPath)
+}
+
+// DescribeChanges describes what changed between two versions of a JSON
+// file, one line per setting, such as "provider.anthropic.model: a → b".
+// Entries of the OpenCode config are named for what they are, as in
+// "added MCP server 'github'". Both versions must parse.
+func DescribeChanges(relPath string, before, after []byte) ([]string, error) {
+	beforeValue, err := jsonc.Unmarshal(before)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", relPath, err)
+	}
+	afterValue, err := jsonc.Unmarshal(after)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", relPath, err)
+	}
+
+	schema := isMcpConfigFile(relPath)
+	var lines []string
+	for _, change := range jsondiff.Diff(beforeValue, afterValue) {
+		lines = append(lines, describeChange(change, schema))
+	}
+	return lines, nil
+}
+
+// describeChange writes one change as a line. With schema set the path is
+// in the OpenCode config, whose named entries and lists are called by
+// name.
+func describeChange(change jsondiff.Change, schema bool) string {
+	path := change.Path
+	value := change.New
+	if change.Kind == jsondiff.Removed {
+		value = change.Old
+	}
+
+	if schema && change.Kind != jsondiff.Changed {
+		switch {
+		case len(path) == 2 && configEntryNames[path[0]] != "":
+			return fmt.Sprintf("%s %s '%s'", change.Kind, configEntryNames[path[0]], path[1])
+		case len(path) == 1 && configListNames[path[0]] != "":
+			if _, ok := value.(string); ok {
+				return fmt.Sprintf("%s %s '%s'", change.Kind, configListNames[path[0]], value)
+			}
+		case len(path) == 4 && path[0] == "provider" && path[2] == "models":
+			preposition := "to"
+			if change.Kind == jsondiff.Removed {
+				preposition = "from"
+			}
+			return fmt.Sprintf("%s model '%s' %s provider '%s'", change.Kind, path[3], preposition, path[1])
+		}
+	}
+
+	name := jsondiff.FormatPath(path)
+	if name == "" {
+		name = "(whole file)"
+	}
+	switch change.Kind {
+	case jsondiff.Added:
+		return fmt.Sprintf("%s: added %s", name, jsondiff.FormatValue(value))
+	case jsondiff.Removed:
+		return fmt.Sprintf("%s: removed %s", name, jsondiff.FormatValue(value))
+	}
+	return fmt.Sprintf("%s: %s → %s", name, jsondiff.FormatValue(change.Old), jsondiff.FormatValue(change.New))
+}
+
+// IncomingConfigChanges describes, setting by setting, how applying the
+// sync repo would change the OpenCode config files. Locked files and
+// files that don't parse are left out; with sync.splitMcpSecrets the MCP
+// credentials kept out of the repo are not compared.
+func (s *Syncer) IncomingConfigChanges() ([]ConfigChanges, error) {
+	repoDir := s.paths.SyncRepoDir()
+
+	var result []ConfigChanges
+	for _, relPath := range validatedFiles {
+		if s.locked[relPath] {
+			continue
+		}
+		incoming, err := s.readFile(filepath.Join(repoDir, relPath))
+		if err != nil {
+			continue
+		}
+		local, err := s.readFile(s.localPath(relPath))
+		if err != nil {
+			continue
+		}
+		if s.cfg.Sync.SplitMcpSecrets && isMcpConfigFile(relPath) {
+			if redacted, _, err := splitMcpSecrets(local); err == nil {
+				local = redacted
+			}
+		}
+
+		changes, err := DescribeChanges(relPath, local, incoming)
+		if err != nil || len(changes) == 0 {
+			continue
+		}
+		result = append(result, ConfigChanges{Path: relPath, Changes: changes})
+	}
+
+	return result, nil
+}
@@ -0,0 +1,72 @@
+package sync
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/GareArc/opencode-sync/internal/config"
+	"github.com/GareArc/opencode-sync/internal/git"
+	"github.com/GareArc/opencode-sync/internal/paths"
+	"github.com/GareArc/opencode-sync/internal/snapshot"
+)
+
+// Configure applies the settings the git, snapshot and paths packages
+// keep in package variables: timeouts, repo.ssh.hostKeyPolicy,
+// repo.author, repo.compressionLevel and where the sync repo lives. It
+// must run before the sync repo is opened. exe is the opencode-sync
+// binary ssh runs to check a pinned host key.
+func Configure(cfg *config.Config, exe string) {
+	git.DefaultTimeout = cfg.Repo.Timeout()
+	git.MaxReadSize = cfg.Sync.MaxMemoryFileSize()
+	git.SSHCommand = SSHCommand(cfg, exe)
+	git.AuthorName, git.AuthorEmail = cfg.Repo.Author.For(Hostname())
+	snapshot.CompressionLevel = cfg.Repo.Compression()
+	paths.DirectLayout = cfg.Repo.Layout == config.LayoutDirect
+	paths.RepoSubdir = cfg.Repo.SubdirPath()
+}
+
+// SSHCommand returns the ssh command git runs to enforce
+// repo.ssh.hostKeyPolicy, or "" to leave the user's ssh configuration alone
+func SSHCommand(cfg *config.Config, exe string) string {
+	options := SSHOptions(cfg, exe)
+	if len(options) == 0 {
+		return ""
+	}
+
+	quoted := make([]string, len(options))
+	for i, option := range options {
+		quoted[i] = shellQuote(option)
+	}
+	return "ssh " + strings.Join(quoted, " ")
+}
+
+// SSHOptions returns the ssh arguments that enforce
+// repo.ssh.hostKeyPolicy, for git's ssh command and for sftp. The
+// fingerprint policy has ssh run exe to look up the pinned key, and is
+// left out without it.
+func SSHOptions(cfg *config.Config, exe string) []string {
+	switch cfg.Repo.SSH.HostKeyPolicy {
+	case config.HostKeyPolicyKnownHosts:
+		return []string{"-o", "StrictHostKeyChecking=yes"}
+	case config.HostKeyPolicyAcceptNew:
+		return []string{"-o", "StrictHostKeyChecking=accept-new"}
+	case config.HostKeyPolicyFingerprint:
+		if exe == "" {
+			return nil
+		}
+
+		// Only keys accepted by ssh-known-hosts are trusted
+		lookup := fmt.Sprintf(`KnownHostsCommand="%s" ssh-known-hosts %s %%H %%t %%K`, exe, cfg.Repo.SSH.Fingerprint)
+		return []string{"-o", "StrictHostKeyChecking=yes", "-o", "UserKnownHostsFile=/dev/null", "-o", "GlobalKnownHostsFile=/dev/null", "-o", lookup}
+	}
+
+	return nil
+}
+
+// shellQuote quotes s for the shell git runs its ssh command through
+func shellQuote(s string) string {
+	if !strings.ContainsAny(s, " \t'\"$`\\*?[]{}()&;|<>!#~") {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,50 @@
+package sync
+
+import (
+	"github.com/GareArc/opencode-sync/internal/config"
+)
+
+// CopyStats counts how files were copied by the last CopyToRepo or
+// CopyFromRepo
+type CopyStats struct {
+	// Reflinked files were cloned copy-on-write
+	Reflinked int
+
+	// Copied files had their content copied
+	Copied int
+}
+
+// CopyStats returns how files were copied by the last CopyToRepo or
+// CopyFromRepo
+func (s *Syncer) CopyStats() CopyStats {
+	return s.stats
+}
+
+// tryReflink clones src to dst when sync.copyMode allows it and the
+// filesystem supports it, preserving the file mode. It reports whether
+// the clone succeeded; otherwise the caller copies the content.
+func (s *Syncer) tryReflink(src, dst string) bool {
+	if s.cfg.Sync.CopyMode == config.CopyModeCopy {
+		return false
+	}
+
+	// Reflinks need real paths, not an in-memory filesystem
+	if _, ok := s.fs.(osFilesystem); !ok {
+		return false
+	}
+
+	info, err := s.fs.Stat(src)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+
+	if err := reflink(src, dst); err != nil {
+		return false
+	}
+	if err := s.chmod(dst, info.Mode()); err != nil {
+		return false
+	}
+
+	s.stats.Reflinked++
+	return true
+}
@@ -0,0 +1,127 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/GareArc/opencode-sync/internal/config"
+)
+
+// Direct reports whether the OpenCode config dir is the sync repo's
+// working tree (repo.layout "direct"). Files are then committed and
+// checked out in place instead of being copied.
+func (s *Syncer) Direct() bool {
+	return s.cfg.Repo.Layout == config.LayoutDirect
+}
+
+// DirectCheckout lists, as sparse checkout patterns, what the direct
+// layout checks out into the OpenCode config dir: everything except what
+// other machines store for destinations outside it
+func DirectCheckout() []string {
+	return []string{
+		"/*",
+		"!/claude-skills/",
+		"!/claude/",
+		"!/" + projectsDir + "/",
+		"!/" + hostsDir + "/",
+		"!/" + sessionsDir + "/",
+		"!/" + baselineDir + "/",
+		"!*.age",
+		"!/" + metadataFile,
+		"!/" + recipientsFile,
+		"!/" + manifestFile,
+	}
+}
+
+// SetSparseCheckout limits the sync repo's working tree to what this
+// machine syncs: sync.onlyDirs, or in the direct layout the paths that
+// belong in the OpenCode config dir
+func (s *Syncer) SetSparseCheckout() error {
+	if s.Direct() {
+		return s.repo.SetSparsePatterns(DirectCheckout())
+	}
+	return s.repo.SetSparseDirs(s.cfg.Sync.OnlyDirs)
+}
+
+// directExcludesHeader starts the ignore rules of the direct layout
+const directExcludesHeader = "# Generated by opencode-sync: only synced paths are tracked"
+
+// RemoveDirectExcludes deletes the ignore rules of the direct layout, which
+// would hide new files in the copy layout's working copy
+func (s *Syncer) RemoveDirectExcludes() error {
+	path := filepath.Join(s.paths.SyncGitDir(), "info", "exclude")
+	data, err := os.ReadFile(path)
+	if err != nil || !strings.HasPrefix(string(data), directExcludesHeader) {
+		return nil
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", path, err)
+	}
+	return nil
+}
+
+// writeDirectExcludes makes git ignore everything in the OpenCode config
+// dir except the synced paths, minus credential files and sync.exclude.
+// The rules go into the repository's info/exclude, so the shared
+// .gitignore is not changed.
+func (s *Syncer) writeDirectExcludes() error {
+	lines := []string{
+		directExcludesHeader,
+		"/*",
+	}
+	for name := range repoGitFiles {
+		lines = append(lines, "!/"+name)
+	}
+	for _, srcPath := range s.paths.SyncableOpenCodePaths() {
+		lines = append(lines, "!/"+filepath.Base(srcPath))
+	}
+	sort.Strings(lines[2:])
+	lines = append(lines, "# Credentials are never tracked, even in synced directories")
+	lines = append(lines, secretFilePatterns...)
+	lines = append(lines, s.cfg.Sync.Exclude...)
+	if rules := s.syncIgnoreRules().rules; len(rules) > 0 {
+		lines = append(lines, "# From .syncignore files")
+		lines = append(lines, rules...)
+	}
+
+	path := filepath.Join(s.paths.SyncGitDir(), "info", "exclude")
+	content := strings.Join(lines, "\n") + "\n"
+	if existing, err := os.ReadFile(path); err == nil && string(existing) == content {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// directChanges returns the synced files in the OpenCode config dir that
+// differ from HEAD
+func (s *Syncer) directChanges() ([]string, error) {
+	if err := s.writeDirectExcludes(); err != nil {
+		return nil, err
+	}
+
+	status, err := s.repo.Status()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get status: %w", err)
+	}
+
+	var changes []string
+	for _, files := range [][]string{status.UntrackedFiles, status.ModifiedFiles, status.StagedFiles} {
+		for _, file := range files {
+			changes = append(changes, filepath.FromSlash(file))
+		}
+	}
+
+	sort.Strings(changes)
+	return changes, nil
+}
@@ -0,0 +1,200 @@
+package sync
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// baselineDir holds the config a team expects every machine to apply, laid
+// out like the rest of the repo. It is compared with, never copied to, the
+// local config.
+const baselineDir = "baseline"
+
+// Ways a local file can drift from the baseline
+const (
+	DriftModified = "modified"
+	DriftMissing  = "missing"
+	DriftExtra    = "extra"
+)
+
+// Drift is a local file that deviates from the baseline
+type Drift struct {
+	// RelPath is the path within the baseline, as a repo path
+	RelPath string
+
+	// LocalPath is where the file is applied on this machine
+	LocalPath string
+
+	// Kind is DriftModified, DriftMissing or DriftExtra
+	Kind string
+}
+
+// baselineFiles returns the files under baseline/ at HEAD, by repo path
+// without the baseline/ prefix. It returns nil if there is no baseline.
+func (s *Syncer) baselineFiles() (map[string]bool, error) {
+	files := map[string]bool{}
+
+	var walk func(dir string) error
+	walk = func(dir string) error {
+		entries, err := s.repo.ListTreeAt("HEAD", dir)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			p := path.Join(dir, entry.Name)
+			if entry.IsDir {
+				if err := walk(p); err != nil {
+					return err
+				}
+				continue
+			}
+			files[strings.TrimPrefix(p, baselineDir+"/")] = true
+		}
+		return nil
+	}
+
+	if err := walk(baselineDir); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", baselineDir, err)
+	}
+
+	return files, nil
+}
+
+// HasBaseline reports whether the repo has a committed baseline
+func (s *Syncer) HasBaseline() bool {
+	files, err := s.baselineFiles()
+	return err == nil && len(files) > 0
+}
+
+// Drift compares the applied local config with the baseline committed to
+// the repo. Baseline files that are missing or differ locally are
+// reported, and so are local files directly in the directories the
+// baseline has files in, such as agent/, that the baseline doesn't have.
+// JSON files are compared by content, ignoring formatting.
+func (s *Syncer) Drift() ([]Drift, error) {
+	files, err := s.baselineFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	var drift []Drift
+	dirs := map[string]bool{}
+	for slashed := range files {
+		relPath := filepath.FromSlash(slashed)
+		localPath := s.localPath(relPath)
+		if localPath == "" {
+			continue
+		}
+
+		if dir := filepath.Dir(relPath); dir != "." {
+			dirs[dir] = true
+		}
+
+		expected, err := s.repo.ReadFileAt("HEAD", baselineDir+"/"+slashed)
+		if err != nil {
+			return nil, err
+		}
+
+		actual, err := s.readFile(localPath)
+		switch {
+		case os.IsNotExist(err):
+			drift = append(drift, Drift{RelPath: relPath, LocalPath: localPath, Kind: DriftMissing})
+		case err != nil:
+			return nil, fmt.Errorf("failed to read %s: %w", localPath, err)
+		case !sameContent(relPath, expected, actual):
+			drift = append(drift, Drift{RelPath: relPath, LocalPath: localPath, Kind: DriftModified})
+		}
+	}
+
+	// Local files the baseline doesn't have, in the directories it covers
+	for dir := range dirs {
+		localDir := s.localPath(dir)
+		if localDir == "" {
+			continue
+		}
+
+		entries, err := s.fs.ReadDir(localDir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", localDir, err)
+		}
+
+		for _, entry := range entries {
+			relPath := filepath.Join(dir, entry.Name())
+			if !entry.IsDir() && !files[filepath.ToSlash(relPath)] && !s.shouldExclude(relPath) {
+				drift = append(drift, Drift{RelPath: relPath, LocalPath: filepath.Join(localDir, entry.Name()), Kind: DriftExtra})
+			}
+		}
+	}
+
+	sort.Slice(drift, func(i, j int) bool {
+		return drift[i].RelPath < drift[j].RelPath
+	})
+
+	return drift, nil
+}
+
+// sameContent reports whether two versions of a file are the same. JSON
+// files are compared in canonical form.
+func sameContent(relPath string, a, b []byte) bool {
+	if bytes.Equal(a, b) {
+		return true
+	}
+
+	if !slices.Contains([]string{".json", ".jsonc"}, filepath.Ext(relPath)) {
+		return false
+	}
+	ca, errA := canonicalJSON(a)
+	cb, errB := canonicalJSON(b)
+	return errA == nil && errB == nil && bytes.Equal(ca, cb)
+}
+
+// EnforceBaseline resets drifting local files to the baseline: missing
+// and modified files are written from it and extra files removed. The
+// local files are backed up first, and the backup is returned.
+func (s *Syncer) EnforceBaseline(drift []Drift) (*Backup, error) {
+	relPaths := make([]string, 0, len(drift))
+	for _, d := range drift {
+		relPaths = append(relPaths, d.RelPath)
+	}
+
+	backup, err := s.backupPaths(relPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, d := range drift {
+		if d.Kind == DriftExtra {
+			if err := s.fs.Remove(d.LocalPath); err != nil && !os.IsNotExist(err) {
+				return backup, fmt.Errorf("failed to remove %s: %w", d.LocalPath, err)
+			}
+			continue
+		}
+
+		data, err := s.repo.ReadFileAt("HEAD", baselineDir+"/"+filepath.ToSlash(d.RelPath))
+		if err != nil {
+			return backup, err
+		}
+		if err := s.fs.MkdirAll(filepath.Dir(d.LocalPath), 0755); err != nil {
+			return backup, fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err := util.WriteFile(s.fs, d.LocalPath, data, 0644); err != nil {
+			return backup, fmt.Errorf("failed to write %s: %w", d.LocalPath, err)
+		}
+	}
+
+	return backup, nil
+}
@@ -0,0 +1,38 @@
+package sync
+
+import (
+	"os"
+
+	"github.com/GareArc/opencode-sync/internal/git"
+)
+
+// ExposedSecret is a credential file committed to the sync repo without
+// encryption, e.g. by an older tool or a manual push
+type ExposedSecret struct {
+	Path string
+
+	// InHead is set when the file is still in the current commit rather
+	// than only in history
+	InHead bool
+}
+
+// PlaintextSecrets searches every commit of the sync repo, including
+// fetched remote branches, for unencrypted auth.json and mcp-auth.json.
+// A shallow clone only has its latest commits to search.
+func PlaintextSecrets(repo *git.BuiltinGit) ([]ExposedSecret, error) {
+	found, err := repo.HistoryPaths(plaintextSecretNames...)
+	if err != nil {
+		return nil, err
+	}
+
+	exposed := make([]ExposedSecret, 0, len(found))
+	for _, path := range found {
+		_, err := repo.ReadFileAt("HEAD", path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		exposed = append(exposed, ExposedSecret{Path: path, InHead: err == nil})
+	}
+
+	return exposed, nil
+}
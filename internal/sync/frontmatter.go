@@ -0,0 +1,126 @@
+package sync
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// frontmatterField is a top-level key of a markdown file's frontmatter
+type frontmatterField struct {
+	// Value is the key's value, unquoted, or "" for a nested map or list
+	Value string
+
+	// Nested is set when the value is a map or list on the lines below
+	Nested bool
+
+	// Line is the line of the key in the file, counting from 1
+	Line int
+}
+
+// frontmatter is the YAML header of an OpenCode agent, command or skill
+type frontmatter struct {
+	Fields map[string]frontmatterField
+
+	// Body is the markdown below the header
+	Body string
+}
+
+// parseFrontmatter splits the frontmatter between --- lines off the top
+// of a markdown file. It reads the top-level keys of the plain YAML these
+// files use: scalars, block scalars (| and >), and maps and lists, which
+// are kept as nested. A file without frontmatter returns nil. Lines that
+// are not YAML are returned as problems, without a path.
+func parseFrontmatter(data []byte) (*frontmatter, []LintProblem) {
+	lines := strings.Split(strings.TrimPrefix(string(data), "\ufeff"), "\n")
+	if strings.TrimRight(lines[0], "\r") != "---" {
+		return nil, nil
+	}
+
+	fm := &frontmatter{Fields: map[string]frontmatterField{}}
+	var problems []LintProblem
+	problem := func(line int, format string, args ...any) {
+		problems = append(problems, LintProblem{Line: line, Reason: fmt.Sprintf(format, args...)})
+	}
+
+	// last is the key indented lines belong to; scalar is set when they
+	// continue its block scalar
+	var last string
+	var scalar bool
+
+	for i := 1; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], "\r")
+		if line == "---" {
+			fm.Body = strings.Join(lines[i+1:], "\n")
+			return fm, problems
+		}
+
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		if line[0] == ' ' || line[0] == '\t' || strings.HasPrefix(line, "- ") {
+			if last == "" {
+				problem(i+1, "indented line without a key")
+				continue
+			}
+			field := fm.Fields[last]
+			if scalar {
+				field.Value = strings.TrimSpace(field.Value + " " + trimmed)
+			} else {
+				field.Nested = true
+			}
+			fm.Fields[last] = field
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.ContainsAny(key, " \t") && !isQuoted(key) {
+			problem(i+1, "expected \"key: value\"")
+			last = ""
+			continue
+		}
+		key = unquoteYAML(key)
+		if _, dup := fm.Fields[key]; dup {
+			problem(i+1, "duplicate key %s", key)
+		}
+
+		value = strings.TrimSpace(value)
+		if !isQuoted(value) {
+			if v, _, found := strings.Cut(value, " #"); found {
+				value = strings.TrimSpace(v)
+			}
+		}
+
+		last = key
+		scalar = strings.HasPrefix(value, "|") || strings.HasPrefix(value, ">")
+		if scalar {
+			value = ""
+		}
+		fm.Fields[key] = frontmatterField{Value: unquoteYAML(value), Line: i + 1}
+	}
+
+	problem(1, "frontmatter is not closed with ---")
+	return nil, problems
+}
+
+// isQuoted reports whether a YAML scalar is in single or double quotes
+func isQuoted(value string) bool {
+	return len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0]
+}
+
+// unquoteYAML returns a YAML scalar without its quotes
+func unquoteYAML(value string) string {
+	if !isQuoted(value) {
+		return value
+	}
+	if value[0] == '\'' {
+		return strings.ReplaceAll(value[1:len(value)-1], "''", "'")
+	}
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		return unquoted
+	}
+	return value[1 : len(value)-1]
+}
@@ -0,0 +1,94 @@
+package sync
+
+import (
+	"os"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+	"github.com/go-git/go-billy/v5/osfs"
+)
+
+// Clock provides the current time
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock is the default Clock
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// osFilesystem is the default filesystem: the real OS filesystem with
+// absolute paths and support for changing file modes
+type osFilesystem struct {
+	*osfs.ChrootOS
+}
+
+// Chmod changes the mode of a file
+func (osFilesystem) Chmod(name string, mode os.FileMode) error {
+	return os.Chmod(name, mode)
+}
+
+// Chtimes changes the access and modification times of a file
+func (osFilesystem) Chtimes(name string, atime, mtime time.Time) error {
+	return os.Chtimes(name, atime, mtime)
+}
+
+// Chown changes the owner of a file. With Lchown it completes
+// billy.Change, which chmod and chtimes check for.
+func (osFilesystem) Chown(name string, uid, gid int) error {
+	return os.Chown(name, uid, gid)
+}
+
+// Lchown changes the owner of a file without following symlinks
+func (osFilesystem) Lchown(name string, uid, gid int) error {
+	return os.Lchown(name, uid, gid)
+}
+
+// Chroot returns a filesystem rooted at path
+func (fs osFilesystem) Chroot(path string) (billy.Filesystem, error) {
+	return chroot.New(fs, path), nil
+}
+
+// Root returns the root path, which is empty because paths are absolute
+func (osFilesystem) Root() string {
+	return ""
+}
+
+// SetFilesystem replaces the filesystem used for local files and their
+// copies in the repo's working tree, e.g. with memfs.New() to run
+// CopyToRepo and CopyFromRepo in memory. It does not take a whole sync
+// off disk: BuiltinGit runs the git CLI, so commits, pushes and pulls
+// always use the real filesystem.
+func (s *Syncer) SetFilesystem(fs billy.Filesystem) {
+	s.fs = fs
+}
+
+// SetClock replaces the clock used for timestamps, including commit
+// timestamps when the repository supports it
+func (s *Syncer) SetClock(clock Clock) {
+	s.clock = clock
+	if repo, ok := s.repo.(interface{ SetClock(func() time.Time) }); ok {
+		repo.SetClock(clock.Now)
+	}
+}
+
+// chmod sets a file mode if the filesystem supports it
+func (s *Syncer) chmod(name string, mode os.FileMode) error {
+	if fs, ok := s.fs.(billy.Change); ok {
+		return fs.Chmod(name, mode)
+	}
+	return nil
+}
+
+// chtimes sets the modification time of a file if the filesystem supports
+// it
+func (s *Syncer) chtimes(name string, mtime time.Time) error {
+	if fs, ok := s.fs.(billy.Change); ok {
+		return fs.Chtimes(name, mtime, mtime)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// repoGitFiles are generated at the repo root to make manual git use
+// behave sensibly. They are repo metadata and never copied locally.
+var repoGitFiles = map[string]string{
+	".gitignore": `# Generated by opencode-sync
+*.log
+logs/
+node_modules/
+.cache/
+bun.lock
+bun.lockb
+.DS_Store
+`,
+	".gitattributes": `# Generated by opencode-sync
+* text=auto
+
+# Encrypted secrets must never be diffed or line-merged
+*.age binary linguist-generated
+
+# Structured merge for JSON lists such as plugins and MCP servers.
+# opencode-sync registers the driver; git falls back to a regular merge
+# when it is not configured.
+opencode.json merge=opencode-json
+opencode.jsonc merge=opencode-json
+
+# Additions to AGENTS.md from both sides are kept without a conflict
+AGENTS.md merge=opencode-union
+
+*.jsonc linguist-language=JSON-with-Comments
+`,
+}
+
+// WriteRepoGitFiles creates .gitignore and .gitattributes in the sync repo.
+// Existing files are left untouched so manual edits survive.
+func (s *Syncer) WriteRepoGitFiles() error {
+	for name, content := range repoGitFiles {
+		path := filepath.Join(s.paths.SyncRepoDir(), name)
+
+		if _, err := s.fs.Stat(path); err == nil {
+			continue
+		}
+
+		if err := util.WriteFile(s.fs, path, []byte(content), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// mergeDriverAttributes route OpenCode config files through the merge
+// driver. They are written to info/attributes in the git dir so repos created
+// before .gitattributes was generated use the driver too.
+var mergeDriverAttributes = []string{
+	"opencode.json merge=" + MergeDriverName,
+	"opencode.jsonc merge=" + MergeDriverName,
+}
+
+// InstallMergeDriver registers the JSON and union merge drivers for the
+// sync repo, and routes sync.unionMerge's patterns to the union driver.
+// command is the opencode-sync invocation git runs to merge a file.
+func (s *Syncer) InstallMergeDriver(command string) error {
+	if err := s.repo.SetMergeDriver(MergeDriverName, "opencode-sync JSON merge", command+" %O %A %B %P"); err != nil {
+		return err
+	}
+	if err := s.repo.SetMergeDriver(UnionMergeDriverName, "opencode-sync union merge", command+" --union %O %A %B %P"); err != nil {
+		return err
+	}
+
+	wanted := slices.Clone(mergeDriverAttributes)
+	for _, pattern := range s.unionMergePatterns() {
+		wanted = append(wanted, s.rootPattern(pattern)+" merge="+UnionMergeDriverName)
+	}
+
+	attrPath := filepath.Join(s.paths.SyncGitDir(), "info", "attributes")
+
+	existing, err := os.ReadFile(attrPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %w", attrPath, err)
+	}
+
+	// Patterns removed from sync.unionMerge stop using the union driver
+	var lines []string
+	for _, line := range strings.SplitAfter(string(existing), "\n") {
+		if strings.HasSuffix(strings.TrimSpace(line), " merge="+UnionMergeDriverName) && !slices.Contains(wanted, strings.TrimSpace(line)) {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	content := strings.Join(lines, "")
+
+	for _, line := range wanted {
+		if slices.Contains(strings.Split(content, "\n"), line) {
+			continue
+		}
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		content += line + "\n"
+	}
+
+	if content == string(existing) {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(attrPath), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(attrPath), err)
+	}
+
+	if err := os.WriteFile(attrPath, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", attrPath, err)
+	}
+
+	return nil
+}
+
+// rootPattern returns a pattern matching paths of the synced files
+// relative to the repository root, as info/attributes wants them. Only
+// patterns with a slash are anchored; the others match at any depth.
+func (s *Syncer) rootPattern(pattern string) string {
+	subdir := s.cfg.Repo.SubdirPath()
+	if subdir == "" || !strings.Contains(strings.TrimSuffix(pattern, "/"), "/") {
+		return pattern
+	}
+	return subdir + "/" + strings.TrimPrefix(pattern, "/")
+}
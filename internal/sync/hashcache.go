@@ -0,0 +1,139 @@
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// hashCacheVersion is bumped when cached hashes are computed differently
+const hashCacheVersion = 1
+
+// racyWindow is how recently a file may have been modified for its hash
+// not to be cached: a write in the same clock tick as the hash would not
+// move the modification time
+const racyWindow = 2 * time.Second
+
+// hashCacheEntry is the hash of a file when it had Size and ModTime
+type hashCacheEntry struct {
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mtime"`
+	Hash    string `json:"hash"`
+}
+
+// hashCache maps absolute file paths to their last known hash
+type hashCache struct {
+	Version int                       `json:"version"`
+	Files   map[string]hashCacheEntry `json:"files"`
+
+	// fresh are the hashes computed since the cache was loaded
+	fresh map[string]hashCacheEntry
+
+	// bypass makes every file be hashed again (see DisableHashCache)
+	bypass bool
+
+	// mu guards fresh, which sources copied concurrently add to
+	mu sync.Mutex
+}
+
+// loadHashCache reads the hash cache. A missing, unreadable or outdated
+// cache is an empty one.
+func (s *Syncer) loadHashCache() *hashCache {
+	cache := &hashCache{Version: hashCacheVersion, Files: map[string]hashCacheEntry{}, fresh: map[string]hashCacheEntry{}}
+
+	data, err := os.ReadFile(s.paths.HashCacheFile())
+	if err != nil {
+		return cache
+	}
+	var loaded hashCache
+	if err := json.Unmarshal(data, &loaded); err != nil || loaded.Version != hashCacheVersion || loaded.Files == nil {
+		return cache
+	}
+	cache.Files = loaded.Files
+	return cache
+}
+
+// hashes returns the hash cache, loading it on first use
+func (s *Syncer) hashes() *hashCache {
+	if s.hashCache == nil {
+		s.hashCache = s.loadHashCache()
+	}
+	return s.hashCache
+}
+
+// DisableHashCache makes the syncer hash every file instead of trusting
+// cached hashes of files whose size and modification time are unchanged.
+// The fresh hashes still replace the cached ones on SaveHashCache.
+func (s *Syncer) DisableHashCache() {
+	s.hashes().bypass = true
+}
+
+// cachedHash returns the cached hash of a file if it is unchanged since
+// it was cached
+func (s *Syncer) cachedHash(path string, info os.FileInfo) (string, bool) {
+	cache := s.hashes()
+	if cache.bypass {
+		return "", false
+	}
+
+	entry, ok := cache.Files[path]
+	if !ok || entry.Size != info.Size() || entry.ModTime != info.ModTime().UnixNano() {
+		return "", false
+	}
+	return entry.Hash, true
+}
+
+// cacheHash records the hash of a file with the size and modification
+// time it was read with
+func (s *Syncer) cacheHash(path string, info os.FileInfo, hash string) {
+	if s.clock.Now().Sub(info.ModTime()) < racyWindow {
+		return
+	}
+	cache := s.hashes()
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cache.fresh[path] = hashCacheEntry{Size: info.Size(), ModTime: info.ModTime().UnixNano(), Hash: hash}
+}
+
+// SaveHashCache adds the hashes computed since the syncer was created to
+// the cache, so the next run only reads files that changed. Files that
+// are gone drop out. Nothing is written if every hash came from the
+// cache.
+func (s *Syncer) SaveHashCache() error {
+	if s.hashCache == nil || len(s.hashCache.fresh) == 0 {
+		return nil
+	}
+
+	files := make(map[string]hashCacheEntry, len(s.hashCache.Files))
+	for path, entry := range s.hashCache.Files {
+		if _, err := s.fs.Stat(path); err == nil {
+			files[path] = entry
+		}
+	}
+	for path, entry := range s.hashCache.fresh {
+		files[path] = entry
+	}
+
+	data, err := json.Marshal(&hashCache{Version: hashCacheVersion, Files: files})
+	if err != nil {
+		return fmt.Errorf("failed to marshal hash cache: %w", err)
+	}
+
+	path := s.paths.HashCacheFile()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write hash cache: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write hash cache: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// hostsDir is the repo directory holding per-host overlays and secrets,
+// stored as hosts/<name>/. Files under it are never applied locally.
+const hostsDir = "hosts"
+
+// HostDirs returns the names of hosts that have a directory in the repo
+func (s *Syncer) HostDirs() ([]string, error) {
+	entries, err := s.fs.ReadDir(filepath.Join(s.paths.SyncRepoDir(), hostsDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hosts directory: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+
+	return names, nil
+}
+
+// RemoveHostDir deletes a host's directory from the repo working tree.
+// It returns false if the host has no directory.
+func (s *Syncer) RemoveHostDir(name string) (bool, error) {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return false, fmt.Errorf("invalid host name: %q", name)
+	}
+
+	dir := filepath.Join(s.paths.SyncRepoDir(), hostsDir, name)
+	if _, err := s.fs.Stat(dir); os.IsNotExist(err) {
+		return false, nil
+	}
+
+	if err := util.RemoveAll(s.fs, dir); err != nil {
+		return false, fmt.Errorf("failed to remove %s: %w", dir, err)
+	}
+
+	return true, nil
+}
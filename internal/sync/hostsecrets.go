@@ -0,0 +1,201 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GareArc/opencode-sync/internal/capability"
+	"github.com/GareArc/opencode-sync/internal/crypto"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func init() {
+	capability.Register(capability.Capability{
+		Name:        "host-secrets",
+		Kind:        capability.KindFeature,
+		Description: "Encrypt per-machine secrets to that machine's own key",
+	})
+}
+
+// Layout of a host's directory in the repo: its public key and the
+// secrets encrypted to it, stored as hosts/<name>/secrets/<path>.age
+const (
+	hostRecipientFile = "recipient.txt"
+	hostSecretsDir    = "secrets"
+)
+
+// LoadHostKey loads this machine's own key from the host key file. The
+// key is generated when it is missing and sync.hostSecrets is set, and
+// never leaves this machine; only its public key is published in the repo.
+func (s *Syncer) LoadHostKey() error {
+	keyFile := s.paths.HostKeyFile()
+
+	if _, err := os.Stat(keyFile); os.IsNotExist(err) {
+		if len(s.cfg.Sync.HostSecrets) == 0 {
+			return nil
+		}
+
+		keyPair, err := crypto.GenerateKey()
+		if err != nil {
+			return fmt.Errorf("failed to generate host key: %w", err)
+		}
+		if err := crypto.SaveKeyToFile(keyPair.PrivateKey, keyFile); err != nil {
+			return fmt.Errorf("failed to save host key: %w", err)
+		}
+	}
+
+	enc, err := crypto.LoadEncryption(keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load host key: %w", err)
+	}
+	recipient := enc.PublicKey()
+
+	s.hostEncryption = s.timed(enc)
+	s.hostRecipient = recipient
+	return nil
+}
+
+// HostRecipients returns the public host keys published in the repo,
+// by host name
+func (s *Syncer) HostRecipients() (map[string]string, error) {
+	names, err := s.HostDirs()
+	if err != nil {
+		return nil, err
+	}
+
+	recipients := map[string]string{}
+	for _, name := range names {
+		data, err := util.ReadFile(s.fs, filepath.Join(s.paths.SyncRepoDir(), hostsDir, name, hostRecipientFile))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read host key for %s: %w", name, err)
+		}
+		recipients[name] = strings.TrimSpace(string(data))
+	}
+
+	return recipients, nil
+}
+
+// isHostSecret reports whether a repo path is kept private to this machine
+func (s *Syncer) isHostSecret(relPath string) bool {
+	return matchPathPatterns(s.cfg.Sync.HostSecrets, relPath)
+}
+
+// hostSecretsRepoDir is where this machine's secrets are stored in the repo
+func (s *Syncer) hostSecretsRepoDir() string {
+	return filepath.Join(s.paths.SyncRepoDir(), hostsDir, Hostname(), hostSecretsDir)
+}
+
+// hostSecretCurrent reports whether the repo holds the current content of
+// a local host secret
+func (s *Syncer) hostSecretCurrent(file FileInfo) bool {
+	if s.hostEncryption == nil {
+		return false
+	}
+
+	return s.encryptedCurrent(s.hostEncryption, filepath.Join(s.hostSecretsRepoDir(), file.RelPath+".age"), file.Hash)
+}
+
+// copyHostSecretsToRepo encrypts the host secrets found by CopyToRepo to
+// this machine's key, publishes the key's recipient and removes any
+// plaintext copy from the shared part of the repo
+func (s *Syncer) copyHostSecretsToRepo() error {
+	if s.hostEncryption == nil {
+		return fmt.Errorf("hostSecrets requires a host key at %s: %w", s.paths.HostKeyFile(), crypto.ErrKeyMissing)
+	}
+
+	hostDir := filepath.Join(s.paths.SyncRepoDir(), hostsDir, Hostname())
+	if err := s.fs.MkdirAll(hostDir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := util.WriteFile(s.fs, filepath.Join(hostDir, hostRecipientFile), []byte(s.hostRecipient+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to publish host key: %w", err)
+	}
+
+	repoDir := s.hostSecretsRepoDir()
+	keep := make(map[string]bool, len(s.hostSecrets))
+
+	for _, source := range s.hostSecrets {
+		dst := filepath.Join(repoDir, source.RelPath+".age")
+		keep[dst] = true
+
+		// A copy pushed before the file became a host secret
+		shared := filepath.Join(s.paths.SyncRepoDir(), source.RelPath)
+		if err := s.fs.Remove(shared); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove shared copy of %s: %w", source.RelPath, err)
+		}
+
+		if err := s.encryptFileVerifiedWith(s.hostEncryption, source.RelPath, source.LocalPath, dst); err != nil {
+			return err
+		}
+	}
+
+	// A partial copy cannot tell which secrets were removed
+	if s.partial() {
+		return nil
+	}
+
+	return s.pruneRepoDir(repoDir, keep)
+}
+
+// hostSecretFiles returns the repo paths of the secrets stored for this
+// machine, without the hosts/<name>/secrets/ prefix and .age suffix
+func (s *Syncer) hostSecretFiles() ([]string, error) {
+	repoDir := s.hostSecretsRepoDir()
+	if _, err := s.fs.Stat(repoDir); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	var relPaths []string
+	err := util.Walk(s.fs, repoDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(path, ".age") {
+			return err
+		}
+
+		relPath, err := filepath.Rel(repoDir, path)
+		if err != nil {
+			return err
+		}
+		relPaths = append(relPaths, strings.TrimSuffix(relPath, ".age"))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan host secrets: %w", err)
+	}
+
+	return relPaths, nil
+}
+
+// copyHostSecretsFromRepo decrypts this machine's secrets to their local
+// destinations. Secrets stored for other machines are never touched.
+func (s *Syncer) copyHostSecretsFromRepo() error {
+	relPaths, err := s.hostSecretFiles()
+	if err != nil {
+		return err
+	}
+	if len(relPaths) == 0 {
+		return nil
+	}
+
+	if s.hostEncryption == nil {
+		return fmt.Errorf("found secrets for %s but the host key is missing at %s: %w", Hostname(), s.paths.HostKeyFile(), crypto.ErrKeyMissing)
+	}
+
+	for _, relPath := range relPaths {
+		dstPath := s.localPath(relPath)
+		if dstPath == "" || !s.onlyMatches(relPath) {
+			continue
+		}
+
+		src := filepath.Join(s.hostSecretsRepoDir(), relPath+".age")
+		if err := s.decryptFileWith(s.hostEncryption, src, dstPath); err != nil {
+			return fmt.Errorf("failed to decrypt %s: %w", relPath, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,208 @@
+package sync
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+
+	"github.com/GareArc/opencode-sync/internal/capability"
+	"github.com/GareArc/opencode-sync/internal/jsonc"
+)
+
+func init() {
+	capability.Register(capability.Capability{
+		Name:        "json-merge",
+		Kind:        capability.KindFeature,
+		Description: "Three-way merge of OpenCode JSON config files",
+	})
+}
+
+// MergeDriverName is the git merge driver used for OpenCode config files
+const MergeDriverName = "opencode-json"
+
+// MergeJSON performs a three-way merge of JSON or JSONC objects, keeping
+// the comments of our side. Object keys are merged recursively. Arrays
+// changed on both sides are unioned, with items removed on either side
+// dropped and duplicates collapsed; object items with the same value in
+// the first of keys that they have are merged as one. It returns false if
+// a value was changed differently on both sides.
+func MergeJSON(base, ours, theirs []byte, keys []string) ([]byte, bool, error) {
+	// Our side's tree keeps its comments and member order in the result
+	tree := &jsonc.Document{Value: &jsonc.Node{Kind: jsonc.Object}}
+
+	var docs [3]map[string]any
+	for i, data := range [][]byte{base, ours, theirs} {
+		if len(bytes.TrimSpace(data)) == 0 {
+			docs[i] = map[string]any{}
+			continue
+		}
+
+		parsed, doc, err := parseConfig(data)
+		if err != nil {
+			return nil, false, err
+		}
+		docs[i] = doc
+		if i == 1 {
+			tree = parsed
+		}
+	}
+
+	m := merger{keys: keys}
+	merged, ok := m.mergeValues(docs[0], docs[1], docs[2])
+	if !ok {
+		return nil, false, nil
+	}
+
+	return formatConfig(tree, merged.(map[string]any)), true, nil
+}
+
+// merger merges JSON values, identifying object items of arrays by keys
+type merger struct {
+	keys []string
+}
+
+// mergeValues merges one value. A nil base means the value did not exist.
+func (m merger) mergeValues(base, ours, theirs any) (any, bool) {
+	switch {
+	case reflect.DeepEqual(ours, theirs):
+		return ours, true
+	case reflect.DeepEqual(base, ours):
+		return theirs, true
+	case reflect.DeepEqual(base, theirs):
+		return ours, true
+	}
+
+	if o, ok := ours.(map[string]any); ok {
+		if t, ok := theirs.(map[string]any); ok {
+			b, _ := base.(map[string]any)
+			return m.mergeObjects(b, o, t)
+		}
+	}
+
+	if o, ok := ours.([]any); ok {
+		if t, ok := theirs.([]any); ok {
+			b, _ := base.([]any)
+			return m.mergeArrays(b, o, t)
+		}
+	}
+
+	return nil, false
+}
+
+// mergeObjects merges objects key by key
+func (m merger) mergeObjects(base, ours, theirs map[string]any) (any, bool) {
+	merged := map[string]any{}
+
+	keys := map[string]bool{}
+	for _, obj := range []map[string]any{base, ours, theirs} {
+		for key := range obj {
+			keys[key] = true
+		}
+	}
+
+	for key := range keys {
+		b, inBase := base[key]
+		o, inOurs := ours[key]
+		t, inTheirs := theirs[key]
+
+		value, keep, ok := m.mergeMember(b, o, t, inBase, inOurs, inTheirs)
+		if !ok {
+			return nil, false
+		}
+		if keep {
+			merged[key] = value
+		}
+	}
+
+	return merged, true
+}
+
+// mergeArrays unions both sides, keeping our order first. Items are
+// matched by arrayItemKey and merged like object members: one removed on
+// either side is dropped, unless the other side changed it, and one both
+// sides kept is merged with mergeValues. Duplicates within a side
+// collapse into their first occurrence.
+func (m merger) mergeArrays(base, ours, theirs []any) (any, bool) {
+	b := m.itemsByKey(base)
+	o := m.itemsByKey(ours)
+	t := m.itemsByKey(theirs)
+
+	merged := []any{}
+	seen := map[string]bool{}
+	for _, item := range append(append([]any{}, ours...), theirs...) {
+		key := m.arrayItemKey(item)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		bv, inBase := b[key]
+		ov, inOurs := o[key]
+		tv, inTheirs := t[key]
+
+		value, keep, ok := m.mergeMember(bv, ov, tv, inBase, inOurs, inTheirs)
+		if !ok {
+			return nil, false
+		}
+		if keep {
+			merged = append(merged, value)
+		}
+	}
+
+	return merged, true
+}
+
+// mergeMember merges an object member or array item that may be missing
+// on any side. It returns false for keep if the merge drops it, and false
+// for ok if one side removed it while the other changed it, or both
+// changed it differently.
+func (m merger) mergeMember(base, ours, theirs any, inBase, inOurs, inTheirs bool) (value any, keep, ok bool) {
+	switch {
+	case inBase && !inOurs && !inTheirs:
+		return nil, false, true
+	case inBase && !inOurs:
+		// Deleted by us; conflict only if they changed it
+		return nil, false, reflect.DeepEqual(base, theirs)
+	case inBase && !inTheirs:
+		return nil, false, reflect.DeepEqual(base, ours)
+	case !inOurs:
+		return theirs, true, true
+	case !inTheirs:
+		return ours, true, true
+	}
+
+	if !inBase {
+		base = nil
+	}
+	value, ok = m.mergeValues(base, ours, theirs)
+	return value, ok, ok
+}
+
+// itemsByKey indexes array items by arrayItemKey, keeping the first item
+// with each key
+func (m merger) itemsByKey(items []any) map[string]any {
+	byKey := make(map[string]any, len(items))
+	for _, item := range items {
+		key := m.arrayItemKey(item)
+		if _, ok := byKey[key]; !ok {
+			byKey[key] = item
+		}
+	}
+	return byKey
+}
+
+// arrayItemKey identifies an array item. Objects with one of the merge
+// keys as a string field are keyed by the first they have, everything
+// else by its JSON encoding.
+func (m merger) arrayItemKey(item any) string {
+	if obj, ok := item.(map[string]any); ok {
+		for _, field := range m.keys {
+			if id, ok := obj[field].(string); ok {
+				return field + ":" + id
+			}
+		}
+	}
+
+	data, _ := json.Marshal(item)
+	return string(data)
+}
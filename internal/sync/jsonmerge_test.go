@@ -0,0 +1,182 @@
+package sync
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMergeJSON(t *testing.T) {
+	defaultKeys := []string{"name", "id"}
+
+	tests := []struct {
+		name   string
+		base   string
+		ours   string
+		theirs string
+		keys   []string
+
+		// want is the merged document, or "" for a conflict
+		want string
+	}{
+		{
+			name:   "different keys changed",
+			base:   `{"a": 1, "b": 1}`,
+			ours:   `{"a": 2, "b": 1}`,
+			theirs: `{"a": 1, "b": 2}`,
+			want:   `{"a": 2, "b": 2}`,
+		},
+		{
+			name:   "same change on both sides",
+			base:   `{"a": 1}`,
+			ours:   `{"a": 2}`,
+			theirs: `{"a": 2}`,
+			want:   `{"a": 2}`,
+		},
+		{
+			name:   "same key changed differently",
+			base:   `{"a": 1}`,
+			ours:   `{"a": 2}`,
+			theirs: `{"a": 3}`,
+		},
+		{
+			name:   "nested objects merged",
+			base:   `{"provider": {"x": {"model": "a", "url": "u"}}}`,
+			ours:   `{"provider": {"x": {"model": "b", "url": "u"}}}`,
+			theirs: `{"provider": {"x": {"model": "a", "url": "v"}, "y": {}}}`,
+			want:   `{"provider": {"x": {"model": "b", "url": "v"}, "y": {}}}`,
+		},
+		{
+			name:   "key added on both sides",
+			base:   `{}`,
+			ours:   `{"a": 1}`,
+			theirs: `{"b": 2}`,
+			want:   `{"a": 1, "b": 2}`,
+		},
+		{
+			name:   "removed on one side, unchanged on the other",
+			base:   `{"a": 1, "b": 1}`,
+			ours:   `{"b": 1}`,
+			theirs: `{"a": 1, "b": 2}`,
+			want:   `{"b": 2}`,
+		},
+		{
+			name:   "removed on one side, changed on the other",
+			base:   `{"a": 1}`,
+			ours:   `{}`,
+			theirs: `{"a": 2}`,
+		},
+		{
+			name:   "plain arrays unioned",
+			base:   `{"plugin": ["a", "b"]}`,
+			ours:   `{"plugin": ["a", "b", "c"]}`,
+			theirs: `{"plugin": ["b", "d"]}`,
+			want:   `{"plugin": ["b", "c", "d"]}`,
+		},
+		{
+			name:   "duplicates collapse",
+			base:   `{"plugin": []}`,
+			ours:   `{"plugin": ["a", "a"]}`,
+			theirs: `{"plugin": ["a", "b"]}`,
+			want:   `{"plugin": ["a", "b"]}`,
+		},
+		{
+			name:   "array items with the same key merged",
+			base:   `{"x": [{"name": "a", "v": 1}]}`,
+			ours:   `{"x": [{"name": "a", "v": 2}]}`,
+			theirs: `{"x": [{"name": "a", "v": 1}, {"name": "b"}]}`,
+			want:   `{"x": [{"name": "a", "v": 2}, {"name": "b"}]}`,
+		},
+		{
+			name:   "array items with the same key changed differently",
+			base:   `{"x": [{"name": "a", "v": 1}]}`,
+			ours:   `{"x": [{"name": "a", "v": 2}]}`,
+			theirs: `{"x": [{"name": "a", "v": 3}]}`,
+		},
+		{
+			name:   "array item removed on one side, changed on the other",
+			base:   `{"x": [{"id": "a", "v": 1}]}`,
+			ours:   `{"x": []}`,
+			theirs: `{"x": [{"id": "a", "v": 2}]}`,
+		},
+		{
+			name:   "array items without a key compared as values",
+			base:   `{"x": [{"v": 1}]}`,
+			ours:   `{"x": [{"v": 1}, {"v": 2}]}`,
+			theirs: `{"x": []}`,
+			want:   `{"x": [{"v": 2}]}`,
+		},
+		{
+			name:   "configured merge key",
+			base:   `{"x": [{"k": "a", "v": 1}]}`,
+			ours:   `{"x": [{"k": "a", "v": 1, "o": true}]}`,
+			theirs: `{"x": [{"k": "a", "v": 2}]}`,
+			keys:   []string{"k"},
+			want:   `{"x": [{"k": "a", "v": 2, "o": true}]}`,
+		},
+		{
+			name:   "empty base",
+			ours:   `{"a": 1}`,
+			theirs: `{"a": 1, "b": 2}`,
+			want:   `{"a": 1, "b": 2}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := tt.keys
+			if keys == nil {
+				keys = defaultKeys
+			}
+
+			got, ok, err := MergeJSON([]byte(tt.base), []byte(tt.ours), []byte(tt.theirs), keys)
+			if err != nil {
+				t.Fatalf("MergeJSON() error = %v", err)
+			}
+			if tt.want == "" {
+				if ok {
+					t.Errorf("MergeJSON() = %s, want a conflict", got)
+				}
+				return
+			}
+			if !ok {
+				t.Fatalf("MergeJSON() reported a conflict, want %s", tt.want)
+			}
+
+			var gotValue, wantValue any
+			if err := json.Unmarshal(got, &gotValue); err != nil {
+				t.Fatalf("MergeJSON() wrote invalid JSON %s: %v", got, err)
+			}
+			if err := json.Unmarshal([]byte(tt.want), &wantValue); err != nil {
+				t.Fatalf("invalid test JSON %s: %v", tt.want, err)
+			}
+			if !reflect.DeepEqual(gotValue, wantValue) {
+				t.Errorf("MergeJSON() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeJSONKeepsOurComments(t *testing.T) {
+	base := `{"a": 1, "b": 1}`
+	ours := "{\n  // why a is 2\n  \"a\": 2,\n  \"b\": 1\n}\n"
+	theirs := `{"a": 1, "b": 3}`
+
+	got, ok, err := MergeJSON([]byte(base), []byte(ours), []byte(theirs), nil)
+	if err != nil || !ok {
+		t.Fatalf("MergeJSON() = %v, %v", ok, err)
+	}
+	if !strings.Contains(string(got), "// why a is 2") {
+		t.Errorf("MergeJSON() = %s, want our comment kept", got)
+	}
+	if !strings.Contains(string(got), `"b": 3`) {
+		t.Errorf("MergeJSON() = %s, want their change to b", got)
+	}
+}
+
+func TestMergeJSONInvalid(t *testing.T) {
+	if _, _, err := MergeJSON([]byte(`{}`), []byte(`{"a": `), []byte(`{}`), nil); err == nil {
+		t.Error("MergeJSON() of invalid JSON returned no error")
+	}
+}
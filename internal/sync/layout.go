@@ -0,0 +1,234 @@
+package sync
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/GareArc/opencode-sync/internal/capability"
+)
+
+// RepoLayout is the layout of the sync repo this build writes. Repos
+// without a layout in their manifest have layout 1.
+const RepoLayout = 2
+
+// secretsDir holds the encrypted auth files from layout 2 on
+const secretsDir = "secrets"
+
+// Paths of the encrypted auth files in the current layout
+var (
+	authRepoFile    = filepath.Join(secretsDir, "auth.json.age")
+	mcpAuthRepoFile = filepath.Join(secretsDir, "mcp-auth.json.age")
+)
+
+// layoutMigration upgrades the repo to the next layout by moving files
+type layoutMigration struct {
+	// layout is the layout the migration upgrades to
+	layout int
+
+	description string
+
+	// renames maps old repo paths to new ones, with forward slashes
+	renames map[string]string
+}
+
+// layoutMigrations are the migrations between layouts, oldest first
+var layoutMigrations = []layoutMigration{
+	{
+		layout:      2,
+		description: "Move the encrypted auth files into secrets/",
+		renames: map[string]string{
+			"auth.json.age":     "secrets/auth.json.age",
+			"mcp-auth.json.age": "secrets/mcp-auth.json.age",
+		},
+	},
+}
+
+func init() {
+	// Listed in the manifest, so builds that predate a layout refuse it
+	for _, migration := range layoutMigrations {
+		capability.Register(capability.Capability{
+			Name:        layoutFeature(migration.layout),
+			Kind:        capability.KindFeature,
+			Description: fmt.Sprintf("Sync repo layout %d: %s", migration.layout, migration.description),
+		})
+	}
+}
+
+// layoutFeature is the manifest feature of a layout
+func layoutFeature(layout int) string {
+	return fmt.Sprintf("layout-%d", layout)
+}
+
+// LayoutMove is a file moved by a layout migration
+type LayoutMove struct {
+	From string
+	To   string
+}
+
+// LayoutMigration is a pending migration and the files it moves
+type LayoutMigration struct {
+	Layout      int
+	Description string
+	Moves       []LayoutMove
+}
+
+// layoutOf returns the layout a manifest records
+func layoutOf(manifest *Manifest) int {
+	if manifest == nil || manifest.Layout == 0 {
+		return 1
+	}
+	return manifest.Layout
+}
+
+// Layout returns the layout of the sync repo's working tree. The direct
+// layout has no files of its own to move, so it is always current.
+func (s *Syncer) Layout() (int, error) {
+	if s.Direct() {
+		return RepoLayout, nil
+	}
+	manifest, err := s.loadWorkingManifest()
+	if err != nil {
+		return 0, err
+	}
+	return layoutOf(manifest), nil
+}
+
+// pathInLayout returns where a repo path of the current layout is stored
+// in a repo with the given layout
+func pathInLayout(relPath string, layout int) string {
+	slashed := filepath.ToSlash(relPath)
+	for i := len(layoutMigrations) - 1; i >= 0; i-- {
+		migration := layoutMigrations[i]
+		if layout >= migration.layout {
+			break
+		}
+		for from, to := range migration.renames {
+			if to == slashed {
+				slashed = from
+				break
+			}
+		}
+	}
+	return filepath.FromSlash(slashed)
+}
+
+// layoutPath returns where a repo path of the current layout is stored in
+// the sync repo's working tree, which may not be migrated yet
+func (s *Syncer) layoutPath(relPath string) string {
+	layout, err := s.Layout()
+	if err != nil {
+		return relPath
+	}
+	return pathInLayout(relPath, layout)
+}
+
+// currentPath returns the path of the current layout for a repo path of
+// the working tree, which may not be migrated yet
+func (s *Syncer) currentPath(relPath string) string {
+	slashed := filepath.ToSlash(relPath)
+	for _, migration := range layoutMigrations {
+		to, ok := migration.renames[slashed]
+		if !ok {
+			continue
+		}
+		// Only read the manifest for the few paths that moved
+		if layout, err := s.Layout(); err != nil || layout >= migration.layout {
+			break
+		}
+		slashed = to
+	}
+	return filepath.FromSlash(slashed)
+}
+
+// PendingMigrations returns the migrations the sync repo's working tree
+// needs to reach RepoLayout, with the files each would move. A repo with
+// a newer layout than this build knows is refused.
+func (s *Syncer) PendingMigrations() ([]LayoutMigration, error) {
+	layout, err := s.Layout()
+	if err != nil {
+		return nil, err
+	}
+	if layout > RepoLayout {
+		return nil, &ManifestError{Layout: layout}
+	}
+
+	var pending []LayoutMigration
+	for _, migration := range layoutMigrations {
+		if migration.layout <= layout {
+			continue
+		}
+
+		pendingMigration := LayoutMigration{Layout: migration.layout, Description: migration.description}
+		for from, to := range migration.renames {
+			if _, err := s.fs.Stat(filepath.Join(s.paths.SyncRepoDir(), filepath.FromSlash(from))); err == nil {
+				pendingMigration.Moves = append(pendingMigration.Moves, LayoutMove{From: from, To: to})
+			}
+		}
+		sort.Slice(pendingMigration.Moves, func(i, j int) bool {
+			return pendingMigration.Moves[i].From < pendingMigration.Moves[j].From
+		})
+		pending = append(pending, pendingMigration)
+	}
+
+	return pending, nil
+}
+
+// MigrateLayout upgrades the sync repo's working tree to RepoLayout and records it in the manifest. The next push commits it.
+// It returns the migrations applied.
+func (s *Syncer) MigrateLayout() ([]LayoutMigration, error) {
+	if s.Direct() {
+		return nil, nil
+	}
+
+	pending, err := s.PendingMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, migration := range pending {
+		for _, move := range migration.Moves {
+			from := filepath.Join(s.paths.SyncRepoDir(), filepath.FromSlash(move.From))
+			to := filepath.Join(s.paths.SyncRepoDir(), filepath.FromSlash(move.To))
+			if _, err := s.fs.Stat(to); err == nil {
+				return nil, fmt.Errorf("failed to move %s to %s: %s already exists", move.From, move.To, move.To)
+			}
+			if err := s.fs.MkdirAll(filepath.Dir(to), 0755); err != nil {
+				return nil, fmt.Errorf("failed to create %s: %w", filepath.Dir(to), err)
+			}
+			if err := s.fs.Rename(from, to); err != nil {
+				return nil, fmt.Errorf("failed to move %s to %s: %w", move.From, move.To, err)
+			}
+		}
+
+		if err := s.recordLayout(migration.Layout); err != nil {
+			return nil, err
+		}
+	}
+
+	return pending, nil
+}
+
+// recordLayout sets the layout in the manifest of the working tree
+func (s *Syncer) recordLayout(layout int) error {
+	manifest, err := s.loadWorkingManifest()
+	if err != nil {
+		return err
+	}
+	if manifest == nil {
+		manifest = &Manifest{}
+	}
+	manifest.Layout = layout
+
+	relPaths, err := s.repoFiles()
+	if err != nil {
+		return fmt.Errorf("failed to list repo files: %w", err)
+	}
+	manifest.Features = manifestFeatures(relPaths, layout)
+	return s.saveManifest(manifest)
+}
+
+// Migrated returns the migrations the last CopyToRepo applied
+func (s *Syncer) Migrated() []LayoutMigration {
+	return s.migrated
+}
@@ -0,0 +1,461 @@
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/GareArc/opencode-sync/internal/jsonc"
+)
+
+// knownProviders are the providers OpenCode ships with. Model IDs are
+// checked against them when OpenCode hasn't cached its list of models.
+var knownProviders = []string{
+	"alibaba", "amazon-bedrock", "anthropic", "azure", "baseten", "cerebras",
+	"cohere", "deepinfra", "deepseek", "fireworks-ai", "github-copilot",
+	"github-models", "google", "google-vertex", "google-vertex-anthropic",
+	"groq", "huggingface", "llama", "mistral", "moonshotai", "openai",
+	"opencode", "openrouter", "perplexity", "togetherai", "vercel", "xai", "zai",
+}
+
+// builtinAgents are the agents OpenCode has without any config
+var builtinAgents = []string{"build", "plan", "general", "explore"}
+
+// agentModes are the values of an agent's mode
+var agentModes = []string{"primary", "subagent", "all"}
+
+// skillName is what a skill's name may look like: lowercase words joined
+// by hyphens
+var skillName = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+var (
+	// markdownLink matches the target of an inline link or image
+	markdownLink = regexp.MustCompile(`\]\(\s*<?([^)\s>]+)>?(?:\s+["'(][^)]*)?\)`)
+
+	// linkDefinition matches the target of a reference-style link
+	linkDefinition = regexp.MustCompile(`^ {0,3}\[[^\]]+\]:\s*<?([^\s>]+)>?`)
+
+	// codeSpan matches inline code, whose brackets are not links
+	codeSpan = regexp.MustCompile("`+[^`]*`+")
+)
+
+// LintProblem is a mistake in a file a push would publish
+type LintProblem struct {
+	// Path is the repo path of the file
+	Path string
+
+	// Line is where the problem is, counting from 1, or 0 for the whole
+	// file
+	Line int
+
+	Reason string
+}
+
+func (p LintProblem) String() string {
+	if p.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", p.Path, p.Line, p.Reason)
+	}
+	return fmt.Sprintf("%s: %s", p.Path, p.Reason)
+}
+
+// LintError is returned when a push would publish files with lint
+// problems
+type LintError struct {
+	Problems []LintProblem
+}
+
+func (e *LintError) Error() string {
+	var files []string
+	seen := map[string]bool{}
+	for _, problem := range e.Problems {
+		if !seen[problem.Path] {
+			seen[problem.Path] = true
+			files = append(files, problem.Path)
+		}
+	}
+	return fmt.Sprintf("refusing to push %d lint problem(s) in %s", len(e.Problems), strings.Join(files, ", "))
+}
+
+// Lint checks the files a push would publish: the frontmatter of OpenCode
+// agents, commands and skills, the models they and the OpenCode config
+// refer to, and the relative links of markdown files. Problems are sorted
+// by path and line.
+func (s *Syncer) Lint() ([]LintProblem, error) {
+	s.resetSyncIgnore()
+
+	all, err := s.getSyncableFiles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get syncable files: %w", err)
+	}
+
+	var files []FileInfo
+	configs := map[string]map[string]any{}
+	var problems []LintProblem
+	for _, file := range all {
+		if !s.onlyMatches(file.RelPath) {
+			continue
+		}
+		files = append(files, file)
+
+		relPath := filepath.ToSlash(file.RelPath)
+		if relPath != "opencode.json" && relPath != "opencode.jsonc" {
+			continue
+		}
+		data, err := s.readFile(file.Path)
+		if err != nil {
+			continue
+		}
+		if reason := validateConfig(relPath, data); reason != "" {
+			problems = append(problems, LintProblem{Path: relPath, Reason: reason})
+			continue
+		}
+		value, _ := jsonc.Unmarshal(data)
+		configs[relPath], _ = value.(map[string]any)
+	}
+
+	catalog := s.modelCatalog(configs)
+	agents := knownAgents(files, configs)
+
+	for _, relPath := range sortedKeys(configs) {
+		problems = append(problems, configModelProblems(relPath, configs[relPath], catalog)...)
+	}
+
+	for _, file := range files {
+		if !strings.EqualFold(filepath.Ext(file.RelPath), ".md") {
+			continue
+		}
+		data, err := s.readFile(file.Path)
+		if err != nil {
+			continue
+		}
+
+		relPath := filepath.ToSlash(file.RelPath)
+		var found []LintProblem
+		found = append(found, lintArtifact(relPath, data, catalog, agents)...)
+		found = append(found, s.brokenLinks(file.Path, data)...)
+		for _, problem := range found {
+			problem.Path = relPath
+			problems = append(problems, problem)
+		}
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].Path != problems[j].Path {
+			return problems[i].Path < problems[j].Path
+		}
+		return problems[i].Line < problems[j].Line
+	})
+	return problems, nil
+}
+
+// modelCatalog lists, for each provider OpenCode knows, the models it
+// has. A nil list accepts any model of the provider.
+type modelCatalog map[string]map[string]bool
+
+// modelCatalog returns the models OpenCode knows: those it cached from
+// models.dev, or any model of knownProviders without a cache, plus the
+// providers and models the OpenCode config defines
+func (s *Syncer) modelCatalog(configs map[string]map[string]any) modelCatalog {
+	catalog := modelCatalog{}
+
+	var cached map[string]struct {
+		Models map[string]json.RawMessage `json:"models"`
+	}
+	data, err := s.readFile(s.paths.OpenCodeModelsFile())
+	if err == nil && json.Unmarshal(data, &cached) == nil && len(cached) > 0 {
+		for provider, entry := range cached {
+			models := map[string]bool{}
+			for model := range entry.Models {
+				models[model] = true
+			}
+			catalog[provider] = models
+		}
+	} else {
+		for _, provider := range knownProviders {
+			catalog[provider] = nil
+		}
+	}
+
+	for _, relPath := range sortedKeys(configs) {
+		providers, _ := configs[relPath]["provider"].(map[string]any)
+		for name, value := range providers {
+			entry, _ := value.(map[string]any)
+			models, _ := entry["models"].(map[string]any)
+			known, ok := catalog[name]
+			switch {
+			case ok && known == nil:
+			case len(models) == 0:
+				// Without models a new provider can't be checked
+				if !ok {
+					catalog[name] = nil
+				}
+			default:
+				if known == nil {
+					known = map[string]bool{}
+					catalog[name] = known
+				}
+				for model := range models {
+					known[model] = true
+				}
+			}
+		}
+	}
+
+	return catalog
+}
+
+// check returns why OpenCode would not find a model, or "" if it would
+func (c modelCatalog) check(id string) string {
+	provider, model, ok := strings.Cut(id, "/")
+	if !ok || provider == "" || model == "" {
+		return fmt.Sprintf("model %q is not provider/model", id)
+	}
+	models, ok := c[provider]
+	if !ok {
+		return fmt.Sprintf("unknown provider %q in model %q", provider, id)
+	}
+	if models != nil && !models[model] {
+		return fmt.Sprintf("unknown model %q", id)
+	}
+	return ""
+}
+
+// configModelProblems checks the models an OpenCode config file refers
+// to
+func configModelProblems(relPath string, root map[string]any, catalog modelCatalog) []LintProblem {
+	var problems []LintProblem
+	check := func(key string, value any) {
+		id, ok := value.(string)
+		if !ok {
+			return
+		}
+		if reason := catalog.check(id); reason != "" {
+			problems = append(problems, LintProblem{Path: relPath, Reason: key + ": " + reason})
+		}
+	}
+
+	check("model", root["model"])
+	check("small_model", root["small_model"])
+	for _, key := range []string{"agent", "mode", "command"} {
+		entries, _ := root[key].(map[string]any)
+		for _, name := range sortedKeys(entries) {
+			entry, _ := entries[name].(map[string]any)
+			check(fmt.Sprintf("%s.%s.model", key, name), entry["model"])
+		}
+	}
+	return problems
+}
+
+// knownAgents returns the names of the agents commands may run with:
+// OpenCode's own, the markdown agents and modes, and those the config
+// defines
+func knownAgents(files []FileInfo, configs map[string]map[string]any) map[string]bool {
+	agents := map[string]bool{}
+	for _, name := range builtinAgents {
+		agents[name] = true
+	}
+	for _, file := range files {
+		relPath := filepath.ToSlash(file.RelPath)
+		dir, name, _ := strings.Cut(relPath, "/")
+		if (dir == "agent" || dir == "mode") && strings.HasSuffix(name, ".md") {
+			agents[strings.TrimSuffix(name, ".md")] = true
+		}
+	}
+	for _, root := range configs {
+		for _, key := range []string{"agent", "mode"} {
+			entries, _ := root[key].(map[string]any)
+			for name := range entries {
+				agents[name] = true
+			}
+		}
+	}
+	return agents
+}
+
+// lintArtifact checks the frontmatter of a markdown agent, mode, command
+// or skill. Other markdown files have none to check.
+func lintArtifact(relPath string, data []byte, catalog modelCatalog, agents map[string]bool) []LintProblem {
+	dir, _, _ := strings.Cut(relPath, "/")
+	// Claude Code skills, which OpenCode loads too, are written the same
+	skill := (dir == "skills" || dir == "claude-skills") && path.Base(relPath) == "SKILL.md"
+	if dir != "agent" && dir != "mode" && dir != "command" && !skill {
+		return nil
+	}
+
+	fm, problems := parseFrontmatter(data)
+	if len(problems) > 0 && fm == nil {
+		return problems
+	}
+	if fm == nil {
+		// Commands need none; what the others need is reported missing
+		fm = &frontmatter{Fields: map[string]frontmatterField{}, Body: string(data)}
+	}
+
+	problem := func(key, format string, args ...any) {
+		problems = append(problems, LintProblem{Line: fm.Fields[key].Line, Reason: fmt.Sprintf(format, args...)})
+	}
+	// scalar returns a key's value, reporting it if it is a map or list
+	scalar := func(key string) (string, bool) {
+		field, ok := fm.Fields[key]
+		if !ok {
+			return "", false
+		}
+		if field.Nested {
+			problem(key, "%s must be a single value", key)
+			return "", false
+		}
+		return field.Value, true
+	}
+	boolean := func(key string) {
+		if value, ok := scalar(key); ok && value != "true" && value != "false" {
+			problem(key, "%s must be true or false", key)
+		}
+	}
+	number := func(key string, max float64) {
+		value, ok := scalar(key)
+		if !ok {
+			return
+		}
+		if n, err := strconv.ParseFloat(value, 64); err != nil || n < 0 || n > max {
+			problem(key, "%s must be a number from 0 to %g", key, max)
+		}
+	}
+	model := func() {
+		if value, ok := scalar("model"); ok {
+			if reason := catalog.check(value); reason != "" {
+				problem("model", "%s", reason)
+			}
+		}
+	}
+	mapping := func(key string) {
+		field, ok := fm.Fields[key]
+		if ok && !field.Nested && !strings.HasPrefix(field.Value, "{") {
+			problem(key, "%s must be a map", key)
+		}
+	}
+
+	switch {
+	case skill:
+		name, ok := scalar("name")
+		switch {
+		case !ok || name == "":
+			problems = append(problems, LintProblem{Line: 1, Reason: "missing name"})
+		case len(name) > 64 || !skillName.MatchString(name):
+			problem("name", "name %q must be at most 64 lowercase letters, digits and hyphens", name)
+		case name != path.Base(path.Dir(relPath)):
+			problem("name", "name %q does not match the skill's directory %s", name, path.Base(path.Dir(relPath)))
+		}
+		description, ok := scalar("description")
+		switch {
+		case !ok || description == "":
+			problems = append(problems, LintProblem{Line: 1, Reason: "missing description"})
+		case len(description) > 1024:
+			problem("description", "description is longer than 1024 characters")
+		}
+
+	case dir == "command":
+		model()
+		boolean("subtask")
+		if agent, ok := scalar("agent"); ok && !agents[agent] {
+			problem("agent", "unknown agent %q", agent)
+		}
+		if strings.TrimSpace(fm.Body) == "" {
+			problems = append(problems, LintProblem{Reason: "the command has no template"})
+		}
+
+	default:
+		if description, ok := scalar("description"); !ok || description == "" {
+			problems = append(problems, LintProblem{Line: 1, Reason: "missing description"})
+		}
+		if mode, ok := scalar("mode"); ok && !slices.Contains(agentModes, mode) {
+			problem("mode", "mode must be %s", strings.Join(agentModes, ", "))
+		}
+		model()
+		number("temperature", 2)
+		number("top_p", 1)
+		boolean("disable")
+		mapping("tools")
+		mapping("permission")
+	}
+
+	return problems
+}
+
+// brokenLinks returns the relative links of a markdown file at localPath
+// whose targets don't exist. Links in code are not followed.
+func (s *Syncer) brokenLinks(localPath string, data []byte) []LintProblem {
+	var problems []LintProblem
+	dir := filepath.Dir(localPath)
+	fenced := false
+
+	for i, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fenced = !fenced
+			continue
+		}
+		if fenced {
+			continue
+		}
+
+		line = codeSpan.ReplaceAllString(line, "")
+		var targets []string
+		for _, match := range markdownLink.FindAllStringSubmatch(line, -1) {
+			targets = append(targets, match[1])
+		}
+		if match := linkDefinition.FindStringSubmatch(line); match != nil {
+			targets = append(targets, match[1])
+		}
+
+		for _, target := range targets {
+			file, ok := relativeLink(target)
+			if !ok {
+				continue
+			}
+			if _, err := s.fs.Stat(filepath.Join(dir, filepath.FromSlash(file))); err != nil {
+				problems = append(problems, LintProblem{Line: i + 1, Reason: fmt.Sprintf("broken link to %s", target)})
+			}
+		}
+	}
+	return problems
+}
+
+// relativeLink returns the file a link target points to relative to the
+// linking file. Anchors, absolute paths, URLs and templates are not
+// relative links.
+func relativeLink(target string) (string, bool) {
+	if strings.ContainsAny(target, "{$") || strings.HasPrefix(target, "/") || strings.HasPrefix(target, "~") {
+		return "", false
+	}
+	// A colon before any slash is a URL scheme, or a drive letter
+	if colon := strings.Index(target, ":"); colon >= 0 && !strings.Contains(target[:colon], "/") {
+		return "", false
+	}
+
+	file, _, _ := strings.Cut(target, "#")
+	file, _, _ = strings.Cut(file, "?")
+	if file == "" {
+		return "", false
+	}
+	if unescaped, err := url.PathUnescape(file); err == nil {
+		file = unescaped
+	}
+	return file, true
+}
+
+// sortedKeys returns the keys of a map, sorted
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
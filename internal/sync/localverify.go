@@ -0,0 +1,148 @@
+package sync
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/GareArc/opencode-sync/internal/crypto"
+)
+
+// Reasons a local file does not match the sync repository
+const (
+	MismatchModified = "modified"
+	MismatchMissing  = "missing"
+)
+
+// Mismatch is a local file whose content differs from what the last
+// commit of the sync repository would apply
+type Mismatch struct {
+	// RelPath is the path in the sync repository
+	RelPath string
+
+	// LocalPath is where the file is applied on this machine
+	LocalPath string
+
+	// Reason is MismatchModified or MismatchMissing
+	Reason string
+}
+
+// VerifyLocal re-reads every applied local file and compares it with the
+// content the sync repository's HEAD would apply, decrypting secrets and
+// restoring MCP credentials as a pull does. It detects files edited by
+// hand, corrupted or left behind by a partial pull.
+func (s *Syncer) VerifyLocal() ([]Mismatch, error) {
+	relPaths, err := s.repoFiles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list repo files: %w", err)
+	}
+
+	var secretsByFile map[string]mcpSecrets
+	if s.cfg.Sync.SplitMcpSecrets {
+		secretsByFile, err = s.loadMcpSecrets()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// This machine's secrets replace any shared copy
+	var hostSecrets []string
+	if s.hostEncryption != nil {
+		hostSecrets, err = s.hostSecretFiles()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var mismatches []Mismatch
+	check := func(relPath, localPath string, expected []byte) {
+		actual, err := s.hashFile(localPath)
+		switch {
+		case os.IsNotExist(err):
+			mismatches = append(mismatches, Mismatch{RelPath: relPath, LocalPath: localPath, Reason: MismatchMissing})
+		case err != nil || actual != fmt.Sprintf("%x", sha256.Sum256(expected)):
+			mismatches = append(mismatches, Mismatch{RelPath: relPath, LocalPath: localPath, Reason: MismatchModified})
+		}
+	}
+
+	for _, relPath := range relPaths {
+		localPath := s.localPath(relPath)
+		if localPath == "" || slices.Contains(hostSecrets, relPath) {
+			continue
+		}
+
+		// Compare with the last commit, not uncommitted repo changes
+		data, err := s.repo.ReadFileAt("HEAD", relPath)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		current := s.currentPath(relPath)
+		encrypted := (current == authRepoFile && s.cfg.Sync.IncludeAuth) ||
+			(current == mcpAuthRepoFile && s.cfg.Sync.IncludeMcpAuth) ||
+			isSessionFile(relPath)
+		_, ownRecipients := s.recipientsSecretPath(relPath)
+
+		if encrypted || ownRecipients {
+			if s.encryption == nil {
+				continue
+			}
+			data, err = s.encryption.Decrypt(data)
+			if ownRecipients && errors.Is(err, crypto.ErrNotRecipient) {
+				// Not restored on this machine
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to decrypt %s: %w", relPath, err)
+			}
+		} else if secrets := secretsByFile[relPath]; len(secrets) > 0 {
+			data, err = mergeMcpSecrets(data, secrets)
+			if err != nil {
+				return nil, fmt.Errorf("failed to merge MCP secrets into %s: %w", relPath, err)
+			}
+		}
+
+		check(relPath, localPath, data)
+	}
+
+	// Auth files kept on their own branch
+	if s.authOnBranch() && s.encryption != nil {
+		rev := "refs/heads/" + AuthBranch
+		for _, a := range s.authArtifacts() {
+			data, err := s.repo.ReadFileAt(rev, a.branchPath())
+			if err != nil {
+				continue
+			}
+			plaintext, err := s.encryption.Decrypt(data)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decrypt %s: %w", a.name, err)
+			}
+			check(a.relPath, a.localPath, plaintext)
+		}
+	}
+
+	for _, relPath := range hostSecrets {
+		localPath := s.localPath(relPath)
+		if localPath == "" {
+			continue
+		}
+
+		data, err := s.repo.ReadFileAt("HEAD", filepath.Join(hostsDir, Hostname(), hostSecretsDir, relPath+".age"))
+		if err != nil {
+			continue
+		}
+		plaintext, err := s.hostEncryption.Decrypt(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt %s: %w", relPath, err)
+		}
+		check(relPath, localPath, plaintext)
+	}
+
+	return mismatches, nil
+}
@@ -0,0 +1,104 @@
+package sync
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// IncomingSuffix is appended to a locked file's path for the version a
+// pull would have written over it
+const IncomingSuffix = ".remote"
+
+// SetLocked sets the repo paths CopyFromRepo must not overwrite. Their
+// incoming version is written next to the local file, with IncomingSuffix.
+func (s *Syncer) SetLocked(relPaths []string) {
+	s.locked = make(map[string]bool, len(relPaths))
+	for _, relPath := range relPaths {
+		s.locked[relPath] = true
+	}
+}
+
+// LockedIncoming returns the files the last CopyFromRepo wrote for locked
+// files whose incoming version differs from the local one
+func (s *Syncer) LockedIncoming() []string {
+	return s.lockedIncoming
+}
+
+// LocalPath returns where a repo path is applied on this machine, or ""
+// if it never is
+func (s *Syncer) LocalPath(relPath string) string {
+	return s.localPath(relPath)
+}
+
+// RepoPath returns the repo path of a synced file, given as an absolute
+// local path or as a repo path
+func (s *Syncer) RepoPath(path string) (string, error) {
+	relPaths, err := s.repoFiles()
+	if err != nil {
+		return "", err
+	}
+
+	files, err := s.getSyncableFiles()
+	if err != nil {
+		return "", err
+	}
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Clean(path)
+		for _, relPath := range relPaths {
+			if relPath == path && s.localPath(relPath) != "" {
+				return relPath, nil
+			}
+		}
+		for _, file := range files {
+			if file.RelPath == path {
+				return file.RelPath, nil
+			}
+		}
+		return "", fmt.Errorf("%s is not in the sync repo", path)
+	}
+
+	for _, relPath := range relPaths {
+		if s.localPath(relPath) == path {
+			return relPath, nil
+		}
+	}
+	// Not pushed yet
+	for _, file := range files {
+		if file.Path == path {
+			return file.RelPath, nil
+		}
+	}
+	return "", fmt.Errorf("%s is not synced by opencode-sync", path)
+}
+
+// isIncomingCopy reports whether a repo path is the incoming version of a
+// locked file, which is never pushed
+func (s *Syncer) isIncomingCopy(relPath string) bool {
+	name, ok := strings.CutSuffix(relPath, IncomingSuffix)
+	return ok && (s.locked[name] || s.locked[name+".age"])
+}
+
+// keepLocked compares the incoming versions CopyFromRepo wrote with the
+// locked files they belong to, removing those that hold nothing new
+func (s *Syncer) keepLocked(incoming []string) error {
+	s.lockedIncoming = nil
+	for _, path := range incoming {
+		theirs, err := s.hashFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		ours, err := s.hashFile(strings.TrimSuffix(path, IncomingSuffix))
+		if err == nil && ours == theirs {
+			if err := s.fs.Remove(path); err != nil {
+				return fmt.Errorf("failed to remove %s: %w", path, err)
+			}
+			continue
+		}
+
+		s.lockedIncoming = append(s.lockedIncoming, path)
+	}
+	return nil
+}
@@ -0,0 +1,484 @@
+package sync
+
+import (
+	"crypto/hmac"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/GareArc/opencode-sync/internal/capability"
+	"github.com/GareArc/opencode-sync/internal/crypto"
+	"github.com/GareArc/opencode-sync/internal/git"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// manifestFile describes at the repo root what a client needs to apply
+// the repo: the features its files use and the oldest version allowed
+const manifestFile = "sync-manifest.json"
+
+// Version is the version of the running opencode-sync, set at startup.
+// Builds without a release version satisfy any minimum.
+var Version = "dev"
+
+// Manifest is the sync repo's manifest. Clients refuse to apply or push to
+// a repo whose manifest asks for a newer version or features they lack,
+// instead of misreading files they don't understand.
+type Manifest struct {
+	// MinVersion is the oldest opencode-sync that may use the repo, set
+	// with 'manifest require'. Empty allows any.
+	MinVersion string `json:"minVersion,omitempty"`
+
+	// Layout is the layout of the repo's files, see RepoLayout. Zero
+	// stands for layout 1, from before layouts were recorded.
+	Layout int `json:"layout,omitempty"`
+
+	// Features are the capabilities the repo's files need, e.g. "age"
+	// for encrypted files, as listed by 'version --json'
+	Features []string `json:"features"`
+
+	// WrittenBy is the version of opencode-sync that last changed the
+	// manifest
+	WrittenBy string `json:"writtenBy"`
+
+	// Signature is a hex HMAC-SHA256 of the other fields, keyed by the
+	// shared key. Empty when written without encryption.
+	Signature string `json:"signature,omitempty"`
+
+	// unknown are the fields written by a newer version, which the
+	// signature covers too
+	unknown map[string]json.RawMessage
+}
+
+// manifestFields are the JSON names of the Manifest fields
+var manifestFields = []string{"minVersion", "layout", "features", "writtenBy", "signature"}
+
+// ManifestError is returned when this build must not use a sync repo
+type ManifestError struct {
+	// MinVersion is set when this build is older than the manifest allows
+	MinVersion string
+
+	// Layout is set when the repo has a newer layout than this build
+	// knows
+	Layout int
+
+	// Missing are the required features this build lacks
+	Missing []string
+
+	// BadSignature is set when the manifest was not signed with the
+	// shared key
+	BadSignature bool
+}
+
+func (e *ManifestError) Error() string {
+	switch {
+	case e.BadSignature:
+		return fmt.Sprintf("%s was not signed with your key; it may have been tampered with", manifestFile)
+	case e.MinVersion != "":
+		return fmt.Sprintf("the sync repo requires opencode-sync %s or newer; this is %s", e.MinVersion, Version)
+	case e.Layout != 0:
+		return fmt.Sprintf("the sync repo uses layout %d, newer than this version of opencode-sync (%s) knows", e.Layout, Version)
+	}
+	return fmt.Sprintf("the sync repo uses features this version of opencode-sync (%s) does not support: %s", Version, strings.Join(e.Missing, ", "))
+}
+
+// manifestFeatures returns the features needed by the files at relPaths
+// in the given layout
+func manifestFeatures(relPaths []string, layout int) []string {
+	features := []string{}
+	if layout > 1 {
+		features = append(features, layoutFeature(layout))
+	}
+	add := func(feature string) {
+		if !slices.Contains(features, feature) {
+			features = append(features, feature)
+		}
+	}
+
+	for _, relPath := range relPaths {
+		slashed := filepath.ToSlash(relPath)
+		parts := strings.Split(slashed, "/")
+		if strings.HasSuffix(slashed, ".age") {
+			add("age")
+		}
+		switch {
+		case slashed == mcpSecretsFile:
+			add("mcp-secrets")
+		case slashed == recipientsFile:
+			add("path-recipients")
+		case parts[0] == sessionsDir && len(parts) > 1:
+			add("sessions")
+		case parts[0] == projectsDir && len(parts) > 1:
+			add("projects")
+		case parts[0] == hostsDir && len(parts) > 3 && parts[2] == hostSecretsDir:
+			add("host-secrets")
+		}
+	}
+
+	slices.Sort(features)
+	return features
+}
+
+// parseManifest reads a manifest. Missing fields are taken as empty.
+func parseManifest(data []byte) (*Manifest, error) {
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", manifestFile, err)
+	}
+	if err := json.Unmarshal(data, &manifest.unknown); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", manifestFile, err)
+	}
+	for _, name := range manifestFields {
+		delete(manifest.unknown, name)
+	}
+	return &manifest, nil
+}
+
+// signedContent is what the signature of a manifest covers: its fields
+// in order, then those of a newer version sorted by name
+func (m *Manifest) signedContent() []byte {
+	unsigned := *m
+	unsigned.Signature = ""
+	data, _ := json.Marshal(unsigned)
+	if len(m.unknown) > 0 {
+		unknown, _ := json.Marshal(m.unknown)
+		data = append(data, unknown...)
+	}
+	return data
+}
+
+// manifestAt returns the manifest committed at rev, or nil if there is
+// none or rev does not exist yet, as in a repo without commits
+func manifestAt(repo git.Repository, rev string) (*Manifest, error) {
+	if _, err := repo.RevParse(rev); err != nil {
+		return nil, nil
+	}
+
+	data, err := repo.ReadFileAt(rev, manifestFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseManifest(data)
+}
+
+// checkManifest checks that this build satisfies the manifest committed
+// at rev. The signature is not checked here, as that takes the key.
+func checkManifest(repo git.Repository, rev string) error {
+	manifest, err := manifestAt(repo, rev)
+	if err != nil || manifest == nil {
+		return err
+	}
+	return manifest.satisfied()
+}
+
+// satisfied checks the version and features the manifest asks for
+func (m *Manifest) satisfied() error {
+	if m.MinVersion != "" && !versionAtLeast(Version, m.MinVersion) {
+		return &ManifestError{MinVersion: m.MinVersion}
+	}
+	if m.Layout > RepoLayout {
+		return &ManifestError{Layout: m.Layout}
+	}
+
+	var missing []string
+	for _, feature := range m.Features {
+		if !capability.Has(feature) {
+			missing = append(missing, feature)
+		}
+	}
+	if len(missing) > 0 {
+		return &ManifestError{Missing: missing}
+	}
+	return nil
+}
+
+// Manifest returns the manifest committed at rev, such as "HEAD", or nil
+// if the repo has none
+func (s *Syncer) Manifest(rev string) (*Manifest, error) {
+	return manifestAt(s.repo, rev)
+}
+
+// CheckManifest checks that this build may use the repo as committed at
+// rev: that it is new enough and has every feature the manifest lists,
+// and, when this machine has the shared key, that a signed manifest was
+// signed with it
+func (s *Syncer) CheckManifest(rev string) error {
+	manifest, err := s.Manifest(rev)
+	if err != nil {
+		return err
+	}
+	return s.checkManifest(manifest)
+}
+
+// CheckPulled is CheckManifest for the commits a pull just brought in,
+// with headBefore the HEAD before the pull. A repo this build does not
+// understand is not applied, so the pull is undone and the sync repo is
+// left as it was.
+func (s *Syncer) CheckPulled(headBefore string) error {
+	err := s.CheckManifest("HEAD")
+	if err == nil {
+		return nil
+	}
+	if head, _ := s.repo.GetHead(); headBefore != "" && head != headBefore {
+		if ancestor, _ := s.repo.IsAncestor(headBefore, head); ancestor {
+			if resetErr := s.repo.ResetHard(headBefore); resetErr != nil {
+				return fmt.Errorf("%w, and undoing the pull failed: %v", err, resetErr)
+			}
+		}
+	}
+	return err
+}
+
+// checkWorkingManifest is CheckManifest for the manifest about to be
+// pushed, which in the copy layout 'manifest require' and 'manifest sign'
+// may have changed since the last commit
+func (s *Syncer) checkWorkingManifest() error {
+	if s.Direct() {
+		return s.CheckManifest("HEAD")
+	}
+	manifest, err := s.loadWorkingManifest()
+	if err != nil {
+		return err
+	}
+	return s.checkManifest(manifest)
+}
+
+func (s *Syncer) checkManifest(manifest *Manifest) error {
+	if manifest == nil {
+		return nil
+	}
+	// A newer version may sign fields this one can't read, so that is
+	// reported first
+	if err := manifest.satisfied(); err != nil {
+		return err
+	}
+	if s.ManifestSigned(manifest) == SignatureInvalid {
+		return &ManifestError{BadSignature: true}
+	}
+	return nil
+}
+
+// Signature states of a manifest
+const (
+	SignatureNone      = "unsigned"
+	SignatureValid     = "valid"
+	SignatureInvalid   = "invalid"
+	SignatureUnchecked = "not checked (no key)"
+)
+
+// ManifestSigned returns the state of the manifest's signature, as far
+// as this machine can tell
+func (s *Syncer) ManifestSigned(manifest *Manifest) string {
+	if manifest.Signature == "" {
+		return SignatureNone
+	}
+	sum := s.mac(manifest.signedContent())
+	if sum == nil {
+		return SignatureUnchecked
+	}
+	if signature, err := hex.DecodeString(manifest.Signature); err != nil || !hmac.Equal(signature, sum) {
+		return SignatureInvalid
+	}
+	return SignatureValid
+}
+
+// mac returns an HMAC of data keyed by the shared key, or nil without one
+func (s *Syncer) mac(data []byte) []byte {
+	enc, ok := s.encryption.(timedEncryption)
+	if !ok {
+		return nil
+	}
+	keyed, ok := enc.Encryption.(interface{ MAC([]byte) ([]byte, error) })
+	if !ok {
+		return nil
+	}
+	sum, err := keyed.MAC(data)
+	if err != nil {
+		return nil
+	}
+	return sum
+}
+
+// loadWorkingManifest reads the manifest in the sync repo's working
+// tree, which 'manifest require' may have changed since the last commit
+func (s *Syncer) loadWorkingManifest() (*Manifest, error) {
+	data, err := util.ReadFile(s.fs, filepath.Join(s.paths.SyncRepoDir(), manifestFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", manifestFile, err)
+	}
+	return parseManifest(data)
+}
+
+// saveManifest signs the manifest and writes it to the sync repo
+func (s *Syncer) saveManifest(manifest *Manifest) error {
+	manifest.WrittenBy = Version
+	manifest.unknown = nil
+	manifest.Signature = ""
+	if sum := s.mac(manifest.signedContent()); sum != nil {
+		manifest.Signature = hex.EncodeToString(sum)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", manifestFile, err)
+	}
+	if err := util.WriteFile(s.fs, filepath.Join(s.paths.SyncRepoDir(), manifestFile), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", manifestFile, err)
+	}
+	return nil
+}
+
+// writeManifest updates the manifest for the files CopyToRepo left in the
+// repo. It is only rewritten when what it requires changed or it lacks a
+// valid signature, so machines on different versions don't keep
+// rewriting it.
+func (s *Syncer) writeManifest() error {
+	relPaths, err := s.repoFiles()
+	if err != nil {
+		return fmt.Errorf("failed to list repo files: %w", err)
+	}
+
+	existing, err := s.loadWorkingManifest()
+	if err != nil {
+		// Written anew below
+		existing = nil
+	}
+
+	// MigrateLayout already brought the repo to the current layout
+	manifest := &Manifest{Layout: RepoLayout, Features: manifestFeatures(relPaths, RepoLayout)}
+	if existing != nil {
+		manifest.MinVersion = existing.MinVersion
+		manifest.Layout = existing.Layout
+		if slices.Equal(existing.Features, manifest.Features) {
+			switch s.ManifestSigned(existing) {
+			case SignatureValid, SignatureUnchecked:
+				return nil
+			case SignatureNone:
+				if s.mac(nil) == nil {
+					return nil
+				}
+			}
+		}
+	}
+
+	return s.saveManifest(manifest)
+}
+
+// SignManifest signs the manifest of the working tree again with the
+// shared key, accepting what it says. The next push commits it.
+func (s *Syncer) SignManifest() error {
+	if s.Direct() {
+		return fmt.Errorf("the manifest is not supported with repo.layout direct")
+	}
+	if s.mac(nil) == nil {
+		return fmt.Errorf("signing the manifest requires encryption to be enabled: %w", crypto.ErrKeyMissing)
+	}
+
+	manifest, err := s.loadWorkingManifest()
+	if err != nil {
+		return err
+	}
+	if manifest == nil {
+		return fmt.Errorf("the sync repo has no %s yet; push to write one", manifestFile)
+	}
+	// Rewriting drops what a newer version added
+	if err := manifest.satisfied(); err != nil {
+		return err
+	}
+	return s.saveManifest(manifest)
+}
+
+// RequireVersion sets the oldest opencode-sync allowed to use the repo in
+// the manifest of the working tree, or allows any if version is empty.
+// The next push commits it.
+func (s *Syncer) RequireVersion(version string) error {
+	if s.Direct() {
+		return fmt.Errorf("the manifest is not supported with repo.layout direct")
+	}
+	if version != "" {
+		if _, ok := parseVersion(version); !ok {
+			return fmt.Errorf("invalid version %q: use a release version such as 1.4.0", version)
+		}
+		if !versionAtLeast(Version, version) {
+			return fmt.Errorf("this is opencode-sync %s, older than %s", Version, version)
+		}
+	}
+
+	manifest, err := s.loadWorkingManifest()
+	if err != nil {
+		return err
+	}
+	if manifest == nil {
+		relPaths, err := s.repoFiles()
+		if err != nil {
+			return fmt.Errorf("failed to list repo files: %w", err)
+		}
+		layout, err := s.Layout()
+		if err != nil {
+			return err
+		}
+		manifest = &Manifest{Layout: layout, Features: manifestFeatures(relPaths, layout)}
+	} else if err := manifest.satisfied(); err != nil {
+		return err
+	}
+	manifest.MinVersion = strings.TrimPrefix(version, "v")
+	return s.saveManifest(manifest)
+}
+
+// parseVersion reads a release version such as 1.4.0 or v1.4.0-rc1 into
+// its numbers, ignoring any pre-release or build suffix
+func parseVersion(version string) ([]int, bool) {
+	version = strings.TrimPrefix(version, "v")
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		version = version[:i]
+	}
+
+	fields := strings.Split(version, ".")
+	numbers := make([]int, 0, len(fields))
+	for _, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return nil, false
+		}
+		numbers = append(numbers, n)
+	}
+	return numbers, true
+}
+
+// versionAtLeast reports whether version is min or newer. A version that
+// is not a release version, such as "dev", is taken to be the newest.
+func versionAtLeast(version, min string) bool {
+	have, ok := parseVersion(version)
+	if !ok {
+		return true
+	}
+	want, ok := parseVersion(min)
+	if !ok {
+		return true
+	}
+
+	for i := 0; i < max(len(have), len(want)); i++ {
+		var h, w int
+		if i < len(have) {
+			h = have[i]
+		}
+		if i < len(want) {
+			w = want[i]
+		}
+		if h != w {
+			return h > w
+		}
+	}
+	return true
+}
@@ -0,0 +1,227 @@
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/GareArc/opencode-sync/internal/capability"
+	"github.com/GareArc/opencode-sync/internal/crypto"
+	"github.com/GareArc/opencode-sync/internal/jsonc"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func init() {
+	capability.Register(capability.Capability{
+		Name:        "mcp-secrets",
+		Kind:        capability.KindFeature,
+		Description: "Encrypt MCP server credentials separately from the config",
+	})
+}
+
+// mcpSecretsFile holds the encrypted MCP credentials split out of the
+// OpenCode config files
+const mcpSecretsFile = "mcp-secrets.json.age"
+
+// mcpSecretPlaceholder replaces secret values in the plaintext config
+const mcpSecretPlaceholder = "<encrypted>"
+
+// mcpSecretFields are the per-server fields that may carry credentials
+var mcpSecretFields = []string{"headers", "environment", "oauth"}
+
+// mcpConfigFiles are the OpenCode config files that may define MCP servers
+var mcpConfigFiles = []string{"opencode.json", "opencode.jsonc"}
+
+// mcpSecrets maps server name to secret field name to its original value
+type mcpSecrets map[string]map[string]any
+
+// splitMcpSecrets replaces secret MCP fields in an OpenCode config with
+// placeholders and returns the redacted config and the extracted secrets
+func splitMcpSecrets(data []byte) ([]byte, mcpSecrets, error) {
+	tree, doc, err := parseConfig(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	secrets := mcpSecrets{}
+
+	servers, _ := doc["mcp"].(map[string]any)
+	for name, raw := range servers {
+		server, ok := raw.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		for _, field := range mcpSecretFields {
+			value, ok := server[field]
+			if !ok {
+				continue
+			}
+
+			if secrets[name] == nil {
+				secrets[name] = map[string]any{}
+			}
+			secrets[name][field] = value
+			server[field] = redactValue(value)
+		}
+	}
+
+	if len(secrets) == 0 {
+		return data, secrets, nil
+	}
+
+	return formatConfig(tree, doc), secrets, nil
+}
+
+// mergeMcpSecrets restores secret MCP fields into a redacted config
+func mergeMcpSecrets(data []byte, secrets mcpSecrets) ([]byte, error) {
+	if len(secrets) == 0 {
+		return data, nil
+	}
+
+	tree, doc, err := parseConfig(data)
+	if err != nil {
+		return nil, err
+	}
+
+	servers, _ := doc["mcp"].(map[string]any)
+	for name, fields := range secrets {
+		server, ok := servers[name].(map[string]any)
+		if !ok {
+			continue // Server was removed upstream
+		}
+
+		for field, value := range fields {
+			server[field] = value
+		}
+	}
+
+	return formatConfig(tree, doc), nil
+}
+
+// parseConfig parses an OpenCode config, which may be JSONC, returning the
+// tree that keeps its comments and the top-level object as plain values
+func parseConfig(data []byte) (*jsonc.Document, map[string]any, error) {
+	tree, err := jsonc.Parse(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	doc, ok := tree.Value.Interface().(map[string]any)
+	if !ok {
+		return nil, nil, fmt.Errorf("failed to parse config: not a JSON object")
+	}
+
+	return tree, doc, nil
+}
+
+// formatConfig writes doc back into the tree it was parsed from and
+// encodes it with two-space indentation. Members that remain keep their
+// order, comments and trailing commas; new members are appended.
+func formatConfig(tree *jsonc.Document, doc map[string]any) []byte {
+	tree.Value.Update(doc)
+	return jsonc.Format(tree)
+}
+
+// redactValue keeps the keys of an object but replaces every value
+func redactValue(value any) any {
+	obj, ok := value.(map[string]any)
+	if !ok {
+		return mcpSecretPlaceholder
+	}
+
+	redacted := make(map[string]any, len(obj))
+	for key := range obj {
+		redacted[key] = mcpSecretPlaceholder
+	}
+	return redacted
+}
+
+// splitMcpConfigs redacts the OpenCode config files already copied into
+// the repo and writes their secrets to mcpSecretsFile
+func (s *Syncer) splitMcpConfigs() error {
+	if s.encryption == nil {
+		return fmt.Errorf("sync.splitMcpSecrets requires encryption to be enabled: %w", crypto.ErrKeyMissing)
+	}
+
+	all := map[string]mcpSecrets{}
+
+	for _, name := range mcpConfigFiles {
+		repoPath := filepath.Join(s.paths.SyncRepoDir(), name)
+
+		data, err := s.readFile(repoPath)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", name, err)
+		}
+
+		redacted, secrets, err := splitMcpSecrets(data)
+		if err != nil {
+			return fmt.Errorf("failed to split MCP secrets from %s: %w", name, err)
+		}
+		if len(secrets) == 0 {
+			continue
+		}
+
+		if err := util.WriteFile(s.fs, repoPath, redacted, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		all[name] = secrets
+	}
+
+	secretsPath := filepath.Join(s.paths.SyncRepoDir(), mcpSecretsFile)
+	if len(all) == 0 {
+		if err := s.fs.Remove(secretsPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", mcpSecretsFile, err)
+		}
+		return nil
+	}
+
+	plaintext, err := json.Marshal(all)
+	if err != nil {
+		return fmt.Errorf("failed to marshal MCP secrets: %w", err)
+	}
+
+	return s.writeEncrypted("MCP secrets", secretsPath, plaintext)
+}
+
+// loadMcpSecrets decrypts mcpSecretsFile from the repo, if present
+func (s *Syncer) loadMcpSecrets() (map[string]mcpSecrets, error) {
+	ciphertext, err := util.ReadFile(s.fs, filepath.Join(s.paths.SyncRepoDir(), mcpSecretsFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", mcpSecretsFile, err)
+	}
+
+	if s.encryption == nil {
+		return nil, fmt.Errorf("found encrypted MCP secrets but encryption is not enabled: %w", crypto.ErrKeyMissing)
+	}
+
+	plaintext, err := s.encryption.Decrypt(ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt MCP secrets: %w", err)
+	}
+
+	var all map[string]mcpSecrets
+	if err := json.Unmarshal(plaintext, &all); err != nil {
+		return nil, fmt.Errorf("failed to parse MCP secrets: %w", err)
+	}
+
+	return all, nil
+}
+
+// isMcpConfigFile reports whether relPath is an OpenCode config file
+// subject to MCP secret splitting
+func isMcpConfigFile(relPath string) bool {
+	for _, name := range mcpConfigFiles {
+		if relPath == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+package sync
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/GareArc/opencode-sync/internal/crypto"
+	"github.com/GareArc/opencode-sync/internal/git"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// readFile reads a file that has to be held in memory whole, such as a
+// config to parse. Files larger than sync.maxMemoryFileSizeMB are refused
+// with a *git.FileTooLargeError; copying, hashing and encrypting stream
+// instead and have no limit.
+func (s *Syncer) readFile(path string) ([]byte, error) {
+	info, err := s.fs.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if limit := s.cfg.Sync.MaxMemoryFileSize(); info.Size() > limit {
+		return nil, &git.FileTooLargeError{Path: path, Size: info.Size(), Limit: limit}
+	}
+
+	return util.ReadFile(s.fs, path)
+}
+
+// tooLarge reports whether err is readFile refusing a file
+func tooLarge(err error) bool {
+	var large *git.FileTooLargeError
+	return errors.As(err, &large)
+}
+
+// decryptedHash returns the hash of what the encrypted file at path
+// decrypts to
+func (s *Syncer) decryptedHash(enc crypto.Encryption, path string) (string, error) {
+	f, err := s.fs.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if err := enc.DecryptReader(f, h); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// encryptedCurrent reports whether the encrypted file at path decrypts to
+// content with the given hash. Age ciphertext differs on every run, so a
+// current file is left untouched; see writeEncrypted.
+func (s *Syncer) encryptedCurrent(enc crypto.Encryption, path, hash string) bool {
+	decrypted, err := s.decryptedHash(enc, path)
+	return err == nil && decrypted == hash
+}
+
+// encryptFileVerifiedWith is encryptFileVerified with an explicit key
+func (s *Syncer) encryptFileVerifiedWith(enc crypto.Encryption, name, src, dst string) error {
+	hash, err := s.hashFile(src)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	if s.encryptedCurrent(enc, dst, hash) {
+		return nil
+	}
+
+	return s.streamEncrypted(name, src, dst, enc.EncryptReader, enc)
+}
+
+// streamEncrypted writes src through encrypt into dst. When verify is set
+// the result must decrypt with it back to what was read from src, as in
+// encryptVerified. dst is only replaced by a verified file.
+func (s *Syncer) streamEncrypted(name, src, dst string, encrypt func(io.Reader, io.Writer) error, verify crypto.Encryption) error {
+	in, err := s.fs.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	defer in.Close()
+
+	if err := s.fs.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	tmp := dst + ".tmp"
+	out, err := s.fs.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to write encrypted %s: %w", name, err)
+	}
+	defer s.fs.Remove(tmp)
+
+	h := sha256.New()
+	err = encrypt(io.TeeReader(in, h), out)
+	if closeErr := out.Close(); err == nil && closeErr != nil {
+		return fmt.Errorf("failed to write encrypted %s: %w", name, closeErr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encrypt %s: %w", name, err)
+	}
+
+	if verify != nil {
+		decrypted, err := s.decryptedHash(verify, tmp)
+		if err != nil {
+			return &VerificationError{Name: name, Err: err}
+		}
+		if decrypted != fmt.Sprintf("%x", h.Sum(nil)) {
+			return &VerificationError{Name: name}
+		}
+	}
+
+	if err := s.fs.Rename(tmp, dst); err != nil {
+		return fmt.Errorf("failed to write encrypted %s: %w", name, err)
+	}
+	return nil
+}
+
+// streamDecrypted writes src decrypted with enc into dst with owner-only
+// permissions. dst is left alone if src does not decrypt.
+func (s *Syncer) streamDecrypted(enc crypto.Encryption, src, dst string) error {
+	in, err := s.fs.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := s.fs.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	tmp := dst + ".tmp"
+	out, err := s.fs.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer s.fs.Remove(tmp)
+
+	err = enc.DecryptReader(in, out)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.fs.Rename(tmp, dst)
+}
@@ -0,0 +1,176 @@
+package sync
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// metadataFile records modification times and executable flags of synced
+// files at the repo root. Git keeps neither times nor, when a file passes
+// through Windows or FAT, executable bits.
+const metadataFile = "file-metadata.json"
+
+// fileMetadata is what is recorded for one file
+type fileMetadata struct {
+	ModTime    time.Time `json:"mtime,omitzero"`
+	Executable bool      `json:"executable,omitempty"`
+}
+
+// preservesMetadata reports whether file metadata is recorded and restored
+func (s *Syncer) preservesMetadata() bool {
+	return s.cfg.Sync.PreserveMtimes || s.cfg.Sync.PreserveExecutable
+}
+
+// execBits reports whether this platform stores executable flags. On
+// Windows they are always unset, so recorded flags are kept instead.
+func execBits() bool {
+	return runtime.GOOS != "windows"
+}
+
+// loadMetadata reads the metadata file, keyed by repo path. A missing
+// file yields an empty map.
+func (s *Syncer) loadMetadata() (map[string]fileMetadata, error) {
+	metadata := map[string]fileMetadata{}
+
+	data, err := util.ReadFile(s.fs, filepath.Join(s.paths.SyncRepoDir(), metadataFile))
+	if os.IsNotExist(err) {
+		return metadata, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", metadataFile, err)
+	}
+
+	var stored map[string]fileMetadata
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", metadataFile, err)
+	}
+	// Stored with forward slashes on every platform
+	for relPath, entry := range stored {
+		metadata[filepath.FromSlash(relPath)] = entry
+	}
+
+	return metadata, nil
+}
+
+// recordMetadata updates the metadata file from the local files copied
+// into the repo. A file keeps its recorded time while its content matches
+// HEAD, so touching a file without changing it makes no commit.
+func (s *Syncer) recordMetadata() error {
+	old, err := s.loadMetadata()
+	if err != nil {
+		return err
+	}
+
+	// Keep entries for files this machine does not sync
+	metadata := map[string]fileMetadata{}
+	repoDir := s.paths.SyncRepoDir()
+	for relPath, entry := range old {
+		if _, err := s.fs.Stat(filepath.Join(repoDir, relPath)); err == nil {
+			metadata[relPath] = entry
+		}
+	}
+
+	files, err := s.getSyncableFiles()
+	if err != nil {
+		return fmt.Errorf("failed to get syncable files: %w", err)
+	}
+
+	for _, file := range files {
+		if s.isHostSecret(file.RelPath) || s.hasRecipients(file.RelPath) {
+			continue
+		}
+
+		info, err := s.fs.Stat(file.Path)
+		if err != nil {
+			return fmt.Errorf("failed to stat %s: %w", file.Path, err)
+		}
+
+		// Fields not preserved here are left as other machines recorded them
+		entry := old[file.RelPath]
+		if s.cfg.Sync.PreserveMtimes && (entry.ModTime.IsZero() || !s.matchesHead(file)) {
+			entry.ModTime = info.ModTime().UTC().Truncate(time.Second)
+		}
+		if s.cfg.Sync.PreserveExecutable && execBits() {
+			entry.Executable = info.Mode()&0111 != 0
+		}
+
+		if entry == (fileMetadata{}) {
+			delete(metadata, file.RelPath)
+		} else {
+			metadata[file.RelPath] = entry
+		}
+	}
+
+	return s.saveMetadata(metadata)
+}
+
+// matchesHead reports whether a local file has the content committed at
+// HEAD
+func (s *Syncer) matchesHead(file FileInfo) bool {
+	data, err := s.repo.ReadFileAt("HEAD", filepath.ToSlash(file.RelPath))
+	if err != nil {
+		return false
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data)) == s.repoFormHash(file)
+}
+
+// saveMetadata writes the metadata file, or removes it when empty
+func (s *Syncer) saveMetadata(metadata map[string]fileMetadata) error {
+	path := filepath.Join(s.paths.SyncRepoDir(), metadataFile)
+
+	if len(metadata) == 0 {
+		if err := s.fs.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", metadataFile, err)
+		}
+		return nil
+	}
+
+	stored := make(map[string]fileMetadata, len(metadata))
+	for relPath, entry := range metadata {
+		stored[filepath.ToSlash(relPath)] = entry
+	}
+
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", metadataFile, err)
+	}
+
+	if err := util.WriteFile(s.fs, path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", metadataFile, err)
+	}
+
+	return nil
+}
+
+// applyMetadata restores the recorded executable flag and modification
+// time of a file copied from the repo
+func (s *Syncer) applyMetadata(dst string, entry fileMetadata) error {
+	if s.cfg.Sync.PreserveExecutable && entry.Executable && execBits() {
+		info, err := s.fs.Stat(dst)
+		if err != nil {
+			return fmt.Errorf("failed to stat %s: %w", dst, err)
+		}
+		// Executable by whoever can read it
+		mode := info.Mode() | (info.Mode()&0444)>>2
+		if mode != info.Mode() {
+			if err := s.chmod(dst, mode); err != nil {
+				return fmt.Errorf("failed to set mode of %s: %w", dst, err)
+			}
+		}
+	}
+
+	if s.cfg.Sync.PreserveMtimes && !entry.ModTime.IsZero() {
+		if err := s.chtimes(dst, entry.ModTime); err != nil {
+			return fmt.Errorf("failed to set modification time of %s: %w", dst, err)
+		}
+	}
+
+	return nil
+}
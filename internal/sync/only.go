@@ -0,0 +1,91 @@
+package sync
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// SetOnly limits the next CopyToRepo, CopyFromRepo and BackupLocal to
+// repo paths matching one of the glob patterns. A "**" segment matches
+// any number of directories, and a pattern naming a directory selects
+// everything below it. No patterns means everything.
+func (s *Syncer) SetOnly(patterns []string) error {
+	if len(patterns) > 0 && s.Direct() {
+		// Every change in the working tree is committed
+		return fmt.Errorf("selecting paths is not supported with repo.layout direct")
+	}
+
+	for _, pattern := range patterns {
+		if _, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+
+	s.only = patterns
+	return nil
+}
+
+// partial reports whether copies are limited to some repo paths, so a
+// copy cannot tell which files were removed
+func (s *Syncer) partial() bool {
+	return len(s.only) > 0 || s.due != nil
+}
+
+// onlyMatches reports whether a repo path is selected by SetOnly and SetDue
+func (s *Syncer) onlyMatches(relPath string) bool {
+	if s.due != nil && !s.due[s.Target(relPath)] {
+		return false
+	}
+	if len(s.only) == 0 {
+		return true
+	}
+
+	segments := strings.Split(filepath.ToSlash(relPath), "/")
+	for _, pattern := range s.only {
+		patternSegments := strings.Split(strings.Trim(filepath.ToSlash(pattern), "/"), "/")
+
+		// A directory pattern selects everything below it
+		for i := len(segments); i > 0; i-- {
+			if matchSegments(patternSegments, segments[:i]) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// matchSegments matches path segments against pattern segments, where a
+// "**" pattern segment matches zero or more path segments
+func matchSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(segments) == 0 {
+		return false
+	}
+
+	if matched, _ := path.Match(pattern[0], segments[0]); !matched {
+		return false
+	}
+
+	return matchSegments(pattern[1:], segments[1:])
+}
+
+// LiteralPattern returns a SetOnly pattern that matches relPath itself,
+// even if it contains glob characters
+func LiteralPattern(relPath string) string {
+	return strings.NewReplacer("*", "[*]", "?", "[?]", "[", "[[]").Replace(relPath)
+}
@@ -0,0 +1,150 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sources copied concurrently by CopyToRepo and CopyFromRepo. Each
+// enabled project is a source of its own, named after its repo directory
+// (see sourceOf).
+const (
+	SourceOpenCode = "opencode"
+	SourceClaude   = "claude"
+	SourceSessions = "sessions"
+)
+
+// SourceResult is how copying one source went
+type SourceResult struct {
+	Name     string
+	Duration time.Duration
+	Err      error
+}
+
+// SourceError is returned for a source that failed to copy. The other
+// sources are still copied; CopyToRepo and CopyFromRepo join the errors of
+// every source that failed.
+type SourceError struct {
+	Source string
+	Err    error
+}
+
+func (e *SourceError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Source, e.Err)
+}
+
+func (e *SourceError) Unwrap() error {
+	return e.Err
+}
+
+// SourceResults returns how each source was copied by the last CopyToRepo
+// or CopyFromRepo
+func (s *Syncer) SourceResults() []SourceResult {
+	return s.sources
+}
+
+// sourceOf returns the source a repo path belongs to
+func (s *Syncer) sourceOf(relPath string) string {
+	if _, ok := s.claudeLocalPath(relPath); ok {
+		return SourceClaude
+	}
+	if isSessionFile(relPath) {
+		return SourceSessions
+	}
+
+	sep := string(filepath.Separator)
+	if rest, ok := strings.CutPrefix(relPath, projectsDir+sep); ok {
+		name, _, _ := strings.Cut(rest, sep)
+		return projectsDir + "/" + name
+	}
+	return SourceOpenCode
+}
+
+// copySources runs fn for each source, on as many at once as
+// sync.parallelism allows. Each runs on its own fork of the syncer; their
+// counters, collected files and timings are added to the syncer's in the
+// order of names once all are done.
+func (s *Syncer) copySources(ctx context.Context, names []string, fn func(ctx context.Context, w *Syncer, name string) error) error {
+	// Load what the forks share, so none of them loads its own
+	s.syncIgnoreRules()
+	s.hashes()
+
+	workers := make([]*Syncer, len(names))
+	s.sources = make([]SourceResult, len(names))
+
+	limit := make(chan struct{}, s.cfg.Sync.Parallel())
+	var wg sync.WaitGroup
+	start := time.Now()
+
+	for i, name := range names {
+		workers[i] = s.fork()
+		wg.Add(1)
+		go func(i int, w *Syncer, name string) {
+			defer wg.Done()
+			limit <- struct{}{}
+			defer func() { <-limit }()
+
+			begin := time.Now()
+			err := fn(ctx, w, name)
+			s.sources[i] = SourceResult{Name: name, Duration: time.Since(begin), Err: err}
+		}(i, workers[i], name)
+	}
+	wg.Wait()
+
+	var errs []error
+	totals := make([]map[string]time.Duration, len(workers))
+	for i, w := range workers {
+		s.join(w)
+		totals[i] = w.timings.Totals()
+
+		if err := s.sources[i].Err; err != nil {
+			// Cancellation stops every source; say so once
+			if ctx.Err() != nil {
+				continue
+			}
+			errs = append(errs, &SourceError{Source: names[i], Err: err})
+		}
+	}
+	s.timings.addConcurrent(totals, time.Since(start))
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return errors.Join(errs...)
+}
+
+// fork returns a copy of the syncer that copies one source concurrently
+// with others. It shares the configuration, repo, keys and caches, which
+// copying only reads, and counts and times what it copies on its own.
+func (s *Syncer) fork() *Syncer {
+	w := *s
+	w.stats = CopyStats{}
+	w.timings = Timings{}
+	w.skippedBinaries = nil
+	w.hostSecrets = nil
+	w.recipientsSecrets = nil
+	w.sources = nil
+
+	// Encryption is timed on the syncer it runs on
+	if enc, ok := s.encryption.(timedEncryption); ok {
+		w.encryption = w.timed(enc.Encryption)
+	}
+	if enc, ok := s.hostEncryption.(timedEncryption); ok {
+		w.hostEncryption = w.timed(enc.Encryption)
+	}
+	return &w
+}
+
+// join adds what a fork copied to the syncer
+func (s *Syncer) join(w *Syncer) {
+	s.stats.Reflinked += w.stats.Reflinked
+	s.stats.Copied += w.stats.Copied
+	s.skippedBinaries = append(s.skippedBinaries, w.skippedBinaries...)
+	s.hostSecrets = append(s.hostSecrets, w.hostSecrets...)
+	s.recipientsSecrets = append(s.recipientsSecrets, w.recipientsSecrets...)
+}
@@ -0,0 +1,39 @@
+package sync
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/GareArc/opencode-sync/internal/capability"
+)
+
+func init() {
+	capability.Register(capability.Capability{
+		Name:        "projects",
+		Kind:        capability.KindFeature,
+		Description: "Sync per-project .opencode directories and AGENTS.md",
+	})
+}
+
+// projectsDir is the repo directory holding per-project configs
+const projectsDir = "projects"
+
+// projectSyncPaths are the entries synced from each project directory
+var projectSyncPaths = []string{".opencode", "AGENTS.md"}
+
+// projectLocalPath maps projects/<name>/<path> to the registered project
+// directory. Unknown or disabled projects have no local destination.
+func (s *Syncer) projectLocalPath(relPath string) string {
+	parts := strings.SplitN(relPath, string(filepath.Separator), 3)
+	if len(parts) < 3 {
+		return ""
+	}
+
+	for _, project := range s.cfg.Projects {
+		if project.Name == parts[1] && project.Enabled {
+			return filepath.Join(project.Path, parts[2])
+		}
+	}
+
+	return ""
+}
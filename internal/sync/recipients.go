@@ -0,0 +1,416 @@
+package sync
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/GareArc/opencode-sync/internal/capability"
+	"github.com/GareArc/opencode-sync/internal/crypto"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func init() {
+	capability.Register(capability.Capability{
+		Name:        "path-recipients",
+		Kind:        capability.KindFeature,
+		Description: "Encrypt matching paths to their own list of public keys",
+	})
+}
+
+// recipientsFile holds the repo's recipient policy. It lives in the repo
+// so everyone sharing it encrypts to the same keys.
+const recipientsFile = "recipients.json"
+
+// RecipientRule encrypts the files matching Path to Recipients only
+type RecipientRule struct {
+	// Path is a glob, file name or directory, as for sync.hostSecrets
+	Path string `json:"path"`
+
+	// Recipients are age public keys, sorted
+	Recipients []string `json:"recipients"`
+}
+
+// RecipientPolicy is the recipient policy of a sync repo. Files matching
+// a rule are stored as <path>.age, encrypted to the rule's recipients
+// instead of the shared key. Age files do not name their recipients, so
+// Files records which keys each one was last encrypted to, by path.
+type RecipientPolicy struct {
+	Rules []RecipientRule     `json:"rules"`
+	Files map[string][]string `json:"files,omitempty"`
+}
+
+// RecipientsFor returns the recipients of a repo path. When several rules
+// match, the last one wins, as in .gitattributes.
+func (p *RecipientPolicy) RecipientsFor(relPath string) ([]string, bool) {
+	for i := len(p.Rules) - 1; i >= 0; i-- {
+		if matchPathPatterns([]string{p.Rules[i].Path}, relPath) {
+			return p.Rules[i].Recipients, true
+		}
+	}
+	return nil, false
+}
+
+// Add adds recipients to the rule for path, creating it if needed, and
+// reports whether anything changed
+func (p *RecipientPolicy) Add(path string, recipients ...string) bool {
+	i := slices.IndexFunc(p.Rules, func(rule RecipientRule) bool { return rule.Path == path })
+	if i < 0 {
+		p.Rules = append(p.Rules, RecipientRule{Path: path})
+		i = len(p.Rules) - 1
+	}
+
+	rule := &p.Rules[i]
+	before := len(rule.Recipients)
+	for _, recipient := range recipients {
+		if !slices.Contains(rule.Recipients, recipient) {
+			rule.Recipients = append(rule.Recipients, recipient)
+		}
+	}
+	slices.Sort(rule.Recipients)
+
+	return before == 0 || len(rule.Recipients) != before
+}
+
+// Remove removes a recipient from the rule for path, or the whole rule
+// if recipient is empty, and reports whether anything changed. A rule
+// left without recipients is removed.
+func (p *RecipientPolicy) Remove(path, recipient string) bool {
+	i := slices.IndexFunc(p.Rules, func(rule RecipientRule) bool { return rule.Path == path })
+	if i < 0 {
+		return false
+	}
+
+	rule := &p.Rules[i]
+	if recipient != "" {
+		j := slices.Index(rule.Recipients, recipient)
+		if j < 0 {
+			return false
+		}
+		rule.Recipients = slices.Delete(rule.Recipients, j, j+1)
+	}
+
+	if recipient == "" || len(rule.Recipients) == 0 {
+		p.Rules = slices.Delete(p.Rules, i, i+1)
+	}
+	return true
+}
+
+// LoadRecipientPolicy reads the recipient policy from the sync repo. A
+// repo without one has no rules.
+func (s *Syncer) LoadRecipientPolicy() (*RecipientPolicy, error) {
+	policy := &RecipientPolicy{}
+
+	data, err := util.ReadFile(s.fs, filepath.Join(s.paths.SyncRepoDir(), recipientsFile))
+	if os.IsNotExist(err) {
+		return policy, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", recipientsFile, err)
+	}
+
+	if err := json.Unmarshal(data, policy); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", recipientsFile, err)
+	}
+	return policy, nil
+}
+
+// SaveRecipientPolicy writes the recipient policy to the sync repo, or
+// removes the file once it is empty
+func (s *Syncer) SaveRecipientPolicy(policy *RecipientPolicy) error {
+	path := filepath.Join(s.paths.SyncRepoDir(), recipientsFile)
+	s.recipients = policy
+
+	if len(policy.Rules) == 0 && len(policy.Files) == 0 {
+		if err := s.fs.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", recipientsFile, err)
+		}
+		return nil
+	}
+
+	data, err := json.MarshalIndent(policy, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", recipientsFile, err)
+	}
+	if err := util.WriteFile(s.fs, path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", recipientsFile, err)
+	}
+	return nil
+}
+
+// OwnRecipient returns the public key of the shared key, which is what
+// this machine is listed as in recipient rules, or "" without one
+func (s *Syncer) OwnRecipient() string {
+	if enc, ok := s.encryption.(timedEncryption); ok {
+		if keyed, ok := enc.Encryption.(interface{ PublicKey() string }); ok {
+			return keyed.PublicKey()
+		}
+	}
+	return ""
+}
+
+// reloadRecipients reads the recipient policy again, after a pull or
+// before a push may have changed it
+func (s *Syncer) reloadRecipients() error {
+	policy, err := s.LoadRecipientPolicy()
+	if err != nil {
+		return err
+	}
+	s.recipients = policy
+	return nil
+}
+
+// recipientPolicy returns the recipient policy, loading it on first use.
+// One that cannot be read has no rules here; push and pull report it.
+func (s *Syncer) recipientPolicy() *RecipientPolicy {
+	if s.recipients == nil {
+		if err := s.reloadRecipients(); err != nil {
+			return &RecipientPolicy{}
+		}
+	}
+	return s.recipients
+}
+
+// recipientCandidate reports whether a repo path can be covered by a
+// recipient rule. Files already encrypted to the shared key or a host key,
+// and the repo's own bookkeeping, are not.
+func recipientCandidate(relPath string) bool {
+	switch relPath {
+	case "auth.json", "mcp-auth.json", "auth.json.age", "mcp-auth.json.age", authRepoFile, mcpAuthRepoFile, mcpSecretsFile, metadataFile, recipientsFile, manifestFile:
+		return false
+	}
+	return !isSessionFile(relPath) && !strings.HasPrefix(relPath, hostsDir+string(filepath.Separator))
+}
+
+// hasRecipients reports whether a local file is stored encrypted to its
+// own recipients
+func (s *Syncer) hasRecipients(relPath string) bool {
+	if !recipientCandidate(relPath) {
+		return false
+	}
+	_, ok := s.recipientPolicy().RecipientsFor(relPath)
+	return ok
+}
+
+// recipientsSecretPath returns the path of the file an .age repo path
+// encrypted to its own recipients decrypts to
+func (s *Syncer) recipientsSecretPath(relPath string) (string, bool) {
+	plain, ok := strings.CutSuffix(relPath, ".age")
+	if !ok || !recipientCandidate(plain) {
+		return "", false
+	}
+
+	policy := s.recipientPolicy()
+	if _, ok := policy.RecipientsFor(plain); ok {
+		return plain, true
+	}
+	if _, ok := policy.Files[filepath.ToSlash(plain)]; ok {
+		return plain, true
+	}
+	return "", false
+}
+
+// recipientsSecretCurrent reports whether the repo holds the current
+// content of a local file encrypted to the current recipients
+func (s *Syncer) recipientsSecretCurrent(file FileInfo) bool {
+	policy := s.recipientPolicy()
+	recipients, _ := policy.RecipientsFor(file.RelPath)
+	if !slices.Equal(policy.Files[filepath.ToSlash(file.RelPath)], recipients) || s.encryption == nil {
+		return false
+	}
+
+	return s.encryptedCurrent(s.encryption, filepath.Join(s.paths.SyncRepoDir(), file.RelPath+".age"), file.Hash)
+}
+
+// copyRecipientsSecretsToRepo encrypts the files found by CopyToRepo to
+// the recipients of their rule, removes any plaintext copy from the repo
+// and records who each file is encrypted to. It then checks that every
+// file in the repo matches the policy.
+func (s *Syncer) copyRecipientsSecretsToRepo() error {
+	policy := s.recipientPolicy()
+	if len(policy.Rules) == 0 && len(policy.Files) == 0 {
+		return nil
+	}
+	if policy.Files == nil {
+		policy.Files = map[string][]string{}
+	}
+
+	repoDir := s.paths.SyncRepoDir()
+	own := s.OwnRecipient()
+	changed := false
+
+	for _, source := range s.recipientsSecrets {
+		recipients, _ := policy.RecipientsFor(source.RelPath)
+		key := filepath.ToSlash(source.RelPath)
+		dst := filepath.Join(repoDir, source.RelPath+".age")
+
+		// A copy pushed before the file had its own recipients
+		shared := filepath.Join(repoDir, source.RelPath)
+		if err := s.fs.Remove(shared); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove plaintext copy of %s: %w", source.RelPath, err)
+		}
+
+		hash, err := s.hashFile(source.LocalPath)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", source.RelPath, err)
+		}
+
+		// Age ciphertext differs on every run; see writeEncrypted
+		if slices.Equal(policy.Files[key], recipients) && s.encryption != nil && s.encryptedCurrent(s.encryption, dst, hash) {
+			continue
+		}
+
+		if err := s.encryptFileTo(recipients, source.RelPath, source.LocalPath, dst, slices.Contains(recipients, own)); err != nil {
+			return err
+		}
+
+		policy.Files[key] = slices.Clone(recipients)
+		changed = true
+	}
+
+	// Files whose rule was removed are stored in plaintext again once a
+	// machine that has them pushes
+	for key := range policy.Files {
+		relPath := filepath.FromSlash(key)
+		if _, ok := policy.RecipientsFor(relPath); ok {
+			continue
+		}
+		if _, err := s.fs.Stat(filepath.Join(repoDir, relPath)); err != nil {
+			continue
+		}
+
+		if err := s.fs.Remove(filepath.Join(repoDir, relPath+".age")); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove encrypted copy of %s: %w", relPath, err)
+		}
+		delete(policy.Files, key)
+		changed = true
+	}
+
+	if changed {
+		if err := s.SaveRecipientPolicy(policy); err != nil {
+			return err
+		}
+	}
+
+	return s.CheckRecipients()
+}
+
+// encryptFileTo encrypts src into dst to recipients. When this machine is
+// one of them the result is verified like encryptVerified; otherwise it
+// cannot be.
+func (s *Syncer) encryptFileTo(recipients []string, name, src, dst string, verify bool) error {
+	encrypt := func(plaintext io.Reader, ciphertext io.Writer) error {
+		defer s.timings.Start(PhaseEncryption)()
+		return crypto.EncryptReaderTo(recipients, plaintext, ciphertext)
+	}
+
+	var enc crypto.Encryption
+	if verify {
+		enc = s.encryption
+	}
+	return s.streamEncrypted(name, src, dst, encrypt, enc)
+}
+
+// RecipientMismatch is a file in the repo that is not stored the way the
+// recipient policy says
+type RecipientMismatch struct {
+	Path   string
+	Reason string
+}
+
+// RecipientsError is returned when a push would publish files that do
+// not match the recipient policy
+type RecipientsError struct {
+	Mismatches []RecipientMismatch
+}
+
+func (e *RecipientsError) Error() string {
+	lines := make([]string, 0, len(e.Mismatches))
+	for _, mismatch := range e.Mismatches {
+		lines = append(lines, fmt.Sprintf("%s (%s)", mismatch.Path, mismatch.Reason))
+	}
+	return fmt.Sprintf("files do not match the recipient policy: %s", strings.Join(lines, ", "))
+}
+
+// CheckRecipients checks that every file in the sync repo covered by a
+// recipient rule is encrypted, to the rule's recipients. Which keys a file
+// is encrypted to is taken from the policy's record and checked against
+// the number of keys in the file's header.
+func (s *Syncer) CheckRecipients() error {
+	policy := s.recipientPolicy()
+	if len(policy.Rules) == 0 {
+		return nil
+	}
+
+	relPaths, err := s.repoFiles()
+	if err != nil {
+		return fmt.Errorf("failed to list repo files: %w", err)
+	}
+
+	mismatches, err := policy.check(relPaths, func(relPath string) ([]byte, error) {
+		return util.ReadFile(s.fs, filepath.Join(s.paths.SyncRepoDir(), relPath))
+	})
+	if err != nil {
+		return err
+	}
+	if len(mismatches) > 0 {
+		return &RecipientsError{Mismatches: mismatches}
+	}
+	return nil
+}
+
+// check returns the repo paths among relPaths that are not stored the way
+// the policy says, reading encrypted files with read
+func (p *RecipientPolicy) check(relPaths []string, read func(relPath string) ([]byte, error)) ([]RecipientMismatch, error) {
+	var mismatches []RecipientMismatch
+	for _, relPath := range relPaths {
+		plain, encrypted := strings.CutSuffix(relPath, ".age")
+		if !encrypted || !recipientCandidate(plain) {
+			if _, ok := p.RecipientsFor(relPath); ok && recipientCandidate(relPath) {
+				mismatches = append(mismatches, RecipientMismatch{Path: relPath, Reason: "stored unencrypted"})
+			}
+			continue
+		}
+
+		recipients, ok := p.RecipientsFor(plain)
+		if !ok {
+			continue
+		}
+
+		if !slices.Equal(p.Files[filepath.ToSlash(plain)], recipients) {
+			mismatches = append(mismatches, RecipientMismatch{Path: relPath, Reason: "encrypted to other recipients"})
+			continue
+		}
+
+		data, err := read(relPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", relPath, err)
+		}
+		if count, err := crypto.CountRecipients(data); err != nil {
+			mismatches = append(mismatches, RecipientMismatch{Path: relPath, Reason: err.Error()})
+		} else if count != len(recipients) {
+			mismatches = append(mismatches, RecipientMismatch{Path: relPath, Reason: fmt.Sprintf("encrypted to %d keys, not %d", count, len(recipients))})
+		}
+	}
+	return mismatches, nil
+}
+
+// copyRecipientsSecretFromRepo decrypts a file encrypted to its own
+// recipients to dst. Machines whose shared key is not one of them leave
+// dst alone.
+func (s *Syncer) copyRecipientsSecretFromRepo(src, dst string) error {
+	if s.encryption == nil {
+		return nil
+	}
+
+	err := s.decryptFile(src, dst)
+	if errors.Is(err, crypto.ErrNotRecipient) {
+		return nil
+	}
+	return err
+}
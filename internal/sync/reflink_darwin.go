@@ -0,0 +1,29 @@
+//go:build darwin
+
+package sync
+
+import (
+	"os"
+
+	"github.com/GareArc/opencode-sync/internal/capability"
+	"golang.org/x/sys/unix"
+)
+
+func init() {
+	capability.Register(capability.Capability{
+		Name:        "reflink",
+		Kind:        capability.KindFeature,
+		Description: "Copy-on-write file copies on filesystems that support them",
+	})
+}
+
+// reflink makes dst a copy-on-write clone of src with clonefile(2). It
+// fails on filesystems other than APFS.
+func reflink(src, dst string) error {
+	// clonefile refuses to overwrite an existing file
+	if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return unix.Clonefile(src, dst, unix.CLONE_NOFOLLOW)
+}
@@ -0,0 +1,40 @@
+//go:build linux
+
+package sync
+
+import (
+	"os"
+
+	"github.com/GareArc/opencode-sync/internal/capability"
+	"golang.org/x/sys/unix"
+)
+
+func init() {
+	capability.Register(capability.Capability{
+		Name:        "reflink",
+		Kind:        capability.KindFeature,
+		Description: "Copy-on-write file copies on filesystems that support them",
+	})
+}
+
+// reflink makes dst a copy-on-write clone of src with FICLONE. It fails
+// on filesystems without reflink support, such as ext4 or across devices.
+func reflink(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	if err := unix.IoctlFileClone(int(out.Fd()), int(in.Fd())); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
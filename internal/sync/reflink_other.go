@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package sync
+
+import "errors"
+
+// reflink is not supported on this platform
+func reflink(src, dst string) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,197 @@
+package sync
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GareArc/opencode-sync/internal/git"
+)
+
+// DetectRenames finds the files renamed in the sync repo since from, the
+// commit before a pull, so BackupLocal saves them under their old path and
+// CopyFromRepo moves them instead of leaving a copy behind. The direct
+// layout needs nothing, since git moves them itself.
+func (s *Syncer) DetectRenames(from string) error {
+	s.renames = nil
+	if s.Direct() || from == "" {
+		return nil
+	}
+
+	renames, err := s.repo.Renames(from, "HEAD")
+	if err != nil {
+		return err
+	}
+	s.renames = renames
+	return nil
+}
+
+// MovedFiles returns the renames the last CopyFromRepo applied by moving
+// the local file
+func (s *Syncer) MovedFiles() []git.FileChange {
+	return s.moved
+}
+
+// WorktreeRenames returns the files renamed in the sync repo's working
+// tree since the last commit
+func (s *Syncer) WorktreeRenames() ([]git.FileChange, error) {
+	changes, err := s.repo.WorktreeChanges()
+	if err != nil {
+		return nil, err
+	}
+
+	var renames []git.FileChange
+	for _, change := range changes {
+		if change.Status == git.StatusRenamed {
+			renames = append(renames, change)
+		}
+	}
+	return renames, nil
+}
+
+// moveRenamed moves the local copies of the files DetectRenames found to
+// their new path, keeping whatever refers to the file by inode. Renames
+// whose new path already exists locally are left to the copy.
+func (s *Syncer) moveRenamed() error {
+	s.moved = nil
+	for _, change := range s.renames {
+		if s.locked[change.OldPath] || s.locked[change.Path] || !s.onlyMatches(change.Path) {
+			continue
+		}
+
+		oldPath := s.localPath(change.OldPath)
+		newPath := s.localPath(change.Path)
+		if oldPath == "" || newPath == "" {
+			continue
+		}
+		if _, err := s.fs.Stat(oldPath); err != nil {
+			continue
+		}
+		if _, err := s.fs.Stat(newPath); !os.IsNotExist(err) {
+			continue
+		}
+
+		if err := s.fs.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err := s.fs.Rename(oldPath, newPath); err != nil {
+			return fmt.Errorf("failed to move %s to %s: %w", oldPath, newPath, err)
+		}
+		s.moved = append(s.moved, change)
+	}
+	return nil
+}
+
+// renameInRepo moves repo files whose local copy is gone to the path of a
+// new local file that kept at least git.RenameSimilarity percent of their
+// content, so the push commits a rename. Otherwise the old file would stay
+// in the repo, since pushes never delete, and come back on other machines.
+func (s *Syncer) renameInRepo() error {
+	repoDir := s.paths.SyncRepoDir()
+
+	relPaths, err := s.repoFiles()
+	if err != nil {
+		return err
+	}
+
+	var gone []string
+	for _, relPath := range relPaths {
+		if !s.renameable(relPath) {
+			continue
+		}
+		if _, err := s.fs.Stat(s.localPath(relPath)); os.IsNotExist(err) {
+			gone = append(gone, relPath)
+		}
+	}
+	if len(gone) == 0 {
+		return nil
+	}
+
+	files, err := s.getSyncableFiles()
+	if err != nil {
+		return err
+	}
+
+	var added []FileInfo
+	for _, file := range files {
+		if !s.renameable(file.RelPath) {
+			continue
+		}
+		if _, err := s.fs.Stat(filepath.Join(repoDir, file.RelPath)); os.IsNotExist(err) {
+			added = append(added, file)
+		}
+	}
+
+	for _, relPath := range gone {
+		// Files too large to compare are not paired
+		before, err := s.readFile(filepath.Join(repoDir, relPath))
+		if tooLarge(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", relPath, err)
+		}
+
+		best, bestScore := -1, git.RenameSimilarity-1
+		for i, file := range added {
+			after, err := s.readFile(file.Path)
+			if tooLarge(err) {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", file.Path, err)
+			}
+			if score := similarity(before, after); score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		if best < 0 {
+			continue
+		}
+
+		newPath := filepath.Join(repoDir, added[best].RelPath)
+		if err := s.fs.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err := s.fs.Rename(filepath.Join(repoDir, relPath), newPath); err != nil {
+			return fmt.Errorf("failed to rename %s in the sync repo: %w", relPath, err)
+		}
+		added = append(added[:best], added[best+1:]...)
+	}
+
+	return nil
+}
+
+// renameable reports whether a repo path is a plain copy of a local file
+// that push may rename
+func (s *Syncer) renameable(relPath string) bool {
+	if strings.HasSuffix(relPath, ".age") || s.locked[relPath] || s.inSubmodule(relPath) {
+		return false
+	}
+	return s.localPath(relPath) != "" && s.selected(relPath, false) && s.onlyMatches(relPath)
+}
+
+// similarity returns how much of two files' content is the same, in
+// percent of the larger one, counting the bytes of the lines they share.
+// Empty files are never similar.
+func similarity(a, b []byte) int {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	lines := make(map[string]int)
+	for _, line := range bytes.SplitAfter(a, []byte("\n")) {
+		lines[string(line)]++
+	}
+
+	shared := 0
+	for _, line := range bytes.SplitAfter(b, []byte("\n")) {
+		if lines[string(line)] > 0 {
+			lines[string(line)]--
+			shared += len(line)
+		}
+	}
+	return shared * 100 / max(len(a), len(b))
+}
@@ -0,0 +1,18 @@
+package sync
+
+import (
+	"github.com/GareArc/opencode-sync/internal/git"
+	"github.com/GareArc/opencode-sync/internal/paths"
+)
+
+// NewRepo returns the sync repo, with paths relative to repo.subdir. For
+// init or clone to create it, in the direct layout its git data goes to
+// the data dir.
+func NewRepo(p *paths.Paths) *git.BuiltinGit {
+	repo := git.NewBuiltinGit(p.SyncRepoRoot())
+	repo.SetSubdir(paths.RepoSubdir)
+	if paths.DirectLayout {
+		repo.SetGitDir(p.SyncGitDir())
+	}
+	return repo
+}
@@ -0,0 +1,277 @@
+package sync
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/GareArc/opencode-sync/internal/capability"
+	"github.com/GareArc/opencode-sync/internal/crypto"
+	"github.com/GareArc/opencode-sync/internal/jsonc"
+)
+
+func init() {
+	capability.Register(capability.Capability{
+		Name:        "verify-repo",
+		Kind:        capability.KindFeature,
+		Description: "Check a checked-out sync repo without a local config or key, e.g. in CI",
+	})
+}
+
+// Kinds of checks VerifyRepo runs
+const (
+	RepoCheckStructure  = "structure"
+	RepoCheckJSON       = "json"
+	RepoCheckEncryption = "encryption"
+	RepoCheckRecipients = "recipients"
+)
+
+// RepoProblem is something wrong with a sync repo found by VerifyRepo
+type RepoProblem struct {
+	// Check is the kind of check that failed, one of the RepoCheck values
+	Check string `json:"check"`
+
+	// Path is the repo path, with forward slashes
+	Path string `json:"path"`
+
+	Reason string `json:"reason"`
+}
+
+// VerifyRepo checks the sync repo checked out at dir on its own, without
+// a config, key or OpenCode install: that its layout is one opencode-sync
+// writes, that JSON files parse, that secrets are only stored encrypted,
+// and that files are encrypted as recipients.json says. Content is never
+// decrypted, so only the number of keys of an age file can be checked.
+// Problems are returned sorted by path.
+func VerifyRepo(dir string) ([]RepoProblem, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read repo: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+
+	relPaths, err := listRepoDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list repo files: %w", err)
+	}
+
+	var problems []RepoProblem
+	report := func(check, relPath, reason string) {
+		problems = append(problems, RepoProblem{Check: check, Path: filepath.ToSlash(relPath), Reason: reason})
+	}
+	read := func(relPath string) ([]byte, error) {
+		return os.ReadFile(filepath.Join(dir, relPath))
+	}
+
+	if len(relPaths) == 0 {
+		report(RepoCheckStructure, ".", "the repo has no synced files")
+	}
+
+	placeholders := false
+	for _, relPath := range relPaths {
+		data, err := read(relPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", relPath, err)
+		}
+
+		if strings.HasSuffix(relPath, ".age") {
+			if _, err := crypto.CountRecipients(data); err != nil {
+				report(RepoCheckEncryption, relPath, err.Error())
+			}
+			continue
+		}
+
+		if reason := verifyRepoLayout(relPath); reason != "" {
+			report(RepoCheckStructure, relPath, reason)
+		}
+		if isSecretFile(relPath) || isSessionFile(relPath) {
+			report(RepoCheckEncryption, relPath, "stored unencrypted")
+			continue
+		}
+		if bytes.IndexByte(data[:min(len(data), binarySniffLen)], 0) >= 0 {
+			continue
+		}
+
+		if hasConflictMarkers(data) {
+			report(RepoCheckStructure, relPath, "has unresolved merge conflict markers")
+		}
+		if reason := verifyRepoJSON(relPath, data); reason != "" {
+			report(RepoCheckJSON, relPath, reason)
+		}
+		if reason := secretInContent(relPath, data); reason != "" {
+			report(RepoCheckEncryption, relPath, reason)
+		}
+		if isMcpConfigFile(relPath) && bytes.Contains(data, []byte(`"`+mcpSecretPlaceholder+`"`)) {
+			placeholders = true
+		}
+	}
+
+	if placeholders && !slices.Contains(relPaths, mcpSecretsFile) {
+		report(RepoCheckStructure, mcpSecretsFile, "missing, though the OpenCode config has encrypted MCP secrets")
+	}
+
+	recipientProblems, err := verifyRepoRecipients(relPaths, read)
+	if err != nil {
+		return nil, err
+	}
+	problems = append(problems, recipientProblems...)
+
+	sort.SliceStable(problems, func(i, j int) bool { return problems[i].Path < problems[j].Path })
+	return problems, nil
+}
+
+// listRepoDir returns the files of the repo checked out at dir, skipping
+// git's data and the files generated for it at the root
+func listRepoDir(dir string) ([]string, error) {
+	var relPaths []string
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.Name() == ".git" {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if _, ok := repoGitFiles[relPath]; !ok {
+			relPaths = append(relPaths, relPath)
+		}
+		return nil
+	})
+	return relPaths, err
+}
+
+// verifyRepoLayout returns why a plaintext file is out of place in a sync
+// repo, or ""
+func verifyRepoLayout(relPath string) string {
+	parts := strings.Split(filepath.ToSlash(relPath), "/")
+	if parts[0] != hostsDir {
+		return ""
+	}
+
+	switch {
+	case len(parts) == 2:
+		return "hosts/ holds only a directory per host"
+	case len(parts) > 3 && parts[2] == hostSecretsDir:
+		return "host secrets must be encrypted (.age)"
+	}
+	return ""
+}
+
+// hasConflictMarkers reports whether data has the markers git leaves in a
+// file it could not merge
+func hasConflictMarkers(data []byte) bool {
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if bytes.HasPrefix(line, []byte("<<<<<<< ")) || bytes.HasPrefix(line, []byte(">>>>>>> ")) {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyRepoJSON returns why a JSON file of the repo is invalid, or "".
+// OpenCode reads its config with comments, so .json files may have them.
+func verifyRepoJSON(relPath string, data []byte) string {
+	switch {
+	case slices.Contains(validatedFiles, relPath):
+		return validateConfig(relPath, data)
+	case relPath == recipientsFile:
+		var policy RecipientPolicy
+		if err := json.Unmarshal(data, &policy); err != nil {
+			return err.Error()
+		}
+	case relPath == metadataFile:
+		var metadata map[string]fileMetadata
+		if err := json.Unmarshal(data, &metadata); err != nil {
+			return err.Error()
+		}
+	case strings.HasSuffix(relPath, ".json"), strings.HasSuffix(relPath, ".jsonc"):
+		if _, err := jsonc.Unmarshal(data); err != nil {
+			return err.Error()
+		}
+	}
+	return ""
+}
+
+// verifyRepoRecipients checks the published host keys, the keys of
+// recipients.json and that the files it covers are encrypted to them
+func verifyRepoRecipients(relPaths []string, read func(relPath string) ([]byte, error)) ([]RepoProblem, error) {
+	var problems []RepoProblem
+	report := func(relPath, reason string) {
+		problems = append(problems, RepoProblem{Check: RepoCheckRecipients, Path: filepath.ToSlash(relPath), Reason: reason})
+	}
+
+	for _, relPath := range relPaths {
+		parts := strings.Split(filepath.ToSlash(relPath), "/")
+		if len(parts) < 3 || parts[0] != hostsDir {
+			continue
+		}
+
+		data, err := read(relPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", relPath, err)
+		}
+		switch {
+		case len(parts) == 3 && parts[2] == hostRecipientFile:
+			if _, err := crypto.NewAgeEncryptionWithPublicKey(strings.TrimSpace(string(data))); err != nil {
+				report(relPath, "not a valid age public key")
+			}
+		case parts[2] == hostSecretsDir && strings.HasSuffix(relPath, ".age"):
+			// Encrypted to the host's key only
+			if count, err := crypto.CountRecipients(data); err == nil && count != 1 {
+				report(relPath, fmt.Sprintf("encrypted to %d keys, not 1", count))
+			}
+		}
+	}
+
+	data, err := read(recipientsFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return problems, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", recipientsFile, err)
+	}
+	var policy RecipientPolicy
+	if json.Unmarshal(data, &policy) != nil {
+		// Reported by the JSON check
+		return problems, nil
+	}
+
+	for _, rule := range policy.Rules {
+		if len(rule.Recipients) == 0 {
+			report(recipientsFile, fmt.Sprintf("rule for %s has no recipients", rule.Path))
+		}
+		for _, recipient := range rule.Recipients {
+			if _, err := crypto.NewAgeEncryptionWithPublicKey(recipient); err != nil {
+				report(recipientsFile, fmt.Sprintf("rule for %s has an invalid key %q", rule.Path, recipient))
+			}
+		}
+	}
+
+	mismatches, err := policy.check(relPaths, read)
+	if err != nil {
+		return nil, err
+	}
+	for _, mismatch := range mismatches {
+		report(mismatch.Path, mismatch.Reason)
+	}
+	return problems, nil
+}
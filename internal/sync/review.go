@@ -0,0 +1,151 @@
+package sync
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/GareArc/opencode-sync/internal/jsonc"
+)
+
+// RiskyChange is a change the sync repo would apply that deserves a look
+// before it replaces the live config
+type RiskyChange struct {
+	// Path is the repo path of the changed file
+	Path string
+
+	// Description says what would change
+	Description string
+}
+
+// RiskyChangesError reports risky changes that were held back because
+// nobody was there to confirm them
+type RiskyChangesError struct {
+	Changes []RiskyChange
+}
+
+func (e *RiskyChangesError) Error() string {
+	lines := make([]string, 0, len(e.Changes))
+	for _, change := range e.Changes {
+		lines = append(lines, change.Description)
+	}
+	return fmt.Sprintf("pulled changes need review before they are applied: %s", strings.Join(lines, ", "))
+}
+
+// RiskyChanges compares the sync repo with the live config and returns the
+// changes applying it would make that are easy to regret: providers added,
+// removed or changed, agents removed from the OpenCode config, and auth
+// files replaced. Locked files are left out.
+func (s *Syncer) RiskyChanges() ([]RiskyChange, error) {
+	repoDir := s.paths.SyncRepoDir()
+
+	var changes []RiskyChange
+	for _, relPath := range mcpConfigFiles {
+		// Locked files are not applied
+		if s.locked[relPath] {
+			continue
+		}
+		incoming, err := s.readFile(filepath.Join(repoDir, relPath))
+		if err != nil {
+			continue
+		}
+		local, err := s.readFile(s.localPath(relPath))
+		if err != nil {
+			continue
+		}
+		changes = append(changes, configRisks(relPath, local, incoming)...)
+	}
+
+	if s.encryption != nil && !s.authOnBranch() {
+		auth := []struct {
+			relPath, localPath string
+			included           bool
+		}{
+			{s.layoutPath(authRepoFile), s.paths.OpenCodeAuthFile(), s.cfg.Sync.IncludeAuth},
+			{s.layoutPath(mcpAuthRepoFile), s.paths.OpenCodeMcpAuthFile(), s.cfg.Sync.IncludeMcpAuth},
+		}
+		for _, a := range auth {
+			if !a.included || s.locked[a.relPath] {
+				continue
+			}
+			if _, err := s.fs.Stat(filepath.Join(repoDir, a.relPath)); err != nil {
+				continue
+			}
+			if _, err := s.fs.Stat(a.localPath); err != nil {
+				continue
+			}
+			if !s.authCurrent(a.localPath, a.relPath) {
+				name := strings.TrimSuffix(a.relPath, ".age")
+				changes = append(changes, RiskyChange{Path: a.relPath, Description: fmt.Sprintf("%s replaced", name)})
+			}
+		}
+	}
+
+	return changes, nil
+}
+
+// configRisks compares the providers and agents of two versions of an
+// OpenCode config file. Files that don't parse are left to
+// ValidateApplied.
+func configRisks(relPath string, local, incoming []byte) []RiskyChange {
+	localValue, err := jsonc.Unmarshal(local)
+	if err != nil {
+		return nil
+	}
+	incomingValue, err := jsonc.Unmarshal(incoming)
+	if err != nil {
+		return nil
+	}
+	localRoot, _ := localValue.(map[string]any)
+	incomingRoot, _ := incomingValue.(map[string]any)
+
+	var changes []RiskyChange
+	add := func(format string, args ...any) {
+		changes = append(changes, RiskyChange{Path: relPath, Description: fmt.Sprintf(format, args...) + " in " + relPath})
+	}
+
+	localProviders, _ := localRoot["provider"].(map[string]any)
+	incomingProviders, _ := incomingRoot["provider"].(map[string]any)
+	for _, name := range unionKeys(localProviders, incomingProviders) {
+		before, hadBefore := localProviders[name]
+		after, hasAfter := incomingProviders[name]
+		switch {
+		case !hadBefore:
+			add("provider %s added", name)
+		case !hasAfter:
+			add("provider %s removed", name)
+		case !reflect.DeepEqual(before, after):
+			add("provider %s changed", name)
+		}
+	}
+
+	localAgents, _ := localRoot["agent"].(map[string]any)
+	incomingAgents, _ := incomingRoot["agent"].(map[string]any)
+	for _, name := range unionKeys(localAgents, nil) {
+		if _, ok := incomingAgents[name]; !ok {
+			add("agent %s removed", name)
+		}
+	}
+
+	return changes
+}
+
+// unionKeys returns the keys of both maps, sorted
+func unionKeys(a, b map[string]any) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	for key := range a {
+		seen[key] = true
+	}
+	for key := range b {
+		seen[key] = true
+	}
+
+	keys := make([]string, 0, len(seen))
+	for key := range seen {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,154 @@
+package sync
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+
+	"github.com/GareArc/opencode-sync/internal/crypto"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// encryptedFiles are the encrypted artifacts stored at fixed repo paths
+var encryptedFiles = []string{authRepoFile, mcpAuthRepoFile, mcpSecretsFile}
+
+// SecretChange describes a change to one key of an encrypted JSON file.
+// Values are never included.
+type SecretChange struct {
+	// Key is the dotted path of the changed entry (e.g. "anthropic.access")
+	Key string
+
+	// Change is "added", "removed" or "rotated"
+	Change string
+}
+
+// SecretDiffs compares each encrypted file in the repo working tree with
+// its version at HEAD, decrypting both in memory. The result maps file
+// name to its changes; unchanged files are omitted.
+func (s *Syncer) SecretDiffs() (map[string][]SecretChange, error) {
+	if s.encryption == nil {
+		return nil, fmt.Errorf("encryption is not enabled: %w", crypto.ErrKeyMissing)
+	}
+
+	// A push may be migrating the repo to a new layout
+	head, err := manifestAt(s.repo, "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	headLayout := layoutOf(head)
+
+	result := map[string][]SecretChange{}
+
+	for _, name := range encryptedFiles {
+		oldCipher, err := s.repo.ReadFileAt("HEAD", filepath.ToSlash(pathInLayout(name, headLayout)))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+
+		newCipher, err := util.ReadFile(s.fs, filepath.Join(s.paths.SyncRepoDir(), s.layoutPath(name)))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+
+		if bytes.Equal(oldCipher, newCipher) {
+			continue
+		}
+
+		oldDoc, err := s.decryptJSON(name, oldCipher)
+		if err != nil {
+			return nil, err
+		}
+		newDoc, err := s.decryptJSON(name, newCipher)
+		if err != nil {
+			return nil, err
+		}
+
+		var changes []SecretChange
+		diffSecretValues("", oldDoc, newDoc, &changes)
+		if len(changes) > 0 {
+			result[name] = changes
+		}
+	}
+
+	return result, nil
+}
+
+// decryptJSON decrypts an encrypted JSON artifact. Empty input yields nil.
+func (s *Syncer) decryptJSON(name string, ciphertext []byte) (any, error) {
+	if len(ciphertext) == 0 {
+		return nil, nil
+	}
+
+	plaintext, err := s.encryption.Decrypt(ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s: %w", name, err)
+	}
+
+	var doc any
+	if err := json.Unmarshal(plaintext, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+
+	return doc, nil
+}
+
+// diffSecretValues records added, removed and rotated keys between two
+// decoded JSON values without retaining the values themselves
+func diffSecretValues(prefix string, oldValue, newValue any, changes *[]SecretChange) {
+	oldObj, oldIsObj := oldValue.(map[string]any)
+	newObj, newIsObj := newValue.(map[string]any)
+
+	if oldValue == nil && newValue == nil {
+		return
+	}
+
+	if !oldIsObj || !newIsObj {
+		switch {
+		case oldValue == nil:
+			*changes = append(*changes, SecretChange{Key: prefix, Change: "added"})
+		case newValue == nil:
+			*changes = append(*changes, SecretChange{Key: prefix, Change: "removed"})
+		case !reflect.DeepEqual(oldValue, newValue):
+			*changes = append(*changes, SecretChange{Key: prefix, Change: "rotated"})
+		}
+		return
+	}
+
+	keys := map[string]bool{}
+	for key := range oldObj {
+		keys[key] = true
+	}
+	for key := range newObj {
+		keys[key] = true
+	}
+
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	for _, key := range sorted {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+
+		oldChild, inOld := oldObj[key]
+		newChild, inNew := newObj[key]
+
+		switch {
+		case !inOld:
+			*changes = append(*changes, SecretChange{Key: path, Change: "added"})
+		case !inNew:
+			*changes = append(*changes, SecretChange{Key: path, Change: "removed"})
+		default:
+			diffSecretValues(path, oldChild, newChild, changes)
+		}
+	}
+}
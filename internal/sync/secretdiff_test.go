@@ -0,0 +1,89 @@
+package sync
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDiffSecretValues(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     []SecretChange
+	}{
+		{
+			name: "unchanged",
+			old:  `{"anthropic": {"type": "api", "key": "k1"}}`,
+			new:  `{"anthropic": {"type": "api", "key": "k1"}}`,
+		},
+		{
+			name: "both missing",
+		},
+		{
+			name: "file added",
+			new:  `{"anthropic": {"key": "k1"}}`,
+			want: []SecretChange{{Key: "", Change: "added"}},
+		},
+		{
+			name: "file removed",
+			old:  `{"anthropic": {"key": "k1"}}`,
+			want: []SecretChange{{Key: "", Change: "removed"}},
+		},
+		{
+			name: "key rotated",
+			old:  `{"anthropic": {"type": "api", "key": "k1"}}`,
+			new:  `{"anthropic": {"type": "api", "key": "k2"}}`,
+			want: []SecretChange{{Key: "anthropic.key", Change: "rotated"}},
+		},
+		{
+			name: "providers added and removed, in key order",
+			old:  `{"b": {"key": "1"}, "c": {"key": "2"}}`,
+			new:  `{"a": {"key": "3"}, "c": {"key": "2"}}`,
+			want: []SecretChange{
+				{Key: "a", Change: "added"},
+				{Key: "b", Change: "removed"},
+			},
+		},
+		{
+			name: "object replaced by a value",
+			old:  `{"a": {"key": "1"}}`,
+			new:  `{"a": "1"}`,
+			want: []SecretChange{{Key: "a", Change: "rotated"}},
+		},
+		{
+			name: "nested change",
+			old:  `{"a": {"oauth": {"access": "x", "refresh": "y"}}}`,
+			new:  `{"a": {"oauth": {"access": "z", "refresh": "y", "expires": 1}}}`,
+			want: []SecretChange{
+				{Key: "a.oauth.access", Change: "rotated"},
+				{Key: "a.oauth.expires", Change: "added"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var changes []SecretChange
+			diffSecretValues("", decodeSecret(t, tt.old), decodeSecret(t, tt.new), &changes)
+			if !reflect.DeepEqual(changes, tt.want) {
+				t.Errorf("diffSecretValues() = %+v, want %+v", changes, tt.want)
+			}
+		})
+	}
+}
+
+// decodeSecret parses a decrypted secret file as decryptJSON does, with ""
+// for a missing file
+func decodeSecret(t *testing.T, data string) any {
+	t.Helper()
+	if data == "" {
+		return nil
+	}
+
+	var v any
+	if err := json.Unmarshal([]byte(data), &v); err != nil {
+		t.Fatalf("invalid test JSON %s: %v", data, err)
+	}
+	return v
+}
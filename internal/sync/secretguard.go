@@ -0,0 +1,186 @@
+package sync
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// secretFilePatterns are file names that hold credentials. In the direct
+// layout they are never tracked, even inside a synced directory.
+var secretFilePatterns = []string{"auth.json", "mcp-auth.json", ".env", ".env.*", "*.pem", "*.key", "id_rsa", "id_ed25519"}
+
+// secretTokenPatterns match well-known API token formats
+var secretTokenPatterns = []struct {
+	kind    string
+	pattern *regexp.Regexp
+}{
+	{"private key", regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`)},
+	{"GitHub token", regexp.MustCompile(`\b(ghp|gho|ghs|ghu)_[A-Za-z0-9]{36}\b|\bgithub_pat_[A-Za-z0-9_]{22,}`)},
+	{"Anthropic or OpenAI API key", regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{20,}`)},
+	{"AWS access key", regexp.MustCompile(`\bAKIA[0-9A-Z]{16}\b`)},
+	{"Slack token", regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`)},
+}
+
+// SecretFinding is a file about to be committed that looks like it holds
+// a credential
+type SecretFinding struct {
+	Path   string
+	Reason string
+}
+
+// SecretsError is returned when a push in the direct layout would commit
+// credentials in plaintext
+type SecretsError struct {
+	Findings []SecretFinding
+}
+
+func (e *SecretsError) Error() string {
+	lines := make([]string, 0, len(e.Findings))
+	for _, finding := range e.Findings {
+		lines = append(lines, fmt.Sprintf("%s (%s)", finding.Path, finding.Reason))
+	}
+	return fmt.Sprintf("refusing to commit what looks like credentials: %s", strings.Join(lines, ", "))
+}
+
+// isSecretFile reports whether a file name is one of secretFilePatterns
+func isSecretFile(relPath string) bool {
+	name := filepath.Base(relPath)
+	for _, pattern := range secretFilePatterns {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSecrets looks for credentials in the changed files of the direct
+// layout's working tree, which would otherwise be committed as they are
+func (s *Syncer) checkSecrets(changes []string) error {
+	var findings []SecretFinding
+
+	for _, relPath := range changes {
+		if isSecretFile(relPath) {
+			findings = append(findings, SecretFinding{Path: relPath, Reason: "credential file"})
+			continue
+		}
+
+		path := filepath.Join(s.paths.SyncRepoDir(), relPath)
+		if s.isBinary(path) {
+			continue
+		}
+		data, err := s.readFile(path)
+		if tooLarge(err) {
+			if kind := s.tokenInFile(path); kind != "" {
+				findings = append(findings, SecretFinding{Path: relPath, Reason: "contains a " + kind})
+			}
+			continue
+		}
+		if err != nil {
+			// Deleted, so nothing is added
+			continue
+		}
+
+		if reason := secretInContent(relPath, data); reason != "" {
+			findings = append(findings, SecretFinding{Path: relPath, Reason: reason})
+		}
+	}
+
+	if len(findings) > 0 {
+		sort.Slice(findings, func(i, j int) bool { return findings[i].Path < findings[j].Path })
+		return &SecretsError{Findings: findings}
+	}
+	return nil
+}
+
+// secretInContent describes the credential found in a file, or returns ""
+func secretInContent(relPath string, data []byte) string {
+	for _, token := range secretTokenPatterns {
+		if token.pattern.Match(data) {
+			return "contains a " + token.kind
+		}
+	}
+
+	if isMcpConfigFile(relPath) {
+		if _, secrets, err := splitMcpSecrets(data); err == nil {
+			for _, server := range slices.Sorted(maps.Keys(secrets)) {
+				for _, field := range slices.Sorted(maps.Keys(secrets[server])) {
+					if hasLiteral(secrets[server][field]) {
+						return fmt.Sprintf("MCP server %s has a credential in %s; use {env:NAME} or {file:path}", server, field)
+					}
+				}
+			}
+		}
+	}
+
+	return ""
+}
+
+// tokenScanChunk and tokenScanOverlap are how much of a file too large
+// to read whole tokenInFile reads at a time, and how much of it is read
+// again with the next chunk so a token across the boundary is found
+const (
+	tokenScanChunk   = 1 << 20
+	tokenScanOverlap = 4 << 10
+)
+
+// tokenInFile returns the kind of token secretTokenPatterns finds in a
+// file too large to read whole, or ""
+func (s *Syncer) tokenInFile(path string) string {
+	f, err := s.fs.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	buf := make([]byte, tokenScanOverlap+tokenScanChunk)
+	kept := 0
+	for {
+		n, err := io.ReadFull(f, buf[kept:])
+		chunk := buf[:kept+n]
+		for _, token := range secretTokenPatterns {
+			if token.pattern.Match(chunk) {
+				return token.kind
+			}
+		}
+		if err != nil {
+			return ""
+		}
+
+		kept = copy(buf, chunk[len(chunk)-tokenScanOverlap:])
+	}
+}
+
+// secretKeyPattern matches the names of settings that hold credentials
+var secretKeyPattern = regexp.MustCompile(`(?i)auth|token|key|secret|password|credential`)
+
+// hasLiteral reports whether a JSON value sets a credential-like key to
+// a string without an OpenCode {env:...} or {file:...} reference. The
+// placeholders of split MCP secrets are not credentials.
+func hasLiteral(value any) bool {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			if text, ok := item.(string); ok {
+				if secretKeyPattern.MatchString(key) && strings.TrimSpace(text) != "" &&
+					text != mcpSecretPlaceholder && !strings.Contains(text, "{env:") && !strings.Contains(text, "{file:") {
+					return true
+				}
+			} else if hasLiteral(item) {
+				return true
+			}
+		}
+	case []any:
+		for _, item := range v {
+			if hasLiteral(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,186 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/GareArc/opencode-sync/internal/capability"
+	"github.com/GareArc/opencode-sync/internal/crypto"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+func init() {
+	capability.Register(capability.Capability{
+		Name:        "sessions",
+		Kind:        capability.KindFeature,
+		Description: "Sync encrypted OpenCode session history",
+	})
+}
+
+// sessionsDir is the repo directory holding encrypted session history
+const sessionsDir = "sessions"
+
+// sessionDataDirs are the OpenCode storage directories synced by
+// sync.includeSessions
+var sessionDataDirs = []string{"session", "message", "part"}
+
+// sessionFile is a local history file considered for syncing
+type sessionFile struct {
+	path    string
+	relPath string
+	size    int64
+	modTime int64
+}
+
+// selectSessionFiles returns the local history files to sync: files newer
+// than the retention period, newest first, up to the size cap
+func (s *Syncer) selectSessionFiles() ([]sessionFile, error) {
+	storageDir := s.paths.OpenCodeStorageDir()
+	cutoff := s.clock.Now().Add(-s.cfg.Sync.SessionsRetention()).Unix()
+
+	var files []sessionFile
+	for _, dir := range sessionDataDirs {
+		root := filepath.Join(storageDir, dir)
+		if _, err := s.fs.Stat(root); os.IsNotExist(err) {
+			continue
+		}
+
+		err := util.Walk(s.fs, root, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			if info.ModTime().Unix() < cutoff {
+				return nil
+			}
+
+			relPath, err := filepath.Rel(storageDir, path)
+			if err != nil {
+				return err
+			}
+
+			files = append(files, sessionFile{
+				path:    path,
+				relPath: relPath,
+				size:    info.Size(),
+				modTime: info.ModTime().Unix(),
+			})
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan session history: %w", err)
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].modTime != files[j].modTime {
+			return files[i].modTime > files[j].modTime
+		}
+		return files[i].relPath < files[j].relPath
+	})
+
+	// Files that would exceed the cap are skipped, so one large file does
+	// not push out all the history behind it
+	maxSize := s.cfg.Sync.SessionsMaxSize()
+	var total int64
+	selected := files[:0]
+	for _, f := range files {
+		if total+f.size > maxSize {
+			continue
+		}
+		total += f.size
+		selected = append(selected, f)
+	}
+
+	return selected, nil
+}
+
+// copySessionsToRepo encrypts the selected history files into the repo
+// and removes repo copies that fell out of the retention window or size cap
+func (s *Syncer) copySessionsToRepo(ctx context.Context) error {
+	if s.encryption == nil {
+		return fmt.Errorf("includeSessions requires encryption to be enabled: %w", crypto.ErrKeyMissing)
+	}
+
+	files, err := s.selectSessionFiles()
+	if err != nil {
+		return err
+	}
+
+	repoDir := filepath.Join(s.paths.SyncRepoDir(), sessionsDir)
+	keep := make(map[string]bool, len(files))
+
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		dst := filepath.Join(repoDir, f.relPath+".age")
+		keep[dst] = true
+
+		if !s.onlyMatches(filepath.Join(sessionsDir, f.relPath+".age")) {
+			continue
+		}
+
+		if err := s.fs.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err := s.encryptFileVerified(f.relPath, f.path, dst); err != nil {
+			return err
+		}
+	}
+
+	// A partial copy cannot tell which files fell out of the window
+	if s.partial() {
+		return nil
+	}
+
+	return s.pruneRepoDir(repoDir, keep)
+}
+
+// pruneRepoDir removes the files under a repo directory that are not in keep
+func (s *Syncer) pruneRepoDir(repoDir string, keep map[string]bool) error {
+	if _, err := s.fs.Stat(repoDir); os.IsNotExist(err) {
+		return nil
+	}
+
+	var stale []string
+	err := util.Walk(s.fs, repoDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		if !keep[path] {
+			stale = append(stale, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scan %s: %w", repoDir, err)
+	}
+
+	for _, path := range stale {
+		if err := s.fs.Remove(path); err != nil {
+			return fmt.Errorf("failed to prune %s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// isSessionFile reports whether a repo path is encrypted session history
+func isSessionFile(relPath string) bool {
+	return strings.HasPrefix(relPath, sessionsDir+string(filepath.Separator))
+}
+
+// sessionLocalPath maps sessions/<path>.age to the OpenCode storage directory
+func (s *Syncer) sessionLocalPath(relPath string) string {
+	if !s.cfg.Sync.IncludeSessions || !strings.HasSuffix(relPath, ".age") {
+		return ""
+	}
+
+	rel := strings.TrimSuffix(strings.TrimPrefix(relPath, sessionsDir+string(filepath.Separator)), ".age")
+	return filepath.Join(s.paths.OpenCodeStorageDir(), rel)
+}
@@ -0,0 +1,350 @@
+package sync
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/GareArc/opencode-sync/internal/capability"
+	"github.com/GareArc/opencode-sync/internal/git"
+)
+
+func init() {
+	capability.Register(capability.Capability{
+		Name:        "local-snapshots",
+		Kind:        capability.KindFeature,
+		Description: "Scheduled local snapshots of the OpenCode config, independent of syncing",
+	})
+}
+
+// snapshotSuffix ends the file name of every snapshot
+const snapshotSuffix = ".tar.gz"
+
+// snapshotTimeFormat names snapshots, so lexical order is chronological
+const snapshotTimeFormat = "20060102-150405"
+
+// LocalSnapshot is a tarball of the local files that sync, taken on a
+// schedule or on request so a bad edit can be undone between pushes
+type LocalSnapshot struct {
+	// Name identifies the snapshot, e.g. 20240501-093000
+	Name string
+
+	// Path is the tarball
+	Path string
+
+	// Time is when it was taken
+	Time time.Time
+
+	// Size is the tarball's size in bytes
+	Size int64
+}
+
+// Snapshots returns the snapshots of the local config, oldest first
+func (s *Syncer) Snapshots() ([]LocalSnapshot, error) {
+	entries, err := os.ReadDir(s.paths.SnapshotsDir())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshots directory: %w", err)
+	}
+
+	var snapshots []LocalSnapshot
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), snapshotSuffix)
+		if !ok || entry.IsDir() {
+			continue
+		}
+		taken, err := time.ParseInLocation(snapshotTimeFormat, name, time.Local)
+		if err != nil {
+			continue
+		}
+		snapshot := LocalSnapshot{Name: name, Path: filepath.Join(s.paths.SnapshotsDir(), entry.Name()), Time: taken}
+		if info, err := entry.Info(); err == nil {
+			snapshot.Size = info.Size()
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Name < snapshots[j].Name })
+	return snapshots, nil
+}
+
+// SnapshotDue reports whether backup.auto asks for a snapshot now
+func (s *Syncer) SnapshotDue() (bool, error) {
+	interval := s.cfg.Backup.Interval()
+	if interval == 0 {
+		return false, nil
+	}
+
+	snapshots, err := s.Snapshots()
+	if err != nil {
+		return false, err
+	}
+	if len(snapshots) == 0 {
+		return true, nil
+	}
+	return s.clock.Now().Sub(snapshots[len(snapshots)-1].Time) >= interval, nil
+}
+
+// AutoSnapshot takes a snapshot if backup.auto says one is due. It
+// returns nil if none was.
+func (s *Syncer) AutoSnapshot() (*LocalSnapshot, error) {
+	due, err := s.SnapshotDue()
+	if err != nil || !due {
+		return nil, err
+	}
+	return s.TakeSnapshot()
+}
+
+// TakeSnapshot writes every local file that sync would push to a new
+// tarball in the snapshots directory, then removes the snapshots that
+// backup.keep and backup.keepDays no longer retain
+func (s *Syncer) TakeSnapshot() (*LocalSnapshot, error) {
+	defer s.timings.Start(PhaseBackup)()
+
+	// A snapshot always covers everything
+	only, due := s.only, s.due
+	s.only, s.due = nil, nil
+	files, err := s.getSyncableFiles()
+	s.only, s.due = only, due
+	if err != nil {
+		return nil, fmt.Errorf("failed to list local files: %w", err)
+	}
+
+	if err := os.MkdirAll(s.paths.SnapshotsDir(), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create snapshots directory: %w", err)
+	}
+
+	// Names have a resolution of a second, and a new snapshot has to sort
+	// after the others
+	now := s.clock.Now().Truncate(time.Second)
+	snapshots, err := s.Snapshots()
+	if err != nil {
+		return nil, err
+	}
+	if len(snapshots) > 0 && !now.After(snapshots[len(snapshots)-1].Time) {
+		now = snapshots[len(snapshots)-1].Time.Add(time.Second)
+	}
+	name := now.Format(snapshotTimeFormat)
+	target := filepath.Join(s.paths.SnapshotsDir(), name+snapshotSuffix)
+
+	// Written under a temporary name, so an interrupted snapshot is never
+	// mistaken for a complete one
+	f, err := os.CreateTemp(s.paths.SnapshotsDir(), ".snapshot-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	err = writeSnapshot(f, files)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := os.Rename(f.Name(), target); err != nil {
+		return nil, fmt.Errorf("failed to save snapshot: %w", err)
+	}
+
+	if err := s.pruneSnapshots(); err != nil {
+		return nil, err
+	}
+
+	snapshot := &LocalSnapshot{Name: name, Path: target, Time: now}
+	if info, err := os.Stat(target); err == nil {
+		snapshot.Size = info.Size()
+	}
+	return snapshot, nil
+}
+
+// writeSnapshot writes files to w as a gzipped tarball of repo paths
+func writeSnapshot(w io.Writer, files []FileInfo) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	for _, file := range files {
+		if err := addSnapshotFile(tw, file); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func addSnapshotFile(tw *tar.Writer, file FileInfo) error {
+	src, err := os.Open(file.Path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	header := &tar.Header{
+		Name:    filepath.ToSlash(file.RelPath),
+		Mode:    int64(info.Mode().Perm()),
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, src)
+	return err
+}
+
+// pruneSnapshots removes snapshots beyond backup.keep and older than
+// backup.keepDays. The newest is always kept.
+func (s *Syncer) pruneSnapshots() error {
+	snapshots, err := s.Snapshots()
+	if err != nil {
+		return err
+	}
+
+	maxAge := s.cfg.Backup.MaxAge()
+	keep := s.cfg.Backup.KeepCount()
+	now := s.clock.Now()
+
+	for i, snapshot := range snapshots[:max(len(snapshots)-1, 0)] {
+		tooMany := len(snapshots)-i > keep
+		tooOld := maxAge > 0 && now.Sub(snapshot.Time) > maxAge
+		if !tooMany && !tooOld {
+			continue
+		}
+		if err := os.Remove(snapshot.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove old snapshot: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// FindSnapshot returns the snapshot with the given name, or the newest
+// one for "latest"
+func (s *Syncer) FindSnapshot(name string) (*LocalSnapshot, error) {
+	snapshots, err := s.Snapshots()
+	if err != nil {
+		return nil, err
+	}
+	if len(snapshots) == 0 {
+		return nil, fmt.Errorf("no snapshots yet; take one with 'opencode-sync snapshot take' or set backup.auto")
+	}
+
+	name = strings.TrimSuffix(name, snapshotSuffix)
+	if name == "latest" {
+		return &snapshots[len(snapshots)-1], nil
+	}
+	for i := range snapshots {
+		if snapshots[i].Name == name {
+			return &snapshots[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no snapshot named %s; 'opencode-sync snapshot list' shows them", name)
+}
+
+// snapshotFile is a file read back from a snapshot
+type snapshotFile struct {
+	relPath string
+	mode    os.FileMode
+	data    []byte
+}
+
+// RestoreSnapshot writes the files of a snapshot back to their local
+// paths, only those matching one of the patterns if any are given (see
+// SetOnly). Local files the snapshot does not have are left alone. The
+// current files are snapshotted first, and that snapshot is returned
+// with the repo paths that were restored.
+func (s *Syncer) RestoreSnapshot(snapshot *LocalSnapshot, patterns []string) ([]string, *LocalSnapshot, error) {
+	files, err := s.readSnapshot(snapshot, patterns)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(files) == 0 {
+		return nil, nil, nil
+	}
+
+	// Taken once the snapshot is read, as pruning may remove it
+	before, err := s.TakeSnapshot()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to snapshot the current files: %w", err)
+	}
+
+	var restored []string
+	for _, file := range files {
+		dstPath := s.localPath(file.relPath)
+		if err := os.MkdirAll(filepath.Dir(dstPath), 0755); err != nil {
+			return restored, before, fmt.Errorf("failed to restore %s: %w", file.relPath, err)
+		}
+		if err := os.WriteFile(dstPath, file.data, file.mode); err != nil {
+			return restored, before, fmt.Errorf("failed to restore %s: %w", file.relPath, err)
+		}
+		restored = append(restored, file.relPath)
+	}
+
+	return restored, before, nil
+}
+
+// readSnapshot returns the files of a snapshot that match patterns
+func (s *Syncer) readSnapshot(snapshot *LocalSnapshot, patterns []string) ([]snapshotFile, error) {
+	f, err := os.Open(snapshot.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot %s: %w", snapshot.Name, err)
+	}
+	defer gz.Close()
+
+	only, due := s.only, s.due
+	s.only, s.due = patterns, nil
+	defer func() { s.only, s.due = only, due }()
+
+	var files []snapshotFile
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read snapshot %s: %w", snapshot.Name, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		relPath := filepath.FromSlash(header.Name)
+		if !filepath.IsLocal(relPath) || !s.onlyMatches(relPath) || s.localPath(relPath) == "" {
+			continue
+		}
+
+		if limit := s.cfg.Sync.MaxMemoryFileSize(); header.Size > limit {
+			return nil, &git.FileTooLargeError{Path: header.Name, Size: header.Size, Limit: limit}
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read snapshot %s: %w", snapshot.Name, err)
+		}
+		files = append(files, snapshotFile{relPath: relPath, mode: os.FileMode(header.Mode).Perm(), data: data})
+	}
+
+	return files, nil
+}
@@ -0,0 +1,106 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/GareArc/opencode-sync/internal/git"
+)
+
+// syncSubject starts the subject of commits made by push and sync
+const syncSubject = "Sync from "
+
+// CommitSync commits the staged changes with subject. With squash, they
+// are folded into HEAD instead when HEAD is a sync commit this machine
+// made the same day that nothing has been built on yet. It returns the
+// commit that was replaced, or "" if a new commit was made.
+func CommitSync(repo git.Repository, subject string, squash bool, now time.Time) (string, error) {
+	if squash {
+		if head := squashTarget(repo, now); head != "" {
+			return head, repo.Amend(CommitMessage(subject))
+		}
+	}
+
+	return "", repo.Commit(CommitMessage(subject))
+}
+
+// squashTarget returns HEAD if the next sync commit can be folded into it
+func squashTarget(repo git.Repository, now time.Time) string {
+	head, err := repo.RevParse("HEAD")
+	if err != nil {
+		return ""
+	}
+
+	last, err := repo.GetLastCommit()
+	if err != nil || !strings.HasPrefix(last.Message, syncSubject) || CommitHost(last.Message) != Hostname() {
+		return ""
+	}
+	if last.Timestamp.In(now.Location()).Format("2006-01-02") != now.Format("2006-01-02") {
+		return ""
+	}
+
+	// Merges keep other machines' history, and a bundled commit is already
+	// on another machine
+	if _, err := repo.RevParse("HEAD^2"); err == nil {
+		return ""
+	}
+	if base, err := repo.RevParse(git.BundleBaseRef); err == nil && base == head {
+		return ""
+	}
+
+	// A pushed HEAD can only be replaced while it is still the remote tip
+	if unpushed, err := repo.UnpushedCommits(); err != nil || unpushed == 0 {
+		if remoteHead(repo) != head {
+			return ""
+		}
+	}
+
+	return head
+}
+
+// remoteHead returns the last known tip of the remote branch
+func remoteHead(repo git.Repository) string {
+	branch, err := repo.GetBranch()
+	if err != nil {
+		return ""
+	}
+	tip, _ := repo.RevParse("origin/" + branch)
+	return tip
+}
+
+// PushSquashed pushes a HEAD that CommitSync amended over replaced. If
+// replaced was already pushed, the remote branch is overwritten only while
+// it still points at replaced; if another machine pushed in the meantime,
+// this sync becomes a new commit after replaced and is rebased onto theirs
+// like any other.
+func PushSquashed(ctx context.Context, repo git.Repository, replaced string) error {
+	if remoteHead(repo) != replaced {
+		return PushWithRetry(ctx, repo)
+	}
+
+	err := repo.ForcePushWithLease(ctx, replaced)
+	if err == nil {
+		return pushAuthBranch(ctx, repo)
+	}
+
+	var rejected *git.RejectedError
+	if !errors.As(err, &rejected) {
+		return err
+	}
+
+	// Split the squash again so only this sync is replayed onto theirs
+	last, err := repo.GetLastCommit()
+	if err != nil {
+		return err
+	}
+	if err := repo.ResetSoft(replaced); err != nil {
+		return err
+	}
+	if err := repo.Commit(last.Message); err != nil {
+		return err
+	}
+
+	return PushWithRetry(ctx, repo)
+}
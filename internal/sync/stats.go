@@ -0,0 +1,68 @@
+package sync
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// File categories reported by RepoFiles
+const (
+	CategoryOpenCode     = "opencode"
+	CategoryClaudeSkills = "claude-skills"
+	CategoryClaude       = "claude"
+	CategoryProjects     = "projects"
+	CategorySecrets      = "secrets"
+	CategoryHosts        = "hosts"
+)
+
+// RepoFile describes a file tracked in the sync repo
+type RepoFile struct {
+	Path     string
+	Category string
+	Size     int64
+}
+
+// RepoFiles returns every synced file in the repo with its category and size
+func (s *Syncer) RepoFiles() ([]RepoFile, error) {
+	relPaths, err := s.repoFiles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list repo files: %w", err)
+	}
+
+	repoDir := s.paths.SyncRepoDir()
+	files := make([]RepoFile, 0, len(relPaths))
+
+	for _, relPath := range relPaths {
+		info, err := s.fs.Stat(filepath.Join(repoDir, relPath))
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat %s: %w", relPath, err)
+		}
+
+		files = append(files, RepoFile{
+			Path:     relPath,
+			Category: fileCategory(relPath),
+			Size:     info.Size(),
+		})
+	}
+
+	return files, nil
+}
+
+// fileCategory classifies a repo-relative path
+func fileCategory(relPath string) string {
+	switch {
+	case strings.HasPrefix(relPath, hostsDir+string(filepath.Separator)):
+		return CategoryHosts
+	case strings.HasSuffix(relPath, ".age"):
+		return CategorySecrets
+	case strings.HasPrefix(relPath, "claude-skills"+string(filepath.Separator)):
+		return CategoryClaudeSkills
+	case strings.HasPrefix(relPath, "claude"+string(filepath.Separator)):
+		return CategoryClaude
+	case strings.HasPrefix(relPath, projectsDir+string(filepath.Separator)):
+		return CategoryProjects
+	default:
+		return CategoryOpenCode
+	}
+}
@@ -1,7 +1,9 @@
 package sync
 
 import (
+	"context"
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -13,6 +15,10 @@ import (
 	"github.com/GareArc/opencode-sync/internal/crypto"
 	"github.com/GareArc/opencode-sync/internal/git"
 	"github.com/GareArc/opencode-sync/internal/paths"
+	"github.com/GareArc/opencode-sync/internal/state"
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-billy/v5/util"
 )
 
 // Syncer handles synchronization between OpenCode config and sync repo
@@ -21,6 +27,64 @@ type Syncer struct {
 	paths      *paths.Paths
 	repo       git.Repository
 	encryption crypto.Encryption
+	fs         billy.Filesystem
+	clock      Clock
+	stats      CopyStats
+	timings    Timings
+
+	// skippedBinaries are binary files left out by the last CopyToRepo
+	skippedBinaries []string
+
+	// only limits copies to matching repo paths (see SetOnly)
+	only []string
+
+	// due limits copies to the sync targets that are due (see SetDue)
+	due map[string]bool
+
+	// locked are repo paths CopyFromRepo must not overwrite (see
+	// SetLocked), and lockedIncoming the files it wrote for them instead
+	locked         map[string]bool
+	lockedIncoming []string
+
+	// invalidApplied are the broken config files the last ApplyPulled
+	// applied in the direct layout (see InvalidApplied)
+	invalidApplied error
+
+	// renames are the files renamed by the pull being applied (see
+	// DetectRenames), and moved those CopyFromRepo moved locally
+	renames []git.FileChange
+	moved   []git.FileChange
+
+	// submodules are repo paths managed as git submodules
+	submodules []string
+
+	// hostEncryption and hostRecipient are this machine's own key, used
+	// for sync.hostSecrets (see LoadHostKey)
+	hostEncryption crypto.Encryption
+	hostRecipient  string
+
+	// hostSecrets are the files CopyToRepo found to be host secrets
+	hostSecrets []syncSource
+
+	// recipients is the repo's recipient policy (see recipientPolicy)
+	recipients *RecipientPolicy
+
+	// recipientsSecrets are the files CopyToRepo found to have their own
+	// recipients
+	recipientsSecrets []syncSource
+
+	// migrated are the layout migrations the last CopyToRepo applied
+	migrated []LayoutMigration
+
+	// ignore are the rules of the .syncignore files (see syncIgnoreRules)
+	ignore *syncIgnore
+
+	// hashCache holds file hashes from earlier runs (see hashes)
+	hashCache *hashCache
+
+	// sources is how each source was copied by the last CopyToRepo or
+	// CopyFromRepo (see copySources)
+	sources []SourceResult
 }
 
 // New creates a new Syncer instance
@@ -30,16 +94,57 @@ func New(cfg *config.Config, p *paths.Paths, repo git.Repository) *Syncer {
 		paths:      p,
 		repo:       repo,
 		encryption: nil, // Will be set if encryption is enabled
+		fs:         osFilesystem{osfs.Default},
+		clock:      systemClock{},
 	}
 }
 
+// Open creates a Syncer set up with this machine's settings: the files
+// locked here, the encryption key if encryption is enabled, and the key
+// for per-host secrets. The CLI and pkg/sync both build their syncer
+// with it, so they treat the repo the same way.
+func Open(cfg *config.Config, p *paths.Paths, repo git.Repository) (*Syncer, error) {
+	s := New(cfg, p, repo)
+
+	// Files locked on this machine are never overwritten by pulls
+	st, err := state.Load()
+	if err != nil {
+		return nil, err
+	}
+	s.SetLocked(st.Locked)
+
+	if cfg.Encryption.Enabled {
+		enc, err := crypto.LoadEncryption(p.KeyFile())
+		if errors.Is(err, crypto.ErrKeyMissing) {
+			return nil, err
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to load encryption key: %w", err)
+		}
+		s.SetEncryption(enc)
+	}
+
+	// Load this machine's own key for per-host secrets
+	if err := s.LoadHostKey(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Config returns the configuration the syncer was created with
+func (s *Syncer) Config() *config.Config {
+	return s.cfg
+}
+
 // SetEncryption sets the encryption instance
 func (s *Syncer) SetEncryption(enc crypto.Encryption) {
-	s.encryption = enc
+	s.encryption = s.timed(enc)
 }
 
 // SyncState represents the current sync state
 type SyncState struct {
+	Branch           string
 	IsClean          bool
 	HasLocalChanges  bool
 	HasRemoteChanges bool
@@ -67,6 +172,12 @@ func (s *Syncer) GetState() (*SyncState, error) {
 		ConflictFiles: []string{},
 	}
 
+	// The branch checked out is the active channel. A repo without
+	// commits has none yet.
+	if branch, err := s.repo.GetBranch(); err == nil {
+		state.Branch = branch
+	}
+
 	// Check if repo is clean
 	isClean, err := s.repo.IsClean()
 	if err != nil {
@@ -91,13 +202,272 @@ func (s *Syncer) GetState() (*SyncState, error) {
 	return state, nil
 }
 
-// CopyToRepo copies OpenCode config files to the sync repository
-func (s *Syncer) CopyToRepo() error {
-	syncablePaths := s.paths.SyncableOpenCodePaths()
+// PendingChanges returns the relative paths of local files that differ
+// from their copy in the sync repository
+func (s *Syncer) PendingChanges() ([]string, error) {
+	s.resetSyncIgnore()
+	if s.Direct() {
+		return s.directChanges()
+	}
+
+	files, err := s.getSyncableFiles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get syncable files: %w", err)
+	}
+
+	var pending []string
+	for _, file := range files {
+		if s.isHostSecret(file.RelPath) {
+			if !s.hostSecretCurrent(file) {
+				pending = append(pending, file.RelPath)
+			}
+			continue
+		}
+		if s.hasRecipients(file.RelPath) {
+			if !s.recipientsSecretCurrent(file) {
+				pending = append(pending, file.RelPath)
+			}
+			continue
+		}
+
+		repoPath := filepath.Join(s.paths.SyncRepoDir(), file.RelPath)
+
+		hash, err := s.hashFile(repoPath)
+		if err != nil {
+			pending = append(pending, file.RelPath)
+			continue
+		}
+
+		if hash != s.repoFormHash(file) {
+			pending = append(pending, file.RelPath)
+		}
+	}
+
+	return pending, nil
+}
+
+// repoFormHash returns the hash a local file would have once copied into
+// the sync repo
+func (s *Syncer) repoFormHash(file FileInfo) string {
+	repoPath := filepath.Join(s.paths.SyncRepoDir(), file.RelPath)
+
+	if s.cfg.Sync.SplitMcpSecrets && isMcpConfigFile(file.RelPath) {
+		// The repo holds the redacted form of the config
+		if data, err := s.readFile(file.Path); err == nil {
+			if redacted, _, err := splitMcpSecrets(data); err == nil {
+				return fmt.Sprintf("%x", sha256.Sum256(redacted))
+			}
+		}
+	} else if s.canonicalizes(repoPath) {
+		// The repo holds the canonical form
+		if data, err := s.readFile(file.Path); err == nil {
+			if canonical, err := canonicalJSON(data); err == nil {
+				return fmt.Sprintf("%x", sha256.Sum256(canonical))
+			}
+		}
+	}
+
+	return file.Hash
+}
+
+// syncSource is a local file or directory and its location in the sync repo
+type syncSource struct {
+	LocalPath string
+	RelPath   string
+}
+
+// syncSources returns every local path that is synced and where it is
+// stored in the repository
+func (s *Syncer) syncSources() []syncSource {
+	var sources []syncSource
+
+	for _, srcPath := range s.paths.SyncableOpenCodePaths() {
+		relPath, _ := filepath.Rel(s.paths.OpenCodeConfigDir, srcPath)
+		sources = append(sources, syncSource{LocalPath: srcPath, RelPath: relPath})
+	}
+
+	// Everything else lives outside the working tree
+	if s.Direct() {
+		return sources
+	}
+
+	sources = append(sources, s.claudeSources()...)
+
+	for _, project := range s.cfg.Projects {
+		if !project.Enabled {
+			continue
+		}
+
+		for _, name := range projectSyncPaths {
+			sources = append(sources, syncSource{
+				LocalPath: filepath.Join(project.Path, name),
+				RelPath:   filepath.Join(projectsDir, project.Name, name),
+			})
+		}
+	}
+
+	return sources
+}
+
+// CopyToRepo copies OpenCode config files to the sync repository. It
+// stops between files when ctx is cancelled.
+func (s *Syncer) CopyToRepo(ctx context.Context) error {
+	s.stats = CopyStats{}
+	s.sources = nil
+	s.skippedBinaries = nil
+	s.hostSecrets = nil
+	s.recipientsSecrets = nil
+	s.migrated = nil
+	s.resetSyncIgnore()
+
+	// Never push to a repo this build does not fully understand
+	if err := s.checkWorkingManifest(); err != nil {
+		return err
+	}
+
+	// The files are already in the working tree, where nothing keeps
+	// credentials out of the commit but a check
+	if s.Direct() {
+		changes, err := s.directChanges()
+		if err != nil {
+			return err
+		}
+		return s.checkSecrets(changes)
+	}
+
+	// Bring a repo written by an older version to the current layout
+	migrated, err := s.MigrateLayout()
+	if err != nil {
+		return err
+	}
+	s.migrated = migrated
+
+	// A pull may have changed who files are encrypted to
+	if err := s.reloadRecipients(); err != nil {
+		return err
+	}
+
+	// Submodule contents come from their own repositories
+	submodules, err := s.repo.Submodules()
+	if err != nil {
+		return err
+	}
+	s.submodules = submodules
+
+	// Let the commit record renames rather than a new file
+	if err := s.renameInRepo(); err != nil {
+		return err
+	}
+
+	// Sources are independent of each other, so they are copied at once
+	bySource := map[string][]syncSource{}
+	var names []string
+	for _, source := range s.syncSources() {
+		name := s.sourceOf(source.RelPath)
+		if _, ok := bySource[name]; !ok {
+			names = append(names, name)
+		}
+		bySource[name] = append(bySource[name], source)
+	}
+	if s.cfg.Sync.IncludeSessions && s.selected(sessionsDir, true) {
+		names = append(names, SourceSessions)
+	}
+
+	if err := s.copySources(ctx, names, func(ctx context.Context, w *Syncer, name string) error {
+		if name == SourceSessions {
+			return w.copySessionsToRepo(ctx)
+		}
+		return w.copySourcesToRepo(ctx, bySource[name])
+	}); err != nil {
+		return err
+	}
+
+	// Handle auth.json if enabled
+	if s.cfg.Sync.IncludeAuth && !s.authOnBranch() && s.onlyMatches(authRepoFile) {
+		if s.encryption == nil {
+			return fmt.Errorf("includeAuth requires encryption to be enabled: %w", crypto.ErrKeyMissing)
+		}
+
+		authSrc := s.paths.OpenCodeAuthFile()
+		if _, err := s.fs.Stat(authSrc); err == nil {
+			authDst := filepath.Join(s.paths.SyncRepoDir(), authRepoFile)
+
+			if err := s.encryptFileVerified("auth.json", authSrc, authDst); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Move MCP credentials out of the plaintext config if enabled
+	if s.cfg.Sync.SplitMcpSecrets {
+		if err := s.splitMcpConfigs(); err != nil {
+			return err
+		}
+	}
+
+	// Handle mcp-auth.json if enabled
+	if s.cfg.Sync.IncludeMcpAuth && !s.authOnBranch() && s.onlyMatches(mcpAuthRepoFile) {
+		if s.encryption == nil {
+			return fmt.Errorf("includeMcpAuth requires encryption to be enabled: %w", crypto.ErrKeyMissing)
+		}
+
+		mcpAuthSrc := s.paths.OpenCodeMcpAuthFile()
+		if _, err := s.fs.Stat(mcpAuthSrc); err == nil {
+			mcpAuthDst := filepath.Join(s.paths.SyncRepoDir(), mcpAuthRepoFile)
+
+			if err := s.encryptFileVerified("mcp-auth.json", mcpAuthSrc, mcpAuthDst); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Keep auth files out of history if enabled
+	if s.authOnBranch() && len(s.authArtifacts()) > 0 {
+		if s.encryption == nil {
+			return fmt.Errorf("authHistory requires encryption to be enabled: %w", crypto.ErrKeyMissing)
+		}
+
+		if err := s.writeAuthBranch(); err != nil {
+			return err
+		}
+	}
+
+	// Encrypt per-machine secrets to this machine's key if enabled
+	if len(s.cfg.Sync.HostSecrets) > 0 {
+		if err := s.copyHostSecretsToRepo(); err != nil {
+			return err
+		}
+	}
+
+	// Encrypt files with their own recipients and check the policy
+	if err := s.copyRecipientsSecretsToRepo(); err != nil {
+		return err
+	}
+
+	// Record modification times and executable flags if enabled
+	if s.preservesMetadata() {
+		if err := s.recordMetadata(); err != nil {
+			return err
+		}
+	}
+
+	// Tell other machines what they need to apply the repo
+	return s.writeManifest()
+}
+
+// copySourcesToRepo copies local paths to the sync repository, leaving
+// host secrets and files with their own recipients for CopyToRepo to
+// encrypt
+func (s *Syncer) copySourcesToRepo(ctx context.Context, sources []syncSource) error {
+	for _, source := range sources {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		srcPath := source.LocalPath
 
-	for _, srcPath := range syncablePaths {
 		// Check if path exists
-		info, err := os.Stat(srcPath)
+		info, err := s.fs.Stat(srcPath)
 		if os.IsNotExist(err) {
 			continue // Skip non-existent paths
 		}
@@ -105,24 +475,25 @@ func (s *Syncer) CopyToRepo() error {
 			return fmt.Errorf("failed to stat %s: %w", srcPath, err)
 		}
 
-		var relPath string
-		var dstPath string
-
-		if srcPath == s.paths.ClaudeSkillsDir {
-			dstPath = filepath.Join(s.paths.SyncRepoDir(), "claude-skills")
-		} else {
-			relPath, err = filepath.Rel(s.paths.OpenCodeConfigDir, srcPath)
-			if err != nil {
-				return fmt.Errorf("failed to get relative path: %w", err)
-			}
-			dstPath = filepath.Join(s.paths.SyncRepoDir(), relPath)
+		if !s.selected(source.RelPath, info.IsDir()) {
+			continue
 		}
 
+		dstPath := filepath.Join(s.paths.SyncRepoDir(), source.RelPath)
+
 		if info.IsDir() {
 			// Copy directory recursively
 			if err := s.copyDir(srcPath, dstPath); err != nil {
 				return fmt.Errorf("failed to copy directory %s: %w", srcPath, err)
 			}
+		} else if !s.onlyMatches(source.RelPath) || s.inSubmodule(source.RelPath) {
+			continue
+		} else if s.isHostSecret(source.RelPath) {
+			s.hostSecrets = append(s.hostSecrets, source)
+		} else if s.hasRecipients(source.RelPath) {
+			s.recipientsSecrets = append(s.recipientsSecrets, source)
+		} else if s.skipBinary(srcPath, source.RelPath) {
+			s.skippedBinaries = append(s.skippedBinaries, source.RelPath)
 		} else {
 			// Copy file
 			if err := s.copyFile(srcPath, dstPath); err != nil {
@@ -131,54 +502,238 @@ func (s *Syncer) CopyToRepo() error {
 		}
 	}
 
-	// Handle auth.json if enabled
-	if s.cfg.Sync.IncludeAuth {
-		if s.encryption == nil {
-			return fmt.Errorf("includeAuth requires encryption to be enabled")
+	return nil
+}
+
+// CopyFromRepo copies files from sync repository to OpenCode config. It
+// stops between files when ctx is cancelled, so callers that need an
+// all-or-nothing apply should back up first (see BackupLocal).
+func (s *Syncer) CopyFromRepo(ctx context.Context) error {
+	s.stats = CopyStats{}
+	s.sources = nil
+	s.resetSyncIgnore()
+
+	// Pulls update the files in place; this checks out what they left
+	// unchanged, such as the files of a fresh clone
+	if s.Direct() {
+		if err := s.repo.ResetHard("HEAD"); err != nil {
+			return fmt.Errorf("failed to copy from repo: %w", err)
 		}
+		return s.writeDirectExcludes()
+	}
 
-		authSrc := s.paths.OpenCodeAuthFile()
-		if _, err := os.Stat(authSrc); err == nil {
-			authDst := filepath.Join(s.paths.SyncRepoDir(), "auth.json.age")
+	if err := s.reloadRecipients(); err != nil {
+		return fmt.Errorf("failed to copy from repo: %w", err)
+	}
 
-			if err := s.encryption.EncryptFile(authSrc, authDst); err != nil {
-				return fmt.Errorf("failed to encrypt auth.json: %w", err)
-			}
+	relPaths, err := s.repoFiles()
+	if err != nil {
+		return fmt.Errorf("failed to copy from repo: %w", err)
+	}
+
+	authOnBranch := s.authOnBranch() && len(s.authArtifacts()) > 0
+	if authOnBranch {
+		if err := s.fetchAuthBranch(ctx); err != nil {
+			return fmt.Errorf("failed to copy from repo: %w", err)
 		}
 	}
 
-	// Handle mcp-auth.json if enabled
-	if s.cfg.Sync.IncludeMcpAuth {
-		if s.encryption == nil {
-			return fmt.Errorf("includeMcpAuth requires encryption to be enabled")
+	var metadata map[string]fileMetadata
+	if s.preservesMetadata() {
+		metadata, err = s.loadMetadata()
+		if err != nil {
+			return fmt.Errorf("failed to copy from repo: %w", err)
 		}
+	}
 
-		mcpAuthSrc := s.paths.OpenCodeMcpAuthFile()
-		if _, err := os.Stat(mcpAuthSrc); err == nil {
-			mcpAuthDst := filepath.Join(s.paths.SyncRepoDir(), "mcp-auth.json.age")
+	var secretsByFile map[string]mcpSecrets
+	if s.cfg.Sync.SplitMcpSecrets {
+		secretsByFile, err = s.loadMcpSecrets()
+		if err != nil {
+			return fmt.Errorf("failed to copy from repo: %w", err)
+		}
+	}
+
+	// Renamed files are moved, so no copy is left at the old path
+	if err := s.moveRenamed(); err != nil {
+		return fmt.Errorf("failed to copy from repo: %w", err)
+	}
+
+	// Sources are independent of each other, so they are applied at once
+	bySource := map[string][]string{}
+	var names []string
+	for _, relPath := range relPaths {
+		name := s.sourceOf(relPath)
+		if _, ok := bySource[name]; !ok {
+			names = append(names, name)
+		}
+		bySource[name] = append(bySource[name], relPath)
+	}
+
+	incomingBySource := make(map[string]*[]string, len(names))
+	for _, name := range names {
+		incomingBySource[name] = new([]string)
+	}
 
-			if err := s.encryption.EncryptFile(mcpAuthSrc, mcpAuthDst); err != nil {
-				return fmt.Errorf("failed to encrypt mcp-auth.json: %w", err)
+	if err := s.copySources(ctx, names, func(ctx context.Context, w *Syncer, name string) error {
+		for _, relPath := range bySource[name] {
+			if err := ctx.Err(); err != nil {
+				return err
 			}
+
+			incoming, err := w.applyRepoFile(relPath, metadata, secretsByFile)
+			if err != nil {
+				return err
+			}
+			if incoming != "" {
+				*incomingBySource[name] = append(*incomingBySource[name], incoming)
+			}
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to copy from repo: %w", err)
+	}
+
+	var incoming []string
+	for _, name := range names {
+		incoming = append(incoming, *incomingBySource[name]...)
+	}
+
+	if err := s.keepLocked(incoming); err != nil {
+		return fmt.Errorf("failed to copy from repo: %w", err)
+	}
+
+	if authOnBranch {
+		if s.encryption == nil {
+			return fmt.Errorf("failed to copy from repo: auth files are kept on %s but encryption is not enabled: %w", AuthBranch, crypto.ErrKeyMissing)
+		}
+
+		if err := s.copyAuthFromBranch(); err != nil {
+			return fmt.Errorf("failed to copy from repo: %w", err)
 		}
 	}
 
+	// This machine's secrets take precedence over any shared copy
+	if err := s.copyHostSecretsFromRepo(); err != nil {
+		return fmt.Errorf("failed to copy from repo: %w", err)
+	}
+
 	return nil
 }
 
-// CopyFromRepo copies files from sync repository to OpenCode config
-func (s *Syncer) CopyFromRepo() error {
+// applyRepoFile applies one repo file to its local path, decrypting it
+// or restoring its MCP credentials as needed. For a locked file it returns
+// the path the incoming version was written to instead.
+func (s *Syncer) applyRepoFile(relPath string, metadata map[string]fileMetadata, secretsByFile map[string]mcpSecrets) (string, error) {
+	path := filepath.Join(s.paths.SyncRepoDir(), relPath)
+
+	dstPath := s.localPath(relPath)
+	if dstPath == "" || !s.onlyMatches(relPath) {
+		return "", nil
+	}
+
+	// Locked files keep the local version; the incoming one goes
+	// next to it
+	incoming := ""
+	if s.locked[relPath] {
+		dstPath += IncomingSuffix
+		incoming = dstPath
+	}
+
+	// Handle encrypted auth.json
+	if s.currentPath(relPath) == authRepoFile && s.cfg.Sync.IncludeAuth {
+		if s.encryption == nil {
+			return "", fmt.Errorf("found encrypted auth.json but encryption is not enabled: %w", crypto.ErrKeyMissing)
+		}
+
+		if err := s.decryptFile(path, dstPath); err != nil {
+			return "", fmt.Errorf("failed to decrypt auth.json: %w", err)
+		}
+		return incoming, nil
+	}
+
+	// Handle encrypted mcp-auth.json
+	if s.currentPath(relPath) == mcpAuthRepoFile && s.cfg.Sync.IncludeMcpAuth {
+		if s.encryption == nil {
+			return "", fmt.Errorf("found encrypted mcp-auth.json but encryption is not enabled: %w", crypto.ErrKeyMissing)
+		}
+
+		if err := s.decryptFile(path, dstPath); err != nil {
+			return "", fmt.Errorf("failed to decrypt mcp-auth.json: %w", err)
+		}
+		return incoming, nil
+	}
+
+	// Handle encrypted session history
+	if isSessionFile(relPath) {
+		if s.encryption == nil {
+			return "", fmt.Errorf("found encrypted session history but encryption is not enabled: %w", crypto.ErrKeyMissing)
+		}
+
+		if err := s.decryptFile(path, dstPath); err != nil {
+			return "", fmt.Errorf("failed to decrypt %s: %w", relPath, err)
+		}
+		return incoming, nil
+	}
+
+	// Handle files encrypted to their own recipients
+	if _, ok := s.recipientsSecretPath(relPath); ok {
+		if err := s.copyRecipientsSecretFromRepo(path, dstPath); err != nil {
+			return "", fmt.Errorf("failed to decrypt %s: %w", relPath, err)
+		}
+		return incoming, nil
+	}
+
+	// Restore MCP credentials into the OpenCode config
+	if secrets := secretsByFile[relPath]; len(secrets) > 0 {
+		data, err := s.readFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", relPath, err)
+		}
+
+		merged, err := mergeMcpSecrets(data, secrets)
+		if err != nil {
+			return "", fmt.Errorf("failed to merge MCP secrets into %s: %w", relPath, err)
+		}
+
+		if err := s.fs.MkdirAll(filepath.Dir(dstPath), 0755); err != nil {
+			return "", fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err := util.WriteFile(s.fs, dstPath, merged, 0600); err != nil {
+			return "", fmt.Errorf("failed to write %s: %w", relPath, err)
+		}
+		return incoming, nil
+	}
+
+	// Copy file
+	if err := s.copyFile(path, dstPath); err != nil {
+		return "", fmt.Errorf("failed to copy %s: %w", relPath, err)
+	}
+	if err := s.applyMetadata(dstPath, metadata[relPath]); err != nil {
+		return "", err
+	}
+
+	return incoming, nil
+}
+
+// repoFiles returns the repo-relative paths of all files in the sync
+// repository that are not excluded
+func (s *Syncer) repoFiles() ([]string, error) {
 	repoDir := s.paths.SyncRepoDir()
 
-	// Walk through repo directory
-	err := filepath.Walk(repoDir, func(path string, info os.FileInfo, err error) error {
+	var relPaths []string
+	err := util.Walk(s.fs, repoDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 
-		// Skip .git directory
-		if info.IsDir() && info.Name() == ".git" {
-			return filepath.SkipDir
+		// Skip .git directory, and the .git file linking a submodule
+		// to its repository
+		if info.Name() == ".git" {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
 		}
 
 		if info.IsDir() {
@@ -191,74 +746,101 @@ func (s *Syncer) CopyFromRepo() error {
 			return fmt.Errorf("failed to get relative path: %w", err)
 		}
 
-		// Skip excluded patterns
+		// Skip excluded patterns and repo metadata
 		if s.shouldExclude(relPath) {
 			return nil
 		}
-
-		// Determine destination
-		var dstPath string
-		if strings.HasPrefix(relPath, "claude-skills"+string(filepath.Separator)) || relPath == "claude-skills" {
-			relToClaudeSkills, _ := filepath.Rel("claude-skills", relPath)
-			if relToClaudeSkills == "." {
-				return nil
-			}
-			dstPath = filepath.Join(s.paths.ClaudeSkillsDir, relToClaudeSkills)
-		} else {
-			dstPath = filepath.Join(s.paths.OpenCodeConfigDir, relPath)
+		if _, ok := repoGitFiles[relPath]; ok {
+			return nil
 		}
 
-		// Handle encrypted auth.json
-		if relPath == "auth.json.age" && s.cfg.Sync.IncludeAuth {
-			if s.encryption == nil {
-				return fmt.Errorf("found encrypted auth.json but encryption is not enabled")
-			}
+		relPaths = append(relPaths, relPath)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-			dstPath = s.paths.OpenCodeAuthFile()
+	return relPaths, nil
+}
 
-			if err := s.encryption.DecryptFile(path, dstPath); err != nil {
-				return fmt.Errorf("failed to decrypt auth.json: %w", err)
-			}
-			return nil
-		}
+// localPath maps a repo-relative path to its destination on this machine.
+// It returns an empty string for paths that have no local destination.
+func (s *Syncer) localPath(relPath string) string {
+	// Files encrypted to their own recipients are stored as <path>.age
+	if plain, ok := s.recipientsSecretPath(relPath); ok {
+		relPath = plain
+	}
 
-		// Handle encrypted mcp-auth.json
-		if relPath == "mcp-auth.json.age" && s.cfg.Sync.IncludeMcpAuth {
-			if s.encryption == nil {
-				return fmt.Errorf("found encrypted mcp-auth.json but encryption is not enabled")
-			}
+	// Claude Code paths are only restored when enabled on this machine
+	if local, ok := s.claudeLocalPath(relPath); ok {
+		return local
+	}
 
-			dstPath = s.paths.OpenCodeMcpAuthFile()
+	if strings.HasPrefix(relPath, projectsDir+string(filepath.Separator)) {
+		return s.projectLocalPath(relPath)
+	}
 
-			if err := s.encryption.DecryptFile(path, dstPath); err != nil {
-				return fmt.Errorf("failed to decrypt mcp-auth.json: %w", err)
-			}
-			return nil
-		}
+	// Per-host data is managed by the hosts command, never copied
+	if strings.HasPrefix(relPath, hostsDir+string(filepath.Separator)) {
+		return ""
+	}
 
-		// Copy file
-		if err := s.copyFile(path, dstPath); err != nil {
-			return fmt.Errorf("failed to copy %s: %w", relPath, err)
-		}
+	// Session history is only restored when enabled on this machine
+	if isSessionFile(relPath) {
+		return s.sessionLocalPath(relPath)
+	}
 
-		return nil
-	})
+	// MCP secrets are merged into the OpenCode config, never copied
+	if relPath == mcpSecretsFile {
+		return ""
+	}
 
-	if err != nil {
-		return fmt.Errorf("failed to copy from repo: %w", err)
+	// File metadata is applied to the files it describes
+	if relPath == metadataFile {
+		return ""
 	}
 
-	return nil
+	// The recipient policy only applies to the repo
+	if relPath == recipientsFile {
+		return ""
+	}
+
+	// The manifest describes the repo itself
+	if relPath == manifestFile {
+		return ""
+	}
+
+	// The baseline is compared with the local config, never copied
+	if strings.HasPrefix(relPath, baselineDir+string(filepath.Separator)) {
+		return ""
+	}
+
+	// Auth files are only restored when enabled on this machine
+	switch s.currentPath(relPath) {
+	case authRepoFile:
+		if s.cfg.Sync.IncludeAuth {
+			return s.paths.OpenCodeAuthFile()
+		}
+		return ""
+	case mcpAuthRepoFile:
+		if s.cfg.Sync.IncludeMcpAuth {
+			return s.paths.OpenCodeMcpAuthFile()
+		}
+		return ""
+	}
+
+	return filepath.Join(s.paths.OpenCodeConfigDir, relPath)
 }
 
 // getSyncableFiles returns list of files that should be synced
 func (s *Syncer) getSyncableFiles() ([]FileInfo, error) {
 	var files []FileInfo
 
-	syncablePaths := s.paths.SyncableOpenCodePaths()
+	for _, source := range s.syncSources() {
+		srcPath := source.LocalPath
 
-	for _, srcPath := range syncablePaths {
-		info, err := os.Stat(srcPath)
+		info, err := s.fs.Stat(srcPath)
 		if os.IsNotExist(err) {
 			continue
 		}
@@ -266,29 +848,23 @@ func (s *Syncer) getSyncableFiles() ([]FileInfo, error) {
 			return nil, fmt.Errorf("failed to stat %s: %w", srcPath, err)
 		}
 
-		var relPath string
-		if srcPath == s.paths.ClaudeSkillsDir {
-			relPath = "claude-skills"
-		} else {
-			relPath, _ = filepath.Rel(s.paths.OpenCodeConfigDir, srcPath)
+		relPath := source.RelPath
+
+		if !s.selected(relPath, info.IsDir()) {
+			continue
 		}
 
 		if info.IsDir() {
 			// Walk directory
-			err := filepath.Walk(srcPath, func(path string, info os.FileInfo, err error) error {
+			err := util.Walk(s.fs, srcPath, func(path string, info os.FileInfo, err error) error {
 				if err != nil || info.IsDir() {
 					return err
 				}
 
-				var fileRelPath string
-				if srcPath == s.paths.ClaudeSkillsDir {
-					pathRelToClaudeSkills, _ := filepath.Rel(s.paths.ClaudeSkillsDir, path)
-					fileRelPath = filepath.Join("claude-skills", pathRelToClaudeSkills)
-				} else {
-					fileRelPath, _ = filepath.Rel(s.paths.OpenCodeConfigDir, path)
-				}
+				pathRelToSource, _ := filepath.Rel(srcPath, path)
+				fileRelPath := filepath.Join(relPath, pathRelToSource)
 
-				if s.shouldExclude(fileRelPath) {
+				if s.shouldExclude(fileRelPath) || s.isIncomingCopy(fileRelPath) || s.skipBinary(path, fileRelPath) {
 					return nil
 				}
 
@@ -311,7 +887,7 @@ func (s *Syncer) getSyncableFiles() ([]FileInfo, error) {
 				return nil, err
 			}
 		} else {
-			if s.shouldExclude(relPath) {
+			if s.shouldExclude(relPath) || s.isIncomingCopy(relPath) || s.skipBinary(srcPath, relPath) {
 				continue
 			}
 
@@ -333,8 +909,45 @@ func (s *Syncer) getSyncableFiles() ([]FileInfo, error) {
 	return files, nil
 }
 
-// shouldExclude checks if a path should be excluded
+// inSubmodule reports whether a repo path lies inside a submodule
+func (s *Syncer) inSubmodule(relPath string) bool {
+	for _, sub := range s.submodules {
+		if relPath == sub || strings.HasPrefix(relPath, sub+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// selected reports whether a repo path is within sync.onlyDirs.
+// Top-level files are always selected.
+func (s *Syncer) selected(relPath string, isDir bool) bool {
+	if len(s.cfg.Sync.OnlyDirs) == 0 {
+		return true
+	}
+
+	relPath = filepath.ToSlash(relPath)
+	if !isDir && !strings.Contains(relPath, "/") {
+		return true
+	}
+
+	for _, dir := range s.cfg.Sync.OnlyDirs {
+		if relPath == dir || strings.HasPrefix(relPath, dir+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// shouldExclude checks if a path should be excluded by sync.exclude or
+// a .syncignore file
 func (s *Syncer) shouldExclude(path string) bool {
+	return s.excludedByConfig(path) || s.syncIgnored(path)
+}
+
+// excludedByConfig checks if a path matches sync.exclude
+func (s *Syncer) excludedByConfig(path string) bool {
 	for _, pattern := range s.cfg.Sync.Exclude {
 		matched, _ := filepath.Match(pattern, filepath.Base(path))
 		if matched {
@@ -352,19 +965,32 @@ func (s *Syncer) shouldExclude(path string) bool {
 func (s *Syncer) copyFile(src, dst string) error {
 	// Create destination directory
 	dstDir := filepath.Dir(dst)
-	if err := os.MkdirAll(dstDir, 0755); err != nil {
+	if err := s.fs.MkdirAll(dstDir, 0755); err != nil {
 		return fmt.Errorf("failed to create directory: %w", err)
 	}
 
+	defer s.timings.Start(PhaseCopying)()
+
+	if s.canonicalizes(dst) {
+		if copied, err := s.copyCanonical(src, dst); copied || err != nil {
+			return err
+		}
+	}
+
+	if s.tryReflink(src, dst) {
+		return nil
+	}
+	s.stats.Copied++
+
 	// Open source file
-	srcFile, err := os.Open(src)
+	srcFile, err := s.fs.Open(src)
 	if err != nil {
 		return fmt.Errorf("failed to open source: %w", err)
 	}
 	defer srcFile.Close()
 
 	// Create destination file
-	dstFile, err := os.Create(dst)
+	dstFile, err := s.fs.Create(dst)
 	if err != nil {
 		return fmt.Errorf("failed to create destination: %w", err)
 	}
@@ -376,11 +1002,11 @@ func (s *Syncer) copyFile(src, dst string) error {
 	}
 
 	// Copy file mode
-	srcInfo, err := os.Stat(src)
+	srcInfo, err := s.fs.Stat(src)
 	if err != nil {
 		return fmt.Errorf("failed to stat source: %w", err)
 	}
-	if err := os.Chmod(dst, srcInfo.Mode()); err != nil {
+	if err := s.chmod(dst, srcInfo.Mode()); err != nil {
 		return fmt.Errorf("failed to set mode: %w", err)
 	}
 
@@ -390,18 +1016,18 @@ func (s *Syncer) copyFile(src, dst string) error {
 // copyDir copies a directory recursively
 func (s *Syncer) copyDir(src, dst string) error {
 	// Get source info
-	srcInfo, err := os.Stat(src)
+	srcInfo, err := s.fs.Stat(src)
 	if err != nil {
 		return fmt.Errorf("failed to stat source: %w", err)
 	}
 
 	// Create destination directory
-	if err := os.MkdirAll(dst, srcInfo.Mode()); err != nil {
+	if err := s.fs.MkdirAll(dst, srcInfo.Mode()); err != nil {
 		return fmt.Errorf("failed to create destination: %w", err)
 	}
 
 	// Read directory entries
-	entries, err := os.ReadDir(src)
+	entries, err := s.fs.ReadDir(src)
 	if err != nil {
 		return fmt.Errorf("failed to read directory: %w", err)
 	}
@@ -410,11 +1036,34 @@ func (s *Syncer) copyDir(src, dst string) error {
 		srcPath := filepath.Join(src, entry.Name())
 		dstPath := filepath.Join(dst, entry.Name())
 
+		// sync.exclude applies to pushes too, not just to status and pull,
+		// and the incoming versions of locked files stay on this machine
+		if relPath, err := filepath.Rel(s.paths.SyncRepoDir(), dstPath); err == nil && (s.shouldExclude(relPath) || s.isIncomingCopy(relPath)) {
+			continue
+		}
+
 		if entry.IsDir() {
 			if err := s.copyDir(srcPath, dstPath); err != nil {
 				return err
 			}
 		} else {
+			if relPath, err := filepath.Rel(s.paths.SyncRepoDir(), dstPath); err == nil {
+				if !s.onlyMatches(relPath) || s.inSubmodule(relPath) {
+					continue
+				}
+				if s.isHostSecret(relPath) {
+					s.hostSecrets = append(s.hostSecrets, syncSource{LocalPath: srcPath, RelPath: relPath})
+					continue
+				}
+				if s.hasRecipients(relPath) {
+					s.recipientsSecrets = append(s.recipientsSecrets, syncSource{LocalPath: srcPath, RelPath: relPath})
+					continue
+				}
+				if s.skipBinary(srcPath, relPath) {
+					s.skippedBinaries = append(s.skippedBinaries, relPath)
+					continue
+				}
+			}
 			if err := s.copyFile(srcPath, dstPath); err != nil {
 				return err
 			}
@@ -424,9 +1073,49 @@ func (s *Syncer) copyDir(src, dst string) error {
 	return nil
 }
 
-// hashFile calculates SHA256 hash of a file
+// decryptFile decrypts src into dst with owner-only permissions
+func (s *Syncer) decryptFile(src, dst string) error {
+	return s.decryptFileWith(s.encryption, src, dst)
+}
+
+// DecryptRepoFile decrypts the content of an .age file at relPath in the
+// repo, with this machine's host key for its own host secrets and the
+// shared key otherwise
+func (s *Syncer) DecryptRepoFile(relPath string, ciphertext []byte) ([]byte, error) {
+	enc := s.encryption
+	if strings.HasPrefix(filepath.ToSlash(relPath), hostsDir+"/"+Hostname()+"/"+hostSecretsDir+"/") {
+		enc = s.hostEncryption
+	}
+	if enc == nil {
+		return nil, fmt.Errorf("no key loaded to decrypt %s", relPath)
+	}
+
+	plaintext, err := enc.Decrypt(ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s: %w", relPath, err)
+	}
+	return plaintext, nil
+}
+
+// decryptFileWith is decryptFile with an explicit key
+func (s *Syncer) decryptFileWith(enc crypto.Encryption, src, dst string) error {
+	return s.streamDecrypted(enc, src, dst)
+}
+
+// hashFile calculates SHA256 hash of a file. Files whose size and
+// modification time match the hash cache are not read.
 func (s *Syncer) hashFile(path string) (string, error) {
-	f, err := os.Open(path)
+	defer s.timings.Start(PhaseHashing)()
+
+	info, err := s.fs.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if hash, ok := s.cachedHash(path, info); ok {
+		return hash, nil
+	}
+
+	f, err := s.fs.Open(path)
 	if err != nil {
 		return "", err
 	}
@@ -437,5 +1126,7 @@ func (s *Syncer) hashFile(path string) (string, error) {
 		return "", err
 	}
 
-	return fmt.Sprintf("%x", h.Sum(nil)), nil
+	hash := fmt.Sprintf("%x", h.Sum(nil))
+	s.cacheHash(path, info, hash)
+	return hash, nil
 }
@@ -0,0 +1,146 @@
+package sync
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+)
+
+// syncIgnoreFile holds exclusions in gitignore syntax, in the OpenCode
+// config dir or any synced directory below it. Its rules apply to the
+// directory it is in, on top of sync.exclude.
+const syncIgnoreFile = ".syncignore"
+
+// syncIgnore is the rules of every .syncignore file
+type syncIgnore struct {
+	matcher gitignore.Matcher
+
+	// rules are the rules rewritten relative to the OpenCode config dir,
+	// for the direct layout's info/exclude
+	rules []string
+}
+
+// syncIgnoreRules returns the rules of the .syncignore files, read once
+// per operation (see resetSyncIgnore)
+func (s *Syncer) syncIgnoreRules() *syncIgnore {
+	if s.ignore == nil {
+		s.ignore = s.loadSyncIgnore()
+	}
+	return s.ignore
+}
+
+// resetSyncIgnore makes the next check read the .syncignore files again
+func (s *Syncer) resetSyncIgnore() {
+	s.ignore = nil
+}
+
+// loadSyncIgnore reads the .syncignore files of the OpenCode config dir
+// and the synced directories in it. Like git, it does not look inside
+// directories that are excluded. Unreadable files are skipped.
+func (s *Syncer) loadSyncIgnore() *syncIgnore {
+	ignore := &syncIgnore{}
+	var patterns []gitignore.Pattern
+
+	read := func(dir []string) {
+		data, err := util.ReadFile(s.fs, filepath.Join(append([]string{s.paths.OpenCodeConfigDir}, dir...)...))
+		if err != nil {
+			return
+		}
+		dir = dir[:len(dir)-1]
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimRight(line, "\r")
+			if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			patterns = append(patterns, gitignore.ParsePattern(line, dir))
+			ignore.rules = append(ignore.rules, rootIgnoreRule(strings.Join(dir, "/"), line))
+		}
+		ignore.matcher = gitignore.NewMatcher(patterns)
+	}
+
+	read([]string{syncIgnoreFile})
+	for _, srcPath := range s.paths.SyncableOpenCodePaths() {
+		if info, err := s.fs.Stat(srcPath); err != nil || !info.IsDir() {
+			continue
+		}
+		_ = util.Walk(s.fs, srcPath, func(path string, info os.FileInfo, err error) error {
+			if err != nil || !info.IsDir() {
+				return nil
+			}
+			relPath, err := filepath.Rel(s.paths.OpenCodeConfigDir, path)
+			if err != nil {
+				return nil
+			}
+			if s.excludedByConfig(relPath) || ignore.match(relPath, true) {
+				return filepath.SkipDir
+			}
+			read(append(strings.Split(filepath.ToSlash(relPath), "/"), syncIgnoreFile))
+			return nil
+		})
+	}
+
+	return ignore
+}
+
+// match reports whether the rules exclude a path relative to the
+// OpenCode config dir
+func (ignore *syncIgnore) match(relPath string, isDir bool) bool {
+	if ignore.matcher == nil {
+		return false
+	}
+	return ignore.matcher.Match(strings.Split(filepath.ToSlash(relPath), "/"), isDir)
+}
+
+// syncIgnored reports whether a .syncignore file excludes a repo path.
+// Only paths synced from the OpenCode config dir are affected.
+func (s *Syncer) syncIgnored(relPath string) bool {
+	if !s.inOpenCodeConfig(relPath) {
+		return false
+	}
+	return s.syncIgnoreRules().match(relPath, false)
+}
+
+// inOpenCodeConfig reports whether a repo path is synced from the
+// OpenCode config dir
+func (s *Syncer) inOpenCodeConfig(relPath string) bool {
+	top := strings.SplitN(filepath.ToSlash(relPath), "/", 2)[0]
+	for _, srcPath := range s.paths.SyncableOpenCodePaths() {
+		if filepath.Base(srcPath) == top {
+			return true
+		}
+	}
+	return false
+}
+
+// rootIgnoreRule rewrites a rule of the .syncignore file in dir, relative
+// to the OpenCode config dir, so it means the same at the root
+func rootIgnoreRule(dir, rule string) string {
+	if dir == "" {
+		return rule
+	}
+
+	negate := ""
+	if strings.HasPrefix(rule, "!") {
+		negate, rule = "!", rule[1:]
+	}
+
+	// Like git, a rule with a slash before its end is anchored to the
+	// directory of its file; one without matches at any depth below it
+	trimmed := strings.TrimSuffix(rule, "/")
+	if strings.Contains(trimmed, "/") {
+		return negate + "/" + path.Join(dir, strings.TrimPrefix(rule, "/")) + suffixSlash(rule)
+	}
+	return negate + "/" + dir + "/**/" + rule
+}
+
+// suffixSlash returns "/" if rule only matches directories
+func suffixSlash(rule string) string {
+	if strings.HasSuffix(rule, "/") {
+		return "/"
+	}
+	return ""
+}
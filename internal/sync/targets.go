@@ -0,0 +1,88 @@
+package sync
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// TargetIntervals returns the push interval of each sync.targets entry
+// that sets one
+func (s *Syncer) TargetIntervals() map[string]time.Duration {
+	return s.cfg.Sync.TargetIntervals()
+}
+
+// Target returns the name of the sync.targets entry with an interval
+// covering a repo path, or "" if none does. Names match like sync.hostSecrets, against the path
+// with and without its .age suffix; where several match, the longest name
+// wins, so "agent/review.md" beats "agent".
+func (s *Syncer) Target(relPath string) string {
+	name := strings.TrimSuffix(relPath, ".age")
+
+	target := ""
+	for pattern := range s.cfg.Sync.TargetIntervals() {
+		if len(pattern) <= len(target) {
+			continue
+		}
+		if matchPathPatterns([]string{pattern}, relPath) || matchPathPatterns([]string{pattern}, name) {
+			target = pattern
+		}
+	}
+	return target
+}
+
+// SetDue limits the next CopyToRepo to repo paths whose target is in due,
+// where "" stands for the paths no target covers. A nil due means
+// everything.
+func (s *Syncer) SetDue(due map[string]bool) error {
+	if due != nil && s.Direct() {
+		// Every change in the working tree is committed
+		return fmt.Errorf("sync.targets is not supported with repo.layout direct")
+	}
+
+	s.due = due
+	return nil
+}
+
+// DuePending returns the files PendingChanges reports, and the encrypted
+// auth files whose local copy changed, that belong to a target in due.
+// A nil due means every target.
+func (s *Syncer) DuePending(due map[string]bool) ([]string, error) {
+	pending, err := s.PendingChanges()
+	if err != nil {
+		return nil, err
+	}
+
+	if !s.Direct() && !s.authOnBranch() && s.encryption != nil {
+		if s.cfg.Sync.IncludeAuth && !s.authCurrent(s.paths.OpenCodeAuthFile(), authRepoFile) {
+			pending = append(pending, authRepoFile)
+		}
+		if s.cfg.Sync.IncludeMcpAuth && !s.authCurrent(s.paths.OpenCodeMcpAuthFile(), mcpAuthRepoFile) {
+			pending = append(pending, mcpAuthRepoFile)
+		}
+	}
+
+	if due == nil {
+		return pending, nil
+	}
+
+	var filtered []string
+	for _, relPath := range pending {
+		if due[s.Target(relPath)] {
+			filtered = append(filtered, relPath)
+		}
+	}
+	return filtered, nil
+}
+
+// authCurrent reports whether the encrypted auth file at relPath in the
+// repo holds the local file at path, or there is no local file
+func (s *Syncer) authCurrent(path, relPath string) bool {
+	hash, err := s.hashFile(path)
+	if err != nil {
+		return true
+	}
+
+	return s.encryptedCurrent(s.encryption, filepath.Join(s.paths.SyncRepoDir(), s.layoutPath(relPath)), hash)
+}
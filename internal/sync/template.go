@@ -0,0 +1,73 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// plaintextSecretNames are credential files that are only ever synced
+// encrypted, and never taken from a template repository
+var plaintextSecretNames = []string{"auth.json", "mcp-auth.json"}
+
+// SeedFromTemplate copies the files of a template checkout into the sync
+// repo. Encrypted files, plaintext credentials and per-host data are
+// skipped and returned so the caller can report them.
+func (s *Syncer) SeedFromTemplate(templateDir string) ([]string, error) {
+	var skipped []string
+
+	err := filepath.Walk(templateDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(templateDir, path)
+		if err != nil {
+			return fmt.Errorf("failed to get relative path: %w", err)
+		}
+
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			if relPath == hostsDir {
+				skipped = append(skipped, relPath+string(filepath.Separator))
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if IsTemplateSecret(relPath) {
+			skipped = append(skipped, relPath)
+			return nil
+		}
+
+		return s.copyFile(path, filepath.Join(s.paths.SyncRepoDir(), relPath))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy template: %w", err)
+	}
+
+	return skipped, nil
+}
+
+// IsTemplateSecret reports whether a file from a template or upstream
+// repository holds credentials or per-host data that must not be taken
+func IsTemplateSecret(relPath string) bool {
+	if strings.HasSuffix(relPath, ".age") {
+		return true
+	}
+
+	if strings.HasPrefix(filepath.ToSlash(relPath), hostsDir+"/") {
+		return true
+	}
+
+	for _, name := range plaintextSecretNames {
+		if filepath.Base(relPath) == name {
+			return true
+		}
+	}
+
+	return false
+}
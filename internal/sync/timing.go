@@ -0,0 +1,145 @@
+package sync
+
+import (
+	"io"
+	"time"
+
+	"github.com/GareArc/opencode-sync/internal/crypto"
+)
+
+// Phases timed during push and pull, in the order they are reported
+const (
+	PhaseHashing    = "hashing"
+	PhaseCopying    = "copying"
+	PhaseEncryption = "encryption"
+	PhaseBackup     = "backup"
+	PhaseGit        = "git add/commit"
+	PhaseNetwork    = "network"
+	PhaseGC         = "gc"
+)
+
+// Phases lists every phase in report order
+var Phases = []string{PhaseHashing, PhaseCopying, PhaseEncryption, PhaseBackup, PhaseGit, PhaseNetwork, PhaseGC}
+
+// Timings adds up the time an operation spends in each phase. A phase
+// started while another is running pauses the outer one, so no time is
+// counted twice. Timings is not safe for concurrent use: work done
+// concurrently is timed on separate Timings (see addConcurrent).
+type Timings struct {
+	totals map[string]time.Duration
+	active []string
+	since  time.Time
+}
+
+// Start begins timing phase and returns the function that ends it:
+//
+//	defer timings.Start(PhaseCopying)()
+func (t *Timings) Start(phase string) func() {
+	if t.totals == nil {
+		t.totals = make(map[string]time.Duration)
+	}
+
+	t.flush()
+	t.active = append(t.active, phase)
+
+	return func() {
+		t.flush()
+		t.active = t.active[:len(t.active)-1]
+	}
+}
+
+// flush credits the time since the last change to the innermost phase
+func (t *Timings) flush() {
+	now := time.Now()
+	if n := len(t.active); n > 0 {
+		t.totals[t.active[n-1]] += now.Sub(t.since)
+	}
+	t.since = now
+}
+
+// addConcurrent credits the phases of work that ran concurrently for
+// elapsed time. The work may add up to more than elapsed, and is then
+// scaled down so that no time is counted twice.
+func (t *Timings) addConcurrent(totals []map[string]time.Duration, elapsed time.Duration) {
+	var sum time.Duration
+	for _, phases := range totals {
+		for _, d := range phases {
+			sum += d
+		}
+	}
+	if sum == 0 {
+		return
+	}
+
+	scale := 1.0
+	if sum > elapsed {
+		scale = float64(elapsed) / float64(sum)
+	}
+
+	if t.totals == nil {
+		t.totals = make(map[string]time.Duration)
+	}
+	for _, phases := range totals {
+		for phase, d := range phases {
+			t.totals[phase] += time.Duration(float64(d) * scale)
+		}
+	}
+}
+
+// Totals returns the time spent in each phase that ran
+func (t *Timings) Totals() map[string]time.Duration {
+	totals := make(map[string]time.Duration, len(t.totals))
+	for phase, d := range t.totals {
+		totals[phase] = d
+	}
+	return totals
+}
+
+// Timings returns the phase timings collected by this syncer
+func (s *Syncer) Timings() *Timings {
+	return &s.timings
+}
+
+// timedEncryption counts the time spent in an Encryption as PhaseEncryption
+type timedEncryption struct {
+	crypto.Encryption
+	timings *Timings
+}
+
+// timed wraps enc so its work is timed, keeping a nil enc nil
+func (s *Syncer) timed(enc crypto.Encryption) crypto.Encryption {
+	if enc == nil {
+		return nil
+	}
+	return timedEncryption{Encryption: enc, timings: &s.timings}
+}
+
+func (e timedEncryption) Encrypt(plaintext []byte) ([]byte, error) {
+	defer e.timings.Start(PhaseEncryption)()
+	return e.Encryption.Encrypt(plaintext)
+}
+
+func (e timedEncryption) Decrypt(ciphertext []byte) ([]byte, error) {
+	defer e.timings.Start(PhaseEncryption)()
+	return e.Encryption.Decrypt(ciphertext)
+}
+
+func (e timedEncryption) EncryptFile(src, dst string) error {
+	defer e.timings.Start(PhaseEncryption)()
+	return e.Encryption.EncryptFile(src, dst)
+}
+
+func (e timedEncryption) DecryptFile(src, dst string) error {
+	defer e.timings.Start(PhaseEncryption)()
+	return e.Encryption.DecryptFile(src, dst)
+}
+
+func (e timedEncryption) EncryptReader(plaintext io.Reader, ciphertext io.Writer) error {
+	defer e.timings.Start(PhaseEncryption)()
+	return e.Encryption.EncryptReader(plaintext, ciphertext)
+}
+
+func (e timedEncryption) DecryptReader(ciphertext io.Reader, plaintext io.Writer) error {
+	defer e.timings.Start(PhaseEncryption)()
+	return e.Encryption.DecryptReader(ciphertext, plaintext)
+}
@@ -0,0 +1,164 @@
+package sync
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// RepoManifest hashes the files of the sync repo working tree that are
+// applied to this machine, keyed by repo path
+func (s *Syncer) RepoManifest() (map[string]string, error) {
+	relPaths, err := s.repoFiles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list repo files: %w", err)
+	}
+
+	manifest := make(map[string]string, len(relPaths))
+	for _, relPath := range relPaths {
+		if s.localPath(relPath) == "" {
+			continue
+		}
+
+		hash, err := s.hashFile(filepath.Join(s.paths.SyncRepoDir(), relPath))
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash %s: %w", relPath, err)
+		}
+		manifest[relPath] = hash
+	}
+
+	return manifest, nil
+}
+
+// HeadManifest is RepoManifest for the content committed at HEAD. It is
+// the baseline when no manifest was recorded yet, so uncommitted edits in
+// the sync repo count as changes.
+func (s *Syncer) HeadManifest() (map[string]string, error) {
+	relPaths, err := s.repoFiles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list repo files: %w", err)
+	}
+
+	manifest := make(map[string]string, len(relPaths))
+	for _, relPath := range relPaths {
+		if s.localPath(relPath) == "" {
+			continue
+		}
+
+		data, err := s.repo.ReadFileAt("HEAD", filepath.ToSlash(relPath))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s at HEAD: %w", relPath, err)
+		}
+		manifest[relPath] = fmt.Sprintf("%x", sha256.Sum256(data))
+	}
+
+	return manifest, nil
+}
+
+// RepoEdits returns the repo paths added or changed in current since
+// manifest was recorded. Deletions are not reported, as push and pull do
+// not delete files either.
+func RepoEdits(manifest, current map[string]string) []string {
+	var edits []string
+	for relPath, hash := range current {
+		if manifest[relPath] != hash {
+			edits = append(edits, relPath)
+		}
+	}
+
+	sort.Strings(edits)
+	return edits
+}
+
+// LocalEdits returns the repo paths of local files added or changed since
+// manifest was recorded
+func (s *Syncer) LocalEdits(manifest map[string]string) ([]string, error) {
+	files, err := s.getSyncableFiles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get syncable files: %w", err)
+	}
+
+	var edits []string
+	for _, file := range files {
+		if s.isHostSecret(file.RelPath) {
+			// Stored encrypted under hosts/, so compare with the repo
+			if !s.hostSecretCurrent(file) {
+				edits = append(edits, file.RelPath)
+			}
+			continue
+		}
+		if s.hasRecipients(file.RelPath) {
+			if !s.recipientsSecretCurrent(file) {
+				edits = append(edits, file.RelPath)
+			}
+			continue
+		}
+
+		if s.repoFormHash(file) != manifest[file.RelPath] {
+			edits = append(edits, file.RelPath)
+		}
+	}
+
+	sort.Strings(edits)
+	return edits, nil
+}
+
+// WatchPaths returns the directories to watch for changes in either
+// direction, and a function that reports which paths below them can be
+// skipped because they are not synced
+func (s *Syncer) WatchPaths() ([]string, func(path string) bool) {
+	repoDir := s.paths.SyncRepoDir()
+	sources := s.syncSources()
+
+	roots := []string{repoDir}
+	seen := map[string]bool{repoDir: true}
+	for _, source := range sources {
+		root := source.LocalPath
+		if info, err := os.Stat(root); err != nil || !info.IsDir() {
+			root = filepath.Dir(root)
+		}
+		if !seen[root] {
+			seen[root] = true
+			roots = append(roots, root)
+		}
+	}
+
+	within := func(path, dir string) (string, bool) {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return "", false
+		}
+		return rel, true
+	}
+
+	skip := func(path string) bool {
+		if filepath.Base(path) == ".git" {
+			return true
+		}
+
+		if rel, ok := within(path, repoDir); ok {
+			return rel != "." && s.shouldExclude(rel)
+		}
+
+		for _, source := range sources {
+			if rel, ok := within(path, source.LocalPath); ok {
+				return s.shouldExclude(filepath.Join(source.RelPath, rel))
+			}
+			// Directories leading to a synced path
+			if _, ok := within(source.LocalPath, path); ok {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	return roots, skip
+}
@@ -0,0 +1,143 @@
+package sync
+
+import (
+	"bytes"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/GareArc/opencode-sync/internal/capability"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+)
+
+func init() {
+	capability.Register(capability.Capability{
+		Name:        "union-merge",
+		Kind:        capability.KindFeature,
+		Description: "Merge additions to AGENTS.md and other growing markdown files without conflicts",
+	})
+}
+
+// UnionMergeDriverName is the git merge driver used for markdown files
+// that mostly grow, such as AGENTS.md
+const UnionMergeDriverName = "opencode-union"
+
+// unionMergeDefaults are merged with the union driver whatever
+// sync.unionMerge says. Without a slash they match at any depth, so
+// project AGENTS.md files are included.
+var unionMergeDefaults = []string{"AGENTS.md"}
+
+// unionMergePatterns returns the gitattributes patterns of the files
+// merged with the union driver
+func (s *Syncer) unionMergePatterns() []string {
+	patterns := slices.Clone(unionMergeDefaults)
+	for _, pattern := range s.cfg.Sync.UnionMerge {
+		if !slices.Contains(patterns, pattern) {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
+
+// unionMerged reports whether a repo path is merged with the union driver
+func (s *Syncer) unionMerged(relPath string) bool {
+	parts := strings.Split(filepath.ToSlash(relPath), "/")
+	for _, pattern := range s.unionMergePatterns() {
+		if gitignore.ParsePattern(pattern, nil).Match(parts, false) == gitignore.Exclude {
+			return true
+		}
+	}
+	return false
+}
+
+// MergeAdditions settles the conflicts in a diff3-style merge result
+// whose markers are markerSize characters long, where both sides only
+// added lines at the same place: our lines come first, then theirs, and
+// an addition both sides made is kept once. Conflicts where a side
+// changed or removed lines of the common ancestor keep their markers, in
+// git's usual seven-character two-way style. It returns false if any are
+// left.
+func MergeAdditions(merged []byte, markerSize int) ([]byte, bool) {
+	const (
+		outside = iota
+		inOurs
+		inBase
+		inTheirs
+	)
+
+	marker := func(line []byte, c byte) bool {
+		return len(line) >= markerSize &&
+			bytes.Count(line[:markerSize], []byte{c}) == markerSize &&
+			(len(line) == markerSize || line[markerSize] == ' ' || line[markerSize] == '\n' || line[markerSize] == '\r')
+	}
+
+	var out bytes.Buffer
+	var ours, base, theirs []byte
+	var oursLabel, theirsLabel []byte
+	clean := true
+	state := outside
+
+	for _, line := range bytes.SplitAfter(merged, []byte("\n")) {
+		switch {
+		case state == outside && marker(line, '<'):
+			state = inOurs
+			ours, base, theirs = nil, nil, nil
+			oursLabel = line[markerSize:]
+		case state == inOurs && marker(line, '|'):
+			state = inBase
+		case (state == inOurs || state == inBase) && marker(line, '='):
+			state = inTheirs
+		case state == inTheirs && marker(line, '>'):
+			state = outside
+			theirsLabel = line[markerSize:]
+
+			if resolved, ok := joinAdditions(ours, base, theirs); ok {
+				out.Write(resolved)
+				continue
+			}
+			clean = false
+			out.WriteString("<<<<<<<")
+			out.Write(oursLabel)
+			out.Write(withNewline(ours))
+			out.WriteString("=======\n")
+			out.Write(withNewline(theirs))
+			out.WriteString(">>>>>>>")
+			out.Write(theirsLabel)
+		case state == inOurs:
+			ours = append(ours, line...)
+		case state == inBase:
+			base = append(base, line...)
+		case state == inTheirs:
+			theirs = append(theirs, line...)
+		default:
+			out.Write(line)
+		}
+	}
+
+	return out.Bytes(), clean
+}
+
+// joinAdditions resolves one conflict if neither side touched the lines
+// of the common ancestor, which is the case when base is empty
+func joinAdditions(ours, base, theirs []byte) ([]byte, bool) {
+	if len(base) > 0 {
+		return nil, false
+	}
+
+	switch {
+	case bytes.HasPrefix(withNewline(theirs), withNewline(ours)):
+		// Also covers both sides adding the same lines
+		return theirs, true
+	case bytes.HasPrefix(withNewline(ours), withNewline(theirs)):
+		return ours, true
+	}
+	return append(withNewline(ours), theirs...), true
+}
+
+// withNewline returns text ending in a newline, unless it is empty
+func withNewline(text []byte) []byte {
+	if len(text) == 0 || bytes.HasSuffix(text, []byte("\n")) {
+		return text
+	}
+	return append(slices.Clip(text), '\n')
+}
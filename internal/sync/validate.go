@@ -0,0 +1,215 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/GareArc/opencode-sync/internal/jsonc"
+)
+
+// validatedFiles are the config files OpenCode refuses to start with if
+// they are broken
+var validatedFiles = []string{"opencode.json", "opencode.jsonc", "oh-my-opencode.json"}
+
+// configSchema gives the JSON types OpenCode accepts for the top-level
+// keys of opencode.json. Keys it doesn't list are not checked, so settings
+// added by newer OpenCode versions pass.
+var configSchema = map[string][]string{
+	"$schema":            {"string"},
+	"theme":              {"string"},
+	"model":              {"string"},
+	"small_model":        {"string"},
+	"default_agent":      {"string"},
+	"username":           {"string"},
+	"share":              {"string"},
+	"autoupdate":         {"boolean", "string"},
+	"snapshot":           {"boolean"},
+	"plugin":             {"array"},
+	"instructions":       {"array"},
+	"disabled_providers": {"array"},
+	"enabled_providers":  {"array"},
+	"agent":              {"object"},
+	"mode":               {"object"},
+	"command":            {"object"},
+	"provider":           {"object"},
+	"mcp":                {"object"},
+	"permission":         {"object", "string"},
+	"tools":              {"object"},
+	"keybinds":           {"object"},
+	"tui":                {"object"},
+	"experimental":       {"object"},
+	"formatter":          {"object", "boolean"},
+	"lsp":                {"object", "boolean"},
+}
+
+// namedEntries are the keys of opencode.json whose entries are objects
+// keyed by name
+var namedEntries = []string{"agent", "mode", "command", "provider", "mcp"}
+
+// ConfigProblem is a config file OpenCode would fail to load
+type ConfigProblem struct {
+	Path   string
+	Reason string
+}
+
+// InvalidConfigError reports config files a pull left broken
+type InvalidConfigError struct {
+	Problems []ConfigProblem
+}
+
+func (e *InvalidConfigError) Error() string {
+	lines := make([]string, 0, len(e.Problems))
+	for _, problem := range e.Problems {
+		lines = append(lines, fmt.Sprintf("%s (%s)", problem.Path, problem.Reason))
+	}
+	return fmt.Sprintf("the pulled config is invalid: %s", strings.Join(lines, ", "))
+}
+
+// ValidateApplied checks that the OpenCode config files applied by
+// CopyFromRepo parse and match the bundled schema. Files that were broken
+// before the pull, according to backup, are not the pull's doing and are
+// not reported.
+func (s *Syncer) ValidateApplied(backup *Backup) error {
+	var problems []ConfigProblem
+
+	for _, relPath := range validatedFiles {
+		localPath := s.localPath(relPath)
+		if localPath == "" {
+			continue
+		}
+		data, err := s.readFile(localPath)
+		if err != nil {
+			continue
+		}
+
+		reason := validateConfig(relPath, data)
+		if reason == "" {
+			continue
+		}
+
+		if backup != nil && slices.Contains(backup.Files, relPath) {
+			before, err := s.readFile(filepath.Join(backup.Dir, "files", relPath))
+			if err == nil && validateConfig(relPath, before) != "" {
+				continue
+			}
+		}
+
+		problems = append(problems, ConfigProblem{Path: relPath, Reason: reason})
+	}
+
+	if len(problems) > 0 {
+		return &InvalidConfigError{Problems: problems}
+	}
+	return nil
+}
+
+// ApplyPulled applies the sync repo to the local config after a pull,
+// with backup holding the local files as they were. An interrupted copy
+// is rolled back, and so is a pull that leaves the config broken (see
+// ValidateApplied), so OpenCode still starts here. restored reports
+// whether the local config was put back from backup.
+//
+// In the direct layout the working tree is the repo, so restoring would
+// undo the pull; a broken config is applied anyway and reported by
+// InvalidApplied instead.
+func (s *Syncer) ApplyPulled(ctx context.Context, backup *Backup) (restored bool, err error) {
+	s.invalidApplied = nil
+
+	if err := s.CopyFromRepo(ctx); err != nil {
+		// The repo is always reapplied in full, so a pull that was cut
+		// short is resumed by running it again
+		if ctx.Err() != nil {
+			restored = s.RestoreBackup(backup) == nil
+		}
+		return restored, fmt.Errorf("failed to copy files: %w", err)
+	}
+
+	if err := s.ValidateApplied(backup); err != nil {
+		if s.Direct() {
+			s.invalidApplied = err
+			return false, nil
+		}
+		if restoreErr := s.RestoreBackup(backup); restoreErr != nil {
+			return false, fmt.Errorf("%w, and restoring the local config failed: %v", err, restoreErr)
+		}
+		return true, fmt.Errorf("%w; the local config was left as it was", err)
+	}
+	return false, nil
+}
+
+// InvalidApplied returns the problems the last ApplyPulled found and
+// applied anyway, in the direct layout, or nil
+func (s *Syncer) InvalidApplied() error {
+	return s.invalidApplied
+}
+
+// validateConfig returns why OpenCode would fail to load a config file,
+// or "" if it looks fine
+func validateConfig(relPath string, data []byte) string {
+	value, err := jsonc.Unmarshal(data)
+	if err != nil {
+		return err.Error()
+	}
+
+	root, ok := value.(map[string]any)
+	if !ok {
+		return "not a JSON object"
+	}
+	if relPath == "oh-my-opencode.json" {
+		return ""
+	}
+
+	keys := make([]string, 0, len(root))
+	for key := range root {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		types, ok := configSchema[key]
+		if !ok {
+			continue
+		}
+		if !slices.Contains(types, jsonType(root[key])) {
+			return fmt.Sprintf("%s must be %s", key, strings.Join(types, " or "))
+		}
+
+		entries, ok := root[key].(map[string]any)
+		if !ok || !slices.Contains(namedEntries, key) {
+			continue
+		}
+		names := make([]string, 0, len(entries))
+		for name := range entries {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if jsonType(entries[name]) != "object" {
+				return fmt.Sprintf("%s.%s must be object", key, name)
+			}
+		}
+	}
+
+	return ""
+}
+
+// jsonType names the JSON type of a value from jsonc.Unmarshal
+func jsonType(v any) string {
+	switch v.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	}
+	return "number"
+}
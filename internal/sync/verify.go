@@ -0,0 +1,79 @@
+package sync
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/GareArc/opencode-sync/internal/crypto"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// VerificationError is returned when an encrypted artifact cannot be
+// decrypted back to its source with the current key
+type VerificationError struct {
+	Name string
+	Err  error
+}
+
+func (e *VerificationError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("encrypted %s failed round-trip verification: %v", e.Name, e.Err)
+	}
+	return fmt.Sprintf("encrypted %s failed round-trip verification: decrypted content does not match the source", e.Name)
+}
+
+func (e *VerificationError) Unwrap() error {
+	return e.Err
+}
+
+// encryptVerified encrypts plaintext and checks that the ciphertext
+// decrypts back to the same content with the current key
+func (s *Syncer) encryptVerified(name string, plaintext []byte) ([]byte, error) {
+	return encryptVerifiedWith(s.encryption, name, plaintext)
+}
+
+// encryptVerifiedWith is encryptVerified with an explicit key
+func encryptVerifiedWith(enc crypto.Encryption, name string, plaintext []byte) ([]byte, error) {
+	ciphertext, err := enc.Encrypt(plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt %s: %w", name, err)
+	}
+
+	decrypted, err := enc.Decrypt(ciphertext)
+	if err != nil {
+		return nil, &VerificationError{Name: name, Err: err}
+	}
+
+	if sha256.Sum256(decrypted) != sha256.Sum256(plaintext) {
+		return nil, &VerificationError{Name: name}
+	}
+
+	return ciphertext, nil
+}
+
+// encryptFileVerified encrypts src into dst, verifying the result. Like
+// writeEncrypted it leaves a current dst untouched.
+func (s *Syncer) encryptFileVerified(name, src, dst string) error {
+	return s.encryptFileVerifiedWith(s.encryption, name, src, dst)
+}
+
+// writeEncrypted encrypts plaintext into dst. Age ciphertext differs on
+// every run, so an existing dst that already decrypts to plaintext is left
+// untouched; otherwise every push would rewrite it and concurrent pushes
+// from two machines could never be rebased cleanly.
+func (s *Syncer) writeEncrypted(name, dst string, plaintext []byte) error {
+	if s.encryptedCurrent(s.encryption, dst, fmt.Sprintf("%x", sha256.Sum256(plaintext))) {
+		return nil
+	}
+
+	ciphertext, err := s.encryptVerified(name, plaintext)
+	if err != nil {
+		return err
+	}
+
+	if err := util.WriteFile(s.fs, dst, ciphertext, 0600); err != nil {
+		return fmt.Errorf("failed to write encrypted %s: %w", name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,343 @@
+package ui
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/GareArc/opencode-sync/internal/config"
+	"github.com/charmbracelet/huh"
+)
+
+// Sync flags offered in the settings form, by label
+var settingsFlags = []struct {
+	label string
+	value func(c *config.Config) *bool
+}{
+	{"Sync auth.json (encrypted)", func(c *config.Config) *bool { return &c.Sync.IncludeAuth }},
+	{"Sync mcp-auth.json (encrypted)", func(c *config.Config) *bool { return &c.Sync.IncludeMcpAuth }},
+	{"Sync session history (encrypted)", func(c *config.Config) *bool { return &c.Sync.IncludeSessions }},
+	{"Encrypt MCP secrets in opencode.json", func(c *config.Config) *bool { return &c.Sync.SplitMcpSecrets }},
+	{"Sort and reformat JSON files", func(c *config.Config) *bool { return &c.Sync.CanonicalJSON }},
+	{"Sync binary files", func(c *config.Config) *bool { return &c.Sync.IncludeBinaries }},
+	{"Preserve modification times", func(c *config.Config) *bool { return &c.Sync.PreserveMtimes }},
+	{"Preserve the executable bit", func(c *config.Config) *bool { return &c.Sync.PreserveExecutable }},
+	{"Sync on metered connections", func(c *config.Config) *bool { return &c.Sync.Network.AllowMetered }},
+	{"Let watch apply risky pulled changes", func(c *config.Config) *bool { return &c.Watch.SkipReview }},
+}
+
+// SettingsForm lets the user edit cfg, one page per section, and writes
+// the changes to cfg only if they confirm at the end. It reports whether
+// they did. Number fields are checked as they are typed; the config as a
+// whole is left to the caller to validate.
+func SettingsForm(cfg *config.Config) (bool, error) {
+	var (
+		repoURL       = cfg.Repo.URL
+		branch        = cfg.Repo.Branch
+		timeout       = intText(cfg.Repo.TimeoutSeconds)
+		authorName    = cfg.Repo.Author.Name
+		authorEmail   = cfg.Repo.Author.Email
+		encryption    = cfg.Encryption.Enabled
+		keyFile       = cfg.Encryption.KeyFile
+		unlockMinutes = intText(cfg.Encryption.UnlockMinutes)
+		flags         []string
+		whenRunning   = cfg.Sync.WhenRunning
+		copyMode      = cfg.Sync.CopyMode
+		historyMode   = cfg.Sync.HistoryMode
+		authHistory   = cfg.Sync.AuthHistory
+		startupPull   = intText(cfg.Sync.StartupPullMinutes)
+		exclude       = strings.Join(cfg.Sync.Exclude, "\n")
+		binaryAllow   = strings.Join(cfg.Sync.BinaryAllow, "\n")
+		hostSecrets   = strings.Join(cfg.Sync.HostSecrets, "\n")
+		unionMerge    = strings.Join(cfg.Sync.UnionMerge, "\n")
+		mergeKeys     = strings.Join(cfg.Sync.MergeKeys, "\n")
+		onlyDirs      = strings.Join(cfg.Sync.OnlyDirs, "\n")
+		claudeSync    = !cfg.Claude.Disabled
+		claudePaths   = slices.Clone(cfg.Claude.Paths)
+		debounce      = intText(cfg.Watch.DebounceSeconds)
+		minPush       = intText(cfg.Watch.MinPushMinutes)
+		quietHours    = cfg.Watch.QuietHours
+		backupAuto    = cfg.Backup.Auto
+		backupKeep    = intText(cfg.Backup.Keep)
+		backupDays    = intText(cfg.Backup.KeepDays)
+		save          bool
+	)
+
+	if len(claudePaths) == 0 {
+		claudePaths = slices.Clone(config.DefaultClaudePaths)
+	}
+
+	// Options in the value are shown selected
+	var flagOptions []huh.Option[string]
+	for _, flag := range settingsFlags {
+		flagOptions = append(flagOptions, huh.NewOption(flag.label, flag.label))
+		if *flag.value(cfg) {
+			flags = append(flags, flag.label)
+		}
+	}
+
+	layout := cfg.Repo.Layout
+	if layout == "" {
+		layout = config.LayoutCopy
+	}
+
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewInput().
+				Title("Repository URL").
+				Description("Changing it on a synced machine works like 'opencode-sync rebind'").
+				Value(&repoURL).
+				Validate(required("repository URL")),
+			huh.NewInput().
+				Title("Branch").
+				Description("Changing it on a synced machine works like 'opencode-sync channel switch'").
+				Value(&branch).
+				Validate(required("branch")),
+			huh.NewInput().
+				Title("Network timeout (seconds)").
+				Description(fmt.Sprintf("Empty for the default, %d", config.DefaultTimeoutSeconds)).
+				Value(&timeout).
+				Validate(number),
+			huh.NewInput().
+				Title("Commit author name").
+				Description("Empty for git's user.name").
+				Value(&authorName),
+			huh.NewInput().
+				Title("Commit author email").
+				Description("Empty for git's user.email").
+				Value(&authorEmail),
+			huh.NewNote().
+				Title("Layout: "+layout).
+				Description("Convert it with 'opencode-sync layout'"),
+		).Title("Repository"),
+
+		huh.NewGroup(
+			huh.NewConfirm().
+				Title("Encrypt secrets").
+				Value(&encryption),
+			huh.NewInput().
+				Title("Key file").
+				Description("Empty for age.key in the config directory").
+				Value(&keyFile),
+			huh.NewInput().
+				Title("Minutes a passphrase-protected key stays unlocked").
+				Description("Empty to keep it until the command exits").
+				Value(&unlockMinutes).
+				Validate(number),
+		).Title("Encryption"),
+
+		huh.NewGroup(
+			huh.NewMultiSelect[string]().
+				Title("Options").
+				Options(flagOptions...).
+				Value(&flags),
+			huh.NewSelect[string]().
+				Title("When OpenCode is running during a pull").
+				Options(
+					huh.NewOption("Warn (default)", ""),
+					huh.NewOption("Wait for it to exit, up to 10 minutes", config.WhenRunningWait),
+					huh.NewOption("Apply anyway", config.WhenRunningForce),
+				).
+				Value(&whenRunning),
+			huh.NewSelect[string]().
+				Title("Copy mode").
+				Options(
+					huh.NewOption("Copy-on-write where the filesystem supports it (default)", ""),
+					huh.NewOption("Always copy", config.CopyModeCopy),
+				).
+				Value(&copyMode),
+			huh.NewSelect[string]().
+				Title("History").
+				Options(
+					huh.NewOption("One commit per sync (default)", ""),
+					huh.NewOption("Fold syncs into this machine's commit of the day", config.HistoryModeSquash),
+				).
+				Value(&historyMode),
+			huh.NewSelect[string]().
+				Title("Auth history").
+				Options(
+					huh.NewOption("Keep every version on the sync branch (default)", ""),
+					huh.NewOption("Keep only the latest, on its own branch", config.AuthHistoryLatest),
+				).
+				Value(&authHistory),
+			huh.NewInput().
+				Title("Minutes between pulls when OpenCode starts").
+				Description(fmt.Sprintf("Empty for the default, %d", config.DefaultStartupPullMinutes)).
+				Value(&startupPull).
+				Validate(number),
+		).Title("Sync"),
+
+		huh.NewGroup(
+			huh.NewText().
+				Title("Excluded patterns").
+				Description("One per line").
+				Value(&exclude),
+			huh.NewText().
+				Title("Binary files to sync anyway").
+				Description("One pattern per line").
+				Value(&binaryAllow),
+			huh.NewText().
+				Title("Files private to this machine").
+				Description("One pattern per line, encrypted to this machine's own key").
+				Value(&hostSecrets),
+			huh.NewText().
+				Title("Files merged like AGENTS.md").
+				Description("One gitattributes pattern per line").
+				Value(&unionMerge),
+			huh.NewText().
+				Title("Fields identifying JSON array items").
+				Description(fmt.Sprintf("One field per line, for merging config files; empty for %s", strings.Join(config.DefaultMergeKeys, ", "))).
+				Value(&mergeKeys),
+			huh.NewText().
+				Title("Only sync these directories").
+				Description("One repo directory per line; empty syncs everything").
+				Value(&onlyDirs),
+		).Title("Paths"),
+
+		huh.NewGroup(
+			huh.NewConfirm().
+				Title("Sync Claude Code files").
+				Affirmative("Yes").
+				Negative("No").
+				Value(&claudeSync),
+			huh.NewMultiSelect[string]().
+				Title("Claude Code paths").
+				Options(huh.NewOptions(config.ClaudePaths...)...).
+				Value(&claudePaths),
+		).Title("Claude Code"),
+
+		huh.NewGroup(
+			huh.NewInput().
+				Title("Seconds to wait after a change (watch --two-way)").
+				Description(fmt.Sprintf("Empty for the default, %d", config.DefaultDebounceSeconds)).
+				Value(&debounce).
+				Validate(number),
+			huh.NewInput().
+				Title("Minimum minutes between pushes").
+				Description("Empty for no minimum").
+				Value(&minPush).
+				Validate(number),
+			huh.NewInput().
+				Title("Quiet hours").
+				Description("A local time range such as 00:00-07:00 without syncs; empty for none").
+				Value(&quietHours),
+			huh.NewSelect[string]().
+				Title("Local snapshots").
+				Options(
+					huh.NewOption("Off (default)", ""),
+					huh.NewOption("Hourly", config.BackupAutoHourly),
+					huh.NewOption("Daily", config.BackupAutoDaily),
+					huh.NewOption("Weekly", config.BackupAutoWeekly),
+				).
+				Value(&backupAuto),
+			huh.NewInput().
+				Title("Snapshots to keep").
+				Description(fmt.Sprintf("Empty for the default, %d", config.DefaultBackupKeep)).
+				Value(&backupKeep).
+				Validate(number),
+			huh.NewInput().
+				Title("Days to keep snapshots").
+				Description("Empty for no age limit").
+				Value(&backupDays).
+				Validate(number),
+		).Title("Watch and snapshots"),
+
+		huh.NewGroup(
+			huh.NewConfirm().
+				Title("Save these settings?").
+				Affirmative("Save").
+				Negative("Discard").
+				Value(&save),
+		),
+	)
+
+	if err := form.Run(); err != nil {
+		return false, err
+	}
+	if !save {
+		return false, nil
+	}
+
+	cfg.Repo.URL = strings.TrimSpace(repoURL)
+	cfg.Repo.Branch = strings.TrimSpace(branch)
+	cfg.Repo.TimeoutSeconds = textInt(timeout)
+	cfg.Repo.Author.Name = strings.TrimSpace(authorName)
+	cfg.Repo.Author.Email = strings.TrimSpace(authorEmail)
+	cfg.Encryption.Enabled = encryption
+	cfg.Encryption.KeyFile = strings.TrimSpace(keyFile)
+	cfg.Encryption.UnlockMinutes = textInt(unlockMinutes)
+	for _, flag := range settingsFlags {
+		*flag.value(cfg) = slices.Contains(flags, flag.label)
+	}
+	cfg.Sync.WhenRunning = whenRunning
+	cfg.Sync.CopyMode = copyMode
+	cfg.Sync.HistoryMode = historyMode
+	cfg.Sync.AuthHistory = authHistory
+	cfg.Sync.StartupPullMinutes = textInt(startupPull)
+	cfg.Sync.Exclude = textList(exclude)
+	cfg.Sync.BinaryAllow = textList(binaryAllow)
+	cfg.Sync.HostSecrets = textList(hostSecrets)
+	cfg.Sync.UnionMerge = textList(unionMerge)
+	cfg.Sync.MergeKeys = textList(mergeKeys)
+	cfg.Sync.OnlyDirs = textList(onlyDirs)
+	cfg.Claude.Disabled = !claudeSync || len(claudePaths) == 0
+	if !slices.Equal(claudePaths, config.DefaultClaudePaths) || len(cfg.Claude.Paths) > 0 {
+		cfg.Claude.Paths = claudePaths
+	}
+	cfg.Watch.DebounceSeconds = textInt(debounce)
+	cfg.Watch.MinPushMinutes = textInt(minPush)
+	cfg.Watch.QuietHours = strings.TrimSpace(quietHours)
+	cfg.Backup.Auto = backupAuto
+	cfg.Backup.Keep = textInt(backupKeep)
+	cfg.Backup.KeepDays = textInt(backupDays)
+
+	return true, nil
+}
+
+// required returns a validator rejecting empty values
+func required(name string) func(string) error {
+	return func(value string) error {
+		if strings.TrimSpace(value) == "" {
+			return fmt.Errorf("%s is required", name)
+		}
+		return nil
+	}
+}
+
+// number accepts empty values, which mean the default, and whole numbers
+// from zero up
+func number(value string) error {
+	if value = strings.TrimSpace(value); value == "" {
+		return nil
+	}
+	if n, err := strconv.Atoi(value); err != nil || n < 0 {
+		return fmt.Errorf("enter a whole number, or nothing for the default")
+	}
+	return nil
+}
+
+// intText shows zero, meaning the default, as an empty field
+func intText(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+// textInt reads a field checked with number
+func textInt(value string) int {
+	n, _ := strconv.Atoi(strings.TrimSpace(value))
+	return n
+}
+
+// textList reads one element per line, dropping empty lines
+func textList(value string) []string {
+	var list []string
+	for _, line := range strings.Split(value, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			list = append(list, line)
+		}
+	}
+	return list
+}
@@ -1,7 +1,9 @@
 package ui
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"time"
 
 	"github.com/GareArc/opencode-sync/internal/config"
@@ -18,24 +20,41 @@ var (
 	warnStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("11"))
 )
 
+// logSink also receives every message, without styling, when set
+var logSink io.Writer
+
+// SetLog makes every message also be written to w, one per line, e.g. for
+// 'ctl logs'. Passing nil stops it. w must be safe for concurrent use.
+func SetLog(w io.Writer) {
+	logSink = w
+}
+
+// message prints msg in style and copies it to the log sink
+func message(style lipgloss.Style, msg string) {
+	fmt.Println(style.Render(msg))
+	if logSink != nil {
+		fmt.Fprintln(logSink, msg)
+	}
+}
+
 // Success prints a success message
 func Success(msg string) {
-	fmt.Println(successStyle.Render("✓ " + msg))
+	message(successStyle, "✓ "+msg)
 }
 
 // Error prints an error message
 func Error(msg string) {
-	fmt.Println(errorStyle.Render("✗ " + msg))
+	message(errorStyle, "✗ "+msg)
 }
 
 // Info prints an info message
 func Info(msg string) {
-	fmt.Println(infoStyle.Render("→ " + msg))
+	message(infoStyle, "→ "+msg)
 }
 
 // Warn prints a warning message
 func Warn(msg string) {
-	fmt.Println(warnStyle.Render("⚠ " + msg))
+	message(warnStyle, "⚠ "+msg)
 }
 
 // MainMenu shows the main interactive menu
@@ -52,6 +71,7 @@ func MainMenu() (string, error) {
 					huh.NewOption("Push local changes", "push"),
 					huh.NewOption("View status", "status"),
 					huh.NewOption("View diff", "diff"),
+					huh.NewOption("Undo last sync", "undo"),
 					huh.NewOption("─────────────────────", ""),
 					huh.NewOption("Settings", "config"),
 					huh.NewOption("Manage encryption key", "key"),
@@ -181,6 +201,24 @@ func Confirm(title string, description string) (bool, error) {
 	return result, err
 }
 
+// Select prompts for one of the given options and returns its value
+func Select(title string, description string, options []huh.Option[string]) (string, error) {
+	var result string
+
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewSelect[string]().
+				Title(title).
+				Description(description).
+				Options(options...).
+				Value(&result),
+		),
+	)
+
+	err := form.Run()
+	return result, err
+}
+
 // Input prompts for text input
 func Input(title string, placeholder string) (string, error) {
 	var result string
@@ -198,21 +236,43 @@ func Input(title string, placeholder string) (string, error) {
 	return result, err
 }
 
+// Password prompts for hidden text input
+func Password(title string, description string) (string, error) {
+	var result string
+
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewInput().
+				Title(title).
+				Description(description).
+				EchoMode(huh.EchoModePassword).
+				Value(&result),
+		),
+	)
+
+	err := form.Run()
+	return result, err
+}
+
 // Spinner runs a function with a spinner animation
 func Spinner(message string, fn func() error) error {
-	var err error
+	// The spinner stops when fn returns. fn always runs to completion,
+	// even if the spinner itself is interrupted, so callers never see a
+	// result while fn is still running.
+	done, finish := context.WithCancel(context.Background())
 
-	action := func() {
+	var err error
+	go func() {
+		defer finish()
 		err = fn()
-	}
+	}()
 
-	if err := spinner.New().
+	_ = spinner.New().
 		Title(message).
-		Action(action).
-		Run(); err != nil {
-		return err
-	}
+		Context(done).
+		Run()
 
+	<-done.Done()
 	return err
 }
 
@@ -0,0 +1,162 @@
+// Package usage keeps opt-in, local-only counts of how opencode-sync is
+// used: which commands run, how often syncs happen and how they fail.
+// Nothing is ever sent anywhere; the counts stay in the data dir for
+// 'stats --usage'.
+package usage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/GareArc/opencode-sync/internal/paths"
+)
+
+// RetentionDays is how many days of daily sync counts are kept
+const RetentionDays = 90
+
+// dayFormat keys the daily sync counts
+const dayFormat = "2006-01-02"
+
+// Usage holds the recorded counts
+type Usage struct {
+	// Since is when recording started
+	Since time.Time `json:"since"`
+
+	// Commands counts the runs of each command, such as "push" or
+	// "config set", and of the syncs watch runs ("watch sync")
+	Commands map[string]*Counts `json:"commands,omitempty"`
+
+	// Days counts the syncs of each day, keyed by local date
+	Days map[string]*Counts `json:"days,omitempty"`
+}
+
+// Counts is how often something ran and how it failed
+type Counts struct {
+	Runs     int `json:"runs"`
+	Failures int `json:"failures,omitempty"`
+
+	// Errors counts the failures by kind, such as "network" or "auth"
+	Errors map[string]int `json:"errors,omitempty"`
+
+	// LastFailure is when it last failed
+	LastFailure time.Time `json:"lastFailure,omitempty"`
+}
+
+// FailureRate returns the share of runs that failed, from 0 to 1
+func (c *Counts) FailureRate() float64 {
+	if c.Runs == 0 {
+		return 0
+	}
+	return float64(c.Failures) / float64(c.Runs)
+}
+
+// record counts a run that failed with an error of kind, or succeeded if
+// kind is empty
+func (c *Counts) record(kind string, now time.Time) {
+	c.Runs++
+	if kind == "" {
+		return
+	}
+
+	c.Failures++
+	c.LastFailure = now
+	if c.Errors == nil {
+		c.Errors = make(map[string]int)
+	}
+	c.Errors[kind]++
+}
+
+// Load reads the recorded counts. A missing file yields empty counts.
+func Load() (*Usage, error) {
+	p, err := paths.Get()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get paths: %w", err)
+	}
+
+	data, err := os.ReadFile(p.UsageFile())
+	if os.IsNotExist(err) {
+		return &Usage{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read usage: %w", err)
+	}
+
+	var u Usage
+	if err := json.Unmarshal(data, &u); err != nil {
+		return nil, fmt.Errorf("failed to parse usage: %w", err)
+	}
+
+	return &u, nil
+}
+
+// Record counts a run of command that failed with an error of kind, or
+// succeeded if kind is empty. Syncs are also counted for their day.
+func Record(command string, sync bool, kind string, now time.Time) error {
+	u, err := Load()
+	if err != nil {
+		return err
+	}
+
+	if u.Since.IsZero() {
+		u.Since = now
+	}
+	if u.Commands == nil {
+		u.Commands = make(map[string]*Counts)
+	}
+	if u.Commands[command] == nil {
+		u.Commands[command] = &Counts{}
+	}
+	u.Commands[command].record(kind, now)
+
+	if sync {
+		day := now.Format(dayFormat)
+		if u.Days == nil {
+			u.Days = make(map[string]*Counts)
+		}
+		if u.Days[day] == nil {
+			u.Days[day] = &Counts{}
+		}
+		u.Days[day].record(kind, now)
+	}
+
+	// Keep the file small on machines that sync all day
+	oldest := now.AddDate(0, 0, -RetentionDays).Format(dayFormat)
+	for day := range u.Days {
+		if day < oldest {
+			delete(u.Days, day)
+		}
+	}
+
+	return save(u)
+}
+
+// save writes the counts atomically
+func save(u *Usage) error {
+	p, err := paths.Get()
+	if err != nil {
+		return fmt.Errorf("failed to get paths: %w", err)
+	}
+
+	data, err := json.MarshalIndent(u, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal usage: %w", err)
+	}
+
+	path := p.UsageFile()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write usage: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write usage: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,389 @@
+package vcs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/GareArc/opencode-sync/internal/git"
+)
+
+// stateFile records, in the mirror, which commits and changesets are the
+// same and what the Backend held at the last exchange
+const stateFile = "opencode-sync-vcs.json"
+
+// syncedState is what stateFile holds
+type syncedState struct {
+	// Commits maps changesets to the mirror commits they were made from
+	// or converted to. A commit can have several changesets, such as one
+	// that starts a branch at it.
+	Commits map[string]string `json:"commits"`
+
+	// Changesets maps mirror commits to their first changeset
+	Changesets map[string]string `json:"changesets"`
+
+	// Branches are the Backend's branch heads at the last exchange
+	Branches map[string]string `json:"branches"`
+
+	// Refs are the mirror's branches when they matched Branches
+	Refs string `json:"refs"`
+}
+
+// record notes that commit and changeset id are the same
+func (s *syncedState) record(commit, id string) {
+	s.Commits[id] = commit
+	if _, ok := s.Changesets[commit]; !ok {
+		s.Changesets[commit] = id
+	}
+}
+
+// Transport is a git.Transport keeping the repository's history in a
+// Backend, one changeset for each commit. Branches keep their names.
+// History that was rewritten, such as by a squashed sync, becomes a new
+// changeset on top of the old ones, since other systems don't rewrite
+// what was pushed.
+type Transport struct {
+	backend Backend
+	mirror  *git.Mirror
+}
+
+// NewTransport creates a Transport between backend and the mirror at
+// mirrorDir
+func NewTransport(backend Backend, mirrorDir string) *Transport {
+	return &Transport{
+		backend: backend,
+		mirror:  &git.Mirror{Path: mirrorDir},
+	}
+}
+
+// Mirror returns the path of the local bare repository
+func (t *Transport) Mirror() string {
+	return t.mirror.Path
+}
+
+// Download converts the changesets the mirror doesn't have yet to
+// commits and moves its branches to the Backend's. An upload that did not
+// finish is sent again.
+func (t *Transport) Download(ctx context.Context) error {
+	if err := t.mirror.Init(); err != nil {
+		return err
+	}
+	synced := t.loadState()
+
+	if err := t.backend.Pull(ctx); err != nil {
+		return t.remoteError(err)
+	}
+	heads, err := t.backend.Branches(ctx)
+	if err != nil {
+		return t.remoteError(err)
+	}
+
+	if sameBranches(heads, synced.Branches) {
+		refs, err := t.mirror.Refs()
+		if err != nil {
+			return err
+		}
+		if refs != synced.Refs {
+			return t.Upload(ctx)
+		}
+		return nil
+	}
+
+	changesets, err := t.backend.Log(ctx)
+	if err != nil {
+		return t.remoteError(err)
+	}
+	for _, c := range changesets {
+		if _, ok := synced.Commits[c.ID]; ok {
+			continue
+		}
+		commit, err := t.importChangeset(ctx, c, synced)
+		if err != nil {
+			return err
+		}
+		synced.record(commit, c.ID)
+	}
+
+	branches := make(map[string]string, len(heads))
+	for name, id := range heads {
+		commit, ok := synced.Commits[id]
+		if !ok {
+			return fmt.Errorf("branch %s of %s is at changeset %s, which has no commit", name, t.backend.Remote(), id)
+		}
+		branches[name] = commit
+	}
+	if err := t.mirror.SetBranches(branches); err != nil {
+		return err
+	}
+
+	refs, err := t.mirror.Refs()
+	if err != nil {
+		return err
+	}
+	synced.Branches = heads
+	synced.Refs = refs
+	return t.saveState(synced)
+}
+
+// importChangeset records a changeset as a commit of the mirror. Its
+// parents must have commits already.
+func (t *Transport) importChangeset(ctx context.Context, c Changeset, synced *syncedState) (string, error) {
+	var parents []string
+	for _, id := range c.Parents {
+		commit, ok := synced.Commits[id]
+		if !ok {
+			return "", fmt.Errorf("changeset %s of %s has a parent without a commit", c.ID, t.backend.Remote())
+		}
+		parents = append(parents, commit)
+	}
+
+	dir, err := t.tempDir()
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+
+	files := filepath.Join(dir, "files")
+	if err := t.backend.Export(ctx, c.ID, files); err != nil {
+		return "", t.remoteError(err)
+	}
+
+	name, email := splitAuthor(c.Author)
+	return t.mirror.CommitFiles(files, git.MirrorCommit{
+		Parents: parents,
+		Author:  name,
+		Email:   email,
+		Time:    c.Time,
+		Message: c.Message,
+	})
+}
+
+// Upload converts the mirror's commits the Backend doesn't have yet to
+// changesets and pushes them, unless nothing changed since the last
+// exchange. It returns a git.RejectedError if another machine pushed in
+// the meantime.
+func (t *Transport) Upload(ctx context.Context) error {
+	refs, err := t.mirror.Refs()
+	if err != nil {
+		return err
+	}
+	synced := t.loadState()
+	if refs == "" || refs == synced.Refs {
+		return nil
+	}
+
+	branches, err := t.mirror.Branches()
+	if err != nil {
+		return err
+	}
+
+	// Everything reachable from the Backend's heads is there already
+	var known []string
+	for _, id := range synced.Branches {
+		if commit, ok := synced.Commits[id]; ok {
+			known = append(known, commit)
+		}
+	}
+
+	heads := make(map[string]string, len(synced.Branches))
+	for name, id := range synced.Branches {
+		heads[name] = id
+	}
+
+	names := make([]string, 0, len(branches))
+	for name := range branches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	committed := false
+	for _, name := range names {
+		tip := branches[name]
+		head := heads[name]
+		if head != "" && synced.Commits[head] == tip {
+			continue
+		}
+
+		commits, err := t.branchCommits(name, tip, head, known, synced)
+		if err != nil {
+			return err
+		}
+		for _, commit := range commits {
+			id, err := t.exportCommit(ctx, name, commit, head, synced)
+			if err != nil {
+				return err
+			}
+			synced.record(commit.Hash, id)
+			head = id
+			committed = true
+		}
+		heads[name] = head
+	}
+
+	if committed {
+		if err := t.backend.Push(ctx); err != nil {
+			if errors.Is(err, ErrRejected) {
+				return &git.RejectedError{Remote: t.backend.Remote(), Err: err}
+			}
+			return t.remoteError(err)
+		}
+	}
+
+	synced.Branches = heads
+	synced.Refs = refs
+	return t.saveState(synced)
+}
+
+// branchCommits returns the commits to convert for a branch of the
+// mirror at tip whose head in the Backend is head, or "" if it has none
+// yet. A branch starting at a commit that is in the Backend already, and
+// history that no longer leads to head, get one new changeset holding
+// tip.
+func (t *Transport) branchCommits(name, tip, head string, known []string, synced *syncedState) ([]git.MirrorCommit, error) {
+	_, exported := synced.Changesets[tip]
+	rewritten := head != "" && !t.mirror.IsAncestor(synced.Commits[head], tip)
+	if (head == "" && exported) || rewritten {
+		commit, err := t.mirror.Commit(tip)
+		if err != nil {
+			return nil, err
+		}
+		if rewritten {
+			commit.Parents = []string{synced.Commits[head]}
+		} else {
+			commit.Parents = []string{tip}
+			commit.Message = "Start branch " + name
+		}
+		return []git.MirrorCommit{*commit}, nil
+	}
+
+	commits, err := t.mirror.Commits(tip, known)
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []git.MirrorCommit
+	for _, commit := range commits {
+		// Converted for another branch earlier on
+		if _, ok := synced.Changesets[commit.Hash]; !ok {
+			missing = append(missing, commit)
+		}
+	}
+	return missing, nil
+}
+
+// exportCommit records a mirror commit as a changeset on branch, whose
+// head is head or "", and returns its ID
+func (t *Transport) exportCommit(ctx context.Context, branch string, commit git.MirrorCommit, head string, synced *syncedState) (string, error) {
+	var parents []string
+	for _, parent := range commit.Parents {
+		// The branch's own head first, as a commit can have several
+		// changesets
+		if head != "" && synced.Commits[head] == parent {
+			parents = append(parents, head)
+		} else if id, ok := synced.Changesets[parent]; ok {
+			parents = append(parents, id)
+		}
+	}
+	if len(parents) == 0 && head != "" {
+		parents = []string{head}
+	}
+
+	dir, err := t.tempDir()
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+
+	files := filepath.Join(dir, "files")
+	if err := t.mirror.Checkout(commit.Hash, files); err != nil {
+		return "", err
+	}
+
+	author := commit.Author
+	if commit.Email != "" {
+		author = fmt.Sprintf("%s <%s>", commit.Author, commit.Email)
+	}
+	id, err := t.backend.Commit(ctx, files, Changeset{
+		Parents: parents,
+		Branch:  branch,
+		Author:  author,
+		Time:    commit.Time,
+		Message: commit.Message,
+	})
+	if err != nil {
+		return "", t.remoteError(err)
+	}
+	return id, nil
+}
+
+// sameBranches reports whether two sets of branch heads are the same
+func sameBranches(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, id := range a {
+		if other, ok := b[name]; !ok || other != id {
+			return false
+		}
+	}
+	return true
+}
+
+// splitAuthor splits "Name <email>" into name and email
+func splitAuthor(author string) (string, string) {
+	name, rest, ok := strings.Cut(author, "<")
+	if !ok {
+		return strings.TrimSpace(author), ""
+	}
+	email, _, _ := strings.Cut(rest, ">")
+	return strings.TrimSpace(name), strings.TrimSpace(email)
+}
+
+// remoteError gives a failed Backend operation the remote's name, unless
+// the Backend classified it like git's own remote errors already
+func (t *Transport) remoteError(err error) error {
+	if errors.Is(err, git.ErrAuth) || errors.Is(err, git.ErrNetwork) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	return fmt.Errorf("failed to sync with %s: %w", t.backend.Remote(), err)
+}
+
+// tempDir creates a directory next to the mirror for the files of one
+// commit, so they never leave the data directory
+func (t *Transport) tempDir() (string, error) {
+	dir, err := os.MkdirTemp(filepath.Dir(t.mirror.Path), "vcs-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	return dir, nil
+}
+
+func (t *Transport) loadState() *syncedState {
+	synced := &syncedState{}
+	if data, err := os.ReadFile(filepath.Join(t.mirror.Path, stateFile)); err == nil {
+		_ = json.Unmarshal(data, synced)
+	}
+	if synced.Commits == nil {
+		synced.Commits = map[string]string{}
+	}
+	if synced.Changesets == nil {
+		synced.Changesets = map[string]string{}
+	}
+	return synced
+}
+
+func (t *Transport) saveState(synced *syncedState) error {
+	data, err := json.Marshal(synced)
+	if err != nil {
+		return fmt.Errorf("failed to marshal history state: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(t.mirror.Path, stateFile), data, 0600); err != nil {
+		return fmt.Errorf("failed to save history state: %w", err)
+	}
+	return nil
+}
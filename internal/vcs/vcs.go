@@ -0,0 +1,70 @@
+// Package vcs keeps the history of the sync repository in a version
+// control system other than git. The sync repo itself stays a git
+// repository; a Transport converts its commits to the changesets of a
+// Backend one by one, so the remote holds the same files and history in
+// a form that system's own tools can read.
+package vcs
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrRejected is returned by Backend.Push when another machine pushed to
+// one of the branches first
+var ErrRejected = errors.New("another machine pushed first")
+
+// Changeset is a commit of a Backend
+type Changeset struct {
+	// ID identifies the changeset in the Backend
+	ID string
+
+	// Parents are the IDs of the changesets it follows; none for the
+	// first one, two for a merge
+	Parents []string
+
+	// Branch is the branch the changeset is on. It has the name of the
+	// git branch it was made from.
+	Branch string
+
+	// Author is the author as "Name <email>"
+	Author string
+
+	Time    time.Time
+	Message string
+}
+
+// Backend is a repository of another version control system, reached
+// through a local clone of it
+type Backend interface {
+	// Remote returns the URL of the repository without secrets, for
+	// messages
+	Remote() string
+
+	// Pull brings the clone up to date with the remote, dropping
+	// changesets a failed Push left in it
+	Pull(ctx context.Context) error
+
+	// Branches returns the changeset at the head of each branch of the
+	// clone
+	Branches(ctx context.Context) (map[string]string, error)
+
+	// Log returns the changesets of the clone's branches, parents before
+	// their children
+	Log(ctx context.Context) ([]Changeset, error)
+
+	// Export writes the files of a changeset into dir, which does not
+	// exist yet
+	Export(ctx context.Context, id, dir string) error
+
+	// Commit records the files in dir as a changeset of the clone with
+	// the parents, branch, author, time and message of c, and returns its
+	// ID
+	Commit(ctx context.Context, dir string, c Changeset) (string, error)
+
+	// Push sends the changesets committed since Pull to the remote. It
+	// returns ErrRejected if another machine pushed to one of their
+	// branches first.
+	Push(ctx context.Context) error
+}
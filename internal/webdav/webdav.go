@@ -0,0 +1,306 @@
+// Package webdav stores snapshots in a folder on a WebDAV server, such as
+// Nextcloud, ownCloud or Fastmail file storage.
+package webdav
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/GareArc/opencode-sync/internal/capability"
+	"github.com/GareArc/opencode-sync/internal/snapshot"
+)
+
+func init() {
+	capability.Register(capability.Capability{
+		Name:        "webdav",
+		Kind:        capability.KindBackend,
+		Description: "Sync encrypted snapshots through a WebDAV folder (dav:// and davs:// URLs)",
+	})
+}
+
+// Client reads and writes the files of one WebDAV folder. It implements
+// snapshot.Store, using ETags so concurrent writers never overwrite each
+// other unnoticed.
+type Client struct {
+	folder   *url.URL
+	username string
+	password string
+	http     *http.Client
+}
+
+// New creates a Client for the folder at an http(s) URL, authenticating
+// with basic auth if username is set
+func New(folder *url.URL, username, password string) *Client {
+	u := *folder
+	u.User = nil
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	return &Client{folder: &u, username: username, password: password, http: http.DefaultClient}
+}
+
+// FolderURL maps a dav:// or davs:// URL to the http:// or https:// URL
+// of the folder and the username it names, if any
+func FolderURL(rawURL string) (*url.URL, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid WebDAV URL: %w", err)
+	}
+
+	switch u.Scheme {
+	case "davs":
+		u.Scheme = "https"
+	case "dav":
+		u.Scheme = "http"
+	default:
+		return nil, "", fmt.Errorf("invalid WebDAV URL %s: must start with davs:// or dav://", rawURL)
+	}
+	if u.Host == "" {
+		return nil, "", fmt.Errorf("invalid WebDAV URL %s: no host", rawURL)
+	}
+
+	username := u.User.Username()
+	u.User = nil
+	return u, username, nil
+}
+
+// fileURL returns the URL of a file in the folder
+func (c *Client) fileURL(name string) string {
+	return c.folder.JoinPath(name).String()
+}
+
+// do sends a request, authenticated if the client has a username
+func (c *Client) do(ctx context.Context, method, target string, body []byte, header http.Header) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	return c.http.Do(req)
+}
+
+// statusError describes an unexpected response, wrapping the snapshot
+// error that matches its status
+func statusError(method, name string, resp *http.Response) error {
+	var kind error
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		kind = snapshot.ErrNotFound
+	case http.StatusNotModified:
+		kind = snapshot.ErrNotModified
+	case http.StatusPreconditionFailed:
+		kind = snapshot.ErrPreconditionFailed
+	case http.StatusUnauthorized, http.StatusForbidden:
+		kind = snapshot.ErrUnauthorized
+	default:
+		return fmt.Errorf("WebDAV %s %s: %s", method, name, resp.Status)
+	}
+	return fmt.Errorf("WebDAV %s %s: %s: %w", method, name, resp.Status, kind)
+}
+
+// multistatus is the answer to PROPFIND. Elements are matched by local
+// name, as servers differ in namespace prefixes.
+type multistatus struct {
+	Responses []struct {
+		Href     string `xml:"href"`
+		Propstat []struct {
+			Status string `xml:"status"`
+			Prop   struct {
+				ETag         string `xml:"getetag"`
+				Length       string `xml:"getcontentlength"`
+				Modified     string `xml:"getlastmodified"`
+				ResourceType struct {
+					Collection *struct{} `xml:"collection"`
+				} `xml:"resourcetype"`
+			} `xml:"prop"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:">
+  <d:prop><d:getetag/><d:getcontentlength/><d:getlastmodified/><d:resourcetype/></d:prop>
+</d:propfind>`
+
+// List returns the files in the folder. A missing folder has none.
+func (c *Client) List(ctx context.Context) ([]snapshot.Object, error) {
+	header := http.Header{"Depth": {"1"}, "Content-Type": {"application/xml; charset=utf-8"}}
+	resp, err := c.do(ctx, "PROPFIND", c.folder.String(), []byte(propfindBody), header)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, statusError("PROPFIND", c.folder.Path, resp)
+	}
+
+	var answer multistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&answer); err != nil {
+		return nil, fmt.Errorf("failed to parse WebDAV listing: %w", err)
+	}
+
+	var objects []snapshot.Object
+	for _, response := range answer.Responses {
+		href, err := url.Parse(response.Href)
+		if err != nil {
+			continue
+		}
+		if strings.TrimSuffix(href.Path, "/") == strings.TrimSuffix(c.folder.Path, "/") {
+			continue
+		}
+
+		object := snapshot.Object{Name: path.Base(href.Path)}
+		collection := false
+		for _, propstat := range response.Propstat {
+			if !strings.Contains(propstat.Status, " 200 ") {
+				continue
+			}
+			prop := propstat.Prop
+			collection = collection || prop.ResourceType.Collection != nil
+			if prop.ETag != "" {
+				object.ETag = prop.ETag
+			}
+			if size, err := strconv.ParseInt(prop.Length, 10, 64); err == nil {
+				object.Size = size
+			}
+			if modified, err := http.ParseTime(prop.Modified); err == nil {
+				object.Modified = modified
+			}
+		}
+		if !collection {
+			objects = append(objects, object)
+		}
+	}
+
+	return objects, nil
+}
+
+// Get returns the content and ETag of a file. With ifNoneMatch set, it
+// returns snapshot.ErrNotModified if the file still has that ETag.
+func (c *Client) Get(ctx context.Context, name, ifNoneMatch string) ([]byte, string, error) {
+	header := http.Header{}
+	if ifNoneMatch != "" {
+		header.Set("If-None-Match", ifNoneMatch)
+	}
+
+	resp, err := c.do(ctx, http.MethodGet, c.fileURL(name), nil, header)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", statusError("GET", name, resp)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to download %s: %w", name, err)
+	}
+	return data, resp.Header.Get("ETag"), nil
+}
+
+// Put writes a file and returns its new ETag, or "" if the server does
+// not report one. With ifMatch set the file must still have that ETag,
+// without it the file must not exist yet; otherwise
+// snapshot.ErrPreconditionFailed is returned. The folder is created if
+// it is missing.
+func (c *Client) Put(ctx context.Context, name string, data []byte, ifMatch string) (string, error) {
+	header := http.Header{"Content-Type": {"application/octet-stream"}}
+	if ifMatch != "" {
+		header.Set("If-Match", ifMatch)
+	} else {
+		header.Set("If-None-Match", "*")
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, err := c.do(ctx, http.MethodPut, c.fileURL(name), data, header)
+		if err != nil {
+			return "", err
+		}
+		resp.Body.Close()
+
+		switch {
+		case resp.StatusCode/100 == 2:
+			return resp.Header.Get("ETag"), nil
+		case resp.StatusCode == http.StatusConflict && attempt == 1:
+			// The folder does not exist yet
+			if err := c.makeFolder(ctx); err != nil {
+				return "", err
+			}
+		default:
+			return "", statusError("PUT", name, resp)
+		}
+	}
+}
+
+// Delete removes a file, if it still has the ETag ifMatch when set
+func (c *Client) Delete(ctx context.Context, name, ifMatch string) error {
+	header := http.Header{}
+	if ifMatch != "" {
+		header.Set("If-Match", ifMatch)
+	}
+
+	resp, err := c.do(ctx, http.MethodDelete, c.fileURL(name), nil, header)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return statusError("DELETE", name, resp)
+	}
+	return nil
+}
+
+// makeFolder creates the folder and any missing parents with MKCOL
+func (c *Client) makeFolder(ctx context.Context) error {
+	return c.mkcol(ctx, c.folder.Path)
+}
+
+// mkcol creates the collection at dir, first creating its parent if the
+// server reports it missing
+func (c *Client) mkcol(ctx context.Context, dir string) error {
+	u := *c.folder
+	u.Path = dir
+
+	for attempt := 1; ; attempt++ {
+		resp, err := c.do(ctx, "MKCOL", u.String(), nil, nil)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		switch {
+		// 405 means it already exists
+		case resp.StatusCode/100 == 2, resp.StatusCode == http.StatusMethodNotAllowed:
+			return nil
+		case resp.StatusCode == http.StatusConflict && attempt == 1 && path.Dir(strings.TrimSuffix(dir, "/")) != "/":
+			if err := c.mkcol(ctx, path.Dir(strings.TrimSuffix(dir, "/"))+"/"); err != nil {
+				return err
+			}
+		default:
+			return statusError("MKCOL", dir, resp)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/GareArc/opencode-sync/internal/config"
+	"github.com/GareArc/opencode-sync/internal/crypto"
+	"github.com/GareArc/opencode-sync/internal/paths"
+)
+
+// KeyInfo describes the installed encryption key
+type KeyInfo struct {
+	// Path is where the private key is stored
+	Path string
+
+	// PublicKey is the age recipient for the key
+	PublicKey string
+}
+
+// GenerateKey creates and installs a new encryption key, replacing any
+// existing one. Data encrypted with the old key becomes unreadable.
+func GenerateKey(ctx context.Context) (*KeyInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	keyPair, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	if err := installKey(keyPair.PrivateKey); err != nil {
+		return nil, err
+	}
+
+	return Key(ctx)
+}
+
+// ImportKey installs an existing age private key
+func ImportKey(ctx context.Context, privateKey string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if _, err := crypto.NewAgeEncryption(privateKey); err != nil {
+		return fmt.Errorf("invalid key format: %w", err)
+	}
+
+	return installKey(privateKey)
+}
+
+// ExportKey returns the installed private key
+func ExportKey(ctx context.Context) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	p, err := paths.Get()
+	if err != nil {
+		return "", fmt.Errorf("failed to get paths: %w", err)
+	}
+
+	if _, err := os.Stat(p.KeyFile()); os.IsNotExist(err) {
+		return "", ErrNoKey
+	}
+
+	return crypto.LoadKeyFromFile(p.KeyFile())
+}
+
+// Key returns information about the installed key
+func Key(ctx context.Context) (*KeyInfo, error) {
+	privateKey, err := ExportKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, err := crypto.GetPublicKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := paths.Get()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get paths: %w", err)
+	}
+
+	return &KeyInfo{Path: p.KeyFile(), PublicKey: publicKey}, nil
+}
+
+// installKey saves the key and enables encryption in an existing config
+func installKey(privateKey string) error {
+	p, err := paths.Get()
+	if err != nil {
+		return fmt.Errorf("failed to get paths: %w", err)
+	}
+
+	if err := p.EnsureDirs(); err != nil {
+		return fmt.Errorf("failed to create directories: %w", err)
+	}
+
+	if err := crypto.SaveKeyToFile(privateKey, p.KeyFile()); err != nil {
+		return fmt.Errorf("failed to save key: %w", err)
+	}
+
+	cfg, err := config.Load()
+	if err == nil && cfg != nil && !cfg.Encryption.Enabled {
+		cfg.Encryption.Enabled = true
+		if err := config.Save(cfg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
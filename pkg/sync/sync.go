@@ -0,0 +1,480 @@
+// Package sync is the public API for embedding opencode-sync into other
+// tools. It drives the same pipeline as the CLI without any terminal
+// output or prompts, and reports what happened through typed results.
+//
+// Cancelling ctx interrupts network operations and stops file copies
+// between files. An interrupted pull restores the local config from its
+// backup, and an interrupted push is finished by the next Push.
+package sync
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/GareArc/opencode-sync/internal/config"
+	"github.com/GareArc/opencode-sync/internal/crypto"
+	"github.com/GareArc/opencode-sync/internal/git"
+	"github.com/GareArc/opencode-sync/internal/paths"
+	"github.com/GareArc/opencode-sync/internal/state"
+	isync "github.com/GareArc/opencode-sync/internal/sync"
+)
+
+// Error categories. Errors returned by this package match at most one of
+// them with errors.Is.
+var (
+	// ErrNotConfigured is returned by Open when no configuration exists yet
+	ErrNotConfigured = config.ErrNoConfig
+
+	// ErrNoKey is returned when encrypted data needs a key that is not installed
+	ErrNoKey = crypto.ErrKeyMissing
+
+	// ErrAuth is returned when the remote rejected the credentials
+	ErrAuth = git.ErrAuth
+
+	// ErrNetwork is returned when the remote could not be reached or timed out
+	ErrNetwork = git.ErrNetwork
+
+	// ErrConflict is returned when remote and local changes conflict
+	ErrConflict = git.ErrConflict
+)
+
+// TimeoutError is returned when clone, pull or push exceeds
+// repo.timeoutSeconds. It matches context.DeadlineExceeded with errors.Is.
+type TimeoutError = git.TimeoutError
+
+// InvalidConfigError is returned by Pull when the pulled config would stop
+// OpenCode from starting. The local config is restored from the backup.
+type InvalidConfigError = isync.InvalidConfigError
+
+// Client syncs the OpenCode configuration of the current user
+type Client struct {
+	cfg    *config.Config
+	paths  *paths.Paths
+	repo   *git.BuiltinGit
+	syncer *isync.Syncer
+}
+
+// SetupOptions configures a new installation
+type SetupOptions struct {
+	// RepoURL is the remote sync repository. If it is set and the sync
+	// repository does not exist locally yet, it is cloned and applied.
+	// Otherwise a new repository is initialized from the local config.
+	RepoURL string
+
+	// Branch is the branch to sync (default "main")
+	Branch string
+
+	// Encryption enables encryption of secrets. A key is generated unless
+	// one exists or PrivateKey is set.
+	Encryption bool
+
+	// PrivateKey is an existing age private key to install
+	PrivateKey string
+
+	// IncludeAuth syncs OpenCode's auth.json (requires Encryption)
+	IncludeAuth bool
+
+	// IncludeMcpAuth syncs OpenCode's mcp-auth.json (requires Encryption)
+	IncludeMcpAuth bool
+
+	// IncludeSessions syncs OpenCode session history (requires Encryption)
+	IncludeSessions bool
+}
+
+// OpenOptions configures Open
+type OpenOptions struct {
+	// HostKeyChecker is the opencode-sync executable ssh runs to check
+	// the remote's host key against repo.ssh.fingerprint. It is needed
+	// with repo.ssh.hostKeyPolicy "fingerprint" and unused otherwise.
+	HostKeyChecker string
+}
+
+// PushOptions configures a push
+type PushOptions struct {
+	// Message overrides the commit subject
+	Message string
+}
+
+// PushResult describes the outcome of a push
+type PushResult struct {
+	// Pushed is false when there was nothing to push
+	Pushed bool
+
+	// Commit is the hash of the pushed commit
+	Commit string
+
+	// SkippedBinaries are binary files that were not synced
+	SkippedBinaries []string
+}
+
+// PullResult describes the outcome of a pull
+type PullResult struct {
+	// Updated is true when the pull brought in new commits
+	Updated bool
+
+	// HeadBefore and HeadAfter are the sync repo HEAD around the pull
+	HeadBefore string
+	HeadAfter  string
+
+	// BackupDir holds the local files as they were before the pull
+	BackupDir string
+
+	// InvalidConfig lists the config files the pull left broken. Pull
+	// otherwise restores them and fails, but in the direct layout the
+	// working tree is the repo and they are applied anyway.
+	InvalidConfig error
+}
+
+// Status describes the local sync state
+type Status struct {
+	// Clean is true when the sync repository has no uncommitted changes
+	Clean bool
+
+	// PendingFiles are local files that differ from the sync repository
+	PendingFiles []string
+
+	// ConflictFiles are files with unresolved conflicts
+	ConflictFiles []string
+}
+
+// Open returns a Client for the existing configuration. It returns
+// ErrNotConfigured if Setup has not been run.
+func Open(ctx context.Context, opts OpenOptions) (*Client, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	if cfg == nil {
+		return nil, ErrNotConfigured
+	}
+
+	p, err := paths.Get()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get paths: %w", err)
+	}
+	if err := configure(cfg, opts.HostKeyChecker); err != nil {
+		return nil, err
+	}
+
+	repo := isync.NewRepo(p)
+	if err := repo.Open(); err != nil {
+		return nil, fmt.Errorf("failed to open git repository: %w", err)
+	}
+
+	return newClient(cfg, p, repo)
+}
+
+// Setup writes the configuration, installs or generates the encryption
+// key and creates the sync repository
+func Setup(ctx context.Context, opts SetupOptions) (*Client, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	p, err := paths.Get()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get paths: %w", err)
+	}
+	if err := p.EnsureDirs(); err != nil {
+		return nil, fmt.Errorf("failed to create directories: %w", err)
+	}
+
+	cfg := config.Default()
+	cfg.Repo.URL = opts.RepoURL
+	if opts.Branch != "" {
+		cfg.Repo.Branch = opts.Branch
+	}
+	cfg.Encryption.Enabled = opts.Encryption || opts.PrivateKey != ""
+	cfg.Sync.IncludeAuth = opts.IncludeAuth
+	cfg.Sync.IncludeMcpAuth = opts.IncludeMcpAuth
+	cfg.Sync.IncludeSessions = opts.IncludeSessions
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	if opts.PrivateKey != "" {
+		if err := ImportKey(ctx, opts.PrivateKey); err != nil {
+			return nil, err
+		}
+	} else if cfg.Encryption.Enabled && !cfg.KeyFileExists() {
+		if _, err := GenerateKey(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := config.Save(cfg); err != nil {
+		return nil, err
+	}
+
+	// A new config has no pinned host key to check
+	if err := configure(cfg, ""); err != nil {
+		return nil, err
+	}
+
+	repo := isync.NewRepo(p)
+	repo.SetTimeout(cfg.Repo.Timeout())
+	if _, err := os.Stat(p.SyncGitDir()); err == nil {
+		if err := repo.Open(); err != nil {
+			return nil, fmt.Errorf("failed to open git repository: %w", err)
+		}
+		return newClient(cfg, p, repo)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if opts.RepoURL != "" {
+		// Clone expects to create the directory itself
+		if err := os.Remove(p.SyncRepoRoot()); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("sync repository directory is not empty: %w", err)
+		}
+		if err := repo.Clone(ctx, opts.RepoURL); err != nil {
+			return nil, err
+		}
+
+		c, err := newClient(cfg, p, repo)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.syncer.CopyFromRepo(ctx); err != nil {
+			return nil, fmt.Errorf("failed to apply configs: %w", err)
+		}
+		return c, nil
+	}
+
+	if err := repo.Init(); err != nil {
+		return nil, err
+	}
+
+	c, err := newClient(cfg, p, repo)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.syncer.CopyToRepo(ctx); err != nil {
+		return nil, fmt.Errorf("failed to copy configs: %w", err)
+	}
+	if err := c.syncer.WriteRepoGitFiles(); err != nil {
+		return nil, err
+	}
+	if err := repo.AddAll(); err != nil {
+		return nil, fmt.Errorf("failed to stage changes: %w", err)
+	}
+	if err := repo.Commit(isync.CommitMessage(fmt.Sprintf("Initial commit from %s", isync.Hostname()))); err != nil {
+		return nil, fmt.Errorf("failed to commit: %w", err)
+	}
+
+	return c, nil
+}
+
+// configure applies the settings the CLI applies at startup, such as
+// repo.ssh.hostKeyPolicy and repo.author. checker is the executable that
+// checks a pinned host key (see OpenOptions.HostKeyChecker).
+func configure(cfg *config.Config, checker string) error {
+	if cfg.Repo.SSH.HostKeyPolicy == config.HostKeyPolicyFingerprint && checker == "" {
+		return fmt.Errorf("repo.ssh.hostKeyPolicy \"fingerprint\" needs OpenOptions.HostKeyChecker")
+	}
+
+	isync.Configure(cfg, checker)
+	isync.Version = moduleVersion()
+	return nil
+}
+
+// moduleVersion returns the version of opencode-sync the embedding program
+// was built with, which the sync repo's manifest may require a minimum of.
+// Builds without one, such as those using a local replace, report "dev".
+func moduleVersion() string {
+	const modulePath = "github.com/GareArc/opencode-sync"
+
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "dev"
+	}
+
+	mod := &bi.Main
+	if mod.Path != modulePath {
+		mod = nil
+		for _, dep := range bi.Deps {
+			if dep.Path == modulePath {
+				mod = dep
+				break
+			}
+		}
+	}
+	if mod == nil || mod.Replace != nil || mod.Version == "" || mod.Version == "(devel)" {
+		return "dev"
+	}
+	return strings.TrimPrefix(mod.Version, "v")
+}
+
+func newClient(cfg *config.Config, p *paths.Paths, repo *git.BuiltinGit) (*Client, error) {
+	repo.SetTimeout(cfg.Repo.Timeout())
+	syncer, err := isync.Open(cfg, p, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{cfg: cfg, paths: p, repo: repo, syncer: syncer}, nil
+}
+
+// Push copies the local config into the sync repository, commits and
+// pushes it. A rejected push is rebased onto the remote and retried.
+func (c *Client) Push(ctx context.Context, opts PushOptions) (*PushResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := c.syncer.SetSparseCheckout(); err != nil {
+		return nil, err
+	}
+	if err := c.syncer.CopyToRepo(ctx); err != nil {
+		return nil, fmt.Errorf("failed to copy files: %w", err)
+	}
+
+	hasChanges, err := c.repo.HasChanges()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for changes: %w", err)
+	}
+
+	// replaced is the commit a squashed sync was folded into
+	var replaced string
+	if hasChanges {
+		subject := opts.Message
+		if subject == "" {
+			subject = fmt.Sprintf("Sync from %s at %s", isync.Hostname(), time.Now().Format("2006-01-02 15:04:05"))
+		}
+
+		if err := c.repo.AddAll(); err != nil {
+			return nil, fmt.Errorf("failed to stage changes: %w", err)
+		}
+		squash := c.cfg.Sync.HistoryMode == config.HistoryModeSquash
+		if replaced, err = isync.CommitSync(c.repo, subject, squash, time.Now()); err != nil {
+			return nil, fmt.Errorf("failed to commit: %w", err)
+		}
+	} else if unpushed, err := c.repo.UnpushedCommits(); (err != nil || unpushed == 0) && !isync.AuthBranchPending(c.repo) {
+		return &PushResult{SkippedBinaries: c.syncer.SkippedBinaries()}, nil
+	}
+
+	// An interrupted push keeps its commit; the next Push sends it
+	squashedHead, _ := c.repo.GetHead()
+	if replaced != "" {
+		err = isync.PushSquashed(ctx, c.repo, replaced)
+	} else {
+		err = isync.PushWithRetry(ctx, c.repo)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to push: %w", err)
+	}
+
+	head, _ := c.repo.GetHead()
+	headBefore, _ := c.repo.RevParse("HEAD~1")
+	squashed := replaced != "" && head == squashedHead
+	if squashed {
+		headBefore = replaced
+	}
+	_ = state.RecordOperation(&state.Operation{
+		Type:       state.OpPush,
+		Time:       time.Now(),
+		HeadBefore: headBefore,
+		HeadAfter:  head,
+		Squashed:   squashed,
+	})
+
+	return &PushResult{Pushed: true, Commit: head, SkippedBinaries: c.syncer.SkippedBinaries()}, nil
+}
+
+// Pull fetches remote changes, backs up the local config and applies the
+// sync repository to it. A pull this build cannot use is undone, and one
+// that leaves the config broken is rolled back from the backup.
+func (c *Client) Pull(ctx context.Context) (*PullResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	hasChanges, err := c.repo.HasChanges()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for changes: %w", err)
+	}
+	if hasChanges {
+		return nil, fmt.Errorf("local changes detected. Commit or discard them before pulling")
+	}
+
+	headBefore, _ := c.repo.GetHead()
+
+	if err := c.syncer.SetSparseCheckout(); err != nil {
+		return nil, err
+	}
+	if err := c.repo.Pull(ctx); err != nil {
+		return nil, fmt.Errorf("failed to pull: %w", err)
+	}
+
+	// A repo this build does not understand is not applied
+	if err := c.syncer.CheckPulled(headBefore); err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Renamed files are moved rather than copied anew
+	if err := c.syncer.DetectRenames(headBefore); err != nil {
+		return nil, fmt.Errorf("failed to detect renamed files: %w", err)
+	}
+
+	backup, err := c.syncer.BackupLocal()
+	if err != nil {
+		return nil, fmt.Errorf("failed to back up local config: %w", err)
+	}
+	if _, err := c.syncer.ApplyPulled(ctx, backup); err != nil {
+		return nil, err
+	}
+
+	headAfter, _ := c.repo.GetHead()
+	_ = state.RecordOperation(&state.Operation{
+		Type:       state.OpPull,
+		Time:       time.Now(),
+		HeadBefore: headBefore,
+		HeadAfter:  headAfter,
+		BackupDir:  backup.Dir,
+	})
+
+	return &PullResult{
+		Updated:       headBefore != headAfter,
+		HeadBefore:    headBefore,
+		HeadAfter:     headAfter,
+		BackupDir:     backup.Dir,
+		InvalidConfig: c.syncer.InvalidApplied(),
+	}, nil
+}
+
+// Status reports local changes and conflicts
+func (c *Client) Status(ctx context.Context) (*Status, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	st, err := c.syncer.GetState()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get state: %w", err)
+	}
+
+	pending, err := c.syncer.PendingChanges()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Status{
+		Clean:         st.IsClean,
+		PendingFiles:  pending,
+		ConflictFiles: st.ConflictFiles,
+	}, nil
+}